@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+// aclSupported reports whether --security-report can read ACLs on this platform
+const aclSupported = false
+
+// extendedACL reports no ACL entries; reading ACLs needs platform APIs not used here
+func extendedACL(string) []string {
+	return nil
+}
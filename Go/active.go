@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/fs"
+	"time"
+)
+
+var (
+	skipActive  time.Duration       // Files modified this close to the scan start are flagged as in flux
+	scanStarted time.Time           // When the walk began
+	inFluxCount int                 // Files flagged as in flux
+	inFluxFiles = map[string]bool{} // isInFlux's answer by full path, for the walk
+)
+
+// inFluxNote returns "(in flux)" for a file isInFlux flags, and counts it
+func inFluxNote(fullPath string, entry fs.DirEntry) string {
+	if !isInFlux(fullPath, entry) {
+		return ""
+	}
+	inFluxCount++
+	return "(in flux)"
+}
+
+// isInFlux reports whether a file is probably still being written: its mtime falls
+// inside the --skip-active window, or its size or mtime changes between two stats.
+// The answer is kept for the walk, so the tree, checksums and directory sizes agree
+// about a file that settles halfway through.
+func isInFlux(fullPath string, entry fs.DirEntry) bool {
+	if skipActive <= 0 || entry.IsDir() {
+		return false
+	}
+	if active, ok := inFluxFiles[fullPath]; ok {
+		return active
+	}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return false
+	}
+	active := scanStarted.Sub(info.ModTime()) < skipActive
+	if !active {
+		again, err := lstat(fullPath)
+		active = err == nil && (again.Size() != info.Size() || !again.ModTime().Equal(info.ModTime()))
+	}
+	inFluxFiles[fullPath] = active
+	return active
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// activeFixture has a file written after the scan starts next to settled ones, and two
+// directories that would be identical but for the file being written in one of them
+const activeFixture = `
+build/
+build/app.bin size=10
+build/app.log size=4 mtime=2099-01-01T00:00:00Z
+a/
+a/x.txt content="same"
+a/y.txt content="same" mtime=2099-01-01T00:00:00Z
+b/
+b/x.txt content="same"
+b/y.txt content="same" mtime=2099-01-01T00:00:00Z
+`
+
+// In-flux files get no digest and do not count toward directory sizes
+func TestInFluxFilesSkipChecksumsAndSizes(t *testing.T) {
+	_, root := renderJSONFixture(t, func() {
+		setOption(t, &skipActive, time.Second)
+		setOption(t, &checksumAlgo, "sha256")
+		setOption(t, &dirSizes, true)
+		setOption(t, &fixtureFS, fs.FS(testtree.MapFS(t, activeFixture)))
+	})
+	build := child(t, root.Children, "build")
+	log := child(t, build.Children, "app.log")
+	if log.Checksum != "" || log.ChecksumError != "skipped, in flux" || !log.InFlux {
+		t.Errorf("app.log = %+v, want no checksum because it is in flux", log)
+	}
+	if bin := child(t, build.Children, "app.bin"); !strings.HasPrefix(bin.Checksum, "sha256:") {
+		t.Errorf("app.bin checksum = %q", bin.Checksum)
+	}
+	if build.Size == nil || *build.Size != 10 {
+		t.Errorf("build size = %v, want 10 without app.log", build.Size)
+	}
+
+	md := renderFixture(t, testtree.MapFS(t, activeFixture), true, func() {
+		setOption(t, &outputFormat, formatMarkdown)
+		setOption(t, &skipActive, time.Second)
+		setOption(t, &checksumAlgo, "sha256")
+	})
+	if !strings.Contains(md, "[F] app.log (in flux) sha256:(skipped, in flux)\n") {
+		t.Errorf("md does not mark the skipped digest:\n%s", md)
+	}
+}
+
+// A directory holding a file being written is never collapsed as a repeat
+func TestInFluxFilesKeepSubtreesApart(t *testing.T) {
+	_, root := renderJSONFixture(t, func() {
+		setOption(t, &skipActive, time.Second)
+		setOption(t, &dedupeSubtrees, true)
+		setOption(t, &fixtureFS, fs.FS(testtree.MapFS(t, activeFixture)))
+	})
+	if b := child(t, root.Children, "b"); b.IdenticalTo != "" || len(b.Children) != 2 {
+		t.Errorf("b = %+v, want it shown in full", b)
+	}
+}
+
+// growingEntry is a listing entry whose Info is the file as the listing saw it
+type growingEntry struct {
+	fs.DirEntry
+	info fs.FileInfo
+}
+
+func (e growingEntry) Info() (fs.FileInfo, error) { return e.info, nil }
+
+// A file that grows between the listing and the second stat is in flux, and the
+// answer holds for the rest of the walk even once the file settles
+func TestInFluxDoubleStatIsCached(t *testing.T) {
+	fsys := testtree.MapFS(t, "grow.log size=5\n")
+	setOption(t, &treeFS, fs.FS(fsys))
+	setOption(t, &scan.Root, "fixture")
+	setOption(t, &skipActive, time.Second)
+	setOption(t, &scanStarted, time.Now())
+	resetWalkState()
+	t.Cleanup(resetWalkState)
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, _ := entries[0].Info()
+	entry := growingEntry{entries[0], listed}
+	fsys["grow.log"] = &fstest.MapFile{Data: []byte("grown since the listing"), ModTime: listed.ModTime()}
+	if !isInFlux("fixture/grow.log", entry) {
+		t.Fatal("a file that grew between the two stats is not in flux")
+	}
+	fsys["grow.log"] = &fstest.MapFile{Data: []byte("12345"), ModTime: listed.ModTime()}
+	if !isInFlux("fixture/grow.log", entry) {
+		t.Error("the answer changed within the walk")
+	}
+	resetWalkState()
+	if isInFlux("fixture/grow.log", entry) {
+		t.Error("a settled file is in flux in the next walk")
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// annotationSeparator starts a hand-written comment at the end of a tree line
+const annotationSeparator = " # "
+
+// annotationNode is a line of the previous output's tree
+type annotationNode struct {
+	label    string // Text after the type tag, without the comment
+	comment  string
+	name     string // Name of the entry it was matched to; empty while unmatched
+	children []*annotationNode
+}
+
+var (
+	preserveAnnotations bool                       // --preserve-annotations: carry # comments over from the existing output file
+	annotationDirs      map[string]*annotationNode // Previous tree node per relative directory path matched so far
+	annotationComments  = map[string]string{}      // Comment per relative path, for entries matched in this walk
+	annotationCarried   []string                   // Orphaned annotations listed by the existing output file
+	annotationRoot      *annotationNode            // Previous tree, nil when there is none
+)
+
+// annotationSource returns the output file annotations are read from: the first -o that is a file
+func annotationSource(locations []string) string {
+	for _, location := range locations {
+		if location != stdoutTarget && location != clipboardTarget {
+			return location
+		}
+	}
+	return ""
+}
+
+// loadAnnotations parses the tree of an existing markdown output. A missing file
+// is a first run and preserves nothing.
+func loadAnnotations(location string) {
+	data, err := os.ReadFile(location)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read annotations from %s: %v", location, err))
+	}
+	annotationRoot = parseAnnotations(data)
+	annotationDirs = map[string]*annotationNode{"": annotationRoot}
+}
+
+// parseAnnotations rebuilds the nesting of the tree lines inside ```sh fences from
+// their connector prefixes. The items of
+// an earlier orphaned annotations section are kept, so they stay until removed by hand.
+func parseAnnotations(data []byte) *annotationNode {
+	root := &annotationNode{}
+	stack := []*annotationNode{root} // stack[d] is the last node seen at depth d-1
+	inFence, inOrphans := false, false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inOrphans {
+			if item, ok := strings.CutPrefix(line, "- "); ok {
+				annotationCarried = append(annotationCarried, item)
+				continue
+			}
+			inOrphans = line == ""
+		}
+		if line == msg("annotations.heading") {
+			inOrphans = true
+			continue
+		}
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence && strings.TrimLeft(line, "`") == "sh"
+			continue
+		}
+		if !inFence {
+			continue
+		}
+		prefix, _, rest, ok := splitTreeLine(line)
+		if !ok {
+			continue
+		}
+		depth, ok := treeLineDepth(prefix)
+		if !ok || depth >= len(stack) {
+			continue
+		}
+		node := &annotationNode{label: rest}
+		if i := strings.Index(rest, annotationSeparator); i >= 0 {
+			node.label, node.comment = rest[:i], strings.TrimSpace(rest[i+len(annotationSeparator):])
+		}
+		parent := stack[depth]
+		parent.children = append(parent.children, node)
+		stack = append(stack[:depth+1], node)
+	}
+	return root
+}
+
+// treeLineDepth returns the nesting depth of an entry's connector prefix: the number
+// of pipe or space units before its branch connector. The file may have been written
+// with another --style, so the presets are tried after the current connectors.
+func treeLineDepth(prefix string) (int, bool) {
+	styles := []ftree.Style{connectors}
+	for _, name := range styleNames() {
+		styles = append(styles, ftree.Styles[name])
+	}
+	for _, style := range styles {
+		if depth, ok := styleDepth(prefix, style); ok {
+			return depth, true
+		}
+	}
+	return 0, false
+}
+
+// styleDepth counts the prefix units of one style before its branch connector
+func styleDepth(prefix string, style ftree.Style) (int, bool) {
+	depth := 0
+	for {
+		switch {
+		case strings.HasPrefix(prefix, style.Pipe):
+			prefix = prefix[len(style.Pipe):]
+		case strings.HasPrefix(prefix, style.Space):
+			prefix = prefix[len(style.Space):]
+		default:
+			rest := strings.TrimSuffix(prefix, " ")
+			return depth, rest == style.Branch || rest == style.LastBranch
+		}
+		depth++
+	}
+}
+
+// matchAnnotations pairs the previous lines under a directory with its entries.
+// A line belongs to the entry whose displayed name it starts with, followed by the
+// end of the line or a space before the annotations; the longest name wins, so
+// "a b" is not taken by "a".
+func matchAnnotations(dir string, entries []fs.DirEntry) {
+	if annotationRoot == nil {
+		return
+	}
+	rel := relativePath(dir, "")
+	if rel == "." {
+		rel = ""
+	}
+	node := annotationDirs[rel]
+	if node == nil {
+		return
+	}
+	for _, old := range node.children {
+		if old.name != "" {
+			continue
+		}
+		var best fs.DirEntry
+		bestName := ""
+		for _, entry := range entries {
+			shown, _ := displayName(entry.Name())
+			if iconMode != "" {
+				shown = iconPrefix(shown, entry.IsDir() || shouldDescend(filepath.Join(dir, entry.Name()), entry)) + shown
+			}
+			if len(shown) <= len(bestName) {
+				continue
+			}
+			if old.label == shown || strings.HasPrefix(old.label, shown+" ") {
+				best, bestName = entry, shown
+			}
+		}
+		if best == nil {
+			continue
+		}
+		childRel := relativePath(dir, best.Name())
+		if _, taken := annotationDirs[childRel]; taken {
+			continue
+		}
+		old.name = bestName
+		annotationDirs[childRel] = old
+		if old.comment != "" {
+			annotationComments[childRel] = old.comment
+		}
+	}
+}
+
+// annotationNote returns the preserved comment of an entry for the end of its line
+func annotationNote(rel string) string {
+	if comment := annotationComments[rel]; comment != "" {
+		return annotationSeparator + comment
+	}
+	return ""
+}
+
+// orphanedAnnotations lists the comments whose lines matched no entry of this run,
+// with the previous path of each, after those already orphaned before
+func orphanedAnnotations() []string {
+	orphans := append([]string(nil), annotationCarried...)
+	var walk func(node *annotationNode, parent string, matched bool)
+	walk = func(node *annotationNode, parent string, matched bool) {
+		for _, child := range node.children {
+			name, childMatched := child.name, matched && child.name != ""
+			if !childMatched {
+				name = child.label
+			}
+			rel := strings.TrimPrefix(parent+"/"+name, "/")
+			if item := fmt.Sprintf("%s: %s", codeSpan(rel), child.comment); !childMatched && child.comment != "" && !slices.Contains(orphans, item) {
+				orphans = append(orphans, item)
+			}
+			walk(child, rel, childMatched)
+		}
+	}
+	if annotationRoot != nil {
+		walk(annotationRoot, "", true)
+	}
+	return orphans
+}
+
+// writeOrphanedAnnotations appends the comments that could not be re-attached
+func writeOrphanedAnnotations(writer io.Writer) {
+	orphans := orphanedAnnotations()
+	if len(orphans) == 0 {
+		return
+	}
+	fmt.Fprintf(writer, "\n%s\n\n", msg("annotations.heading"))
+	for _, orphan := range orphans {
+		fmt.Fprintf(writer, "- %s\n", orphan)
+	}
+}
+
+// resetAnnotations forgets which previous lines were matched, for another render
+func resetAnnotations() {
+	annotationComments = map[string]string{}
+	if annotationRoot == nil {
+		return
+	}
+	annotationDirs = map[string]*annotationNode{"": annotationRoot}
+	var clear func(node *annotationNode)
+	clear = func(node *annotationNode) {
+		for _, child := range node.children {
+			child.name = ""
+			clear(child)
+		}
+	}
+	clear(annotationRoot)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of --anomalies findings, in the order the summary lists them
+const (
+	anomalySize      = "size"
+	anomalyAge       = "age"
+	anomalyExtension = "extension"
+)
+
+// anomalyThresholds are the rule parameters of --anomalies, set with --anomaly-thresholds
+type anomalyThresholds struct {
+	size     float64 // Standard deviations of log size above the siblings
+	age      float64 // Standard deviations of modification time away from the siblings
+	ext      float64 // Share of the files one extension needs before the others stand out
+	siblings int     // Fewest other files in the directory before a file is judged
+}
+
+// anomalyFinding is one file that stands out from its siblings
+type anomalyFinding struct {
+	kind    string
+	rel     string
+	score   float64 // Standard deviations, or for extensions the dominant share
+	details string
+}
+
+var (
+	anomaliesEnabled bool // --anomalies: flag files that stand out from their siblings
+	anomalyRules     = anomalyThresholds{size: 3, age: 3, ext: 0.95, siblings: 5}
+	anomalyFindings  []anomalyFinding
+	anomalyNotes     = map[string][]string{} // Anomaly kinds per relative path, for labels
+)
+
+// Floors for the standard deviations, so a directory of near-identical files does not
+// make a small difference an outlier: a factor of about 1.4 in size, one day in age
+const (
+	anomalySizeFloor = 0.5
+	anomalyAgeFloor  = 1.0
+)
+
+// anomalyTopN is how many findings of each kind the summary lists
+const anomalyTopN = 10
+
+// parseAnomalyThresholds reads --anomaly-thresholds, e.g. "size=4,age=3,ext=0.9,siblings=10"
+func parseAnomalyThresholds(spec string) error {
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return fmt.Errorf("expected key=value in --anomaly-thresholds, got %q", part)
+		}
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number <= 0 {
+			return fmt.Errorf("--anomaly-thresholds %s must be a positive number, got %q", key, value)
+		}
+		switch key {
+		case "size":
+			anomalyRules.size = number
+		case "age":
+			anomalyRules.age = number
+		case "ext":
+			if number > 1 {
+				return fmt.Errorf("--anomaly-thresholds ext is a share between 0 and 1, got %q", value)
+			}
+			anomalyRules.ext = number
+		case "siblings":
+			if number != math.Trunc(number) {
+				return fmt.Errorf("--anomaly-thresholds siblings must be a whole number, got %q", value)
+			}
+			anomalyRules.siblings = int(number)
+		default:
+			return fmt.Errorf("unknown --anomaly-thresholds key %q (use size, age, ext, siblings)", key)
+		}
+	}
+	return nil
+}
+
+// sampleStats holds sums of a sample, so the mean and deviation without any one
+// value can be taken in constant time
+type sampleStats struct {
+	n      int
+	sum    float64
+	sumSq  float64
+	values []float64
+}
+
+// add puts a value in the sample
+func (s *sampleStats) add(x float64) {
+	s.n++
+	s.sum += x
+	s.sumSq += x * x
+	s.values = append(s.values, x)
+}
+
+// without returns the mean and standard deviation of the sample without the value at i,
+// so one outlier does not drag its own baseline; the deviation is never below floor
+func (s *sampleStats) without(i int, floor float64) (mean, stddev float64) {
+	x := s.values[i]
+	n := float64(s.n - 1)
+	mean = (s.sum - x) / n
+	variance := (s.sumSq-x*x)/n - mean*mean
+	stddev = math.Sqrt(math.Max(variance, 0))
+	return mean, math.Max(stddev, floor)
+}
+
+// recordAnomalies compares the files of a directory listing with each other. Sizes
+// are compared on a log scale, since file sizes spread over orders of magnitude;
+// modification times are compared in days. Directories with fewer than the
+// configured number of siblings per file are skipped: a baseline of two or three
+// files says nothing about what is typical.
+func recordAnomalies(dir string, entries []fs.DirEntry) {
+	if !anomaliesEnabled {
+		return
+	}
+	var rels, exts []string
+	var sizes, ages sampleStats
+	var sizeBytes []int64
+	extCount := map[string]int{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entryInfo(entry)
+		if err != nil {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(entry.Name()))
+		rels = append(rels, relativePath(dir, entry.Name()))
+		exts = append(exts, ext)
+		extCount[ext]++
+		sizeBytes = append(sizeBytes, info.Size())
+		sizes.add(math.Log2(float64(info.Size()) + 1))
+		ages.add(float64(info.ModTime().Unix()) / 86400)
+	}
+	if len(rels) <= anomalyRules.siblings {
+		return
+	}
+
+	dominant, dominantCount := "", 0
+	for ext, count := range extCount {
+		if count > dominantCount || (count == dominantCount && ext < dominant) {
+			dominant, dominantCount = ext, count
+		}
+	}
+	share := float64(dominantCount) / float64(len(rels))
+
+	for i, rel := range rels {
+		if mean, stddev := sizes.without(i, anomalySizeFloor); (sizes.values[i]-mean)/stddev > anomalyRules.size {
+			z := (sizes.values[i] - mean) / stddev
+			addAnomaly(anomalySize, rel, z, fmt.Sprintf("%s, siblings typically %s (%.1fσ)",
+				formatSize(sizeBytes[i]), formatSize(int64(math.Exp2(mean)-1)), z))
+		}
+		if mean, stddev := ages.without(i, anomalyAgeFloor); math.Abs(ages.values[i]-mean)/stddev > anomalyRules.age {
+			z := (ages.values[i] - mean) / stddev
+			direction := "newer"
+			if z < 0 {
+				direction = "older"
+			}
+			addAnomaly(anomalyAge, rel, math.Abs(z), fmt.Sprintf("%s days %s than its siblings (%.1fσ)",
+				groupThousands(int(math.Abs(ages.values[i]-mean))), direction, math.Abs(z)))
+		}
+		// Only an extension rare enough that the rest is near uniform stands out
+		if exts[i] != dominant && share >= anomalyRules.ext && float64(extCount[exts[i]]) <= float64(len(rels))*(1-anomalyRules.ext) {
+			addAnomaly(anomalyExtension, rel, share, fmt.Sprintf("%s among %s %s files",
+				extLabel(exts[i]), groupThousands(dominantCount), extLabel(dominant)))
+		}
+	}
+}
+
+// extLabel names an extension for the summary
+func extLabel(ext string) string {
+	if ext == "" {
+		return "no extension"
+	}
+	return ext
+}
+
+// addAnomaly records a finding and the label note of its file
+func addAnomaly(kind, rel string, score float64, details string) {
+	anomalyFindings = append(anomalyFindings, anomalyFinding{kind, rel, score, details})
+	anomalyNotes[rel] = append(anomalyNotes[rel], kind)
+}
+
+// anomalyNote returns the annotation of a file that stands out, e.g. "(anomaly: size, age)"
+func anomalyNote(rel string) string {
+	kinds := anomalyNotes[rel]
+	if len(kinds) == 0 {
+		return ""
+	}
+	return "(anomaly: " + strings.Join(kinds, ", ") + ")"
+}
+
+// writeAnomalies appends the strongest findings of each kind, most extreme first
+func writeAnomalies(writer io.Writer) {
+	fmt.Fprintf(writer, "\n%s\n\n", msg("anomalies.heading"))
+	if len(anomalyFindings) == 0 {
+		fmt.Fprintln(writer, msg("anomalies.none"))
+		return
+	}
+	fmt.Fprintf(writer, "%s\n| --- | --- | --- |\n", msg("anomalies.table"))
+	for _, kind := range []string{anomalySize, anomalyAge, anomalyExtension} {
+		var findings []anomalyFinding
+		for _, finding := range anomalyFindings {
+			if finding.kind == kind {
+				findings = append(findings, finding)
+			}
+		}
+		sort.SliceStable(findings, func(i, j int) bool {
+			if findings[i].score != findings[j].score {
+				return findings[i].score > findings[j].score
+			}
+			return findings[i].rel < findings[j].rel
+		})
+		for i, finding := range findings {
+			if i == anomalyTopN {
+				fmt.Fprintf(writer, "| %s | %s | |\n", kind, msg("anomalies.more", groupThousands(len(findings)-anomalyTopN)))
+				break
+			}
+			fmt.Fprintf(writer, "| %s | %s | %s |\n", kind, tableSpan(redactPath(displayPath(finding.rel))), strings.ReplaceAll(finding.details, "|", `\|`))
+		}
+	}
+}
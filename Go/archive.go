@@ -0,0 +1,385 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of archives the tree can be read from
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+)
+
+var archivePath string // --archive: read the tree from this zip or tar archive instead of a directory
+
+// errArchiveContents is returned for reads of a file's contents inside an archive
+var errArchiveContents = errors.New("archive entries are listed, not extracted")
+
+// archiveKindByName detects an archive by its extension: .zip, .tar, .tar.gz or .tgz
+func archiveKindByName(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz
+	}
+	return ""
+}
+
+// archiveKindByContent detects an archive by its first bytes, for --archive paths
+// without a known extension
+func archiveKindByContent(f io.ReaderAt) string {
+	head := make([]byte, 262)
+	n, _ := f.ReadAt(head, 0)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return archiveZip
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return archiveTarGz
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return archiveTar
+	}
+	return ""
+}
+
+// isArchiveFile reports whether a -d path is an archive rather than a directory
+func isArchiveFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular() && archiveKindByName(p) != ""
+}
+
+// archiveEntry is a file, directory or link of an archive
+type archiveEntry struct {
+	name     string // Base name
+	mode     fs.FileMode
+	size     int64
+	modTime  time.Time
+	link     string   // Target of a symbolic link
+	children []string // Sorted names of a directory's entries
+}
+
+// archiveFS is the tree of an archive's entry names as an fs.FS. Archives list
+// entries in any order and often leave out the directories above them, so missing
+// directories are made up and every listing is sorted. Only names and metadata are
+// kept; contents are not extracted, and nested archives are plain files.
+type archiveFS struct {
+	entries map[string]*archiveEntry // By slash-separated path, "." for the root
+}
+
+// loadArchive reads the entry list of a zip or tar archive
+func loadArchive(p string) (*archiveFS, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	kind := archiveKindByName(p)
+	if kind == "" {
+		if kind = archiveKindByContent(f); kind == "" {
+			return nil, fmt.Errorf("%s is not a zip or tar archive", p)
+		}
+	}
+	a := &archiveFS{entries: map[string]*archiveEntry{
+		".": {name: ".", mode: fs.ModeDir | 0o755, modTime: info.ModTime()},
+	}}
+	switch kind {
+	case archiveZip:
+		err = a.readZip(f, info.Size())
+	default:
+		var r io.Reader = f
+		if kind == archiveTarGz {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", p, err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		err = a.readTar(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", p, err)
+	}
+	a.link(info.ModTime())
+	return a, nil
+}
+
+// readZip adds the entries of a zip archive; a link's target is its contents
+func (a *archiveFS) readZip(f io.ReaderAt, size int64) error {
+	r, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range r.File {
+		entry := &archiveEntry{mode: file.Mode(), size: int64(file.UncompressedSize64), modTime: file.Modified}
+		if strings.HasSuffix(file.Name, "/") {
+			entry.mode |= fs.ModeDir
+		}
+		if entry.mode&fs.ModeSymlink != 0 {
+			if rc, err := file.Open(); err == nil {
+				target, _ := io.ReadAll(io.LimitReader(rc, 4096))
+				rc.Close()
+				entry.link = string(target)
+			}
+		}
+		a.add(file.Name, entry)
+	}
+	return nil
+}
+
+// readTar adds the entries of a tar stream; hard links are listed as the files they are
+func (a *archiveFS) readTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry := &archiveEntry{mode: header.FileInfo().Mode(), size: header.Size, modTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			entry.link = header.Linkname
+		case tar.TypeLink:
+			entry.mode = entry.mode.Perm()
+		}
+		a.add(header.Name, entry)
+	}
+}
+
+// add records an entry by its cleaned name. Names that would leave the archive's
+// root, such as "../x", are skipped with a warning, since they do not belong in it.
+func (a *archiveFS) add(name string, entry *archiveEntry) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	clean := path.Clean("/" + slashed)[1:]
+	if strings.Contains("/"+slashed+"/", "/../") {
+		warnf("Skipping archive entry %q: it points outside the archive", name)
+		return
+	}
+	if clean == "" {
+		return
+	}
+	// A name listed twice keeps its last header, as extracting would
+	entry.name = path.Base(clean)
+	a.entries[clean] = entry
+}
+
+// link fills in the directories no entry names and the sorted child lists. An
+// entry under a file name turns that file into a directory, as extracting would.
+func (a *archiveFS) link(modTime time.Time) {
+	names := make([]string, 0, len(a.entries))
+	for name := range a.entries {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			parent, ok := a.entries[dir]
+			if !ok {
+				parent = &archiveEntry{name: path.Base(dir), mode: fs.ModeDir | 0o755, modTime: modTime}
+				a.entries[dir] = parent
+			} else if !parent.mode.IsDir() {
+				parent.mode, parent.link, parent.size = fs.ModeDir|0o755, "", 0
+			}
+			if dir == "." {
+				break
+			}
+		}
+	}
+	for name, entry := range a.entries {
+		if name == "." {
+			continue
+		}
+		parent := a.entries[path.Dir(name)]
+		parent.children = append(parent.children, entry.name)
+	}
+	for _, entry := range a.entries {
+		sort.Strings(entry.children)
+	}
+}
+
+// lookup returns the entry of a valid fs.FS name
+func (a *archiveFS) lookup(op, name string) (*archiveEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := a.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// Open returns a directory that can be listed, or a file whose contents cannot be read
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	entry, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveFile{fs: a, path: name, entry: entry}, nil
+}
+
+// ReadDir returns the sorted entries of a directory
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := a.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries := make([]fs.DirEntry, 0, len(entry.children))
+	for _, child := range entry.children {
+		entries = append(entries, fs.FileInfoToDirEntry(archiveInfo{a.entries[path.Join(name, child)]}))
+	}
+	return entries, nil
+}
+
+// Stat returns an entry's metadata; links inside archives are not followed
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := a.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return archiveInfo{entry}, nil
+}
+
+// Lstat returns an entry's metadata
+func (a *archiveFS) Lstat(name string) (fs.FileInfo, error) {
+	return a.Stat(name)
+}
+
+// ReadLink returns the target of a link entry
+func (a *archiveFS) ReadLink(name string) (string, error) {
+	entry, err := a.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if entry.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return entry.link, nil
+}
+
+// archiveInfo is the fs.FileInfo of an archive entry
+type archiveInfo struct {
+	entry *archiveEntry
+}
+
+func (i archiveInfo) Name() string       { return i.entry.name }
+func (i archiveInfo) Size() int64        { return i.entry.size }
+func (i archiveInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i archiveInfo) ModTime() time.Time { return i.entry.modTime }
+func (i archiveInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i archiveInfo) Sys() any           { return nil }
+
+// archiveFile is an opened archive entry
+type archiveFile struct {
+	fs     *archiveFS
+	path   string
+	entry  *archiveEntry
+	offset int // Children already returned by ReadDir(n)
+}
+
+// Stat returns the entry's metadata
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return archiveInfo{f.entry}, nil }
+
+// Read fails, since contents are not extracted
+func (f *archiveFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.path, Err: errArchiveContents}
+}
+
+// Close does nothing
+func (f *archiveFile) Close() error { return nil }
+
+// ReadDir lists a directory in batches of n, or whole for n <= 0
+func (f *archiveFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries, err := f.fs.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+	entries = entries[f.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(n, len(entries))]
+	}
+	f.offset += len(entries)
+	return entries, nil
+}
+
+var loadedArchive *archiveFS // The archive the tree is read from, when there is one
+
+// archiveRefusal is an option an archive cannot be read with, and whether it was given
+type archiveRefusal struct {
+	set  bool
+	name string
+}
+
+// openArchiveInput loads the archive given with --archive or as -d and makes it the
+// input directory. Options that read file contents or ask git are refused, since
+// only the archive's entry list is read; main adds the modes it decides itself.
+func openArchiveInput(refusals []archiveRefusal) {
+	if archivePath != "" && flagSet("d") {
+		usageExit("--archive cannot be combined with -d; pass the archive to one of them")
+	}
+	if archivePath == "" {
+		archivePath = scan.Root
+	}
+	refusals = append(refusals,
+		archiveRefusal{outputFormat == formatManifest, "-f manifest"},
+		archiveRefusal{checkLinks != "", "--check-links"},
+		archiveRefusal{useGitignore, "--gitignore"},
+		archiveRefusal{exportIgnore, "--export-ignore"},
+		archiveRefusal{useDockerignore, "--dockerignore"},
+		archiveRefusal{gitAge, "--git-age"},
+		archiveRefusal{gitStatus, "--git-status"},
+	)
+	for _, refused := range refusals {
+		if refused.set {
+			usageExit(fmt.Sprintf("%s cannot be used with an archive, whose files are listed but not extracted", refused.name))
+		}
+	}
+	archive, err := loadArchive(archivePath)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read the archive: %v", err))
+	}
+	loadedArchive = archive
+	scan.Root = archivePath
+	treeFS = archive
+}
+
+// rootFS returns the filesystem below a root the walk starts at: a test's fixture, the loaded archive
+// for its path, the directory otherwise
+func rootFS(root string) fs.FS {
+	if fixtureFS != nil {
+		return fixtureFS
+	}
+	if loadedArchive != nil && root == archivePath {
+		return loadedArchive
+	}
+	return os.DirFS(longPath(root))
+}
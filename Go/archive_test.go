@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Each fixture archive lists its files out of order and without most of their
+// directories, under unicode names, next to a nested archive: the tree gets the
+// missing directories, sorted children and the nested archive as a file, whatever the
+// archive's kind
+func TestArchiveFixtures(t *testing.T) {
+	const want = "├── [F] README.md\n" +
+		"├── [D] docs\n" +
+		"│   └── [F] guide.md\n" +
+		"├── [D] empty\n" +
+		"├── [D] src\n" +
+		"│   ├── [F] alpha.go\n" +
+		"│   ├── [D] deep\n" +
+		"│   │   └── [F] ünïcode.txt\n" +
+		"│   └── [F] zeta.go\n" +
+		"├── [F] vendor.zip\n" +
+		"└── [D] 日本語\n" +
+		"    └── [F] ファイル.txt\n"
+	for _, name := range []string{"fixture.zip", "fixture.tar", "fixture.tar.gz", "fixture.tgz"} {
+		archive, err := filepath.Abs(filepath.Join("testdata", "archives", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout, stderr, code := runFTG(t, t.TempDir(), "-d", archive, "-o", "-", "--no-summary")
+		if code != exitOK || stdout != want {
+			t.Errorf("%s: exit code %d, %s\ngot\n%s\nwant\n%s", name, code, stderr, stdout, want)
+		}
+	}
+}
+
+// --archive reads an archive whose name does not say what it is, and the exclusion
+// rules apply inside it as they do on disk
+func TestArchiveFlag(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "archives", "fixture.tgz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := testtree.Dir(t, "")
+	if err := os.WriteFile(filepath.Join(dir, "artifact"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const want = "├── [F] README.md\n" +
+		"├── [D] empty\n" +
+		"├── [D] src\n" +
+		"│   ├── [F] alpha.go\n" +
+		"│   └── [F] zeta.go\n" +
+		"├── [F] vendor.zip\n" +
+		"└── [D] 日本語\n" +
+		"    └── [F] ファイル.txt\n"
+	stdout, stderr, code := runFTG(t, dir, "--archive", "artifact", "-e", "docs,deep", "-o", "-", "--no-summary")
+	if code != exitOK || stdout != want {
+		t.Errorf("exit code %d, %s\ngot\n%s\nwant\n%s", code, stderr, stdout, want)
+	}
+}
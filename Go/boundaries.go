@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+var (
+	ownerBoundaries    bool                  // --owner-boundaries: mark directories owned by someone other than their parent's owner
+	ownerBoundaryDepth int                   // --owner-boundary-depth: ignore boundaries deeper than this, 0 for none
+	dirOwners          = map[string]string{} // Owners of the directories seen, by full path
+	boundaryFindings   []ownerBoundary       // Boundaries in walk order
+	boundaryNotes      = map[string]string{} // Notes of the boundaries found, by full path, so each is listed once
+)
+
+// ownerBoundary is a directory whose owner differs from its parent's
+type ownerBoundary struct {
+	rel      string
+	from, to string
+}
+
+// ownerOfDir returns the owner of a directory, looking it up once
+func ownerOfDir(fullPath string) string {
+	if owner, ok := dirOwners[fullPath]; ok {
+		return owner
+	}
+	owner := "unknown"
+	if info, err := statPath(fullPath); err == nil {
+		owner = fileOwner(info)
+	}
+	dirOwners[fullPath] = owner
+	return owner
+}
+
+// ownerBoundaryNote returns "(owner changes: alice -> bob)" for a directory whose
+// owner is not its parent's with --owner-boundaries. Owners that cannot be read,
+// as on Windows or inside an archive, never make a boundary.
+func ownerBoundaryNote(path string, entry fs.DirEntry) string {
+	if !ownerBoundaries || !entry.IsDir() {
+		return ""
+	}
+	fullPath := filepath.Join(path, entry.Name())
+	if note, ok := boundaryNotes[fullPath]; ok {
+		return note
+	}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return ""
+	}
+	owner := fileOwner(info)
+	dirOwners[fullPath] = owner
+	parent := ownerOfDir(path)
+	if owner == parent || owner == "unknown" || parent == "unknown" {
+		return ""
+	}
+	if ownerBoundaryDepth > 0 && entryDepth(fullPath) > ownerBoundaryDepth {
+		return ""
+	}
+	boundaryFindings = append(boundaryFindings, ownerBoundary{rel: relativePath(path, entry.Name()), from: parent, to: owner})
+	note := fmt.Sprintf("(owner changes: %s -> %s)", redactText(parent), redactText(owner))
+	boundaryNotes[fullPath] = note
+	return note
+}
+
+// writeOwnerBoundaries appends the --owner-boundaries section, in tree order
+func writeOwnerBoundaries(writer io.Writer) {
+	fmt.Fprintf(writer, "\n%s\n\n", msg("boundaries.heading"))
+	if len(boundaryFindings) == 0 {
+		fmt.Fprintln(writer, msg("boundaries.none"))
+		return
+	}
+	fmt.Fprintf(writer, "%s\n| --- | --- | --- |\n", msg("boundaries.table"))
+	for _, found := range boundaryFindings {
+		fmt.Fprintf(writer, "| %s | %s | %s |\n", tableSpan(redactPath(displayPath(found.rel))), tableSpan(redactText(found.from)), tableSpan(redactText(found.to)))
+	}
+}
@@ -0,0 +1,61 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	atFdcwd           = -100
+	atSymlinkNofollow = 0x100
+	statxBtime        = 0x800
+)
+
+// statxTimestamp mirrors struct statx_timestamp
+type statxTimestamp struct {
+	Sec  int64
+	Nsec uint32
+	_    int32
+}
+
+// statxBuf mirrors the leading fields of struct statx; the rest is padding
+type statxBuf struct {
+	Mask           uint32
+	Blksize        uint32
+	Attributes     uint64
+	Nlink          uint32
+	UID            uint32
+	GID            uint32
+	Mode           uint16
+	_              uint16
+	Ino            uint64
+	Size           uint64
+	Blocks         uint64
+	AttributesMask uint64
+	Atime          statxTimestamp
+	Btime          statxTimestamp
+	Ctime          statxTimestamp
+	Mtime          statxTimestamp
+	_              [128]byte
+}
+
+// birthTime returns the creation time of path using statx, when the kernel
+// and filesystem record one
+func birthTime(path string, _ fs.FileInfo) (time.Time, bool) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var buf statxBuf
+	dirfd := atFdcwd
+	_, _, errno := syscall.Syscall6(sysStatx, uintptr(dirfd), uintptr(unsafe.Pointer(p)),
+		atSymlinkNofollow, statxBtime, uintptr(unsafe.Pointer(&buf)), 0)
+	if errno != 0 || buf.Mask&statxBtime == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(buf.Btime.Sec, int64(buf.Btime.Nsec)), true
+}
@@ -0,0 +1,4 @@
+package main
+
+// sysStatx is the statx system call number on linux/amd64
+const sysStatx = 332
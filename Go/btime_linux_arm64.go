@@ -0,0 +1,4 @@
+package main
+
+// sysStatx is the statx system call number on linux/arm64
+const sysStatx = 291
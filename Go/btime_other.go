@@ -0,0 +1,13 @@
+//go:build !darwin && !windows && !(linux && (amd64 || arm64))
+
+package main
+
+import (
+	"io/fs"
+	"time"
+)
+
+// birthTime reports that creation times are unavailable on this platform
+func birthTime(string, fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+var (
+	scanBudget      time.Duration       // --budget: time the scan may take before the tree is rendered from what was read
+	budgetScanned   = map[string]bool{} // Directories listed within the budget
+	budgetUnscanned int                 // Directories found but left unread when the budget ran out
+	budgetNote      string              // Completeness note appended when the budget cut the scan short
+)
+
+// budgetReserve is the share of --budget kept for rendering: no directory is
+// listed once less than this is left
+const budgetReserve = 10
+
+// scanWithinBudget lists the tree breadth first until the budget is nearly spent, so
+// every level above the cut is complete however deep the tree goes. The listings go
+// to the walk's cache; directories the scan did not reach are shown as stubs.
+func scanWithinBudget(root string, rootEntries []fs.DirEntry, started time.Time) {
+	stopAt := started.Add(scanBudget - scanBudget/budgetReserve)
+	listingCache[root] = rootEntries
+	defer delete(listingCache, root) // The walk already holds the root listing
+	budgetScanned[root] = true
+	scanned, cutDepth := 1, 0
+	level := []string{root}
+	for depth := 1; len(level) > 0; depth++ {
+		var next []string
+		for _, dir := range level {
+			if entries, ok := listingCache[dir]; ok {
+				for _, entry := range visibleEntries(dir, entries) {
+					if shouldExclude(dir, entry) {
+						continue
+					}
+					if fullPath := filepath.Join(dir, entry.Name()); shouldDescend(fullPath, entry) {
+						next = append(next, fullPath)
+					}
+				}
+			}
+		}
+		for i, dir := range next {
+			if cutDepth > 0 || !time.Now().Before(stopAt) {
+				if cutDepth == 0 {
+					cutDepth = depth
+				}
+				budgetUnscanned += len(next) - i
+				next = next[:i]
+				break
+			}
+			// A listing that fails is read again by the walk, which reports the error
+			if entries, err := readDir(dir); err == nil {
+				listingCache[dir] = entries
+			}
+			budgetScanned[dir] = true
+			scanned++
+		}
+		level = next
+	}
+	if budgetUnscanned > 0 {
+		budgetNote = msg("summary.budget", groupThousands(scanned), groupThousands(scanned+budgetUnscanned), scanBudget, cutDepth)
+	}
+}
+
+// budgetStub reports whether a directory is shown as "(not scanned)" because the
+// budget ran out before it was listed
+func budgetStub(fullPath string) bool {
+	return scanBudget > 0 && !budgetScanned[fullPath]
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var runTimeout time.Duration // --timeout: cancel the run after this long, 0 for no limit
+
+// cancelGrace is how long a cancelled run has to stop on its own before it is ended
+// where it stands, for a read that hangs on a dead network mount
+const cancelGrace = 3 * time.Second
+
+// errInterrupted is the cause of a run cancelled by Ctrl-C or SIGTERM
+var errInterrupted = errors.New("interrupted")
+
+// pendingTemps are the temporary output files not yet committed or dropped. The lock
+// is held across a commit, so a forced exit never removes a file being renamed.
+var pendingTemps = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: map[string]bool{}}
+
+// runContext returns the context of the generation run, cancelled by the first Ctrl-C
+// or SIGTERM or once --timeout has passed. A run that has not stopped cancelGrace
+// later, or a second signal, is ended from here with its temporary output files
+// removed.
+func runContext() context.Context {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	if runTimeout > 0 {
+		time.AfterFunc(runTimeout, func() { cancel(fmt.Errorf("--timeout %s reached", runTimeout)) })
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel(errInterrupted)
+		case <-ctx.Done():
+		}
+		select {
+		case <-signals:
+		case <-time.After(cancelGrace):
+		}
+		pendingTemps.Lock()
+		removePendingTemps()
+		fmt.Fprintf(os.Stderr, "\nError: %v and the run did not stop; no output file was replaced\n", context.Cause(ctx))
+		exitProcess(exitCancelled)
+	}()
+	return ctx
+}
+
+// cancelExit reports how far a cancelled run got and exits with exitCancelled. The
+// walk stops before anything is written, so no output file is replaced.
+func cancelExit(ctx context.Context) {
+	pendingTemps.Lock()
+	removePendingTemps()
+	note := ""
+	if resumed != nil {
+		note = fmt.Sprintf("; the same command with --resume %s carries on from the last directory completed", resumed.location)
+	}
+	exitWith(exitCancelled, fmt.Sprintf("Cancelled (%v) after reading %s and listing %s; no output file was replaced%s",
+		context.Cause(ctx), treeCount(counters.readable, "directory", "directories"), treeCount(counters.dirs+counters.files, "entry", "entries"), note))
+}
+
+// removePendingTemps deletes the temporary output files; the caller holds the lock
+// and exits without releasing it
+func removePendingTemps() {
+	for name := range pendingTemps.names {
+		os.Remove(name)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+// cancellingFS cancels the run once it has listed after directories, whether they are
+// read whole or opened and streamed
+type cancellingFS struct {
+	fs.FS
+	after  int32
+	reads  *atomic.Int32
+	cancel context.CancelFunc
+}
+
+// listed counts a directory listing, cancelling the run on the after-th
+func (f cancellingFS) listed() {
+	if f.reads.Add(1) == f.after {
+		f.cancel()
+	}
+}
+
+// ReadDir lists a directory
+func (f cancellingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.listed()
+	return fs.ReadDir(f.FS, name)
+}
+
+// Open opens a file, counting a directory as a listing
+func (f cancellingFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err == nil {
+		if info, err := file.Stat(); err == nil && info.IsDir() {
+			f.listed()
+		}
+	}
+	return file, err
+}
+
+// Stat reads an entry's information without counting it
+func (f cancellingFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.FS, name)
+}
+
+// syntheticTree is a MapFS of dirs directories with files files each
+func syntheticTree(dirs, files int) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for d := range dirs {
+		for f := range files {
+			fsys[fmt.Sprintf("d%03d/sub/f%03d.txt", d, f)] = &fstest.MapFile{Data: []byte("x")}
+		}
+	}
+	return fsys
+}
+
+// Cancelling mid-walk stops every format within a few directory reads, whether the
+// listings are read ahead by --jobs or during the walk
+func TestCancelStopsWalkPromptly(t *testing.T) {
+	const dirs, cancelAt = 200, 20
+	for _, workers := range []int{1, max(2, walkJobs)} {
+		for _, format := range []string{formatMarkdown, formatText, formatJSON, formatHTML, formatMarkdownList, formatMermaid, formatCSV, formatSVG} {
+			t.Run(fmt.Sprintf("%s with %d jobs", format, workers), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				var reads atomic.Int32
+				fsys := cancellingFS{syntheticTree(dirs, 5), cancelAt, &reads, cancel}
+				got := renderFixtureContext(ctx, t, fsys, true, func() {
+					setOption(t, &outputFormat, format)
+					setOption(t, &walkJobs, workers)
+				})
+				if got != nil {
+					t.Errorf("a cancelled run rendered %d bytes", len(got))
+				}
+				if !errors.Is(ctx.Err(), context.Canceled) {
+					t.Fatalf("ctx.Err() = %v", ctx.Err())
+				}
+				// Reads the workers had started may finish; no later one begins
+				if n := reads.Load(); n > cancelAt+int32(workers) {
+					t.Errorf("%d of %d directories read after cancelling at %d", n, 2*dirs+1, cancelAt)
+				}
+			})
+		}
+	}
+}
+
+// A cancelled context fails the next listing before it is read
+func TestGetEntriesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var reads atomic.Int32
+	useFixture(t, cancellingFS{syntheticTree(1, 1), 0, &reads, cancel}, nil)
+	setOption(t, &treeFS, rootFS(scan.Root))
+	cancel()
+	if _, err := getEntries(ctx, filepath.Join(scan.Root, "d000")); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if n := reads.Load(); n != 0 {
+		t.Errorf("%d directories read after cancelling", n)
+	}
+}
+
+// --timeout cancels the run with its own exit code and leaves no output behind
+func TestTimeoutExitCode(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 50 {
+		os.MkdirAll(filepath.Join(dir, fmt.Sprintf("d%02d", i), "sub"), 0o755)
+	}
+	out := filepath.Join(t.TempDir(), "tree.md")
+	_, stderr, code := runFTG(t, dir, "--timeout", "1ns", "-o", out)
+	if code != exitCancelled || !strings.Contains(stderr, "--timeout 1ns reached") {
+		t.Errorf("exit code %d, stderr %q", code, stderr)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("a cancelled run wrote its output")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// changedSince is the --changed-since git ref: only files changed since it are shown
+var changedSince string
+
+// runGit runs a git command inside dir and returns its standard output
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkGitInput makes sure the input directory is there before git is asked about it,
+// so a mistyped -d is not reported as a directory outside a repository
+func checkGitInput(root string) error {
+	info, err := os.Stat(root)
+	switch {
+	case os.IsNotExist(err):
+		return fmt.Errorf("the input directory %s does not exist", root)
+	case err != nil:
+		return fmt.Errorf("cannot read the input directory %s: %v", root, err)
+	case !info.IsDir():
+		return fmt.Errorf("the input directory %s is not a directory", root)
+	}
+	return nil
+}
+
+// loadChangedSince collects the files changed between ref and HEAD plus untracked files,
+// relative to root; renamed files are annotated "(renamed)"
+func loadChangedSince(root, ref string) (keepSet, error) {
+	if err := checkGitInput(root); err != nil {
+		return nil, err
+	}
+	if _, err := runGit(root, "rev-parse", "--show-toplevel"); err != nil {
+		return nil, fmt.Errorf("%s is not inside a git repository", root)
+	}
+	if _, err := runGit(root, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown git ref %q", ref)
+	}
+
+	diff, err := runGit(root, "diff", "--name-status", "-z", "-M", "--relative", ref, "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %v", err)
+	}
+	untracked, err := runGit(root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("git ls-files failed: %v", err)
+	}
+
+	kept := keepSet{}
+	fields := strings.Split(string(diff), "\x00")
+	for i := 0; i+1 < len(fields); i++ {
+		status := fields[i]
+		switch {
+		case strings.HasPrefix(status, "R"), strings.HasPrefix(status, "C"):
+			// Renames and copies list the old path first; only the new one exists on disk
+			if i+2 >= len(fields) {
+				break
+			}
+			note := ""
+			if status[0] == 'R' {
+				note = "(renamed)"
+			}
+			kept.add(fields[i+2], note)
+			i += 2
+		case strings.HasPrefix(status, "D"):
+			// Deleted paths have nothing left to render
+			i++
+		default:
+			kept.add(fields[i+1], "")
+			i++
+		}
+	}
+	for _, name := range strings.Split(string(untracked), "\x00") {
+		if name != "" {
+			kept.add(name, "")
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	checksumAlgo    string                           // --checksum: sha256, sha1 or md5 digest appended to each file
+	checksumMaxSize int64  = 100 << 20               // --checksum-max-size: files above this are not hashed
+	checksums              = map[string]fileDigest{} // Digests by full path, hashed before the walk
+)
+
+// checksumHashes are the algorithms --checksum accepts
+var checksumHashes = map[string]func() hash.Hash{"sha256": sha256.New, "sha1": sha1.New, "md5": md5.New}
+
+// checksumShown is how many hex digits of each end of a digest the tree shows;
+// JSON has the whole digest
+const checksumShown = 8
+
+// fileDigest is the checksum of one file, or why there is none
+type fileDigest struct {
+	sum     string // Hex digest
+	skipped bool   // Larger than --checksum-max-size
+	inFlux  bool   // Still being written, see --skip-active
+	err     error  // Why the file could not be read
+}
+
+// digestJob is a file handed to a hashing worker
+type digestJob struct {
+	fullPath string
+	entry    fs.DirEntry
+	info     fs.FileInfo // As the digest cache was consulted, nil when it was not
+}
+
+// digestResult is a worker's digest of a file
+type digestResult struct {
+	digestJob
+	digest fileDigest
+}
+
+// hashFiles hashes every file the tree will show with a bounded pool of workers
+// before the walk, which then renders in order from the digests. Files are listed
+// here, on one goroutine, as filters use package state; workers only read. Digests
+// of unchanged files come from the digest cache, which is saved as the pass goes, so
+// a cancelled pass leaves what it hashed for the next run.
+func hashFiles(ctx context.Context, root string, rootEntries []fs.DirEntry) {
+	var files []digestJob
+	var walk func(dir string, entries []fs.DirEntry)
+	walk = func(dir string, entries []fs.DirEntry) {
+		for _, entry := range visibleEntries(dir, entries) {
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			fullPath := filepath.Join(dir, entry.Name())
+			if entry.Type().IsRegular() {
+				if !isInFlux(fullPath, entry) {
+					files = append(files, digestJob{fullPath: fullPath, entry: entry})
+				}
+				continue
+			}
+			if !shouldDescend(fullPath, entry) {
+				continue
+			}
+			subEntries, ok := listingCache[fullPath]
+			if !ok {
+				var err error
+				if subEntries, err = readDir(fullPath); err != nil {
+					// The walk reads it again and reports the error
+					continue
+				}
+				listingCache[fullPath] = subEntries
+			}
+			walk(fullPath, subEntries)
+		}
+	}
+	walk(root, rootEntries)
+
+	if hashCache == nil {
+		hashCache = loadHashCache()
+	}
+	pending := files[:0]
+	for _, job := range files {
+		info, err := entryInfo(job.entry)
+		if err == nil && info.Size() <= checksumMaxSize {
+			if sum, ok := hashCache.lookup(job.fullPath, info); ok {
+				checksums[job.fullPath] = fileDigest{sum: sum}
+				continue
+			}
+			job.info = info
+		}
+		pending = append(pending, job)
+	}
+
+	workers := walkJobs
+	if jobsAuto || workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	jobs := make(chan digestJob)
+	results := make(chan digestResult)
+	var running sync.WaitGroup
+	for i := 0; i < min(workers, len(pending)); i++ {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			for job := range jobs {
+				results <- digestResult{job, digestFile(job.fullPath, job.entry)}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, job := range pending {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		running.Wait()
+		close(results)
+	}()
+	saved := time.Now()
+	for result := range results {
+		checksums[result.fullPath] = result.digest
+		if result.info != nil && result.digest.sum != "" {
+			hashCache.store(result.fullPath, result.info, result.digest.sum)
+		}
+		if time.Since(saved) > hashCacheFlush {
+			hashCache.save()
+			saved = time.Now()
+		}
+	}
+	hashCache.save()
+	writeHashCacheStats(messages)
+}
+
+// digestFile hashes one file with the --checksum algorithm
+func digestFile(fullPath string, entry fs.DirEntry) fileDigest {
+	info, err := entry.Info()
+	if err != nil {
+		return fileDigest{err: err}
+	}
+	if info.Size() > checksumMaxSize {
+		return fileDigest{skipped: true}
+	}
+	f, err := openPath(fullPath)
+	if err != nil {
+		return fileDigest{err: err}
+	}
+	defer f.Close()
+	h := checksumHashes[checksumAlgo]()
+	if _, err := io.Copy(h, f); err != nil {
+		return fileDigest{err: err}
+	}
+	return fileDigest{sum: hex.EncodeToString(h.Sum(nil))}
+}
+
+// entryDigest returns a file's digest, hashing it now if the pre-pass did not
+func entryDigest(fullPath string, entry fs.DirEntry) fileDigest {
+	if digest, ok := checksums[fullPath]; ok {
+		return digest
+	}
+	if isInFlux(fullPath, entry) {
+		// A digest of a file being written is stale before anyone reads it
+		return fileDigest{inFlux: true}
+	}
+	return digestFile(fullPath, entry)
+}
+
+// checksumNote returns "sha256:ab12cd34…5678ef90" for a file with --checksum, the
+// reason in parentheses when it was skipped or could not be read
+func checksumNote(path string, entry fs.DirEntry) string {
+	if checksumAlgo == "" || !entry.Type().IsRegular() {
+		return ""
+	}
+	digest := entryDigest(filepath.Join(path, entry.Name()), entry)
+	switch {
+	case digest.skipped:
+		return checksumAlgo + ":(skipped, too large)"
+	case digest.inFlux:
+		return checksumAlgo + ":(skipped, in flux)"
+	case digest.err != nil:
+		return fmt.Sprintf("%s:(unreadable: %v)", checksumAlgo, digestError(digest.err))
+	case len(digest.sum) > 2*checksumShown:
+		return checksumAlgo + ":" + digest.sum[:checksumShown] + "…" + digest.sum[len(digest.sum)-checksumShown:]
+	}
+	return checksumAlgo + ":" + digest.sum
+}
+
+// checksumField returns the full digest for the JSON "checksum" field and, when
+// there is none, the reason for "checksumError"
+func checksumField(fullPath string, entry fs.DirEntry) (string, string) {
+	if checksumAlgo == "" || !entry.Type().IsRegular() {
+		return "", ""
+	}
+	digest := entryDigest(fullPath, entry)
+	switch {
+	case digest.skipped:
+		return "", "skipped, too large"
+	case digest.inFlux:
+		return "", "skipped, in flux"
+	case digest.err != nil:
+		return "", digestError(digest.err).Error()
+	}
+	return checksumAlgo + ":" + digest.sum, ""
+}
+
+// digestError drops the path from a read error, as the label already names the file
+func digestError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
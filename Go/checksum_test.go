@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/faultfs"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// checksumFixture holds files with well-known digests: "abc" and the empty file
+const checksumFixture = `
+abc.txt content=abc
+empty.txt
+locked.txt content=x
+`
+
+// Digests of checksumFixture's files, as FIPS 180 and RFC 1321 give them
+var knownDigests = map[string]map[string]string{
+	"sha256": {
+		"abc.txt":   "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"empty.txt": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	},
+	"sha1": {
+		"abc.txt":   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		"empty.txt": "da39a3ee5e6b4b0d3255bfef95601890afd80709",
+	},
+	"md5": {
+		"abc.txt":   "900150983cd24fb0d6963f7d28e17f72",
+		"empty.txt": "d41d8cd98f00b204e9800998ecf8427e",
+	},
+}
+
+// The tree shows each end of a digest and JSON the whole of it, for every algorithm;
+// a file that cannot be read gets its error inline and the others are still hashed
+func TestChecksumKnownDigests(t *testing.T) {
+	useHashCache(t)
+	fsys := faultfs.New(testtree.MapFS(t, checksumFixture), map[string]faultfs.Fault{"locked.txt": faultfs.Permission})
+	for algo, digests := range knownDigests {
+		t.Run(algo, func(t *testing.T) {
+			captureWarnings(t)
+			forgetHashCache(t)
+			tree := renderFixture(t, fsys, true, func() {
+				setOption(t, &noSummary, true)
+				setOption(t, &checksumAlgo, algo)
+			})
+			for name, sum := range digests {
+				if want := "[F] " + name + " " + algo + ":" + sum[:checksumShown] + "…" + sum[len(sum)-checksumShown:] + "\n"; !strings.Contains(tree, want) {
+					t.Errorf("got\n%s\nwant a line ending in %q", tree, want)
+				}
+			}
+			if want := "└── [F] locked.txt " + algo + ":(unreadable: permission denied)\n"; !strings.Contains(tree, want) {
+				t.Errorf("got\n%s\nwant %q", tree, want)
+			}
+
+			forgetHashCache(t)
+			data := renderFixture(t, fsys, true, func() {
+				setOption(t, &outputFormat, formatJSON)
+				setOption(t, &checksumAlgo, algo)
+			})
+			var root struct {
+				Children []struct {
+					Name     string `json:"name"`
+					Checksum string `json:"checksum"`
+				} `json:"children"`
+			}
+			if err := json.Unmarshal([]byte(data), &root); err != nil {
+				t.Fatalf("%v\n%s", err, data)
+			}
+			found := 0
+			for _, child := range root.Children {
+				if sum, ok := digests[child.Name]; ok {
+					found++
+					if child.Checksum != algo+":"+sum {
+						t.Errorf("%s has checksum %q, want %q", child.Name, child.Checksum, algo+":"+sum)
+					}
+				}
+			}
+			if found != len(digests) {
+				t.Errorf("JSON has %d of the %d files:\n%s", found, len(digests), data)
+			}
+		})
+	}
+}
+
+// Files above --checksum-max-size are not hashed and say so
+func TestChecksumMaxSize(t *testing.T) {
+	useHashCache(t)
+	got := renderFixture(t, testtree.MapFS(t, "abc.txt content=abc\nempty.txt\n"), true, func() {
+		setOption(t, &noSummary, true)
+		setOption(t, &checksumAlgo, "sha256")
+		setOption(t, &checksumMaxSize, 2)
+	})
+	want := "├── [F] abc.txt sha256:(skipped, too large)\n" +
+		"└── [F] empty.txt sha256:e3b0c442…7852b855\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+)
+
+// clipboardTarget is the -o value that sends the tree to the system clipboard
+const clipboardTarget = "clipboard"
+
+// clipboardWarnSize is the size above which clipboard managers commonly truncate or stall
+const clipboardWarnSize = 4 << 20
+
+// clipboardCommand picks the command that copies stdin to the clipboard on goos
+func clipboardCommand(goos string, getenv func(string) string, lookPath func(string) (string, error)) ([]string, error) {
+	have := func(name string) bool {
+		_, err := lookPath(name)
+		return err == nil
+	}
+	switch goos {
+	case "darwin":
+		if have("pbcopy") {
+			return []string{"pbcopy"}, nil
+		}
+	case "windows":
+		if have("clip.exe") {
+			return []string{"clip.exe"}, nil
+		}
+	default:
+		if getenv("WAYLAND_DISPLAY") != "" && have("wl-copy") {
+			return []string{"wl-copy"}, nil
+		}
+		if getenv("DISPLAY") != "" {
+			if have("xclip") {
+				return []string{"xclip", "-selection", "clipboard"}, nil
+			}
+			if have("xsel") {
+				return []string{"xsel", "--clipboard", "--input"}, nil
+			}
+		}
+		if getenv("WAYLAND_DISPLAY") == "" && getenv("DISPLAY") == "" {
+			return nil, errors.New("no clipboard available: no X11 or Wayland session (DISPLAY and WAYLAND_DISPLAY are unset)")
+		}
+		return nil, errors.New("no clipboard available: install wl-copy, xclip or xsel")
+	}
+	return nil, fmt.Errorf("no clipboard command found for %s", goos)
+}
+
+// copyToClipboard places the rendered tree on the system clipboard
+func copyToClipboard(data []byte) error {
+	args, err := clipboardCommand(runtime.GOOS, os.Getenv, exec.LookPath)
+	if err != nil {
+		return err
+	}
+	if len(data) > clipboardWarnSize {
+		warnf("Warning: copying %s to the clipboard; some clipboards truncate content this large", formatSize(int64(len(data))))
+	}
+	if args[0] == "clip.exe" {
+		data = clipboardUTF16(data)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s failed: %s", args[0], msg)
+		}
+		return fmt.Errorf("%s failed: %v", args[0], err)
+	}
+	return nil
+}
+
+// clipboardUTF16 encodes text as UTF-16LE with a byte order mark, the one encoding
+// clip.exe reads whatever the console's code page; UTF-8 would be stored as ANSI text,
+// and names with emoji or other characters beyond the BMP would turn into mojibake
+func clipboardUTF16(data []byte) []byte {
+	units := utf16.Encode([]rune(string(data)))
+	out := make([]byte, 2, 2+2*len(units))
+	out[0], out[1] = 0xFF, 0xFE
+	for _, unit := range units {
+		out = binary.LittleEndian.AppendUint16(out, unit)
+	}
+	return out
+}
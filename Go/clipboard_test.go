@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// The clipboard command is picked per platform from what the session provides and
+// what is installed, and a headless Linux names the missing variables
+func TestClipboardCommand(t *testing.T) {
+	for _, test := range []struct {
+		goos      string
+		env       map[string]string
+		installed []string
+		want      string // The command, or the error
+	}{
+		{"darwin", nil, []string{"pbcopy"}, "pbcopy"},
+		{"darwin", nil, nil, "no clipboard command found for darwin"},
+		{"windows", nil, []string{"clip.exe"}, "clip.exe"},
+		{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"wl-copy", "xclip"}, "wl-copy"},
+		{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0", "DISPLAY": ":0"}, []string{"xclip"}, "xclip -selection clipboard"},
+		{"linux", map[string]string{"DISPLAY": ":0"}, []string{"wl-copy", "xsel"}, "xsel --clipboard --input"},
+		{"freebsd", map[string]string{"DISPLAY": ":0"}, []string{"xclip", "xsel"}, "xclip -selection clipboard"},
+		{"linux", map[string]string{"WAYLAND_DISPLAY": "wayland-0"}, []string{"xclip"}, "no clipboard available: install wl-copy, xclip or xsel"},
+		{"linux", nil, []string{"wl-copy", "xclip"}, "no clipboard available: no X11 or Wayland session (DISPLAY and WAYLAND_DISPLAY are unset)"},
+	} {
+		getenv := func(name string) string { return test.env[name] }
+		lookPath := func(name string) (string, error) {
+			if slices.Contains(test.installed, name) {
+				return "/usr/bin/" + name, nil
+			}
+			return "", errors.New("not found")
+		}
+		args, err := clipboardCommand(test.goos, getenv, lookPath)
+		got := strings.Join(args, " ")
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("%s with %v and %v: got %q, want %q", test.goos, test.env, test.installed, got, test.want)
+		}
+	}
+}
+
+// clip.exe gets UTF-16LE with a byte order mark, characters beyond the BMP as
+// surrogate pairs
+func TestClipboardUTF16(t *testing.T) {
+	got := fmt.Sprintf("% x", clipboardUTF16([]byte("a├😀\n")))
+	if want := "ff fe 61 00 1c 25 3d d8 00 de 0a 00"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// Without a clipboard the run fails naming why, after writing its other outputs
+func TestClipboardUnavailable(t *testing.T) {
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("PATH", t.TempDir())
+	dir := t.TempDir()
+	for _, args := range [][]string{{"-o", "clipboard"}, {"--copy", "-o", "-"}} {
+		stdout, stderr, code := runFTG(t, dir, append([]string{"-d", testtree.Dir(t, "a.txt\n")}, args...)...)
+		if code != exitFatal || !strings.Contains(stderr, "Error: cannot copy to clipboard: no clipboard") {
+			t.Errorf("%q: exit code %d: %s", args, code, stderr)
+		}
+		if args[0] == "--copy" && !strings.Contains(stdout, "[F] a.txt") {
+			t.Errorf("%q: the tree did not reach standard output:\n%s", args, stdout)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+//go:build unix && !darwin
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// fakeXclip puts an xclip on PATH that stores what it is given, and returns the
+// file it stores it in
+func fakeXclip(t *testing.T) string {
+	t.Helper()
+	bin := t.TempDir()
+	clip := filepath.Join(t.TempDir(), "clipboard")
+	script := "#!/bin/sh\n[ \"$*\" = \"-selection clipboard\" ] || exit 2\ncat > '" + clip + "'\n"
+	if err := os.WriteFile(filepath.Join(bin, "xclip"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+	return clip
+}
+
+// -o clipboard and --copy hand the clipboard the render the other outputs get
+func TestCopyToClipboard(t *testing.T) {
+	clip := fakeXclip(t)
+	src := testtree.Dir(t, "a.txt\nsub/b.txt\n")
+	for _, args := range [][]string{{"-o", "clipboard"}, {"--copy", "-o", "-"}, {"-f", "json", "-o", "-", "-o", "clipboard"}} {
+		os.Remove(clip)
+		stdout, stderr, code := runFTG(t, t.TempDir(), append([]string{"-d", src, "--no-summary"}, args...)...)
+		copied, err := os.ReadFile(clip)
+		if code != exitOK || err != nil || !strings.Contains(stdout+stderr, "File tree has been copied to the clipboard") {
+			t.Fatalf("%q: exit code %d, %v: %s", args, code, err, stderr)
+		}
+		if !strings.Contains(string(copied), "b.txt") {
+			t.Errorf("%q: the clipboard holds\n%s", args, copied)
+		}
+		if slices.Contains(args, "-") && stdout != string(copied) {
+			t.Errorf("%q: standard output\n%s\nthe clipboard\n%s", args, stdout, copied)
+		}
+	}
+}
+
+// Content past clipboardWarnSize is still copied, with a warning
+func TestCopyToClipboardWarnsWhenLarge(t *testing.T) {
+	clip := fakeXclip(t)
+	logs := captureWarnings(t)
+	setOption(t, &progress.warnings, 0)
+	data := bytes.Repeat([]byte("x"), clipboardWarnSize+1)
+	if err := copyToClipboard(data); err != nil {
+		t.Fatal(err)
+	}
+	if copied, _ := os.ReadFile(clip); len(copied) != len(data) {
+		t.Errorf("%d bytes copied, want %d", len(copied), len(data))
+	}
+	if !strings.Contains(logs.String(), "Warning: copying 4.0 MB to the clipboard") {
+		t.Errorf("warnings: %q", logs.String())
+	}
+}
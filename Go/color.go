@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Values of --color
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// Classes of tree entries a painter can tell apart
+const (
+	classDir  = "dir"
+	classLink = "link"
+	classExec = "exec"
+	classFile = "file"
+)
+
+var colorMode = colorAuto // --color: auto colors the tree when it only goes to a terminal
+
+// treePainter decorates the parts of a tree line
+type treePainter interface {
+	connector(s string) string       // Prefixes, branches and connectors
+	name(label, class string) string // An entry's label, by entry class
+}
+
+// plainPainter writes tree lines unchanged; files, the clipboard and pipes always get it
+type plainPainter struct{}
+
+func (plainPainter) connector(s string) string   { return s }
+func (plainPainter) name(label, _ string) string { return label }
+
+// ansiPainter colors tree lines the way tree -C does with the default LS_COLORS
+type ansiPainter struct{}
+
+// ansiColors are the SGR codes of each entry class; plain files keep the terminal's color
+var ansiColors = map[string]string{classDir: "01;34", classLink: "01;36", classExec: "01;32"}
+
+func (ansiPainter) connector(s string) string {
+	if s == "" {
+		return s
+	}
+	return "\x1b[2m" + s + "\x1b[0m"
+}
+
+func (ansiPainter) name(label, class string) string {
+	code, ok := ansiColors[class]
+	if !ok {
+		return label
+	}
+	return "\x1b[" + code + "m" + label + "\x1b[0m"
+}
+
+// painter styles the lines of the tree being rendered
+var painter treePainter = plainPainter{}
+
+// parseColor validates --color
+func parseColor(mode string) error {
+	switch mode {
+	case colorAuto, colorAlways, colorNever:
+		colorMode = mode
+		return nil
+	}
+	return fmt.Errorf("unknown --color value %q (use auto, always or never)", mode)
+}
+
+// useColor decides whether the tree is painted. Escapes would end up in files, the
+// clipboard or uploads along with everything else rendered once, so color needs
+// standard output to be the only destination. auto also needs it to be a terminal
+// and NO_COLOR (https://no-color.org) to be unset; always means always.
+func useColor(locations []string) bool {
+	if colorMode == colorNever || postURL != "" || pipeCommand != "" {
+		return false
+	}
+	for _, location := range locations {
+		if location != stdoutTarget {
+			return false
+		}
+	}
+	if colorMode == colorAlways {
+		return true
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&fs.ModeCharDevice != 0
+}
+
+// entryClass returns the painter class of an entry. Only colored output needs to
+// know about execute bits, so plain output does not stat files for them.
+func entryClass(entry fs.DirEntry, entryType string) string {
+	switch entryType {
+	case "D":
+		return classDir
+	case "L":
+		return classLink
+	}
+	if _, plain := painter.(plainPainter); plain {
+		return classFile
+	}
+	if info, err := entryInfo(entry); err == nil && isExecutable(entry.Name(), info.Mode()) {
+		return classExec
+	}
+	return classFile
+}
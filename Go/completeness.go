@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// readFailure is a directory the walk found but could not list
+type readFailure struct {
+	rel    string
+	reason string
+}
+
+var (
+	noSummary    bool          // --no-summary: leave out the totals and filters lines under the tree
+	readFailures []readFailure // Unreadable directories in walk order, listed under the tree
+)
+
+// readErrorReason names why a directory could not be listed, briefly enough for the tree
+func readErrorReason(err error) string {
+	switch {
+	case errors.Is(err, syscall.ENOTDIR):
+		return "no longer a directory"
+	case os.IsNotExist(err):
+		return "removed during the scan"
+	case os.IsPermission(err):
+		return "permission denied"
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// recordReadFailure warns about a directory the walk could not list, counts it, and
+// records it for the list under the tree. walkTree calls it for every format.
+func recordReadFailure(dir, rel string, err error) {
+	warnf("%s", readDirError(dir, err))
+	countReadError(err)
+	readFailures = append(readFailures, readFailure{rel, readErrorReason(err)})
+}
+
+// printReadError marks an unreadable directory in the tree with a child entry, so the
+// output shows what is missing and not only stderr
+func printReadError(writer io.Writer, prefix string, err error) {
+	if _, err := fmt.Fprintf(writer, "%s%s [error: %s]\n", prefix, connectors.LastBranch, readErrorReason(err)); err != nil {
+		warnf("Error writing entry: %v", err)
+	}
+}
+
+// writeReadErrors appends the directories that could not be listed and why
+func writeReadErrors(writer io.Writer) {
+	if len(readFailures) == 0 {
+		return
+	}
+	fmt.Fprintf(writer, "\n%s\n\n", msg("errors.heading"))
+	for _, failure := range readFailures {
+		fmt.Fprintf(writer, "- %s: %s\n", codeSpan(redactPath(displayPath(failure.rel))), failure.reason)
+	}
+}
+
+// writeSummary appends the totals of the tree: directories, files, their size and
+// the entries exclusion rules hid
+func writeSummary(writer io.Writer) {
+	if scan.DirsOnly {
+		fmt.Fprintf(writer, "\n%s\n", msg("summary.dirsOnly", msgCount("count.dir", counters.dirs), groupThousands(excludedTotal())))
+		return
+	}
+	fmt.Fprintf(writer, "\n%s\n", msg("summary.totals", msgCount("count.dir", counters.dirs), msgCount("count.file", counters.files),
+		formatTotal(counters.bytes), groupThousands(excludedTotal())))
+}
+
+// estimateCompleteness returns the share of known directories the scan could list.
+//
+// The model is deliberately simple: every directory the walk discovered is either
+// listed (readable) or could not be entered (unreadable or vanished). Whatever sits
+// below an inaccessible directory is unknown and not counted, so the figure is an
+// upper bound on coverage of the whole namespace.
+func estimateCompleteness(readable, inaccessible int) float64 {
+	total := readable + inaccessible
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(readable) / float64(total)
+}
+
+// writeCompleteness appends the coverage estimate when some directories could not be entered
+func writeCompleteness(writer io.Writer) {
+	inaccessible := counters.unreadable + counters.vanished
+	if inaccessible == 0 {
+		return
+	}
+	fmt.Fprintf(writer, "\n%s\n", msg("summary.completeness",
+		estimateCompleteness(counters.readable, inaccessible), groupThousands(inaccessible)))
+}
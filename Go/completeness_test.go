@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/faultfs"
+)
+
+// The estimate is the share of discovered directories that could be listed
+func TestEstimateCompleteness(t *testing.T) {
+	for _, test := range []struct {
+		readable, inaccessible int
+		want                   float64
+	}{
+		{0, 0, 100},
+		{10, 0, 100},
+		{87, 13, 87},
+		{1, 3, 25},
+		{0, 1, 0},
+	} {
+		if got := estimateCompleteness(test.readable, test.inaccessible); got != test.want {
+			t.Errorf("%d readable, %d inaccessible: %v, want %v", test.readable, test.inaccessible, got, test.want)
+		}
+	}
+}
+
+// Inaccessible subtrees count once, at the directory that could not be entered:
+// of the nine directories of syntheticTree(4, 1), d002/sub is never discovered,
+// d001/sub and d002 cannot be entered, and the six others are listed
+func TestCompletenessReported(t *testing.T) {
+	captureWarnings(t)
+	faults := map[string]faultfs.Fault{"d001/sub": faultfs.Permission, "d002": faultfs.Vanished}
+	reset := func() {
+		setOption(t, &progress.warnings, 0)
+		setOption(t, &outputTruncated, false)
+	}
+	md := renderFaulty(t, faultfs.New(syntheticTree(4, 1), faults), faults, reset)
+	if want := "\nScan covered approximately 75% of reachable directories (2 inaccessible)\n"; !strings.Contains(md, want) {
+		t.Errorf("got\n%s\nwant it to hold %q", md, want)
+	}
+
+	got := renderFaulty(t, faultfs.New(syntheticTree(4, 1), faults), faults, func() {
+		reset()
+		setOption(t, &outputFormat, formatJSON)
+	})
+	var root struct {
+		Meta struct{ Completeness *jsonCompleteness }
+	}
+	if err := json.Unmarshal([]byte(got), &root); err != nil {
+		t.Fatal(err)
+	}
+	if c := root.Meta.Completeness; c == nil || c.Percent != 75 || c.Inaccessible != 2 {
+		t.Errorf("meta.completeness = %+v, want 75%% with 2 inaccessible", c)
+	}
+
+	clean := renderFixture(t, syntheticTree(4, 1), true, reset)
+	if strings.Contains(clean, "Scan covered") {
+		t.Errorf("a complete scan reports its coverage:\n%s", clean)
+	}
+}
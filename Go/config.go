@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config files hold persistent defaults in the options document schema, keyed by
+// long flag name or alias, as JSON or as flat TOML:
+//
+//	exclude = ".git,node_modules,dist"
+//	max-depth = 3
+//	format = "text"
+//	output-template = "trees/{dir}-{date}.{ext}"
+//
+// They are the lowest-priority source: the command line and --options-from win.
+var (
+	configPath     string                                  // --config: config file to use instead of the discovered one
+	noConfig       bool                                    // --no-config: load no config file
+	outputTemplate = "file_tree_{dir}_{date}_{time}.{ext}" // --output-template: default output path when no destination is given
+)
+
+// configNames are the files looked for in the input directory, in order
+var configNames = []string{".ftg.toml", ".ftg.json"}
+
+// repoConfigKeys are the options a config file found in the input directory may set:
+// what the tree shows and how. The directory may be someone else's repository, so
+// options that run commands, write or send anything, or read file descriptors are
+// only taken from ftg/config in the user config directory or an explicit --config.
+var repoConfigKeys = map[string]bool{
+	// Filters
+	"e": true, "exclude-from": true, "hidden": true, "include": true, "profile": true, "define-profile": true,
+	"rules-order": true, "only": true, "only-ext": true, "no-default-excludes": true, "gitignore": true,
+	"export-ignore": true, "dockerignore": true, "min-size": true, "max-size": true, "max-depth": true,
+	"auto-depth": true, "auto-depth-lines": true, "sample": true, "max-entries": true, "prune": true,
+	"dirs-only": true, "grep-name": true, "context": true, "grep-ignore-accents": true, "follow-symlinks": true,
+	"include-virtual": true, "dedupe-subtrees": true, "dedupe-mounts": true, "skip-active": true,
+	"redact-patterns": true, "redact-keep-ext": true, "redact-env": true,
+	// Display
+	"format": true, "color": true, "no-wrap": true, "ambiguous-wide": true, "trailing-slash": true,
+	"entry-format": true, "overview-depth": true, "svg-font-size": true, "svg-theme": true, "svg-glyphs": true,
+	"mermaid-direction": true, "mermaid-max-nodes": true, "link-base": true, "site-depth": true,
+	"root-label": true, "full-paths": true, "os-paths": true, "relative-to": true, "root-prefix": true,
+	"sort": true, "traversal": true, "lang": true, "style": true, "connectors": true, "size": true,
+	"dir-sizes": true, "disk-usage": true, "size-both": true, "btime": true, "mtime": true, "time-format": true,
+	"git-age": true, "git-status": true, "group-by": true, "usage-by": true, "usage-top": true, "name-stats": true,
+	"name-max": true, "name-suggest": true,
+	"crlf": true, "icons": true, "icon-map": true, "find-orphans": true, "checksum": true,
+	"checksum-max-size": true, "hash-cache-clear": true, "media-info": true, "provenance": true,
+	"explain-excludes": true, "lint-filters": true, "no-summary": true, "owner-boundaries": true, "owner-boundary-depth": true,
+	"anomalies": true, "anomaly-thresholds": true, "security-report": true, "manifest-allow-partial": true,
+	"check-links": true, "simulate-retention": true, "quiet": true, "progress": true,
+	// Output named inside the working directory, replacing only ftg's own earlier output
+	"output-template": true, "skip-unchanged": true,
+}
+
+// findConfig returns the config file for a run: .ftg.toml or .ftg.json in the input
+// directory, then ftg/config in the user config directory ($XDG_CONFIG_HOME), or "" for
+// none. local reports a file of the input directory.
+func findConfig(dir string) (path string, local bool) {
+	for _, name := range configNames {
+		if path := filepath.Join(dir, name); fileExists(path) {
+			return path, true
+		}
+	}
+	if base, err := os.UserConfigDir(); err == nil {
+		if path := filepath.Join(base, "ftg", "config"); fileExists(path) {
+			return path, false
+		}
+	}
+	return "", false
+}
+
+// loadConfig applies the config file to every option not set on the command line or
+// by --options-from. An explicit --config must exist; a discovered file that cannot
+// be parsed is an error too, rather than being skipped silently, and so is one in
+// the input directory setting an option outside repoConfigKeys.
+func loadConfig(dir string) error {
+	if noConfig {
+		return nil
+	}
+	path, local := configPath, false
+	if path == "" {
+		if path, local = findConfig(dir); path == "" {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config %s: %v", path, err)
+	}
+	doc, err := parseConfig(path, data)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	if local {
+		if err := checkRepoConfig(doc, path); err != nil {
+			return err
+		}
+	}
+	return applyDocument(doc, path)
+}
+
+// checkRepoConfig refuses a config of the input directory that sets an option outside
+// repoConfigKeys, naming them all, or an output template leading out of the working
+// directory
+func checkRepoConfig(doc map[string]any, path string) error {
+	var refused []string
+	for name := range doc {
+		if !repoConfigKeys[canonicalFlag(name)] {
+			refused = append(refused, flagName(name))
+		}
+		if template, ok := doc[name].(string); ok && canonicalFlag(name) == "output-template" && !filepath.IsLocal(template) {
+			return fmt.Errorf("config %s sets --output-template to %s, outside the working directory; "+
+				"a config in the scanned directory may only name output below it", path, template)
+		}
+	}
+	if len(refused) == 0 {
+		return nil
+	}
+	sort.Strings(refused)
+	return fmt.Errorf("config %s sets %s, which a config in the scanned directory may not; "+
+		"set it in ftg/config under the user config directory, pass --config %s to trust this file, or --no-config to ignore it",
+		path, strings.Join(refused, ", "), path)
+}
+
+// parseConfig decodes a config file or options document by extension; one without,
+// such as ftg/config or "-" for stdin, is JSON when it starts with { and TOML otherwise
+func parseConfig(path string, data []byte) (map[string]any, error) {
+	isJSON := filepath.Ext(path) == ".json"
+	if filepath.Ext(path) == "" {
+		isJSON = bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+	}
+	if !isJSON {
+		return parseTOML(data)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// parseTOML reads the flat subset of TOML config files need: key = value lines with
+// strings, integers, booleans and one-line arrays of them, and # comments. Tables
+// are rejected, since options have no sections.
+func parseTOML(data []byte) (map[string]any, error) {
+	doc := map[string]any{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported, put every option at the top level", n)
+		}
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.TrimSpace(key)
+		if len(key) >= 2 && key[0] == '\'' && key[len(key)-1] == '\'' {
+			// A literal key, taken as written
+			key = key[1 : len(key)-1]
+		} else if unquoted, err := strconv.Unquote(key); err == nil {
+			key = unquoted
+		}
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", n)
+		}
+		if _, dup := doc[key]; dup {
+			return nil, fmt.Errorf("line %d: %q is set twice", n, key)
+		}
+		value, rest, err := tomlValue(strings.TrimSpace(rest), true)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q after the value", n, rest)
+		}
+		doc[key] = value
+	}
+	return doc, scanner.Err()
+}
+
+// tomlValue parses the value at the start of s and returns it with the text after it
+func tomlValue(s string, arrays bool) (any, string, error) {
+	switch {
+	case s == "":
+		return nil, "", fmt.Errorf("missing value")
+	case s[0] == '"':
+		// A basic string: find the closing quote that is not escaped
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				text, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return nil, "", fmt.Errorf("invalid string %s", s[:i+1])
+				}
+				return text, s[i+1:], nil
+			}
+		}
+		return nil, "", fmt.Errorf("unterminated string")
+	case s[0] == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case s[0] == '[' && arrays:
+		var values []any
+		s = strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(s, "]") {
+			value, rest, err := tomlValue(s, false)
+			if err != nil {
+				return nil, "", err
+			}
+			values = append(values, value)
+			s = strings.TrimSpace(rest)
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimSpace(s[1:])
+			} else if !strings.HasPrefix(s, "]") {
+				return nil, "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+		return values, s[1:], nil
+	}
+	word, rest := s, ""
+	if i := strings.IndexAny(s, " \t,]#"); i >= 0 {
+		word, rest = s[:i], s[i:]
+	}
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	if _, err := strconv.Atoi(strings.ReplaceAll(word, "_", "")); err == nil {
+		return json.Number(strings.ReplaceAll(word, "_", "")), rest, nil
+	}
+	return nil, "", fmt.Errorf("unsupported value %q (use a quoted string, integer, boolean or array)", word)
+}
+
+// defaultOutputPath expands --output-template: {time} and {date} of the run, {ext} of
+// the format and {dir}, the input directory's name made safe for a file name on any
+// platform, so "D:\" gives "D", "\\server\share" gives "server_share" and "nul" "_nul"
+func defaultOutputPath(extension string) string {
+	now := time.Now()
+	dir := strings.Trim(sanitizeFileName(rootName(scan.Root)), "_")
+	if dir == "" || dir == "." {
+		dir = "root"
+	}
+	if reservedName(dir) {
+		// Trimming took off the _ that kept a directory called con from naming con.md
+		dir = "_" + dir
+	}
+	return strings.NewReplacer(
+		"{time}", now.Format("15-04-05"),
+		"{date}", now.Format("2006-01-02"),
+		"{ext}", extension,
+		"{dir}", dir,
+	).Replace(outputTemplate)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// A config in the scanned directory, which may be someone else's repository, cannot
+// run commands, send or write anything; the same file passed with --config can
+func TestRepoConfigRefused(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "pwned")
+	tests := []struct {
+		name, config, refused string
+	}{
+		{"pipe", `pipe = "touch ` + marker + `; cat"`, "--pipe"},
+		{"post", "post-url = \"http://127.0.0.1:1/\"\npost-auth-env = \"HOME\"", "--post-auth-env, --post-url"},
+		{"output", `o = ["` + marker + `"]` + "\nforce = true", "--force, -o"},
+		{"output alias", `output = "` + marker + `"`, "--output"},
+		{"inject", `inject = "` + marker + `"`, "--inject"},
+		{"logs", "history = \"" + marker + "\"\nusage-log = true\nresult-json-fd = 1", "--history, --result-json-fd, --usage-log"},
+		{"json", `{"pipe": "touch ` + marker + `; cat"}`, "--pipe"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := testtree.Dir(t, "README.md\nsrc/main.go\n")
+			name := ".ftg.toml"
+			if strings.HasPrefix(test.config, "{") {
+				name = ".ftg.json"
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(test.config+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			stdout, stderr, code := runFTG(t, dir, "-o", "-")
+			if code != exitFatal || stdout != "" || !strings.Contains(stderr, "sets "+test.refused+", which a config in the scanned directory may not") {
+				t.Errorf("exit %d, stdout %q, stderr %q; want exit %d refusing %s", code, stdout, stderr, exitFatal, test.refused)
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Fatalf("the config's command ran or its output was written: %s exists", marker)
+			}
+		})
+	}
+
+	t.Run("explicit config", func(t *testing.T) {
+		dir := testtree.Dir(t, "README.md\n")
+		config := filepath.Join(dir, ".ftg.toml")
+		if err := os.WriteFile(config, []byte("pipe = \"tr a-z A-Z\"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		stdout, stderr, code := runFTG(t, dir, "--config", config, "-o", "-")
+		if code != exitOK || !strings.Contains(stdout, "README.MD") {
+			t.Errorf("exit %d, stderr %q, stdout\n%s\nwant the tree through the pipe", code, stderr, stdout)
+		}
+	})
+}
+
+// Filter and display options still come from a repository's config, and so does an
+// output template naming a file below the working directory
+func TestRepoConfigAllowed(t *testing.T) {
+	dir := testtree.Dir(t, "README.md\nbuild/out.txt\nsrc/main.go\n")
+	config := "e = \"build\"\nformat = \"text\"\nmax-depth = 1\noutput-template = \"trees/tree.{ext}\"\nskip-unchanged = true\n"
+	if err := os.WriteFile(filepath.Join(dir, ".ftg.toml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "trees"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runFTG(t, dir); code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "trees", "tree.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree := string(data); strings.Contains(tree, "build") || !strings.Contains(tree, "└── … (1 entry omitted)\n") {
+		t.Errorf("tree ignores the config:\n%s", tree)
+	}
+
+	t.Run("template outside", func(t *testing.T) {
+		dir := testtree.Dir(t, "README.md\n")
+		if err := os.WriteFile(filepath.Join(dir, ".ftg.toml"), []byte("output-template = \"../tree.{ext}\"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, stderr, code := runFTG(t, dir); code != exitFatal || !strings.Contains(stderr, "outside the working directory") {
+			t.Errorf("exit %d, stderr %q; want the template refused", code, stderr)
+		}
+	})
+}
+
+// Every option a repository's config may set is one ftg has
+func TestRepoConfigKeys(t *testing.T) {
+	set, _, _ := parseArgs(t)
+	for name := range repoConfigKeys {
+		if set.Lookup(name) == nil {
+			t.Errorf("repoConfigKeys holds %q, which is not a flag", name)
+		}
+	}
+}
+
+// The command line beats --options-from, which beats the config; .ftg.toml is found
+// before .ftg.json, and a config of the input directory before ftg/config in the user
+// config directory. --no-config loads none and --config replaces the discovered one.
+func TestConfigPrecedence(t *testing.T) {
+	// ftgCommand points the user config directory at a fresh directory; this one replaces it
+	home := t.TempDir()
+	userDirs := []string{"HOME=" + home, "XDG_CONFIG_HOME=" + home, "APPDATA=" + home}
+	for _, env := range userDirs {
+		name, value, _ := strings.Cut(env, "=")
+		t.Setenv(name, value)
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		t.Skipf("no user config directory: %v", err)
+	}
+	user := filepath.Join(base, "ftg", "config")
+	if err := os.MkdirAll(filepath.Dir(user), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(user, []byte(`{"format": "json", "sort": "size"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		files map[string]string
+		args  []string
+		want  string
+	}{
+		{"user config", nil, nil, `{"format": "json", "sort": "size"}`},
+		{"toml", map[string]string{".ftg.toml": "format = \"text\"\nmax-depth = 1\n"}, nil, `{"format": "text", "max-depth": 1}`},
+		{"json", map[string]string{".ftg.json": `{"e": ".git,dist", "max-depth": 3}`}, nil, `{"e": ".git,dist", "max-depth": 3}`},
+		{"toml before json", map[string]string{".ftg.toml": "max-depth = 1\n", ".ftg.json": `{"max-depth": 3}`}, nil, `{"max-depth": 1}`},
+		{"command line", map[string]string{".ftg.toml": "format = \"text\"\nmax-depth = 1\n"}, []string{"-L", "4"}, `{"format": "text", "max-depth": 4}`},
+		{"options-from", map[string]string{".ftg.toml": "format = \"text\"\nmax-depth = 1\n", "opts.json": `{"format": "csv"}`},
+			[]string{"--options-from", "opts.json"}, `{"format": "csv", "max-depth": 1}`},
+		{"options-from and command line", map[string]string{".ftg.toml": "max-depth = 1\n", "opts.json": `{"max-depth": 2}`},
+			[]string{"--options-from", "opts.json", "-L", "5"}, `{"max-depth": 5}`},
+		{"no-config", map[string]string{".ftg.toml": "max-depth = 1\n"}, []string{"--no-config"}, `{"no-config": true}`},
+		{"config", map[string]string{".ftg.toml": "max-depth = 1\n", "other.toml": "output-template = 'trees/{dir}.{ext}'\n"},
+			[]string{"--config", "other.toml"}, `{"config": "other.toml", "output-template": "trees/{dir}.{ext}"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := testtree.Dir(t, "README.md\n")
+			for name, content := range test.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cmd := ftgCommand(t, dir, append(test.args, "--print-config")...)
+			cmd.Env = append(cmd.Env, userDirs...)
+			stdout, err := cmd.Output()
+			var got, want map[string]any
+			if err != nil || json.Unmarshal(stdout, &got) != nil {
+				t.Fatalf("%v: %s", err, stdout)
+			}
+			if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("printed %s, want %s", stdout, test.want)
+			}
+		})
+	}
+}
+
+// An explicit --config that is missing or malformed fails the run before any output,
+// naming the file
+func TestConfigErrors(t *testing.T) {
+	dir := testtree.Dir(t, "README.md\n")
+	for name, content := range map[string]string{
+		"table.toml":   "[filters]\ne = \"dist\"\n",
+		"array.toml":   "e = [\"a\", \"b\"\n",
+		"json.json":    `{"e": `,
+		"twice.toml":   "e = \"a\"\ne = \"b\"\n",
+		"unknown.toml": "no-such-option = true\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct{ config, want string }{
+		{"missing.toml", "Error: cannot read config missing.toml: "},
+		{"table.toml", "Error: invalid config table.toml: line 1: tables are not supported"},
+		{"array.toml", "Error: invalid config array.toml: line 1: expected , or ] in array"},
+		{"json.json", "Error: invalid config json.json: "},
+		{"twice.toml", "Error: invalid config twice.toml: line 2: \"e\" is set twice"},
+		{"unknown.toml", "no-such-option"},
+	} {
+		stdout, stderr, code := runFTG(t, dir, "--config", test.config, "-o", "-")
+		if code == exitOK || stdout != "" || !strings.Contains(stderr, test.want) {
+			t.Errorf("--config %s: exit code %d, stdout %q, stderr %q; want %q", test.config, code, stdout, stderr, test.want)
+		}
+	}
+}
+
+// The flat TOML subset reads strings of both quotes, integers with separators,
+// booleans, one-line arrays and trailing comments
+func TestParseTOML(t *testing.T) {
+	doc, err := parseTOML([]byte("# defaults\n\ne = \"a,\\\"b\\\"\" # excluded\n'quoted-key' = 'c:\\dir'\n" +
+		"max-depth = 1_000\nprune = true\nquiet = false\no = [\"x.md\", 'y.md']\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"e": `a,"b"`, "quoted-key": `c:\dir`, "max-depth": json.Number("1000"), "prune": true, "quiet": false,
+		"o": []any{"x.md", "y.md"},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("parsed %#v, want %#v", doc, want)
+	}
+	for input, wantErr := range map[string]string{
+		"e":               "line 1: expected key = value",
+		"= 1":             "line 1: missing key",
+		"e =":             "line 1: missing value",
+		"e = \"open":      "line 1: unterminated string",
+		"e = 1.5":         `line 1: unsupported value "1.5"`,
+		"e = \"a\" \"b\"": `line 1: unexpected "\"b\"" after the value`,
+	} {
+		if _, err := parseTOML([]byte(input + "\n")); err == nil || err.Error() != wantErr && !strings.HasPrefix(err.Error(), wantErr) {
+			t.Errorf("%q: error %v, want %s", input, err, wantErr)
+		}
+	}
+}
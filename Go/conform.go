@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// Layout templates for "ftg conform" describe the expected layout of a project:
+//
+//	required:
+//	  - cmd/
+//	  - internal/
+//	  - path: api/openapi.yaml
+//	    message: every service publishes its API
+//	forbidden:
+//	  - src/
+//	rules:
+//	  - glob: "cmd/*/main.go"
+//	    min: 1
+//	    message: each command has a main package
+//	  - glob: "**/*.exe"
+//	    max: 0
+//	    message: binaries are built, not committed
+//
+// A trailing "/" asks for a directory. Paths and globs are relative to the input
+// directory and are checked against the entries the walk shows, so excludes,
+// --hidden and --max-depth apply as they do to the tree.
+
+// Kinds of layout rules, in the order the template lists them
+const (
+	layoutRequired  = "required"
+	layoutForbidden = "forbidden"
+	layoutGlob      = "glob"
+)
+
+// layoutReportLimit is how many paths of a rule the markdown report lists
+const layoutReportLimit = 10
+
+// layoutRule is one check of a layout template
+type layoutRule struct {
+	kind    string
+	path    string // Path, or the glob of a glob rule; a trailing "/" asks for a directory
+	min     int    // Glob rules: fewest matches
+	max     int    // Glob rules: most matches, -1 for no limit
+	message string // Why the rule exists, shown when it fails
+}
+
+// layoutResult is the outcome of one rule, as reported in JSON
+type layoutResult struct {
+	Kind      string   `json:"kind"`
+	Path      string   `json:"path"`
+	Min       *int     `json:"min,omitempty"`
+	Max       *int     `json:"max,omitempty"`
+	Passed    bool     `json:"passed"`
+	Message   string   `json:"message,omitempty"`
+	Matches   int      `json:"matches"`
+	Missing   []string `json:"missing,omitempty"`
+	Offending []string `json:"offending,omitempty"`
+	Problem   string   `json:"problem,omitempty"`
+}
+
+// layoutReport is the JSON report of "ftg conform"
+type layoutReport struct {
+	Template string         `json:"template"`
+	Root     string         `json:"root"`
+	Passed   bool           `json:"passed"`
+	Rules    []layoutResult `json:"rules"`
+	Summary  struct {
+		Rules  int `json:"rules"`
+		Failed int `json:"failed"`
+	} `json:"summary"`
+}
+
+// runConform checks the tree below root against a layout template and prints the
+// report as markdown or, with -f json, as JSON. Failed rules exit with the
+// differences code, so CI can gate on it.
+func runConform(root, template string) {
+	data, err := os.ReadFile(template)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read layout template %s: %v", template, err))
+	}
+	doc, err := parseYAML(data)
+	if err != nil {
+		usageExit(fmt.Sprintf("invalid layout template %s: %v", template, err))
+	}
+	rules, err := layoutRules(doc)
+	if err != nil {
+		usageExit(fmt.Sprintf("invalid layout template %s: %v", template, err))
+	}
+
+	treeFS = rootFS(root)
+	// The subcommands run to the end; only the generation run can be cancelled
+	ctx := context.Background()
+	entries, err := getEntries(ctx, root)
+	if err != nil {
+		errorExit("Cannot read the input directory")
+	}
+	nodes := map[string]bool{} // Relative path of every walked entry, true for directories
+	walkLayout(ctx, root, entries, nodes)
+
+	report := layoutReport{Template: template, Root: redactPath(virtualPath(root)), Passed: true, Rules: []layoutResult{}}
+	for _, rule := range rules {
+		result := checkLayoutRule(rule, nodes)
+		if !result.Passed {
+			report.Passed = false
+			report.Summary.Failed++
+		}
+		report.Rules = append(report.Rules, result)
+	}
+	report.Summary.Rules = len(rules)
+
+	if outputFormat == formatJSON {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		writeLayoutReport(os.Stdout, report)
+	}
+	if !report.Passed {
+		exitProcess(exitDifferences)
+	}
+	exitProcess(runExitCode(true))
+}
+
+// walkLayout collects the entries the tree would show, the way the manifest walks them
+func walkLayout(ctx context.Context, dir string, entries []fs.DirEntry, nodes map[string]bool) {
+	for _, entry := range filterExcluded(dir, visibleEntries(dir, entries)) {
+		fullPath := filepath.Join(dir, entry.Name())
+		nodes[relativePath(dir, entry.Name())] = entry.IsDir()
+		if !entry.IsDir() || !shouldDescend(fullPath, entry) {
+			continue
+		}
+		subEntries, err := getEntries(ctx, fullPath)
+		if err != nil {
+			warnf("%s", readDirError(fullPath, err))
+			countReadError(err)
+			continue
+		}
+		walkLayout(ctx, fullPath, subEntries, nodes)
+	}
+}
+
+// layoutRules reads the rules of a parsed template. Required and forbidden items are
+// a path or a mapping with path and message; glob rules are mappings with glob and
+// optional min, max and message. Unknown keys are errors, so a typo cannot turn a
+// rule off.
+func layoutRules(doc map[string]any) ([]layoutRule, error) {
+	var rules []layoutRule
+	for key := range doc {
+		if key != layoutRequired && key != layoutForbidden && key != "rules" {
+			return nil, fmt.Errorf("unknown key %q (use required, forbidden, rules)", key)
+		}
+	}
+	for _, kind := range []string{layoutRequired, layoutForbidden} {
+		items, ok := doc[kind].([]any)
+		if !ok && doc[kind] != nil {
+			return nil, fmt.Errorf("%s must be a list of paths", kind)
+		}
+		for i, item := range items {
+			rule := layoutRule{kind: kind}
+			switch item := item.(type) {
+			case string:
+				rule.path = item
+			case map[string]any:
+				for field, value := range item {
+					text, isText := value.(string)
+					switch {
+					case !isText:
+						return nil, fmt.Errorf("%s item %d: %s must be a string", kind, i+1, field)
+					case field == "path":
+						rule.path = text
+					case field == "message":
+						rule.message = text
+					default:
+						return nil, fmt.Errorf("%s item %d: unknown key %q (use path, message)", kind, i+1, field)
+					}
+				}
+			default:
+				return nil, fmt.Errorf("%s item %d must be a path or a mapping", kind, i+1)
+			}
+			if err := checkLayoutPath(rule.path); err != nil {
+				return nil, fmt.Errorf("%s item %d: %v", kind, i+1, err)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	items, ok := doc["rules"].([]any)
+	if !ok && doc["rules"] != nil {
+		return nil, fmt.Errorf("rules must be a list")
+	}
+	for i, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("rule %d must be a mapping with glob, min, max and message", i+1)
+		}
+		rule := layoutRule{kind: layoutGlob, min: -1, max: -1}
+		for field, value := range fields {
+			text, _ := value.(string)
+			switch field {
+			case "glob":
+				rule.path = text
+			case "message":
+				rule.message = text
+			case "min", "max":
+				n, err := strconv.Atoi(text)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("rule %d: %s must be a non-negative integer, got %q", i+1, field, text)
+				}
+				if field == "min" {
+					rule.min = n
+				} else {
+					rule.max = n
+				}
+			default:
+				return nil, fmt.Errorf("rule %d: unknown key %q (use glob, min, max, message)", i+1, field)
+			}
+		}
+		if err := checkLayoutPath(rule.path); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		// A glob alone asks for at least one match; with only a max there is no minimum
+		if rule.min < 0 {
+			rule.min = 0
+			if rule.max < 0 {
+				rule.min = 1
+			}
+		}
+		if rule.max >= 0 && rule.max < rule.min {
+			return nil, fmt.Errorf("rule %d: max %d is below min %d", i+1, rule.max, rule.min)
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no rules (add required, forbidden or rules)")
+	}
+	return rules, nil
+}
+
+// checkLayoutPath rejects template paths that cannot name an entry below the input directory
+func checkLayoutPath(p string) error {
+	clean := strings.TrimSuffix(strings.TrimPrefix(p, "./"), "/")
+	switch {
+	case clean == "" || clean == ".":
+		return fmt.Errorf("missing path")
+	case strings.HasPrefix(clean, "/") || filepath.IsAbs(clean):
+		return fmt.Errorf("%q must be relative to the input directory", p)
+	case clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(clean, "/../"):
+		return fmt.Errorf("%q leaves the input directory", p)
+	}
+	return nil
+}
+
+// checkLayoutRule evaluates a rule over the walked entries
+func checkLayoutRule(rule layoutRule, nodes map[string]bool) layoutResult {
+	result := layoutResult{Kind: rule.kind, Path: rule.path, Message: rule.message}
+	pattern := strings.TrimPrefix(rule.path, "./")
+	wantDir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	shown := func(rel string) string { return redactPath(displayPath(rel)) }
+
+	switch rule.kind {
+	case layoutRequired:
+		isDir, exists := nodes[pattern]
+		switch {
+		case !exists:
+			result.Missing = []string{shown(pattern)}
+		case wantDir && !isDir:
+			result.Problem = "is a file, a directory is required"
+		default:
+			result.Matches = 1
+		}
+		result.Passed = exists && (!wantDir || isDir)
+	case layoutForbidden:
+		isDir, exists := nodes[pattern]
+		if exists && (!wantDir || isDir) {
+			result.Matches = 1
+			result.Offending = []string{shown(pattern)}
+		}
+		result.Passed = result.Matches == 0
+	case layoutGlob:
+		var matches []string
+		for rel, isDir := range nodes {
+			if (!wantDir || isDir) && ftree.MatchPath(pattern, rel) {
+				matches = append(matches, rel)
+			}
+		}
+		sort.Strings(matches)
+		minimum, maximum := rule.min, rule.max
+		result.Min, result.Max = &minimum, nil
+		if maximum >= 0 {
+			result.Max = &maximum
+		}
+		result.Matches = len(matches)
+		result.Passed = len(matches) >= rule.min && (rule.max < 0 || len(matches) <= rule.max)
+		if len(matches) > rule.max && rule.max >= 0 {
+			for _, rel := range matches {
+				result.Offending = append(result.Offending, shown(rel))
+			}
+		}
+	}
+	return result
+}
+
+// layoutRuleName describes a rule for the markdown report, e.g. "glob `**/*.exe` (max 0)"
+func layoutRuleName(result layoutResult) string {
+	name := result.Kind + " " + codeSpan(result.Path)
+	if result.Kind != layoutGlob {
+		return name
+	}
+	switch {
+	case result.Max == nil:
+		return fmt.Sprintf("%s (min %d)", name, *result.Min)
+	case *result.Min == 0:
+		return fmt.Sprintf("%s (max %d)", name, *result.Max)
+	}
+	return fmt.Sprintf("%s (%d to %d)", name, *result.Min, *result.Max)
+}
+
+// layoutDetails explains a rule's outcome for the markdown report
+func layoutDetails(result layoutResult) string {
+	var parts []string
+	switch {
+	case len(result.Missing) > 0:
+		parts = append(parts, "missing")
+	case result.Problem != "":
+		parts = append(parts, result.Problem)
+	case len(result.Offending) > 0:
+		spans := make([]string, 0, layoutReportLimit)
+		for i, rel := range result.Offending {
+			if i == layoutReportLimit {
+				spans = append(spans, fmt.Sprintf("and %s more", groupThousands(len(result.Offending)-layoutReportLimit)))
+				break
+			}
+			spans = append(spans, codeSpan(rel))
+		}
+		parts = append(parts, "present: "+strings.Join(spans, ", "))
+	case result.Kind == layoutGlob && result.Matches == 1:
+		parts = append(parts, "1 match")
+	case result.Kind == layoutGlob:
+		parts = append(parts, groupThousands(result.Matches)+" matches")
+	}
+	if !result.Passed && result.Message != "" {
+		parts = append(parts, result.Message)
+	}
+	return strings.ReplaceAll(strings.Join(parts, "; "), "|", `\|`)
+}
+
+// writeLayoutReport prints the markdown report of "ftg conform"
+func writeLayoutReport(writer io.Writer, report layoutReport) {
+	fmt.Fprintf(writer, "# Layout conformance of %s\n\nTemplate: %s\n\n", codeSpan(report.Root), codeSpan(report.Template))
+	fmt.Fprintln(writer, "| Result | Rule | Details |\n| --- | --- | --- |")
+	for _, result := range report.Rules {
+		verdict := "pass"
+		if !result.Passed {
+			verdict = "**fail**"
+		}
+		fmt.Fprintf(writer, "| %s | %s | %s |\n", verdict, layoutRuleName(result), layoutDetails(result))
+	}
+	if report.Passed {
+		fmt.Fprintf(writer, "\nAll %s passed.\n", plural(report.Summary.Rules, "rule"))
+		return
+	}
+	fmt.Fprintf(writer, "\n%s of %s failed.\n", groupThousands(report.Summary.Failed), plural(report.Summary.Rules, "rule"))
+}
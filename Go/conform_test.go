@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// layoutFixture is a service that follows most of the standard layout
+const layoutFixture = `
+cmd/api/main.go
+cmd/worker/
+internal/store/db.go
+api/
+src/legacy.go
+tools/build.exe
+`
+
+// conform runs "ftg conform -f json" over layoutFixture with this template and
+// decodes the report
+func conform(t *testing.T, template string) (layoutReport, int) {
+	t.Helper()
+	dir := testtree.Dir(t, layoutFixture)
+	file := filepath.Join(t.TempDir(), "layout.yaml")
+	if err := os.WriteFile(file, []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := runFTG(t, dir, "conform", "-f", "json", file)
+	var report layoutReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("decode: %v\nstdout %q\nstderr %q", err, stdout, stderr)
+	}
+	return report, code
+}
+
+func TestConformRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		passed   bool
+		check    func(*testing.T, layoutResult)
+	}{
+		{"required directory", "required:\n  - cmd/\n", true, nil},
+		{"required file", "required:\n  - internal/store/db.go\n", true, nil},
+		{"required but missing", "required:\n  - path: api/openapi.yaml\n    message: every service publishes its API\n", false, func(t *testing.T, r layoutResult) {
+			if len(r.Missing) != 1 || r.Missing[0] != "api/openapi.yaml" || r.Message != "every service publishes its API" {
+				t.Errorf("result = %+v", r)
+			}
+		}},
+		{"required directory is a file", "required:\n  - cmd/api/main.go/\n", false, func(t *testing.T, r layoutResult) {
+			if r.Problem != "is a file, a directory is required" {
+				t.Errorf("problem = %q", r.Problem)
+			}
+		}},
+		{"forbidden and present", "forbidden:\n  - src/\n", false, func(t *testing.T, r layoutResult) {
+			if len(r.Offending) != 1 || r.Offending[0] != "src" {
+				t.Errorf("offending = %v", r.Offending)
+			}
+		}},
+		{"forbidden and absent", "forbidden:\n  - vendor/\n", true, nil},
+		{"forbidden directory but a file", "forbidden:\n  - cmd/api/main.go/\n", true, nil},
+		{"glob with a minimum", "rules:\n  - glob: \"cmd/*/main.go\"\n    min: 2\n", false, func(t *testing.T, r layoutResult) {
+			if r.Matches != 1 || r.Min == nil || *r.Min != 2 || r.Max != nil {
+				t.Errorf("result = %+v", r)
+			}
+		}},
+		{"glob with a maximum", "rules:\n  - glob: \"**/*.exe\"\n    max: 0\n    message: binaries are built, not committed\n", false, func(t *testing.T, r layoutResult) {
+			if len(r.Offending) != 1 || r.Offending[0] != "tools/build.exe" {
+				t.Errorf("offending = %v", r.Offending)
+			}
+		}},
+		{"glob alone needs a match", "rules:\n  - glob: \"internal/*/\"\n", true, func(t *testing.T, r layoutResult) {
+			if r.Matches != 1 {
+				t.Errorf("matches = %d, want internal/store only", r.Matches)
+			}
+		}},
+		{"glob in range", "rules:\n  - glob: \"cmd/*/\"\n    min: 1\n    max: 2\n", true, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, code := conform(t, test.template)
+			if len(report.Rules) != 1 {
+				t.Fatalf("got %d results", len(report.Rules))
+			}
+			result := report.Rules[0]
+			if result.Passed != test.passed || report.Passed != test.passed {
+				t.Errorf("passed = %v, want %v (%+v)", result.Passed, test.passed, result)
+			}
+			if want := map[bool]int{true: exitOK, false: exitDifferences}[test.passed]; code != want {
+				t.Errorf("exit code = %d, want %d", code, want)
+			}
+			if test.check != nil {
+				test.check(t, result)
+			}
+		})
+	}
+}
+
+// A template that cannot be checked is a usage error, not a failed layout
+func TestConformRejectsTemplates(t *testing.T) {
+	for _, template := range []string{
+		"require:\n  - cmd/\n",
+		"required:\n  - path: cmd/\n    why: typo\n",
+		"rules:\n  - glob: \"*.go\"\n    min: 3\n    max: 1\n",
+		"forbidden:\n  - ../outside/\n",
+		"rules: []\n",
+	} {
+		dir := testtree.Dir(t, layoutFixture)
+		file := filepath.Join(t.TempDir(), "layout.yaml")
+		os.WriteFile(file, []byte(template), 0o644)
+		_, stderr, code := runFTG(t, dir, "conform", file)
+		if code != exitUsage || !strings.Contains(stderr, "invalid layout template") {
+			t.Errorf("%q: exit code %d, stderr %q", template, code, stderr)
+		}
+	}
+}
+
+// The markdown report has a row per rule and the tally
+func TestConformMarkdownReport(t *testing.T) {
+	dir := testtree.Dir(t, layoutFixture)
+	file := filepath.Join(t.TempDir(), "layout.yaml")
+	os.WriteFile(file, []byte("required:\n  - cmd/\nforbidden:\n  - src/\nrules:\n  - glob: \"**/*.exe\"\n    max: 0\n    message: binaries are built, not committed\n"), 0o644)
+	stdout, _, code := runFTG(t, dir, "conform", file)
+	if code != exitDifferences {
+		t.Errorf("exit code = %d, want %d", code, exitDifferences)
+	}
+	for _, want := range []string{
+		"| pass | required `cmd/` |  |\n",
+		"| **fail** | forbidden `src/` | present: `src` |\n",
+		"| **fail** | glob `**/*.exe` (max 0) | present: `tools/build.exe`; binaries are built, not committed |\n",
+		"\n2 of 3 rules failed.\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("report lacks %q:\n%s", want, stdout)
+		}
+	}
+}
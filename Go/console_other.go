@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// useUTF8Console does nothing: terminals here take the UTF-8 bytes as they are
+func useUTF8Console() {}
+
+// restoreConsole does nothing, as useUTF8Console changed nothing
+func restoreConsole() {}
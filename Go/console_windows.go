@@ -0,0 +1,37 @@
+package main
+
+import "syscall"
+
+var (
+	procGetConsoleOutputCP = syscall.NewLazyDLL("kernel32.dll").NewProc("GetConsoleOutputCP")
+	procSetConsoleOutputCP = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleOutputCP")
+	savedConsoleCP         uintptr // Code page the console had before the run, 0 when it was left alone
+)
+
+// codePageUTF8 is CP_UTF8
+const codePageUTF8 = 65001
+
+// useUTF8Console switches the console the run is attached to to UTF-8 until it ends.
+// Go writes to a console with WriteConsoleW, so names outside the ANSI code page and
+// surrogate pairs show either way; but the --pipe command, and the shell reading
+// redirected output, decode the bytes they get with the console's code page, which
+// turns UTF-8 names into mojibake unless it is UTF-8 too.
+func useUTF8Console() {
+	cp, _, _ := procGetConsoleOutputCP.Call()
+	if cp == 0 || cp == codePageUTF8 {
+		// No console, or nothing to change
+		return
+	}
+	if ok, _, _ := procSetConsoleOutputCP.Call(codePageUTF8); ok != 0 {
+		savedConsoleCP = cp
+	}
+}
+
+// restoreConsole gives the console back the code page it had, since the change
+// would otherwise outlast the run in the user's window
+func restoreConsole() {
+	if savedConsoleCP != 0 {
+		procSetConsoleOutputCP.Call(savedConsoleCP)
+		savedConsoleCP = 0
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// formatCSV and formatTSV select the flat table of every entry, for spreadsheets;
+// formatPaths the bare list of paths, for diff and ftg diff -f unified
+const (
+	formatCSV   = "csv"
+	formatTSV   = "tsv"
+	formatPaths = "paths"
+)
+
+// csvHeader names the columns of -f csv and -f tsv
+var csvHeader = []string{"path", "type", "depth", "size", "mtime"}
+
+// renderCSV returns one row per entry under a header row, in the order of the tree or
+// with --traversal bfs a level at a time: the path from the input directory with forward slashes, D, F or L, the depth with
+// the top level at 1, the size in bytes (empty for directories) and the modification
+// time in RFC 3339 UTC. walkTree decides what is listed, as it does for the
+// trees, so exclusions, --max-depth and the size filters apply alike. -f csv writes
+// paths as they are, encoding/csv quoting the fields that need it; -f tsv separates
+// the fields with tabs and escapes path segments like tree names, since a TSV row
+// cannot hold a tab or a line break.
+func renderCSV(ctx context.Context, root string, entries []fs.DirEntry) ([]byte, error) {
+	var out bytes.Buffer
+	writer := csv.NewWriter(&out)
+	if outputFormat == formatTSV {
+		writer.Comma = '\t'
+	}
+	writeRow := func(row []string) {
+		if err := writer.Write(row); err != nil {
+			warnf("Error writing entry: %v", err)
+		}
+	}
+	writeRow(csvHeader)
+	err := walkTree(ctx, root, entries, func(e treeEntry) error {
+		if e.event != walkEntry {
+			return nil
+		}
+		if _, redacted := redactName(e.entry.Name()); redacted {
+			redactedCount++
+		}
+		row := []string{csvPath(e.rel), e.entryType, strconv.Itoa(e.depth), "", ""}
+		if e.size >= 0 {
+			row[3] = strconv.FormatInt(e.size, 10)
+		}
+		if info, err := entryInfo(e.entry); err == nil {
+			row[4] = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		writeRow(row)
+		return nil
+	})
+	if err != nil {
+		return walkFailure(ctx, err)
+	}
+	writer.Flush()
+	return out.Bytes(), nil
+}
+
+// renderPaths returns one line per entry in the order of -f csv: its path from the
+// input directory with forward slashes, escaped like -f tsv so that every line holds
+// one path, and with a / after a directory. Diffs of two snapshots then show a
+// directory that became a file, which readPathsSnapshot tells apart in turn.
+func renderPaths(ctx context.Context, root string, entries []fs.DirEntry) ([]byte, error) {
+	var out bytes.Buffer
+	err := walkTree(ctx, root, entries, func(e treeEntry) error {
+		if e.event != walkEntry {
+			return nil
+		}
+		if _, redacted := redactName(e.entry.Name()); redacted {
+			redactedCount++
+		}
+		out.WriteString(pathsLine(e.rel, e.entryType == "D" || e.descend))
+		return nil
+	})
+	if err != nil {
+		return walkFailure(ctx, err)
+	}
+	return out.Bytes(), nil
+}
+
+// pathsLine returns the -f paths line of the entry at rel
+func pathsLine(rel string, isDir bool) string {
+	line := printablePath(filepath.ToSlash(redactPath(displayPath(rel))))
+	if isDir {
+		line += "/"
+	}
+	return line + "\n"
+}
+
+// csvPath returns the path column of an entry at rel: its displayed, redacted path,
+// with the segments escaped for -f tsv
+func csvPath(rel string) string {
+	p := filepath.ToSlash(redactPath(displayPath(rel)))
+	if outputFormat == formatTSV {
+		return printablePath(p)
+	}
+	return p
+}
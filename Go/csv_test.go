@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestGoldenCSV(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), true, func() {
+		setOption(t, &outputFormat, formatCSV)
+	})
+	testtree.Golden(t, "tree.csv", []byte(got))
+}
+
+func TestGoldenTSV(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), true, func() {
+		setOption(t, &outputFormat, formatTSV)
+	})
+	testtree.Golden(t, "tree.tsv", []byte(got))
+}
+
+func TestGoldenPaths(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), true, func() {
+		setOption(t, &outputFormat, formatPaths)
+	})
+	testtree.Golden(t, "tree.paths", []byte(got))
+}
+
+// traversalTree has three levels, with names that sort differently from how they nest
+const traversalTree = `
+b/c/deep.txt
+b/a.txt
+a/x.txt
+z.txt
+`
+
+// --traversal keeps each directory's entries together and in order: dfs puts them
+// right after their directory, bfs after every entry of the level above
+func TestCSVTraversal(t *testing.T) {
+	tests := []struct {
+		format, traversal string
+		want              []string
+	}{
+		{formatCSV, traversalDFS, []string{"a", "a/x.txt", "b", "b/a.txt", "b/c", "b/c/deep.txt", "z.txt"}},
+		{formatCSV, traversalBFS, []string{"a", "b", "z.txt", "a/x.txt", "b/a.txt", "b/c", "b/c/deep.txt"}},
+		{formatTSV, traversalBFS, []string{"a", "b", "z.txt", "a/x.txt", "b/a.txt", "b/c", "b/c/deep.txt"}},
+		{formatPaths, traversalBFS, []string{"a", "b", "z.txt", "a/x.txt", "b/a.txt", "b/c", "b/c/deep.txt"}},
+		{formatManifest, traversalDFS, []string{"a/x.txt", "b/a.txt", "b/c/deep.txt", "z.txt"}},
+		{formatManifest, traversalBFS, []string{"z.txt", "a/x.txt", "b/a.txt", "b/c/deep.txt"}},
+	}
+	for _, test := range tests {
+		t.Run(test.format+" "+test.traversal, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, traversalTree), true, func() {
+				setOption(t, &outputFormat, test.format)
+				setOption(t, &traversal, test.traversal)
+			})
+			var paths []string
+			if test.format == formatManifest {
+				var doc manifestDoc
+				if err := json.Unmarshal([]byte(got), &doc); err != nil {
+					t.Fatal(err)
+				}
+				for _, file := range doc.Files {
+					paths = append(paths, file.Path)
+				}
+			} else if test.format == formatPaths {
+				for _, line := range strings.Fields(got) {
+					paths = append(paths, strings.TrimSuffix(line, "/"))
+				}
+			} else {
+				for _, line := range strings.Split(strings.TrimSpace(got), "\n")[1:] {
+					paths = append(paths, strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == '\t' })[0])
+				}
+			}
+			if !slices.Equal(paths, test.want) {
+				t.Errorf("order %q, want %q", paths, test.want)
+			}
+		})
+	}
+}
+
+// The nested formats refuse bfs
+func TestTraversalRefused(t *testing.T) {
+	dir := testtree.Dir(t, "a/b.txt\n")
+	for _, format := range []string{formatMarkdown, formatJSON, formatText} {
+		_, stderr, code := runFTG(t, dir, "-d", dir, "-o", "-", "-f", format, "--traversal", "bfs")
+		if code != exitUsage || !strings.Contains(stderr, "--traversal bfs is only available with -f csv, tsv, paths and manifest") {
+			t.Errorf("-f %s: exit code %d, %s", format, code, stderr)
+		}
+	}
+	if _, stderr, code := runFTG(t, dir, "-d", dir, "-o", "-", "-f", "csv", "--traversal", "up"); code != exitUsage || !strings.Contains(stderr, `unknown --traversal value "up"`) {
+		t.Errorf("exit code %d, %s", code, stderr)
+	}
+}
+
+// A comma or quote in a name is quoted by encoding/csv and reads back unchanged
+func TestCSVQuotesCommasAndQuotes(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, `"a,b \"c\".txt" size=3`), true, func() {
+		setOption(t, &outputFormat, formatCSV)
+	})
+	if want := "\"a,b \"\"c\"\".txt\",F,1,3,2024-01-02T03:04:05Z\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("got\n%s\nwant the row %q", got, want)
+	}
+	rows, err := csv.NewReader(strings.NewReader(got)).ReadAll()
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if len(rows) != 2 || rows[1][0] != `a,b "c".txt` {
+		t.Errorf("rows = %q", rows)
+	}
+}
+
+// -f tsv escapes control characters and invalid UTF-8 as the trees do, so every
+// entry stays on one row; -f csv writes the names as they are, quoted where needed,
+// and reads back unchanged
+func TestCSVHostileNames(t *testing.T) {
+	var spec strings.Builder
+	for _, name := range testtree.HostileNames {
+		spec.WriteString(strconv.Quote("dir/"+name) + "\n")
+	}
+	t.Run(formatTSV, func(t *testing.T) {
+		got := renderFixture(t, testtree.MapFS(t, spec.String()), true, func() {
+			setOption(t, &outputFormat, formatTSV)
+		})
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		if want := len(testtree.HostileNames) + 2; len(lines) != want {
+			t.Fatalf("%d lines, want a header, dir/ and one per name (%d):\n%s", len(lines), want, got)
+		}
+		for _, want := range []string{`dir/new\nline.txt`, `dir/tab\there.txt`, `dir/carriage\rreturn.txt`, `dir/ansi \x1b[31mred\x1b[0m.txt`, `dir/bad\xffutf8.txt`, "dir/ünïcödé.txt"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("no %s in\n%s", want, got)
+			}
+		}
+		if strings.ContainsAny(got, "\r\x1b") {
+			t.Errorf("raw control characters in\n%q", got)
+		}
+	})
+	t.Run(formatCSV, func(t *testing.T) {
+		got := renderFixture(t, testtree.MapFS(t, spec.String()), true, func() {
+			setOption(t, &outputFormat, formatCSV)
+		})
+		rows, err := csv.NewReader(strings.NewReader(got)).ReadAll()
+		if err != nil {
+			t.Fatalf("read back: %v", err)
+		}
+		paths := map[string]bool{}
+		for _, row := range rows[1:] {
+			paths[row[0]] = true
+		}
+		for _, name := range testtree.HostileNames {
+			if !paths["dir/"+name] {
+				t.Errorf("no row for %q in %q", "dir/"+name, rows)
+			}
+		}
+	})
+}
+
+// --verify-renderers reads -f csv and -f tsv back as they are written, so a name with
+// a line break passes in both
+func TestVerifyRenderersLineBreakName(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows file names cannot hold a line break")
+	}
+	dir := testtree.Dir(t, "tree/plain.txt\n")
+	if err := os.WriteFile(filepath.Join(dir, "tree", "new\nline"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{formatCSV, formatTSV} {
+		stdout, stderr, code := runFTG(t, dir, "-d", "tree", "-f", format, "-o", "-", "--verify-renderers")
+		if code != exitOK || !strings.Contains(stderr, "Renderer check passed") {
+			t.Errorf("-f %s: exit %d, stderr %q", format, code, stderr)
+		}
+		if want := map[string]string{formatCSV: "\"new\nline\",F,1,0,", formatTSV: "new\\nline\tF\t1\t0\t"}[format]; !strings.Contains(stdout, want) {
+			t.Errorf("-f %s: no row %q in\n%s", format, want, stdout)
+		}
+	}
+}
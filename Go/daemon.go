@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// "ftg daemon" keeps a snapshot of the tree's listings and metadata in memory and
+// renders it on request over a unix socket, so repeated renders of a large tree do
+// not walk the disk again. The walk and the renderers work on package state, so
+// renders are serialized by walkMu; a refresh builds the next snapshot off to the
+// side, taking walkMu only to filter each directory, and swaps it in when done,
+// so renders keep using the previous snapshot while a refresh is in progress.
+var (
+	daemonSocket  = defaultDaemonSocket() // --socket: unix socket of ftg daemon and ftg daemon-client
+	daemonRefresh time.Duration           // --refresh: rebuild the daemon's snapshot this often, 0 for only on request
+	walkMu        sync.Mutex              // Guards the package state used by walks and renderers in the daemon
+)
+
+// defaultDaemonSocket is ftg.sock in $XDG_RUNTIME_DIR, which only its user can enter,
+// or else in daemonTempDir
+func defaultDaemonSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "ftg.sock")
+	}
+	return filepath.Join(daemonTempDir(), "ftg.sock")
+}
+
+// daemonTempDir is the user's own directory for the socket below the shared
+// temporary directory
+func daemonTempDir() string {
+	name := "ftg"
+	if uid := os.Getuid(); uid >= 0 {
+		name = fmt.Sprintf("ftg-%d", uid)
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+// checkSocketDir makes sure a socket in daemonTempDir cannot be replaced or listened
+// on by anyone else: with create the directory is made for the user alone, and one
+// that someone else made, or that others can enter, is refused. Sockets elsewhere are
+// left to the directory their user named.
+func checkSocketDir(socket string, create bool) error {
+	dir := filepath.Dir(socket)
+	if dir != daemonTempDir() {
+		return nil
+	}
+	if create {
+		if err := os.Mkdir(dir, 0o700); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || !privateDir(info) {
+		return fmt.Errorf("%s is not a directory of your own that only you can enter; remove it or pass --socket", dir)
+	}
+	return nil
+}
+
+// errSnapshotMissing is returned for paths the snapshot did not list
+var errSnapshotMissing = errors.New("not in the snapshot")
+
+// snapshotFS serves listings and lstat results captured by one walk of the input
+// directory. File contents (grep, link checks, ignore files) are read from disk.
+type snapshotFS struct {
+	live    fs.FS                    // The input directory, for contents and link targets
+	dirs    map[string][]fs.DirEntry // Listings by slash-separated path, "." for the root
+	errs    map[string]error         // Directories that could not be listed, with the error
+	infos   map[string]fs.FileInfo   // Lstat results by path
+	built   time.Time                // When the walk finished
+	took    time.Duration            // How long the walk took
+	entries int                      // Entries listed
+}
+
+// Open reads from disk; only listings and metadata are kept in the snapshot
+func (s *snapshotFS) Open(name string) (fs.File, error) {
+	return s.live.Open(name)
+}
+
+// ReadDir returns a copy of a captured listing, since the walk filters listings in place
+func (s *snapshotFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err, ok := s.errs[name]; ok {
+		return nil, err
+	}
+	entries, ok := s.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errSnapshotMissing}
+	}
+	return append([]fs.DirEntry(nil), entries...), nil
+}
+
+// Lstat returns the captured metadata of a path
+func (s *snapshotFS) Lstat(name string) (fs.FileInfo, error) {
+	if info, ok := s.infos[name]; ok {
+		return info, nil
+	}
+	return nil, &fs.PathError{Op: "lstat", Path: name, Err: errSnapshotMissing}
+}
+
+// Stat follows links on disk and returns the captured metadata of everything else
+func (s *snapshotFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := s.Lstat(name)
+	if err != nil || info.Mode()&fs.ModeSymlink != 0 {
+		return fs.Stat(s.live, name)
+	}
+	return info, nil
+}
+
+// buildSnapshot walks root and captures every listing and the metadata of every
+// entry. Excluded entries are kept in their parent's listing, so the renders count
+// them, but excluded directories and links are not descended into.
+func buildSnapshot(root string) (*snapshotFS, error) {
+	started := time.Now()
+	s := &snapshotFS{
+		live:  os.DirFS(root),
+		dirs:  map[string][]fs.DirEntry{},
+		errs:  map[string]error{},
+		infos: map[string]fs.FileInfo{},
+	}
+	info, err := fs.Stat(s.live, ".")
+	if err != nil {
+		return nil, err
+	}
+	s.infos["."] = info
+	var walk func(name string)
+	walk = func(name string) {
+		listing, err := fs.ReadDir(s.live, name)
+		if err != nil {
+			s.errs[name] = err
+			return
+		}
+		entries := make([]fs.DirEntry, 0, len(listing))
+		for _, entry := range listing {
+			info, err := entry.Info()
+			if err != nil {
+				// Gone since the listing; the next refresh will not list it either
+				continue
+			}
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+			s.infos[path.Join(name, entry.Name())] = info
+		}
+		s.dirs[name] = entries
+		s.entries += len(entries)
+
+		dir := filepath.Join(root, filepath.FromSlash(name))
+		var subdirs []string
+		walkMu.Lock()
+		for _, entry := range entries {
+			if entry.IsDir() && !shouldExclude(dir, entry) {
+				subdirs = append(subdirs, path.Join(name, entry.Name()))
+			}
+		}
+		walkMu.Unlock()
+		for _, sub := range subdirs {
+			walk(sub)
+		}
+	}
+	walk(".")
+	s.built = time.Now()
+	s.took = s.built.Sub(started)
+	return s, nil
+}
+
+// daemonRequest is one line of the daemon protocol
+type daemonRequest struct {
+	Command string `json:"command"`          // render, refresh, stats or shutdown
+	Format  string `json:"format,omitempty"` // md (default), text, html or json
+	Depth   int    `json:"depth,omitempty"`  // Deepest level shown, 0 for no limit
+	Only    string `json:"only,omitempty"`   // Comma-separated --only patterns
+}
+
+// daemonResponse answers a request on one line
+type daemonResponse struct {
+	OK     bool         `json:"ok"`
+	Error  string       `json:"error,omitempty"`
+	Output string       `json:"output,omitempty"`
+	Stats  *daemonStats `json:"stats,omitempty"`
+}
+
+// daemonStats describes the snapshot being served
+type daemonStats struct {
+	Root        string    `json:"root"`
+	Directories int       `json:"directories"`
+	Entries     int       `json:"entries"`
+	BuiltAt     time.Time `json:"builtAt"`
+	BuildTime   string    `json:"buildTime"`
+	Renders     int       `json:"renders"`
+	Refreshes   int       `json:"refreshes"`
+}
+
+// treeDaemon serves one input directory
+type treeDaemon struct {
+	root       string
+	connectors ftree.Style // Connectors chosen on the command line, for every format but text
+	textStyle  ftree.Style // Connectors for -f text
+
+	mu        sync.RWMutex // Guards snapshot and the counts
+	snapshot  *snapshotFS
+	renders   int
+	refreshes int
+	refreshMu sync.Mutex // Held while a refresh builds the next snapshot
+
+	listener net.Listener
+	conns    sync.WaitGroup // Connections being served
+	stop     sync.Once
+	done     chan struct{}
+}
+
+// current returns the snapshot renders should use
+func (d *treeDaemon) current() *snapshotFS {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.snapshot
+}
+
+// refresh builds a new snapshot and replaces the current one; concurrent refreshes
+// wait for the one in progress and then build again, so they see later changes
+func (d *treeDaemon) refresh() error {
+	d.refreshMu.Lock()
+	defer d.refreshMu.Unlock()
+	next, err := buildSnapshot(d.root)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.snapshot = next
+	d.refreshes++
+	d.mu.Unlock()
+	return nil
+}
+
+// stats reports the current snapshot
+func (d *treeDaemon) stats() *daemonStats {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return &daemonStats{
+		Root:        d.root,
+		Directories: len(d.snapshot.dirs),
+		Entries:     d.snapshot.entries,
+		BuiltAt:     d.snapshot.built,
+		BuildTime:   d.snapshot.took.Round(time.Microsecond).String(),
+		Renders:     d.renders,
+		Refreshes:   d.refreshes,
+	}
+}
+
+// render renders the current snapshot with the options of a request, starting from
+// fresh walk state each time
+func (d *treeDaemon) render(req daemonRequest) ([]byte, error) {
+	switch req.Format {
+	case "":
+		req.Format = formatMarkdown
+	case formatMarkdown, formatText, formatHTML, formatJSON:
+	default:
+		return nil, fmt.Errorf("unsupported format %q (md, text, html or json)", req.Format)
+	}
+	if req.Depth < 0 {
+		return nil, errors.New("depth must not be negative")
+	}
+	snapshot := d.current()
+
+	walkMu.Lock()
+	defer walkMu.Unlock()
+	resetWalkState()
+	treeFS = snapshot
+	scan.MaxDepth = req.Depth
+	outputFormat = req.Format
+	onlyPatterns = nil
+	if req.Only != "" {
+		onlyPatterns = strings.Split(filepath.ToSlash(req.Only), ",")
+	}
+	connectors = d.connectors
+	if req.Format == formatText {
+		connectors = d.textStyle
+	}
+	d.mu.Lock()
+	d.renders++
+	d.mu.Unlock()
+
+	// Renders read the snapshot from memory; shutdown is the daemon's own signal handling
+	ctx := context.Background()
+	entries, err := getEntries(ctx, d.root)
+	if err != nil {
+		return nil, errors.New("cannot read the input directory")
+	}
+	var output bytes.Buffer
+	switch req.Format {
+	case formatJSON:
+		return renderJSON(ctx, d.root, entries)
+	case formatHTML:
+		return renderHTML(ctx, d.root, entries)
+	case formatText:
+		return renderText(ctx, d.root, entries)
+	}
+	fmt.Fprintf(&output, "%s\n\n%s\n", headerTitle(d.root), msg("header.star", repository))
+	fmt.Fprintln(&output, "```sh")
+	generateTree(ctx, &output, d.root, entries)
+	fmt.Fprintln(&output, "```")
+	writeCompleteness(&output)
+	return widenFences(output.Bytes()), nil
+}
+
+// handle answers the requests of one connection, one JSON object per line each way
+func (d *treeDaemon) handle(conn net.Conn) {
+	defer d.conns.Done()
+	defer conn.Close()
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	encoder := json.NewEncoder(conn)
+	for {
+		var req daemonRequest
+		if err := decoder.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				_ = encoder.Encode(daemonResponse{Error: "malformed request: " + err.Error()})
+			}
+			return
+		}
+		var resp daemonResponse
+		switch req.Command {
+		case "render":
+			output, err := d.render(req)
+			if err != nil {
+				resp.Error = err.Error()
+			} else {
+				resp.OK, resp.Output = true, string(output)
+			}
+		case "refresh":
+			if err := d.refresh(); err != nil {
+				resp.Error = "refresh failed, still serving the previous snapshot: " + err.Error()
+			} else {
+				resp.OK, resp.Stats = true, d.stats()
+			}
+		case "stats":
+			resp.OK, resp.Stats = true, d.stats()
+		case "shutdown":
+			resp.OK = true
+			_ = encoder.Encode(resp)
+			d.shutdown()
+			return
+		default:
+			resp.Error = fmt.Sprintf("unknown command %q (render, refresh, stats or shutdown)", req.Command)
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// shutdown stops accepting connections; serve returns once the open ones are answered
+func (d *treeDaemon) shutdown() {
+	d.stop.Do(func() {
+		close(d.done)
+		d.listener.Close()
+	})
+}
+
+// listenSocket listens on a unix socket only its owner can connect to, from the moment
+// it is created, replacing a stale socket file left by a daemon that did not shut down
+func listenSocket(socket string) (net.Listener, error) {
+	if err := checkSocketDir(socket, true); err != nil {
+		return nil, err
+	}
+	if _, err := os.Lstat(socket); err == nil {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := listenPrivate(socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// runDaemon implements "ftg daemon [options]": it builds the first snapshot, then
+// serves requests until a shutdown request, SIGINT or SIGTERM, answering the
+// requests already received before it removes the socket and exits
+func runDaemon(root string) {
+	d := &treeDaemon{root: root, connectors: connectors, textStyle: connectors, done: make(chan struct{})}
+	if !flagSet("connectors") && !flagSet("style") {
+		d.textStyle = ftree.Styles["tree"]
+	}
+	if err := d.refresh(); err != nil {
+		errorExit(fmt.Sprintf("Cannot read the input directory: %v", err))
+	}
+	d.refreshes = 0
+	listener, err := listenSocket(daemonSocket)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot listen on %s: %v", daemonSocket, err))
+	}
+	d.listener = listener
+	stats := d.stats()
+	log.Printf("Serving %s on %s (%s, snapshot built in %s)", root, daemonSocket, groupThousands(stats.Entries)+" entries", stats.BuildTime)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			d.shutdown()
+		case <-d.done:
+		}
+	}()
+	if daemonRefresh > 0 {
+		go func() {
+			ticker := time.NewTicker(daemonRefresh)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := d.refresh(); err != nil {
+						warnf("Refresh failed, still serving the previous snapshot: %v", err)
+					}
+				case <-d.done:
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-d.done:
+			default:
+				warnf("Cannot accept connections: %v", err)
+				d.shutdown()
+			}
+			break
+		}
+		d.conns.Add(1)
+		go d.handle(conn)
+	}
+	d.conns.Wait()
+	_ = os.Remove(daemonSocket)
+	log.Printf("Stopped serving %s", root)
+}
+
+// daemonClient implements "ftg daemon-client command [options]": it sends one request
+// and prints the rendered tree, or the stats as JSON
+func daemonClient(args []string) {
+	const usage = "usage: ftg daemon-client render|refresh|stats|shutdown [--socket path] [--format md|text|html|json] [--depth N] [--only patterns]"
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		usageExit(usage)
+	}
+	req := daemonRequest{Command: args[0]}
+	fset := flag.NewFlagSet("daemon-client", flag.ExitOnError)
+	fset.StringVar(&daemonSocket, "socket", daemonSocket, "Unix socket of the daemon")
+	fset.StringVar(&req.Format, "format", "", "Output format of render: md (default), text, html or json")
+	fset.IntVar(&req.Depth, "depth", 0, "Deepest level render shows, 0 for no limit")
+	fset.StringVar(&req.Only, "only", "", "Patterns render shows, with their ancestors")
+	_ = fset.Parse(args[1:])
+	if fset.NArg() > 0 {
+		usageExit(usage)
+	}
+
+	if err := checkSocketDir(daemonSocket, false); err != nil && !os.IsNotExist(err) {
+		errorExit(fmt.Sprintf("Cannot trust the socket %s: %v", daemonSocket, err))
+	}
+	conn, err := net.Dial("unix", daemonSocket)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot reach the daemon on %s: %v", daemonSocket, err))
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		errorExit(fmt.Sprintf("Cannot send the request: %v", err))
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		errorExit(fmt.Sprintf("Cannot read the daemon's answer: %v", err))
+	}
+	if !resp.OK {
+		errorExit(resp.Error)
+	}
+	switch {
+	case resp.Output != "":
+		fmt.Print(resp.Output)
+	case resp.Stats != nil:
+		out, _ := json.MarshalIndent(resp.Stats, "", "  ")
+		fmt.Println(string(out))
+	}
+}
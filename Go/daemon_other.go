@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+	"net"
+)
+
+// listenPrivate listens on a unix socket; there is no umask to set here
+func listenPrivate(socket string) (net.Listener, error) {
+	return net.Listen("unix", socket)
+}
+
+// privateDir reports that a directory is private; its ACL is not checked here
+func privateDir(fs.FileInfo) bool {
+	return true
+}
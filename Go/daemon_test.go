@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemonStatsFieldNames(t *testing.T) {
+	data, err := json.Marshal(daemonStats{Root: "/src", BuiltAt: time.Unix(0, 0).UTC(), BuildTime: "1ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for name := range fields {
+		if strings.Contains(name, "_") {
+			t.Errorf("stats field %q is not camelCase like the rest of the JSON output", name)
+		}
+	}
+	for _, name := range []string{"builtAt", "buildTime"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("stats has no %q field: %s", name, data)
+		}
+	}
+}
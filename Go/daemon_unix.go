@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenPrivate listens on a unix socket created with the mode 0600, so there is no
+// moment in which others can connect before listenSocket's chmod
+func listenPrivate(socket string) (net.Listener, error) {
+	old := syscall.Umask(0o177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", socket)
+}
+
+// privateDir reports whether a directory belongs to the user running ftg and no one
+// else can enter it
+func privateDir(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid() && info.Mode().Perm()&0o077 == 0
+}
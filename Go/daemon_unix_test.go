@@ -0,0 +1,59 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Without $XDG_RUNTIME_DIR the socket is made in a 0700 directory of the user's own
+// in the temporary directory, and both are only the user's from the start
+func TestDaemonSocketMode(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	socket := defaultDaemonSocket()
+	if filepath.Dir(socket) != daemonTempDir() {
+		t.Fatalf("default socket %s is not in %s", socket, daemonTempDir())
+	}
+	listener, err := listenSocket(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	for file, want := range map[string]os.FileMode{filepath.Dir(socket): 0o700, socket: 0o600} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != want {
+			t.Errorf("%s has the mode %v, want %v", file, info.Mode().Perm(), want)
+		}
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got := defaultDaemonSocket(); got != "/run/user/1000/ftg.sock" {
+		t.Errorf("with $XDG_RUNTIME_DIR the socket is %s", got)
+	}
+}
+
+// A socket directory in the temporary directory that others can enter is not used
+func TestDaemonSocketDirRefused(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	if err := os.Mkdir(daemonTempDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(daemonTempDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenSocket(filepath.Join(daemonTempDir(), "ftg.sock")); err == nil || !strings.Contains(err.Error(), "only you can enter") {
+		t.Errorf("listenSocket = %v, want the directory refused", err)
+	}
+	// A socket the user named elsewhere is left to that directory
+	listener, err := listenSocket(filepath.Join(t.TempDir(), "ftg.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+)
+
+// subtreeSum is the structural fingerprint of a directory
+type subtreeSum struct {
+	hash  string
+	files int
+}
+
+var (
+	dedupeSubtrees bool                      // Collapse directories identical to one already rendered
+	subtreeSums    = map[string]subtreeSum{} // Fingerprints by directory path
+	firstSubtree   = map[string]string{}     // Relative path of the first rendered directory per fingerprint
+)
+
+// subtreeFingerprint hashes the names, types and sizes below dir, honoring the same
+// filters as the walk. Each directory is read and hashed at most once.
+func subtreeFingerprint(dir string) subtreeSum {
+	if sum, ok := subtreeSums[dir]; ok {
+		return sum
+	}
+	h := sha256.New()
+	files := 0
+	// Read directly: look-ahead listings must not count as walk progress
+	entries, err := readDir(dir)
+	if err != nil {
+		fmt.Fprintf(h, "unreadable\x00")
+	}
+	for _, entry := range visibleEntries(dir, entries) {
+		name := entry.Name()
+		if shouldExclude(dir, entry) {
+			continue
+		}
+		fullPath := filepath.Join(dir, name)
+		if shouldDescend(fullPath, entry) {
+			child := subtreeFingerprint(fullPath)
+			files += child.files
+			fmt.Fprintf(h, "D\x00%s\x00%s\x00", name, child.hash)
+			continue
+		}
+		if isInFlux(fullPath, entry) {
+			// A file still being written never makes its directory a repeat
+			fmt.Fprintf(h, "in flux\x00%s\x00", fullPath)
+			continue
+		}
+		var size int64
+		if info, err := entryInfo(entry); err == nil {
+			size = info.Size()
+		}
+		files++
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00", getEntryType(entry), name, size)
+	}
+	sum := subtreeSum{hash: hex.EncodeToString(h.Sum(nil)), files: files}
+	subtreeSums[dir] = sum
+	return sum
+}
+
+// identicalSubtree returns the relative path of the directory rendered earlier that a
+// directory repeats, and how many files they hold. The first directory in walk order
+// is rendered in full, so the choice is deterministic; subtrees without files are
+// never collapsed.
+func identicalSubtree(fullPath, rel string) (string, int, bool) {
+	if !dedupeSubtrees {
+		return "", 0, false
+	}
+	sum := subtreeFingerprint(fullPath)
+	if sum.files == 0 {
+		return "", 0, false
+	}
+	first, seen := firstSubtree[sum.hash]
+	if !seen {
+		firstSubtree[sum.hash] = rel
+		return "", 0, false
+	}
+	return first, sum.files, true
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// dedupeFixture repeats lodash in three packages, once with a file of another size,
+// and has a pair of empty directories
+const dedupeFixture = `
+pkgs/a/lodash/index.js size=12
+pkgs/a/lodash/lib/x.js size=3
+pkgs/b/lodash/index.js size=12
+pkgs/b/lodash/lib/x.js size=3
+pkgs/c/lodash/index.js size=12
+pkgs/c/lodash/lib/x.js size=4
+pkgs/d/extra.txt
+pkgs/d/lodash/index.js size=12
+pkgs/d/lodash/lib/x.js size=3
+empty1/
+empty2/
+`
+
+// Each later copy of a subtree collapses to a reference to the first in walk order,
+// the outermost repeat only; a copy that differs in a size is shown, and so are
+// empty directories
+func TestDedupeSubtrees(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, dedupeFixture), true, func() {
+		setOption(t, &dedupeSubtrees, true)
+		setOption(t, &noSummary, true)
+	})
+	want := "├── [D] empty1\n" +
+		"├── [D] empty2\n" +
+		"└── [D] pkgs\n" +
+		"    ├── [D] a\n" +
+		"    │   └── [D] lodash\n" +
+		"    │       ├── [F] index.js\n" +
+		"    │       └── [D] lib\n" +
+		"    │           └── [F] x.js\n" +
+		"    ├── [D] b (identical to pkgs/a, 2 files)\n" +
+		"    ├── [D] c\n" +
+		"    │   └── [D] lodash\n" +
+		"    │       ├── [F] index.js\n" +
+		"    │       └── [D] lib\n" +
+		"    │           └── [F] x.js\n" +
+		"    └── [D] d\n" +
+		"        ├── [F] extra.txt\n" +
+		"        └── [D] lodash (identical to pkgs/a/lodash, 2 files)\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	_, root := renderJSONFixture(t, func() {
+		setOption(t, &dedupeSubtrees, true)
+		setOption(t, &fixtureFS, fs.FS(testtree.MapFS(t, dedupeFixture)))
+	})
+	pkgs := child(t, root.Children, "pkgs")
+	if b := child(t, pkgs.Children, "b"); b.IdenticalTo != "pkgs/a" || len(b.Children) != 0 {
+		t.Errorf("b = %+v, want it to refer to pkgs/a", b)
+	}
+	if lodash := child(t, child(t, pkgs.Children, "d").Children, "lodash"); lodash.IdenticalTo != "pkgs/a/lodash" {
+		t.Errorf("d/lodash = %+v, want it to refer to pkgs/a/lodash", lodash)
+	}
+	if c := child(t, pkgs.Children, "c"); c.IdenticalTo != "" {
+		t.Errorf("c = %+v, want it shown in full", c)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	autoDepth      bool                         // Choose maxDepth from a sampling pass
+	autoDepthLines = 400                        // Line budget for --auto-depth
+	autoDepthNote  string                       // Explanation appended when --auto-depth limited the tree
+	listingCache   = map[string][]fs.DirEntry{} // Listings read by the sampling pass, handed to the walk
+	// --count-hidden-limit: entries below a directory --max-depth cuts off that are
+	// counted before its line says "N+"; 0 counts them all
+	countHiddenLimit = 10000
+)
+
+// entryDepth returns how deep a path sits below the input directory; top-level entries are 1
+func entryDepth(fullPath string) int {
+	rel := relativePath(filepath.Dir(fullPath), filepath.Base(fullPath))
+	return strings.Count(rel, "/") + 1
+}
+
+// withinDepth reports whether the contents of a directory are inside --max-depth
+func withinDepth(fullPath string) bool {
+	return scan.MaxDepth <= 0 || entryDepth(fullPath) < scan.MaxDepth
+}
+
+// depthLimited reports whether -L/--max-depth was given
+func depthLimited() bool {
+	return flagSet("max-depth")
+}
+
+// depthCutoff reports whether a directory is left closed only because of --max-depth
+func depthCutoff(fullPath string, entry fs.DirEntry) bool {
+	return entry.IsDir() && !withinDepth(fullPath) && reparseKindOf(fullPath, entry) == reparseNone
+}
+
+// omittedEntries counts every entry --max-depth hides below a directory, at any depth,
+// after the same filters and exclusions the walk would apply. Only listings are read,
+// through readDir like the walk's unless the sampling pass of --auto-depth already
+// holds them, and the count stops once ctx is done or it passes --count-hidden-limit,
+// which more reports.
+func omittedEntries(ctx context.Context, dir string) (count int, more bool) {
+	pending := []string{dir}
+	for len(pending) > 0 && ctx.Err() == nil {
+		dir, pending = pending[len(pending)-1], pending[:len(pending)-1]
+		entries, ok := cachedEntries(dir)
+		if !ok {
+			var err error
+			if entries, err = readDir(dir); err != nil {
+				continue
+			}
+		}
+		for _, entry := range visibleEntries(dir, entries) {
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			if count++; countHiddenLimit > 0 && count > countHiddenLimit {
+				return countHiddenLimit, true
+			}
+			// Links are not followed, so only listings are read
+			if fullPath := filepath.Join(dir, entry.Name()); depthCutoff(fullPath, entry) {
+				pending = append(pending, fullPath)
+			}
+		}
+	}
+	return count, false
+}
+
+// printOmitted writes the line telling readers a directory's listing was cut off by
+// --max-depth, with a count more marks as a lower bound
+func printOmitted(writer io.Writer, prefix string, count int, more bool) {
+	if count == 0 {
+		return
+	}
+	note := msgCount("tree.omitted", count)
+	if more {
+		note = msg("tree.omittedMore", groupThousands(count))
+	}
+	if _, err := fmt.Fprintf(writer, "%s %s\n", painter.connector(prefix+connectors.LastBranch), note); err != nil {
+		warnf("Error writing entry: %v", err)
+	}
+}
+
+// chooseAutoDepth counts the lines each level of the tree would add, breadth first,
+// and returns the deepest level whose running total stays within the line budget.
+// It stops reading as soon as the budget is exceeded and caches every listing so
+// the walk does not read those directories again. A tree that fits entirely gets 0.
+func chooseAutoDepth(root string, rootEntries []fs.DirEntry) int {
+	lines := 0
+	level := []string{root}
+	listingCache[root] = rootEntries
+	defer delete(listingCache, root) // The walk already holds the root listing
+	for depth := 1; len(level) > 0; depth++ {
+		var next []string
+		for _, dir := range level {
+			entries, ok := listingCache[dir]
+			if !ok {
+				var err error
+				if entries, err = readDir(dir); err != nil {
+					continue
+				}
+				listingCache[dir] = entries
+			}
+			for _, entry := range visibleEntries(dir, entries) {
+				name := entry.Name()
+				if shouldExclude(dir, entry) {
+					continue
+				}
+				lines++
+				if fullPath := filepath.Join(dir, name); shouldDescend(fullPath, entry) {
+					next = append(next, fullPath)
+				}
+			}
+		}
+		if lines > autoDepthLines {
+			return max(depth-1, 1)
+		}
+		level = next
+	}
+	return 0
+}
+
+// cachedEntries returns and forgets a listing read by the sampling pass
+func cachedEntries(dir string) ([]fs.DirEntry, bool) {
+	entries, ok := listingCache[dir]
+	if ok {
+		delete(listingCache, dir)
+	}
+	return entries, ok
+}
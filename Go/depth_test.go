@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		want  string
+	}{
+		{"depth 1", 1, `.
+├── .env
+├── Makefile
+├── README.md
+├── docs
+│   └── … (1 entry omitted)
+├── empty
+└── src
+    └── … (4 entries omitted)
+
+3 directories, 3 files
+`},
+		{"depth 2", 2, `.
+├── .env
+├── Makefile
+├── README.md
+├── docs
+│   └── guide.md
+├── empty
+└── src
+    ├── main.go
+    └── util
+        └── … (2 entries omitted)
+
+4 directories, 5 files
+`},
+		{"unlimited", 0, `.
+├── .env
+├── Makefile
+├── README.md
+├── docs
+│   └── guide.md
+├── empty
+└── src
+    ├── main.go
+    └── util
+        ├── strings.go
+        └── strings_test.go
+
+4 directories, 7 files
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, fixture), true, func() {
+				setOption(t, &outputFormat, formatText)
+				setOption(t, &scan.MaxDepth, test.depth)
+			})
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// The omitted count follows the exclusions: node_modules stays hidden below the cutoff too
+func TestMaxDepthCountsAfterExclusions(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, "lib/\nlib/a.js\nlib/node_modules/x.js\nlib/.cache/\n"), true, func() {
+		setOption(t, &outputFormat, formatText)
+		setOption(t, &scan.MaxDepth, 1)
+	})
+	if want := ".\n└── lib\n    └── … (2 entries omitted)\n\n1 directory, 0 files\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// A directory --max-depth cuts off counts every entry below it, up to
+// --count-hidden-limit, and nothing once the run is cancelled
+func TestMaxDepthCountsRecursively(t *testing.T) {
+	spec := "a/b/c/d.txt\na/b/e.txt\na/node_modules/x/y.js\na/f.txt\n"
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{"every level", 10000, "└── … (5 entries omitted)"},
+		{"no cap", 0, "└── … (5 entries omitted)"},
+		{"capped", 3, "└── … (3+ entries omitted)"},
+		{"at the cap", 5, "└── … (5 entries omitted)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, spec), true, func() {
+				setOption(t, &outputFormat, formatText)
+				setOption(t, &scan.MaxDepth, 1)
+				setOption(t, &countHiddenLimit, test.limit)
+			})
+			if want := ".\n└── a\n    " + test.want + "\n\n1 directory, 0 files\n"; got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+	t.Run("cancelled", func(t *testing.T) {
+		useFixture(t, testtree.MapFS(t, spec), func() {
+			setOption(t, &scan.MaxDepth, 1)
+		})
+		dir := filepath.Join("fixture", "a")
+		if count, _ := omittedEntries(context.Background(), dir); count != 5 {
+			t.Fatalf("omittedEntries = %d, want 5", count)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if count, more := omittedEntries(ctx, dir); count != 0 || more {
+			t.Errorf("omittedEntries = %d, %v after cancelling; want nothing read", count, more)
+		}
+	})
+}
+
+// The walk after the sampling pass of --auto-depth lists no directory the pass read:
+// it reads what a run with the chosen --max-depth reads, counts of the entries cut
+// off included
+func TestAutoDepthReusesListings(t *testing.T) {
+	render := func(auto bool) (string, int32) {
+		var reads atomic.Int32
+		got := renderFixture(t, cancellingFS{syntheticTree(10, 5), 0, &reads, func() {}}, true, func() {
+			setOption(t, &autoDepth, auto)
+			setOption(t, &autoDepthLines, 50)
+			// --auto-depth sets scan.MaxDepth itself
+			setOption(t, &scan.MaxDepth, map[bool]int{true: 0, false: 2}[auto])
+		})
+		return got, reads.Load()
+	}
+	auto, autoReads := render(true)
+	fixed, fixedReads := render(false)
+	if autoReads != fixedReads {
+		t.Errorf("--auto-depth read %d directories, -L 2 %d", autoReads, fixedReads)
+	}
+	if want := "\n(depth limited to 2 automatically; run without --auto-depth for everything)\n"; !strings.HasSuffix(auto, want) ||
+		strings.TrimSuffix(auto, want) != fixed {
+		t.Errorf("--auto-depth:\n%s\n-L 2:\n%s", auto, fixed)
+	}
+}
+
+// --auto-depth picks the deepest level whose lines, counted level by level, fit the
+// budget, and never less than 1; a tree that fits is shown whole, and an explicit
+// --max-depth wins
+func TestAutoDepthHeuristic(t *testing.T) {
+	deep := testtree.MapFS(t, "a/1.txt\na/b/2.txt\na/b/c/3.txt\na/b/c/d/4.txt\na/b/c/d/e/5.txt\n")
+	for _, test := range []struct {
+		name  string
+		fsys  fs.FS
+		lines int
+		depth int // 0 when the whole tree fits
+	}{
+		{"wide", syntheticTree(30, 1), 50, 1}, // 30 lines, then 60
+		{"wider than the budget", syntheticTree(60, 1), 50, 1},
+		{"deep", deep, 6, 3}, // 1, 3, 5, then 7, 9 and 10
+		{"deep within the budget", deep, 9, 5},
+		{"fits", deep, 10, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := renderFixture(t, test.fsys, true, func() {
+				setOption(t, &autoDepth, true)
+				setOption(t, &autoDepthLines, test.lines)
+				setOption(t, &scan.MaxDepth, 0)
+			})
+			note := fmt.Sprintf("(depth limited to %d automatically;", test.depth)
+			if test.depth == 0 {
+				note = "automatically"
+			}
+			if strings.Contains(got, note) != (test.depth > 0) {
+				t.Errorf("want the note %q only for a limited depth:\n%s", note, got)
+			}
+		})
+	}
+
+	src := testtree.Dir(t, "a/b/c.txt\n")
+	stdout, stderr, code := runFTG(t, src, "-d", ".", "--auto-depth", "--auto-depth-lines", "1", "-L", "2", "-o", "-")
+	if code != exitOK || strings.Contains(stdout, "automatically") || !strings.Contains(stdout, "[D] b\n") {
+		t.Errorf("-L 2 with --auto-depth: exit code %d, got\n%s%s", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Changes reported by "ftg diff"
+const (
+	diffAdded       = "added"
+	diffRemoved     = "removed"
+	diffTypeChanged = "typeChanged"
+)
+
+// diffMarkers prefix the tree lines of "ftg diff"; unchanged ancestors get a space
+var diffMarkers = map[string]string{diffAdded: "+", diffRemoved: "-", diffTypeChanged: "~", "": " "}
+
+// diffNode is an entry of one side of "ftg diff", keyed by name below its parent
+type diffNode struct {
+	kind     string // Type letter, as the tree prints it
+	children map[string]*diffNode
+}
+
+// diffChange is one entry that differs between the trees, as reported in JSON
+type diffChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	Type   string `json:"type"`           // Type in the second tree, or in the first for removals
+	From   string `json:"from,omitempty"` // Previous type of a typeChanged entry
+}
+
+// diffReport is the JSON report of "ftg diff"
+type diffReport struct {
+	Old       string       `json:"old"`
+	New       string       `json:"new"`
+	Identical bool         `json:"identical"`
+	Changes   []diffChange `json:"changes"`
+	Summary   struct {
+		Added       int `json:"added"`
+		Removed     int `json:"removed"`
+		TypeChanged int `json:"typeChanged"`
+	} `json:"summary"`
+}
+
+// runDiff compares the trees below two directories by presence and type and prints
+// the differences as an annotated tree, or with -f json as a flat list. Contents
+// are not compared; a renamed entry is a removal and an addition. Differences exit
+// with the code for checks that found differences, except with --emit-sync-script,
+// where the script is the result.
+func runDiff(oldDir, newDir string) {
+	oldTree := buildDiffTree(oldDir)
+	newTree := buildDiffTree(newDir)
+	report := diffReport{Old: redactPath(virtualPath(oldDir)), New: redactPath(virtualPath(newDir)), Changes: []diffChange{}}
+	collectDiff(&report, oldTree, newTree, "")
+	report.Identical = len(report.Changes) == 0
+
+	if syncScript != "" {
+		writeSyncScript(os.Stdout, report, newDir, oldTree, newTree)
+		exitProcess(runExitCode(true))
+	}
+	switch outputFormat {
+	case formatJSON:
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	case formatText:
+		writeDiffTree(os.Stdout, oldTree, newTree, "")
+		writeDiffSummary(os.Stdout, report)
+	default:
+		fmt.Printf("# Differences between %s and %s\n\n", codeSpan(report.Old), codeSpan(report.New))
+		if !report.Identical {
+			// A diff fence colors the + and - lines where markdown is rendered
+			fmt.Println("```diff")
+			writeDiffTree(os.Stdout, oldTree, newTree, "")
+			fmt.Println("```")
+			fmt.Println()
+		}
+		writeDiffSummary(os.Stdout, report)
+	}
+	if !report.Identical {
+		exitProcess(exitDifferences)
+	}
+	exitProcess(runExitCode(true))
+}
+
+// buildDiffTree walks a directory with the usual filters into memory. The input
+// directory is switched to it for the walk, so patterns with a / and --max-depth
+// apply relative to each side the same way.
+func buildDiffTree(dir string) *diffNode {
+	scan.Root = normalizeInput(dir)
+	treeFS = os.DirFS(longPath(scan.Root))
+	ctx := context.Background()
+	entries, err := getEntries(ctx, scan.Root)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read the directory %s", dir))
+	}
+	root := &diffNode{kind: "D"}
+	addDiffChildren(ctx, root, scan.Root, entries)
+	return root
+}
+
+// addDiffChildren adds the entries of a listing, and those below them, to node
+func addDiffChildren(ctx context.Context, node *diffNode, dir string, entries []fs.DirEntry) {
+	node.children = map[string]*diffNode{}
+	for _, entry := range filterExcluded(dir, visibleEntries(dir, entries)) {
+		fullPath := filepath.Join(dir, entry.Name())
+		name, _ := redactName(entry.Name())
+		child := &diffNode{kind: reparseType(fullPath, entry)}
+		node.children[name] = child
+		if !shouldDescend(fullPath, entry) {
+			continue
+		}
+		subEntries, err := getEntries(ctx, fullPath)
+		if err != nil {
+			warnf("%s", readDirError(fullPath, err))
+			countReadError(err)
+			continue
+		}
+		addDiffChildren(ctx, child, fullPath, subEntries)
+	}
+}
+
+// diffNames returns the names below either node in the tree's order
+func diffNames(a, b *diffNode) []string {
+	isDir := map[string]bool{}
+	for _, node := range []*diffNode{a, b} {
+		if node == nil {
+			continue
+		}
+		for name, child := range node.children {
+			isDir[name] = isDir[name] || child.kind == "D"
+		}
+	}
+	names := make([]string, 0, len(isDir))
+	for name := range isDir {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return sortsBefore(names[i], isDir[names[i]], names[j], isDir[names[j]]) })
+	return names
+}
+
+// diffChild returns the child of a node that may be missing
+func diffChild(node *diffNode, name string) *diffNode {
+	if node == nil {
+		return nil
+	}
+	return node.children[name]
+}
+
+// diffChangeOf classifies an entry present on at least one side, "" when unchanged
+func diffChangeOf(before, after *diffNode) string {
+	switch {
+	case before == nil:
+		return diffAdded
+	case after == nil:
+		return diffRemoved
+	case before.kind != after.kind:
+		return diffTypeChanged
+	}
+	return ""
+}
+
+// diffChanged reports whether anything differs at or below a pair of entries
+func diffChanged(before, after *diffNode) bool {
+	if diffChangeOf(before, after) != "" {
+		return true
+	}
+	for _, name := range diffNames(before, after) {
+		if diffChanged(diffChild(before, name), diffChild(after, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectDiff lists every differing entry below a pair of directories, depth first.
+// Everything below an added or removed directory is listed too, so the flat list
+// names each path that appears or disappears.
+func collectDiff(report *diffReport, before, after *diffNode, parent string) {
+	for _, name := range diffNames(before, after) {
+		oldChild, newChild := diffChild(before, name), diffChild(after, name)
+		rel := strings.TrimPrefix(parent+"/"+name, "/")
+		change := diffChangeOf(oldChild, newChild)
+		switch change {
+		case diffAdded:
+			report.Summary.Added++
+			report.Changes = append(report.Changes, diffChange{Path: rel, Change: change, Type: jsonTypes[newChild.kind]})
+		case diffRemoved:
+			report.Summary.Removed++
+			report.Changes = append(report.Changes, diffChange{Path: rel, Change: change, Type: jsonTypes[oldChild.kind]})
+		case diffTypeChanged:
+			report.Summary.TypeChanged++
+			report.Changes = append(report.Changes, diffChange{Path: rel, Change: change, Type: jsonTypes[newChild.kind], From: jsonTypes[oldChild.kind]})
+		}
+		collectDiff(report, oldChild, newChild, rel)
+	}
+}
+
+// writeDiffTree prints the entries that differ with their ancestors, marked + for
+// added, - for removed and ~ for a changed type; unchanged subtrees are left out
+func writeDiffTree(writer io.Writer, before, after *diffNode, prefix string) {
+	var names []string
+	for _, name := range diffNames(before, after) {
+		if diffChanged(diffChild(before, name), diffChild(after, name)) {
+			names = append(names, name)
+		}
+	}
+	for i, name := range names {
+		oldChild, newChild := diffChild(before, name), diffChild(after, name)
+		change := diffChangeOf(oldChild, newChild)
+		isLast := i == len(names)-1
+		connector, childPrefix := connectors.Connector(isLast), connectors.Indent(prefix, isLast)
+		shown := newChild
+		if shown == nil {
+			shown = oldChild
+		}
+		label := name
+		if change == diffTypeChanged {
+			label += fmt.Sprintf(" (was %s)", oldChild.kind)
+		}
+		fmt.Fprintf(writer, "%s %s [%s] %s\n", diffMarkers[change], prefix+connector, shown.kind, label)
+		writeDiffTree(writer, oldChild, newChild, childPrefix)
+	}
+}
+
+// writeDiffSummary prints the totals of a diff
+func writeDiffSummary(writer io.Writer, report diffReport) {
+	if report.Identical {
+		fmt.Fprintln(writer, "No differences.")
+		return
+	}
+	fmt.Fprintf(writer, "%s added, %s removed, %s changed type\n",
+		groupThousands(report.Summary.Added), groupThousands(report.Summary.Removed), groupThousands(report.Summary.TypeChanged))
+}
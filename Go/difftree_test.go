@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// ftg diff shows a rename as a removal and an addition, a nested addition with every
+// directory it adds, a type change with what the directory held, and nothing for
+// identical trees, with the exit code for differences only when there are some;
+// -f json lists the same changes
+func TestDiffTrees(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		tree     string
+		changes  []string // change path, in the JSON report's order
+		code     int
+	}{
+		{
+			"rename",
+			"src/old.go\nsrc/keep.go\n", "src/renamed.go\nsrc/keep.go\n",
+			"  └── [D] src\n" +
+				"-     ├── [F] old.go\n" +
+				"+     └── [F] renamed.go\n",
+			[]string{"removed src/old.go", "added src/renamed.go"},
+			exitDifferences,
+		},
+		{
+			"nested addition",
+			"src/keep.go\n", "src/keep.go\nsrc/new/deep/x.go\n",
+			"  └── [D] src\n" +
+				"+     └── [D] new\n" +
+				"+         └── [D] deep\n" +
+				"+             └── [F] x.go\n",
+			[]string{"added src/new", "added src/new/deep", "added src/new/deep/x.go"},
+			exitDifferences,
+		},
+		{
+			"type change",
+			"t/x\n", "t\n",
+			"~ └── [F] t (was D)\n" +
+				"-     └── [F] x\n",
+			[]string{"typeChanged t", "removed t/x"},
+			exitDifferences,
+		},
+		{"identical", "a/b.txt\nc.txt\n", "a/b.txt\nc.txt\n", "No differences.\n", nil, exitOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			before, after := testtree.Dir(t, test.old), testtree.Dir(t, test.new)
+			stdout, stderr, code := runFTG(t, dir, "diff", before, after)
+			if code != test.code || !strings.Contains(stdout, test.tree) {
+				t.Errorf("exit code %d, %s\ngot\n%s\nwant %d and\n%s", code, stderr, stdout, test.code, test.tree)
+			}
+
+			stdout, stderr, code = runFTG(t, dir, "diff", "-f", "json", before, after)
+			var report diffReport
+			if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+				t.Fatalf("exit code %d, %v: %s%s", code, err, stdout, stderr)
+			}
+			var changes []string
+			for _, change := range report.Changes {
+				changes = append(changes, change.Change+" "+change.Path)
+			}
+			if code != test.code || report.Identical != (test.changes == nil) || !slices.Equal(changes, test.changes) {
+				t.Errorf("-f json: exit code %d, identical %v, changes %q; want %d and %q", code, report.Identical, changes, test.code, test.changes)
+			}
+		})
+	}
+}
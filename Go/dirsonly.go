@@ -0,0 +1,22 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// filterDirsOnly drops everything but directories, and the links to directories the
+// walk follows, with --dirs-only. It runs after the other filters, so --prune still
+// judges a directory by the files inside it.
+func filterDirsOnly(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if !scan.DirsOnly {
+		return entries
+	}
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		if entry.IsDir() || shouldDescend(filepath.Join(dir, entry.Name()), entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
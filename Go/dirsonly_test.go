@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// dirsFixture has files before, between and after the directories of every level,
+// and directories that hold files only or nothing
+const dirsFixture = `
+0.txt
+a/1.txt
+a/b/2.txt
+a/b/c/3.txt
+a/b/z.txt
+a/e/
+a/z.txt
+f-only/x.txt
+m.txt
+z/
+zz.txt
+`
+
+// --dirs-only leaves every file out, ends each level on its last directory and counts
+// only directories; -L cuts it as usual, and --prune drops the empty directories but
+// keeps those that only hold files
+func TestDirsOnly(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		prune bool
+		want  string
+	}{
+		{"all", 0, false, "├── [D] a\n" +
+			"│   ├── [D] b\n" +
+			"│   │   └── [D] c\n" +
+			"│   └── [D] e\n" +
+			"├── [D] f-only\n" +
+			"└── [D] z\n" +
+			"\nSummary: 6 directories, 0 excluded\n"},
+		{"-L 2", 2, false, "├── [D] a\n" +
+			"│   ├── [D] b\n" +
+			"│   │   └── … (1 entry omitted)\n" +
+			"│   └── [D] e\n" +
+			"├── [D] f-only\n" +
+			"└── [D] z\n"},
+		{"--prune", 0, true, "├── [D] a\n" +
+			"│   └── [D] b\n" +
+			"│       └── [D] c\n" +
+			"└── [D] f-only\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, dirsFixture), true, func() {
+				setOption(t, &scan.DirsOnly, true)
+				setOption(t, &scan.MaxDepth, test.depth)
+				setOption(t, &pruneEmpty, test.prune)
+			})
+			if !strings.HasPrefix(got, test.want) {
+				t.Errorf("got\n%s\nwant it to start with\n%s", got, test.want)
+			}
+			if strings.Contains(got, "[F]") {
+				t.Errorf("files are listed:\n%s", got)
+			}
+		})
+	}
+}
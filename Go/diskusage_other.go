@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package main
+
+import "io/fs"
+
+// allocatedSize is unknown here; sizes on disk fall back to the length of the file
+func allocatedSize(string, fs.FileInfo) (int64, bool) {
+	return 0, false
+}
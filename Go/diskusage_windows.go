@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/fs"
+	"syscall"
+	"unsafe"
+)
+
+var procGetCompressedFileSize = syscall.NewLazyDLL("kernel32.dll").NewProc("GetCompressedFileSizeW")
+
+// allocatedSize returns the bytes GetCompressedFileSize reports for the file: what a
+// compressed or sparse file takes on disk, and the length of any other
+func allocatedSize(fullPath string, info fs.FileInfo) (int64, bool) {
+	if _, ok := info.Sys().(*syscall.Win32FileAttributeData); !ok {
+		return 0, false
+	}
+	name, err := syscall.UTF16PtrFromString(fullPath)
+	if err != nil {
+		return 0, false
+	}
+	var high uint32
+	low, _, err := procGetCompressedFileSize.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&high)))
+	if uint32(low) == 0xFFFFFFFF && err != syscall.Errno(0) {
+		// INVALID_FILE_SIZE, unless it is the low half of a real size
+		return 0, false
+	}
+	return int64(high)<<32 | int64(uint32(low)), true
+}
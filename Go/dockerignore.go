@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// dockerRule is one pattern line of a .dockerignore file
+type dockerRule struct {
+	pattern string // Cleaned pattern relative to the build context, without "!"
+	negate  bool   // "!" re-includes what earlier lines excluded
+	line    int
+}
+
+var (
+	useDockerignore bool         // --dockerignore: show what docker build sends as the build context
+	dockerRules     []dockerRule // Rules of the root .dockerignore, in file order
+)
+
+// dockerAlwaysSent are the files the build context always includes: docker needs them
+// to run the build even when .dockerignore lists them
+var dockerAlwaysSent = []string{"Dockerfile", ".dockerignore"}
+
+// loadDockerignore parses the root .dockerignore. Docker reads no other ignore file,
+// so unlike .gitignore there are no per-directory files.
+func loadDockerignore(root string) {
+	file := filepath.Join(root, ".dockerignore")
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			warnf("--dockerignore found no %s; docker would send the whole directory", displayPath(".dockerignore"))
+		} else {
+			warnf("Cannot read %s: %v", file, err)
+		}
+		return
+	}
+	defer f.Close()
+	digest := sha256.New()
+	defer recordIgnoreFile(displayPath(".dockerignore"), digest)
+	scanner := bufio.NewScanner(io.TeeReader(f, digest))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if rule, ok := newDockerRule(text, line); ok {
+			dockerRules = append(dockerRules, rule)
+		}
+	}
+}
+
+// newDockerRule parses one line the way docker does: a "#" only starts a comment in
+// the first column, surrounding space is trimmed, and the pattern is cleaned and made
+// relative to the context, so "/dist/", "./dist" and "dist" are the same rule
+func newDockerRule(text string, line int) (dockerRule, bool) {
+	if strings.HasPrefix(text, "#") {
+		return dockerRule{}, false
+	}
+	text = strings.TrimSpace(text)
+	rule := dockerRule{line: line}
+	if strings.HasPrefix(text, "!") {
+		rule.negate = true
+		text = strings.TrimSpace(text[1:])
+	}
+	if text == "" {
+		return dockerRule{}, false
+	}
+	text = strings.TrimPrefix(path.Clean(filepath.ToSlash(text)), "/")
+	if text == "" || text == "." {
+		return dockerRule{}, false
+	}
+	rule.pattern = text
+	return rule, true
+}
+
+// matches reports whether the rule applies to rel or to a directory above it. Patterns
+// match whole paths from the context root: "*.md" only matches at the top, and
+// "**/*.md" at any depth.
+func (r dockerRule) matches(rel string) bool {
+	for current := rel; current != "." && current != "/"; current = path.Dir(current) {
+		if ftree.MatchPath(r.pattern, current) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayMatchBelow reports whether the rule could match a path inside dir: its leading
+// segments match the directory's, and it has segments left or a "**"
+func (r dockerRule) mayMatchBelow(dir string) bool {
+	patternParts, dirParts := strings.Split(r.pattern, "/"), strings.Split(dir, "/")
+	for i, part := range dirParts {
+		if i >= len(patternParts) {
+			return false
+		}
+		if patternParts[i] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(patternParts[i], part); !ok {
+			return false
+		}
+	}
+	return len(patternParts) > len(dirParts)
+}
+
+// origin describes where a rule came from, for explain and the --explain-excludes table
+func (r dockerRule) origin() string {
+	pattern := r.pattern
+	if r.negate {
+		pattern = "!" + pattern
+	}
+	return fmt.Sprintf("%s:%d (%s)", displayPath(".dockerignore"), r.line, pattern)
+}
+
+// dockerignoreRule returns the rule deciding whether docker sends rel: the last line
+// matching it or a directory above it. An excluded directory that a later "!" line
+// could re-include something inside is kept, as docker walks into it, and its
+// entries are decided one by one; the Dockerfile and .dockerignore are always kept.
+func dockerignoreRule(rel string, isDir bool) (excludeRule, bool) {
+	for _, name := range dockerAlwaysSent {
+		if rel == name {
+			return excludeRule{pattern: name, include: true, origin: "always sent by docker build", fromFile: true}, true
+		}
+	}
+	winner := -1
+	for i, rule := range dockerRules {
+		if rule.matches(rel) {
+			winner = i
+		}
+	}
+	if winner < 0 {
+		return excludeRule{}, false
+	}
+	rule := dockerRules[winner]
+	if !rule.negate && isDir {
+		for _, later := range dockerRules[winner+1:] {
+			if later.negate && later.mayMatchBelow(rel) {
+				return excludeRule{pattern: later.pattern, include: true, origin: later.origin() + ", which may match inside", fromFile: true}, true
+			}
+		}
+	}
+	return excludeRule{pattern: rule.pattern, include: rule.negate, origin: rule.origin(), fromFile: true}, true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+var (
+	entryFormat   string             // --entry-format: preset name or text/template of what follows each connector
+	entryTemplate *template.Template // The parsed --entry-format, nil for the usual lines
+	entryFailed   bool               // An entry's template has failed and been warned about
+)
+
+// entryFormatPresets are the --entry-format names; default is the usual line
+var entryFormatPresets = map[string]string{
+	"default":  "",
+	"compact":  "{{.Icon}}{{.Name}}",
+	"detailed": "{{.Icon}}{{.Name}}  [{{.Type}}]{{with .Size}}  {{.}}{{end}}  {{.ModTime}}",
+}
+
+// entryFields are what an --entry-format template can show of an entry
+type entryFields struct {
+	Name    string // Shown name, redacted and with unprintable characters escaped
+	Label   string // The name with every note the options add, as in the usual line
+	Type    string // D, F or L
+	IsDir   bool   // A directory, or a link the walk follows
+	Size    string // File size such as 4.2 KB; empty for directories
+	Bytes   int64  // File size in bytes; 0 for directories
+	RelPath string // Path from the input directory, with slashes
+	ModTime string // Modification time in --time-format, ? when it cannot be read
+	Icon    string // --icons glyph and a space, or empty
+}
+
+// entryFormatFuncs pad a field to a display width for aligned columns:
+// {{rpad 30 .Name}} fills on the right, {{lpad 9 .Size}} on the left
+var entryFormatFuncs = template.FuncMap{
+	"rpad": func(width int, s string) string { return s + strings.Repeat(" ", max(0, width-displayWidth(s))) },
+	"lpad": func(width int, s string) string { return strings.Repeat(" ", max(0, width-displayWidth(s))) + s },
+}
+
+// templateErrorAt finds the action an execution error is about, and templateQuoted
+// the quoted word of a parse error such as an unknown function
+var (
+	templateErrorAt = regexp.MustCompile(`at <([^>]+)>: (.*)`)
+	templateQuoted  = regexp.MustCompile(`"([^"]+)"`)
+)
+
+// parseEntryFormat resolves a preset name or parses and trial-runs a template, so a
+// field or function that does not exist fails here rather than on every line. The
+// error shows the template with a caret under the offending part when it can be found.
+func parseEntryFormat(spec string) (*template.Template, error) {
+	if preset, ok := entryFormatPresets[spec]; ok {
+		if preset == "" {
+			return nil, nil
+		}
+		spec = preset
+	}
+	tmpl, err := template.New("entry-format").Funcs(entryFormatFuncs).Parse(spec)
+	if err == nil {
+		sample := entryFields{Name: "main.go", Label: "main.go", Type: "F", Size: "1.2 KB", Bytes: 1234, RelPath: "cmd/main.go", ModTime: "2024-03-12 09:41"}
+		err = tmpl.Execute(io.Discard, sample)
+		// Errors that depend on the entry, like an index out of range, are left to
+		// the fallback of formatEntry
+		if err != nil && !strings.Contains(err.Error(), "can't evaluate field") && !strings.Contains(err.Error(), "wrong type") && !strings.Contains(err.Error(), "wrong number of args") {
+			err = nil
+		}
+	}
+	if err == nil {
+		return tmpl, nil
+	}
+	message, part := strings.TrimPrefix(err.Error(), "template: entry-format:"), ""
+	if found := templateErrorAt.FindStringSubmatch(err.Error()); found != nil {
+		message, part = found[2], found[1]
+	} else if found := templateQuoted.FindStringSubmatch(message); found != nil {
+		part = found[1]
+	} else if strings.Contains(message, "unclosed action") {
+		part = spec[strings.LastIndex(spec, "{{"):]
+	}
+	names := make([]string, 0, len(entryFormatPresets))
+	for name := range entryFormatPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hint := fmt.Sprintf("fields are .Name, .Label, .Type, .IsDir, .Size, .Bytes, .RelPath, .ModTime and .Icon, functions rpad and lpad, presets %s", strings.Join(names, ", "))
+	if i := strings.Index(spec, part); part != "" && i >= 0 {
+		return nil, fmt.Errorf("invalid --entry-format: %s\n  %s\n  %s^\n(%s)", message, spec, strings.Repeat(" ", displayWidth(spec[:i])), hint)
+	}
+	return nil, fmt.Errorf("invalid --entry-format: %s\n  %s\n(%s)", message, spec, hint)
+}
+
+// newEntryFields describes an entry of the tree for --entry-format
+func newEntryFields(path string, entry fs.DirEntry, entryType, shownName, icon, label string, isDir bool) *entryFields {
+	if entryTemplate == nil {
+		return nil
+	}
+	fields := &entryFields{Name: shownName, Label: label, Type: entryType, IsDir: isDir, Icon: icon, ModTime: "?"}
+	fields.RelPath = redactPath(relativePath(path, entry.Name()))
+	if isDir && outputFormat == formatText && trailingSlash {
+		fields.Name += "/"
+	}
+	if info, err := entryInfo(entry); err == nil {
+		fields.ModTime = formatTimestamp(info.ModTime())
+		if !isDir {
+			fields.Size, fields.Bytes = formatSize(info.Size()), info.Size()
+		}
+	}
+	return fields
+}
+
+// formatEntry runs --entry-format for one entry. An entry the template fails on
+// shows its name instead, with one warning for the run, rather than ending it.
+func formatEntry(fields *entryFields) string {
+	var line strings.Builder
+	if err := entryTemplate.Execute(&line, fields); err != nil {
+		if !entryFailed {
+			entryFailed = true
+			warnf("--entry-format failed for %s (%v); showing names instead", filepath.ToSlash(fields.RelPath), err)
+		}
+		return fields.Name
+	}
+	// A template that writes newlines would break the tree apart
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(line.String())
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// entryFormatTree has a directory, whose Size is empty, and files of two sizes
+const entryFormatTree = "a/b.txt size=2048\nc.txt size=5\n"
+
+// A custom template and the presets replace what follows each connector; Size is empty
+// for directories, so a with block leaves them without one
+func TestEntryFormat(t *testing.T) {
+	src := testtree.Dir(t, entryFormatTree)
+	for _, test := range []struct {
+		format string
+		want   string
+	}{
+		{"{{.Type}}:{{.Name}}{{with .Size}} ({{.}}){{end}} {{.RelPath}}", "├── D:a a\n" +
+			"│   └── F:b.txt (2.0 KB) a/b.txt\n" +
+			"└── F:c.txt (5 B) c.txt\n"},
+		{"{{rpad 6 .Name}}|{{lpad 7 .Size}}|", "├── a     |       |\n" +
+			"│   └── b.txt | 2.0 KB|\n" +
+			"└── c.txt |    5 B|\n"},
+		{"compact", "├── a\n│   └── b.txt\n└── c.txt\n"},
+		{"default", "├── [D] a\n│   └── [F] b.txt\n└── [F] c.txt\n"},
+	} {
+		stdout, stderr, code := runFTG(t, t.TempDir(), "-d", src, "--entry-format", test.format, "-o", "-", "--no-summary")
+		if code != exitOK || stdout != test.want {
+			t.Errorf("%s: exit code %d, %s\ngot\n%s\nwant\n%s", test.format, code, stderr, stdout, test.want)
+		}
+	}
+}
+
+// An invalid template is refused before the walk, with a caret under the part at
+// fault; one that only fails on some entries shows their names and warns once
+func TestEntryFormatInvalid(t *testing.T) {
+	src := testtree.Dir(t, entryFormatTree)
+	for _, test := range []struct {
+		format string
+		want   string
+	}{
+		{"{{.Nmae}}", "can't evaluate field Nmae in type main.entryFields\n  {{.Nmae}}\n    ^\n"},
+		{"x {{.Name", "unclosed action\n  x {{.Name\n    ^\n"},
+		{"{{foo .Name}}", "function \"foo\" not defined\n  {{foo .Name}}\n    ^\n"},
+	} {
+		_, stderr, code := runFTG(t, t.TempDir(), "-d", src, "--entry-format", test.format, "-o", "-")
+		if code != exitUsage || !strings.Contains(stderr, test.want) || !strings.Contains(stderr, "fields are .Name") {
+			t.Errorf("%s: exit code %d, %s; want %d and %q", test.format, code, stderr, exitUsage, test.want)
+		}
+	}
+
+	stdout, stderr, code := runFTG(t, t.TempDir(), "-d", src, "--entry-format", "{{index .Name 5}}", "-o", "-", "--no-summary")
+	if want := "├── a\n│   └── b.txt\n└── c.txt\n"; code != exitWarnings || stdout != want || strings.Count(stderr, "--entry-format failed") != 1 {
+		t.Errorf("exit code %d, %s\ngot\n%s\nwant\n%s", code, stderr, stdout, want)
+	}
+
+	if _, stderr, code := runFTG(t, t.TempDir(), "-d", src, "--entry-format", "compact", "-f", "json", "-o", "-"); code != exitUsage ||
+		!strings.Contains(stderr, "--entry-format is only available with -f md and -f text") {
+		t.Errorf("-f json: exit code %d, %s", code, stderr)
+	}
+}
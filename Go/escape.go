@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// printableName returns a name with what would corrupt or disguise a line of output
+// made visible: invalid UTF-8 bytes as \xff, control characters as \n, \t, \x1b or
+// \u0085, and the bidirectional controls that reorder text as \u202e. A backslash
+// becomes \\, so a name spelling out "\n" never looks like one holding a line break;
+// on Windows, where it separates paths and no name holds one, it is kept. Everything
+// else, joiners and emoji included, is kept, so the output is always valid UTF-8
+// and one name never spans lines.
+func printableName(name string) string {
+	var shown strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		escape := ""
+		switch {
+		case r == '\\' && filepath.Separator != '\\':
+			escape = `\\`
+		case r == utf8.RuneError && size == 1:
+			escape = fmt.Sprintf(`\x%02x`, name[i])
+		case r == '\n':
+			escape = `\n`
+		case r == '\r':
+			escape = `\r`
+		case r == '\t':
+			escape = `\t`
+		case r < 0x80 && unicode.IsControl(r):
+			escape = fmt.Sprintf(`\x%02x`, r)
+		case unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r):
+			escape = fmt.Sprintf(`\u%04x`, r)
+		}
+		if escape != "" && shown.Len() == 0 {
+			shown.WriteString(name[:i])
+		}
+		switch {
+		case escape != "":
+			shown.WriteString(escape)
+		case shown.Len() > 0:
+			shown.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+	if shown.Len() == 0 {
+		return name
+	}
+	return shown.String()
+}
+
+// displayName returns the name to show in a tree line: redacted, then made printable
+func displayName(name string) (string, bool) {
+	shown, redacted := redactName(name)
+	return printableName(shown), redacted
+}
+
+// printablePath applies printableName to every segment of a slash-separated path
+func printablePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = printableName(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// longestBacktickRun returns the length of the longest run of backticks in text
+func longestBacktickRun(text []byte) int {
+	longest, run := 0, 0
+	for _, b := range text {
+		if b == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// isFenceLine reports whether a line opens or closes a code block ftg wrote: three or
+// more backticks, then an info string such as "sh" or nothing
+func isFenceLine(line []byte) bool {
+	rest := bytes.TrimLeft(line, "`")
+	if len(line)-len(rest) < 3 {
+		return false
+	}
+	for _, b := range rest {
+		if b < 'a' || b > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// widenFences lengthens the code fences of a markdown document past the longest run
+// of backticks anywhere else in it, so a name like "```" can never close the tree's
+// code block early. Documents without such a run are returned as they are.
+func widenFences(doc []byte) []byte {
+	lines := bytes.SplitAfter(doc, []byte("\n"))
+	longest := 0
+	for _, line := range lines {
+		if !isFenceLine(bytes.TrimRight(line, "\n")) {
+			longest = max(longest, longestBacktickRun(line))
+		}
+	}
+	if longest < 3 {
+		return doc
+	}
+	fence := bytes.Repeat([]byte("`"), longest+1)
+	var out bytes.Buffer
+	for _, line := range lines {
+		if isFenceLine(bytes.TrimRight(line, "\n")) {
+			out.Write(fence)
+			out.Write(bytes.TrimLeft(line, "`"))
+			continue
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
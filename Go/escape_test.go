@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestPrintableName(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"plain.txt", "plain.txt"},
+		{"new\nline", `new\nline`},
+		{`new\nline`, `new\\nline`},
+		{`\`, `\\`},
+		{"tab\there", `tab\there`},
+		{"bad\xffbyte", `bad\xffbyte`},
+		{"rtl\u202etxt.exe", `rtl\u202etxt.exe`},
+		{"emoji 🐹", "emoji 🐹"},
+	}
+	for _, test := range tests {
+		want := test.want
+		if filepath.Separator == '\\' {
+			// Windows names never hold a backslash, so it is left alone there
+			want = strings.ReplaceAll(want, `\\`, `\`)
+		}
+		if got := printableName(test.name); got != want {
+			t.Errorf("printableName(%q) = %q, want %q", test.name, got, want)
+		}
+	}
+}
+
+// A name spelling out "\n" and one holding a line break render as different lines
+func TestBackslashNamesStayDistinct(t *testing.T) {
+	if filepath.Separator == '\\' {
+		t.Skip("Windows names cannot hold a backslash")
+	}
+	spec := strconv.Quote("new\nline") + "\n" + strconv.Quote(`new\nline`) + "\n"
+	tests := map[string][]string{
+		formatMarkdown: {`new\nline`, `new\\nline`},
+		formatText:     {`new\nline`, `new\\nline`},
+		// Markdown escapes each backslash once more
+		formatMarkdownList: {`new\\nline`, `new\\\\nline`},
+	}
+	for format, wants := range tests {
+		t.Run(format, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, spec), true, func() {
+				setOption(t, &outputFormat, format)
+			})
+			for _, want := range wants {
+				if !strings.Contains(got, want+"\n") {
+					t.Errorf("no line ending in %s in\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"time"
+)
+
+var estimateBudget = 5 * time.Second // --estimate-budget: time "ftg estimate" may spend sampling
+
+// estimateTally accumulates what a sample saw; in random probes every value is weighted
+// by the number of siblings skipped on the way down, so the totals are estimates
+type estimateTally struct {
+	dirs      float64
+	entries   float64
+	nameBytes float64 // Sum of name lengths, for output sizes
+	depthSum  float64 // Sum of entry depths, for the indentation of output lines
+}
+
+// add records one listed directory holding entries at depth, weighted
+func (t *estimateTally) add(depth int, entries []fs.DirEntry, weight float64) {
+	t.dirs += weight
+	for _, entry := range entries {
+		t.entries += weight
+		t.nameBytes += weight * float64(len(entry.Name()))
+		t.depthSum += weight * float64(depth)
+	}
+}
+
+// estimator samples a tree within a time budget
+type estimator struct {
+	reads    int
+	latency  time.Duration // Total time spent in ReadDir
+	deepest  int           // Deepest level seen
+	exact    estimateTally // Directories listed breadth first
+	frontier []estimateDir // Directories breadth first sampling did not reach
+	probes   []estimateTally
+}
+
+// estimateDir is a directory waiting to be listed
+type estimateDir struct {
+	path  string
+	depth int
+}
+
+// list reads dir the way the walk would, timing the read; subdirectories are returned separately
+func (e *estimator) list(dir string) (entries []fs.DirEntry, subdirs []string) {
+	start := time.Now()
+	listing, err := readDir(dir)
+	e.latency += time.Since(start)
+	e.reads++
+	if err != nil {
+		return nil, nil
+	}
+	for _, entry := range visibleEntries(dir, listing) {
+		if shouldExclude(dir, entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if fullPath := filepath.Join(dir, entry.Name()); shouldDescend(fullPath, entry) {
+			subdirs = append(subdirs, fullPath)
+		}
+	}
+	return entries, subdirs
+}
+
+// breadthFirst lists directories level by level until the deadline; the unvisited
+// rest of the queue becomes the frontier
+func (e *estimator) breadthFirst(root string, deadline time.Time) {
+	queue := []estimateDir{{root, 1}}
+	for len(queue) > 0 && time.Now().Before(deadline) {
+		dir := queue[0]
+		queue = queue[1:]
+		entries, subdirs := e.list(dir.path)
+		e.exact.add(dir.depth, entries, 1)
+		if len(entries) > 0 {
+			e.deepest = max(e.deepest, dir.depth)
+		}
+		for _, sub := range subdirs {
+			queue = append(queue, estimateDir{sub, dir.depth + 1})
+		}
+	}
+	e.frontier = queue
+}
+
+// probe walks from a random frontier directory down random subdirectories to a leaf.
+// Weighting each level by the product of the branching factors above it makes the
+// tally an unbiased estimate of the whole subtree below the start (Knuth, 1975).
+func (e *estimator) probe(deadline time.Time) bool {
+	dir := e.frontier[rand.Intn(len(e.frontier))]
+	var tally estimateTally
+	weight := 1.0
+	for {
+		if time.Now().After(deadline) {
+			return false
+		}
+		entries, subdirs := e.list(dir.path)
+		tally.add(dir.depth, entries, weight)
+		if len(entries) > 0 {
+			e.deepest = max(e.deepest, dir.depth)
+		}
+		if len(subdirs) == 0 {
+			break
+		}
+		weight *= float64(len(subdirs))
+		dir = estimateDir{subdirs[rand.Intn(len(subdirs))], dir.depth + 1}
+	}
+	e.probes = append(e.probes, tally)
+	return true
+}
+
+// extrapolate returns the estimated total of one tally field with a rough range of
+// two standard errors, never below what was counted exactly
+func (e *estimator) extrapolate(field func(estimateTally) float64) (total, low, high float64) {
+	known := field(e.exact)
+	if len(e.frontier) == 0 || len(e.probes) == 0 {
+		return known, known, known
+	}
+	var sum, squares float64
+	for _, probe := range e.probes {
+		sum += field(probe)
+	}
+	n := float64(len(e.probes))
+	mean := sum / n
+	for _, probe := range e.probes {
+		squares += (field(probe) - mean) * (field(probe) - mean)
+	}
+	scale := float64(len(e.frontier))
+	spread := 2 * scale * math.Sqrt(squares/n) / math.Sqrt(n)
+	if len(e.probes) == 1 {
+		spread = scale * mean
+	}
+	total = known + scale*mean
+	return total, max(known, total-spread), total + spread
+}
+
+// runEstimate samples the tree below root for "ftg estimate": half the budget lists
+// the top levels breadth first, the rest sends random probes below the frontier
+func runEstimate(root string, budget time.Duration) {
+	started := time.Now()
+	e := &estimator{}
+	e.breadthFirst(root, started.Add(budget/2))
+	for len(e.frontier) > 0 && e.probe(started.Add(budget)) {
+	}
+	if e.reads == 0 || e.exact.dirs == 0 {
+		errorExit("Cannot read the input directory")
+	}
+
+	entries, entriesLow, entriesHigh := e.extrapolate(func(t estimateTally) float64 { return t.entries })
+	dirs, dirsLow, dirsHigh := e.extrapolate(func(t estimateTally) float64 { return t.dirs })
+	nameBytes, _, _ := e.extrapolate(func(t estimateTally) float64 { return t.nameBytes })
+	depthSum, _, _ := e.extrapolate(func(t estimateTally) float64 { return t.depthSum })
+	perRead := e.latency / time.Duration(e.reads)
+	full := time.Duration(dirs) * perRead
+	if full > time.Second {
+		full = full.Round(time.Second)
+	} else {
+		full = full.Round(time.Millisecond)
+	}
+
+	fmt.Printf("Estimate for %s (sampled %s in %s)\n", scan.Root, groupThousands(e.reads)+" directory listings", time.Since(started).Round(time.Millisecond))
+	if len(e.frontier) == 0 {
+		fmt.Println("The sample covered the whole tree, so the counts below are exact.")
+	} else {
+		fmt.Printf("Rough figures: %s below %s unvisited directories were probed at random; the ranges are about two standard errors.\n",
+			plural(len(e.probes), "path"), groupThousands(len(e.frontier)))
+	}
+	fmt.Printf("  entries:          %s\n", estimateRange(entries, entriesLow, entriesHigh))
+	fmt.Printf("  directories:      %s\n", estimateRange(dirs, dirsLow, dirsHigh))
+	fmt.Printf("  depth:            %d (deepest level seen)\n", e.deepest)
+	fmt.Printf("  ReadDir latency:  %s per directory (mean of %s)\n", perRead.Round(time.Microsecond), groupThousands(e.reads))
+	fmt.Printf("  full scan:        ~%s (directories × latency; caches and other load change it)\n", full)
+	if entries == 0 {
+		return
+	}
+	avgName, avgDepth := nameBytes/entries, depthSum/entries
+	fmt.Printf("  output size:      md ~%s, json ~%s, svg ~%s\n",
+		formatSize(int64(entries*estimateLineBytes(formatMarkdown, avgName, avgDepth))),
+		formatSize(int64(entries*estimateLineBytes(formatJSON, avgName, avgDepth))),
+		formatSize(int64(entries*estimateLineBytes(formatSVG, avgName, avgDepth))))
+}
+
+// estimateLineBytes approximates the bytes one entry adds to the output of a format,
+// from the average name length and depth; labels and annotations are not counted
+func estimateLineBytes(format string, avgName, avgDepth float64) float64 {
+	line := float64(len(connectors.Branch)+len("[F] \n")) + avgName + (avgDepth-1)*float64(len(connectors.Pipe))
+	switch format {
+	case formatJSON:
+		// {"name": "...", "type": "file"} on indented lines, two levels of indentation per depth
+		return 44 + avgName + avgDepth*4*4
+	case formatSVG:
+		// One <text> element per line with its coordinates
+		return 48 + line
+	}
+	return line
+}
+
+// estimateRange formats an estimate and its range, or just the count when it is exact
+func estimateRange(total, low, high float64) string {
+	if low == high {
+		return groupThousands(int(total))
+	}
+	return fmt.Sprintf("~%s (%s – %s)", groupThousands(int(math.Round(total))), groupThousands(int(low)), groupThousands(int(math.Round(high))))
+}
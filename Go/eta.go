@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"time"
+)
+
+// etaSmoothing is the weight of the newest directory in the moving average of time
+// per directory: recent directories count most, as speed changes across a tree
+const etaSmoothing = 0.05
+
+// etaLevel counts the directories at one depth of the walk
+type etaLevel struct {
+	found    int // Directories at this depth the walk will enter
+	visited  int // Those read so far
+	children int // Subdirectories found in those read
+}
+
+// etaEstimator predicts the rest of a walk. Each directory found but not yet read is
+// expected to hold as many directories below it as those read at its depth did,
+// and each directory to take the moving average of the recent time per directory.
+type etaEstimator struct {
+	levels   []etaLevel // Indexed by depth, the input directory at 0
+	visited  int        // Directories read
+	last     time.Time  // When the previous directory was read
+	mean     float64    // Moving average of seconds per directory
+	variance float64    // Moving variance of seconds per directory
+}
+
+// etaEstimate is a prediction of the rest of the walk
+type etaEstimate struct {
+	remaining time.Duration // Expected directories left times the time per directory
+	spread    time.Duration // How far off remaining may be from the spread of directory times
+	percent   float64       // Directories read of those expected in all
+	growing   bool          // Directories were found at a depth none was read at, so remaining is a lower bound
+}
+
+// level returns the counts of a depth, growing the table as the walk goes deeper
+func (e *etaEstimator) level(depth int) *etaLevel {
+	for len(e.levels) <= depth {
+		e.levels = append(e.levels, etaLevel{})
+	}
+	return &e.levels[depth]
+}
+
+// visit records that a directory at depth was read at now and holds found
+// subdirectories the walk will enter
+func (e *etaEstimator) visit(now time.Time, depth, found int) {
+	if e.visited > 0 {
+		seconds := max(now.Sub(e.last).Seconds(), 0)
+		if e.visited == 1 {
+			e.mean = seconds
+		} else {
+			delta := seconds - e.mean
+			e.mean += etaSmoothing * delta
+			e.variance = (1 - etaSmoothing) * (e.variance + etaSmoothing*delta*delta)
+		}
+	}
+	e.last = now
+	e.visited++
+	current := e.level(max(depth, 0))
+	current.visited++
+	if current.found < current.visited {
+		// The input directory, or one read without being found, e.g. outside the walk
+		current.found = current.visited
+	}
+	e.discover(depth, found)
+}
+
+// discover adds subdirectories found in a later batch of a listing already visited
+func (e *etaEstimator) discover(depth, found int) {
+	depth, found = max(depth, 0), max(found, 0)
+	e.level(depth).children += found
+	e.level(depth + 1).found += found
+}
+
+// estimate predicts the rest of the walk, or reports false before there is a time to go by
+func (e *etaEstimator) estimate() (etaEstimate, bool) {
+	if e.visited < 2 {
+		return etaEstimate{}, false
+	}
+	var est etaEstimate
+	left, below := 0.0, 1.0 // below: expected directories under one found at the depth, itself included
+	for depth := len(e.levels) - 1; depth >= 0; depth-- {
+		level := e.levels[depth]
+		if level.visited > 0 {
+			below = 1 + float64(level.children)/float64(level.visited)*below
+		} else {
+			below = 1
+		}
+		if pending := level.found - level.visited; pending > 0 {
+			left += float64(pending) * below
+			est.growing = est.growing || level.visited == 0
+		}
+	}
+	est.remaining = time.Duration(left * e.mean * float64(time.Second))
+	est.spread = time.Duration(math.Sqrt(left*e.variance) * float64(time.Second))
+	est.percent = 100 * float64(e.visited) / (float64(e.visited) + left)
+	return est, true
+}
+
+// describe formats an estimate like "~3m20s remaining (62%)". While directories turn
+// up at a depth none was read at yet, the time is a lower bound and the share an
+// upper one, and the text says so.
+func (est etaEstimate) describe() string {
+	remaining := roundETA(est.remaining)
+	if est.growing {
+		return fmt.Sprintf("at least ~%s remaining (at most %.0f%%, still finding deeper directories)", remaining, est.percent)
+	}
+	if spread := roundETA(est.spread); spread > 0 {
+		return fmt.Sprintf("~%s ±%s remaining (%.0f%%)", remaining, spread, est.percent)
+	}
+	return fmt.Sprintf("~%s remaining (%.0f%%)", remaining, est.percent)
+}
+
+// roundETA rounds a duration to what a reader can use: seconds, or tenths under 10s
+func roundETA(d time.Duration) time.Duration {
+	if d < 10*time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
+
+var walkETA etaEstimator // Fed by the walk when --progress-json is on
+
+// progressDiscover feeds the estimator a directory listing: the subdirectories the
+// walk will enter, after exclusions and --max-depth, are found. Followed links are
+// left out, as deciding to follow one can warn. A listing read in batches passes
+// visited only with its first one.
+func progressDiscover(dir string, entries []fs.DirEntry, visited bool) {
+	if !progress.enabled {
+		return
+	}
+	found := 0
+	for _, entry := range entries {
+		if entry.IsDir() && withinDepth(filepath.Join(dir, entry.Name())) && !shouldExclude(dir, entry) {
+			found++
+		}
+	}
+	depth := 0
+	if dir != scan.Root {
+		depth = entryDepth(dir)
+	}
+	if visited {
+		walkETA.visit(time.Now(), depth, found)
+	} else {
+		walkETA.discover(depth, found)
+	}
+}
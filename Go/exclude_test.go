@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// The connectors follow the visible entries, wherever the excluded ones sort
+func TestExcludedEntriesKeepConnectors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{"beginning", `
+.git/HEAD
+a.txt
+b.txt
+`, `├── [F] a.txt
+└── [F] b.txt
+`},
+		{"middle", `
+a.txt
+node_modules/x.js
+z.txt
+`, `├── [F] a.txt
+└── [F] z.txt
+`},
+		{"end", `
+a.txt
+lib/
+lib/b.txt
+lib/node_modules/x.js
+node_modules/y.js
+`, `├── [F] a.txt
+└── [D] lib
+    └── [F] b.txt
+`},
+		{"end of a directory above others", `
+lib/
+lib/b.txt
+lib/node_modules/x.js
+z.txt
+`, `├── [D] lib
+│   └── [F] b.txt
+└── [F] z.txt
+`},
+		{"everything", `
+lib/node_modules/x.js
+lib/target/y.o
+z.txt
+`, `├── [D] lib
+└── [F] z.txt
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, test.spec), true, func() {
+				setOption(t, &noSummary, true)
+			})
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// A bare name excludes every entry with that name, a path only the entry at that path
+func TestExcludeByRelativePath(t *testing.T) {
+	const spec = `
+packages/legacy/src/old.go
+packages/web/src/app.ts
+src/main.go
+`
+	tests := []struct {
+		pattern, want string
+	}{
+		{"src", `└── [D] packages
+    ├── [D] legacy
+    └── [D] web
+`},
+		{"packages/legacy/src", `├── [D] packages
+│   ├── [D] legacy
+│   └── [D] web
+│       └── [D] src
+│           └── [F] app.ts
+└── [D] src
+    └── [F] main.go
+`},
+	}
+	full := `├── [D] packages
+│   ├── [D] legacy
+│   │   └── [D] src
+│   │       └── [F] old.go
+│   └── [D] web
+│       └── [D] src
+│           └── [F] app.ts
+└── [D] src
+    └── [F] main.go
+`
+	if filepath.Separator == '\\' {
+		// Backslashes separate paths on Windows only; elsewhere they stay part of the name
+		full = tests[1].want
+	}
+	tests = append(tests, struct{ pattern, want string }{`packages\legacy\src`, full})
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, spec), true, func() {
+				setOption(t, &noSummary, true)
+				addExcludeRule(sourceCLI, "user (-e)", test.pattern)
+			})
+			if got != test.want {
+				t.Errorf("-e %s: got\n%s\nwant\n%s", test.pattern, got, test.want)
+			}
+		})
+	}
+}
+
+// The default exclusions apply unless -c or --no-default-excludes turns them off,
+// and -e still applies without them
+func TestClearDefaultExcludes(t *testing.T) {
+	dir := testtree.Dir(t, `
+node_modules/left-pad/index.js
+.git/HEAD
+src/main.go
+src/main.log
+`)
+	const complete = `├── [D] .git
+│   └── [F] HEAD
+├── [D] node_modules
+│   └── [D] left-pad
+│       └── [F] index.js
+└── [D] src
+    ├── [F] main.go
+    └── [F] main.log
+`
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "└── [D] src\n    ├── [F] main.go\n    └── [F] main.log\n"},
+		{[]string{"-c"}, complete},
+		{[]string{"--no-default-excludes"}, complete},
+		{[]string{"-c", "-e", "*.log"}, `├── [D] .git
+│   └── [F] HEAD
+├── [D] node_modules
+│   └── [D] left-pad
+│       └── [F] index.js
+└── [D] src
+    └── [F] main.go
+`},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, append([]string{"-o", "-", "--no-summary"}, test.args...)...)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			if stdout != test.want {
+				t.Errorf("got\n%s\nwant\n%s", stdout, test.want)
+			}
+		})
+	}
+}
+
+// --exclude-from skips comments and blank lines and reads files saved on Windows,
+// with CRLF line ends and a byte order mark, like any other
+func TestLoadExcludeFile(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"LF", "# build output\ndist\n\n*.log\n  # indented comment\nsrc/gen/\n"},
+		{"CRLF", "# build output\r\ndist\r\n\r\n*.log\r\n  # indented comment\r\nsrc/gen/\r\n"},
+		{"BOM and CRLF", "\ufeff# build output\r\ndist\r\n\r\n*.log\r\n  # indented comment\r\nsrc/gen/"},
+		{"BOM before a pattern", "\ufeffdist\n\n*.log\n\n\nsrc/gen/\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setOption(t, &ruleLayers, map[string]*ruleLayer{})
+			setOption(t, &excludeSources, map[string]string{})
+			file := filepath.Join(t.TempDir(), "excludes")
+			if err := os.WriteFile(file, []byte(test.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := loadExcludeFile(file); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			rules := layer(sourceCLI).rules
+			for _, rule := range rules {
+				got = append(got, rule.pattern)
+			}
+			if want := "dist *.log src/gen/"; strings.Join(got, " ") != want {
+				t.Fatalf("patterns %q, want %q", got, want)
+			}
+			if line := test.content[:strings.Index(test.content, "*.log")]; !strings.HasSuffix(rules[1].origin, fmt.Sprintf(":%d)", strings.Count(line, "\n")+1)) {
+				t.Errorf("origin %q names the wrong line", rules[1].origin)
+			}
+		})
+	}
+}
+
+// The patterns of every --exclude-from apply in order after -e, and a file that
+// cannot be read fails before any output is written
+func TestExcludeFrom(t *testing.T) {
+	dir := testtree.Dir(t, "a.log\nkeep.log\nmain.go\nnotes.txt\n")
+	first, second := filepath.Join(t.TempDir(), "first"), filepath.Join(t.TempDir(), "second")
+	os.WriteFile(first, []byte("# logs\r\n*.log\r\n"), 0o644)
+	os.WriteFile(second, []byte("\ufeff!keep.log\n"), 0o644)
+	stdout, stderr, code := runFTG(t, dir, "-o", "-", "--no-summary", "-e", "*.txt", "--exclude-from", first, "--exclude-from", second)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if want := "├── [F] keep.log\n└── [F] main.go\n"; stdout != want {
+		t.Errorf("got\n%s\nwant\n%s", stdout, want)
+	}
+
+	out := filepath.Join(t.TempDir(), "tree.md")
+	_, stderr, code = runFTG(t, dir, "-o", out, "--exclude-from", filepath.Join(dir, "missing"))
+	if code == exitOK || !strings.Contains(stderr, "missing") {
+		t.Errorf("missing file: exit code %d, stderr %q", code, stderr)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Error("the output was written despite the unreadable --exclude-from file")
+	}
+}
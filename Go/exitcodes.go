@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Exit codes are a stable contract for wrapper scripts
+const (
+	exitOK          = 0 // Completed without warnings
+	exitFatal       = 1 // A fatal error stopped the run
+	exitUsage       = 2 // Invalid flags or arguments
+	exitWarnings    = 3 // Completed, but warnings were reported (e.g. unreadable directories)
+	exitDifferences = 4 // A check or diff found differences
+	exitTruncated   = 5 // Completed, but the output was cut short by a limit
+	exitFindings    = 6 // Completed, but --strict-security found risky permissions
+	exitPipe        = 7 // The --pipe command failed or timed out; nothing was written
+	exitMarkers     = 8 // --inject-dry-run found the markers missing, repeated or misordered
+	exitCancelled   = 9 // Ctrl-C, SIGTERM or --timeout stopped the run; nothing was written
+)
+
+// exitCodeTable is printed by --exit-codes
+var exitCodeTable = []struct {
+	code    int
+	meaning string
+}{
+	{exitOK, "completed without warnings"},
+	{exitFatal, "fatal error (unreadable input directory, failed write, git failure)"},
+	{exitUsage, "usage error (unknown flag, invalid flag value, missing argument)"},
+	{exitWarnings, "completed with warnings (see stderr or warning events)"},
+	{exitDifferences, "a check or diff found differences"},
+	{exitTruncated, "output truncated by a limit (--budget, --max-entries, --mermaid-max-nodes, the lines an SVG holds)"},
+	{exitFindings, "--strict-security found high or medium security findings"},
+	{exitPipe, "the --pipe command failed or timed out (its stderr is shown); no output file was replaced"},
+	{exitMarkers, "--inject-dry-run found the --inject markers missing, repeated or misordered"},
+	{exitCancelled, "cancelled by Ctrl-C, SIGTERM or --timeout; no output file was replaced"},
+}
+
+// showExitCodes prints the exit code contract and exits
+func showExitCodes() {
+	fmt.Println("Exit codes:")
+	for _, row := range exitCodeTable {
+		fmt.Printf("  %d  %s\n", row.code, row.meaning)
+	}
+	exitProcess(exitOK)
+}
+
+// exitProcess ends the run with code, giving the console back its code page first
+func exitProcess(code int) {
+	restoreConsole()
+	os.Exit(code)
+}
+
+// exitWith reports a message as an error and exits with code
+func exitWith(code int, message string) {
+	clearProgressLine()
+	writeResult(code, message)
+	appendUsageLog(code)
+	if progress.enabled {
+		emitProgress(progressEvent{Event: "error", Message: message})
+		exitProcess(code)
+	}
+	log.Printf("Error: %s\n", message)
+	exitProcess(code)
+}
+
+// usageExit reports an invalid flag or argument and exits with the usage code
+func usageExit(message string) {
+	exitWith(exitUsage, message)
+}
+
+var outputTruncated bool // A limit cut the output short, see noteTruncated
+
+// noteTruncated records that a limit such as --max-entries cut the output
+// short, for exitTruncated. Renders whose warnings are muted leave it alone, as
+// theirs is not the output written.
+func noteTruncated() {
+	if !progress.muted {
+		outputTruncated = true
+	}
+}
+
+// runExitCode returns the exit code of a finished run
+func runExitCode(ok bool) int {
+	switch {
+	case !ok:
+		return exitFatal
+	case strictSecurity && failingSecurityFindings() > 0:
+		return exitFindings
+	case budgetUnscanned > 0 || outputTruncated:
+		return exitTruncated
+	case progress.warnings > 0:
+		return exitWarnings
+	}
+	return exitOK
+}
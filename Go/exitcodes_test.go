@@ -0,0 +1,56 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Every code of the contract comes from the case it documents, and every limit that
+// cuts the output short exits with exitTruncated rather than exitWarnings
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"complete", []string{"-d", "src", "-o", "-"}, exitOK},
+		{"missing input directory", []string{"-d", "missing", "-o", "-"}, exitFatal},
+		{"unknown flag", []string{"--no-such-flag"}, exitUsage},
+		{"warning", []string{"-d", "src", "-f", "json", "-o", "tree.md"}, exitWarnings},
+		{"diff", []string{"diff", "src", "new"}, exitDifferences},
+		{"--max-entries", []string{"-d", "src", "--max-entries", "1", "-o", "-"}, exitTruncated},
+		{"--mermaid-max-nodes", []string{"-d", "src", "-f", "mermaid", "--mermaid-max-nodes", "2", "-o", "-"}, exitTruncated},
+		{"--strict-security", []string{"-d", "src", "--strict-security", "-o", "-"}, exitFindings},
+		{"--pipe", []string{"-d", "src", "--pipe", "exit 3", "-o", "tree.md"}, exitPipe},
+		{"--inject-dry-run", []string{"-d", "src", "--inject", "README.md", "--inject-dry-run"}, exitMarkers},
+		{"--timeout", []string{"-d", "src", "--timeout", "1ns", "-o", "-"}, exitCancelled},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.want == exitFindings && runtime.GOOS == "windows" {
+				t.Skip("no permission bits to find")
+			}
+			dir := testtree.Dir(t, "src/a/one.txt\nsrc/a/two.txt\nsrc/open.sh mode=0777\nnew/other.txt\nREADME.md content=#\n")
+			stdout, stderr, code := runFTG(t, dir, test.args...)
+			if code != test.want {
+				t.Errorf("exit code %d, want %d:\n%s%s", code, test.want, stdout, stderr)
+			}
+		})
+	}
+}
+
+// --exit-codes prints the table the codes above follow
+func TestExitCodesTable(t *testing.T) {
+	stdout, _, code := runFTG(t, t.TempDir(), "--exit-codes")
+	if code != exitOK {
+		t.Fatalf("exit code %d", code)
+	}
+	for _, row := range exitCodeTable {
+		if !strings.Contains(stdout, row.meaning) {
+			t.Errorf("--exit-codes leaves out %d: %s", row.code, row.meaning)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// explainPath prints every filter decision the walk would make for target, from the
+// top-level ancestor down to the entry itself, followed by the final verdict
+func explainPath(target string) {
+	absRoot, err := filepath.Abs(scan.Root)
+	if err != nil {
+		absRoot = scan.Root
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		absTarget = target
+	}
+	rel, err := filepath.Rel(absRoot, absTarget)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		fmt.Printf("%s: outside the input directory %s, never shown\n", target, scan.Root)
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		fmt.Printf("%s: the input directory itself, always shown as the root\n", target)
+		return
+	}
+
+	fmt.Printf("%s (relative path %s)\n", target, displayPath(rel))
+	if _, err := os.Lstat(absTarget); err != nil {
+		fmt.Println("  note: path does not exist on disk, so it could only appear if created")
+	}
+	if exportIgnore || useGitignore || useDockerignore {
+		var consulted []string
+		if useDockerignore && fileExists(filepath.Join(scan.Root, ".dockerignore")) {
+			consulted = append(consulted, displayPath(".dockerignore"))
+		}
+		parts := strings.Split(rel, "/")
+		for i := 0; i < len(parts); i++ {
+			dir := strings.Join(parts[:i], "/")
+			dirPath := filepath.Join(scan.Root, filepath.FromSlash(dir))
+			if useGitignore {
+				loadGitignore(dirPath)
+				if fileExists(filepath.Join(dirPath, ".gitignore")) {
+					consulted = append(consulted, displayPath(path.Join(dir, ".gitignore")))
+				}
+			}
+			if exportIgnore {
+				loadAttributes(dirPath)
+				if fileExists(filepath.Join(dirPath, ".gitattributes")) {
+					consulted = append(consulted, displayPath(path.Join(dir, ".gitattributes")))
+				}
+			}
+		}
+		if len(consulted) == 0 {
+			consulted = []string{"none found"}
+		}
+		fmt.Printf("  ignore files consulted: %s\n", strings.Join(consulted, ", "))
+	}
+
+	verdict := "shown"
+	parts := strings.Split(rel, "/")
+	for i, name := range parts {
+		current := strings.Join(parts[:i+1], "/")
+		// Join onto inputDirectory like the walk does, so relative paths resolve the same way
+		parent := filepath.Join(scan.Root, filepath.FromSlash(path.Dir(current)))
+		reason := explainStep(parent, current, name)
+		fmt.Printf("  %s: %s\n", displayPath(current), reason)
+		if !strings.HasPrefix(reason, "kept") {
+			if current == rel {
+				verdict = "hidden (" + reason + ")"
+			} else {
+				verdict = "hidden because ancestor " + displayPath(current) + " is not shown"
+			}
+			break
+		}
+	}
+	fmt.Printf("  verdict: %s\n", verdict)
+}
+
+// prepareExplain computes what the walk decides over the whole tree before it
+// renders, --grep-name's selection and --prune's empty directories, so explain
+// reports the same decisions
+func prepareExplain() {
+	if grepName == "" && !pruneEmpty {
+		return
+	}
+	treeFS = rootFS(scan.Root)
+	entries, err := getEntries(context.Background(), scan.Root)
+	if err != nil {
+		errorExit("Cannot read the input directory")
+	}
+	if grepName != "" {
+		keepFilter = grepSelection(scan.Root, entries)
+	}
+	if pruneEmpty {
+		pruneTree(scan.Root, entries)
+	}
+}
+
+// explainStep evaluates the walk's filters for one path component in the order the
+// walk applies them: the parent's --max-depth first, then the filters of
+// visibleEntries, then the exclusion rules
+func explainStep(parent, rel, name string) string {
+	if depth := strings.Count(rel, "/") + 1; scan.MaxDepth > 0 && depth > scan.MaxDepth {
+		return fmt.Sprintf("at depth %d, below --max-depth %d", depth, scan.MaxDepth)
+	}
+	if keepFilter != nil && !isKept(rel) {
+		if grepName != "" {
+			return "no --grep-name match, none within --grep-context lines and not an ancestor of a match"
+		}
+		return "not in the --changed-since set"
+	}
+	if !includeVirtual && isFilesystemRoot(parent) && virtualFilesystems[name] {
+		return "virtual filesystem skipped when scanning / (use --include-virtual)"
+	}
+	fullPath := filepath.Join(parent, name)
+	info, err := os.Lstat(fullPath)
+	isDir := err == nil && info.IsDir()
+	if onlyActive() {
+		if !onlyMatch(rel, isDir) && (!isDir || !containsOnlyMatch(fullPath)) {
+			return "does not match --only or --only-ext and contains no match"
+		}
+	}
+	if sizeFilterActive() && err == nil && info.Mode().IsRegular() && !sizeInRange(info.Size()) {
+		return fmt.Sprintf("size %s is outside --min-size and --max-size", formatSize(info.Size()))
+	}
+	if prunedDirs[fullPath] {
+		return "holds nothing to show, removed by --prune"
+	}
+	if scan.DirsOnly && err == nil && !isDir && !shouldDescend(fullPath, fs.FileInfoToDirEntry(info)) {
+		return "not a directory, hidden by --dirs-only"
+	}
+	if rule, priority, found := decidingRule(rel, name, isDir); found {
+		rank := fmt.Sprintf("priority %d of %d, %s", priority, len(rulesOrder), rulesOrder[priority-1])
+		if rule.include {
+			return fmt.Sprintf("kept: re-included by %s; %s", rule.describe(), rank)
+		}
+		return fmt.Sprintf("excluded by %s; %s", rule.describe(), rank)
+	}
+	if dotfileHidden(rel, name) {
+		return "dotfile hidden by --hidden=hide (keep it with --include)"
+	}
+	return "kept"
+}
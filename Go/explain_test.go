@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// explain names the filter that hides a path, in the order the walk applies them
+func TestExplainFollowsTheWalk(t *testing.T) {
+	dir := testtree.Dir(t, `
+apps/
+apps/web/
+apps/web/main.go
+apps/web/util.go
+docs/
+docs/guide.md
+empty/
+notes.txt
+`)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"apps/web/main.go"}, "verdict: shown"},
+		{[]string{"-L", "1", "apps/web/main.go"}, "apps/web: at depth 2, below --max-depth 1"},
+		{[]string{"-L", "2", "apps/web/main.go"}, "apps/web/main.go: at depth 3, below --max-depth 2"},
+		{[]string{"-L", "3", "apps/web/main.go"}, "verdict: shown"},
+		{[]string{"--dirs-only", "notes.txt"}, "notes.txt: not a directory, hidden by --dirs-only"},
+		{[]string{"--dirs-only", "docs"}, "verdict: shown"},
+		{[]string{"--prune", "empty"}, "empty: holds nothing to show, removed by --prune"},
+		{[]string{"--prune", "docs"}, "verdict: shown"},
+		{[]string{"--grep-name", "util", "apps/web/util.go"}, "verdict: shown"},
+		{[]string{"--grep-name", "util", "docs/guide.md"}, "docs: no --grep-name match"},
+		// --max-depth is decided before the exclusions of the entry it cuts off
+		{[]string{"-L", "1", "-e", "web", "apps/web"}, "apps/web: at depth 2, below --max-depth 1"},
+		{[]string{"-e", "web", "apps/web"}, "apps/web: excluded by"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, append([]string{"explain"}, test.args...)...)
+			if code != 0 {
+				t.Fatalf("exit code %d, stderr %q", code, stderr)
+			}
+			if !strings.Contains(stdout, test.want) {
+				t.Errorf("output does not contain %q:\n%s", test.want, stdout)
+			}
+		})
+	}
+}
+
+// The trace of a path lists the ignore files consulted and the decision at every
+// level down to it, ending in the verdict
+func TestExplainTrace(t *testing.T) {
+	dir := testtree.Dir(t, `
+.gitignore content="build/\n*.log\n!keep.log\n"
+.env
+a.log
+keep.log
+big size=100
+build/out
+src/main.go
+src/gen/.gitignore content="x\n"
+src/gen/x
+`)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-g", "a.log"}, "a.log (relative path a.log)\n" +
+			"  ignore files consulted: .gitignore\n" +
+			"  a.log: excluded by .gitignore:2 (*.log); priority 2 of 4, ignorefiles\n" +
+			"  verdict: hidden (excluded by .gitignore:2 (*.log); priority 2 of 4, ignorefiles)\n"},
+		{[]string{"-g", "keep.log"}, "keep.log (relative path keep.log)\n" +
+			"  ignore files consulted: .gitignore\n" +
+			"  keep.log: kept: re-included by .gitignore:3 (!keep.log); priority 2 of 4, ignorefiles\n" +
+			"  verdict: shown\n"},
+		{[]string{"-g", "build/out"}, "build/out (relative path build/out)\n" +
+			"  ignore files consulted: .gitignore\n" +
+			"  build: excluded by .gitignore:1 (build/); priority 2 of 4, ignorefiles\n" +
+			"  verdict: hidden because ancestor build is not shown\n"},
+		{[]string{"-g", "src/gen/x"}, "src/gen/x (relative path src/gen/x)\n" +
+			"  ignore files consulted: .gitignore, src/gen/.gitignore\n" +
+			"  src: kept\n" +
+			"  src/gen: kept\n" +
+			"  src/gen/x: excluded by src/gen/.gitignore:1 (x); priority 2 of 4, ignorefiles\n" +
+			"  verdict: hidden (excluded by src/gen/.gitignore:1 (x); priority 2 of 4, ignorefiles)\n"},
+		{[]string{"node_modules/y"}, "node_modules/y (relative path node_modules/y)\n" +
+			"  note: path does not exist on disk, so it could only appear if created\n" +
+			"  node_modules: excluded by pattern \"node_modules\" (default); priority 4 of 4, defaults\n" +
+			"  verdict: hidden because ancestor node_modules is not shown\n"},
+		{[]string{"-e", "big", "big"}, "big (relative path big)\n" +
+			"  big: excluded by pattern \"big\" (user (-e)); priority 1 of 4, cli\n" +
+			"  verdict: hidden (excluded by pattern \"big\" (user (-e)); priority 1 of 4, cli)\n"},
+		{[]string{"--max-size", "50", "big"}, "big (relative path big)\n" +
+			"  big: size 100 B is outside --min-size and --max-size\n" +
+			"  verdict: hidden (size 100 B is outside --min-size and --max-size)\n"},
+		{[]string{"--hidden=hide", ".env"}, ".env (relative path .env)\n" +
+			"  .env: dotfile hidden by --hidden=hide (keep it with --include)\n" +
+			"  verdict: hidden (dotfile hidden by --hidden=hide (keep it with --include))\n"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, append([]string{"explain"}, test.args...)...)
+			if code != 0 || stdout != test.want {
+				t.Errorf("exit code %d, stderr %q, got\n%s\nwant\n%s", code, stderr, stdout, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+)
+
+var (
+	appliedProfiles  []string              // --profile names, in the order applied
+	ignoreFileHashes = map[string]string{} // Ignore and pattern files the scan read, by shown path: their digests
+)
+
+// recordIgnoreFile notes an ignore file the scan consulted with the sha256 digest of
+// what was read, so a tree committed next to it shows when the file has changed since
+func recordIgnoreFile(name string, digest hash.Hash) {
+	ignoreFileHashes[name] = "sha256:" + hex.EncodeToString(digest.Sum(nil))
+}
+
+// filterRecord is the effective filter configuration of a scan, the meta.filters of
+// -f json and the filters line of the summary. Every field is always present; limits
+// that are not set are null.
+type filterRecord struct {
+	DefaultExcludes []string           `json:"defaultExcludes"` // Empty with -c
+	UserExcludes    []string           `json:"userExcludes"`    // -e, --exclude-from, -i and --options-from; "!pattern" re-includes
+	IgnoreFiles     []ignoreFileRecord `json:"ignoreFiles"`     // In path order
+	Presets         []string           `json:"presets"`         // --profile names
+	Hidden          string             `json:"hidden"`          // --hidden: show or hide
+	Include         []string           `json:"include"`         // --include
+	Only            []string           `json:"only"`            // --only
+	MaxDepth        *int               `json:"maxDepth"`
+	MinSize         *int64             `json:"minSize"` // Bytes
+	MaxSize         *int64             `json:"maxSize"` // Bytes
+	ChangedSince    *string            `json:"changedSince"`
+}
+
+// ignoreFileRecord is one ignore file a scan consulted
+type ignoreFileRecord struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"` // "sha256:…" of the contents read
+}
+
+// layerPatterns returns the patterns of a rule source in the order given
+func layerPatterns(source string) []string {
+	patterns := []string{}
+	if l, ok := ruleLayers[source]; ok {
+		for _, rule := range l.rules {
+			patterns = append(patterns, rule.label())
+		}
+	}
+	return patterns
+}
+
+// collectFilters gathers the filter configuration the scan ran with
+func collectFilters() filterRecord {
+	record := filterRecord{
+		DefaultExcludes: layerPatterns(sourceDefaults),
+		UserExcludes:    layerPatterns(sourceCLI),
+		IgnoreFiles:     []ignoreFileRecord{},
+		Presets:         append([]string{}, appliedProfiles...),
+		Hidden:          hiddenMode,
+		Include:         append([]string{}, includePatterns...),
+		Only:            append([]string{}, onlyPatterns...),
+	}
+	names := make([]string, 0, len(ignoreFileHashes))
+	for name := range ignoreFileHashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		record.IgnoreFiles = append(record.IgnoreFiles, ignoreFileRecord{redactPath(name), ignoreFileHashes[name]})
+	}
+	if scan.MaxDepth > 0 {
+		record.MaxDepth = &scan.MaxDepth
+	}
+	if minSize >= 0 {
+		record.MinSize = &minSize
+	}
+	if maxSize >= 0 {
+		record.MaxSize = &maxSize
+	}
+	if changedSince != "" {
+		record.ChangedSince = &changedSince
+	}
+	return record
+}
+
+// writeFilters appends the filters line of the summary: what an entry missing from
+// the tree may have been left out by
+func writeFilters(writer io.Writer) {
+	record := collectFilters()
+	parts := []string{msg("filters.noDefaults")}
+	if len(record.DefaultExcludes) > 0 {
+		parts[0] = msg("filters.defaults", strings.Join(record.DefaultExcludes, ", "))
+	}
+	if len(record.UserExcludes) > 0 {
+		parts = append(parts, msg("filters.user", strings.Join(record.UserExcludes, ", ")))
+	}
+	if len(record.IgnoreFiles) > 0 {
+		files := make([]string, len(record.IgnoreFiles))
+		for i, file := range record.IgnoreFiles {
+			// Twelve hex digits tell versions apart; -f json has the whole digest
+			files[i] = fmt.Sprintf("%s (%.19s)", file.Path, file.Hash)
+		}
+		parts = append(parts, msg("filters.ignoreFiles", strings.Join(files, ", ")))
+	}
+	if len(record.Presets) > 0 {
+		parts = append(parts, msg("filters.presets", strings.Join(record.Presets, ", ")))
+	}
+	if record.Hidden == hiddenHide {
+		parts = append(parts, msg("filters.hidden"))
+	}
+	if len(record.Include) > 0 {
+		parts = append(parts, msg("filters.include", strings.Join(record.Include, ", ")))
+	}
+	if len(record.Only) > 0 {
+		parts = append(parts, msg("filters.only", strings.Join(record.Only, ", ")))
+	}
+	if record.MaxDepth != nil {
+		parts = append(parts, msg("filters.depth", *record.MaxDepth))
+	}
+	if record.MinSize != nil {
+		parts = append(parts, msg("filters.minSize", formatSize(*record.MinSize)))
+	}
+	if record.MaxSize != nil {
+		parts = append(parts, msg("filters.maxSize", formatSize(*record.MaxSize)))
+	}
+	if record.ChangedSince != nil {
+		parts = append(parts, msg("filters.changedSince", *record.ChangedSince))
+	}
+	fmt.Fprintf(writer, "%s\n", msg("summary.filters", strings.Join(parts, "; ")))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// filterTree has an ignore file, a profile's output and files on both sides of a size limit
+const filterTree = `
+.gitignore content="*.log\n"
+.github/ci.yml size=10
+app.log size=10
+build/out.bin size=10
+node_modules/x.js size=10
+src/main.go size=100
+src/deep/a.go size=10
+`
+
+// filterFields are the keys of meta.filters; consumers rely on every one being present
+var filterFields = []string{"changedSince", "defaultExcludes", "hidden", "ignoreFiles", "include", "maxDepth", "maxSize", "minSize", "only", "presets", "userExcludes"}
+
+// metaFilters runs ftg -f json on dir and returns meta.filters, raw and decoded
+func metaFilters(t *testing.T, dir string, args ...string) (map[string]json.RawMessage, filterRecord) {
+	t.Helper()
+	stdout, stderr, code := runFTG(t, dir, append([]string{"-d", dir, "-f", "json", "-o", "-"}, args...)...)
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	var root struct {
+		Meta struct {
+			Filters json.RawMessage `json:"filters"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &root); err != nil {
+		t.Fatalf("%v in\n%s", err, stdout)
+	}
+	var raw map[string]json.RawMessage
+	var record filterRecord
+	if err := json.Unmarshal(root.Meta.Filters, &raw); err != nil {
+		t.Fatalf("meta.filters: %v in\n%s", err, stdout)
+	}
+	if err := json.Unmarshal(root.Meta.Filters, &record); err != nil {
+		t.Fatal(err)
+	}
+	return raw, record
+}
+
+// The field names of meta.filters are locked, and every field is there whether or not
+// its filter is set, even with --no-summary
+func TestJSONFilterSchema(t *testing.T) {
+	dir := testtree.Dir(t, filterTree)
+	for _, args := range [][]string{nil, {"--no-summary", "-g", "-e", "build", "--only", "src", "-L", "2", "--min-size", "5", "--max-size", "1K"}} {
+		raw, _ := metaFilters(t, dir, args...)
+		keys := slices.Sorted(maps.Keys(raw))
+		if !slices.Equal(keys, filterFields) {
+			t.Errorf("%q: meta.filters has %q, want %q", args, keys, filterFields)
+		}
+	}
+	raw, _ := metaFilters(t, dir)
+	for _, limit := range []string{"maxDepth", "minSize", "maxSize", "changedSince"} {
+		if string(raw[limit]) != "null" {
+			t.Errorf("%s = %s without its flag, want null", limit, raw[limit])
+		}
+	}
+	for _, list := range []string{"userExcludes", "ignoreFiles", "presets", "include", "only"} {
+		if string(raw[list]) != "[]" {
+			t.Errorf("%s = %s without its flag, want []", list, raw[list])
+		}
+	}
+}
+
+// meta.filters records the configuration of the run, with a digest of each ignore
+// file as read
+func TestJSONFilterValues(t *testing.T) {
+	dir := testtree.Dir(t, filterTree)
+	_, got := metaFilters(t, dir, "-g", "-e", "build,!keep", "--profile", "node", "--hidden", "hide", "--include", ".github",
+		"-L", "2", "--min-size", "5", "--max-size", "1K")
+	digest := sha256.Sum256([]byte("*.log\n"))
+	want := filterRecord{
+		UserExcludes: []string{"build", "!keep"},
+		IgnoreFiles:  []ignoreFileRecord{{".gitignore", "sha256:" + hex.EncodeToString(digest[:])}},
+		Presets:      []string{"node"},
+		Hidden:       hiddenHide,
+		Include:      []string{".github"},
+	}
+	if !slices.Equal(got.UserExcludes, want.UserExcludes) || !slices.Equal(got.IgnoreFiles, want.IgnoreFiles) ||
+		!slices.Equal(got.Presets, want.Presets) || got.Hidden != want.Hidden || !slices.Equal(got.Include, want.Include) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if !slices.Contains(got.DefaultExcludes, "node_modules") {
+		t.Errorf("defaultExcludes = %q, want the defaults", got.DefaultExcludes)
+	}
+	if got.MaxDepth == nil || *got.MaxDepth != 2 || got.MinSize == nil || *got.MinSize != 5 || got.MaxSize == nil || *got.MaxSize != 1024 {
+		t.Errorf("limits %v %v %v, want 2, 5 and 1024", got.MaxDepth, got.MinSize, got.MaxSize)
+	}
+	if _, complete := metaFilters(t, dir, "-c"); len(complete.DefaultExcludes) != 0 {
+		t.Errorf("-c: defaultExcludes = %q, want none", complete.DefaultExcludes)
+	}
+}
+
+// The md summary carries the same configuration on one line, which --no-summary leaves out
+func TestMarkdownFilterLine(t *testing.T) {
+	dir := testtree.Dir(t, filterTree)
+	stdout, stderr, code := runFTG(t, dir, "-d", dir, "-o", "-", "-g", "-e", "build", "--profile", "node", "-L", "1")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	want := "\nFilters: default excludes node_modules, .next, .vscode, .idea, .git, target, Cargo.lock; user excludes build; " +
+		"ignore files .gitignore (sha256:318d9a165337); profiles node; depth at most 1\n"
+	if !strings.Contains(stdout, want) {
+		t.Errorf("got\n%s\nwant the line %q", stdout, want)
+	}
+	if stdout, _, _ := runFTG(t, dir, "-d", dir, "-o", "-", "--no-summary"); strings.Contains(stdout, "Filters:") {
+		t.Errorf("--no-summary still has the filters line:\n%s", stdout)
+	}
+}
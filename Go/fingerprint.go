@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var skipUnchanged bool // Leave output files untouched when their content fingerprint matches
+
+// fingerprintLine matches an embedded fingerprint in each of its forms: the comment
+// ending markdown, HTML and SVG, the trailer of -f text and the first field of the
+// JSON formats at any --json-indent
+var fingerprintLine = regexp.MustCompile(`(?m)^(?:<!-- ftg:fingerprint |ftg:fingerprint | +"fingerprint": ")(sha256:[0-9a-f]{64})(?: -->|",)?\r?\n?`)
+
+// volatilePrefixes mark lines that change on every run without the tree changing,
+// in the language of --lang
+func volatilePrefixes() [][]byte {
+	level := strings.Repeat(" ", jsonIndent)
+	return [][]byte{
+		[]byte("- " + msg("provenance.started") + ": "), []byte("- " + msg("provenance.finished") + ": "),
+		[]byte(level + `"generated": `),                                               // The time stamp of -f manifest
+		[]byte(level + level + `"started": `), []byte(level + level + `"finished": `), // -f json --provenance
+	}
+}
+
+// contentFingerprint hashes rendered output, skipping timestamp lines and any embedded
+// fingerprint. CRLF counts as LF, so --crlf leaves the fingerprint as it is.
+func contentFingerprint(data []byte) string {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	h := sha256.New()
+	prefixes := volatilePrefixes()
+	for _, line := range bytes.SplitAfter(fingerprintLine.ReplaceAll(data, nil), []byte("\n")) {
+		volatile := false
+		for _, prefix := range prefixes {
+			volatile = volatile || bytes.HasPrefix(line, prefix)
+		}
+		if !volatile {
+			h.Write(line)
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprinted returns the output with the fingerprint --skip-unchanged compares; piped
+// output and an injected section carry none
+func fingerprinted(data []byte, bare bool) []byte {
+	if bare || injectFile != "" {
+		return data
+	}
+	return embedFingerprint(outputFormat, data)
+}
+
+// embedFingerprint adds the fingerprint of data in the form the format allows. CSV,
+// TSV and paths have no place for one that readers would skip, so they are left as
+// they are.
+func embedFingerprint(format string, data []byte) []byte {
+	switch format {
+	case formatCSV, formatTSV, formatPaths:
+		return data
+	case formatJSON, formatManifest:
+		if !bytes.HasPrefix(data, []byte("{\n")) {
+			return data
+		}
+		return append([]byte(fmt.Sprintf("{\n%s\"fingerprint\": %q,\n", strings.Repeat(" ", jsonIndent), contentFingerprint(data))), data[2:]...)
+	}
+	// The blank line before the fingerprint is part of what it covers
+	data = append(data, '\n')
+	if format == formatText {
+		return append(data, fmt.Sprintf("ftg:fingerprint %s\n", contentFingerprint(data))...)
+	}
+	return append(data, fmt.Sprintf("<!-- ftg:fingerprint %s -->\n", contentFingerprint(data))...)
+}
+
+// recordedFingerprint returns the fingerprint embedded in output, if any
+func recordedFingerprint(output []byte) (string, bool) {
+	recorded := fingerprintLine.FindSubmatch(output)
+	if recorded == nil {
+		return "", false
+	}
+	return string(recorded[1]), true
+}
+
+// hasFingerprint reports whether file holds ftg output with an embedded fingerprint
+func hasFingerprint(file string) bool {
+	existing, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	_, ok := recordedFingerprint(existing)
+	return ok
+}
+
+// unchangedOnDisk reports whether file already holds output with the same fingerprint.
+// The existing file must still match its own recorded fingerprint, so a file edited
+// by hand, or with a damaged fingerprint, is rewritten.
+func unchangedOnDisk(file string, data []byte) bool {
+	existing, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	fingerprint, ok := recordedFingerprint(existing)
+	if !ok {
+		return false
+	}
+	return fingerprint == contentFingerprint(existing) && fingerprint == contentFingerprint(data)
+}
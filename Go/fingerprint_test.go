@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestEmbeddedFingerprintRoundTrip(t *testing.T) {
+	outputs := map[string]string{
+		formatMarkdown: "# File Tree for x\n\n```sh\n└── [F] a\n```\n",
+		formatHTML:     "<!DOCTYPE html>\n<html><body></body></html>\n",
+		formatText:     ".\n└── a\n\n0 directories, 1 file\n",
+		formatJSON:     "{\n  \"name\": \"x\",\n  \"type\": \"dir\"\n}\n",
+		formatManifest: "{\n  \"schemaVersion\": 1,\n  \"generated\": \"2026-01-01T00:00:00Z\"\n}\n",
+	}
+	for format, data := range outputs {
+		t.Run(format, func(t *testing.T) {
+			embedded := embedFingerprint(format, []byte(data))
+			recorded, ok := recordedFingerprint(embedded)
+			if !ok {
+				t.Fatalf("no fingerprint found in\n%s", embedded)
+			}
+			if recorded != contentFingerprint(embedded) {
+				t.Errorf("recorded %s, but the output hashes to %s", recorded, contentFingerprint(embedded))
+			}
+			if crlf := toCRLF(embedded); contentFingerprint(crlf) != recorded {
+				t.Error("CRLF line ends change the fingerprint")
+			}
+			if !bytes.HasPrefix(embedded, []byte(data[:2])) || !strings.Contains(string(embedded), strings.TrimPrefix(data, "{\n")) {
+				t.Errorf("embedding changed the output:\n%s", embedded)
+			}
+		})
+	}
+	if got := embedFingerprint(formatCSV, []byte("path,type\n")); string(got) != "path,type\n" {
+		t.Errorf("CSV got a fingerprint: %q", got)
+	}
+}
+
+// The manifest's time stamp changes on every run without the tree changing
+func TestManifestTimestampIsVolatile(t *testing.T) {
+	a := "{\n  \"schemaVersion\": 1,\n  \"generated\": \"2026-01-01T00:00:00Z\"\n}\n"
+	b := strings.Replace(a, "2026-01-01", "2027-06-30", 1)
+	if contentFingerprint([]byte(a)) != contentFingerprint([]byte(b)) {
+		t.Error("the generated time changes the manifest fingerprint")
+	}
+}
+
+// stableLines is data without the lines volatilePrefixes mark, such as the manifest's
+// time stamp, so two runs over the same tree compare equal
+func stableLines(data []byte) []byte {
+	var stable []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if !slices.ContainsFunc(volatilePrefixes(), func(prefix []byte) bool { return bytes.HasPrefix(line, prefix) }) {
+			stable = append(stable, line...)
+		}
+	}
+	return stable
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	for _, format := range []string{formatMarkdown, formatText, formatJSON, formatManifest, formatHTML, formatSVG} {
+		t.Run(format, func(t *testing.T) {
+			dir := testtree.Dir(t, "tree/a.txt content=one\ntree/b/c.txt\n")
+			out := filepath.Join(dir, "out."+formats[format].extensions[0])
+			run := func(args ...string) (string, int) {
+				t.Helper()
+				// Status messages go to stdout when the tree goes to a file
+				stdout, stderr, code := runFTG(t, dir, append([]string{"-d", "tree", "-f", format, "-o", out}, args...)...)
+				return stdout + stderr, code
+			}
+			if stderr, code := run(); code != exitOK {
+				t.Fatalf("first run: exit code %d: %s", code, stderr)
+			}
+			old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			os.Chtimes(out, old, old)
+
+			// Unchanged: the file is not touched
+			stderr, code := run("--skip-unchanged")
+			if code != exitOK || !strings.Contains(stderr, "unchanged") {
+				t.Fatalf("unchanged run: exit code %d: %s", code, stderr)
+			}
+			if info, _ := os.Stat(out); !info.ModTime().Equal(old) {
+				t.Errorf("unchanged output was rewritten (mtime %v)", info.ModTime())
+			}
+
+			// Changed: replaced without --force
+			os.WriteFile(filepath.Join(dir, "tree", "new.txt"), nil, 0o644)
+			stderr, code = run("--skip-unchanged")
+			if code != exitOK || !strings.Contains(stderr, "written") {
+				t.Fatalf("changed run: exit code %d: %s", code, stderr)
+			}
+			data, _ := os.ReadFile(out)
+			if !strings.Contains(string(data), "new.txt") {
+				t.Errorf("changed output was not rewritten:\n%s", data)
+			}
+
+			// Corrupted: content edited under its fingerprint, or the fingerprint damaged
+			for _, corrupt := range []func([]byte) []byte{
+				func(data []byte) []byte { return bytes.Replace(data, []byte("new.txt"), []byte("old.txt"), 1) },
+				func(data []byte) []byte { return bytes.Replace(data, []byte("sha256:"), []byte("sha256:0"), 1) },
+			} {
+				os.WriteFile(out, corrupt(data), 0o644)
+				stderr, code = run("--skip-unchanged")
+				if code != exitOK {
+					t.Fatalf("corrupted run: exit code %d: %s", code, stderr)
+				}
+				if again, _ := os.ReadFile(out); !bytes.Equal(stableLines(again), stableLines(data)) {
+					t.Errorf("corrupted output was not replaced:\n%s", again)
+				}
+			}
+		})
+	}
+}
+
+func TestSkipUnchangedKeepsForeignFiles(t *testing.T) {
+	dir := testtree.Dir(t, "tree/a.txt\nnotes.md content=\"my own notes\\n\"\n")
+	stdout, stderr, code := runFTG(t, dir, "-d", "tree", "-o", "notes.md", "--skip-unchanged")
+	if code == exitOK || !strings.Contains(stderr, "already exists") {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if strings.Contains(stdout, "Generating") {
+		t.Errorf("the refusal came after the walk started:\n%s", stdout)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.md")); string(data) != "my own notes\n" {
+		t.Errorf("notes.md was changed to %q", data)
+	}
+}
+
+func TestSkipUnchangedNeedsAFingerprint(t *testing.T) {
+	for _, format := range []string{formatCSV, formatTSV} {
+		_, stderr, code := runFTG(t, t.TempDir(), "-f", format, "-o", "out", "--skip-unchanged")
+		if code != exitUsage || !strings.Contains(stderr, "fingerprint") {
+			t.Errorf("-f %s: exit code %d: %s", format, code, stderr)
+		}
+	}
+}
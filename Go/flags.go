@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Alternative flag spellings share the Value of the flag they stand for, so value
+// flags, booleans and repeatable flags like -o behave the same under every name and
+// repeated uses of different spellings accumulate. Deprecated spellings keep working
+// and print a notice once per run naming the replacement; --strict-flags makes them
+// usage errors and FTG_SUPPRESS_DEPRECATIONS=1 silences the notices.
+var (
+	strictFlags     bool                  // --strict-flags: deprecated spellings are usage errors
+	flagAliases     = map[string]string{} // Flag name per alternative spelling
+	deprecatedFlags = map[string]bool{}   // Alternative spellings that are deprecated
+)
+
+// aliasFlag registers alias as another spelling of the flag name already registered on set
+func aliasFlag(set *flag.FlagSet, alias, name string) {
+	target := set.Lookup(name)
+	if target == nil {
+		panic("aliasFlag: no flag " + name)
+	}
+	set.Var(target.Value, alias, target.Usage)
+	flagAliases[alias] = name
+}
+
+// deprecatedFlag registers old as a spelling of name that still works but is reported
+func deprecatedFlag(set *flag.FlagSet, old, name string) {
+	aliasFlag(set, old, name)
+	deprecatedFlags[old] = true
+}
+
+// canonicalFlag returns the flag an alias stands for, or name itself
+func canonicalFlag(name string) string {
+	if target, ok := flagAliases[name]; ok {
+		return target
+	}
+	return name
+}
+
+// flagName formats a flag name the way the usage text writes it
+func flagName(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// checkDeprecatedFlags reports the deprecated spellings used on the command line or in an options document
+func checkDeprecatedFlags() {
+	flag.Visit(func(f *flag.Flag) {
+		if !deprecatedFlags[f.Name] {
+			return
+		}
+		notice := fmt.Sprintf("%s is deprecated; use %s instead", flagName(f.Name), flagName(flagAliases[f.Name]))
+		if strictFlags {
+			usageExit(notice + " (--strict-flags)")
+		}
+		if os.Getenv("FTG_SUPPRESS_DEPRECATIONS") == "" {
+			log.Printf("Warning: %s", notice)
+		}
+	})
+}
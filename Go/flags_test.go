@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// parseArgs registers the flags on a fresh set and parses args, restoring the package
+// variables the tests touch once the test ends
+func parseArgs(t *testing.T, args ...string) (*flag.FlagSet, *cliFlags, error) {
+	t.Helper()
+	savedFormat, savedOutputs, savedNoDefaults := outputFormat, outputLocations, scan.NoDefaultExcludes
+	savedMaxDepth, savedSizes := scan.MaxDepth, showSizes
+	t.Cleanup(func() {
+		outputFormat, outputLocations, scan.NoDefaultExcludes = savedFormat, savedOutputs, savedNoDefaults
+		scan.MaxDepth, showSizes = savedMaxDepth, savedSizes
+	})
+	outputLocations = nil
+	set := flag.NewFlagSet("ftg", flag.ContinueOnError)
+	set.SetOutput(io.Discard)
+	cli := defineFlags(set)
+	return set, cli, set.Parse(args)
+}
+
+func TestShortAndLongSpellingsShareValues(t *testing.T) {
+	tests := []struct {
+		args  []string
+		check func(*cliFlags) bool
+	}{
+		{[]string{"-e", "dist"}, func(cli *cliFlags) bool { return cli.exclude == "dist" }},
+		{[]string{"--exclude", "dist"}, func(cli *cliFlags) bool { return cli.exclude == "dist" }},
+		{[]string{"-f", "json"}, func(*cliFlags) bool { return outputFormat == "json" }},
+		{[]string{"--format=json"}, func(*cliFlags) bool { return outputFormat == "json" }},
+		{[]string{"-c"}, func(*cliFlags) bool { return scan.NoDefaultExcludes }},
+		{[]string{"--no-default-excludes"}, func(*cliFlags) bool { return scan.NoDefaultExcludes }},
+		{[]string{"-L", "2"}, func(*cliFlags) bool { return scan.MaxDepth == 2 }},
+		{[]string{"--max-depth", "2"}, func(*cliFlags) bool { return scan.MaxDepth == 2 }},
+		{[]string{"-s"}, func(*cliFlags) bool { return showSizes }},
+		{[]string{"-h"}, func(cli *cliFlags) bool { return cli.help }},
+		{[]string{"--help"}, func(cli *cliFlags) bool { return cli.help }},
+		{[]string{"--version"}, func(cli *cliFlags) bool { return cli.versionFlag }},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			_, cli, err := parseArgs(t, test.args...)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if !test.check(cli) {
+				t.Errorf("%v did not set the option", test.args)
+			}
+		})
+	}
+}
+
+func TestRepeatedOutputSpellingsAccumulate(t *testing.T) {
+	_, _, err := parseArgs(t, "-o", "a.md", "--output", "b.md", "-o", "-")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if want := []string{"a.md", "b.md", "-"}; !slices.Equal(outputLocations, want) {
+		t.Errorf("outputLocations = %q, want %q", outputLocations, want)
+	}
+}
+
+func TestDeprecatedSpellingStillParses(t *testing.T) {
+	set, _, err := parseArgs(t, "--clear")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !scan.NoDefaultExcludes {
+		t.Error("--clear did not set --no-default-excludes")
+	}
+	if !deprecatedFlags["clear"] || canonicalFlag("clear") != "no-default-excludes" {
+		t.Errorf("--clear is not recorded as deprecated for --no-default-excludes")
+	}
+	if set.Lookup("clear").Value != set.Lookup("no-default-excludes").Value {
+		t.Error("--clear does not share the value of --no-default-excludes")
+	}
+}
+
+func TestUnknownFlagShowsHelp(t *testing.T) {
+	set := flag.NewFlagSet("ftg", flag.ContinueOnError)
+	defineFlags(set)
+	var stderr bytes.Buffer
+	set.SetOutput(&stderr)
+	set.Usage = func() { writeHelp(set.Output()) }
+	err := set.Parse([]string{"--bogus"})
+	if err == nil || !strings.Contains(err.Error(), "bogus") {
+		t.Fatalf("parse error = %v, want one naming --bogus", err)
+	}
+	if !strings.Contains(stderr.String(), "Usage: ftg") || !strings.Contains(stderr.String(), "--exclude-from") {
+		t.Errorf("stderr does not hold the help text:\n%s", stderr.String())
+	}
+}
+
+func TestMissingFlagValue(t *testing.T) {
+	if _, _, err := parseArgs(t, "-o"); err == nil {
+		t.Error("-o without a value parsed")
+	}
+	if _, _, err := parseArgs(t, "--max-depth", "deep"); err == nil {
+		t.Error("--max-depth deep parsed")
+	}
+}
+
+// Every spelling the help text advertises must be one the parser accepts
+func TestHelpSpellingsAreRegistered(t *testing.T) {
+	set := flag.NewFlagSet("ftg", flag.ContinueOnError)
+	defineFlags(set)
+	var help bytes.Buffer
+	writeHelp(&help)
+	options := help.String()[strings.Index(help.String(), "Options:"):]
+	for _, line := range strings.Split(options, "\n") {
+		// Only the option column names flags; descriptions refer to others in passing
+		column := regexp.MustCompile(`^  (-[-a-zA-Z0-9, ]+?)(\s{2,}|$)`).FindStringSubmatch(line)
+		if column == nil {
+			continue
+		}
+		for _, spelling := range regexp.MustCompile(`--?[a-zA-Z][-a-zA-Z0-9]*`).FindAllString(column[1], -1) {
+			if set.Lookup(strings.TrimLeft(spelling, "-")) == nil {
+				t.Errorf("help lists %s, which does not parse", spelling)
+			}
+		}
+	}
+}
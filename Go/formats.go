@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var noExtCheck bool // --no-ext-check: write -o files of any extension without a warning
+
+// formatInfo is how the output of a format is named and served
+type formatInfo struct {
+	extensions  []string // Of its files, the first for the default output path, without the dot
+	contentType string   // Sent with --post-url
+}
+
+// formats has an entry for every -f format but html-site and md-site, which write a directory
+var formats = map[string]formatInfo{
+	formatMarkdown:     {[]string{"md", "markdown"}, "text/markdown; charset=utf-8"},
+	formatMarkdownList: {[]string{"md", "markdown"}, "text/markdown; charset=utf-8"},
+	formatMermaid:      {[]string{"md", "markdown", "mmd"}, "text/markdown; charset=utf-8"},
+	formatText:         {[]string{"txt", "text"}, "text/plain; charset=utf-8"},
+	formatHTML:         {[]string{"html", "htm"}, "text/html; charset=utf-8"},
+	formatJSON:         {[]string{"json"}, "application/json"},
+	formatManifest:     {[]string{"json"}, "application/json"},
+	formatSVG:          {[]string{"svg"}, "image/svg+xml"},
+	formatCSV:          {[]string{"csv"}, "text/csv; charset=utf-8"},
+	formatTSV:          {[]string{"tsv", "tab"}, "text/tab-separated-values; charset=utf-8"},
+	formatPaths:        {[]string{"txt", "paths"}, "text/plain; charset=utf-8"},
+}
+
+// checkOutputExtensions warns about -o files whose extension is not one of the
+// format's, such as -f json -o tree.md. A name without an extension is taken as meant.
+func checkOutputExtensions(format string, locations []string) {
+	info, ok := formats[format]
+	if !ok || noExtCheck {
+		return
+	}
+	for _, location := range locations {
+		if location == stdoutTarget || location == clipboardTarget {
+			continue
+		}
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(location)), ".")
+		if ext != "" && !slices.Contains(info.extensions, ext) {
+			warnf("%s gets -f %s output, which is usually named .%s; --no-ext-check keeps quiet about it", location, format, info.extensions[0])
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import "syscall"
+
+// filesystemMagic maps statfs magic numbers to filesystem names
+var filesystemMagic = map[uint32]string{
+	0xEF53:     "ext4",
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x794C7630: "overlay",
+	0x6969:     "nfs",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x65735546: "fuse",
+	0x4D44:     "vfat",
+	0x2011BAB0: "exfat",
+	0x5346544E: "ntfs",
+	0x9FA0:     "proc",
+	0x62656572: "sysfs",
+	0x9660:     "iso9660",
+	0x73717368: "squashfs",
+}
+
+// filesystemType returns the name of the filesystem holding path
+func filesystemType(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "unknown"
+	}
+	if name, ok := filesystemMagic[uint32(st.Type)]; ok {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// filesystemType returns the name of the filesystem holding path
+func filesystemType(path string) string {
+	return "unknown"
+}
@@ -4,6 +4,9 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -11,19 +14,24 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
 )
 
 // Global variables
 var (
-	excludePatterns = map[string]bool{}  // Stores patterns of files/directories to exclude
-	outputLocation  string               // Path where the output file will be written
-	inputDirectory  string               // Root directory for tree generation
-	version         = "1.0.1"            // Current version of the application
+	outputLocations stringList // Paths where the output file will be written
+	excludeFrom     stringList // Files of exclusion patterns, one per line, read in order after -e
+	version         = "1.0.1"  // Current version of the application
 	author          = "https://github.com/easttexaselectronics"
 	repository      = "https://github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/tree/main/Go"
 	donation        = "https://github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/tree/main/Go"
+	showBirthTime   bool                 // Annotate entries with their creation time
+	timeLayout      = "2006-01-02 15:04" // Layout used for timestamp annotations
 )
 
 // init initializes the logger to not print timestamps
@@ -31,52 +39,393 @@ func init() {
 	log.SetFlags(0)
 }
 
-// showUsage prints the usage information for the application
+// showUsage prints the usage information for -h and exits successfully, as the
+// help was asked for
 func showUsage() {
-	fmt.Println(`Usage: ftg [-e pattern1,pattern2,...] [-o output_location] [-d input_directory] [-i] [-c] [-h] [-v]
+	writeHelp(os.Stdout)
+	exitProcess(exitOK)
+}
+
+// writeHelp writes the usage information for the application
+func writeHelp(writer io.Writer) {
+	fmt.Fprintln(writer, `Usage: ftg [-e pattern1,pattern2,...] [-o output_location]... [-d input_directory] [-i] [-c] [--changed-since ref] [-h] [-v]
+       ftg explain [options] path...   Show why each path is or isn't in the tree
+       ftg estimate [options]          Sample the tree for a few seconds and estimate its size, scan time and output size
+       ftg conform [options] layout.yaml   Check the tree against required, forbidden and glob rules (-f json for CI)
+       ftg diff [options] old new      Show entries added (+), removed (-) or changed in type (~) between two trees
+       ftg diff -f unified old.txt new.txt   Unified diff of two -f paths snapshots, where an entry that changed
+                                       type or moved is one ! line: "!build/ (was a file)", "!lib/a.go (moved from a.go)"
+       ftg merge [-f md|json] [-o file] host1.json host2.json...   Union -f json snapshots of several hosts, marking
+                                       each entry [3/3], [only: host2] or [2/3: host1, host3] and differing sizes
+       ftg test-pattern [--exclude-from file]... pattern... -- path...   Show which rule, if any, excludes or keeps each path
+       ftg history report [--json] history.ndjson   Show growth over the runs recorded with --history
+       ftg usage-report [--json] [usage.log]   Show which flags the runs recorded with --usage-log used
+       ftg daemon [options]            Keep the tree in memory and render it on request over --socket
+       ftg daemon-client render|refresh|stats|shutdown [--socket path] [--format f] [--depth N] [--only patterns]
+       ftg init                        Answer a few questions to write a .ftg.toml and generate the first tree
+       ftg check-update [--json]       Check GitHub for a newer release (set FTG_NO_UPDATE_CHECK=1 to disable)
 Options:
   -e, --exclude      Exclude directories or files (comma-separated)(.git,node_modules,.vscode)
-  -o, --output       Specify an output location; default output is in the pwd
-  -d, --directory    Specify an input directory; default is the pwd
+  --exclude-from     Read more patterns from a file, one per line; blank lines and # comments are skipped
+                     (repeatable, applied in order after -e)
+                     Names match at any depth; patterns with / match the relative path, ** spans directories
+  --hidden           show (default) or hide entries whose names start with a dot; hidden directories
+                     are not descended into, and -e "!pattern" or --include keeps one
+  --include          Dotfiles to keep with --hidden=hide (same syntax as -e, e.g. .github,.env.example)
+  --profile          Exclude the generated and vendored content of these kinds of project, e.g. node,go
+                     (comma-separated and repeatable; the presets source of --rules-order, so -e and
+                     --include still win)
+  --define-profile   Define or replace a profile as name=pattern,pattern (repeatable; config files can
+                     use an array: define-profile = ["web=dist,.cache"])
+  --list-profiles    Show the profiles and their patterns and exit
+  --rules-order      Precedence of rule sources, highest first (default cli,ignorefiles,presets,defaults);
+                     within a source the last matching rule wins, and "!pattern" in -e re-includes
+  --simulate-retention Report what a cleanup policy like 'delete if older than 180d and size > 100MB'
+                     would remove, without deleting anything (repeatable to compare policies)
+  --security-report  Append the risky permissions in the tree: world-writable entries, setuid/setgid files,
+                     ACLs granting access beyond owner and group (Linux), symlinks leaving the root (md only)
+  --strict-security  Like --security-report, and exit with code 6 on high or medium findings
+  --preserve-annotations Keep the "# comment" text added by hand at the end of tree lines in the existing
+                     -o file when it is regenerated; comments of entries that are gone are listed under
+                     "Orphaned annotations" (md only)
+  --anomalies        Mark files that stand out from their siblings: sizes far above them, modification
+                     times far from them, a rare extension among near-uniform ones; lists the top
+                     findings (md only)
+  --owner-boundaries Mark directories owned by someone other than their parent's owner, e.g.
+                     (owner changes: alice -> bob), and list them all after the tree (md only)
+  --owner-boundary-depth Ignore boundaries deeper than this level (default 0, every level)
+  --anomaly-thresholds Rules of --anomalies (implies it), default size=3,age=3,ext=0.95,siblings=5:
+                     standard deviations of log size and of age, the share the common extension
+                     needs, and the fewest other files a directory needs before it is judged
+  --explain-excludes Append a table of the exclusion rules in evaluation order with their hits (md and json)
+  --lint-filters     Warn after the walk about -e rules that repeat another rule (also a default or a root
+                     .gitignore line), match nothing the walk reached, or only match what other rules decide
+  --only             Only show paths matching these patterns (same syntax as -e) and their ancestors
+  --only-ext         Only show files with these extensions (go,md,proto; case-insensitive, "go" or ".go")
+                     and the directories leading to them; directories without any are left out
+  -o, --output       Specify an output location; default output is in the pwd (repeatable, "clipboard" copies it, "-" writes to stdout)
+  --stdout           Write the bare tree to stdout (same as -o -); messages go to stderr
+  --crlf             End lines with CRLF: auto (default; md, md-list, mermaid and text on Windows),
+                     always (also a bare --crlf) or never. --inject keeps the endings of its file
+  -f, --format       Output format: md (default), text (plain, like tree), html (one page, collapsible directories), json (nested name/type/children), html-site (one linked page per directory, needs --output-dir), svg,
+                     mermaid (markdown with a Mermaid diagram that GitHub renders), manifest (flat JSON list of
+                     every file with size, sha256, sniffed MIME type and executable bit, for compliance tooling),
+                     md-list (markdown nested list with bold directories instead of a code block),
+                     md-site (html-site as Markdown: one page per directory, each with linked breadcrumbs),
+                     csv and tsv (one row per entry: path,type,depth,size,mtime, for spreadsheets),
+                     paths (one path per line, directories ending in /, for diff and ftg diff -f unified)
+  --manifest-allow-partial Write -f manifest even when files cannot be read, with a null sha256;
+                     without it an unreadable file fails the run
+  --manifest-schema  Print the JSON Schema of -f manifest and exit
+  --mermaid-direction TD (default, graph TD) or LR (flowchart LR) for -f mermaid
+  --mermaid-max-nodes Stop the -f mermaid diagram at this many nodes, with a warning and exit code 5 (default 2000)
+  --link-base        Turn each -f md-list entry into a link to this URL plus its escaped path, e.g.
+                     https://github.com/owner/repo/blob/main (GitHub redirects blob/ to tree/ for directories)
+  --color            Color the tree like tree -C: auto (default; only when standard output is a terminal
+                     and NO_COLOR is unset), always or never. Only output that goes nowhere but standard
+                     output is colored, so files, the clipboard and --pipe never get escape codes (md, text)
+  --no-wrap          On a terminal, cut annotations that do not fit the width with … instead of wrapping
+  --ambiguous-wide   Count East Asian Ambiguous characters (box drawing, ±, §, Greek, Cyrillic, …) two columns
+                     wide, as terminals set up for CJK text draw them: the space prefix is widened to match
+                     the guides, and --entry-format padding and wrapping follow
+                     them onto indented lines; names and tree lines are never broken, files never wrapped
+  --icons            Put a glyph before each name by its extension: Nerd Font glyphs (bare --icons or
+                     --icons=nerd, needs a patched font) or --icons=emoji (📁, 📄, 🐹, ...); md, md-list, text
+                     and svg lines get it, -f json an "icon" field
+  --icon-map         Replace glyphs of --icons: ext=glyph pairs, comma-separated and repeatable, e.g.
+                     go=🐹,tar.gz=📦; dir and file set the directory and fallback glyphs (config: icon-map)
+  --trailing-slash   End directory names with / in -f text
+  --entry-format     What follows each connector in md and text, as a Go text/template: fields .Name,
+                     .Label (the name with its notes), .Type, .IsDir, .Size (empty for directories),
+                     .Bytes, .RelPath, .ModTime and .Icon, functions rpad and lpad, e.g.
+                     '{{rpad 30 .Name}} {{.Size}}'; or a preset: default, compact or detailed
+  --svg-font-size    Font size of -f svg in pixels (default 14)
+  --svg-theme        Colors of -f svg: light (default) or dark
+  --svg-glyphs       Draw folder and file shapes in -f svg instead of [D]/[F]
+  --lang             Language of the markdown report and messages: en (default), de, fr, es, ja;
+                     file names, JSON, html-site and svg output stay as they are
+  --check-links md   Flag markdown files whose relative links point at paths missing from the tree
+                     and list the missing targets (URLs and #anchors are not checked)
+  --overview-depth   Put an overview this many levels deep, with linked directories and their counts,
+                     above the full tree (md only)
+  --output-dir       Directory for -f html-site (index.html, style.css and pages/) and -f md-site (index.md and pages/)
+  --site-depth       Directories up to this depth get their own html-site or md-site page; deeper ones are inlined (default 2)
+  --emit-sync-script With ftg diff, print a sh or powershell script of mkdir and copy steps that turns a copy
+                     of the old tree into the new layout, copying files from the new tree (exit code 0)
+  --include-deletes  Let the --emit-sync-script script also delete what the new tree no longer has
+  --estimate-budget  Time ftg estimate spends sampling before it extrapolates (default 5s)
+  --socket           Unix socket of ftg daemon and ftg daemon-client (default ftg.sock in
+                     $XDG_RUNTIME_DIR, else in a directory of your own, ftg-<uid>, in the temp directory);
+                     only its owner can connect
+  --refresh          Rebuild the ftg daemon snapshot this often, e.g. 10m (default only on a refresh request)
+  --no-summary       Leave out the totals and filters lines under the tree: directories, files, size and
+                     excluded entries, then the excludes, ignore files (with digests), profiles and limits
+                     applied; -f json keeps the filters in meta.filters
+  --jobs             Directories read concurrently before the tree is rendered (default GOMAXPROCS);
+                     1 reads them one at a time as the tree is rendered; auto starts at 4 and adapts to
+                     read latency and I/O errors (adjustments and the peak show with --report-resources)
+  --jobs-min, --jobs-max Bounds of --jobs auto (default 1 and 64)
+  --stream-threshold Print directories with more entries than this in batches as they are read, in the
+                     order the filesystem returns them, noted (streamed, unsorted) (default 100000, 0 never)
+  --stream-sort      Keep the usual order in streamed directories with a merge sort through temporary files
+  --self-check       Render twice, the second time with shuffled directory listings and different
+                     --jobs and GOMAXPROCS, and fail with exit code 4 unless both outputs are byte-identical
+  --verify-renderers Render every format, read the json, manifest, md and text outputs back, and fail
+                     with exit code 4 unless all of them list the same entries
+  --history          Append a summary record of each run to this NDJSON log
+  --history-detail   summary (default), or changes to also record the paths added and removed since the last detailed run
+  --usage-log        Append the names of the flags given (never their values or any path), the duration
+                     and the entry counts of each run to ~/.local/state/ftg/usage.log ($XDG_STATE_HOME;
+                     the local app data directory on Windows); nothing is sent anywhere, and the log
+                     moves to usage.log.1 past 1 MB
+  --pipe             Run the output through this shell command, e.g. 'jq .', and write what it prints;
+                     if it fails no output file is replaced and ftg exits with code 7
+  --pipe-timeout     Kill the --pipe command after this long (default 1m, 0 for no limit)
+  --skip-unchanged   Leave output files untouched (mtime included) when the tree has not changed, and
+                     replace earlier ftg output that changed without needing --force; the fingerprint
+                     is a comment in md, html and svg, a trailer line in text and a field in json
+                     (not with csv, tsv, paths, html-site or md-site)
+  --sidecar          Also write each -o file's -f json snapshot to <file>.json, from the same walk
+  --check            Write nothing; compare each -o file, and with --sidecar its .json, with this run's
+                     render and exit with code 4 naming every file that is missing or out of date
+  --force            Replace output files that already exist; without it ftg refuses to overwrite them
+  --json-indent      Spaces per level of -f json, -f manifest and ftg merge -f json (default 2, 1 to 8);
+                     fields keep a fixed order and map keys are sorted, so the same tree gives the same bytes
+  --no-ext-check     Do not warn when the extension of an -o file is not the one -f writes (e.g. -f json -o tree.md)
+  --inject           Replace the section of this file between <!-- ftg:start --> and <!-- ftg:end -->
+                     with the tree, keeping the rest of the file byte for byte (e.g. a README)
+  --inject-markers   Start and end marker for --inject, comma-separated
+  --inject-dry-run   Print what --inject would change as a unified diff and write nothing; exits 0
+                     if the injection would succeed, 8 if the markers are missing or repeated
+  --inject-backup    Save the --inject file as <file>.bak before changing it
+  --copy             Copy the tree to the system clipboard (same as -o clipboard)
+  --post-url         Also upload the tree with an HTTP PUT to this URL (retries transient failures)
+  --post-content-type
+                     Content-Type header for --post-url (default text/markdown; charset=utf-8)
+  --post-auth-env    Environment variable whose value is sent as the Authorization header
+  -d, --directory    Specify an input directory; default is the pwd (--root is another spelling). A .zip, .tar,
+                     .tar.gz or .tgz file is read as an archive. Repeat it, or separate directories with commas,
+                     to render several roots as sections of one output (md and text), in the order given
+  --archive          Show the tree of this zip or tar archive without extracting it, whatever its extension
+  --overlay          Show the tree as a plan of "move: old -> new", "delete: path" and "create: path (dir)"
+                     lines would leave it, without touching the files; changed entries say (moved from old)
+                     or (planned)
+  --root-prefix      Show paths as if this directory were / (for volumes mounted into a container)
+  --relative-to      Show paths and match "/" patterns relative to this directory (relative values start at -d)
+  --root-label       How headers and root lines name the input directory: auto (default; as given, with .
+                     resolved to the absolute path), abs, rel (to the working directory) or none
+  --full-paths       Show each entry as its path from the input directory instead of its name, for
+                     grepping -f text (md, md-list, text; --relative-to applies)
+  --os-paths         Keep the separators of the OS in shown paths; they are / by default on every OS
   -i, --interactive  Interactive mode to select items to exclude
-  -c, --clear        Clear the exclusion list
+  -c, --no-default-excludes
+                     Skip the default exclusions (node_modules, .git, target, ...; on Windows also $RECYCLE.BIN
+                     and System Volume Information); -e patterns still apply
+                     (--clear is a deprecated spelling)
+  --strict-flags     Fail on deprecated flag spellings instead of warning (FTG_SUPPRESS_DEPRECATIONS=1 hides the warnings)
+  --changed-since    Only show files changed since a git ref (plus untracked files)
+  -s, --size         Show each file's size, e.g. (4.2 KB); (?) when it cannot be read
+  --dir-sizes        Show each directory's total size, counting files below --max-depth but not excluded ones
+  --disk-usage       Show and total the space files take on disk (allocated blocks) instead of their length;
+                     implies -s
+  --size-both        Show each file's length and its size on disk, e.g. (1.0 GB, 4.0 KB on disk); implies -s.
+                     Files taking less than half their length on disk are marked (sparse)
+  --btime            Show each entry's creation time where the platform records it (n/a otherwise)
+  --mtime            Show each entry's modification time, e.g. go.mod (2024-03-12 09:41); (?) if it cannot be read
+  --time-format      Layout of --mtime, --btime and --git-age times: a Go reference layout ("Jan 2 15:04"),
+                     iso, unix, or relative ("3 days ago"); default 2006-01-02 15:04
+  --checksum         Append each file's digest: sha256, sha1 or md5, e.g. sha256:ab12cd34…5678ef90
+                     (the whole digest in -f json); files are hashed concurrently with --jobs readers
+  --checksum-max-size Files larger than this are not hashed and show (skipped, too large) (default 100MB)
+  --hash-cache-clear Forget the digests of earlier runs. --checksum reuses the digest of a file whose path,
+                     size and mtime are unchanged, from ftg/digests.json in the user cache directory
+  --media-info       Show image dimensions (1920×1080) of PNG, JPEG, GIF and WebP files and the duration
+                     of WAV and MP4/MOV files, from their headers only; nothing if a header cannot be read
+  --git-age          Show each file's last commit time, or its mtime marked (untracked), in a git repo
+  --git-status       Mark entries with their working-tree status: (M) modified, (A) added or untracked,
+                     (D) tracked but deleted, which are shown although gone from disk; an untracked
+                     directory is marked as a whole. Fails outside a git repo unless set by a config file
+  --group-by         Render one section per owner or extension: owner, ext
+  --usage-by         Append a disk usage table: owner, ext, or owner,ext for owners by extension (md and json)
+  --usage-top        Columns shown by --usage-by before the smaller ones collapse into "other" (default 8)
+  --name-stats       Append a section on name lengths: the longest names, the directories with the longest
+                     names on average and the names longer than --name-max (md, text and json)
+  --name-max         Names longer than this many characters are listed by --name-stats (default 100)
+  --name-suggest     Also show what a rename to a prefix, a short hash and the extension would make of
+                     each of them, e.g. nightly-build-3f2a9c1d.tar.gz (nothing is renamed)
+  --redact-patterns  Replace names matching these globs with [redacted] (comma-separated)
+  --redact-keep-ext  Keep the extension of redacted names (e.g. [redacted].pem)
+  --redact-env       Also redact path segments equal to the current user or host name
+  --include-virtual  Scan /proc, /sys, /dev and /run when the input directory is / (skipped by default)
+  --grep-name        Only show entries whose name contains this text (case-insensitive) and their parents
+  --context          Also show this many tree lines before and after each --grep-name match
+  --grep-ignore-accents  Match --grep-name ignoring diacritics (factúre matches facture)
+  --sample           Show the first and last 3 entries of directories with more than N entries, eliding the rest
+  --max-entries      Show the first N entries of each directory, then one line counting the rest
+                     (md, text and svg); a run that leaves any out exits with code 5
+  -L, --max-depth    Deepest level to show; 1 is just the top level (default: everything). A directory
+                     it cuts off gets a line counting every entry below it, reading only listings
+  --count-hidden-limit Stop that count after this many entries and show "N+" (default 10000, 0 for no cap)
+  --auto-depth       Limit the depth so the tree stays within --auto-depth-lines lines (default 400)
+  --auto-depth-lines Line budget used by --auto-depth
+  --budget           Scan for at most this long (e.g. 30s), breadth first so the shallow levels are complete,
+                     then render what was read; unread directories say (not scanned) and exit code 5 follows
+                     (md and text only)
+  --timeout          Cancel the run after this long (e.g. 30s) and write nothing, like Ctrl-C or SIGTERM:
+                     how far it got is shown and exit code 9 follows (--budget renders what was read instead)
+  --resume           Checkpoint the walk to this state file and its .spill as each directory is completed;
+                     the same command run again after a crash, kill, Ctrl-C or --timeout takes the completed
+                     directories from the spill instead of walking them, and refuses other options (md and text)
+  --prune            Leave out directories with nothing to show: empty, everything inside excluded, or only
+                     directories left out themselves; directories cut off by --max-depth stay
+  --dirs-only        Show directories only, like tree -d; the summary counts directories alone, and with
+                     --prune a directory stays when it holds files, even though they are not shown
+  --min-size         Only show files of at least this size: bytes, or K, M, G, T (also KB, KiB, ...; powers
+                     of 1024), e.g. --min-size 50M; directories are still walked (add --prune to drop empty ones)
+  --max-size         Only show files of at most this size, in the same units as --min-size
+  --dedupe-mounts    Show a directory reachable at several paths (bind mounts, overlays) only once; on by default for /
+  --dedupe-subtrees  Collapse directories whose names, types and sizes repeat an earlier one
+  --follow-symlinks  Descend into symlinked directories and Windows junctions instead of listing them
+                     as [L] name -> target; links back into the current path are marked [cycle]
+  --find-orphans     Annotate stale artifacts (.pyc without .py, .o without source, *.orig, swap files) as (orphan?)
+                     and list them after the tree (md and json)
+  --progress-json    Write newline-delimited JSON progress events (dir, summary, warning, complete) to stderr
+  --progress         Status line on stderr with the directories read, entries written and the current
+                     directory, redrawn ten times a second: auto (default; when stderr is a terminal),
+                     always or never. It is cleared before any message, and never touches stdout
+  -q, --quiet        No status line, banner or "written to" messages; warnings and errors still show
+  -g, --gitignore    Skip paths matched by the root and nested .gitignore files (negations, dir/ and ** supported)
+  --export-ignore    Exclude paths marked export-ignore in .gitattributes files (matches git archive)
+  --dockerignore     Show the docker build context: apply the root .dockerignore with docker's rules (patterns
+                     match from the root, last match wins, ! re-includes, even inside excluded directories);
+                     the Dockerfile and .dockerignore always stay
+  --skip-active      Annotate files modified within this duration of the scan (e.g. 2s) as (in flux) (md and json)
+  --provenance       Append a provenance section: root, filesystem, user, timestamps, entries each exclusion hid (md and json)
+  --result-json-fd   Write one JSON result object (status, outputs, counts, exit code) to this descriptor (ignored on Windows)
+  --report-resources Print peak memory, ReadDir/stat counts and per-phase wall time to stderr
+  --sort             Entry order: name (case-insensitive), dirs-first or files-first (default: byte order)
+  --traversal        Order of -f csv, tsv, paths and manifest: dfs (default) lists each directory's entries right
+                     after it, as the trees do; bfs lists every entry of a level before the next level, each
+                     directory's entries together and in --sort order
+  --style            Connector style preset: default, rounded, double
+  --connectors       Custom connectors as 'branch,last-branch,pipe-prefix,space-prefix'
   -h, --help         Show this help message and exit
-  -v, --version      Show version information and exit`)
-	os.Exit(1)
+  -v, --version      Show version information and exit
+  --options-from     Read options from a JSON or flat TOML document ("-" for stdin); command-line flags take precedence
+  --config           Config file with default options in the --options-from schema, as JSON or flat TOML
+                     (key = value); by default .ftg.toml or .ftg.json in the input directory, then
+                     $XDG_CONFIG_HOME/ftg/config; command-line flags and --options-from win. A config
+                     in the input directory may only set filter and display options; --pipe, -o,
+                     --force, --inject, --post-url and the like need ftg/config or --config
+  --no-config        Do not load a config file
+  --output-template  Default output path when no -o is given (default file_tree_{dir}_{date}_{time}.{ext});
+                     {time}, {date}, {ext} and {dir} (the input directory's name) are filled in
+  --print-config     Print the effective options as a JSON document for --options-from and exit
+  --exit-codes       Show the exit codes and what they mean`)
 }
 
 // showVersion prints the version information and exits
 func showVersion() {
 	fmt.Printf("File Tree Generator version: %s\nLeave us a star at %s\nAuthor: %s\n", version, repository, author)
 	fmt.Printf("Buy me a coffee: %s\n", donation)
-	os.Exit(0)
+	exitProcess(exitOK)
 }
 
 // errorExit logs an error message and exits the program
 func errorExit(message string) {
-	log.Fatalf("Error: %s\n", message)
+	exitWith(exitFatal, message)
 }
 
-// shouldExclude checks if a given name matches any exclusion pattern
-func shouldExclude(name string) bool {
-	return excludePatterns[name]
+// flagSet reports whether a flag was given on the command line under any of its spellings
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if canonicalFlag(f.Name) == name {
+			set = true
+		}
+	})
+	return set
 }
 
-// getEntries reads the contents of a directory
-func getEntries(path string) ([]fs.DirEntry, error) {
-	return os.ReadDir(path)
+// getEntries reads the contents of a directory, dropping entries with malformed names.
+// A cancelled ctx ends the walk here, before the next listing is read.
+func getEntries(ctx context.Context, path string) ([]fs.DirEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, cached := cachedEntries(path)
+	if !cached {
+		var err error
+		if entries, err = readDir(path); err != nil {
+			return nil, err
+		}
+	}
+	recordListing(path, entries)
+	counters.readable++
+	listed := len(entries)
+	entries = validEntries(path, entries)
+	progressDiscover(path, entries, true)
+	progressDir(path, listed)
+	return entries, nil
 }
 
-// printEntry writes a formatted entry to the output
-func printEntry(writer io.Writer, name, entryType, prefix string, isLast bool) {
-	var connector string
-	if isLast {
-		connector = "└──"
-	} else {
-		connector = "├──"
+// validEntries drops entries with malformed names from a listing
+func validEntries(path string, entries []fs.DirEntry) []fs.DirEntry {
+	valid := entries[:0]
+	for _, entry := range entries {
+		if !validEntryName(entry.Name()) {
+			// Broken FUSE and network filesystems can list empty or path-like names
+			warnf("Skipping entry with invalid name %q in %s", entry.Name(), path)
+			continue
+		}
+		valid = append(valid, entry)
+	}
+	return valid
+}
+
+// validEntryName reports whether a listed name can be joined to its directory safely
+func validEntryName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\x00"+string(filepath.Separator))
+}
+
+// readDirError describes a directory that could not be listed during the walk
+func readDirError(path string, err error) string {
+	switch {
+	case errors.Is(err, syscall.ENOTDIR) || errors.Is(err, ftree.ErrChangedType):
+		return fmt.Sprintf("%s changed from a directory to a file during the scan", path)
+	case os.IsNotExist(err):
+		return fmt.Sprintf("%s was removed during the scan", path)
+	}
+	return fmt.Sprintf("Cannot read directory %s: %v", path, err)
+}
+
+// entryInfoError describes a listed entry whose file information could not be read
+func entryInfoError(path string, err error) string {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("%s was removed during the scan", path)
+	}
+	return fmt.Sprintf("Cannot read %s: %v", path, err)
+}
+
+// printEntry writes a formatted entry to the output; with fields, --entry-format
+// gives what follows the connector in place of the type tag and name
+func printEntry(writer io.Writer, style treePainter, name, entryType, class, prefix string, isLast bool, fields *entryFields) {
+	connector := connectors.Connector(isLast)
+	if fields != nil {
+		name = formatEntry(fields)
+	}
+	if outputFormat == formatText {
+		printTextEntry(writer, style, name, entryType, class, prefix+connector)
+		return
+	}
+	if fields != nil {
+		if _, err := fmt.Fprintf(writer, "%s %s\n", style.connector(prefix+connector), style.name(name, class)); err != nil {
+			warnf("Error writing entry: %v", err)
+		}
+		return
 	}
-	if _, err := fmt.Fprintf(writer, "%s%s [%s] %s\n", prefix, connector, entryType, name); err != nil {
-		log.Printf("Error writing entry: %v", err)
+	if _, err := fmt.Fprintf(writer, "%s [%s] %s\n", style.connector(prefix+connector), entryType, style.name(name, class)); err != nil {
+		warnf("Error writing entry: %v", err)
 	}
 }
 
@@ -88,129 +437,1297 @@ func getEntryType(entry fs.DirEntry) string {
 	return "F"
 }
 
-// generateTree recursively generates the tree structure
-func generateTree(writer io.Writer, path string, prefix string, entries []fs.DirEntry) {
-	for i, entry := range entries {
-		name := entry.Name()
-		if shouldExclude(name) {
-			continue
-		}
+// relativePath returns the slash-separated path of name inside dir, relative to the input directory
+func relativePath(dir, name string) string {
+	rel, err := filepath.Rel(scan.Root, filepath.Join(dir, name))
+	if err != nil {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
 
-		isLast := i == len(entries)-1
-		entryType := getEntryType(entry)
-		printEntry(writer, name, entryType, prefix, isLast)
+// visibleEntries applies the filters that remove entries, then --sort, before connectors are chosen
+func visibleEntries(path string, entries []fs.DirEntry) []fs.DirEntry {
+	return sortEntries(filteredEntries(path, entries))
+}
 
-		if entryType == "D" {
-			newPrefix := prefix
-			if isLast {
-				newPrefix += "    "
-			} else {
-				newPrefix += "│   "
-			}
+// filteredEntries applies the filters that remove entries, leaving the order alone
+func filteredEntries(path string, entries []fs.DirEntry) []fs.DirEntry {
+	return filterDirsOnly(path, contentEntries(path, entries))
+}
+
+// contentEntries applies the filters that decide what a directory holds: all of
+// filteredEntries but --dirs-only, which only changes what is shown of it
+func contentEntries(path string, entries []fs.DirEntry) []fs.DirEntry {
+	return filterPruned(path, filterSize(path, filterOnly(path, filterVirtual(path, filterKept(path, withDeleted(path, entries))))))
+}
 
-			subEntries, err := getEntries(filepath.Join(path, name))
-			if err != nil {
-				log.Printf("Cannot read directory %s: %v", filepath.Join(path, name), err)
-				continue
+// entryLabel returns the entry name followed by any enabled annotations
+func entryLabel(path string, entry fs.DirEntry) string {
+	label, redacted := entryName(path, entry.Name())
+	if redacted {
+		redactedCount++
+	}
+	label += reparseLabel(filepath.Join(path, entry.Name()), entry)
+	if note := overlayNote(filepath.Join(path, entry.Name())); note != "" {
+		label += " " + note
+	}
+	if note := sizeNote(path, entry); note != "" {
+		label += " " + note
+	}
+	if note := keepFilter[relativePath(path, entry.Name())]; note != "" {
+		label += " " + note
+	}
+	if note := linkNote(relativePath(path, entry.Name())); note != "" {
+		label += " " + note
+	}
+	if note := orphanNote(path, entry.Name(), entry.IsDir()); note != "" {
+		label += " " + note
+	}
+	if note := ownerBoundaryNote(path, entry); note != "" {
+		label += " " + note
+	}
+	if note := inFluxNote(filepath.Join(path, entry.Name()), entry); note != "" {
+		label += " " + note
+	}
+	if note := gitStatusNote(relativePath(path, entry.Name())); note != "" {
+		label += " " + note
+	}
+	if note := gitAgeNote(relativePath(path, entry.Name()), entry); note != "" {
+		label += " " + note
+	}
+	if note := anomalyNote(relativePath(path, entry.Name())); note != "" {
+		label += " " + note
+	}
+	if note := mtimeNote(entry); note != "" {
+		label += " " + note
+	}
+	if note := mediaNote(path, entry); note != "" {
+		label += " " + note
+	}
+	if note := checksumNote(path, entry); note != "" {
+		label += " " + note
+	}
+	if showBirthTime {
+		label += " (created " + formatBirthTime(filepath.Join(path, entry.Name()), entry) + ")"
+	}
+	return label
+}
+
+// formatBirthTime returns the entry's creation time, or "n/a" where the platform or filesystem has none
+func formatBirthTime(fullPath string, entry fs.DirEntry) string {
+	info, err := entryInfo(entry)
+	if err != nil {
+		return "?"
+	}
+	born, ok := birthTime(fullPath, info)
+	if !ok {
+		return "n/a"
+	}
+	return formatTimestamp(born)
+}
+
+// generateTree writes the tree below path, one line per entry, as walkTree visits
+// it. A directory's line waits for its listing, so it can say it is streamed and
+// its wrapped label knows whether entries follow. The walk only ends early once ctx
+// is done, which the callers check.
+func generateTree(ctx context.Context, writer io.Writer, path string, entries []fs.DirEntry) {
+	if resumed != nil {
+		writer = io.MultiWriter(writer, resumed)
+	}
+	prefixes := []string{""} // Prefix of the lines at each depth below an open directory
+	walkTree(ctx, path, entries, func(e treeEntry) error {
+		prefix := prefixes[e.depth-1]
+		switch e.event {
+		case walkElided:
+			printElision(writer, prefix, e.elided)
+		case walkTruncated:
+			printMore(writer, prefix, e.elided, e.isLast)
+		case walkEntry:
+			prefixes = prefixes[:e.depth]
+			if !e.descend {
+				printTreeEntry(ctx, writer, e, prefix, false)
+			} else if resumed != nil && resumed.enter(writer, e, prefix) {
+				return fs.SkipDir
+			}
+		case walkOpen:
+			printTreeEntry(ctx, writer, e, prefix, e.listed != 0)
+			prefixes = append(prefixes, connectors.Indent(prefix, e.isLast))
+		case walkFailed:
+			if len(prefixes) == e.depth {
+				// Not a streamed directory failing part way, whose line is out already
+				printTreeEntry(ctx, writer, e, prefix, false)
+			}
+			printReadError(writer, connectors.Indent(prefix, e.isLast), e.err)
+			if resumed != nil && e.listed != -1 {
+				resumed.drop()
+			}
+		case walkClose:
+			prefixes = prefixes[:e.depth]
+			if resumed != nil {
+				resumed.close()
 			}
-			generateTree(writer, filepath.Join(path, name), newPrefix, subEntries)
 		}
+		return nil
+	})
+}
+
+// printTreeEntry prints the line of one entry, children telling whether lines of its
+// entries follow
+func printTreeEntry(ctx context.Context, writer io.Writer, e treeEntry, prefix string, children bool) {
+	path, entry := e.dir, e.entry
+	fullPath := filepath.Join(path, entry.Name())
+	label := entryLabel(path, entry) + stubNote(e) + streamNote(e) + annotationNote(e.rel)
+	recordOverview(writer, path, entry, label, e.descend)
+
+	newPrefix := connectors.Indent(prefix, e.isLast)
+	var wrapped []string
+	shownName, _ := entryName(path, entry.Name())
+	icon := iconPrefix(shownName, entry.IsDir() || e.descend)
+	lead := entryLeadWidth(prefix, e.entryType, e.isLast) + displayWidth(icon)
+	fields := newEntryFields(path, entry, e.entryType, shownName, icon, label, entry.IsDir() || e.descend)
+	if wrapColumns > 0 && fields == nil {
+		label, wrapped = fitLabel(lead, wrapColumns-max(lead+2, displayWidth(newPrefix+connectors.Pipe)+1), shownName, label)
+	}
+	label = icon + label
+	printEntry(writer, painter, label, e.entryType, entryClass(entry, e.entryType), prefix, e.isLast, fields)
+	if len(wrapped) > 0 {
+		printContinuations(writer, continuationPrefix(newPrefix, children, lead), wrapped)
+	}
+	if !e.descend && depthCutoff(fullPath, entry) {
+		count, more := omittedEntries(ctx, fullPath)
+		printOmitted(writer, newPrefix, count, more)
 	}
 }
 
-// interactiveMode is a placeholder for future interactive exclusion selection
-func interactiveMode() {
-	fmt.Println("Interactive mode not implemented.")
-	os.Exit(1)
+// cliFlags holds the options main reads itself rather than through a package variable
+type cliFlags struct {
+	// Sizes parsed once the flags are read
+	checksumMax, minSizeText, maxSizeText string
+
+	exclude, include, hidden, color, anomalySpec, only, onlyExt, rulesOrderSpec, style, connectorSpec, redact, usageSpec string
+
+	manifestSchemaFlag, interactive, help, versionFlag, exitCodes, progressJSON, copyFlag, stdoutFlag bool
+}
+
+// defineFlags registers every flag and its alternative spellings on set
+func defineFlags(set *flag.FlagSet) *cliFlags {
+	cli := &cliFlags{}
+	set.StringVar(&cli.exclude, "e", "", "Exclude directories or files (comma-separated)")
+	set.Var(&excludeFrom, "exclude-from", "Read exclusion patterns from this file, one per line (repeatable)")
+	set.StringVar(&cli.hidden, "hidden", hiddenShow, "Show or hide entries whose names start with a dot (show, hide)")
+	set.StringVar(&cli.include, "include", "", "Dotfiles still shown with --hidden=hide (comma-separated)")
+	set.Var(&profileNames, "profile", "Exclusion profiles to apply, e.g. node,go (repeatable)")
+	set.Var(&profileDefs, "define-profile", "Define an exclusion profile as name=pattern,pattern (repeatable)")
+	set.BoolVar(&listProfiles, "list-profiles", false, "Show the exclusion profiles and exit")
+	set.StringVar(&cli.rulesOrderSpec, "rules-order", "", "Precedence of rule sources, highest first (cli,ignorefiles,presets,defaults)")
+	set.Var(&retentionSpecs, "simulate-retention", "Simulate a cleanup policy such as 'delete if older than 180d' (repeatable)")
+	set.BoolVar(&explainExcludes, "explain-excludes", false, "Append the exclusion rules in evaluation order with their hits")
+	set.BoolVar(&lintFilters, "lint-filters", false, "Warn about redundant, unused and shadowed exclusion rules")
+	set.StringVar(&cli.only, "only", "", "Only show paths matching these patterns and their ancestors (comma-separated)")
+	set.StringVar(&cli.onlyExt, "only-ext", "", "Only show files with these extensions and the directories leading to them (comma-separated)")
+	set.Var(&outputLocations, "o", "Specify an output location (repeatable)")
+	set.StringVar(&outputFormat, "format", formatMarkdown, "Output format (md, md-list, text, html, json, html-site, md-site, svg, mermaid, manifest, csv, tsv, paths)")
+	set.BoolVar(&manifestAllowPartial, "manifest-allow-partial", false, "Write -f manifest with a null sha256 for files that cannot be read")
+	set.BoolVar(&cli.manifestSchemaFlag, "manifest-schema", false, "Print the JSON Schema of -f manifest and exit")
+	set.StringVar(&cli.color, "color", colorAuto, "Color the tree on a terminal (auto, always, never)")
+	set.BoolVar(&noWrap, "no-wrap", false, "Cut annotations that do not fit the terminal with … instead of wrapping them")
+	set.BoolVar(&ambiguousWide, "ambiguous-wide", false, "Count East Asian Ambiguous characters two columns wide")
+	set.BoolVar(&trailingSlash, "trailing-slash", false, "End directory names with / in -f text")
+	set.StringVar(&entryFormat, "entry-format", "", "Template or preset (default, compact, detailed) of each tree line in md and text")
+	set.StringVar(&checkLinks, "check-links", "", "Flag broken relative links in files of this type (md)")
+	set.IntVar(&overviewDepth, "overview-depth", 0, "Render an overview this many levels deep above the full tree")
+	set.StringVar(&outputDir, "output-dir", "", "Directory written by -f html-site and -f md-site")
+	set.IntVar(&svgFontSize, "svg-font-size", svgFontSize, "Font size of -f svg in pixels")
+	set.StringVar(&svgThemeName, "svg-theme", svgThemeName, "Colors of -f svg (light, dark)")
+	set.BoolVar(&svgGlyphs, "svg-glyphs", false, "Draw folder and file shapes in -f svg")
+	set.StringVar(&mermaidDirection, "mermaid-direction", mermaidDirection, "Direction of -f mermaid (TD, LR)")
+	set.IntVar(&mermaidMaxNodes, "mermaid-max-nodes", mermaidMaxNodes, "Stop the -f mermaid diagram at this many nodes")
+	set.StringVar(&linkBase, "link-base", "", "Link each -f md-list entry to this URL followed by its path")
+	set.IntVar(&siteDepth, "site-depth", siteDepth, "Directories up to this depth get their own html-site or md-site page")
+	set.Var(rootsValue{}, "d", "Specify an input directory; repeat it or separate directories with commas for several")
+	set.StringVar(&archivePath, "archive", "", "Read the tree from this zip or tar archive")
+	set.StringVar(&overlayPlan, "overlay", "", "Render the tree as this plan of moves, deletions and creations would leave it")
+	set.StringVar(&rootPrefix, "root-prefix", "", "Treat this directory as / for displayed paths (e.g. a volume mounted at /scan)")
+	set.StringVar(&relativeTo, "relative-to", "", "Base directory for displayed paths and path patterns")
+	set.StringVar(&rootLabel, "root-label", "auto", "How headers name the input directory: auto, abs, rel or none")
+	set.BoolVar(&fullPaths, "full-paths", false, "Show each entry as its path from the input directory")
+	set.BoolVar(&osPaths, "os-paths", false, "Keep the separators of the OS in shown paths instead of /")
+	set.BoolVar(&cli.interactive, "i", false, "Interactive visual mode to select items to exclude")
+	set.BoolVar(&scan.NoDefaultExcludes, "no-default-excludes", false, "Do not apply the default exclusions")
+	set.BoolVar(&cli.help, "h", false, "Show this help message and exit")
+	set.BoolVar(&cli.versionFlag, "v", false, "Show version information and exit")
+	set.StringVar(&optionsFrom, "options-from", "", "Read options from a JSON or TOML document (- for stdin)")
+	set.StringVar(&configPath, "config", "", "Config file with default options (default .ftg.toml or .ftg.json in the input directory, then ftg/config)")
+	set.BoolVar(&noConfig, "no-config", false, "Do not load a config file")
+	set.StringVar(&outputTemplate, "output-template", outputTemplate, "Default output path: {time}, {date}, {ext} and {dir} are filled in")
+	set.BoolVar(&printConfig, "print-config", false, "Print the effective options as JSON and exit")
+	set.BoolVar(&cli.exitCodes, "exit-codes", false, "Show the exit codes and exit")
+	set.StringVar(&changedSince, "changed-since", "", "Only show files changed since a git ref")
+	set.StringVar(&sortOrder, "sort", "", "Entry order: name, dirs-first or files-first")
+	set.StringVar(&traversal, "traversal", traversal, "Order of the flat formats: dfs or bfs")
+	set.StringVar(&lang, "lang", lang, "Language of the report and messages (en, de, fr, es, ja)")
+	set.StringVar(&cli.style, "style", "default", "Connector style preset (default, rounded, double)")
+	set.StringVar(&cli.connectorSpec, "connectors", "", "Custom connectors: branch,last-branch,pipe-prefix,space-prefix")
+	set.BoolVar(&showSizes, "size", false, "Show file sizes")
+	set.BoolVar(&dirSizes, "dir-sizes", false, "Show cumulative directory sizes")
+	set.BoolVar(&diskUsage, "disk-usage", false, "Show sizes on disk instead of file lengths")
+	set.BoolVar(&sizeBoth, "size-both", false, "Show file lengths and sizes on disk")
+	set.BoolVar(&showBirthTime, "btime", false, "Annotate entries with their creation (birth) time")
+	set.BoolVar(&showMTime, "mtime", false, "Annotate entries with their modification time")
+	set.StringVar(&timeFormat, "time-format", "", "Layout of timestamp annotations (Go layout, iso, unix or relative)")
+	set.BoolVar(&gitAge, "git-age", false, "Show each file's last commit time")
+	set.BoolVar(&gitStatus, "git-status", false, "Mark entries modified (M), added (A) or deleted (D) in the working tree")
+	set.StringVar(&groupBy, "group-by", "", "Render one tree per owner or extension (owner, ext)")
+	set.StringVar(&cli.usageSpec, "usage-by", "", "Append a disk usage table by owner and/or extension (e.g. owner,ext)")
+	set.IntVar(&usageTop, "usage-top", usageTop, "Extension columns shown by --usage-by before the rest become \"other\"")
+	set.BoolVar(&nameStatsEnabled, "name-stats", false, "Append a section on the lengths of entry names")
+	set.IntVar(&nameMax, "name-max", nameMax, "Names longer than this many characters are listed by --name-stats")
+	set.BoolVar(&nameSuggest, "name-suggest", false, "Suggest a truncate-and-hash rename for names longer than --name-max")
+	set.BoolVar(&includeVirtual, "include-virtual", false, "Scan /proc, /sys, /dev and /run when the root is /")
+	set.StringVar(&grepName, "grep-name", "", "Only show entries whose name contains this text, plus context")
+	set.IntVar(&grepContext, "context", 0, "Lines of tree context around each --grep-name match")
+	set.BoolVar(&grepIgnoreAccents, "grep-ignore-accents", false, "Ignore diacritics when matching --grep-name")
+	set.IntVar(&sampleSize, "sample", 0, "Show only both ends of directories with more entries than this")
+	set.IntVar(&maxEntries, "max-entries", 0, "Show at most this many entries per directory, then a line counting the rest")
+	set.IntVar(&scan.MaxDepth, "max-depth", 0, "Deepest level to show")
+	set.IntVar(&countHiddenLimit, "count-hidden-limit", countHiddenLimit, "Entries counted below a directory --max-depth cuts off before showing N+ (0 for no cap)")
+	set.BoolVar(&autoDepth, "auto-depth", false, "Pick the deepest level that fits in --auto-depth-lines")
+	set.IntVar(&autoDepthLines, "auto-depth-lines", autoDepthLines, "Line budget for --auto-depth")
+	set.DurationVar(&scanBudget, "budget", 0, "Scan breadth first for at most this long, then render what was read")
+	set.DurationVar(&runTimeout, "timeout", 0, "Cancel the run and write nothing after this long")
+	set.StringVar(&resumeState, "resume", "", "Checkpoint the walk to this state file and resume an interrupted run from it")
+	set.BoolVar(&pruneEmpty, "prune", false, "Leave out directories with nothing visible inside")
+	set.BoolVar(&scan.DirsOnly, "dirs-only", false, "Show only directories, like tree -d")
+	set.Var(crlfValue{}, "crlf", "End lines with CRLF: auto (text and markdown on Windows), always or never")
+	set.Var(iconsValue{}, "icons", "Put a glyph before each name: nerd (the default with a bare --icons) or emoji")
+	set.Var(iconMapValue{}, "icon-map", "Glyphs for --icons by extension, e.g. go=🐹,tar.gz=📦,dir=📂 (repeatable)")
+	set.StringVar(&cli.minSizeText, "min-size", "", "Only show files of at least this size (e.g. 50M)")
+	set.StringVar(&cli.maxSizeText, "max-size", "", "Only show files of at most this size (e.g. 1G)")
+	set.BoolVar(&dedupeSubtrees, "dedupe-subtrees", false, "Collapse directories identical to one already shown")
+	set.BoolVar(&dedupeMounts, "dedupe-mounts", false, "Show directories reachable through several mounts only once (default on when the root is /)")
+	set.BoolVar(&followSymlinks, "follow-symlinks", false, "Descend into symlinked directories and junctions")
+	set.BoolVar(&findOrphans, "find-orphans", false, "Flag derived artifacts whose source is missing and merge/editor leftovers")
+	set.BoolVar(&cli.progressJSON, "progress-json", false, "Write newline-delimited JSON progress events to stderr")
+	set.StringVar(&progressMode, "progress", progressAuto, "Status line on stderr: never, auto (when stderr is a terminal) or always")
+	set.BoolVar(&quiet, "quiet", false, "Print no status line, banner or status messages")
+	set.BoolVar(&useGitignore, "gitignore", false, "Skip paths matched by .gitignore files")
+	set.BoolVar(&exportIgnore, "export-ignore", false, "Exclude paths marked export-ignore in .gitattributes")
+	set.StringVar(&checksumAlgo, "checksum", "", "Append each file's digest: sha256, sha1 or md5")
+	set.StringVar(&cli.checksumMax, "checksum-max-size", "100MB", "Do not hash files larger than this")
+	set.BoolVar(&hashCacheClear, "hash-cache-clear", false, "Delete the digest cache before the run")
+	set.BoolVar(&showMediaInfo, "media-info", false, "Annotate images with their dimensions and media files with their duration")
+	set.BoolVar(&useDockerignore, "dockerignore", false, "Exclude what the root .dockerignore keeps out of the docker build context")
+	set.DurationVar(&skipActive, "skip-active", 0, "Flag files modified within this window of the scan start (e.g. 2s)")
+	set.BoolVar(&provenanceFlag, "provenance", false, "Append a record of what the scan covered")
+	set.IntVar(&resultFD, "result-json-fd", resultFD, "Write a JSON result object to this file descriptor when the run ends")
+	set.BoolVar(&reportResources, "report-resources", false, "Print resource usage to stderr after the run")
+	set.StringVar(&cli.redact, "redact-patterns", "", "Replace names matching these globs with [redacted] (comma-separated)")
+	set.BoolVar(&redactKeepExt, "redact-keep-ext", false, "Keep the extension of redacted names")
+	set.BoolVar(&redactEnv, "redact-env", false, "Also redact path segments equal to the user or host name")
+	set.BoolVar(&cli.stdoutFlag, "stdout", false, "Write the bare tree to stdout (same as -o -)")
+	set.BoolVar(&cli.copyFlag, "copy", false, "Also copy the tree to the system clipboard (same as -o clipboard)")
+	set.StringVar(&syncScript, "emit-sync-script", "", "With ftg diff, print a sh or powershell script syncing the old tree's layout to the new")
+	set.BoolVar(&includeDeletes, "include-deletes", false, "Let the sync script delete entries the new tree no longer has")
+	set.DurationVar(&estimateBudget, "estimate-budget", estimateBudget, "Time ftg estimate may spend sampling")
+	set.StringVar(&daemonSocket, "socket", daemonSocket, "Unix socket of ftg daemon")
+	set.DurationVar(&daemonRefresh, "refresh", 0, "Rebuild the ftg daemon snapshot this often")
+	set.BoolVar(&preserveAnnotations, "preserve-annotations", false, "Keep the # comments added by hand to the existing output file")
+	set.BoolVar(&ownerBoundaries, "owner-boundaries", false, "Mark directories whose owner differs from their parent's owner")
+	set.IntVar(&ownerBoundaryDepth, "owner-boundary-depth", 0, "Ignore owner boundaries deeper than this level")
+	set.BoolVar(&anomaliesEnabled, "anomalies", false, "Flag files whose size, age or extension stands out from their siblings")
+	set.StringVar(&cli.anomalySpec, "anomaly-thresholds", "", "Thresholds of --anomalies, e.g. size=3,age=3,ext=0.95,siblings=5")
+	set.BoolVar(&securityReport, "security-report", false, "Append the risky permissions found in the tree")
+	set.BoolVar(&strictSecurity, "strict-security", false, "Exit with code 6 on high or medium security findings")
+	set.BoolVar(&noSummary, "no-summary", false, "Leave out the totals and filters lines under the tree")
+	set.Var(jobsValue{}, "jobs", "Directories read concurrently before the tree is rendered, or auto")
+	set.IntVar(&streamThreshold, "stream-threshold", streamThreshold, "Stream directories with more entries than this (0 never)")
+	set.BoolVar(&streamSorted, "stream-sort", false, "Merge-sort streamed directories through temporary files")
+	set.IntVar(&jobsMin, "jobs-min", jobsMin, "Fewest concurrent reads with --jobs auto")
+	set.IntVar(&jobsMax, "jobs-max", jobsMax, "Most concurrent reads with --jobs auto")
+	set.BoolVar(&selfCheck, "self-check", false, "Render twice and require byte-identical output")
+	set.BoolVar(&verifyRenderers, "verify-renderers", false, "Render every format and require the same entries in each")
+	set.StringVar(&historyFile, "history", "", "Append a summary record of each run to this NDJSON log")
+	set.BoolVar(&usageLog, "usage-log", false, "Record the flag names, duration and counts of each run in the local usage log")
+	set.StringVar(&historyDetail, "history-detail", historyDetail, "History detail: summary or changes")
+	set.StringVar(&pipeCommand, "pipe", "", "Run the output through this shell command before writing it")
+	set.DurationVar(&pipeTimeout, "pipe-timeout", pipeTimeout, "Kill the --pipe command after this long")
+	set.BoolVar(&forceOverwrite, "force", false, "Replace output files that already exist")
+	set.IntVar(&jsonIndent, "json-indent", jsonIndent, "Spaces per level of -f json and -f manifest (1 to 8)")
+	set.BoolVar(&noExtCheck, "no-ext-check", false, "Do not warn when an -o file's extension does not match -f")
+	set.BoolVar(&sidecarJSON, "sidecar", false, "Also write each -o file's -f json snapshot to <file>.json")
+	set.BoolVar(&checkOutputs, "check", false, "Compare the -o files (and --sidecar snapshots) with this run's render instead of writing them")
+	set.BoolVar(&skipUnchanged, "skip-unchanged", false, "Do not rewrite output files whose content fingerprint is unchanged")
+	set.StringVar(&injectFile, "inject", "", "Replace the marked section of this file with the tree")
+	set.StringVar(&injectMarkers, "inject-markers", injectMarkers, "Start and end marker for --inject, comma-separated")
+	set.BoolVar(&injectDryRun, "inject-dry-run", false, "Print what --inject would change as a diff and write nothing")
+	set.BoolVar(&injectBackup, "inject-backup", false, "Save the --inject file as <file>.bak before changing it")
+	set.StringVar(&postURL, "post-url", "", "Also PUT the rendered tree to this URL")
+	set.StringVar(&postContentType, "post-content-type", postContentType, "Content-Type used for --post-url")
+	set.StringVar(&postAuthEnv, "post-auth-env", "", "Environment variable holding the Authorization header for --post-url")
+	set.BoolVar(&strictFlags, "strict-flags", false, "Treat deprecated flag spellings as errors")
+
+	// Short and long spellings of the same options
+	for alias, name := range map[string]string{
+		"f": "format", "c": "no-default-excludes", "s": "size", "L": "max-depth", "g": "gitignore", "q": "quiet",
+		"exclude": "e", "output": "o", "directory": "d", "root": "d", "interactive": "i", "help": "h", "version": "v",
+	} {
+		aliasFlag(set, alias, name)
+	}
+	// --clear no longer clears anything but the defaults; -c stays as the short form
+	deprecatedFlag(set, "clear", "no-default-excludes")
+	return cli
 }
 
 // main is the entry point of the application
 func main() {
+	// Non-ASCII names need a UTF-8 console on Windows; exitProcess restores it
+	useUTF8Console()
+	defer restoreConsole()
+
 	// Define command-line flags
-	var exclude string
-	var interactive, clearExclusions, help, versionFlag bool
+	cli := defineFlags(flag.CommandLine)
 
-	flag.StringVar(&exclude, "e", "", "Exclude directories or files (comma-separated)")
-	flag.StringVar(&outputLocation, "o", "", "Specify an output location")
-	flag.StringVar(&inputDirectory, "d", "", "Specify an input directory")
-	flag.BoolVar(&interactive, "i", false, "Interactive visual mode to select items to exclude")
-	flag.BoolVar(&clearExclusions, "c", false, "Clear the exclusion list")
-	flag.BoolVar(&help, "h", false, "Show this help message and exit")
-	flag.BoolVar(&versionFlag, "v", false, "Show version information and exit")
+	// Subcommands: "explain [options] path..." traces filter decisions with the
+	// normal options, "check-update" and "test-pattern" have their own arguments
+	explainMode, estimateMode, daemonMode, conformMode, diffMode := false, false, false, false, false
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "explain":
+			explainMode = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "estimate":
+			estimateMode = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "daemon":
+			daemonMode = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "conform":
+			conformMode = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "diff":
+			diffMode = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "daemon-client":
+			daemonClient(os.Args[2:])
+			return
+		case "check-update":
+			checkUpdate(os.Args[2:])
+			return
+		case "init":
+			dir, generate := runInit(os.Args[2:])
+			if !generate {
+				return
+			}
+			// Generate the first tree as a plain run would, with the config just written
+			os.Args = []string{os.Args[0], "-d", dir}
+		case "test-pattern":
+			testPatterns(os.Args[2:])
+			return
+		case "history":
+			historyReport(os.Args[2:])
+			return
+		case "merge":
+			runSnapshotMerge(os.Args[2:])
+			return
+		case "usage-report":
+			usageReport(os.Args[2:])
+			return
+		}
+	}
 
+	// A flag that does not parse shows the real help on stderr, then exits with the usage code
+	flag.Usage = func() { writeHelp(os.Stderr) }
 	flag.Parse()
 
+	// Fill in options from a document; flags given on the command line win
+	if optionsFrom != "" {
+		if err := applyOptions(optionsFrom); err != nil {
+			usageExit(err.Error())
+		}
+	}
+	gitStatusAsked = gitStatus
+	// Then from the config file, the lowest-priority source
+	if configPath != "" && noConfig {
+		usageExit("--config cannot be combined with --no-config")
+	}
+	configDir := normalizeInput(scan.Root)
+	if configDir == "" {
+		configDir = "."
+	}
+	if isArchiveFile(configDir) {
+		// An archive uses the config of the directory it is in
+		configDir = filepath.Dir(configDir)
+	}
+	if err := loadConfig(configDir); err != nil {
+		errorExit(err.Error())
+	}
+	checkDeprecatedFlags()
+	if printConfig {
+		showConfig()
+	}
+	if countHiddenLimit < 0 {
+		usageExit("--count-hidden-limit must not be negative")
+	}
+	if jsonIndent < 1 || jsonIndent > 8 {
+		usageExit(fmt.Sprintf("--json-indent must be between 1 and 8 (got %d)", jsonIndent))
+	}
+	if depthLimited() && scan.MaxDepth < 1 {
+		usageExit(fmt.Sprintf("--max-depth must be at least 1 (got %d); leave it out to show everything", scan.MaxDepth))
+	}
+
+	switch progressMode {
+	case progressNever, progressAuto, progressAlways:
+	default:
+		usageExit(fmt.Sprintf("unknown --progress value %q (use never, auto or always)", progressMode))
+	}
+	if cli.progressJSON && progressMode == progressAlways {
+		usageExit("--progress=always cannot be combined with --progress-json, which writes to stderr too")
+	}
+	if cli.progressJSON {
+		startProgress()
+	}
+	runStarted = time.Now()
+	if resultFD >= 0 {
+		openResultFD(resultFD)
+	}
+
 	// Handle special flags
 	switch {
-	case help:
+	case cli.help:
 		showUsage()
-	case versionFlag:
+	case cli.versionFlag:
 		showVersion()
-	case clearExclusions:
-		excludePatterns = map[string]bool{}
+	case cli.exitCodes:
+		showExitCodes()
+	case listProfiles:
+		showProfiles()
+	case cli.manifestSchemaFlag:
+		showManifestSchema()
 	}
 
-	// Process exclusion patterns
-	if exclude != "" {
-		for _, pattern := range strings.Split(exclude, ",") {
-			excludePatterns[pattern] = true
+	// Select the connector style; explicit connectors override the preset
+	var err error
+	if connectors, err = lookupStyle(cli.style); err != nil {
+		usageExit(err.Error())
+	}
+	if cli.connectorSpec != "" {
+		if connectors, err = parseConnectors(cli.connectorSpec); err != nil {
+			usageExit(err.Error())
 		}
+	} else if outputFormat == formatText && !flagSet("style") {
+		connectors = ftree.Styles["tree"]
+	}
+	if ambiguousWide && cli.connectorSpec == "" {
+		connectors = alignStyle(connectors)
 	}
 
-	// Add common exclusions
-	commonExcludes := []string{"node_modules", ".next", ".vscode", ".idea", ".git", "target", "Cargo.lock"}
-	for _, pattern := range commonExcludes {
-		excludePatterns[pattern] = true
+	if err := checkSortOrder(); err != nil {
+		usageExit(err.Error())
+	}
+	if err := setLang(lang); err != nil {
+		usageExit(err.Error())
+	}
+	if groupBy != "" && groupBy != "owner" && groupBy != "ext" {
+		usageExit(fmt.Sprintf("unknown --group-by value %q (use owner or ext)", groupBy))
+	}
+	if cli.usageSpec != "" {
+		if err := parseUsageBy(cli.usageSpec); err != nil {
+			usageExit(err.Error())
+		}
+	}
+	if grepName != "" && groupBy != "" {
+		usageExit("--grep-name cannot be combined with --group-by")
+	}
+	if checksumAlgo != "" {
+		if checksumHashes[checksumAlgo] == nil {
+			usageExit(fmt.Sprintf("unknown --checksum algorithm %q (use sha256, sha1 or md5)", checksumAlgo))
+		}
+		var err error
+		if checksumMaxSize, err = parseByteSize(cli.checksumMax); err != nil {
+			usageExit(fmt.Sprintf("--checksum-max-size: %v", err))
+		}
+	}
+	if hashCacheClear {
+		clearHashCache()
+	}
+	if cli.minSizeText != "" {
+		var err error
+		if minSize, err = parseByteSize(cli.minSizeText); err != nil {
+			usageExit(fmt.Sprintf("--min-size: %v", err))
+		}
+	}
+	if cli.maxSizeText != "" {
+		var err error
+		if maxSize, err = parseByteSize(cli.maxSizeText); err != nil {
+			usageExit(fmt.Sprintf("--max-size: %v", err))
+		}
+	}
+	if minSize >= 0 && maxSize >= 0 && minSize > maxSize {
+		usageExit("--min-size must not be larger than --max-size")
+	}
+	if (flagSet("name-max") || nameSuggest) && !nameStatsEnabled {
+		usageExit("--name-max and --name-suggest only work with --name-stats")
+	}
+	if nameStatsEnabled {
+		if outputFormat != formatMarkdown && outputFormat != formatText && outputFormat != formatJSON {
+			usageExit("--name-stats only works with -f md, text or json")
+		}
+		if nameMax < 20 {
+			usageExit("--name-max must be at least 20, so a suggested name has room for a hash")
+		}
+	}
+	if maxEntries < 0 {
+		usageExit("--max-entries must not be negative")
+	}
+	if sampleSize < 0 {
+		usageExit("--sample must not be negative")
+	}
+	if maxEntries > 0 {
+		if !truncatesListings(outputFormat) {
+			usageExit("--max-entries only works with -f md, text or svg")
+		}
+		if sampleSize > 0 {
+			usageExit("--max-entries cannot be combined with --sample")
+		}
+	}
+	switch {
+	case traversal != traversalDFS && traversal != traversalBFS:
+		usageExit(fmt.Sprintf("unknown --traversal value %q (use dfs or bfs)", traversal))
+	case traversal == traversalBFS && !slices.Contains(flatFormats, outputFormat):
+		usageExit(fmt.Sprintf("--traversal bfs is only available with -f csv, tsv, paths and manifest; -f %s nests entries in their directories", outputFormat))
+	}
+	if historyDetail != "summary" && historyDetail != "changes" {
+		usageExit(fmt.Sprintf("unknown --history-detail value %q (use summary or changes)", historyDetail))
+	}
+	switch outputFormat {
+	case formatMarkdown, formatJSON:
+	case formatManifest:
+		if groupBy != "" || sampleSize > 0 {
+			usageExit("-f manifest lists every file and cannot be combined with --group-by or --sample")
+		}
+	case formatText, formatHTML, formatCSV, formatTSV, formatPaths:
+		flat := outputFormat == formatCSV || outputFormat == formatTSV || outputFormat == formatPaths
+		if groupBy != "" {
+			usageExit(fmt.Sprintf("--group-by cannot be combined with -f %s", outputFormat))
+		}
+		if skipUnchanged && flat {
+			usageExit(fmt.Sprintf("--skip-unchanged cannot be combined with -f %s, which has no place for a fingerprint", outputFormat))
+		}
+		if sampleSize > 0 && flat {
+			usageExit(fmt.Sprintf("-f %s lists every entry and cannot be combined with --sample", outputFormat))
+		}
+	case formatMarkdownList:
+		if groupBy != "" {
+			usageExit("--group-by cannot be combined with -f md-list")
+		}
+	case formatMermaid:
+		if !strings.EqualFold(mermaidDirection, "TD") && !strings.EqualFold(mermaidDirection, "LR") {
+			usageExit(fmt.Sprintf("unknown --mermaid-direction %q (use TD or LR)", mermaidDirection))
+		}
+		if mermaidMaxNodes < 2 {
+			usageExit("--mermaid-max-nodes must be at least 2")
+		}
+		if groupBy != "" {
+			usageExit("--group-by cannot be combined with -f mermaid")
+		}
+	case formatSVG:
+		if _, ok := svgThemes[svgThemeName]; !ok {
+			usageExit(fmt.Sprintf("unknown --svg-theme %q (use light or dark)", svgThemeName))
+		}
+		if svgFontSize < 1 {
+			usageExit("--svg-font-size must be at least 1")
+		}
+		if ambiguousWide {
+			usageExit("--ambiguous-wide cannot be combined with -f svg, whose font draws every character of the tree one column wide")
+		}
+		if groupBy != "" {
+			usageExit("--group-by cannot be combined with -f svg")
+		}
+	case formatHTMLSite, formatMDSite:
+		if outputDir == "" {
+			usageExit(fmt.Sprintf("-f %s needs --output-dir", outputFormat))
+		}
+		if groupBy != "" {
+			usageExit("--group-by cannot be combined with -f " + outputFormat)
+		}
+		if historyFile != "" {
+			usageExit("--history cannot be combined with -f " + outputFormat)
+		}
+		if selfCheck {
+			usageExit("--self-check cannot be combined with -f " + outputFormat)
+		}
+		if verifyRenderers {
+			usageExit("--verify-renderers cannot be combined with -f " + outputFormat)
+		}
+		if pipeCommand != "" {
+			usageExit("--pipe cannot be combined with -f " + outputFormat)
+		}
+		if skipUnchanged {
+			usageExit("--skip-unchanged cannot be combined with -f " + outputFormat)
+		}
+	case formatUnified:
+		if !diffMode {
+			usageExit("-f unified is only available with ftg diff, which compares two -f paths snapshots with it")
+		}
+	default:
+		usageExit(fmt.Sprintf("unknown format %q (use md, md-list, text, html, json, html-site, md-site, svg, mermaid, manifest, csv, tsv or paths)", outputFormat))
 	}
 
-	if interactive {
-		interactiveMode()
+	for _, spec := range retentionSpecs {
+		if _, err := parseRetention(spec); err != nil {
+			usageExit(err.Error())
+		}
+	}
+	if len(retentionSpecs) > 0 && outputFormat != formatMarkdown {
+		usageExit("--simulate-retention needs -f md")
+	}
+	if checkLinks != "" && checkLinks != "md" {
+		usageExit(fmt.Sprintf("unknown --check-links type %q (use md)", checkLinks))
+	}
+	if checkLinks != "" && outputFormat != formatMarkdown {
+		usageExit("--check-links needs -f md")
+	}
+	if overviewDepth < 0 {
+		usageExit("--overview-depth must be at least 1")
+	}
+	if grepContext < 0 {
+		usageExit("--context must not be negative")
+	}
+	if (flagSet("context") || grepIgnoreAccents) && grepName == "" {
+		usageExit("--context and --grep-ignore-accents only work with --grep-name")
+	}
+	if pipeCommand != "" && skipUnchanged {
+		usageExit("--skip-unchanged cannot be combined with --pipe")
+	}
+	if walkJobs < 1 {
+		usageExit("--jobs must be at least 1")
+	}
+	if streamThreshold < 0 {
+		usageExit("--stream-threshold must not be negative")
+	}
+	if linkBase != "" && outputFormat != formatMarkdownList {
+		usageExit("--link-base only works with -f md-list")
+	}
+	if scanBudget < 0 {
+		usageExit("--budget must not be negative")
+	}
+	if scanBudget > 0 {
+		if outputFormat != formatMarkdown && outputFormat != formatText {
+			usageExit("--budget only works with -f md or -f text")
+		}
+		if autoDepth || grepName != "" || checkLinks != "" || cli.only != "" || cli.onlyExt != "" || dirSizes || groupBy != "" || overviewDepth > 0 || pruneEmpty || checksumAlgo != "" {
+			usageExit("--budget cannot be combined with --auto-depth, --grep-name, --check-links, --only, --only-ext, --dir-sizes, --group-by, --overview-depth, --prune or --checksum, which read the whole tree")
+		}
+	}
+	if jobsMin < 1 || jobsMax < jobsMin {
+		usageExit("--jobs-min must be at least 1 and no more than --jobs-max")
+	}
+	if pipeTimeout < 0 {
+		usageExit("--pipe-timeout must not be negative")
+	}
+	if runTimeout < 0 {
+		usageExit("--timeout must not be negative")
+	}
+	if cli.anomalySpec != "" {
+		if err := parseAnomalyThresholds(cli.anomalySpec); err != nil {
+			usageExit(err.Error())
+		}
+		anomaliesEnabled = true
+	}
+	if ownerBoundaryDepth < 0 {
+		usageExit("--owner-boundary-depth must not be negative")
+	}
+	if flagSet("owner-boundary-depth") && !ownerBoundaries {
+		usageExit("--owner-boundary-depth only works with --owner-boundaries")
+	}
+	// These append a summary, which only md and json have a place for
+	if outputFormat != formatMarkdown && outputFormat != formatJSON {
+		for _, refused := range []archiveRefusal{
+			{provenanceFlag, "--provenance"},
+			{explainExcludes, "--explain-excludes"},
+			{usageEnabled, "--usage-by"},
+			{findOrphans, "--find-orphans"},
+			{skipActive > 0, "--skip-active"},
+		} {
+			if refused.set {
+				usageExit(fmt.Sprintf("%s is only available with -f md and -f json", refused.name))
+			}
+		}
+	}
+	if ownerBoundaries && outputFormat != formatMarkdown {
+		usageExit("--owner-boundaries is only available with -f md")
+	}
+	if anomaliesEnabled && outputFormat != formatMarkdown {
+		usageExit("--anomalies is only available with -f md")
+	}
+	if strictSecurity {
+		securityReport = true
+	}
+	if diskUsage || sizeBoth {
+		showSizes = true
+	}
+	if securityReport && outputFormat != formatMarkdown {
+		usageExit("--security-report is only available with -f md")
+	}
+	if preserveAnnotations && (outputFormat != formatMarkdown || groupBy != "" || overviewDepth > 0) {
+		usageExit("--preserve-annotations needs -f md and cannot be combined with --group-by or --overview-depth")
+	}
+	switch rootLabel {
+	case "auto", "abs", "rel", "none":
+	default:
+		usageExit(fmt.Sprintf("unknown --root-label %q (use auto, abs, rel or none)", rootLabel))
+	}
+	if fullPaths && outputFormat != formatMarkdown && outputFormat != formatMarkdownList && outputFormat != formatText {
+		usageExit("--full-paths is only available with -f md, -f md-list and -f text")
+	}
+	if fullPaths && preserveAnnotations {
+		usageExit("--full-paths cannot be combined with --preserve-annotations, which matches lines by name")
+	}
+	if entryFormat != "" {
+		if outputFormat != formatMarkdown && outputFormat != formatText {
+			usageExit("--entry-format is only available with -f md and -f text")
+		}
+		if preserveAnnotations {
+			usageExit("--entry-format cannot be combined with --preserve-annotations, which reads the usual lines back")
+		}
+		tmpl, err := parseEntryFormat(entryFormat)
+		if err != nil {
+			usageExit(err.Error())
+		}
+		entryTemplate = tmpl
+	}
+	if overviewDepth > 0 && (outputFormat != formatMarkdown || groupBy != "") {
+		usageExit("--overview-depth needs -f md and cannot be combined with --group-by")
+	}
+	if verifyRenderers && (groupBy != "" || overviewDepth > 0) {
+		usageExit("--verify-renderers compares the plain trees and cannot be combined with --group-by or --overview-depth")
+	}
+	if err := parseHidden(cli.hidden); err != nil {
+		usageExit(err.Error())
+	}
+	if err := parseColor(cli.color); err != nil {
+		usageExit(err.Error())
+	}
+	if err := parseTimeFormat(timeFormat); err != nil {
+		usageExit(err.Error())
+	}
+	if cli.include != "" {
+		includePatterns = strings.Split(filepath.ToSlash(cli.include), ",")
+	}
+	if cli.rulesOrderSpec != "" {
+		if err := parseRulesOrder(cli.rulesOrderSpec); err != nil {
+			usageExit(err.Error())
+		}
+	}
+	// Process exclusion patterns
+	if cli.exclude != "" {
+		for _, pattern := range strings.Split(cli.exclude, ",") {
+			addExcludeRule(sourceCLI, "user (-e)", pattern)
+		}
+	}
+	// Read before anything is written, so a mistyped name leaves no partial output
+	for _, file := range excludeFrom {
+		if err := loadExcludeFile(file); err != nil {
+			usageExit(fmt.Sprintf("Cannot read the --exclude-from file: %v", err))
+		}
 	}
 
-	// Set default output location if not specified
-	if outputLocation == "" {
-		currentTime := time.Now().Format("15-04-05")
-		outputLocation = fmt.Sprintf("file_tree_%s.md", currentTime)
+	if err := applyProfiles(); err != nil {
+		usageExit(err.Error())
+	}
+
+	// Add common exclusions unless -c or --no-default-excludes asks for the complete tree
+	if !scan.NoDefaultExcludes {
+		for _, pattern := range ftree.DefaultExcludes {
+			addExcludeRule(sourceDefaults, "default", pattern)
+		}
+		for _, pattern := range platformExcludes {
+			addExcludeRule(sourceDefaults, "default", pattern)
+		}
 	}
 
 	// Set default input directory to current working directory if not specified
-	if inputDirectory == "" {
-		var err error
-		inputDirectory, err = os.Getwd()
+	if scan.Root == "" {
+		scan.Root, err = os.Getwd()
 		if err != nil {
 			errorExit("Failed to get current directory")
 		}
 	}
+	scan.Root = normalizeInput(scan.Root)
+	if multiRoot() {
+		checkRoots([]archiveRefusal{
+			{archivePath != "", "--archive"}, {changedSince != "", "--changed-since"}, {gitAge, "--git-age"},
+			{gitStatus, "--git-status"}, {useDockerignore, "--dockerignore"}, {relativeTo != "", "--relative-to"}, {overlayPlan != "", "--overlay"},
+			{historyFile != "", "--history"}, {preserveAnnotations, "--preserve-annotations"}, {cli.interactive, "-i"},
+			{estimateMode, "ftg estimate"}, {daemonMode, "ftg daemon"}, {conformMode, "ftg conform"},
+			{diffMode, "ftg diff"}, {explainMode, "ftg explain"}, {verifyRenderers, "--verify-renderers"},
+			{siteFormat(outputFormat), "-f " + outputFormat}, {resumeState != "", "--resume"},
+		})
+	}
+	if archivePath != "" || isArchiveFile(scan.Root) {
+		openArchiveInput([]archiveRefusal{
+			{changedSince != "", "--changed-since"}, {estimateMode, "ftg estimate"}, {daemonMode, "ftg daemon"},
+		})
+	}
 
-	fmt.Printf("Generating your file tree for %s, while you wait... \nGive the project a star at %s\n", inputDirectory, repository)
+	if cli.stdoutFlag {
+		outputLocations = append(outputLocations, stdoutTarget)
+	}
+	if stdoutCount(outputLocations) > 1 {
+		usageExit("standard output is given more than once (-o - and --stdout both mean stdout); give it once, or the tree is printed twice")
+	}
 
-	// Create and open the output file
-	outputFile, err := os.Create(outputLocation)
-	if err != nil {
-		errorExit(fmt.Sprintf("Cannot write to output location %s", outputLocation))
+	// Piped output carries only the tree: no title, code fence or fingerprint, and
+	// every status message goes to stderr
+	bare := toStdout(outputLocations)
+	if bare || injectDryRun {
+		// The --inject-dry-run diff is printed on stdout too
+		messages = os.Stderr
+	}
+	if quiet || progress.enabled && messages == os.Stderr {
+		// --progress-json keeps stderr to its events
+		messages = io.Discard
+	}
+
+	if cli.interactive {
+		interactiveMode()
+	}
+	if cli.only != "" {
+		onlyPatterns = strings.Split(filepath.ToSlash(cli.only), ",")
+	}
+	if cli.onlyExt != "" {
+		parseOnlyExts(cli.onlyExt)
+	}
+
+	// Collect redaction rules
+	if cli.redact != "" {
+		redactPatterns = strings.Split(cli.redact, ",")
+	}
+	if redactEnv {
+		loadRedactEnv()
+	}
+
+	if cli.copyFlag {
+		outputLocations = append(outputLocations, clipboardTarget)
+	}
+
+	if (injectDryRun || injectBackup) && injectFile == "" {
+		usageExit("--inject-dry-run and --inject-backup need --inject")
+	}
+	if injectDryRun && injectBackup {
+		usageExit("--inject-dry-run writes nothing, so there is nothing for --inject-backup to save")
+	}
+
+	// The marked section of the --inject file is the only destination
+	if injectFile != "" {
+		if toStdout(outputLocations) {
+			usageExit(fmt.Sprintf("--inject writes the tree into %s, so it cannot also go to stdout; drop -o -/--stdout, or drop --inject and redirect stdout", injectFile))
+		}
+		if len(outputLocations) > 0 || postURL != "" || pipeCommand != "" {
+			usageExit("--inject cannot be combined with -o, --stdout, --copy, --post-url or --pipe")
+		}
+		if outputFormat != formatMarkdown && outputFormat != formatMarkdownList {
+			usageExit("--inject only works with -f md or -f md-list")
+		}
+		checkInjectTarget(injectFile)
+	}
+
+	// Set default output location if no destination was specified
+	if info, ok := formats[outputFormat]; ok && len(outputLocations) == 0 && postURL == "" && injectFile == "" {
+		outputLocations = append(outputLocations, defaultOutputPath(info.extensions[0]))
+	}
+	checkOutputExtensions(outputFormat, outputLocations)
+	if sidecarJSON || checkOutputs {
+		checkPairing(outputLocations)
+	}
+
+	if (outputFormat == formatMarkdown || outputFormat == formatText) && useColor(outputLocations) {
+		painter = ansiPainter{}
+	}
+	if outputFormat == formatMarkdown || outputFormat == formatText {
+		wrapColumns = terminalWrapWidth(outputLocations)
+	}
+	if preserveAnnotations {
+		source := annotationSource(outputLocations)
+		if source == "" {
+			usageExit("--preserve-annotations needs an -o file to read the annotations from")
+		}
+		loadAnnotations(source)
+	}
+	// Fail before the walk rather than after it; the write checks again
+	for _, location := range outputLocations {
+		if checkOutputs {
+			break
+		}
+		if err := checkOverwrite(location); err != nil {
+			errorExit(err.Error())
+		}
+		if err := checkOverwrite(sidecarPath(location)); sidecarJSON && err != nil {
+			errorExit(err.Error())
+		}
+	}
+
+	if rootPrefix != "" {
+		setRootPrefix(rootPrefix)
+	}
+	if !flagSet("dedupe-mounts") && isFilesystemRoot(scan.Root) {
+		dedupeMounts = true
+	}
+	if relativeTo != "" {
+		setRelativeTo(relativeTo)
+	}
+
+	// Docker reads only the .dockerignore at the root of the build context
+	if useDockerignore {
+		loadDockerignore(scan.Root)
+	}
+
+	// Restrict the tree to files changed since the given git ref
+	if changedSince != "" {
+		keepFilter, err = loadChangedSince(scan.Root, changedSince)
+		if err != nil {
+			errorExit(err.Error())
+		}
+	}
+
+	// Date files by their last commit, since a fresh clone gives everything the same mtime
+	if gitAge {
+		gitAgeTimes, err = loadGitAges(scan.Root)
+		if err != nil {
+			errorExit(err.Error())
+		}
+	}
+
+	// Mark working-tree changes; a config file shared with exports that are not
+	// repositories only fails the run when the flag was asked for directly
+	if gitStatus {
+		gitStatuses, gitDeleted, err = loadGitStatus(scan.Root)
+		if err != nil && gitStatusAsked {
+			errorExit(err.Error())
+		}
+		gitStatus = err == nil
+	}
+
+	if overlayPlan != "" {
+		if estimateMode || daemonMode || conformMode || diffMode || explainMode {
+			usageExit("--overlay only applies to the tree, not to ftg estimate, daemon, conform, diff or explain")
+		}
+		if overlayOps, err = loadPlan(overlayPlan); err != nil {
+			usageExit(fmt.Sprintf("invalid --overlay plan %s: %v", overlayPlan, err))
+		}
+	}
+
+	if resumeState != "" {
+		checkResume(estimateMode || daemonMode || conformMode || diffMode || explainMode)
+	}
+
+	if estimateMode {
+		if estimateBudget <= 0 {
+			usageExit("--estimate-budget must be positive")
+		}
+		runEstimate(scan.Root, estimateBudget)
+		return
+	}
+	if daemonMode {
+		if daemonRefresh < 0 {
+			usageExit("--refresh must not be negative")
+		}
+		runDaemon(scan.Root)
+		return
+	}
+	if conformMode {
+		if flag.NArg() != 1 {
+			usageExit("conform needs one layout template")
+		}
+		if outputFormat != formatMarkdown && outputFormat != formatJSON {
+			usageExit(fmt.Sprintf("conform reports as md or json, not %s", outputFormat))
+		}
+		runConform(scan.Root, flag.Arg(0))
+		return
 	}
-	defer func() {
-		if err := outputFile.Close(); err != nil {
-			log.Printf("Error closing file: %v", err)
+	if diffMode {
+		if flag.NArg() != 2 {
+			usageExit("diff needs two directories, or two -f paths snapshots with -f unified: ftg diff [options] old new")
+		}
+		if outputFormat == formatUnified {
+			if syncScript != "" {
+				usageExit("--emit-sync-script copies from the new directory, which -f unified snapshots do not have")
+			}
+			runPathsDiff(flag.Arg(0), flag.Arg(1))
+			return
+		}
+		if outputFormat != formatMarkdown && outputFormat != formatText && outputFormat != formatJSON {
+			usageExit(fmt.Sprintf("diff reports as md, text, json or unified, not %s", outputFormat))
+		}
+		switch syncScript {
+		case "", syncScriptSh, syncScriptPowerShell:
+		default:
+			usageExit(fmt.Sprintf("--emit-sync-script writes sh or powershell, not %s", syncScript))
+		}
+		if syncScript != "" && redactionEnabled() {
+			usageExit("--emit-sync-script cannot be combined with redaction: the script needs the real names")
 		}
-	}()
+		if includeDeletes && syncScript == "" {
+			usageExit("--include-deletes only works with --emit-sync-script")
+		}
+		runDiff(flag.Arg(0), flag.Arg(1))
+		return
+	}
+	if explainMode {
+		if flag.NArg() == 0 {
+			usageExit("explain needs at least one path")
+		}
+		prepareExplain()
+		for _, target := range flag.Args() {
+			explainPath(target)
+		}
+		return
+	}
 
-	// Write header to the output file
-	if _, err := fmt.Fprintf(outputFile, "# File Tree for %s\n\n## Give the project a star at %s\n```sh\n", inputDirectory, repository); err != nil {
-		errorExit(fmt.Sprintf("Error writing to output file: %v", err))
+	roots := scan.Root
+	if multiRoot() {
+		roots = strings.Join(inputRoots, ", ")
+	}
+	fmt.Fprintln(messages, msg("status.generating", roots, repository))
+	if resumeState != "" {
+		resumed = openResume(resumeState)
+	}
+	progress.line = wantProgressLine(cli.progressJSON)
+	if info, ok := formats[outputFormat]; ok && !flagSet("post-content-type") {
+		postContentType = info.contentType
 	}
 
-	// Read the input directory and generate the tree
-	entries, err := getEntries(inputDirectory)
+	// Render the tree once so every destination receives identical bytes
+	ctx := runContext()
+	var sidecar []byte
+	if sidecarJSON {
+		// First, so the render of the output leaves the state the summary reads
+		if sidecar, err = renderSidecar(ctx); ctx.Err() != nil {
+			cancelExit(ctx)
+		} else if err != nil {
+			errorExit(fmt.Sprintf("--sidecar: %v", err))
+		}
+	}
+	data, err := renderRoots(ctx, bare)
+	if ctx.Err() != nil {
+		cancelExit(ctx)
+	}
+	if err != nil {
+		errorExit(err.Error())
+	}
+	if resumed != nil {
+		resumed.finish()
+	}
+	if siteFormat(outputFormat) {
+		// The site is written into --output-dir as the tree is walked
+		finishRun(true)
+	}
+	clearProgressLine()
+	if selfCheck {
+		checkReproducible(ctx, data, bare)
+	}
+	if verifyRenderers {
+		checkRenderers(ctx)
+	}
+	data = fingerprinted(data, bare)
+	if historyFile != "" {
+		appendHistory(contentFingerprint(data))
+	}
+
+	startPhase("write")
+	if injectFile != "" {
+		// The injected section takes the line endings of the file around it
+		finishRun(injectTree(injectFile, data))
+	}
+	if useCRLF(outputFormat) {
+		data = toCRLF(data)
+	}
+	if checkOutputs {
+		checkFiles(outputLocations, data, sidecar)
+	}
+	if pipeCommand != "" {
+		finishRun(writePiped(outputLocations, data))
+	}
+	ok := writeOutputs(outputLocations, data)
+	if sidecarJSON {
+		ok = writeSidecars(outputLocations, sidecar) && ok
+	}
+	finishRun(ok)
+}
+
+// renderRun reads the input directory and renders the output of the chosen format.
+// It returns no output and no error once ctx is cancelled; any other failure is
+// returned for main to report, as nothing below it ends the process.
+func renderRun(ctx context.Context, bare bool) ([]byte, error) {
+	treeFS = rootFS(scan.Root)
+	scanStarted = time.Now()
+	if overlayPlan != "" {
+		overlay, err := overlayTree(scan.Root, treeFS)
+		if err != nil {
+			return nil, err
+		}
+		treeFS = overlay
+	}
+	startPhase("walk")
+	entries, err := scan.Walker.ReadDir(ctx, scan.Root)
+	if ctx.Err() != nil {
+		return nil, nil
+	}
 	if err != nil {
-		errorExit("Cannot read the input directory")
+		return nil, errors.New("cannot read the input directory")
+	}
+	if dedupeMounts {
+		resetVisitedDirs(scan.Root)
+	}
+	if autoDepth && !depthLimited() {
+		if scan.MaxDepth = chooseAutoDepth(scan.Root, entries); scan.MaxDepth > 0 {
+			autoDepthNote = msg("summary.autoDepth", scan.MaxDepth)
+		}
+	}
+	if scanBudget > 0 {
+		scanWithinBudget(scan.Root, entries, scanStarted)
+	} else {
+		prefetchListings(ctx, scan.Root, entries)
+	}
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+	if grepName != "" {
+		keepFilter = grepSelection(scan.Root, entries)
+	}
+	if pruneEmpty {
+		pruneTree(scan.Root, entries)
 	}
-	generateTree(outputFile, inputDirectory, "", entries)
+	if checksumAlgo != "" {
+		hashFiles(ctx, scan.Root, entries)
+	}
+	if checkLinks != "" {
+		collectLinks(scan.Root, entries)
+	}
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+	var data []byte
+	switch outputFormat {
+	case formatJSON:
+		data, err = renderJSON(ctx, scan.Root, entries)
+	case formatHTML:
+		data, err = renderHTML(ctx, scan.Root, entries)
+	case formatText:
+		data, err = renderText(ctx, scan.Root, entries)
+	case formatMermaid:
+		data, err = renderMermaid(ctx, scan.Root, entries, bare)
+	case formatMarkdownList:
+		data, err = renderMarkdownList(ctx, scan.Root, entries, bare)
+	case formatManifest:
+		data, err = renderManifest(ctx, scan.Root, entries)
+	case formatSVG:
+		data, err = renderSVG(ctx, scan.Root, entries)
+	case formatCSV, formatTSV:
+		data, err = renderCSV(ctx, scan.Root, entries)
+	case formatPaths:
+		data, err = renderPaths(ctx, scan.Root, entries)
+	case formatHTMLSite, formatMDSite:
+		if err = writeHTMLSite(ctx, outputDir, scan.Root, entries, outputFormat == formatMDSite); err == nil && ctx.Err() == nil {
+			writtenOutputs = append(writtenOutputs, outputDir)
+		}
+	default:
+		data, err = renderMarkdown(ctx, entries, bare)
+	}
+	if err != nil || ctx.Err() != nil {
+		return nil, err
+	}
+	// The hit counts the lint reads are complete once any format has walked the tree
+	if lintFilters {
+		reportFilterLint()
+	}
+	return data, nil
+}
 
-	// Close the code block in the output file
-	if _, err := fmt.Fprintln(outputFile, "```"); err != nil {
-		log.Printf("Error writing to output file: %v", err)
+// renderMarkdown renders the tree of the input directory as -f md, followed by the
+// summaries the options ask for
+func renderMarkdown(ctx context.Context, entries []fs.DirEntry, bare bool) ([]byte, error) {
+	var output bytes.Buffer
+	if !bare && injectFile == "" && !multiRoot() {
+		fmt.Fprintf(&output, "%s\n\n%s\n", headerTitle(scan.Root), msg("header.star", repository))
 	}
+	if groupBy != "" {
+		renderGroups(ctx, &output, scan.Root)
+	} else if overviewDepth > 0 {
+		renderOverview(ctx, &output, scan.Root, entries)
+	} else if bare {
+		generateTree(ctx, &output, scan.Root, entries)
+	} else {
+		fmt.Fprintln(&output, "```sh")
+		generateTree(ctx, &output, scan.Root, entries)
 
-	fmt.Printf("File tree has been written to %s\n", outputLocation)
-}
\ No newline at end of file
+		// Close the code block in the output
+		fmt.Fprintln(&output, "```")
+	}
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+	if !noSummary {
+		writeSummary(&output)
+		writeFilters(&output)
+	}
+	if autoDepthNote != "" {
+		fmt.Fprintf(&output, "\n%s\n", autoDepthNote)
+	}
+	if budgetNote != "" {
+		fmt.Fprintf(&output, "\n%s\n", budgetNote)
+	}
+	if grepName != "" {
+		fmt.Fprintf(&output, "\n%s\n", msg("summary.grep", grepName, groupThousands(grepMatches), msgCount("count.line", grepContext)))
+	}
+
+	startPhase("render")
+	writeCompleteness(&output)
+	writeReadErrors(&output)
+	if skipActive > 0 {
+		fmt.Fprintf(&output, "\n%s\n", msg("summary.inFlux", skipActive, groupThousands(inFluxCount)))
+	}
+	if findOrphans {
+		writeOrphanSummary(&output)
+	}
+	if usageEnabled {
+		writeUsage(&output)
+	}
+	if nameStatsEnabled {
+		writeNameStats(&output)
+	}
+	if skipped := skippedVirtualList(); len(skipped) > 0 {
+		fmt.Fprintf(&output, "\n%s\n", msg("summary.virtual", strings.Join(skipped, ", ")))
+	}
+	if provenanceFlag {
+		writeProvenance(&output, scan.Root, scanStarted, time.Now())
+	}
+	if len(retentionSpecs) > 0 {
+		writeRetention(&output, scan.Root)
+	}
+	if explainExcludes {
+		writeRuleReport(&output)
+	}
+	if checkLinks != "" {
+		writeBrokenLinks(&output)
+	}
+	if securityReport {
+		writeSecurityReport(&output)
+	}
+	if ownerBoundaries {
+		writeOwnerBoundaries(&output)
+	}
+	if anomaliesEnabled {
+		writeAnomalies(&output)
+	}
+	if preserveAnnotations {
+		writeOrphanedAnnotations(&output)
+	}
+	if redactionEnabled() {
+		fmt.Fprintf(&output, "\n%s\n", msg("summary.redacted", groupThousands(redactedCount)))
+	}
+
+	// Fences are widened before main adds the fingerprint, which covers them
+	return widenFences(output.Bytes()), nil
+}
@@ -0,0 +1,27 @@
+// Package ftree walks, filters and renders file trees, the core of the ftg command
+// line tool for use in other programs.
+//
+// A Generator renders one directory in a few formats:
+//
+//	g := ftree.Generator{Root: "src", Options: ftree.Options{Exclude: []string{"*.log"}, MaxDepth: 2}}
+//	if err := g.Generate(os.Stdout); err != nil {
+//		...
+//	}
+//
+// Exclusion patterns follow MatchPattern: a bare name hides entries with that
+// name at any depth, a path with "/" only the entry at that path from the root,
+// and "!pattern" keeps what the patterns after it would hide. DefaultExcludes
+// hide version control and build directories unless Options.NoDefaultExcludes is
+// set. Options.ExcludeFuncs add sources of their own, each an ExcludeFunc deciding
+// to Keep, Hide or Stub an entry or leaving it to the next: the patterns decide
+// first, then the funcs in order, then the defaults.
+//
+// Walk returns the same entries as a sequence for a range loop, and Generator.Walk
+// passes them to a callback that can prune directories with fs.SkipDir.
+//
+// Render writes a tree of Nodes in the same formats, for hierarchies that do not come
+// from a filesystem; Generate renders the Nodes of the walk with it.
+//
+// Walker is the traversal underneath, with hooks for reading, filtering and
+// descending; the command line renders every format from one.
+package ftree
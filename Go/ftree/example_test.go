@@ -0,0 +1,124 @@
+package ftree_test
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing/fstest"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// project is the filesystem the examples render
+var project = fstest.MapFS{
+	"README.md":             {Data: []byte("# Demo\n")},
+	"go.mod":                {Data: []byte("module demo\n")},
+	"cmd/demo/main.go":      {Data: []byte("package main\n")},
+	"internal/db/db.go":     {Data: []byte("package db\n")},
+	"node_modules/x/x.js":   {},
+	"testdata/big.golden":   {Data: make([]byte, 4096)},
+	"internal/db/db.go.bak": {},
+}
+
+func ExampleGenerator_Generate() {
+	g := ftree.Generator{Options: ftree.Options{FS: project, Exclude: []string{"*.bak", "testdata"}, Format: ftree.FormatText}}
+	if err := g.Generate(os.Stdout); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// .
+	// ├── README.md
+	// ├── cmd
+	// │   └── demo
+	// │       └── main.go
+	// ├── go.mod
+	// └── internal
+	//     └── db
+	//         └── db.go
+	//
+	// 4 directories, 4 files
+}
+
+func ExampleGenerator_Walk() {
+	g := ftree.Generator{Options: ftree.Options{FS: project}}
+	err := g.Walk(context.Background(), func(e ftree.Entry) error {
+		if e.Event != ftree.EventEntry {
+			return nil
+		}
+		if e.Name == "internal" {
+			return fs.SkipDir
+		}
+		fmt.Printf("%s%s %d\n", strings.Repeat("  ", e.Depth-1), e.Name, e.Size)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// README.md 7
+	// cmd -1
+	//   demo -1
+	//     main.go 13
+	// go.mod 12
+	// testdata -1
+	//   big.golden 4096
+}
+
+func ExampleWalk() {
+	// Find the first Go file below the root; nothing after it is read
+	for e, err := range ftree.Walk(context.Background(), ".", ftree.Options{FS: project}) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if strings.HasSuffix(e.Name, ".go") {
+			fmt.Println(e.Path)
+			break
+		}
+	}
+	// Output:
+	// cmd/demo/main.go
+}
+
+func ExampleRender() {
+	root := &ftree.Node{Name: "services", IsDir: true, Children: []*ftree.Node{
+		{Name: "search", IsDir: true, Children: []*ftree.Node{{Name: "indexer", Meta: map[string]string{"replicas": "2"}}}},
+		{Name: "billing", IsDir: true},
+	}}
+	if err := ftree.Render(os.Stdout, root, ftree.FormatMarkdown, ftree.RenderOptions{Sort: ftree.SortName}); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// # File Tree for services
+	//
+	// ```sh
+	// ├── [D] billing
+	// └── [D] search
+	//     └── [F] indexer (replicas: 2)
+	// ```
+}
+
+func ExampleRender_json() {
+	root := &ftree.Node{Name: "services", IsDir: true, Children: []*ftree.Node{
+		{Name: "search", IsDir: true, Meta: map[string]string{"owner": "discovery"}},
+	}}
+	if err := ftree.Render(os.Stdout, root, ftree.FormatJSON, ftree.RenderOptions{}); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// {
+	//   "name": "services",
+	//   "type": "dir",
+	//   "children": [
+	//     {
+	//       "name": "search",
+	//       "type": "dir",
+	//       "meta": {
+	//         "owner": "discovery"
+	//       }
+	//     }
+	//   ]
+	// }
+}
@@ -0,0 +1,74 @@
+package ftree
+
+import "io/fs"
+
+// Decision is what an exclusion source makes of an entry
+type Decision int
+
+const (
+	Undecided Decision = iota // No opinion; the next source decides
+	Keep                      // Shown, and entered if it is a directory
+	Hide                      // Left out of the tree
+	Stub                      // Shown but not entered, so a directory appears without its contents
+)
+
+// ExcludeFunc is a source of exclusions: it decides on the entry d at relPath, the
+// slash-separated path from the root, or returns Undecided to leave it to the
+// sources after it. It is called once per listed entry, before the entry is shown.
+type ExcludeFunc func(relPath string, d fs.DirEntry) Decision
+
+// Decide asks funcs in order and returns the first decision other than Undecided,
+// with the index of the func that made it, or Undecided and -1 when none decided.
+// The order is the precedence: a Keep from one source overrides a Hide from any
+// source after it, and the other way round.
+func Decide(funcs []ExcludeFunc, relPath string, d fs.DirEntry) (Decision, int) {
+	for i, fn := range funcs {
+		if decision := fn(relPath, d); decision != Undecided {
+			return decision, i
+		}
+	}
+	return Undecided, -1
+}
+
+// Compose returns the source that asks funcs in order, as Decide does
+func Compose(funcs ...ExcludeFunc) ExcludeFunc {
+	return func(relPath string, d fs.DirEntry) Decision {
+		decision, _ := Decide(funcs, relPath, d)
+		return decision
+	}
+}
+
+// ExcludeFunc returns the set as a source: the rule deciding an entry hides it, or
+// keeps it when it is an inclusion, and an entry no rule matches is Undecided
+func (s *RuleSet) ExcludeFunc() ExcludeFunc {
+	return func(relPath string, d fs.DirEntry) Decision {
+		i, ok := s.Match(relPath, d.Name())
+		switch {
+		case !ok:
+			return Undecided
+		case s.rules[i].Include:
+			return Keep
+		}
+		return Hide
+	}
+}
+
+// excludeFunc composes the sources of o in their precedence: the Exclude patterns,
+// so "!pattern" keeps what the others would hide, then ExcludeFuncs in order, then
+// DefaultExcludes, which every other source overrides
+func (o *Options) excludeFunc() ExcludeFunc {
+	var patterns, defaults RuleSet
+	for _, pattern := range o.Exclude {
+		if rule, ok := ParseRule(pattern); ok {
+			patterns.Add(rule)
+		}
+	}
+	funcs := append([]ExcludeFunc{patterns.ExcludeFunc()}, o.ExcludeFuncs...)
+	if !o.NoDefaultExcludes {
+		for _, name := range DefaultExcludes {
+			defaults.Add(Rule{Pattern: name})
+		}
+		funcs = append(funcs, defaults.ExcludeFunc())
+	}
+	return Compose(funcs...)
+}
@@ -0,0 +1,92 @@
+package ftree
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// decide returns an ExcludeFunc deciding the one path given, and counts its calls
+func decide(rel string, decision Decision, calls *int) ExcludeFunc {
+	return func(relPath string, d fs.DirEntry) Decision {
+		*calls++
+		if relPath == rel {
+			return decision
+		}
+		return Undecided
+	}
+}
+
+// The first source with an opinion decides, and the sources after it are not asked
+func TestDecideOrder(t *testing.T) {
+	fsys := testtree.MapFS(t, "a.txt\n")
+	entries, _ := fs.ReadDir(fsys, ".")
+	var first, second, third int
+	funcs := []ExcludeFunc{decide("b.txt", Hide, &first), decide("a.txt", Keep, &second), decide("a.txt", Hide, &third)}
+	if decision, i := Decide(funcs, "a.txt", entries[0]); decision != Keep || i != 1 {
+		t.Errorf("Decide = %v from %d, want Keep from 1", decision, i)
+	}
+	if first != 1 || second != 1 || third != 0 {
+		t.Errorf("calls %d, %d, %d, want 1, 1, 0", first, second, third)
+	}
+	if decision, i := Decide(funcs, "c.txt", entries[0]); decision != Undecided || i != -1 {
+		t.Errorf("nobody decides: Decide = %v from %d", decision, i)
+	}
+	if decision := Compose(funcs[2], funcs[1])("a.txt", entries[0]); decision != Hide {
+		t.Errorf("Compose in the other order = %v, want Hide", decision)
+	}
+}
+
+// The patterns decide first, then the funcs in order, then the defaults
+func TestOptionsExclusionPrecedence(t *testing.T) {
+	fsys := testtree.MapFS(t, `
+build/out.bin
+keep.log
+node_modules/x.js
+other.log
+src/main.go
+`)
+	var calls int
+	hide := func(rel string) ExcludeFunc { return decide(rel, Hide, &calls) }
+	keep := func(rel string) ExcludeFunc { return decide(rel, Keep, &calls) }
+	tests := []struct {
+		name string
+		opts Options
+		want string // Entries shown, space-separated
+	}{
+		{"funcs hide what no pattern decides", Options{ExcludeFuncs: []ExcludeFunc{hide("src")}},
+			"build build/out.bin keep.log other.log"},
+		{"an inclusion beats a func", Options{Exclude: []string{"!src"}, ExcludeFuncs: []ExcludeFunc{hide("src")}},
+			"build build/out.bin keep.log other.log src src/main.go"},
+		{"an exclusion beats a func", Options{Exclude: []string{"*.log"}, ExcludeFuncs: []ExcludeFunc{keep("keep.log")}},
+			"build build/out.bin src src/main.go"},
+		{"the later pattern wins", Options{Exclude: []string{"*.log", "!keep.log"}, ExcludeFuncs: []ExcludeFunc{hide("keep.log")}},
+			"build build/out.bin keep.log src src/main.go"},
+		{"a func beats the defaults", Options{ExcludeFuncs: []ExcludeFunc{keep("node_modules")}},
+			"build build/out.bin keep.log node_modules node_modules/x.js other.log src src/main.go"},
+		{"the first func wins", Options{ExcludeFuncs: []ExcludeFunc{keep("other.log"), hide("other.log")}},
+			"build build/out.bin keep.log other.log src src/main.go"},
+		{"a stub is shown but not entered", Options{ExcludeFuncs: []ExcludeFunc{decide("build", Stub, &calls)}},
+			"build keep.log other.log src src/main.go"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.FS = fsys
+			var shown []string
+			err := (&Generator{Options: test.opts}).Walk(t.Context(), func(e Entry) error {
+				if e.Event == EventEntry {
+					shown = append(shown, e.Path)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(shown, " "); got != test.want {
+				t.Errorf("shown %q, want %q", got, test.want)
+			}
+		})
+	}
+}
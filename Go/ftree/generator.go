@@ -0,0 +1,170 @@
+package ftree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Formats Generate writes
+const (
+	FormatMarkdown = "md"   // A heading and the tree in a code block, directories tagged [D] and files [F]
+	FormatText     = "text" // Laid out like the output of tree(1), with a count at the bottom
+	FormatJSON     = "json" // Nested objects with name, type and children
+)
+
+// Options are the settings of a tree. The zero value renders everything but
+// DefaultExcludes as markdown.
+type Options struct {
+	FS                fs.FS         // Read in place of the operating system's files, the root being a path in it
+	Exclude           []string      // Patterns hidden at any depth, as MatchPattern reads them; "!pattern" keeps what a later source hides
+	ExcludeFuncs      []ExcludeFunc // Sources of exclusions asked, in order, about what no pattern decides
+	NoDefaultExcludes bool          // Show what DefaultExcludes hide
+	MaxDepth          int           // Levels of directories read below the root; 0 reads them all
+	DirsOnly          bool          // Leave out everything but directories
+	Format            string        // FormatMarkdown, FormatText or FormatJSON; markdown when empty
+}
+
+// Generator renders the tree of one directory
+type Generator struct {
+	Root string // Directory rendered, "." when empty
+	Options
+
+	// Walker, when set, is the traversal in place of the one Options describe: its
+	// ReadDir lists Root as given, and FS, the exclusions, MaxDepth and DirsOnly are
+	// left to its hooks
+	Walker *Walker
+}
+
+// Generate writes the tree of g.Root to w. A root that cannot be read and an unknown
+// format are returned before anything is written. Directories below the root that
+// cannot be read are shown empty, entries with invalid names are left out, and
+// their errors, with those of entries whose size cannot be read, are returned
+// together once the tree is written.
+func (g *Generator) Generate(w io.Writer) error {
+	return g.GenerateContext(context.Background(), w)
+}
+
+// GenerateContext is Generate with a context; once ctx is done the walk stops and
+// nothing is written
+func (g *Generator) GenerateContext(ctx context.Context, w io.Writer) error {
+	format, err := checkFormat(g.Format)
+	if err != nil {
+		return err
+	}
+	top := &Node{Name: g.root(), IsDir: true}
+	parents := []*Node{top} // The directory whose entries the walk is in, last
+	var failed []error
+	err = g.Walk(ctx, func(e Entry) error {
+		parent := parents[len(parents)-1]
+		switch e.Event {
+		case EventEntry:
+			parent.Children = append(parent.Children, &Node{Name: e.Name, IsDir: e.IsDir()})
+			if e.Err != nil {
+				failed = append(failed, e.Err)
+			}
+		case EventOpen:
+			parents = append(parents, parent.Children[len(parent.Children)-1])
+		case EventClose:
+			parents = parents[:len(parents)-1]
+		case EventFailed, EventSkipped:
+			failed = append(failed, e.Err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := Render(w, top, format, RenderOptions{}); err != nil {
+		return err
+	}
+	return errors.Join(failed...)
+}
+
+// Walk calls fn for every entry of the tree Generate would write, in its order, as
+// Walker.Walk does: fs.SkipDir returned for EventEntry keeps a directory from being
+// read, any other error ends the walk and is returned, and so is the context's error
+// once ctx is done. A root that cannot be read is returned before fn is called.
+func (g *Generator) Walk(ctx context.Context, fn func(Entry) error) error {
+	walker, dir := g.walker(g.root())
+	entries, err := walker.ReadDir(ctx, dir)
+	if err != nil {
+		return err
+	}
+	return walker.Walk(ctx, dir, entries, fn)
+}
+
+// root returns the directory g renders
+func (g *Generator) root() string {
+	if g.Root == "" {
+		return "."
+	}
+	return g.Root
+}
+
+// walker returns the Walker of g's options and the path it reads root by
+func (g *Generator) walker(root string) (*Walker, string) {
+	if g.Walker != nil {
+		return g.Walker, root
+	}
+	fsys := g.FS
+	if fsys == nil {
+		fsys, root = os.DirFS(root), "."
+	}
+	exclude := g.excludeFunc()
+	stubs := map[string]bool{} // Directories shown without their contents, by path from the root
+	return &Walker{
+		ReadDir: func(ctx context.Context, dir string) ([]fs.DirEntry, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return ReadDir(fsys, dir)
+		},
+		List: func(dir string, entries []fs.DirEntry) Listing {
+			shown := entries[:0:0]
+			for _, d := range entries {
+				if g.DirsOnly && !d.IsDir() {
+					continue
+				}
+				rel := relPath(root, dir, d.Name())
+				switch exclude(rel, d) {
+				case Hide:
+					continue
+				case Stub:
+					stubs[rel] = true
+				}
+				shown = append(shown, d)
+			}
+			return Listing{Entries: shown}
+		},
+		Descend: func(e Entry) bool {
+			return e.IsDir() && !stubs[e.Path] && (g.MaxDepth <= 0 || e.Depth < g.MaxDepth)
+		},
+	}, root
+}
+
+// relPath returns the path from root of the entry name in dir, both paths of an fs.FS
+func relPath(root, dir, name string) string {
+	switch {
+	case dir == root:
+		return name
+	case root == ".":
+		return dir + "/" + name
+	}
+	return strings.TrimPrefix(dir, root+"/") + "/" + name
+}
+
+// checkFormat returns the format to render, markdown for ""
+func checkFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return FormatMarkdown, nil
+	case FormatMarkdown, FormatText, FormatJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q (use md, text or json)", format)
+}
@@ -0,0 +1,230 @@
+package ftree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/faultfs"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+const fixture = `
+README.md
+docs/guide.md
+node_modules/left-pad/index.js
+src/main.go
+src/main.log
+src/util/strings.go
+`
+
+// generate renders the fixture with opts and fails the test on an error
+func generate(t *testing.T, fsys fs.FS, opts Options) string {
+	t.Helper()
+	opts.FS = fsys
+	var out bytes.Buffer
+	if err := (&Generator{Options: opts}).Generate(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestGenerateFormats(t *testing.T) {
+	fsys := testtree.MapFS(t, fixture)
+	tests := []struct {
+		format, want string
+	}{
+		{FormatMarkdown, "# File Tree for .\n\n```sh\n" + `├── [F] README.md
+├── [D] docs
+│   └── [F] guide.md
+└── [D] src
+    ├── [F] main.go
+    ├── [F] main.log
+    └── [D] util
+        └── [F] strings.go
+` + "```\n"},
+		{FormatText, `.
+├── README.md
+├── docs
+│   └── guide.md
+└── src
+    ├── main.go
+    ├── main.log
+    └── util
+        └── strings.go
+
+3 directories, 5 files
+`},
+		{FormatJSON, `{
+  "name": ".",
+  "type": "dir",
+  "children": [
+    {
+      "name": "README.md",
+      "type": "file"
+    },
+    {
+      "name": "docs",
+      "type": "dir",
+      "children": [
+        {
+          "name": "guide.md",
+          "type": "file"
+        }
+      ]
+    }
+  ]
+}
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			opts := Options{Format: test.format}
+			if test.format == FormatJSON {
+				opts.Exclude = []string{"src"}
+			}
+			if got := generate(t, fsys, opts); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	fsys := testtree.MapFS(t, fixture)
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"exclude a name and a path", Options{Exclude: []string{"*.log", "src/util"}}, `├── README.md
+├── docs
+│   └── guide.md
+└── src
+    └── main.go
+`},
+		{"keep one of a glob", Options{Exclude: []string{"*.md", "!README.md"}}, `├── README.md
+├── docs
+└── src
+    ├── main.go
+    ├── main.log
+    └── util
+        └── strings.go
+`},
+		{"no default excludes", Options{NoDefaultExcludes: true, DirsOnly: true}, `├── docs
+├── node_modules
+│   └── left-pad
+└── src
+    └── util
+`},
+		{"max depth", Options{MaxDepth: 1}, `├── README.md
+├── docs
+└── src
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.Format = FormatText
+			got := generate(t, fsys, test.opts)
+			got = strings.Join(strings.Split(got, "\n")[1:], "\n")
+			got = got[:strings.Index(got, "\n\n")+1]
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// failingDirFS is fsys with the listing of one directory failing
+type failingDirFS struct {
+	fs.FS
+	dir string
+}
+
+// ReadDir fails for the chosen directory and reads fsys otherwise
+func (f failingDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	return fs.ReadDir(f.FS, name)
+}
+
+// Errors come back to the caller: an unknown format or unreadable root before any
+// output, an unreadable directory below the root after the rest of the tree
+func TestGenerateErrors(t *testing.T) {
+	fsys := testtree.MapFS(t, fixture)
+	var out bytes.Buffer
+	if err := (&Generator{Options: Options{FS: fsys, Format: "yaml"}}).Generate(&out); err == nil || out.Len() > 0 {
+		t.Errorf("format yaml: err %v, output %q", err, out.String())
+	}
+	if err := (&Generator{Root: "missing", Options: Options{FS: fsys}}).Generate(&out); !errors.Is(err, fs.ErrNotExist) || out.Len() > 0 {
+		t.Errorf("missing root: err %v, output %q", err, out.String())
+	}
+	err := (&Generator{Options: Options{FS: failingDirFS{fsys, "src/util"}, Format: FormatText}}).Generate(&out)
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("unreadable directory: err %v, want a permission error", err)
+	}
+	if !strings.Contains(out.String(), "    └── util\n\n3 directories") {
+		t.Errorf("unreadable directory: got\n%s", out.String())
+	}
+}
+
+// Faults met during the walk are returned with the tree written around them: a
+// nameless entry is left out, a directory turned file is reported as ErrChangedType
+// and an entry whose Info fails keeps its place
+func TestGenerateFaults(t *testing.T) {
+	tests := []struct {
+		path  string
+		fault faultfs.Fault
+		want  error
+		line  string // Line of the tree the fault leaves
+	}{
+		{"src/util", faultfs.EmptyName, ErrInvalidName, "    └── util\n        └── strings.go\n"},
+		{"src/util", faultfs.BecameFile, ErrChangedType, "    └── util\n\n3 directories"},
+		{"src/main.go", faultfs.InfoError, nil, "    ├── main.go\n"},
+	}
+	for _, test := range tests {
+		fsys := faultfs.New(testtree.MapFS(t, fixture), map[string]faultfs.Fault{test.path: test.fault})
+		var out bytes.Buffer
+		err := (&Generator{Options: Options{FS: fsys, Format: FormatText}}).Generate(&out)
+		if err == nil || test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%v at %s: err %v, want %v", test.fault, test.path, err, test.want)
+		}
+		if !strings.Contains(out.String(), test.line) {
+			t.Errorf("%v at %s: got\n%s", test.fault, test.path, out.String())
+		}
+	}
+}
+
+// Without an FS, Root is a directory of the operating system's
+func TestGenerateDirectory(t *testing.T) {
+	dir := testtree.Dir(t, fixture)
+	var out bytes.Buffer
+	if err := (&Generator{Root: dir, Options: Options{Format: FormatText, MaxDepth: 1}}).Generate(&out); err != nil {
+		t.Fatal(err)
+	}
+	if want := dir + "\n├── README.md\n├── docs\n└── src\n\n2 directories, 1 file\n"; out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+// A Walker of the caller's own takes the place of the one Options describe
+func TestGenerateWalker(t *testing.T) {
+	fsys := testtree.MapFS(t, fixture)
+	walker := &Walker{
+		ReadDir: func(_ context.Context, dir string) ([]fs.DirEntry, error) { return fs.ReadDir(fsys, dir) },
+		Descend: func(e Entry) bool { return e.Path == "src" },
+	}
+	var out bytes.Buffer
+	g := &Generator{Root: ".", Options: Options{MaxDepth: 1, Format: FormatText}, Walker: walker}
+	if err := g.Generate(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := ".\n├── README.md\n├── docs\n├── node_modules\n└── src\n    ├── main.go\n    ├── main.log\n    └── util\n\n4 directories, 3 files\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,46 @@
+package ftree
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// errStopped ends the walk under Walk once the loop ranging over it breaks
+var errStopped = errors.New("walk stopped")
+
+// Walk returns the entries of the tree of root with opts, in the order Generate
+// writes them, for a range loop:
+//
+//	for e, err := range ftree.Walk(ctx, "src", ftree.Options{}) {
+//		if err != nil {
+//			...
+//		}
+//		fmt.Println(e.Path)
+//	}
+//
+// Errors come in the sequence, and the walk goes on after them: an entry whose size
+// cannot be read comes with the error, a directory that cannot be read is yielded
+// again with its error after its entry, and an entry with an invalid name is
+// yielded only with the error, as EventSkipped. A root that cannot be read or a
+// done context ends the sequence with a last pair whose Entry is zero. Each entry is yielded before
+// its directory is read, and breaking out of the loop stops the walk there, without
+// reading another directory. To leave a subtree out instead, Stub it with an
+// ExcludeFunc.
+func Walk(ctx context.Context, root string, opts Options) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		g := Generator{Root: root, Options: opts}
+		err := g.Walk(ctx, func(e Entry) error {
+			switch {
+			case e.Event == EventEntry && !yield(e, e.Err):
+				return errStopped
+			case (e.Event == EventFailed || e.Event == EventSkipped) && !yield(e, e.Err):
+				return errStopped
+			}
+			return nil
+		})
+		if err != nil && err != errStopped {
+			yield(Entry{}, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ftree
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// The sequence holds the entries in render order, an unreadable directory in-band
+func TestWalkSequence(t *testing.T) {
+	fsys := failingDirFS{testtree.MapFS(t, walkFixture), "docs"}
+	var got []string
+	for e, err := range Walk(t.Context(), ".", Options{FS: fsys}) {
+		if err != nil {
+			if !errors.Is(err, fs.ErrPermission) {
+				t.Fatalf("%s: %v", e.Path, err)
+			}
+			got = append(got, "failed "+e.Path)
+			continue
+		}
+		got = append(got, e.Path)
+	}
+	if want := "a.txt docs failed docs src src/util src/util/strings.go z.txt"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+// Breaking out of the loop stops the walk before another directory is read
+func TestWalkBreak(t *testing.T) {
+	var reads atomic.Int32
+	fsys := countingFS{testtree.MapFS(t, walkFixture), &reads}
+	for e, err := range Walk(t.Context(), ".", Options{FS: fsys}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Path == "docs" {
+			break
+		}
+	}
+	if n := reads.Load(); n != 1 {
+		t.Errorf("%d directories read, want only the root", n)
+	}
+}
+
+// A cancelled context ends the sequence with its error, and nothing more is read
+func TestWalkSequenceCancelled(t *testing.T) {
+	var reads atomic.Int32
+	fsys := countingFS{testtree.MapFS(t, walkFixture), &reads}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	var paths []string
+	var last error
+	for e, err := range Walk(ctx, ".", Options{FS: fsys}) {
+		if err != nil {
+			last = err
+			continue
+		}
+		paths = append(paths, e.Path)
+		if e.Path == "docs" {
+			cancel()
+		}
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("last error %v, want context.Canceled", last)
+	}
+	if strings.Join(paths, " ") != "a.txt docs" || reads.Load() != 1 {
+		t.Errorf("entries %q and %d reads after the cancel", paths, reads.Load())
+	}
+}
+
+// A root that cannot be read is the one pair of the sequence
+func TestWalkMissingRoot(t *testing.T) {
+	var pairs int
+	for e, err := range Walk(t.Context(), "missing", Options{FS: testtree.MapFS(t, walkFixture)}) {
+		pairs++
+		if !errors.Is(err, fs.ErrNotExist) || e.Name != "" {
+			t.Errorf("got %+v, %v", e, err)
+		}
+	}
+	if pairs != 1 {
+		t.Errorf("%d pairs, want 1", pairs)
+	}
+}
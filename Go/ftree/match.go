@@ -0,0 +1,60 @@
+package ftree
+
+import (
+	"path"
+	"strings"
+)
+
+// IsLiteral reports whether a pattern is a plain name, which a RuleSet matches by lookup
+func IsLiteral(pattern string) bool {
+	return !strings.ContainsAny(pattern, "/*?[")
+}
+
+// MatchPattern reports whether an exclusion pattern matches the entry at rel, a
+// slash-separated path from the root, whose base name is name:
+//   - a pattern without "/" matches the name at any depth; "*", "?" and "[...]" are
+//     the wildcards of path.Match
+//   - a pattern containing "/" matches the whole of rel, a leading or trailing "/"
+//     being optional, with "**" spanning any number of directories as in MatchPath
+func MatchPattern(pattern, rel, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "/"), "/")
+	return MatchPath(pattern, rel)
+}
+
+// MatchPath matches a slash-separated path against a slash-separated glob. Each
+// segment is matched with path.Match, so "*" never crosses a "/", and a "**" segment
+// matches zero or more whole segments. A trailing "**" must match at least one
+// segment, so "a/**" matches everything inside a but not a itself.
+func MatchPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return len(name) > 0
+			}
+			for skip := 0; skip <= len(name); skip++ {
+				if matchSegments(rest, name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,64 @@
+package ftree
+
+import (
+	"path"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"node_modules", "node_modules", true},
+		{"node_modules", "web/node_modules", true},
+		{"*.log", "logs/build.log", true},
+		{"*.log", "build.log.gz", false},
+		{"src/gen", "src/gen", true},
+		{"src/gen", "lib/src/gen", false},
+		{"/src/gen/", "src/gen", true},
+		{"src/*/tmp", "src/a/tmp", true},
+		{"src/*/tmp", "src/a/b/tmp", false},
+		{"src/**/tmp", "src/tmp", true},
+		{"src/**/tmp", "src/a/b/tmp", true},
+		{"src/**", "src", false},
+		{"src/**", "src/a", true},
+	}
+	for _, test := range tests {
+		if got := MatchPattern(test.pattern, test.rel, path.Base(test.rel)); got != test.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", test.pattern, test.rel, got, test.want)
+		}
+	}
+}
+
+// The last matching rule decides, literal or not
+func TestRuleSetLastMatchWins(t *testing.T) {
+	var rules RuleSet
+	for _, pattern := range []string{"*.log", "!keep.log", "keep.log", "!debug.log", "logs/**"} {
+		rule, _ := ParseRule(pattern)
+		rules.Add(rule)
+	}
+	tests := []struct {
+		rel  string
+		want string // Pattern of the deciding rule, "" for none
+	}{
+		{"build.log", "*.log"},
+		{"keep.log", "keep.log"},
+		{"debug.log", "debug.log"},
+		{"logs/debug.log", "logs/**"},
+		{"main.go", ""},
+	}
+	for _, test := range tests {
+		i, ok := rules.Match(test.rel, path.Base(test.rel))
+		got := ""
+		if ok {
+			got = rules.Rules()[i].Pattern
+		}
+		if got != test.want {
+			t.Errorf("%s decided by %q, want %q", test.rel, got, test.want)
+		}
+	}
+	if _, ok := ParseRule("!"); ok {
+		t.Error(`ParseRule("!") reported a rule`)
+	}
+}
@@ -0,0 +1,187 @@
+package ftree
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Node is one entry of a tree to render, from a filesystem or built by hand
+type Node struct {
+	Name     string
+	IsDir    bool
+	Meta     map[string]string // Shown after the name as "(key: value, ...)", in key order; an object in JSON
+	Children []*Node           // Entries of a directory, in the order shown unless RenderOptions.Sort says otherwise
+}
+
+// Orders of RenderOptions.Sort, as ftg --sort names them
+const (
+	SortName       = "name"        // Case-insensitive by name
+	SortDirsFirst  = "dirs-first"  // Directories, then files, each case-insensitive
+	SortFilesFirst = "files-first" // Files, then directories, each case-insensitive
+)
+
+// RenderOptions are the settings of Render
+type RenderOptions struct {
+	Sort          string // SortName, SortDirsFirst or SortFilesFirst; "" keeps the order of Children
+	TrailingSlash bool   // End directory names with "/" in FormatText
+	Style         Style  // Connectors the lines are drawn with; Styles["default"] when zero
+}
+
+// Render writes the tree below root in a format of Generate, with the root as the
+// title of markdown, the first line of text and the top object of JSON. The tree is
+// checked first, and nothing is written when a node is nil, a name below the root is
+// empty or holds a "/", or two children of a directory share a name. Render does not
+// change the nodes.
+func Render(w io.Writer, root *Node, format string, opts RenderOptions) error {
+	format, err := checkFormat(format)
+	if err != nil {
+		return err
+	}
+	switch opts.Sort {
+	case "", SortName, SortDirsFirst, SortFilesFirst:
+	default:
+		return fmt.Errorf("unknown sort order %q (use name, dirs-first or files-first)", opts.Sort)
+	}
+	if root == nil {
+		return fmt.Errorf("the root node is nil")
+	}
+	if err := validate(root, root.Name); err != nil {
+		return err
+	}
+	if opts.Style == (Style{}) {
+		opts.Style = Styles["default"]
+	}
+	out := bufio.NewWriter(w)
+	switch format {
+	case FormatJSON:
+		data, err := json.MarshalIndent(jsonTree(root, opts), "", "  ")
+		if err != nil {
+			return err
+		}
+		out.Write(append(data, '\n'))
+	case FormatText:
+		fmt.Fprintln(out, root.Name+metaNote(root.Meta))
+		dirs, files := writeLines(out, root, "", false, opts)
+		fmt.Fprintf(out, "\n%s, %s\n", count(dirs, "directory", "directories"), count(files, "file", "files"))
+	default:
+		fmt.Fprintf(out, "# File Tree for %s\n\n```sh\n", root.Name)
+		writeLines(out, root, "", true, opts)
+		fmt.Fprintln(out, "```")
+	}
+	return out.Flush()
+}
+
+// validate checks the children of n, at rel, and everything below them
+func validate(n *Node, rel string) error {
+	seen := make(map[string]bool, len(n.Children))
+	for i, child := range n.Children {
+		switch {
+		case child == nil:
+			return fmt.Errorf("child %d of %q is nil", i, rel)
+		case child.Name == "":
+			return fmt.Errorf("child %d of %q has no name", i, rel)
+		case strings.Contains(child.Name, "/"):
+			return fmt.Errorf("name %q in %q contains a slash", child.Name, rel)
+		case seen[child.Name]:
+			return fmt.Errorf("%q has two children named %q", rel, child.Name)
+		}
+		seen[child.Name] = true
+		if err := validate(child, path.Join(rel, child.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sorted returns the children of n in the order of opts
+func sorted(n *Node, opts RenderOptions) []*Node {
+	if opts.Sort == "" {
+		return n.Children
+	}
+	children := slices.Clone(n.Children)
+	slices.SortStableFunc(children, func(a, b *Node) int {
+		if opts.Sort != SortName && a.IsDir != b.IsDir {
+			if a.IsDir == (opts.Sort == SortDirsFirst) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return children
+}
+
+// writeLines writes one connector line per entry below n, tagged [D] or [F] when
+// tags is set, and returns how many directories and files it wrote
+func writeLines(w io.Writer, n *Node, prefix string, tags bool, opts RenderOptions) (dirs, files int) {
+	children := sorted(n, opts)
+	for i, child := range children {
+		isLast := i == len(children)-1
+		name := child.Name
+		switch {
+		case tags && child.IsDir:
+			name = "[D] " + name
+		case tags:
+			name = "[F] " + name
+		case child.IsDir && opts.TrailingSlash:
+			name += "/"
+		}
+		fmt.Fprintf(w, "%s%s %s%s\n", prefix, opts.Style.Connector(isLast), name, metaNote(child.Meta))
+		if !child.IsDir {
+			files++
+			continue
+		}
+		d, f := writeLines(w, child, opts.Style.Indent(prefix, isLast), tags, opts)
+		dirs, files = dirs+d+1, files+f
+	}
+	return dirs, files
+}
+
+// metaNote returns the " (key: value, ...)" shown after a name, "" without metadata
+func metaNote(meta map[string]string) string {
+	if len(meta) == 0 {
+		return ""
+	}
+	var pairs []string
+	for _, key := range slices.Sorted(maps.Keys(meta)) {
+		pairs = append(pairs, key+": "+meta[key])
+	}
+	return " (" + strings.Join(pairs, ", ") + ")"
+}
+
+// count returns n with the singular or plural noun, e.g. "1 directory"
+func count(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// jsonNode is one entry of the JSON tree
+type jsonNode struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"` // "dir" or "file"
+	Meta     map[string]string `json:"meta,omitempty"`
+	Children []*jsonNode       `json:"children,omitempty"`
+}
+
+// jsonTree converts the tree below n for encoding
+func jsonTree(n *Node, opts RenderOptions) *jsonNode {
+	j := &jsonNode{Name: n.Name, Type: "file", Meta: n.Meta}
+	if n.IsDir {
+		j.Type = "dir"
+	}
+	for _, child := range sorted(n, opts) {
+		j.Children = append(j.Children, jsonTree(child, opts))
+	}
+	return j
+}
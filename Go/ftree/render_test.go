@@ -0,0 +1,159 @@
+package ftree
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// service is a hand-built tree with metadata, children out of order and mixed case
+func service() *Node {
+	return &Node{Name: "billing", IsDir: true, Children: []*Node{
+		{Name: "workers", IsDir: true, Children: []*Node{
+			{Name: "invoice", Meta: map[string]string{"replicas": "3", "owner": "payments"}},
+		}},
+		{Name: "README"},
+		{Name: "api", IsDir: true, Meta: map[string]string{"port": "8080"}, Children: []*Node{
+			{Name: "v2", IsDir: true},
+			{Name: "Routes.go"},
+			{Name: "auth.go"},
+		}},
+	}}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		sort, want string
+	}{
+		{"", `├── [D] workers
+│   └── [F] invoice (owner: payments, replicas: 3)
+├── [F] README
+└── [D] api (port: 8080)
+    ├── [D] v2
+    ├── [F] Routes.go
+    └── [F] auth.go
+`},
+		{SortName, `├── [D] api (port: 8080)
+│   ├── [F] auth.go
+│   ├── [F] Routes.go
+│   └── [D] v2
+├── [F] README
+└── [D] workers
+    └── [F] invoice (owner: payments, replicas: 3)
+`},
+		{SortDirsFirst, `├── [D] api (port: 8080)
+│   ├── [D] v2
+│   ├── [F] auth.go
+│   └── [F] Routes.go
+├── [D] workers
+│   └── [F] invoice (owner: payments, replicas: 3)
+└── [F] README
+`},
+	}
+	for _, test := range tests {
+		t.Run(test.sort, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Render(&out, service(), FormatMarkdown, RenderOptions{Sort: test.sort}); err != nil {
+				t.Fatal(err)
+			}
+			want := "# File Tree for billing\n\n```sh\n" + test.want + "```\n"
+			if out.String() != want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+			}
+		})
+	}
+}
+
+// The JSON tree decodes to the nodes it was rendered from, metadata included
+func TestRenderJSON(t *testing.T) {
+	var out bytes.Buffer
+	root := service()
+	if err := Render(&out, root, FormatJSON, RenderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var decoded jsonNode
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("%v in\n%s", err, out.String())
+	}
+	var toNode func(j *jsonNode) *Node
+	toNode = func(j *jsonNode) *Node {
+		n := &Node{Name: j.Name, IsDir: j.Type == "dir", Meta: j.Meta}
+		for _, child := range j.Children {
+			n.Children = append(n.Children, toNode(child))
+		}
+		return n
+	}
+	if got := toNode(&decoded); !reflect.DeepEqual(got, root) {
+		t.Errorf("decoded tree differs from the nodes:\n%s", out.String())
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	var out bytes.Buffer
+	if err := Render(&out, service(), FormatText, RenderOptions{Sort: SortFilesFirst, TrailingSlash: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := `billing
+├── README
+├── api/ (port: 8080)
+│   ├── auth.go
+│   ├── Routes.go
+│   └── v2/
+└── workers/
+    └── invoice (owner: payments, replicas: 3)
+
+3 directories, 4 files
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+// RenderOptions.Style draws the lines with another preset's connectors
+func TestRenderStyle(t *testing.T) {
+	var out bytes.Buffer
+	if err := Render(&out, service(), FormatText, RenderOptions{Sort: SortName, Style: Styles["double"]}); err != nil {
+		t.Fatal(err)
+	}
+	want := "billing\n╠══ api (port: 8080)\n║   ╠══ auth.go\n║   ╠══ Routes.go\n║   ╚══ v2\n╠══ README\n╚══ workers\n    ╚══ invoice (owner: payments, replicas: 3)\n\n3 directories, 4 files\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+// A malformed tree is refused with the place it went wrong, and nothing is written
+func TestRenderValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(root *Node)
+		want string
+	}{
+		{"nil child", func(root *Node) { root.Children[2].Children[1] = nil }, `child 1 of "billing/api" is nil`},
+		{"duplicate names", func(root *Node) { root.Children[0].Name = "README" }, `"billing" has two children named "README"`},
+		{"empty name", func(root *Node) { root.Children[0].Children[0].Name = "" }, `child 0 of "billing/workers" has no name`},
+		{"slash in a name", func(root *Node) { root.Children[1].Name = "docs/README" }, `name "docs/README" in "billing" contains a slash`},
+	}
+	for _, test := range tests {
+		for _, format := range []string{FormatMarkdown, FormatJSON} {
+			t.Run(test.name+" "+format, func(t *testing.T) {
+				root := service()
+				test.edit(root)
+				var out bytes.Buffer
+				err := Render(&out, root, format, RenderOptions{})
+				if err == nil || err.Error() != test.want {
+					t.Errorf("err = %v, want %s", err, test.want)
+				}
+				if out.Len() > 0 {
+					t.Errorf("wrote %q", out.String())
+				}
+			})
+		}
+	}
+	if err := Render(&bytes.Buffer{}, nil, FormatJSON, RenderOptions{}); err == nil {
+		t.Error("a nil root was rendered")
+	}
+	if err := Render(&bytes.Buffer{}, service(), FormatJSON, RenderOptions{Sort: "size"}); err == nil {
+		t.Error("an unknown sort order was accepted")
+	}
+}
@@ -0,0 +1,68 @@
+package ftree
+
+import "strings"
+
+// DefaultExcludes are the names hidden from every tree unless Options.NoDefaultExcludes is set
+var DefaultExcludes = []string{"node_modules", ".next", ".vscode", ".idea", ".git", "target", "Cargo.lock"}
+
+// Rule is one pattern of a RuleSet: an exclusion, or an inclusion written "!pattern"
+type Rule struct {
+	Pattern string // As matched by MatchPattern, without the "!"
+	Include bool   // Keeps what the pattern matches
+}
+
+// ParseRule reads an exclusion pattern, where a leading "!" makes an inclusion. It
+// reports false for a pattern that is empty once the "!" is removed.
+func ParseRule(pattern string) (Rule, bool) {
+	rule := Rule{Pattern: pattern}
+	if strings.HasPrefix(pattern, "!") {
+		rule.Pattern, rule.Include = pattern[1:], true
+	}
+	return rule, rule.Pattern != ""
+}
+
+// RuleSet holds rules in the order they were added; the last one that matches an
+// entry decides it, so a later pattern overrides an earlier one. Literal names are
+// looked up in a map, so a set of plain names costs the same however long it is.
+// The zero value is an empty set.
+type RuleSet struct {
+	rules    []Rule
+	literals map[string]int // Index of the last literal rule per name
+	globs    []int          // Indexes of the rules that need matching, in order
+}
+
+// Add appends a rule and returns its index
+func (s *RuleSet) Add(rule Rule) int {
+	s.rules = append(s.rules, rule)
+	i := len(s.rules) - 1
+	if IsLiteral(rule.Pattern) {
+		if s.literals == nil {
+			s.literals = map[string]int{}
+		}
+		s.literals[rule.Pattern] = i
+	} else {
+		s.globs = append(s.globs, i)
+	}
+	return i
+}
+
+// Rules returns the rules in the order they were added
+func (s *RuleSet) Rules() []Rule {
+	return s.rules
+}
+
+// Match returns the index of the last rule matching the entry at rel with base name
+// name, or false when none does
+func (s *RuleSet) Match(rel, name string) (int, bool) {
+	best := -1
+	if i, ok := s.literals[name]; ok {
+		best = i
+	}
+	for j := len(s.globs) - 1; j >= 0 && s.globs[j] > best; j-- {
+		if MatchPattern(s.rules[s.globs[j]].Pattern, rel, name) {
+			best = s.globs[j]
+			break
+		}
+	}
+	return best, best >= 0
+}
@@ -0,0 +1,34 @@
+package ftree
+
+// Style holds the strings a tree's structure is drawn with
+type Style struct {
+	Branch     string // Connector of an entry with more siblings below it
+	LastBranch string // Connector of the last entry of a directory
+	Pipe       string // Prefix continued under an entry with more siblings
+	Space      string // Prefix continued under the last entry of a directory
+}
+
+// Styles are the built-in presets, as ftg --style names them
+var Styles = map[string]Style{
+	"default": {Branch: "├──", LastBranch: "└──", Pipe: "│   ", Space: "    "},
+	"rounded": {Branch: "├──", LastBranch: "╰──", Pipe: "│   ", Space: "    "},
+	"double":  {Branch: "╠══", LastBranch: "╚══", Pipe: "║   ", Space: "    "},
+	// tree(1) pads its vertical lines with no-break spaces; ftg -f text uses this unless --style is given
+	"tree": {Branch: "├──", LastBranch: "└──", Pipe: "│\u00a0\u00a0 ", Space: "    "},
+}
+
+// Connector returns the connector drawn before an entry, the last of its directory or not
+func (s Style) Connector(isLast bool) string {
+	if isLast {
+		return s.LastBranch
+	}
+	return s.Branch
+}
+
+// Indent returns the prefix of the lines below an entry drawn after prefix
+func (s Style) Indent(prefix string, isLast bool) string {
+	if isLast {
+		return prefix + s.Space
+	}
+	return prefix + s.Pipe
+}
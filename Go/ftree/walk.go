@@ -0,0 +1,363 @@
+package ftree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Event says what a call of a walk's callback is about
+type Event int
+
+const (
+	EventEntry     Event = iota // An entry; a directory comes before it is read
+	EventOpen                   // A directory was read; its entries follow, then EventClose
+	EventClose                  // Every entry of a directory was visited
+	EventFailed                 // A directory could not be read; Err says why
+	EventElided                 // The listing left out Elided entries of Dir here
+	EventSkipped                // A listed entry of Dir cannot be shown, and is left out; Err says why
+	EventTruncated              // The listing stopped short: Elided entries of Dir follow the last one shown
+)
+
+// Errors a walk reports for entries the listings got wrong
+var (
+	ErrChangedType = errors.New("changed from a directory to a file since it was listed")
+	ErrInvalidName = errors.New("invalid name")
+)
+
+// Entry is one call of a walk's callback. Every event of an entry carries the entry;
+// EventElided has none and only sets Dir, Depth and Elided, EventTruncated the same and
+// IsLast, EventSkipped only Dir, Name, Depth and Err.
+type Entry struct {
+	Event    Event
+	Dir      string      // Directory holding the entry, as the walk's ReadDir takes it
+	Path     string      // Slash-separated path from the root of the walk
+	Name     string      // Base name
+	Depth    int         // 1 for the entries of the root
+	DirEntry fs.DirEntry // The entry as listed
+	Size     int64       // Size in bytes of anything but a directory, -1 when it cannot be read
+	IsLast   bool        // Last of the entries shown for its directory; for EventTruncated, no error follows
+	Descend  bool        // The directory is read after EventEntry unless the callback returns fs.SkipDir
+	Listed   int         // Entries the directory shows, for EventOpen; -1 when it is streamed
+	Elided   int         // Entries left out, for EventElided and EventTruncated
+	Err      error       // Why the directory could not be read, for EventFailed; why Size is -1, for EventEntry
+}
+
+// IsDir reports whether the entry is a directory as listed
+func (e Entry) IsDir() bool {
+	return e.DirEntry != nil && e.DirEntry.IsDir()
+}
+
+// Listing is what a directory shows: its entries in order and, when some were left
+// out of the middle, how many and before which entry, or when the listing stopped
+// short, how many it left out at the end
+type Listing struct {
+	Entries   []fs.DirEntry
+	ElideAt   int // Index of the entry the elision comes before, when Elided > 0
+	Elided    int
+	Truncated int
+}
+
+// Stream reads a directory too large to list whole, a batch at a time. Next returns
+// the next batch as the directory shows it, the way Walker.List would, and io.EOF
+// after the last; a batch does not elide. Close is called once the walk is done with
+// the directory.
+type Stream interface {
+	Next() (Listing, error)
+	Close() error
+}
+
+// Walker is the traversal every tree is rendered from. Its hooks decide where
+// directories are read and what they show; ReadDir must be set, and the others
+// default to showing and entering everything. Walk and Generator build one from
+// Options.
+type Walker struct {
+	ReadDir func(ctx context.Context, dir string) ([]fs.DirEntry, error) // Lists a directory
+	Join    func(dir, name string) string                                // Path ReadDir takes for an entry of dir; path.Join when nil
+	List    func(dir string, entries []fs.DirEntry) Listing              // What a directory shows; everything in listing order when nil
+	Descend func(e Entry) bool                                           // Whether a listed entry is read; directories are when nil
+	Info    func(d fs.DirEntry) (fs.FileInfo, error)                     // Reads the size of an entry; fs.DirEntry.Info when nil
+	Stream  func(ctx context.Context, dir string) (Stream, error)        // Reads a directory in batches, or nil to leave it to ReadDir; never when nil
+
+	queue *[]Entry // Directories WalkBreadthFirst has yet to read, nil for a depth-first walk
+}
+
+// Walk visits every entry below root that the listings show, in their order, and
+// descends where Descend says so. root is read already: entries is its listing.
+//
+// A directory gets EventEntry, then EventOpen, its entries and EventClose, or
+// EventFailed once it could not be read. A streamed directory whose reading fails part
+// way gets EventFailed after the entries read, then EventClose. Like
+// filepath.WalkDir, fs.SkipDir returned for EventEntry keeps a directory from being
+// read, and returned for any other event is ignored; any other error ends the walk
+// and is returned. The walk stops with the context's error once ctx is done, without
+// reading further directories.
+func (w *Walker) Walk(ctx context.Context, root string, entries []fs.DirEntry, fn func(Entry) error) error {
+	listing, err := w.list(root, 1, entries, fn)
+	if err != nil {
+		return err
+	}
+	return w.walk(ctx, root, "", 1, listing, fn)
+}
+
+// WalkBreadthFirst is Walk a level at a time: every entry of root, then the entries
+// of each directory among them in the order they were listed, then those of the
+// directories found there, and so on. The entries of each directory keep the order
+// of its listing.
+//
+// A directory gets EventEntry where its listing shows it and EventOpen, its entries
+// and EventClose, or EventFailed, once the walk gets to it, so the events no longer
+// nest. Directories wait in a queue until then, which holds up to a whole level of
+// the tree.
+func (w *Walker) WalkBreadthFirst(ctx context.Context, root string, entries []fs.DirEntry, fn func(Entry) error) error {
+	bfs := *w
+	bfs.queue = &[]Entry{}
+	if err := bfs.Walk(ctx, root, entries, fn); err != nil {
+		return err
+	}
+	for len(*bfs.queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e := (*bfs.queue)[0]
+		*bfs.queue = (*bfs.queue)[1:]
+		if err := bfs.enter(ctx, e, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// list is what a directory read whole shows, once the entries that cannot be shown
+// got EventSkipped
+func (w *Walker) list(dir string, depth int, entries []fs.DirEntry, fn func(Entry) error) (Listing, error) {
+	entries, err := w.valid(dir, depth, entries, fn)
+	if err != nil || w.List == nil {
+		return Listing{Entries: entries}, err
+	}
+	return w.List(dir, entries), nil
+}
+
+// valid returns the entries whose names can be path segments and sends EventSkipped
+// for the others
+func (w *Walker) valid(dir string, depth int, entries []fs.DirEntry, fn func(Entry) error) ([]fs.DirEntry, error) {
+	valid := entries[:0:0]
+	for _, d := range entries {
+		if d != nil && validName(d.Name()) {
+			valid = append(valid, d)
+			continue
+		}
+		e := Entry{Event: EventSkipped, Dir: dir, Depth: depth, Err: fmt.Errorf("%w: a nil entry in %q", ErrInvalidName, dir)}
+		if d != nil {
+			e.Name, e.Err = d.Name(), fmt.Errorf("%w %q in %q", ErrInvalidName, d.Name(), dir)
+		}
+		if err := notify(fn, e); err != nil {
+			return nil, err
+		}
+	}
+	return valid, nil
+}
+
+// walk visits the listing of one directory, dir at rel
+func (w *Walker) walk(ctx context.Context, dir, rel string, depth int, listing Listing, fn func(Entry) error) error {
+	for i, d := range listing.Entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if listing.Elided > 0 && i == listing.ElideAt {
+			if err := notify(fn, Entry{Event: EventElided, Dir: dir, Depth: depth, Elided: listing.Elided}); err != nil {
+				return err
+			}
+		}
+		if err := w.visit(ctx, dir, rel, depth, d, i == len(listing.Entries)-1 && listing.Truncated == 0, fn); err != nil {
+			return err
+		}
+	}
+	return w.truncated(dir, depth, listing.Truncated, true, fn)
+}
+
+// walkStream visits a streamed directory batch by batch. Each entry is held back
+// until the next one shows whether it is the last.
+func (w *Walker) walkStream(ctx context.Context, dir string, e Entry, stream Stream, fn func(Entry) error) error {
+	defer stream.Close()
+	e.Event, e.Err, e.Listed = EventOpen, nil, -1
+	if err := notify(fn, e); err != nil {
+		return err
+	}
+	var pending fs.DirEntry
+	truncated := 0
+	var readErr error
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		entries, err := w.valid(dir, e.Depth+1, batch.Entries, fn)
+		if err != nil {
+			return err
+		}
+		truncated += batch.Truncated
+		for _, d := range entries {
+			if pending != nil {
+				if err := w.visit(ctx, dir, e.Path, e.Depth+1, pending, false, fn); err != nil {
+					return err
+				}
+			}
+			pending = d
+		}
+	}
+	if pending != nil {
+		if err := w.visit(ctx, dir, e.Path, e.Depth+1, pending, readErr == nil && truncated == 0, fn); err != nil {
+			return err
+		}
+	}
+	if err := w.truncated(dir, e.Depth+1, truncated, readErr == nil, fn); err != nil {
+		return err
+	}
+	if readErr != nil {
+		failed := e
+		failed.Event, failed.Err = EventFailed, readErr
+		if err := notify(fn, failed); err != nil {
+			return err
+		}
+	}
+	e.Event = EventClose
+	return notify(fn, e)
+}
+
+// visit sends the events of one entry of dir and walks the directory it names
+func (w *Walker) visit(ctx context.Context, dir, rel string, depth int, d fs.DirEntry, isLast bool, fn func(Entry) error) error {
+	e := Entry{Event: EventEntry, Dir: dir, Path: path.Join(rel, d.Name()), Name: d.Name(), Depth: depth, DirEntry: d,
+		Size: -1, IsLast: isLast}
+	if w.Descend != nil {
+		e.Descend = w.Descend(e)
+	} else {
+		e.Descend = d.IsDir()
+	}
+	if !d.IsDir() {
+		if info, err := w.info(d); err != nil {
+			e.Err = err
+		} else if info != nil {
+			e.Size = info.Size()
+		}
+	}
+	err := fn(e)
+	switch {
+	case err == fs.SkipDir:
+		return nil
+	case err != nil:
+		return err
+	case !e.Descend:
+		return nil
+	case w.queue != nil:
+		*w.queue = append(*w.queue, e)
+		return nil
+	}
+	return w.enter(ctx, e, fn)
+}
+
+// enter reads the directory of an entry about to be descended into and walks it
+func (w *Walker) enter(ctx context.Context, e Entry, fn func(Entry) error) error {
+	sub := w.join(e.Dir, e.Name)
+	stream, entries, err := w.read(ctx, sub)
+	if ctx.Err() != nil {
+		if stream != nil {
+			stream.Close()
+		}
+		return ctx.Err()
+	}
+	if err != nil {
+		e.Event, e.Err = EventFailed, err
+		return notify(fn, e)
+	}
+	if stream != nil {
+		return w.walkStream(ctx, sub, e, stream, fn)
+	}
+	listing, err := w.list(sub, e.Depth+1, entries, fn)
+	if err != nil {
+		return err
+	}
+	e.Event, e.Err, e.Listed = EventOpen, nil, len(listing.Entries)
+	if err := notify(fn, e); err != nil {
+		return err
+	}
+	if err := w.walk(ctx, sub, e.Path, e.Depth+1, listing, fn); err != nil {
+		return err
+	}
+	e.Event = EventClose
+	return notify(fn, e)
+}
+
+// read lists a directory the walk enters: as a Stream where the Stream hook takes
+// it, whole through ReadDir otherwise
+func (w *Walker) read(ctx context.Context, dir string) (Stream, []fs.DirEntry, error) {
+	if w.Stream != nil {
+		if stream, err := w.Stream(ctx, dir); stream != nil || err != nil {
+			return stream, nil, err
+		}
+	}
+	entries, err := w.ReadDir(ctx, dir)
+	return nil, entries, err
+}
+
+// truncated sends EventTruncated for the entries a listing of dir left out at its end
+func (w *Walker) truncated(dir string, depth, n int, isLast bool, fn func(Entry) error) error {
+	if n == 0 {
+		return nil
+	}
+	return notify(fn, Entry{Event: EventTruncated, Dir: dir, Depth: depth, Elided: n, IsLast: isLast})
+}
+
+// notify calls fn for an event fs.SkipDir means nothing for
+func notify(fn func(Entry) error, e Entry) error {
+	if err := fn(e); err != fs.SkipDir {
+		return err
+	}
+	return nil
+}
+
+// join returns the path ReadDir takes for name inside dir
+func (w *Walker) join(dir, name string) string {
+	if w.Join != nil {
+		return w.Join(dir, name)
+	}
+	return path.Join(dir, name)
+}
+
+// info reads the file information of an entry
+func (w *Walker) info(d fs.DirEntry) (fs.FileInfo, error) {
+	if w.Info != nil {
+		return w.Info(d)
+	}
+	return d.Info()
+}
+
+// validName reports whether a listed name can be a path segment. Broken FUSE and
+// network filesystems can list empty or path-like names.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\x00")
+}
+
+// ReadDir is fs.ReadDir for a directory a listing showed. One that has since become a
+// file fails with ErrChangedType, in place of whatever fsys says about reading a file
+// as a directory.
+func ReadDir(fsys fs.FS, name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) {
+		if info, statErr := fs.Stat(fsys, name); statErr == nil && !info.IsDir() {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrChangedType}
+		}
+	}
+	return entries, err
+}
@@ -0,0 +1,325 @@
+package ftree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// countingFS counts the directories read from fsys
+type countingFS struct {
+	fs.FS
+	reads *atomic.Int32
+}
+
+// ReadDir counts the read and lists the directory
+func (c countingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	c.reads.Add(1)
+	return fs.ReadDir(c.FS, name)
+}
+
+// walkEvents runs Generator.Walk over fsys and returns one line per event until fn's
+// result ends the walk
+func walkEvents(ctx context.Context, t *testing.T, fsys fs.FS, fn func(Entry) error) ([]string, error) {
+	t.Helper()
+	var events []string
+	err := (&Generator{Options: Options{FS: fsys}}).Walk(ctx, func(e Entry) error {
+		switch e.Event {
+		case EventEntry:
+			events = append(events, fmt.Sprintf("entry %s depth %d size %d last %v", e.Path, e.Depth, e.Size, e.IsLast))
+		case EventOpen:
+			events = append(events, "open "+e.Path)
+		case EventClose:
+			events = append(events, "close "+e.Path)
+		case EventFailed:
+			events = append(events, "failed "+e.Path)
+		}
+		if fn == nil {
+			return nil
+		}
+		return fn(e)
+	})
+	return events, err
+}
+
+const walkFixture = `
+a.txt size=3
+docs/guide.md size=20
+src/
+src/util/strings.go size=100
+z.txt
+`
+
+// A directory gets its entry, then its listing between open and close, in tree order
+func TestWalkEventOrder(t *testing.T) {
+	events, err := walkEvents(t.Context(), t, testtree.MapFS(t, walkFixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `
+entry a.txt depth 1 size 3 last false
+entry docs depth 1 size -1 last false
+open docs
+entry docs/guide.md depth 2 size 20 last true
+close docs
+entry src depth 1 size -1 last false
+open src
+entry src/util depth 2 size -1 last true
+open src/util
+entry src/util/strings.go depth 3 size 100 last true
+close src/util
+close src
+entry z.txt depth 1 size 0 last true
+`
+	if got := strings.Join(events, "\n"); got != strings.TrimSpace(want) {
+		t.Errorf("events:\n%s\nwant:\n%s", got, strings.TrimSpace(want))
+	}
+}
+
+// A breadth-first walk visits a level at a time, each directory's entries in listing
+// order, and opens each directory once its level is done
+func TestWalkBreadthFirst(t *testing.T) {
+	fsys := testtree.MapFS(t, walkFixture+"docs/api/v1.md\n")
+	walker := &Walker{ReadDir: func(_ context.Context, dir string) ([]fs.DirEntry, error) { return fs.ReadDir(fsys, dir) }}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	err = walker.WalkBreadthFirst(t.Context(), ".", entries, func(e Entry) error {
+		switch e.Event {
+		case EventEntry:
+			events = append(events, fmt.Sprintf("entry %s depth %d last %v", e.Path, e.Depth, e.IsLast))
+		case EventOpen:
+			events = append(events, "open "+e.Path)
+		case EventClose:
+			events = append(events, "close "+e.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `
+entry a.txt depth 1 last false
+entry docs depth 1 last false
+entry src depth 1 last false
+entry z.txt depth 1 last true
+open docs
+entry docs/api depth 2 last false
+entry docs/guide.md depth 2 last true
+close docs
+open src
+entry src/util depth 2 last true
+close src
+open docs/api
+entry docs/api/v1.md depth 3 last true
+close docs/api
+open src/util
+entry src/util/strings.go depth 3 last true
+close src/util
+`
+	if got := strings.Join(events, "\n"); got != strings.TrimSpace(want) {
+		t.Errorf("events:\n%s\nwant:\n%s", got, strings.TrimSpace(want))
+	}
+	if walker.queue != nil {
+		t.Error("the walker keeps the breadth-first queue")
+	}
+}
+
+// fs.SkipDir from a directory's entry keeps it from being read, and the walk goes on
+func TestWalkSkipDir(t *testing.T) {
+	var reads atomic.Int32
+	events, err := walkEvents(t.Context(), t, countingFS{testtree.MapFS(t, walkFixture), &reads}, func(e Entry) error {
+		if e.Event == EventEntry && e.Path == "src" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(events, "\n")
+	if strings.Contains(joined, "src/util") || strings.Contains(joined, "open src") {
+		t.Errorf("src was read after fs.SkipDir:\n%s", joined)
+	}
+	if !strings.HasSuffix(joined, "entry z.txt depth 1 size 0 last true") {
+		t.Errorf("the walk stopped after fs.SkipDir:\n%s", joined)
+	}
+	if n := reads.Load(); n != 2 {
+		t.Errorf("%d directories read, want the root and docs", n)
+	}
+}
+
+// Any other error ends the walk at once and is returned
+func TestWalkStopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	events, err := walkEvents(t.Context(), t, testtree.MapFS(t, walkFixture), func(e Entry) error {
+		if e.Path == "docs/guide.md" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("err = %v, want %v", err, stop)
+	}
+	if last := events[len(events)-1]; !strings.HasPrefix(last, "entry docs/guide.md") {
+		t.Errorf("the walk went on to %q", last)
+	}
+}
+
+// A cancelled context ends the walk with its error, before another directory is read
+func TestWalkCancelled(t *testing.T) {
+	var reads atomic.Int32
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	events, err := walkEvents(ctx, t, countingFS{testtree.MapFS(t, walkFixture), &reads}, func(e Entry) error {
+		if e.Path == "docs" {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(events) != 2 || reads.Load() != 1 {
+		t.Errorf("%d directories read, events %q", reads.Load(), events)
+	}
+}
+
+// An unreadable directory gets EventFailed in place of its listing
+func TestWalkUnreadableDirectory(t *testing.T) {
+	var failure error
+	events, err := walkEvents(t.Context(), t, failingDirFS{testtree.MapFS(t, walkFixture), "src/util"}, func(e Entry) error {
+		if e.Event == EventFailed {
+			failure = e.Err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(failure, fs.ErrPermission) {
+		t.Errorf("EventFailed Err = %v, want a permission error", failure)
+	}
+	if joined := strings.Join(events, "\n"); !strings.Contains(joined, "entry src/util depth 2 size -1 last true\nfailed src/util\nclose src") {
+		t.Errorf("events:\n%s", joined)
+	}
+}
+
+// sliceStream streams batches, then fails with err or ends
+type sliceStream struct {
+	batches []Listing
+	err     error
+	closed  *bool
+}
+
+// Next returns the next batch, then err or io.EOF
+func (s *sliceStream) Next() (Listing, error) {
+	if len(s.batches) == 0 {
+		if s.err != nil {
+			return Listing{}, s.err
+		}
+		return Listing{}, io.EOF
+	}
+	batch := s.batches[0]
+	s.batches = s.batches[1:]
+	return batch, nil
+}
+
+// Close records that the walk is done with the stream
+func (s *sliceStream) Close() error {
+	*s.closed = true
+	return nil
+}
+
+// A streamed directory gets its batches as one listing, the last entry known only at
+// the end, and a listing cut short gets EventTruncated after its last entry
+func TestWalkStreamAndTruncated(t *testing.T) {
+	fsys := testtree.MapFS(t, walkFixture)
+	entries := func(names ...string) []fs.DirEntry {
+		var listed []fs.DirEntry
+		for _, d := range mustReadDir(t, fsys, "src") {
+			for _, name := range names {
+				if d.Name() == name {
+					listed = append(listed, d)
+				}
+			}
+		}
+		return listed
+	}
+	for _, test := range []struct {
+		name      string
+		truncated int
+		err       error
+		want      string
+	}{
+		{"complete", 0, nil, "open src listed -1\nentry src/util last false\nentry src/util last true\nclose src"},
+		{"truncated", 3, nil, "open src listed -1\nentry src/util last false\nentry src/util last false\ntruncated src 3 last true\nclose src"},
+		{"failing", 3, fs.ErrPermission, "open src listed -1\nentry src/util last false\nentry src/util last false\ntruncated src 3 last false\nfailed src\nclose src"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			closed := false
+			w := &Walker{
+				ReadDir: func(_ context.Context, dir string) ([]fs.DirEntry, error) { return fs.ReadDir(fsys, dir) },
+				List: func(dir string, entries []fs.DirEntry) Listing {
+					if dir == "." {
+						return Listing{Entries: entries[2:3], Truncated: 4}
+					}
+					return Listing{Entries: entries}
+				},
+				Descend: func(e Entry) bool { return e.Path == "src" },
+				Stream: func(_ context.Context, dir string) (Stream, error) {
+					if dir != "src" {
+						return nil, nil
+					}
+					batches := []Listing{{Entries: entries("util")}, {Entries: entries("util"), Truncated: test.truncated}}
+					return &sliceStream{batches, test.err, &closed}, nil
+				},
+			}
+			var events []string
+			err := w.Walk(t.Context(), ".", mustReadDir(t, fsys, "."), func(e Entry) error {
+				switch e.Event {
+				case EventEntry:
+					events = append(events, fmt.Sprintf("entry %s last %v", e.Path, e.IsLast))
+				case EventOpen:
+					events = append(events, fmt.Sprintf("open %s listed %d", e.Path, e.Listed))
+				case EventClose:
+					events = append(events, "close "+e.Path)
+				case EventFailed:
+					events = append(events, "failed "+e.Path)
+				case EventTruncated:
+					events = append(events, fmt.Sprintf("truncated %s %d last %v", e.Dir, e.Elided, e.IsLast))
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "entry src last false\n" + test.want + "\ntruncated . 4 last true"
+			if got := strings.Join(events, "\n"); got != want {
+				t.Errorf("events:\n%s\nwant:\n%s", got, want)
+			}
+			if !closed {
+				t.Error("the stream was not closed")
+			}
+		})
+	}
+}
+
+// mustReadDir lists a directory of fsys
+func mustReadDir(t *testing.T, fsys fs.FS, dir string) []fs.DirEntry {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
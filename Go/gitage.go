@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	gitAge      bool                 // Annotate files with their last commit time instead of trusting mtime
+	gitAgeTimes map[string]time.Time // Last commit time per path, relative to the input directory
+)
+
+// loadGitAges finds the last commit touching every tracked path below root with a
+// single git log run. Commits are listed newest first, so the first time a path
+// appears is its latest change. Renames are split into a delete and an add, which
+// makes the rename commit the last change of the new path and leaves the old,
+// no longer existing path harmlessly in the map.
+func loadGitAges(root string) (map[string]time.Time, error) {
+	if _, err := runGit(root, "rev-parse", "--show-toplevel"); err != nil {
+		return nil, fmt.Errorf("%s is not inside a git repository", root)
+	}
+	out, err := runGit(root, "log", "--format=%x01%ct", "--name-only", "--no-renames", "-z", "--relative", "--", ".")
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %v", err)
+	}
+
+	ages := map[string]time.Time{}
+	var current time.Time
+	for _, field := range strings.Split(string(out), "\x00") {
+		field = strings.TrimPrefix(field, "\n")
+		if strings.HasPrefix(field, "\x01") {
+			seconds, err := strconv.ParseInt(field[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected git log output %q", field)
+			}
+			current = time.Unix(seconds, 0)
+			continue
+		}
+		if _, seen := ages[field]; field != "" && !seen {
+			ages[field] = current
+		}
+	}
+	return ages, nil
+}
+
+// gitAgeNote returns "(committed <time>)" for tracked files and the mtime marked
+// untracked for everything else; directories are not annotated
+func gitAgeNote(rel string, entry fs.DirEntry) string {
+	if !gitAge || entry.IsDir() {
+		return ""
+	}
+	if committed, ok := gitAgeTimes[rel]; ok {
+		return "(committed " + formatTimestamp(committed) + ")"
+	}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return "(untracked)"
+	}
+	return "(modified " + formatTimestamp(info.ModTime()) + ", untracked)"
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Tracked files are dated by their last commit, a renamed one by the rename, and
+// untracked files by their mtime, marked; a run below the top of the repository
+// finds the same times
+func TestGitAge(t *testing.T) {
+	t.Setenv("TZ", "UTC")
+	t.Setenv("GIT_COMMITTER_DATE", "2020-01-02T03:04:05Z")
+	dir := gitRepo(t, "old.txt\nsrc/a.go\n")
+	git(t, dir, "mv", "old.txt", "new.txt")
+	t.Setenv("GIT_COMMITTER_DATE", "2021-03-04T05:06:07Z")
+	git(t, dir, "-c", "user.name=ftg", "-c", "user.email=ftg@example.com", "commit", "-q", "-m", "rename")
+	untracked := filepath.Join(dir, "untracked.txt")
+	if err := os.WriteFile(untracked, []byte("u\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2022, 5, 6, 7, 8, 9, 0, time.UTC)
+	if err := os.Chtimes(untracked, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "--git-age", "--time-format", "iso", "-o", "-", "--no-summary")
+	want := "├── [F] new.txt (committed 2021-03-04T05:06:07Z)\n" +
+		"├── [D] src\n" +
+		"│   └── [F] a.go (committed 2020-01-02T03:04:05Z)\n" +
+		"└── [F] untracked.txt (modified 2022-05-06T07:08:09Z, untracked)\n"
+	if code != exitOK || stdout != want {
+		t.Errorf("exit code %d, got\n%s%s\nwant\n%s", code, stdout, stderr, want)
+	}
+	stdout, stderr, code = runFTG(t, filepath.Join(dir, "src"), "-d", ".", "--git-age", "--time-format", "iso", "-o", "-", "--no-summary")
+	if want := "└── [F] a.go (committed 2020-01-02T03:04:05Z)\n"; code != exitOK || stdout != want {
+		t.Errorf("in src: exit code %d, got\n%s%s\nwant\n%s", code, stdout, stderr, want)
+	}
+}
+
+// Outside a git repository --git-age stops the run rather than date nothing
+func TestGitAgeNeedsRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir := testtree.Dir(t, "a.txt\n")
+	if _, stderr, code := runFTG(t, dir, "-d", ".", "--git-age", "-o", "-"); code != exitFatal || !strings.Contains(stderr, "is not inside a git repository") {
+		t.Errorf("exit code %d: %s", code, stderr)
+	}
+}
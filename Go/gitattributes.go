@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// attrRule is one pattern line of a .gitattributes file that mentions export-ignore
+type attrRule struct {
+	base     string // Directory of the .gitattributes file, relative to the input directory ("" for the root)
+	pattern  string // Pattern with any leading "/" removed
+	anchored bool   // Pattern contains a "/" and is matched against the path below base
+	set      bool   // export-ignore is set (true) or unset/unspecified (false)
+}
+
+var (
+	exportIgnore bool                      // Exclude paths marked export-ignore in .gitattributes
+	attrRules    = map[string][]attrRule{} // Parsed rules per directory, loaded as the walk reaches it
+)
+
+// parseAttributes reads the export-ignore rules of one .gitattributes file.
+// Unlike .gitignore there is no negation: "!export-ignore" and "-export-ignore"
+// both turn the attribute back off, and "[attr]" macro definitions are skipped.
+func parseAttributes(file, base string) []attrRule {
+	f, err := os.Open(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warnf("Cannot read %s: %v", file, err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var rules []attrRule
+	digest := sha256.New()
+	scanner := bufio.NewScanner(io.TeeReader(f, digest))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		pattern := fields[0]
+		if strings.HasPrefix(pattern, "!") {
+			// Negative patterns are forbidden in attribute files and git ignores them
+			continue
+		}
+		for _, attr := range fields[1:] {
+			switch attr {
+			case "export-ignore", "export-ignore=true":
+				rules = append(rules, newAttrRule(base, pattern, true))
+			case "-export-ignore", "!export-ignore":
+				rules = append(rules, newAttrRule(base, pattern, false))
+			}
+		}
+	}
+	recordIgnoreFile(displayPath(path.Join(base, ".gitattributes")), digest)
+	return rules
+}
+
+// newAttrRule normalizes a pattern the way git does for attribute files
+func newAttrRule(base, pattern string, set bool) attrRule {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	return attrRule{base: base, pattern: strings.TrimPrefix(pattern, "/"), anchored: anchored, set: set}
+}
+
+// matches reports whether the rule applies to rel, a path relative to the input directory
+func (r attrRule) matches(rel string) bool {
+	// A trailing slash never matches in attribute files; "dir/**" must be used instead
+	if strings.HasSuffix(r.pattern, "/") {
+		return false
+	}
+	sub := rel
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		sub = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(sub))
+		return ok
+	}
+	return ftree.MatchPath(r.pattern, sub)
+}
+
+// loadAttributes parses the .gitattributes of a directory once
+func loadAttributes(dir string) {
+	rel, err := filepath.Rel(scan.Root, dir)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	if _, ok := attrRules[rel]; ok {
+		return
+	}
+	attrRules[rel] = parseAttributes(filepath.Join(dir, ".gitattributes"), rel)
+}
+
+// exportIgnoreRule returns the rule deciding export-ignore for rel: deeper files beat
+// shallower ones and later lines beat earlier ones, so the last match in that order wins
+func exportIgnoreRule(rel string) (attrRule, bool) {
+	var winner attrRule
+	found := false
+	parts := strings.Split(rel, "/")
+	dirs := []string{""}
+	for i := 1; i < len(parts); i++ {
+		dirs = append(dirs, strings.Join(parts[:i], "/"))
+	}
+	for _, dir := range dirs {
+		for _, rule := range attrRules[dir] {
+			if rule.matches(rel) {
+				winner, found = rule, true
+			}
+		}
+	}
+	return winner, found
+}
+
+// attributesFile returns the .gitattributes path a rule was read from, relative to the input directory
+func (r attrRule) attributesFile() string {
+	return path.Join(r.base, ".gitattributes")
+}
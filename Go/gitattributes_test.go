@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// attributes are a root and a nested .gitattributes whose export-ignore rules differ
+// from .gitignore's: no negation, no directory-only patterns, and a deeper file
+// overriding a shallower one
+var attributes = map[string]string{
+	".gitattributes": "*.log export-ignore\n/build export-ignore\ndocs/ export-ignore\ntests/** export-ignore\n" +
+		"tests/keep.txt -export-ignore\n!secret export-ignore\nsub/*.md export-ignore\n",
+	"sub/.gitattributes": "*.log !export-ignore\nnotes.txt\texport-ignore\n",
+}
+
+// The export-ignore state of each path as git check-attr reports it: set, unset or
+// unspecified. Only set excludes the path.
+var attributeCases = map[string]string{
+	"a.log":              "set",
+	"sub/a.log":          "unspecified", // "!export-ignore" resets it, where "-" unsets it
+	"sub/deep/x.log":     "unspecified",
+	"build":              "set",
+	"sub/build":          "unspecified",
+	"docs":               "unspecified",
+	"tests/unit.go":      "set",
+	"tests/keep.txt":     "unset",
+	"secret":             "unspecified",
+	"sub/readme.md":      "set",
+	"sub/deep/readme.md": "unspecified",
+	"sub/notes.txt":      "set",
+	"notes.txt":          "unspecified",
+}
+
+// attributesDir writes the attributes files to a new directory
+func attributesDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range attributes {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExportIgnoreRules(t *testing.T) {
+	dir := attributesDir(t)
+	setOption(t, &scan.Root, dir)
+	setOption(t, &attrRules, map[string][]attrRule{})
+	for _, sub := range []string{"", "sub", "sub/deep", "tests"} {
+		loadAttributes(filepath.Join(dir, sub))
+	}
+	for rel, want := range attributeCases {
+		rule, ok := exportIgnoreRule(rel)
+		if excluded := ok && rule.set; excluded != (want == "set") {
+			t.Errorf("%s: excluded is %t, but export-ignore is %s", rel, excluded, want)
+		}
+	}
+}
+
+// The cases above are what git itself says
+func TestExportIgnoreCasesMatchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir := attributesDir(t)
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	for rel, want := range attributeCases {
+		out, err := exec.Command("git", "-C", dir, "check-attr", "export-ignore", "--", rel).Output()
+		if err != nil {
+			t.Fatalf("git check-attr %s: %v", rel, err)
+		}
+		if got := strings.TrimPrefix(strings.TrimSpace(string(out)), rel+": export-ignore: "); got != want {
+			t.Errorf("git check-attr says %s is %s, the case says %s", rel, got, want)
+		}
+	}
+}
+
+// --export-ignore leaves out the paths git archive would, and keeps the rest
+func TestExportIgnoreRun(t *testing.T) {
+	dir := attributesDir(t)
+	for _, name := range []string{"a.log", "build/out.bin", "sub/a.log", "sub/readme.md", "tests/unit.go", "tests/keep.txt", "docs/guide.md"} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "--export-ignore", "-o", "-")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	want := "├── [F] .gitattributes\n├── [D] docs\n│   └── [F] guide.md\n├── [D] sub\n│   ├── [F] .gitattributes\n│   └── [F] a.log\n└── [D] tests\n    └── [F] keep.txt\n"
+	if !strings.HasPrefix(stdout, want) {
+		t.Errorf("got\n%s\nwant\n%s", stdout, want)
+	}
+}
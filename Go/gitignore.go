@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// ignoreRule is one pattern line of a .gitignore file
+type ignoreRule struct {
+	base     string // Directory of the .gitignore file, relative to the input directory ("" for the root)
+	pattern  string // Pattern without leading "/", trailing "/" or "!"
+	negate   bool   // "!" re-includes what earlier rules ignored
+	dirOnly  bool   // Trailing "/" only matches directories
+	anchored bool   // Pattern contains a "/" and is matched against the path below base
+	line     int    // Line number, for explain
+}
+
+var (
+	useGitignore bool                        // Skip paths matched by .gitignore files
+	ignoreRules  = map[string][]ignoreRule{} // Parsed rules per directory, loaded as the walk reaches it
+)
+
+// parseGitignore reads the rules of one .gitignore file
+func parseGitignore(file, base string) []ignoreRule {
+	f, err := os.Open(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warnf("Cannot read %s: %v", file, err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	digest := sha256.New()
+	scanner := bufio.NewScanner(io.TeeReader(f, digest))
+	for line := 1; scanner.Scan(); line++ {
+		if rule, ok := newIgnoreRule(base, scanner.Text(), line); ok {
+			rules = append(rules, rule)
+		}
+	}
+	recordIgnoreFile(displayPath(path.Join(base, ".gitignore")), digest)
+	return rules
+}
+
+// newIgnoreRule parses one line using gitignore syntax
+func newIgnoreRule(base, text string, line int) (ignoreRule, bool) {
+	// Trailing spaces are ignored unless escaped with a backslash
+	for strings.HasSuffix(text, " ") && !strings.HasSuffix(text, "\\ ") {
+		text = text[:len(text)-1]
+	}
+	if text == "" || strings.HasPrefix(text, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base, line: line}
+	switch {
+	case strings.HasPrefix(text, "!"):
+		rule.negate = true
+		text = text[1:]
+	case strings.HasPrefix(text, `\!`), strings.HasPrefix(text, `\#`):
+		text = text[1:]
+	}
+	text = strings.ReplaceAll(text, `\ `, " ")
+	if strings.HasSuffix(text, "/") {
+		rule.dirOnly = true
+		text = strings.TrimRight(text, "/")
+	}
+	rule.anchored = strings.Contains(text, "/")
+	rule.pattern = strings.TrimPrefix(text, "/")
+	if rule.pattern == "" {
+		return ignoreRule{}, false
+	}
+	return rule, true
+}
+
+// matches reports whether the rule applies to rel, a path relative to the input directory
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	sub := rel
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		sub = strings.TrimPrefix(rel, r.base+"/")
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.pattern, path.Base(sub))
+		return ok
+	}
+	return ftree.MatchPath(r.pattern, sub)
+}
+
+// source describes where a rule came from, for explain
+func (r ignoreRule) source() string {
+	pattern := r.pattern
+	if r.anchored && !strings.Contains(pattern, "/") {
+		pattern = "/" + pattern
+	}
+	if r.negate {
+		pattern = "!" + pattern
+	}
+	if r.dirOnly {
+		pattern += "/"
+	}
+	return fmt.Sprintf("%s:%d (%s)", displayPath(path.Join(r.base, ".gitignore")), r.line, pattern)
+}
+
+// loadGitignore parses the .gitignore of a directory once
+func loadGitignore(dir string) {
+	rel, err := filepath.Rel(scan.Root, dir)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." {
+		rel = ""
+	}
+	if _, ok := ignoreRules[rel]; ok {
+		return
+	}
+	ignoreRules[rel] = parseGitignore(filepath.Join(dir, ".gitignore"), rel)
+}
+
+// gitignoreRule returns the rule deciding whether rel is ignored: deeper files beat
+// shallower ones and later lines beat earlier ones, so the last match in that order wins
+func gitignoreRule(rel string, isDir bool) (ignoreRule, bool) {
+	var winner ignoreRule
+	found := false
+	parts := strings.Split(rel, "/")
+	dirs := []string{""}
+	for i := 1; i < len(parts); i++ {
+		dirs = append(dirs, strings.Join(parts[:i], "/"))
+	}
+	for _, dir := range dirs {
+		for _, rule := range ignoreRules[dir] {
+			if rule.matches(rel, isDir) {
+				winner, found = rule, true
+			}
+		}
+	}
+	return winner, found
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// ignoreTree has a root .gitignore with anchored, directory-only, ** and negated
+// patterns, and a nested one that re-includes what the root ignores
+const ignoreTree = `
+.gitignore content="*.log\n/build/\ndocs/**/*.tmp\n!keep.log\n"
+a.log
+keep.log
+build/out.bin
+src/build/main.go
+docs/a/b.tmp
+docs/a/c.md
+sub/.gitignore content="!*.log\nsecret/\n"
+sub/debug.log
+sub/secret/key.txt
+sub/notes/secret
+zz.log
+`
+
+// -g follows gitignore semantics down the tree, nested files overriding their
+// parents, and entries it leaves out keep the last-child connectors right
+func TestGitignoreNestedAndNegation(t *testing.T) {
+	dir := testtree.Dir(t, ignoreTree)
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "-g", "-o", "-")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	want := `├── [F] .gitignore
+├── [D] docs
+│   └── [D] a
+│       └── [F] c.md
+├── [F] keep.log
+├── [D] src
+│   └── [D] build
+│       └── [F] main.go
+└── [D] sub
+    ├── [F] .gitignore
+    ├── [F] debug.log
+    └── [D] notes
+        └── [F] secret
+`
+	if !strings.HasPrefix(stdout, want) {
+		t.Errorf("got\n%s\nwant\n%s", stdout, want)
+	}
+}
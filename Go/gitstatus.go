@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	gitStatus      bool                       // --git-status: mark entries modified, added or deleted in the working tree
+	gitStatusAsked bool                       // --git-status came from the command line or --options-from, not just a config file
+	gitStatuses    map[string]string          // Status letter per path relative to the input directory
+	gitDeleted     map[string]map[string]bool // Deleted entries gone from disk by the relative path of their directory, true for directories
+)
+
+// loadGitStatus reads the working-tree status of everything below root with a single
+// git status run. Each path gets one letter: D for a tracked file that is gone from
+// disk, A for an added or untracked one, M for any other change, conflicts included.
+// Untracked directories are one entry. A deleted file whose directory is gone too is
+// recorded as its topmost missing directory, which the walk then shows as deleted.
+func loadGitStatus(root string) (map[string]string, map[string]map[string]bool, error) {
+	if err := checkGitInput(root); err != nil {
+		return nil, nil, err
+	}
+	prefix, err := runGit(root, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, nil, fmt.Errorf("--git-status needs a git repository, and %s is not inside one", root)
+	}
+	out, err := runGit(root, "status", "--porcelain", "-z", "--no-renames", "--untracked-files=normal", "--", ".")
+	if err != nil {
+		return nil, nil, fmt.Errorf("git status failed: %v", err)
+	}
+	statuses, deleted := map[string]string{}, map[string]map[string]bool{}
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) < 4 {
+			continue
+		}
+		x, y := field[0], field[1]
+		if x == 'R' || x == 'C' || y == 'R' || y == 'C' {
+			// The old path follows; --no-renames should leave none
+			i++
+		}
+		rel, ok := strings.CutPrefix(field[3:], strings.TrimSpace(string(prefix)))
+		if !ok {
+			continue
+		}
+		rel = strings.TrimSuffix(rel, "/")
+		switch {
+		case x == 'D' || y == 'D':
+			if missing, dir, ok := missingPath(root, rel); ok {
+				statuses[missing] = "D"
+				parent := path.Dir(missing)
+				if deleted[parent] == nil {
+					deleted[parent] = map[string]bool{}
+				}
+				deleted[parent][path.Base(missing)] = dir
+			}
+		case x == 'A' || x == '?':
+			statuses[rel] = "A"
+		default:
+			statuses[rel] = "M"
+		}
+	}
+	return statuses, deleted, nil
+}
+
+// missingPath returns the topmost part of rel that is not on disk and whether it is
+// a directory of rel; ok is false when rel is there after all, as after git rm --cached
+func missingPath(root, rel string) (string, bool, bool) {
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		if _, err := lstat(filepath.Join(root, filepath.FromSlash(prefix))); err != nil {
+			return prefix, i < len(parts)-1, true
+		}
+	}
+	return "", false, false
+}
+
+// gitStatusNote returns "(M)", "(A)" or "(D)" for an entry with a working-tree status
+func gitStatusNote(rel string) string {
+	if status := gitStatuses[rel]; status != "" {
+		return "(" + status + ")"
+	}
+	return ""
+}
+
+// deletedEntry is a tracked file or directory that is no longer on disk
+type deletedEntry struct {
+	name string
+	dir  bool
+}
+
+func (e deletedEntry) Name() string { return e.name }
+func (e deletedEntry) IsDir() bool  { return e.dir }
+
+// Type returns a directory or a regular file, as the entry was when it was tracked
+func (e deletedEntry) Type() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+// Info fails, since there is nothing left to describe
+func (e deletedEntry) Info() (fs.FileInfo, error) {
+	return nil, &fs.PathError{Op: "lstat", Path: e.name, Err: fs.ErrNotExist}
+}
+
+// isDeleted reports whether an entry is a deleted one added by --git-status
+func isDeleted(entry fs.DirEntry) bool {
+	_, deleted := entry.(deletedEntry)
+	return deleted
+}
+
+// withDeleted adds the deleted entries of a directory to its listing, in byte order
+// with the rest, so the filters and --sort treat them like any other entry
+func withDeleted(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	names := gitDeleted[relativePath(dir, "")]
+	if len(names) == 0 {
+		return entries
+	}
+	present := map[string]bool{}
+	for _, entry := range entries {
+		present[entry.Name()] = true
+	}
+	merged := append(entries[:0:0], entries...)
+	for name, isDir := range names {
+		if !present[name] {
+			merged = append(merged, deletedEntry{name, isDir})
+		}
+	}
+	if len(merged) == len(entries) {
+		return entries
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Name() < merged[j].Name()
+	})
+	return merged
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// gitRepo commits tree to a new repository and returns its directory
+func gitRepo(t *testing.T, tree string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir := testtree.Dir(t, tree)
+	git(t, dir, "init", "-q")
+	git(t, dir, "add", ".")
+	git(t, dir, "-c", "user.name=ftg", "-c", "user.email=ftg@example.com", "commit", "-q", "-m", "initial")
+	return dir
+}
+
+// git runs a git command in dir, failing the test when it fails
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// Modified, staged and untracked files and deleted ones are marked, an untracked
+// directory on itself only, and deleted entries are shown without a warning; a run
+// below the top of the repository marks paths relative to it the same way
+func TestGitStatus(t *testing.T) {
+	dir := gitRepo(t, "src/a.go\nsrc/b.go\ngone.txt\nold/x.txt\n")
+	if err := os.WriteFile(filepath.Join(dir, "src", "a.go"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"gone.txt", "old"} {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"new/x.txt", "new/y.txt", "staged.txt", "untracked.txt"} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(t, dir, "add", "staged.txt")
+
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "--git-status", "-o", "-", "--no-summary")
+	want := "├── [F] gone.txt (D)\n" +
+		"├── [D] new (A)\n" +
+		"│   ├── [F] x.txt\n" +
+		"│   └── [F] y.txt\n" +
+		"├── [D] old (D)\n" +
+		"├── [D] src\n" +
+		"│   ├── [F] a.go (M)\n" +
+		"│   └── [F] b.go\n" +
+		"├── [F] staged.txt (A)\n" +
+		"└── [F] untracked.txt (A)\n"
+	if code != exitOK || stdout != want {
+		t.Errorf("exit code %d, %s\ngot\n%s\nwant\n%s", code, stderr, stdout, want)
+	}
+
+	stdout, stderr, code = runFTG(t, filepath.Join(dir, "src"), "-d", ".", "--git-status", "-o", "-", "--no-summary")
+	if want := "├── [F] a.go (M)\n└── [F] b.go\n"; code != exitOK || stdout != want {
+		t.Errorf("below the top: exit code %d, %s\ngot\n%s\nwant\n%s", code, stderr, stdout, want)
+	}
+}
+
+// Outside a repository --git-status fails with a message, and a run without it does not
+func TestGitStatusNeedsRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir := testtree.Dir(t, "a.txt\n")
+	_, stderr, code := runFTG(t, dir, "-d", ".", "--git-status", "-o", "-")
+	if code != exitFatal || !strings.Contains(stderr, "--git-status needs a git repository") {
+		t.Errorf("exit code %d: %s", code, stderr)
+	}
+	if _, stderr, code := runFTG(t, dir, "-d", ".", "-o", "-"); code != exitOK {
+		t.Errorf("without --git-status: exit code %d: %s", code, stderr)
+	}
+}
+
+// A mistyped -d is named as missing, not as a directory outside a repository
+func TestGitInputMissing(t *testing.T) {
+	dir := gitRepo(t, "a.txt\n")
+	for _, flag := range [][]string{{"--git-status"}, {"--changed-since", "HEAD"}} {
+		_, stderr, code := runFTG(t, dir, append(flag, "-d", "missing", "-o", "-")...)
+		if code != exitFatal || !strings.Contains(stderr, "the input directory missing does not exist") {
+			t.Errorf("%s: exit code %d: %s", flag[0], code, stderr)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go
+
+go 1.25
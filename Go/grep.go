@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	grepName          string // Show only entries whose name contains this text, plus context
+	grepContext       int    // Tree lines of context shown before and after each match
+	grepIgnoreAccents bool   // Fold diacritics before matching
+	grepMatches       int    // Entries whose name matched
+)
+
+// accentFolds maps accented Latin letters to their unaccented base letter
+var accentFolds = map[rune]string{}
+
+func init() {
+	for base, accented := range map[string]string{
+		"a": "àáâãäåāăą", "c": "çćĉċč", "d": "ďđ", "e": "èéêëēĕėęě", "g": "ĝğġģ",
+		"h": "ĥħ", "i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀł", "n": "ñńņňŉ",
+		"o": "òóôõöøōŏő", "r": "ŕŗř", "s": "śŝşš", "t": "ţťŧ", "u": "ùúûüũūŭůűų",
+		"w": "ŵ", "y": "ýÿŷ", "z": "źżž", "ae": "æ", "oe": "œ", "ss": "ß",
+	} {
+		for _, r := range accented {
+			accentFolds[r] = base
+		}
+	}
+}
+
+// foldName lower-cases a name for matching and, with --grep-ignore-accents, strips diacritics
+func foldName(name string) string {
+	name = strings.ToLower(name)
+	if !grepIgnoreAccents {
+		return name
+	}
+	var b strings.Builder
+	for _, r := range name {
+		switch base, ok := accentFolds[r]; {
+		case ok:
+			b.WriteString(base)
+		case r >= 0x300 && r <= 0x36F:
+			// Combining marks, as in decomposed names from macOS filesystems
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// grepSelection walks the tree in render order and returns the paths to keep: every
+// entry whose name matches, grepContext lines on either side of it, and, through
+// keepSet, the ancestors of all of them. Listings are cached for the real walk.
+func grepSelection(root string, rootEntries []fs.DirEntry) keepSet {
+	needle := foldName(grepName)
+	var lines []string // Relative paths in the order the tree prints them
+	var matched []int  // Indexes into lines
+	var walk func(dir string, entries []fs.DirEntry)
+	walk = func(dir string, entries []fs.DirEntry) {
+		for _, entry := range visibleEntries(dir, entries) {
+			name := entry.Name()
+			rel := relativePath(dir, name)
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			if strings.Contains(foldName(name), needle) {
+				matched = append(matched, len(lines))
+			}
+			lines = append(lines, rel)
+			fullPath := filepath.Join(dir, name)
+			if !shouldDescend(fullPath, entry) {
+				continue
+			}
+			subEntries, ok := listingCache[fullPath]
+			if !ok {
+				var err error
+				if subEntries, err = readDir(fullPath); err != nil {
+					// The walk reads it again and reports the error
+					continue
+				}
+				listingCache[fullPath] = subEntries
+			}
+			walk(fullPath, subEntries)
+		}
+	}
+	walk(root, rootEntries)
+
+	grepMatches = len(matched)
+	selected := keepSet{}
+	for _, i := range matched {
+		for j := max(0, i-grepContext); j <= min(len(lines)-1, i+grepContext); j++ {
+			selected.add(lines[j], keepFilter[lines[j]])
+		}
+	}
+	return selected
+}
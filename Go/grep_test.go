@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// grepTree has matches in several directories, names with a precomposed and a
+// decomposed accent, and neighbours to serve as context across directory ends
+const grepTree = `
+billing/2023/Facture-02.pdf
+billing/2023/statement.pdf
+billing/2024/invoice-01.pdf
+billing/2024/notes.txt
+billing/2024/receipt.pdf
+docs/factúre.txt
+docs/fa` + "́" + `cture-scan.png
+docs/readme.md
+misc/a
+misc/b
+top.txt
+`
+
+// Only matches, their context lines and their parents are shown, with connectors
+// recomputed for what is left, and the summary counts the matches
+func TestGrepNameGolden(t *testing.T) {
+	dir := testtree.Dir(t, grepTree)
+	for _, test := range []struct {
+		golden string
+		args   []string
+	}{
+		{"grep-invoice", []string{"--grep-name", "INVOICE"}},
+		{"grep-invoice-context-2", []string{"--grep-name", "invoice", "--context", "2"}},
+		{"grep-facture", []string{"--grep-name", "facture"}},
+		{"grep-facture-accents", []string{"--grep-name", "facture", "--grep-ignore-accents"}},
+		{"grep-facture-accents-context-1", []string{"--grep-name", "factúre", "--grep-ignore-accents", "--context", "1"}},
+		{"grep-none", []string{"--grep-name", "nothing"}},
+	} {
+		t.Run(test.golden, func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, append([]string{"-d", ".", "-o", "-", "--quiet"}, test.args...)...)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			testtree.Golden(t, test.golden+".md", []byte(stdout))
+		})
+	}
+}
+
+// --context and --grep-ignore-accents only mean something with --grep-name, and a
+// negative --context is refused
+func TestGrepNameRefused(t *testing.T) {
+	dir := testtree.Dir(t, "a.txt\n")
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--context", "2"}, "--context and --grep-ignore-accents only work with --grep-name"},
+		{[]string{"--grep-ignore-accents"}, "--context and --grep-ignore-accents only work with --grep-name"},
+		{[]string{"--grep-name", "a", "--context", "-1"}, "--context must not be negative"},
+	} {
+		if _, stderr, code := runFTG(t, dir, append([]string{"-d", ".", "-o", "-"}, test.args...)...); code != exitUsage || !strings.Contains(stderr, test.want) {
+			t.Errorf("%q: exit code %d, %s; want %q", test.args, code, stderr, test.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	groupBy    string          // --group-by pivot: "", "owner" or "ext"
+	groupShown map[string]bool // Entries the group sections counted so far; nil outside them
+)
+
+// fileGroup is one section of a grouped document
+type fileGroup struct {
+	key   string
+	paths keepSet
+	files int
+	bytes int64
+}
+
+// groupKey returns the group a file belongs to under the current pivot
+func groupKey(rel string, info fs.FileInfo) string {
+	return pivotKey(groupBy, rel, info)
+}
+
+// pivotKey returns a file's owner or lower-cased extension, for --group-by and --usage-by
+func pivotKey(pivot, rel string, info fs.FileInfo) string {
+	if pivot == "owner" {
+		return fileOwner(info)
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(rel), "."))
+	if ext == "" || ext == strings.ToLower(strings.TrimPrefix(path.Base(rel), ".")) {
+		return "(no extension)"
+	}
+	return ext
+}
+
+// collectGroups walks the input directory with the normal exclusions and sorts every file into its group
+func collectGroups(ctx context.Context, dir string, groups map[string]*fileGroup) {
+	entries, err := getEntries(ctx, dir)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		warnf("%s", readDirError(dir, err))
+		countReadError(err)
+		return
+	}
+	// The exclusions are counted here, once, and not by the sections
+	for _, entry := range filterExcluded(dir, visibleEntries(dir, entries)) {
+		name := entry.Name()
+		if shouldDescend(filepath.Join(dir, name), entry) {
+			collectGroups(ctx, filepath.Join(dir, name), groups)
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+
+		info, err := entryInfo(entry)
+		if err != nil {
+			warnf("Cannot stat %s: %v", filepath.Join(dir, name), err)
+			continue
+		}
+		rel := relativePath(dir, name)
+		key := groupKey(rel, info)
+		group, ok := groups[key]
+		if !ok {
+			group = &fileGroup{key: key, paths: keepSet{}}
+			groups[key] = group
+		}
+		group.paths.add(rel, keepFilter[rel])
+		group.files++
+		group.bytes += info.Size()
+	}
+}
+
+// renderGroups writes one tree section per group, ordered by group name
+func renderGroups(ctx context.Context, writer io.Writer, root string) {
+	found := eachGroup(ctx, root, func(group *fileGroup, entries []fs.DirEntry) {
+		fmt.Fprintf(writer, "\n### %s: %s (%s, %s)\n```sh\n", groupBy, redactText(group.key), msgCount("count.file", group.files), formatSize(group.bytes))
+		if entries != nil {
+			generateTree(ctx, writer, root, entries)
+		}
+		fmt.Fprintln(writer, "```")
+	})
+	if !found {
+		fmt.Fprintf(writer, "\n%s\n", msg("group.none"))
+	}
+}
+
+// eachGroup collects the groups below root and calls render for each, ordered by
+// group name, with the tree narrowed to the group's files; entries is nil when root
+// can no longer be read. It reports whether there was any group.
+func eachGroup(ctx context.Context, root string, render func(group *fileGroup, entries []fs.DirEntry)) bool {
+	groups := map[string]*fileGroup{}
+	collectGroups(ctx, root, groups)
+
+	// The collection pass already counted every read and exclusion, and rendering
+	// re-reads the same directories, so only what the sections show is kept. A
+	// directory shown in several sections counts once.
+	saved := counters
+	saved.excludedBy = map[string]int{}
+	for pattern, hits := range counters.excludedBy {
+		saved.excludedBy[pattern] = hits
+	}
+	groupShown = map[string]bool{}
+	defer func() {
+		saved.dirs, saved.files, saved.bytes, saved.special = counters.dirs, counters.files, counters.bytes, counters.special
+		counters, groupShown = saved, nil
+	}()
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	outer := keepFilter
+	defer func() { keepFilter = outer }()
+	for _, key := range keys {
+		keepFilter = groups[key].paths
+		entries, err := getEntries(ctx, root)
+		if err != nil {
+			entries = nil
+		} else if dedupeMounts {
+			resetVisitedDirs(root)
+		}
+		render(groups[key], entries)
+	}
+	return len(keys) > 0
+}
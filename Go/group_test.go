@@ -0,0 +1,33 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// summaryLine returns the totals line of an md tree
+func summaryLine(t *testing.T, output string) string {
+	t.Helper()
+	line := regexp.MustCompile(`(?m)^Summary: .*$`).FindString(output)
+	if line == "" {
+		t.Fatalf("no summary in\n%s", output)
+	}
+	return line
+}
+
+// Directories repeat in every section they lead to but count once, and exclusions
+// count once for the whole run. empty/ holds no file, so no section shows it.
+func TestGroupedSummaryCountsWhatIsShown(t *testing.T) {
+	fsys := testtree.MapFS(t, fixture)
+	if got, want := summaryLine(t, renderFixture(t, fsys, false, nil)), "Summary: 4 directories, 7 files, 4.5 KB in total, 1 excluded"; got != want {
+		t.Errorf("ungrouped summary %q, want %q", got, want)
+	}
+	grouped := renderFixture(t, fsys, false, func() {
+		setOption(t, &groupBy, "ext")
+	})
+	if got, want := summaryLine(t, grouped), "Summary: 3 directories, 7 files, 4.5 KB in total, 1 excluded"; got != want {
+		t.Errorf("grouped summary %q, want %q", got, want)
+	}
+}
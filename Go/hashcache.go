@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// hashCacheVersion is the "v" of the digest cache; a cache of another version is discarded
+const hashCacheVersion = 1
+
+// hashCacheFlush is how often a long --checksum pass saves the digests it has so far,
+// so a run that is cancelled or killed resumes from there
+const hashCacheFlush = 30 * time.Second
+
+var (
+	hashCacheClear    bool   // --hash-cache-clear: delete the digest cache before the run
+	hashCacheLocation string // Digest cache file; the user cache directory's ftg/digests.json when empty
+	hashCache         *digestCache
+)
+
+// hashCacheStats counts how the pass's lookups went, for the status line after it and
+// --report-resources
+var hashCacheStats struct {
+	hits, misses, stale int // stale counts the misses of files changed since they were cached
+}
+
+// digestCache holds the --checksum digests of earlier runs by absolute path. A digest
+// is reused while the file has the size and modification time it was hashed with.
+type digestCache struct {
+	Version   int                     `json:"v"`
+	Algorithm string                  `json:"algorithm"` // A cache of another --checksum algorithm is discarded
+	Files     map[string]cachedDigest `json:"files"`
+	changed   bool                    // Digests were added since the cache was read
+}
+
+// cachedDigest is the digest of one file as it was when hashed
+type cachedDigest struct {
+	Size  int64  `json:"size"`
+	MTime int64  `json:"mtime"` // Unix nanoseconds
+	Sum   string `json:"sum"`
+}
+
+// hashCachePath returns where the digest cache lives
+func hashCachePath() (string, error) {
+	if hashCacheLocation != "" {
+		return hashCacheLocation, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "ftg", "digests.json"), nil
+}
+
+// clearHashCache deletes the digest cache for --hash-cache-clear
+func clearHashCache() {
+	location, err := hashCachePath()
+	if err != nil {
+		warnf("Warning: cannot find the digest cache: %v", err)
+		return
+	}
+	if err := os.Remove(location); err != nil && !os.IsNotExist(err) {
+		warnf("Warning: cannot delete the digest cache %s: %v", location, err)
+	}
+}
+
+// loadHashCache reads the digest cache for the --checksum algorithm. A missing cache,
+// or one for another algorithm or version, starts empty; one that cannot be parsed is
+// discarded with a warning and replaced when the pass saves.
+func loadHashCache() *digestCache {
+	empty := &digestCache{Version: hashCacheVersion, Algorithm: checksumAlgo, Files: map[string]cachedDigest{}}
+	location, err := hashCachePath()
+	if err != nil {
+		warnf("Warning: cannot find the digest cache, every file is hashed: %v", err)
+		return empty
+	}
+	data, err := os.ReadFile(location)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warnf("Warning: cannot read the digest cache %s, every file is hashed: %v", location, err)
+		}
+		return empty
+	}
+	var cache digestCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		warnf("Warning: discarding the digest cache %s, which cannot be parsed: %v", location, err)
+		empty.changed = true
+		return empty
+	}
+	if cache.Version != hashCacheVersion || cache.Algorithm != checksumAlgo || cache.Files == nil {
+		empty.changed = true
+		return empty
+	}
+	return &cache
+}
+
+// cacheKey returns the absolute path a file is cached under
+func cacheKey(fullPath string) string {
+	if abs, err := filepath.Abs(fullPath); err == nil {
+		return abs
+	}
+	return fullPath
+}
+
+// lookup returns the cached digest of a file when it still has the size and
+// modification time it was hashed with, and counts the outcome
+func (c *digestCache) lookup(fullPath string, info fs.FileInfo) (string, bool) {
+	cached, ok := c.Files[cacheKey(fullPath)]
+	switch {
+	case !ok:
+		hashCacheStats.misses++
+		return "", false
+	case cached.Size != info.Size() || cached.MTime != info.ModTime().UnixNano():
+		hashCacheStats.misses++
+		hashCacheStats.stale++
+		return "", false
+	}
+	hashCacheStats.hits++
+	return cached.Sum, true
+}
+
+// store records the digest a worker computed for a file
+func (c *digestCache) store(fullPath string, info fs.FileInfo, sum string) {
+	c.Files[cacheKey(fullPath)] = cachedDigest{Size: info.Size(), MTime: info.ModTime().UnixNano(), Sum: sum}
+	c.changed = true
+}
+
+// save writes the cache back when digests were added; a cache that cannot be written
+// only warns, as the next run hashes again
+func (c *digestCache) save() {
+	if !c.changed {
+		return
+	}
+	location, err := hashCachePath()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(location), 0o700)
+	}
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(c); err == nil {
+			err = writeAtomic(location, data)
+		}
+	}
+	if err != nil {
+		warnf("Warning: cannot save the digest cache: %v", err)
+		return
+	}
+	c.changed = false
+}
+
+// writeHashCacheStats prints how many digests came from the cache
+func writeHashCacheStats(writer io.Writer) {
+	fmt.Fprintf(writer, "Digest cache: %s, %s (%d stale)\n", treeCount(hashCacheStats.hits, "hit", "hits"),
+		treeCount(hashCacheStats.misses, "miss", "misses"), hashCacheStats.stale)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// useHashCache points the digest cache at a file of a temporary directory, read
+// afresh by the next pass
+func useHashCache(t *testing.T) string {
+	t.Helper()
+	location := filepath.Join(t.TempDir(), "ftg", "digests.json")
+	setOption(t, &hashCacheLocation, location)
+	setOption(t, &messages, io.Discard)
+	forgetHashCache(t)
+	return location
+}
+
+// forgetHashCache makes the next pass read the cache file again, with fresh counts
+func forgetHashCache(t *testing.T) {
+	t.Helper()
+	setOption(t, &hashCache, nil)
+	setOption(t, &hashCacheStats, hashCacheStats)
+	hashCacheStats.hits, hashCacheStats.misses, hashCacheStats.stale = 0, 0, 0
+}
+
+// hashRun renders fsys with --checksum sha256 as a new run would and returns the tree
+func hashRun(t *testing.T, fsys fstest.MapFS, algorithm string) string {
+	t.Helper()
+	forgetHashCache(t)
+	return renderFixture(t, fsys, true, func() {
+		setOption(t, &noSummary, true)
+		setOption(t, &checksumAlgo, algorithm)
+	})
+}
+
+// A digest is reused while the size and mtime of its file are unchanged, so the cached
+// one shows even for contents rewritten in place; a new mtime rehashes
+func TestHashCache(t *testing.T) {
+	location := useHashCache(t)
+	fsys := testtree.MapFS(t, `a.txt content="one"`+"\n"+`b.txt content="two"`+"\n")
+	first := hashRun(t, fsys, "sha256")
+	if got := hashCacheStats; got.hits != 0 || got.misses != 2 {
+		t.Errorf("first run: %+v, want two misses", got)
+	}
+	if _, err := os.Stat(location); err != nil {
+		t.Fatalf("the cache was not saved: %v", err)
+	}
+
+	fsys["a.txt"].Data = []byte("new") // Same size and mtime: the cache cannot tell
+	if got := hashRun(t, fsys, "sha256"); got != first || hashCacheStats.hits != 2 || hashCacheStats.misses != 0 {
+		t.Errorf("hit: %+v\n%s\nwant the first run's digests\n%s", hashCacheStats, got, first)
+	}
+
+	fsys["a.txt"].ModTime = testtree.ModTime.Add(time.Second)
+	got := hashRun(t, fsys, "sha256")
+	if hashCacheStats.hits != 1 || hashCacheStats.misses != 1 || hashCacheStats.stale != 1 {
+		t.Errorf("stale mtime: %+v, want a hit and a stale miss", hashCacheStats)
+	}
+	sum := sha256.Sum256([]byte("new"))
+	if digest := hex.EncodeToString(sum[:]); !strings.Contains(got, "[F] a.txt sha256:"+digest[:8]+"…"+digest[56:]) {
+		t.Errorf("stale mtime: got\n%s\nwant the digest of the new contents", got)
+	}
+
+	hashRun(t, fsys, "md5")
+	if hashCacheStats.hits != 0 || hashCacheStats.misses != 2 {
+		t.Errorf("another algorithm: %+v, want every file hashed", hashCacheStats)
+	}
+	hashRun(t, fsys, "sha256")
+	if hashCacheStats.misses != 2 {
+		t.Errorf("back to sha256: %+v, want the md5 cache discarded", hashCacheStats)
+	}
+}
+
+// A cache that cannot be parsed is discarded with a warning and replaced
+func TestHashCacheCorrupted(t *testing.T) {
+	location := useHashCache(t)
+	fsys := testtree.MapFS(t, `a.txt content="one"`+"\n")
+	want := hashRun(t, fsys, "sha256")
+	for _, corrupt := range []string{`{"v":1,"algorithm":"sha256","files":{"x":`, "\x00\x01", `{"v":1,"algorithm":"sha256","files":[]}`} {
+		if err := os.WriteFile(location, []byte(corrupt), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		logs := captureWarnings(t)
+		if got := hashRun(t, fsys, "sha256"); got != want || hashCacheStats.misses != 1 {
+			t.Errorf("%q: %+v\n%s\nwant a fresh digest\n%s", corrupt, hashCacheStats, got, want)
+		}
+		if !strings.Contains(logs.String(), "discarding the digest cache") {
+			t.Errorf("%q: warnings %q, want the cache discarded", corrupt, logs)
+		}
+		hashRun(t, fsys, "sha256")
+		if hashCacheStats.hits != 1 {
+			t.Errorf("%q: the replacement cache was not saved: %+v", corrupt, hashCacheStats)
+		}
+	}
+}
+
+// --hash-cache-clear deletes the cache, and a missing one is no error
+func TestHashCacheClear(t *testing.T) {
+	location := useHashCache(t)
+	hashRun(t, testtree.MapFS(t, "a.txt\n"), "sha256")
+	logs := captureWarnings(t)
+	clearHashCache()
+	clearHashCache()
+	if _, err := os.Stat(location); !os.IsNotExist(err) {
+		t.Errorf("the cache is still there: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("warnings %q", logs)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// Values of --hidden
+const (
+	hiddenShow = "show"
+	hiddenHide = "hide"
+)
+
+// hiddenLabel is the key dotfiles hidden by --hidden=hide are counted under
+const hiddenLabel = "dotfiles (--hidden=hide)"
+
+var (
+	hiddenMode      = hiddenShow // --hidden: show or hide entries whose names start with a dot
+	includePatterns []string     // --include: dotfiles kept by --hidden=hide, same syntax as -e
+)
+
+// parseHidden validates --hidden
+func parseHidden(mode string) error {
+	if mode != hiddenShow && mode != hiddenHide {
+		return fmt.Errorf("unknown --hidden value %q (use show or hide)", mode)
+	}
+	hiddenMode = mode
+	return nil
+}
+
+// dotfileHidden reports whether --hidden=hide leaves out an entry: its name starts
+// with a dot and no --include pattern lists it. Like an exclusion, it hides a
+// directory's whole subtree.
+func dotfileHidden(rel, name string) bool {
+	if hiddenMode != hiddenHide || !strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, pattern := range includePatterns {
+		if ftree.MatchPattern(pattern, matchPath(rel), name) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// --hidden=hide leaves out dotfiles and what is below hidden directories, except
+// those --include names; a file --include names below a hidden directory stays out
+// with it
+func TestHiddenInclude(t *testing.T) {
+	src := testtree.Dir(t, ".github/workflows/ci.yml\n.cache/x\n.env\n.editorconfig\nsrc/.hidden\nsrc/main.go\n")
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "├── [D] .cache\n" +
+			"│   └── [F] x\n" +
+			"├── [F] .editorconfig\n" +
+			"├── [F] .env\n" +
+			"├── [D] .github\n" +
+			"│   └── [D] workflows\n" +
+			"│       └── [F] ci.yml\n" +
+			"└── [D] src\n" +
+			"    ├── [F] .hidden\n" +
+			"    └── [F] main.go\n"},
+		{[]string{"--hidden=hide"}, "└── [D] src\n" +
+			"    └── [F] main.go\n"},
+		{[]string{"--hidden=hide", "--include", ".github"}, "├── [D] .github\n" +
+			"│   └── [D] workflows\n" +
+			"│       └── [F] ci.yml\n" +
+			"└── [D] src\n" +
+			"    └── [F] main.go\n"},
+		{[]string{"--hidden=hide", "--include", ".github,.e*"}, "├── [F] .editorconfig\n" +
+			"├── [F] .env\n" +
+			"├── [D] .github\n" +
+			"│   └── [D] workflows\n" +
+			"│       └── [F] ci.yml\n" +
+			"└── [D] src\n" +
+			"    └── [F] main.go\n"},
+		{[]string{"--hidden=hide", "--include", "x"}, "└── [D] src\n" +
+			"    └── [F] main.go\n"},
+	} {
+		stdout, stderr, code := runFTG(t, t.TempDir(), append(test.args, "-d", src, "-o", "-", "--no-summary")...)
+		if code != exitOK || stdout != test.want {
+			t.Errorf("%q: exit code %d, %s\ngot\n%s\nwant\n%s", test.args, code, stderr, stdout, test.want)
+		}
+	}
+}
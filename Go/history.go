@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyVersion is the "v" field of every history record; readers skip newer versions
+const historyVersion = 1
+
+// historyRecord is one line of a --history log:
+//
+//	{"v":1,"time":"2026-10-14T08:30:00Z","root":"/src/app","dirs":12,"files":240,"bytes":1048576,
+//	 "fingerprint":"sha256:…","top":{"src":900000,"docs":148576}}
+//
+// With --history-detail changes the record also lists the paths added and removed
+// since the last detailed record, whose fingerprint is given as "base". Directories
+// end in "/". The first detailed record has no base and lists every path as added,
+// so replaying the detailed records in order rebuilds any earlier snapshot.
+type historyRecord struct {
+	Version     int              `json:"v"`
+	Time        time.Time        `json:"time"`
+	Root        string           `json:"root"`
+	Dirs        int              `json:"dirs"`
+	Files       int              `json:"files"`
+	Bytes       int64            `json:"bytes"`
+	Fingerprint string           `json:"fingerprint"`
+	Top         map[string]int64 `json:"top"`
+	Detail      string           `json:"detail,omitempty"`
+	Base        string           `json:"base,omitempty"`
+	Added       []string         `json:"added,omitempty"`
+	Removed     []string         `json:"removed,omitempty"`
+}
+
+var (
+	historyFile   string               // NDJSON log appended to with --history
+	historyDetail = "summary"          // summary, or changes to record per-path adds and removes
+	historyPaths  = map[string]int64{} // Rendered paths with their sizes, directories ending in "/"
+)
+
+// recordHistory remembers a rendered entry for the history record
+func recordHistory(dir string, entry fs.DirEntry) {
+	if historyFile == "" {
+		return
+	}
+	rel := relativePath(dir, entry.Name())
+	if entry.IsDir() {
+		historyPaths[rel+"/"] = 0
+		return
+	}
+	var size int64
+	if info, err := entryInfo(entry); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+	}
+	historyPaths[rel] = size
+}
+
+// newHistoryRecord summarizes the recorded paths
+func newHistoryRecord(fingerprint string) historyRecord {
+	record := historyRecord{
+		Version:     historyVersion,
+		Time:        time.Now().UTC().Truncate(time.Second),
+		Root:        redactPath(virtualPath(scan.Root)),
+		Fingerprint: fingerprint,
+		Top:         map[string]int64{},
+	}
+	for rel, size := range historyPaths {
+		if strings.HasSuffix(rel, "/") {
+			record.Dirs++
+		} else {
+			record.Files++
+			record.Bytes += size
+		}
+		if top, _, nested := strings.Cut(rel, "/"); nested {
+			record.Top[redactText(top)] += size
+		}
+	}
+	return record
+}
+
+// replayHistory rebuilds the path set of the last detailed record in a log
+func replayHistory(log []byte) (map[string]bool, string) {
+	paths := map[string]bool{}
+	base := ""
+	for _, record := range parseHistory(log) {
+		if record.Detail != "changes" {
+			continue
+		}
+		if record.Base == "" {
+			paths = map[string]bool{}
+		}
+		for _, rel := range record.Removed {
+			delete(paths, rel)
+		}
+		for _, rel := range record.Added {
+			paths[rel] = true
+		}
+		base = record.Fingerprint
+	}
+	return paths, base
+}
+
+// parseHistory reads the records of a log, skipping damaged lines and newer versions
+func parseHistory(log []byte) []historyRecord {
+	var records []historyRecord
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var record historyRecord
+		if err := json.Unmarshal(text, &record); err != nil {
+			warnf("Skipping line %d of the history log: %v", line, err)
+			continue
+		}
+		if record.Version > historyVersion {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// appendHistory appends this run's record to the --history log. The file is locked
+// for the read-and-append so concurrent runs cannot interleave or diff against a
+// snapshot that is being written, and each record goes out in a single write.
+func appendHistory(fingerprint string) {
+	f, err := os.OpenFile(historyFile, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		warnf("Cannot open history log %s: %v", historyFile, err)
+		return
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		warnf("Cannot lock history log %s: %v", historyFile, err)
+		return
+	}
+	defer unlockFile(f)
+
+	record := newHistoryRecord(fingerprint)
+	if historyDetail == "changes" {
+		log, err := io.ReadAll(f)
+		if err != nil {
+			warnf("Cannot read history log %s: %v", historyFile, err)
+			return
+		}
+		previous, base := replayHistory(log)
+		record.Detail, record.Base = "changes", base
+		for rel := range historyPaths {
+			shown := filepath.ToSlash(redactPath(rel))
+			if !previous[shown] {
+				record.Added = append(record.Added, shown)
+			}
+			delete(previous, shown)
+		}
+		for rel := range previous {
+			record.Removed = append(record.Removed, rel)
+		}
+		sort.Strings(record.Added)
+		sort.Strings(record.Removed)
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		warnf("Cannot encode history record: %v", err)
+		return
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		warnf("Cannot append to history log %s: %v", historyFile, err)
+	}
+}
+
+// historyRow is one run in "ftg history report"
+type historyRow struct {
+	Time        time.Time `json:"time"`
+	Dirs        int       `json:"dirs"`
+	Files       int       `json:"files"`
+	Bytes       int64     `json:"bytes"`
+	FilesDelta  int       `json:"filesDelta"`
+	BytesDelta  int64     `json:"bytesDelta"`
+	Added       int       `json:"added"`
+	Removed     int       `json:"removed"`
+	Fingerprint string    `json:"fingerprint"`
+}
+
+// historyReport implements "ftg history report [--json] history.ndjson"
+func historyReport(args []string) {
+	if len(args) == 0 || args[0] != "report" {
+		usageExit("usage: ftg history report [--json] history.ndjson")
+	}
+	fset := flag.NewFlagSet("history report", flag.ExitOnError)
+	jsonOutput := fset.Bool("json", false, "Print the report as JSON")
+	_ = fset.Parse(args[1:])
+	if fset.NArg() != 1 {
+		usageExit("usage: ftg history report [--json] history.ndjson")
+	}
+	log, err := os.ReadFile(fset.Arg(0))
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read history log: %v", err))
+	}
+	rows := historyRows(parseHistory(log))
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(rows, "", "  ")
+		fmt.Println(string(out))
+		exitProcess(runExitCode(true))
+	}
+	writeHistoryTable(os.Stdout, rows)
+	exitProcess(runExitCode(true))
+}
+
+// historyRows turns records into report rows with the change since the previous run
+func historyRows(records []historyRecord) []historyRow {
+	rows := []historyRow{}
+	for i, record := range records {
+		row := historyRow{
+			Time:        record.Time,
+			Dirs:        record.Dirs,
+			Files:       record.Files,
+			Bytes:       record.Bytes,
+			Added:       len(record.Added),
+			Removed:     len(record.Removed),
+			Fingerprint: record.Fingerprint,
+		}
+		if i > 0 {
+			row.FilesDelta = record.Files - records[i-1].Files
+			row.BytesDelta = record.Bytes - records[i-1].Bytes
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// writeHistoryTable renders report rows as a markdown table
+func writeHistoryTable(writer io.Writer, rows []historyRow) {
+	fmt.Fprintln(writer, "| Run | Directories | Files | Size | Files change | Size change | Added | Removed | Fingerprint |")
+	fmt.Fprintln(writer, "| --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: | --- |")
+	for _, row := range rows {
+		sizeDelta := formatSize(max(row.BytesDelta, -row.BytesDelta))
+		if row.BytesDelta < 0 {
+			sizeDelta = "-" + sizeDelta
+		} else {
+			sizeDelta = "+" + sizeDelta
+		}
+		fingerprint := strings.TrimPrefix(row.Fingerprint, "sha256:")
+		if len(fingerprint) > 12 {
+			fingerprint = fingerprint[:12]
+		}
+		fmt.Fprintf(writer, "| %s | %d | %d | %s | %+d | %s | %d | %d | %s |\n",
+			row.Time.Local().Format(timeLayout), row.Dirs, row.Files, formatSize(row.Bytes),
+			row.FilesDelta, sizeDelta, row.Added, row.Removed, fingerprint)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// pageCSS styles the standalone page of -f html; nothing is loaded from elsewhere
+const pageCSS = `body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+ul.tree, ul.tree ul { list-style: none; padding-left: 1.2rem; margin: 0; font-family: ui-monospace, Menlo, Consolas, monospace; }
+ul.tree summary { cursor: pointer; font-weight: bold; }
+ul.tree li.note { color: #a00; }
+p.meta { color: #666; }
+`
+
+// renderHTML returns the tree as one standalone page in which every directory is a
+// <details> element, so readers can collapse large directories in the browser
+func renderHTML(ctx context.Context, root string, entries []fs.DirEntry) ([]byte, error) {
+	var out bytes.Buffer
+	title := html.EscapeString(msg("html.title", shownRoot(root)))
+	fmt.Fprintf(&out, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n",
+		lang, title, pageCSS)
+	fmt.Fprintf(&out, "<h1>%s</h1>\n<p class=\"meta\">%s · <a href=\"%s\">%s</a></p>\n",
+		title, time.Now().Format(timeLayout), repository, html.EscapeString(msg("html.star")))
+	out.WriteString("<ul class=\"tree\">\n")
+	if err := writeHTMLEntries(ctx, &out, root, entries); err != nil {
+		return walkFailure(ctx, err)
+	}
+	out.WriteString("</ul>\n</body>\n</html>\n")
+	return out.Bytes(), nil
+}
+
+// writeHTMLEntries renders the tree below dir through walkTree, with the filters and
+// exclusions of every other format
+func writeHTMLEntries(ctx context.Context, writer io.Writer, dir string, entries []fs.DirEntry) error {
+	var label string // Of the directory walkTree reads next
+	return walkTree(ctx, dir, entries, func(e treeEntry) error {
+		switch e.event {
+		case walkElided:
+			fmt.Fprintf(writer, "<li class=\"note\">%s</li>\n", html.EscapeString(msg("tree.similar", groupThousands(e.elided))))
+		case walkEntry:
+			label = html.EscapeString(entryLabel(e.dir, e.entry) + stubNote(e))
+			if !e.descend {
+				fmt.Fprintf(writer, "<li>%s</li>\n", label)
+			}
+		case walkFailed:
+			fmt.Fprintf(writer, "<li class=\"note\">%s/ (unreadable)</li>\n", label)
+		case walkOpen:
+			fmt.Fprintf(writer, "<li><details open>\n<summary>%s/</summary>\n<ul>\n", label)
+		case walkClose:
+			fmt.Fprintf(writer, "</ul>\n</details></li>\n")
+		}
+		return nil
+	})
+}
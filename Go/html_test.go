@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Names, link targets and the root path are escaped wherever -f html places them, so
+// a file named <script>.js is text; the page loads nothing from elsewhere
+func TestHTMLEscaping(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows file names cannot hold < or \"")
+	}
+	src := testtree.Dir(t, `<root>/<script>.js
+		<root>/a&b/q"t'.txt
+		<root>/lnk -> <script>.js
+	`)
+	root := filepath.Join(src, "<root>")
+	stdout, stderr, code := runFTG(t, t.TempDir(), "-d", root, "-f", "html", "-o", "-", "--quiet")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	escapedRoot := strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(root)
+	for _, want := range []string{
+		"<title>File Tree for " + escapedRoot + "</title>\n",
+		"<h1>File Tree for " + escapedRoot + "</h1>\n",
+		"<li>&lt;script&gt;.js</li>\n",
+		"<li><details open>\n<summary>a&amp;b/</summary>\n<ul>\n<li>q&#34;t&#39;.txt</li>\n</ul>\n</details></li>\n",
+		"<li>lnk -&gt; &lt;script&gt;.js</li>\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("missing %q:\n%s", want, stdout)
+		}
+	}
+	if !regexp.MustCompile(`<p class="meta">\d{4}-\d\d-\d\d \d\d:\d\d · `).MatchString(stdout) {
+		t.Errorf("no generation time in the header:\n%s", stdout)
+	}
+	for _, external := range []string{"<script", "<link", " src=", "@import", "url("} {
+		if strings.Contains(stdout, external) {
+			t.Errorf("%q in a standalone page:\n%s", external, stdout)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Output formats selected with -f
+const (
+	formatMarkdown = "md"
+	formatHTML     = "html"
+	formatHTMLSite = "html-site"
+	formatMDSite   = "md-site"
+)
+
+var (
+	outputFormat = formatMarkdown // Output format
+	outputDir    string           // Directory written by multi-file formats
+	siteDepth    = 2              // Directories up to this depth get their own html-site or md-site page
+)
+
+// siteFormat reports whether the format writes one page per directory into --output-dir
+func siteFormat(format string) bool {
+	return format == formatHTMLSite || format == formatMDSite
+}
+
+// siteCSS is the shared stylesheet written once per site
+const siteCSS = `body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+nav.crumbs { margin-bottom: 1rem; color: #666; }
+ul.tree { list-style: none; padding-left: 1.2rem; font-family: ui-monospace, monospace; }
+ul.tree li.dir > a, ul.tree li.dir > span { font-weight: bold; }
+ul.tree li.note { color: #a00; }
+table.stats td { padding: 0.2rem 1rem 0.2rem 0; }
+`
+
+// htmlSite collects the pages of an html-site or md-site render
+type htmlSite struct {
+	markdown bool              // The pages are Markdown rather than HTML
+	pages    map[string]string // Page file name per directory, relative to the input directory
+	used     map[string]bool   // Lower-cased file names already taken, for case-insensitive filesystems
+	dirs     int
+	files    int
+	bytes    int64
+}
+
+// ext is the extension of the site's files, with the dot
+func (s *htmlSite) ext() string {
+	if s.markdown {
+		return ".md"
+	}
+	return ".html"
+}
+
+// pageName returns a sanitized, collision-free file name for a directory's page.
+// Names only use [a-z0-9._-] so links work unescaped from file:// on any platform,
+// and the "d-" prefix keeps them clear of reserved Windows names like CON or NUL.
+// Both site formats name pages alike, and a page is named before it is rendered,
+// so any page can link any other. Names are taken from the redacted path, so the
+// file names do not publish what the pages hide.
+func (s *htmlSite) pageName(rel string) string {
+	if name, ok := s.pages[rel]; ok {
+		return name
+	}
+	base := "root"
+	if rel != "" {
+		var b strings.Builder
+		b.WriteString("d-")
+		for _, r := range strings.ToLower(redactPath(rel)) {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+				b.WriteRune(r)
+			default:
+				b.WriteByte('_')
+			}
+		}
+		base = b.String()
+		if len(base) > 100 {
+			base = base[:100]
+		}
+	}
+	name := base + s.ext()
+	for n := 2; s.used[name]; n++ {
+		name = fmt.Sprintf("%s-%d%s", base, n, s.ext())
+	}
+	s.used[name] = true
+	s.pages[rel] = name
+	return name
+}
+
+// writeHTMLSite renders the tree below root as one page per directory into dir, as
+// HTML or with markdown as Markdown. A cancelled run keeps the pages written so far;
+// the index is only written for a whole site.
+func writeHTMLSite(ctx context.Context, dir, root string, entries []fs.DirEntry, markdown bool) error {
+	pagesDir := filepath.Join(dir, "pages")
+	if err := os.MkdirAll(pagesDir, 0o755); err != nil {
+		return fmt.Errorf("cannot create %s: %w", pagesDir, err)
+	}
+	site := &htmlSite{markdown: markdown, pages: map[string]string{}, used: map[string]bool{}}
+	if err := site.writePages(ctx, pagesDir, root, entries); err != nil {
+		_, err = walkFailure(ctx, err)
+		return err
+	}
+
+	rows := [][2]string{
+		{"Directories", fmt.Sprint(site.dirs)},
+		{"Files", fmt.Sprint(site.files)},
+		{"Total size", formatSize(site.bytes)},
+		{"Pages", fmt.Sprint(len(site.pages))},
+		{"Page depth", fmt.Sprint(siteDepth)},
+		{"Generated", time.Now().Format(timeLayout)},
+	}
+	var index strings.Builder
+	kind := "HTML"
+	if markdown {
+		kind = "Markdown"
+		fmt.Fprintf(&index, "# File Tree for %s\n\n[Browse the tree](pages/%s)\n\n| | |\n| --- | --- |\n", markdownEscapes.Replace(shownRoot(root)), site.pageName(""))
+		for _, row := range rows {
+			fmt.Fprintf(&index, "| %s | %s |\n", row[0], row[1])
+		}
+		fmt.Fprintf(&index, "\n%s\n", msg("header.star", repository))
+	} else {
+		if err := writeFile(filepath.Join(dir, "style.css"), []byte(siteCSS)); err != nil {
+			return fmt.Errorf("cannot write %s: %w", filepath.Join(dir, "style.css"), err)
+		}
+		title := html.EscapeString(shownRoot(root))
+		fmt.Fprintf(&index, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>File Tree for %s</title>\n<link rel=\"stylesheet\" href=\"style.css\">\n</head>\n<body>\n", title)
+		fmt.Fprintf(&index, "<h1>File Tree for %s</h1>\n<p><a href=\"pages/%s\">Browse the tree</a></p>\n<table class=\"stats\">\n", title, site.pageName(""))
+		for _, row := range rows {
+			fmt.Fprintf(&index, "<tr><td>%s</td><td>%s</td></tr>\n", row[0], row[1])
+		}
+		fmt.Fprintf(&index, "</table>\n<p>Give the project a star at <a href=\"%s\">%s</a></p>\n</body>\n</html>\n", repository, repository)
+	}
+	file := filepath.Join(dir, "index"+site.ext())
+	if err := writeFile(file, []byte(index.String())); err != nil {
+		return fmt.Errorf("cannot write %s: %w", file, err)
+	}
+	fmt.Printf("%s site with %s has been written to %s\n", kind, plural(len(site.pages), "page"), dir)
+	return nil
+}
+
+// sitePage is an html-site or md-site page or nested list that walkTree is filling
+type sitePage struct {
+	page   *strings.Builder // The page the list is on
+	rel    string           // Directory of the list, relative to the input directory
+	own    bool             // The directory has a page of its own rather than a list on its parent's
+	indent string           // Of the list's Markdown items
+}
+
+// startPage begins the page of the directory rel, up to its listing: the heading and,
+// under it, the breadcrumbs
+func (s *htmlSite) startPage(rel string) *strings.Builder {
+	page := &strings.Builder{}
+	title := redactPath(path.Join(path.Base(filepath.ToSlash(scan.Root)), rel))
+	if s.markdown {
+		fmt.Fprintf(page, "# %s\n\n%s\n\n", markdownEscapes.Replace(title), s.breadcrumbs(rel))
+		return page
+	}
+	title = html.EscapeString(title)
+	fmt.Fprintf(page, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<link rel=\"stylesheet\" href=\"../style.css\">\n</head>\n<body>\n", title)
+	fmt.Fprintf(page, "<h1>%s</h1>\n%s<ul class=\"tree\">\n", title, s.breadcrumbs(rel))
+	return page
+}
+
+// finishPage ends the page of the directory rel and writes it to pagesDir
+func (s *htmlSite) finishPage(pagesDir, rel string, page *strings.Builder) {
+	if !s.markdown {
+		page.WriteString("</ul>\n</body>\n</html>\n")
+	}
+	file := filepath.Join(pagesDir, s.pageName(rel))
+	if err := writeFile(file, []byte(page.String())); err != nil {
+		warnf("Cannot write %s: %v", file, err)
+	}
+}
+
+// writePages walks the tree below dir through walkTree and writes a page for it and
+// for every directory within siteDepth, linked from its parent's page; deeper ones are
+// inlined as nested lists
+func (s *htmlSite) writePages(ctx context.Context, pagesDir, dir string, entries []fs.DirEntry) error {
+	open := []sitePage{{page: s.startPage(""), own: true}}
+	var label string // Of the directory walkTree reads next
+	err := walkTree(ctx, dir, entries, func(e treeEntry) error {
+		top := open[len(open)-1]
+		switch e.event {
+		case walkElided:
+			if s.markdown {
+				fmt.Fprintf(top.page, "%s- %s\n", top.indent, markdownEscapes.Replace(msg("tree.similar", groupThousands(e.elided))))
+			} else {
+				fmt.Fprintf(top.page, "<li class=\"note\">%s</li>\n", html.EscapeString(msg("tree.similar", groupThousands(e.elided))))
+			}
+		case walkEntry:
+			label = entryLabel(e.dir, e.entry) + stubNote(e)
+			if s.markdown {
+				label = markdownEscapes.Replace(label)
+			} else {
+				label = html.EscapeString(label)
+			}
+			if e.descend {
+				s.dirs++
+				return nil
+			}
+			s.files++
+			if e.size > 0 && !e.entry.IsDir() {
+				s.bytes += e.size
+			}
+			if s.markdown {
+				fmt.Fprintf(top.page, "%s- %s\n", top.indent, label)
+			} else {
+				fmt.Fprintf(top.page, "<li class=\"file\">%s</li>\n", label)
+			}
+		case walkFailed:
+			if s.markdown {
+				fmt.Fprintf(top.page, "%s- **%s/** (unreadable)\n", top.indent, label)
+			} else {
+				fmt.Fprintf(top.page, "<li class=\"dir note\"><span>%s/</span> (unreadable)</li>\n", label)
+			}
+		case walkOpen:
+			own := strings.Count(e.rel, "/") < siteDepth
+			switch {
+			case own && s.markdown:
+				fmt.Fprintf(top.page, "%s- [**%s/**](%s)\n", top.indent, label, s.pageName(e.rel))
+			case own:
+				fmt.Fprintf(top.page, "<li class=\"dir\"><a href=\"%s\">%s/</a></li>\n", s.pageName(e.rel), label)
+			case s.markdown:
+				fmt.Fprintf(top.page, "%s- **%s/**\n", top.indent, label)
+			default:
+				fmt.Fprintf(top.page, "<li class=\"dir\"><span>%s/</span>\n<ul class=\"tree\">\n", label)
+			}
+			if own {
+				open = append(open, sitePage{page: s.startPage(e.rel), rel: e.rel, own: true})
+				return nil
+			}
+			open = append(open, sitePage{page: top.page, rel: e.rel, indent: top.indent + "  "})
+		case walkClose:
+			open = open[:len(open)-1]
+			switch {
+			case top.own:
+				s.finishPage(pagesDir, top.rel, top.page)
+			case !s.markdown:
+				top.page.WriteString("</ul>\n</li>\n")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.finishPage(pagesDir, "", open[0].page)
+	return nil
+}
+
+// breadcrumbs links the index and the pages of rel and every directory above it,
+// as "Index / repo / services / payments"
+func (s *htmlSite) breadcrumbs(rel string) string {
+	crumbs := [][2]string{{"Index", "../index" + s.ext()}, {redactText(rootName(scan.Root)), s.pageName("")}}
+	if rel != "" {
+		parts := strings.Split(rel, "/")
+		for i, part := range parts {
+			crumbs = append(crumbs, [2]string{redactText(part), s.pageName(strings.Join(parts[:i+1], "/"))})
+		}
+	}
+	links := make([]string, len(crumbs))
+	for i, crumb := range crumbs {
+		if s.markdown {
+			links[i] = fmt.Sprintf("[%s](%s)", markdownEscapes.Replace(crumb[0]), crumb[1])
+		} else {
+			links[i] = fmt.Sprintf("<a href=\"%s\">%s</a>", crumb[1], html.EscapeString(crumb[0]))
+		}
+	}
+	if s.markdown {
+		return strings.Join(links, " / ")
+	}
+	return "<nav class=\"crumbs\">" + strings.Join(links, " / ") + "</nav>\n"
+}
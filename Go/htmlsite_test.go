@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// siteTree nests past the default --site-depth and has two directories whose page
+// names collide
+const siteTree = `
+services/payments/api/handler.go
+services/payments/main.go
+services/auth/token.go
+docs/a+b/one.md
+docs/a_b/two.md
+README.md
+`
+
+// siteLinks matches the relative links of html-site and md-site pages
+var siteLinks = regexp.MustCompile(`href="([^":]+)"|\]\(([^):]+)\)`)
+
+// Every relative link of either site resolves to a file of the site, and every page
+// is linked from another
+func TestSiteLinkIntegrity(t *testing.T) {
+	for _, format := range []string{formatHTMLSite, formatMDSite} {
+		t.Run(format, func(t *testing.T) {
+			dir := testtree.Dir(t, siteTree)
+			if _, stderr, code := runFTG(t, dir, "-d", ".", "-f", format, "--output-dir", "site", "--quiet"); code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			site := filepath.Join(dir, "site")
+			linked := map[string]bool{}
+			pages := 0
+			err := filepath.WalkDir(site, func(file string, d os.DirEntry, err error) error {
+				if err != nil || d.IsDir() || strings.HasSuffix(file, ".css") {
+					return err
+				}
+				pages++
+				data, err := os.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				for _, match := range siteLinks.FindAllStringSubmatch(string(data), -1) {
+					target := filepath.Join(filepath.Dir(file), filepath.FromSlash(match[1]+match[2]))
+					if _, err := os.Stat(target); err != nil {
+						t.Errorf("%s links %s, which is missing", file, match[1]+match[2])
+					}
+					if target != file && !strings.HasSuffix(target, ".css") {
+						linked[target] = true
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The index, and the root, docs, services and their four subdirectories
+			if pages != 8 {
+				t.Errorf("%d pages, want 8", pages)
+			}
+			if len(linked) != pages {
+				t.Errorf("%d of %d pages are linked from another", len(linked), pages)
+			}
+		})
+	}
+}
+
+// The breadcrumbs are the line under a page's heading and link the index and the
+// page of every directory down to the page's own
+func TestSiteBreadcrumbs(t *testing.T) {
+	tests := []struct {
+		format, page, want string
+	}{
+		{formatMDSite, "d-services_payments.md", "# src/services/payments\n\n" +
+			"[Index](../index.md) / [src](root.md) / [services](d-services.md) / [payments](d-services_payments.md)\n\n" +
+			"- **api/**\n  - handler.go\n- main.go\n"},
+		{formatMDSite, "d-docs.md", "- [**a+b/**](d-docs_a_b.md)\n- [**a\\_b/**](d-docs_a_b-2.md)\n"},
+		{formatHTMLSite, "d-services_payments.html", "<h1>src/services/payments</h1>\n" +
+			`<nav class="crumbs"><a href="../index.html">Index</a> / <a href="root.html">src</a> / ` +
+			`<a href="d-services.html">services</a> / <a href="d-services_payments.html">payments</a></nav>` + "\n"},
+	}
+	for _, test := range tests {
+		dir := testtree.Dir(t, strings.ReplaceAll(siteTree, "\n", "\nsrc/"))
+		if _, stderr, code := runFTG(t, dir, "-d", "src", "-f", test.format, "--output-dir", "site", "--quiet"); code != exitOK {
+			t.Fatalf("exit code %d: %s", code, stderr)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "site", "pages", test.page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), test.want) {
+			t.Errorf("-f %s %s:\n%s\nwant it to hold\n%s", test.format, test.page, data, test.want)
+		}
+	}
+}
+
+func TestSiteRefused(t *testing.T) {
+	dir := testtree.Dir(t, "a.txt\n")
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-f", "md-site"}, "-f md-site needs --output-dir"},
+		{[]string{"-f", "md-site", "--output-dir", "site", "--group-by", "ext"}, "--group-by cannot be combined with -f md-site"},
+		{[]string{"-f", "md-site", "--output-dir", "site", "--sidecar", "-o", "x.md"}, "--sidecar cannot be combined with -f md-site"},
+	} {
+		if _, stderr, code := runFTG(t, dir, append([]string{"-d", "."}, test.args...)...); code != exitUsage || !strings.Contains(stderr, test.want) {
+			t.Errorf("%q: exit code %d, %s; want %q", test.args, code, stderr, test.want)
+		}
+	}
+}
+
+// A redacted directory is hidden in the page file names and links as well as in
+// the pages' text
+func TestSiteRedaction(t *testing.T) {
+	for _, format := range []string{formatHTMLSite, formatMDSite} {
+		dir := testtree.Dir(t, siteTree)
+		if _, stderr, code := runFTG(t, dir, "-d", ".", "-f", format, "--output-dir", "site", "--redact-patterns", "payments", "--quiet"); code != exitOK {
+			t.Fatalf("exit code %d: %s", code, stderr)
+		}
+		err := filepath.WalkDir(filepath.Join(dir, "site"), func(file string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(file)
+			if strings.Contains(d.Name(), "payments") || strings.Contains(string(data), "payments") {
+				t.Errorf("-f %s: %s publishes the redacted name", format, file)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "site", "pages", "d-services__redacted_"+map[string]string{formatHTMLSite: ".html", formatMDSite: ".md"}[format])); err != nil {
+			t.Errorf("-f %s: %v", format, err)
+		}
+	}
+}
+
+// Directories down to --site-depth get a page, deeper ones are inlined in their
+// ancestor's; the index holds the totals and every page shares one style sheet
+func TestSiteDepth(t *testing.T) {
+	dir := testtree.Dir(t, "s/a/b/c/d/f.txt size=3\ns/top.txt\n")
+	if _, stderr, code := runFTG(t, dir, "-d", "s", "-f", "html-site", "--output-dir", "site", "--site-depth", "2", "--quiet"); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	site := filepath.Join(dir, "site")
+	pages, err := filepath.Glob(filepath.Join(site, "pages", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, page := range pages {
+		names = append(names, filepath.Base(page))
+	}
+	if got := strings.Join(names, " "); got != "d-a.html d-a_b.html root.html" {
+		t.Errorf("pages %s, want root, a and a/b only", got)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(site, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if page := read("pages/d-a_b.html"); !strings.Contains(page, `<link rel="stylesheet" href="../style.css">`) ||
+		!strings.Contains(page, "<li class=\"dir\"><span>c/</span>\n<ul class=\"tree\">\n<li class=\"dir\"><span>d/</span>\n<ul class=\"tree\">\n<li class=\"file\">f.txt</li>") {
+		t.Errorf("a/b does not inline c and d:\n%s", page)
+	}
+	index := read("index.html")
+	for _, want := range []string{
+		`<link rel="stylesheet" href="style.css">`, `<a href="pages/root.html">Browse the tree</a>`,
+		"<tr><td>Directories</td><td>4</td></tr>", "<tr><td>Files</td><td>2</td></tr>", "<tr><td>Total size</td><td>3 B</td></tr>",
+		"<tr><td>Pages</td><td>3</td></tr>", "<tr><td>Page depth</td><td>2</td></tr>",
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html misses %q:\n%s", want, index)
+		}
+	}
+	if read("style.css") == "" {
+		t.Error("style.css is empty")
+	}
+}
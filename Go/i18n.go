@@ -0,0 +1,93 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// catalogFiles holds one JSON message catalog per language, keyed by message id.
+// Counted messages have ".one" and ".other" variants; a language without a
+// singular form (ja) only defines ".other". Tree connectors and file names are
+// never looked up here.
+//
+//go:embed messages/*.json
+var catalogFiles embed.FS
+
+var (
+	lang       = "en"                 // --lang
+	catalog    map[string]string      // Messages of the selected language
+	catalogEN  = mustCatalog("en")    // English messages, the fallback for missing keys
+	catalogAll = availableLanguages() // Languages with a catalog, for validation
+)
+
+// mustCatalog loads an embedded catalog that ships with the binary
+func mustCatalog(name string) map[string]string {
+	messages, err := loadCatalog(name)
+	if err != nil {
+		panic(err)
+	}
+	return messages
+}
+
+// loadCatalog parses messages/<name>.json
+func loadCatalog(name string) (map[string]string, error) {
+	data, err := catalogFiles.ReadFile("messages/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no message catalog for %q", name)
+	}
+	messages := map[string]string{}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("message catalog %s: %v", name, err)
+	}
+	return messages, nil
+}
+
+// availableLanguages lists the embedded catalogs
+func availableLanguages() []string {
+	entries, _ := catalogFiles.ReadDir("messages")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setLang selects the catalog for --lang
+func setLang(name string) error {
+	messages, err := loadCatalog(name)
+	if err != nil {
+		return fmt.Errorf("unknown --lang %q (use %s)", name, strings.Join(catalogAll, ", "))
+	}
+	lang, catalog = name, messages
+	return nil
+}
+
+// lookup returns the first of keys defined by the selected language, then by English
+func lookup(keys ...string) string {
+	for _, messages := range []map[string]string{catalog, catalogEN} {
+		for _, key := range keys {
+			if text, ok := messages[key]; ok {
+				return text
+			}
+		}
+	}
+	return keys[0]
+}
+
+// msg formats a message of the catalog
+func msg(key string, args ...any) string {
+	return fmt.Sprintf(lookup(key), args...)
+}
+
+// msgCount formats a counted message such as "3 files"; the count is grouped for the language
+func msgCount(key string, count int) string {
+	keys := []string{key + ".other"}
+	if count == 1 {
+		keys = []string{key + ".one", key + ".other"}
+	}
+	return fmt.Sprintf(lookup(keys...), groupThousands(count))
+}
@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+// Every catalog may leave keys to English, but none may define a key English lacks
+func TestCatalogKeysExistInEnglish(t *testing.T) {
+	for _, name := range catalogAll {
+		messages := mustCatalog(name)
+		for key := range messages {
+			if _, ok := catalogEN[key]; !ok {
+				t.Errorf("messages/%s.json defines %q, which messages/en.json does not", name, key)
+			}
+		}
+	}
+}
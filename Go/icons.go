@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	iconMode      string                // --icons: nerd or emoji glyphs before each name, "" for none
+	iconOverrides = map[string]string{} // --icon-map glyphs by lower-case extension with its dot, or "dir" and "file"
+)
+
+// iconsValue is the --icons flag: bare, it picks Nerd Font glyphs
+type iconsValue struct{}
+
+// String returns the icon set chosen
+func (iconsValue) String() string {
+	return iconMode
+}
+
+// Set chooses the icon set: nerd (also a bare --icons), emoji, or none
+func (iconsValue) Set(value string) error {
+	switch strings.ToLower(value) {
+	case "nerd", "true":
+		iconMode = "nerd"
+	case "emoji":
+		iconMode = "emoji"
+	case "none", "false":
+		iconMode = ""
+	default:
+		return fmt.Errorf("unknown icon set %q (use nerd, emoji or none)", value)
+	}
+	return nil
+}
+
+// IsBoolFlag lets --icons be given without a value
+func (iconsValue) IsBoolFlag() bool {
+	return true
+}
+
+// iconMapValue is the repeatable --icon-map flag of ext=glyph pairs
+type iconMapValue struct{}
+
+// String returns the overrides given so far
+func (iconMapValue) String() string {
+	pairs := make([]string, 0, len(iconOverrides))
+	for key, glyph := range iconOverrides {
+		pairs = append(pairs, key+"="+glyph)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set adds overrides such as "go=🐹,.tar.gz=📦,dir=📂"; "go" and ".go" are the
+// same extension, and "dir" and "file" replace the directory and fallback glyphs
+func (iconMapValue) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		key, glyph, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		key, glyph = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(glyph)
+		if !ok || key == "" || glyph == "" {
+			return fmt.Errorf("icon override %q is not ext=glyph", pair)
+		}
+		if key != "dir" && key != "file" && !strings.HasPrefix(key, ".") {
+			key = "." + key
+		}
+		iconOverrides[key] = glyph
+	}
+	return nil
+}
+
+// The Nerd Font glyphs for directories and for files no other glyph matches
+const (
+	nerdDirIcon  = '\uf07b' // nf-fa-folder
+	nerdFileIcon = '\uf15b' // nf-fa-file
+)
+
+// nerdIcons are Nerd Font glyphs by extension, or by whole lower-case name for files
+// known by name; keys with a leading dot are extensions
+var nerdIcons = map[string]rune{
+	// nf-seti-go
+	".go": '\ue627', ".mod": '\ue627', ".sum": '\ue627',
+	// nf-oct-markdown
+	".md": '\uf48a', ".markdown": '\uf48a',
+	// nf-seti-json
+	".json": '\ue60b',
+	// nf-seti-config
+	".yaml": '\ue615', ".yml": '\ue615', ".toml": '\ue615', ".ini": '\ue615', ".cfg": '\ue615',
+	// nf-oct-terminal
+	".sh": '\uf489', ".bash": '\uf489', ".zsh": '\uf489', ".fish": '\uf489', ".ps1": '\uf489',
+	// nf-seti-python
+	".py": '\ue606',
+	// nf-dev-rust
+	".rs": '\ue7a8',
+	// nf-dev-javascript
+	".js": '\ue74e', ".mjs": '\ue74e', ".cjs": '\ue74e',
+	// nf-seti-typescript
+	".ts": '\ue628', ".tsx": '\ue628',
+	// nf-dev-html5
+	".html": '\ue736', ".htm": '\ue736',
+	// nf-dev-css3
+	".css": '\ue749', ".scss": '\ue749',
+	// nf-custom-c
+	".c": '\ue61e', ".h": '\ue61e',
+	// nf-custom-cpp
+	".cpp": '\ue61d', ".cc": '\ue61d', ".hpp": '\ue61d',
+	// nf-dev-java
+	".java": '\ue738',
+	// nf-dev-ruby
+	".rb": '\ue739',
+	// nf-seti-lua
+	".lua": '\ue620',
+	// nf-dev-swift
+	".swift": '\ue755',
+	// nf-fa-file_image_o
+	".png": '\uf1c5', ".jpg": '\uf1c5', ".jpeg": '\uf1c5', ".gif": '\uf1c5', ".webp": '\uf1c5', ".svg": '\uf1c5', ".ico": '\uf1c5',
+	// nf-fa-music
+	".mp3": '\uf001', ".wav": '\uf001', ".flac": '\uf001',
+	// nf-fa-video_camera
+	".mp4": '\uf03d', ".mov": '\uf03d', ".mkv": '\uf03d',
+	// nf-oct-file_zip
+	".zip": '\uf410', ".tar": '\uf410', ".gz": '\uf410', ".tgz": '\uf410', ".tar.gz": '\uf410', ".xz": '\uf410', ".7z": '\uf410',
+	// nf-fa-file_pdf_o
+	".pdf": '\uf1c1',
+	// nf-fa-file_text
+	".txt": '\uf15c', ".log": '\uf15c',
+	// nf-fa-lock
+	".lock": '\uf023',
+	// nf-linux-docker
+	"dockerfile": '\uf308',
+	// nf-fa-git
+	".gitignore": '\uf1d3', ".gitattributes": '\uf1d3', ".gitmodules": '\uf1d3',
+	// nf-seti-config
+	"makefile": '\ue615',
+}
+
+// The emoji for directories and for files no other emoji matches
+const (
+	emojiDirIcon  = "📁"
+	emojiFileIcon = "📄"
+)
+
+// emojiIcons are the --icons=emoji glyphs, keyed like nerdIcons. Some need a
+// variation selector, so they are strings.
+var emojiIcons = map[string]string{
+	".go": "🐹", ".mod": "🐹", ".sum": "🐹",
+	".md": "📝", ".markdown": "📝",
+	".json": "⚙️", ".yaml": "⚙️", ".yml": "⚙️", ".toml": "⚙️", ".ini": "⚙️", ".cfg": "⚙️",
+	".sh": "🐚", ".bash": "🐚", ".zsh": "🐚", ".fish": "🐚", ".ps1": "🐚",
+	".py": "🐍",
+	".rs": "🦀",
+	".js": "📜", ".mjs": "📜", ".cjs": "📜", ".ts": "📜", ".tsx": "📜",
+	".html": "🌐", ".htm": "🌐",
+	".css": "🎨", ".scss": "🎨",
+	".png": "🖼️", ".jpg": "🖼️", ".jpeg": "🖼️", ".gif": "🖼️", ".webp": "🖼️", ".svg": "🖼️", ".ico": "🖼️",
+	".mp3": "🎵", ".wav": "🎵", ".flac": "🎵",
+	".mp4": "🎞️", ".mov": "🎞️", ".mkv": "🎞️",
+	".zip": "📦", ".tar": "📦", ".gz": "📦", ".tgz": "📦", ".tar.gz": "📦", ".xz": "📦", ".7z": "📦",
+	".pdf":       "📕",
+	".lock":      "🔒",
+	"dockerfile": "🐳",
+}
+
+// entryIcon returns the --icons glyph of an entry by its shown name, so a redacted
+// name gets the generic glyph: a file known by its whole name first, then its
+// extensions longest first, so archive.tar.gz matches .tar.gz before .gz, with
+// --icon-map taking precedence over the built-in set
+func entryIcon(name string, isDir bool) string {
+	if iconMode == "" {
+		return ""
+	}
+	if isDir {
+		return iconGlyph("dir")
+	}
+	lower := strings.ToLower(name)
+	if glyph, ok := builtinIcon(lower); ok {
+		return glyph
+	}
+	for i := 1; i < len(lower); i++ {
+		if lower[i] != '.' {
+			continue
+		}
+		if glyph := iconGlyph(lower[i:]); glyph != "" {
+			return glyph
+		}
+	}
+	return iconGlyph("file")
+}
+
+// iconGlyph returns the glyph of an extension, or of "dir" and "file": the
+// --icon-map override, else the built-in one, "" for an extension with neither
+func iconGlyph(key string) string {
+	if glyph, ok := iconOverrides[key]; ok {
+		return glyph
+	}
+	switch {
+	case key == "dir" && iconMode == "emoji":
+		return emojiDirIcon
+	case key == "dir":
+		return string(nerdDirIcon)
+	case key == "file" && iconMode == "emoji":
+		return emojiFileIcon
+	case key == "file":
+		return string(nerdFileIcon)
+	}
+	glyph, _ := builtinIcon(key)
+	return glyph
+}
+
+// builtinIcon looks an extension or a whole name up in the chosen icon set
+func builtinIcon(key string) (string, bool) {
+	if iconMode == "emoji" {
+		glyph, ok := emojiIcons[key]
+		return glyph, ok
+	}
+	if glyph, ok := nerdIcons[key]; ok {
+		return string(glyph), true
+	}
+	return "", false
+}
+
+// iconPrefix returns the glyph and a space to put before a tree line's name, or ""
+func iconPrefix(name string, isDir bool) string {
+	if icon := entryIcon(name, isDir); icon != "" {
+		return icon + " "
+	}
+	return ""
+}
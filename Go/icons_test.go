@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Names are looked up whole, dotfiles such as .gitignore included, then by their
+// extensions longest first and in any case, so archive.tar.gz takes the .tar.gz glyph
+// before the .gz one; names with no glyph of their own get the file glyph
+func TestEntryIcon(t *testing.T) {
+	overrides := map[string]string{".gz": "G", ".tar.gz": "T", ".v2.md": "2"}
+	tests := []struct {
+		name       string
+		isDir      bool
+		emoji      string
+		nerd       rune
+		overridden string // With overrides; "" when the emoji stays
+	}{
+		{"main.go", false, "🐹", nerdIcons[".go"], ""},
+		{"archive.tar.gz", false, "📦", nerdIcons[".tar.gz"], "T"},
+		{"ARCHIVE.TAR.GZ", false, "📦", nerdIcons[".tar.gz"], "T"},
+		{"logs.gz", false, "📦", nerdIcons[".gz"], "G"},
+		{"backup.2024.tar.gz", false, "📦", nerdIcons[".tar.gz"], "T"},
+		{"notes.v2.md", false, "📝", nerdIcons[".md"], "2"},
+		{"Dockerfile", false, "🐳", nerdIcons["dockerfile"], ""},
+		{"README", false, emojiFileIcon, nerdFileIcon, ""},
+		{".gitignore", false, emojiFileIcon, nerdIcons[".gitignore"], ""},
+		{"trailing.", false, emojiFileIcon, nerdFileIcon, ""},
+		{"src.go", true, emojiDirIcon, nerdDirIcon, ""},
+	}
+	for _, test := range tests {
+		setOption(t, &iconMode, "emoji")
+		if got := entryIcon(test.name, test.isDir); got != test.emoji {
+			t.Errorf("emoji %s: %q, want %q", test.name, got, test.emoji)
+		}
+		setOption(t, &iconMode, "nerd")
+		if got := entryIcon(test.name, test.isDir); got != string(test.nerd) {
+			t.Errorf("nerd %s: %q, want %q", test.name, got, string(test.nerd))
+		}
+		setOption(t, &iconMode, "emoji")
+		setOption(t, &iconOverrides, overrides)
+		want := test.overridden
+		if want == "" {
+			want = test.emoji
+		}
+		if got := entryIcon(test.name, test.isDir); got != want {
+			t.Errorf("overridden %s: %q, want %q", test.name, got, want)
+		}
+		setOption(t, &iconOverrides, map[string]string{})
+	}
+	setOption(t, &iconMode, "")
+	if got := entryIcon("main.go", false); got != "" {
+		t.Errorf("without --icons: %q", got)
+	}
+}
+
+// The tree puts the glyph between the type and the name; JSON keeps the name and gives the glyph
+// its own field; and the config file's icon-map overrides the built-in glyphs
+func TestIconsOutput(t *testing.T) {
+	dir := testtree.Dir(t, `src/main.go
+archive.tar.gz
+.ftg.toml content="icons = \"emoji\"\nicon-map = \"md=M\"\n"
+README.md
+`)
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "-o", "-", "--no-summary")
+	want := "├── [F] ⚙️ .ftg.toml\n" +
+		"├── [F] M README.md\n" +
+		"├── [F] 📦 archive.tar.gz\n" +
+		"└── [D] 📁 src\n" +
+		"    └── [F] 🐹 main.go\n"
+	if code != exitOK || stdout != want {
+		t.Errorf("exit code %d, %s\ngot\n%s\nwant\n%s", code, stderr, stdout, want)
+	}
+
+	stdout, stderr, code = runFTG(t, dir, "-d", ".", "-f", "json", "-o", "-")
+	var root jsonNode
+	if err := json.Unmarshal([]byte(stdout), &root); code != exitOK || err != nil {
+		t.Fatalf("exit code %d, %v: %s", code, err, stderr)
+	}
+	for _, child := range root.Children {
+		if child.Name == "archive.tar.gz" && child.Icon != "📦" || strings.ContainsAny(child.Name, "📦📁🐹") {
+			t.Errorf("JSON entry %q has icon %q", child.Name, child.Icon)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// initPresets are the exclusion profiles "ftg init" offers: those with a marker file,
+// suggested when it is in the directory
+var initPresets = func() []exclusionProfile {
+	var presets []exclusionProfile
+	for _, profile := range builtinProfiles {
+		if profile.marker != "" {
+			presets = append(presets, profile)
+		}
+	}
+	return presets
+}()
+
+// initFormats are the formats "ftg init" offers, the ones written to a single file
+var initFormats = []string{formatMarkdown, formatText, formatHTML, formatJSON, formatSVG, formatMermaid}
+
+// initCommittedTemplate is the output name for trees kept in version control: without
+// a timestamp, so each run replaces the file (with --skip-unchanged, which replaces
+// only ftg's own output) and its diff shows what changed
+const initCommittedTemplate = "file_tree.{ext}"
+
+// initAnswers are the choices made in "ftg init"
+type initAnswers struct {
+	dir      string
+	format   string
+	excludes []string
+	depth    int // 0 for no limit
+	commit   bool
+	generate bool
+}
+
+// runInit asks a few questions, writes .ftg.toml to the chosen directory and prints
+// the equivalent command. It returns the directory and whether the first tree
+// should be generated now, which main does with the config just written.
+func runInit(args []string) (string, bool) {
+	if len(args) > 0 {
+		usageExit("usage: ftg init (it asks for everything it needs)")
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&fs.ModeCharDevice == 0 {
+		usageExit("ftg init asks questions and needs a terminal. Without one, write .ftg.toml by hand, e.g.\n" +
+			"  format = \"md\"\n  exclude = \"dist,vendor\"\n  max-depth = 3\n" +
+			"or save the options of a command you already use: ftg [options] --print-config > .ftg.json")
+	}
+	answers := askInit(bufio.NewScanner(os.Stdin), os.Stdout)
+	if !answers.generate {
+		return "", false
+	}
+	return answers.dir, true
+}
+
+// askInit runs the questions on in and out, writes the config and prints the command
+func askInit(in *bufio.Scanner, out io.Writer) initAnswers {
+	ask := func(question, fallback string, check func(string) error) string {
+		for {
+			if fallback != "" {
+				fmt.Fprintf(out, "%s [%s]: ", question, fallback)
+			} else {
+				fmt.Fprintf(out, "%s: ", question)
+			}
+			if !in.Scan() {
+				fmt.Fprintln(out)
+				errorExit("ftg init needs an answer to every question; aborted, nothing was written")
+			}
+			answer := strings.TrimSpace(in.Text())
+			if answer == "" {
+				answer = fallback
+			}
+			if err := check(answer); err != nil {
+				fmt.Fprintf(out, "  %v\n", err)
+				continue
+			}
+			return answer
+		}
+	}
+	yesNo := func(answer string) error {
+		switch strings.ToLower(answer) {
+		case "y", "yes", "n", "no":
+			return nil
+		}
+		return fmt.Errorf("answer y or n")
+	}
+
+	fmt.Fprintln(out, "This writes a .ftg.toml with your defaults; every later run in that directory uses it.")
+	var answers initAnswers
+	answers.dir = ask("Directory to map", ".", func(dir string) error {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		return nil
+	})
+	answers.format = ask("Output format ("+strings.Join(initFormats, ", ")+")", formatMarkdown, func(format string) error {
+		if !slices.Contains(initFormats, format) {
+			return fmt.Errorf("choose one of %s", strings.Join(initFormats, ", "))
+		}
+		return nil
+	})
+
+	fmt.Fprintf(out, "Exclude presets (always excluded: %s):\n", strings.Join(ftree.DefaultExcludes, ", "))
+	var suggested []string
+	for i, preset := range initPresets {
+		mark := " "
+		if fileExists(filepath.Join(answers.dir, preset.marker)) {
+			mark = "*"
+			suggested = append(suggested, strconv.Itoa(i+1))
+		}
+		fmt.Fprintf(out, "%4d %s %-7s %s\n", i+1, mark, preset.name, strings.Join(preset.patterns, ", "))
+	}
+	fallback := strings.Join(suggested, ",")
+	if fallback == "" {
+		fallback = "none"
+	}
+	picks := ask("Presets to use (e.g. 1,3, * = found in the directory, none)", fallback, func(answer string) error {
+		if answer == "none" {
+			return nil
+		}
+		_, err := parseSelection(answer, len(initPresets))
+		return err
+	})
+	if picks != "none" {
+		indexes, _ := parseSelection(picks, len(initPresets))
+		for _, i := range indexes {
+			answers.excludes = appendExcludes(answers.excludes, initPresets[i].patterns)
+		}
+	}
+	extra := ask("Other names or patterns to exclude (comma-separated)", "none", func(string) error { return nil })
+	if extra != "none" {
+		answers.excludes = appendExcludes(answers.excludes, strings.Split(extra, ","))
+	}
+
+	depth := ask("Deepest level to show (a number, or all)", "all", func(answer string) error {
+		if answer == "all" {
+			return nil
+		}
+		if n, err := strconv.Atoi(answer); err != nil || n < 1 {
+			return fmt.Errorf("enter a number of at least 1, or all")
+		}
+		return nil
+	})
+	answers.depth, _ = strconv.Atoi(depth)
+	answers.commit = strings.HasPrefix(strings.ToLower(ask("Will you commit the tree to the repository? (y/n)", "n", yesNo)), "y")
+
+	path := filepath.Join(answers.dir, ".ftg.toml")
+	if fileExists(path) && !strings.HasPrefix(strings.ToLower(ask(path+" exists. Replace it? (y/n)", "n", yesNo)), "y") {
+		fmt.Fprintln(out, "Aborted; the existing config was left as it is.")
+		exitProcess(exitFatal)
+	}
+	if err := writeInitConfig(path, answers); err != nil {
+		errorExit(err.Error())
+	}
+	fmt.Fprintf(out, "Wrote %s\n", path)
+	if fileExists(filepath.Join(answers.dir, ".ftg.json")) {
+		fmt.Fprintln(out, "Note: .ftg.toml is read before the .ftg.json that is also in this directory, so the .ftg.json is no longer used.")
+	}
+	fmt.Fprintf(out, "Equivalent command without the config:\n  %s\n", initCommand(answers))
+	if !answers.commit {
+		fmt.Fprintln(out, "Trees get a timestamped name; add file_tree_* to .gitignore to keep them out of commits.")
+	}
+	answers.generate = strings.HasPrefix(strings.ToLower(ask("Generate the tree now? (y/n)", "y", yesNo)), "y")
+	return answers
+}
+
+// appendExcludes adds patterns that are neither blank, already chosen nor default excludes
+func appendExcludes(excludes, patterns []string) []string {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" && !slices.Contains(excludes, pattern) && !slices.Contains(ftree.DefaultExcludes, pattern) {
+			excludes = append(excludes, pattern)
+		}
+	}
+	return excludes
+}
+
+// initOptions returns the answers as config keys and values, in the order they are written
+func initOptions(answers initAnswers) ([]string, map[string]any) {
+	keys := []string{"format"}
+	values := map[string]any{"format": answers.format}
+	if len(answers.excludes) > 0 {
+		keys = append(keys, "exclude")
+		values["exclude"] = strings.Join(answers.excludes, ",")
+	}
+	if answers.depth > 0 {
+		keys = append(keys, "max-depth")
+		values["max-depth"] = json.Number(strconv.Itoa(answers.depth))
+	}
+	if answers.commit {
+		keys = append(keys, "output-template", "skip-unchanged")
+		values["output-template"] = initCommittedTemplate
+		values["skip-unchanged"] = true
+	}
+	return keys, values
+}
+
+// writeInitConfig writes the answers as TOML and reads the file back with the config
+// loader's parser, so a config that would not load the same way is never left behind
+func writeInitConfig(path string, answers initAnswers) error {
+	keys, values := initOptions(answers)
+	var b strings.Builder
+	b.WriteString("# Written by ftg init. Options given on the command line win; see ftg -h for the rest.\n")
+	for _, key := range keys {
+		switch value := values[key].(type) {
+		case json.Number:
+			fmt.Fprintf(&b, "%s = %s\n", key, value)
+		case bool:
+			fmt.Fprintf(&b, "%s = %t\n", key, value)
+		default:
+			fmt.Fprintf(&b, "%s = %s\n", key, strconv.Quote(fmt.Sprint(value)))
+		}
+	}
+	doc, err := parseConfig(path, []byte(b.String()))
+	if err != nil || len(doc) != len(values) {
+		return fmt.Errorf("the config ftg init generated does not parse (%v); please report this", err)
+	}
+	for key, value := range values {
+		if doc[key] != value {
+			return fmt.Errorf("the config ftg init generated reads %s back as %v instead of %v; please report this", key, doc[key], value)
+		}
+	}
+	if err := writeAtomic(path, []byte(b.String())); err != nil {
+		return fmt.Errorf("cannot write %s: %v", path, err)
+	}
+	return nil
+}
+
+// shellWord matches arguments that need no quoting in a POSIX shell
+var shellWord = regexp.MustCompile(`^[A-Za-z0-9_./,:=+@%-]+$`)
+
+// shellQuote quotes an argument for the printed command
+func shellQuote(arg string) string {
+	if shellWord.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// initCommand returns the one-line command that has the effect of the config
+func initCommand(answers initAnswers) string {
+	args := []string{"ftg"}
+	if answers.dir != "." {
+		args = append(args, "-d", answers.dir)
+	}
+	if answers.format != formatMarkdown {
+		args = append(args, "-f", answers.format)
+	}
+	if len(answers.excludes) > 0 {
+		args = append(args, "-e", strings.Join(answers.excludes, ","))
+	}
+	if answers.depth > 0 {
+		args = append(args, "-L", strconv.Itoa(answers.depth))
+	}
+	if answers.commit {
+		args = append(args, "--output-template", initCommittedTemplate, "--skip-unchanged")
+	}
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	return strings.Join(args, " ")
+}
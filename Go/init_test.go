@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// The whole wizard on scripted answers: a wrong answer is asked again, an empty one
+// takes the default, and the config written loads back to the same options as the
+// command it prints
+func TestAskInit(t *testing.T) {
+	dir := testtree.Dir(t, "package.json\nsrc/index.js\n")
+	script := strings.Join([]string{
+		dir,
+		"xml", "text", // A format ftg init does not offer, then one it does
+		"",          // The suggested presets: node, for package.json
+		"tmp, dist", // dist is in the node preset already, node_modules a default exclude
+		"0", "2",
+		"y",
+		"n",
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	answers := askInit(bufio.NewScanner(strings.NewReader(script)), &out)
+	if answers.generate {
+		t.Error("the tree is generated after answering n")
+	}
+	transcript := out.String()
+	for _, want := range []string{
+		"choose one of md, text", "enter a number of at least 1, or all",
+		"Presets to use (e.g. 1,3, * = found in the directory, none) [1]",
+		"Wrote " + filepath.Join(dir, ".ftg.toml"),
+		"-f text -e dist,build,coverage,tmp -L 2 --output-template 'file_tree.{ext}' --skip-unchanged",
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("transcript lacks %q:\n%s", want, transcript)
+		}
+	}
+	config, err := os.ReadFile(filepath.Join(dir, ".ftg.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`format = "text"`, `exclude = "dist,build,coverage,tmp"`, "max-depth = 2", "skip-unchanged = true"} {
+		if !strings.Contains(string(config), want+"\n") {
+			t.Errorf(".ftg.toml lacks %q:\n%s", want, config)
+		}
+	}
+	// The run in the directory reads the config as the options of the printed command
+	fromConfig, stderr, code := runFTG(t, dir, "-d", ".", "--print-config")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	fromCommand, _, _ := runFTG(t, dir, "-d", ".", "-f", "text", "-e", "dist,build,coverage,tmp", "-L", "2",
+		"--output-template", "file_tree.{ext}", "--skip-unchanged", "--no-config", "--print-config")
+	if fromConfig != strings.Replace(fromCommand, "  \"no-config\": true,\n", "", 1) {
+		t.Errorf("with the config:\n%s\nwith the command:\n%s", fromConfig, fromCommand)
+	}
+}
+
+// Without a terminal ftg init explains how to write the config by hand
+func TestInitNeedsTerminal(t *testing.T) {
+	_, stderr, code := runFTG(t, t.TempDir(), "init")
+	if code != exitUsage || !strings.Contains(stderr, "needs a terminal") {
+		t.Errorf("exit code %d: %s", code, stderr)
+	}
+}
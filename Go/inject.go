@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	injectFile    string                                  // --inject: file whose marked section receives the tree
+	injectMarkers = "<!-- ftg:start -->,<!-- ftg:end -->" // --inject-markers: start and end marker, comma-separated
+	injectDryRun  bool                                    // --inject-dry-run: print the change to the --inject file as a diff instead of writing it
+	injectBackup  bool                                    // --inject-backup: keep the --inject file as it was in <file>.bak before changing it
+)
+
+// markerError is an --inject file whose markers are missing, repeated or misordered
+type markerError struct{ error }
+
+// parseInjectMarkers splits --inject-markers into its start and end marker
+func parseInjectMarkers(spec string) (string, string, error) {
+	start, end, ok := strings.Cut(spec, ",")
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	switch {
+	case !ok || start == "" || end == "" || strings.Contains(end, ","):
+		return "", "", fmt.Errorf("--inject-markers takes a start and an end marker separated by a comma, got %q", spec)
+	case start == end || strings.Contains(start, end) || strings.Contains(end, start):
+		return "", "", fmt.Errorf("--inject-markers start and end must differ, and neither may contain the other")
+	}
+	return start, end, nil
+}
+
+// injectRegion finds the bytes between the marker lines: from the line after the
+// start marker to the beginning of the end marker's line. Text around the markers
+// on their own lines is kept. Missing, repeated or misordered markers are
+// errors, since guessing could overwrite the wrong part of the file.
+func injectRegion(data []byte, location, start, end string) (int, int, error) {
+	starts, ends := bytes.Count(data, []byte(start)), bytes.Count(data, []byte(end))
+	hint := fmt.Sprintf("add the lines\n\n%s\n%s\n\nwhere the tree belongs", start, end)
+	switch {
+	case starts == 0 && ends == 0:
+		return 0, 0, fmt.Errorf("%s has no %s and %s markers; %s", location, start, end, hint)
+	case starts == 0:
+		return 0, 0, fmt.Errorf("%s has %s but no %s marker before it; add the start marker rather than letting ftg guess", location, end, start)
+	case ends == 0:
+		return 0, 0, fmt.Errorf("%s has %s but no %s marker after it; add the end marker rather than letting ftg guess", location, start, end)
+	case starts > 1 || ends > 1:
+		return 0, 0, fmt.Errorf("%s has %s and %s; keep exactly one pair of markers",
+			location, plural(starts, "start marker"), plural(ends, "end marker"))
+	}
+	startAt, endAt := bytes.Index(data, []byte(start)), bytes.Index(data, []byte(end))
+	if endAt < startAt {
+		return 0, 0, fmt.Errorf("%s has %s before %s; swap them", location, end, start)
+	}
+	newline := bytes.IndexByte(data[startAt:], '\n')
+	if newline < 0 || startAt+newline > endAt {
+		return 0, 0, fmt.Errorf("%s has %s and %s on one line; put each marker on its own line", location, start, end)
+	}
+	from := startAt + newline + 1
+	to := bytes.LastIndexByte(data[:endAt], '\n') + 1
+	return from, to, nil
+}
+
+// checkInjectTarget fails before the walk when the file cannot take the tree; with
+// --inject-dry-run, markers that are wrong exit with their own code
+func checkInjectTarget(location string) {
+	start, end, err := parseInjectMarkers(injectMarkers)
+	if err != nil {
+		usageExit(err.Error())
+	}
+	data, err := os.ReadFile(location)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read %s for --inject: %v", location, err))
+	}
+	if _, _, err := injectRegion(data, location, start, end); err != nil {
+		if injectDryRun {
+			exitWith(exitMarkers, err.Error())
+		}
+		errorExit(err.Error())
+	}
+}
+
+// injection is an --inject file before and after the tree replaces its section
+type injection struct {
+	target        string      // The file to write, with links followed
+	perm          fs.FileMode // Its permissions, kept when it is replaced
+	before, after []byte
+}
+
+// planInjection reads location and replaces its marked section with the tree in
+// memory. Everything outside the section is kept byte for byte; the tree takes the
+// line ending of the start marker's line, so a CRLF file stays CRLF. A link is
+// followed, so the file it points to is the one to update rather than replace by a
+// copy. Marker problems are a markerError.
+func planInjection(location string, tree []byte) (injection, error) {
+	start, end, _ := parseInjectMarkers(injectMarkers)
+	target, err := filepath.EvalSymlinks(location)
+	if err != nil {
+		return injection{}, fmt.Errorf("cannot read %s for --inject: %v", location, err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return injection{}, fmt.Errorf("cannot read %s for --inject: %v", location, err)
+	}
+	data, err := os.ReadFile(location)
+	if err != nil {
+		return injection{}, fmt.Errorf("cannot read %s for --inject: %v", location, err)
+	}
+	from, to, err := injectRegion(data, location, start, end)
+	if err != nil {
+		return injection{}, markerError{err}
+	}
+	if bytes.HasSuffix(data[:from], []byte("\r\n")) {
+		tree = bytes.ReplaceAll(bytes.ReplaceAll(tree, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+	}
+	updated := append(append(append([]byte(nil), data[:from]...), tree...), data[to:]...)
+	return injection{target: target, perm: info.Mode().Perm(), before: data, after: updated}, nil
+}
+
+// injectTree replaces the marked section of location with the rendered tree and
+// writes the file back atomically, first saving it as location.bak with
+// --inject-backup. The file is re-read here, since it may have changed during the
+// walk, and left alone when the section is already up to date. With
+// --inject-dry-run the change is printed as a unified diff and nothing is written.
+func injectTree(location string, tree []byte) bool {
+	plan, err := planInjection(location, tree)
+	if err != nil {
+		if injectDryRun && errors.As(err, new(markerError)) {
+			exitWith(exitMarkers, err.Error())
+		}
+		warnf("Error: %v", err)
+		return false
+	}
+	if bytes.Equal(plan.after, plan.before) {
+		if !injectDryRun {
+			writtenOutputs = append(writtenOutputs, location)
+		}
+		fmt.Fprintln(messages, msg("status.unchanged", location))
+		return true
+	}
+	if injectDryRun {
+		if _, err := os.Stdout.Write(unifiedDiff(filepath.ToSlash(location), plan.before, plan.after)); err != nil {
+			warnf("Error: cannot write the --inject-dry-run diff: %v", err)
+			return false
+		}
+		fmt.Fprintln(messages, msg("status.dryRun", location))
+		return true
+	}
+	if injectBackup {
+		// The backup is complete before the file itself is touched
+		if err := writeInjected(location+".bak", plan.perm, plan.before); err != nil {
+			warnf("Error: cannot write the --inject-backup copy %s.bak: %v", location, err)
+			return false
+		}
+		writtenOutputs = append(writtenOutputs, location+".bak")
+	}
+	if err := writeInjected(plan.target, plan.perm, plan.after); err != nil {
+		warnf("Error: cannot write to %s: %v", location, err)
+		return false
+	}
+	writtenOutputs = append(writtenOutputs, location)
+	fmt.Fprintln(messages, msg("status.written", location))
+	return true
+}
+
+// writeInjected replaces location atomically with data, giving it perm
+func writeInjected(location string, perm fs.FileMode, data []byte) error {
+	f, err := createAtomic(location)
+	if err != nil {
+		return err
+	}
+	f.perm = perm
+	if _, err := f.Write(data); err != nil {
+		f.abort()
+		return err
+	}
+	return f.commit()
+}
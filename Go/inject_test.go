@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// injectFixture runs --inject on a README.md holding readme, next to the tree it
+// receives, and returns the file afterwards
+func injectFixture(t *testing.T, readme string, args ...string) (string, string, int) {
+	t.Helper()
+	dir := testtree.Dir(t, "tree/src/main.go\n")
+	file := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(file, []byte(readme), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runFTG(t, dir, append([]string{"-d", "tree", "--inject", "README.md", "-q"}, args...)...)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), stderr, code
+}
+
+// Everything outside the markers survives byte for byte, and the tree takes the
+// file's line endings
+func TestInjectRoundTrip(t *testing.T) {
+	tests := []struct {
+		name, before, after, newline string
+	}{
+		{"LF", "# Demo\n\nIntro.\n<!-- ftg:start -->\nold tree\n", "<!-- ftg:end -->\n\n## Usage\n\nTrailing text", "\n"},
+		{"CRLF", "# Demo\r\n\r\nIntro.\r\n<!-- ftg:start -->\r\nold tree\r\n", "<!-- ftg:end -->\r\n\r\n## Usage\r\n\r\nTrailing text\r\n", "\r\n"},
+		{"CRLF without a final newline", "# Demo\r\n<!-- ftg:start -->\r\n", "<!-- ftg:end -->\r\ntrailing  \t", "\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := strings.LastIndex(test.before, "<!-- ftg:start -->")
+			prefix := test.before[:start+len("<!-- ftg:start -->")+len(test.newline)]
+			got, stderr, code := injectFixture(t, test.before+test.after)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			if !strings.HasPrefix(got, prefix) || !strings.HasSuffix(got, test.after) {
+				t.Fatalf("text around the markers changed:\n%q", got)
+			}
+			tree := strings.TrimSuffix(strings.TrimPrefix(got, prefix), test.after)
+			if !strings.Contains(tree, "main.go") || strings.Contains(tree, "old tree") {
+				t.Errorf("section = %q, want the new tree", tree)
+			}
+			if lone := strings.Count(tree, "\n") - strings.Count(tree, "\r\n"); test.newline == "\r\n" && lone != 0 {
+				t.Errorf("%d LF line ends in a CRLF file: %q", lone, tree)
+			}
+			if test.newline == "\n" && strings.Contains(tree, "\r") {
+				t.Errorf("CR in an LF file: %q", tree)
+			}
+		})
+	}
+}
+
+// A second run finds the section up to date and leaves the file as it is
+func TestInjectIsIdempotent(t *testing.T) {
+	dir := testtree.Dir(t, "tree/src/main.go\n")
+	file := filepath.Join(dir, "README.md")
+	os.WriteFile(file, []byte("a\r\n<!-- ftg:start -->\r\n<!-- ftg:end -->\r\nz\r\n"), 0o644)
+	if _, stderr, code := runFTG(t, dir, "-d", "tree", "--inject", "README.md", "-q"); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	first, _ := os.ReadFile(file)
+	runFTG(t, dir, "-d", "tree", "--inject", "README.md", "-q")
+	if again, _ := os.ReadFile(file); !bytes.Equal(again, first) {
+		t.Errorf("the second run changed the file:\n%q\n%q", first, again)
+	}
+}
+
+// Custom markers work like the default ones
+func TestInjectCustomMarkers(t *testing.T) {
+	got, stderr, code := injectFixture(t, "top\n[tree]\n[/tree]\nbottom\n", "--inject-markers", "[tree],[/tree]")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if !strings.HasPrefix(got, "top\n[tree]\n") || !strings.HasSuffix(got, "[/tree]\nbottom\n") || !strings.Contains(got, "main.go") {
+		t.Errorf("got %q", got)
+	}
+}
+
+// Missing or lone markers fail and leave the file alone
+func TestInjectRefusesBadMarkers(t *testing.T) {
+	for _, readme := range []string{
+		"no markers\n",
+		"<!-- ftg:start -->\nonly the start\n",
+		"only the end\n<!-- ftg:end -->\n",
+		"<!-- ftg:end -->\n<!-- ftg:start -->\n",
+	} {
+		got, stderr, code := injectFixture(t, readme)
+		if code == exitOK {
+			t.Errorf("%q: exit code 0, stderr %q", readme, stderr)
+		}
+		if got != readme {
+			t.Errorf("%q was changed to %q", readme, got)
+		}
+	}
+}
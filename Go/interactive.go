@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// interactiveDepth is how many levels -i offers for exclusion
+const interactiveDepth = 2
+
+// interactiveItem is one numbered entry offered by -i
+type interactiveItem struct {
+	rel      string // Path relative to the input directory
+	isDir    bool
+	excluded bool
+}
+
+// interactiveItems lists the top levels of the input directory that the current filters still show
+func interactiveItems(dir string, depth int) []interactiveItem {
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil
+	}
+	var items []interactiveItem
+	for _, entry := range visibleEntries(dir, entries) {
+		rel := relativePath(dir, entry.Name())
+		if shouldExclude(dir, entry) {
+			continue
+		}
+		items = append(items, interactiveItem{rel: rel, isDir: entry.IsDir()})
+		if entry.IsDir() && depth < interactiveDepth {
+			items = append(items, interactiveItems(filepath.Join(dir, entry.Name()), depth+1)...)
+		}
+	}
+	return items
+}
+
+// parseSelection reads "1,3-5 8" into item indexes, rejecting numbers outside 1..count
+func parseSelection(input string, count int) ([]int, error) {
+	var picked []int
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' }) {
+		low, high, isRange := strings.Cut(field, "-")
+		from, err := strconv.Atoi(low)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number or range", field)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(high); err != nil || to < from {
+				return nil, fmt.Errorf("%q is not a valid range", field)
+			}
+		}
+		if from < 1 || to > count {
+			return nil, fmt.Errorf("%s is out of range (1-%d)", field, count)
+		}
+		for n := from; n <= to; n++ {
+			picked = append(picked, n-1)
+		}
+	}
+	return picked, nil
+}
+
+// printItems shows the numbered entries with their exclusion state
+func printItems(writer io.Writer, items []interactiveItem) {
+	for i, item := range items {
+		mark, suffix := " ", ""
+		if item.excluded {
+			mark = "x"
+		}
+		if item.isDir {
+			suffix = "/"
+		}
+		indent := strings.Repeat("  ", strings.Count(item.rel, "/"))
+		fmt.Fprintf(writer, "%4d [%s] %s%s%s\n", i+1, mark, indent, filepath.Base(item.rel), suffix)
+	}
+}
+
+// interactiveMode lets the user toggle exclusions for the top two levels of the input
+// directory with plain line input, then merges the confirmed choices into excludePatterns
+func interactiveMode() {
+	items := interactiveItems(scan.Root, 1)
+	if len(items) == 0 {
+		fmt.Fprintln(messages, "Nothing to select: the input directory has no visible entries.")
+		return
+	}
+	input := bufio.NewScanner(os.Stdin)
+	prompt := func(text string) string {
+		fmt.Fprint(messages, text)
+		if !input.Scan() {
+			fmt.Fprintln(messages)
+			errorExit("interactive mode needs input; aborted")
+		}
+		return strings.TrimSpace(input.Text())
+	}
+
+	for {
+		printItems(messages, items)
+		answer := prompt("Toggle entries (e.g. 1,3-5), enter d when done or q to quit: ")
+		switch strings.ToLower(answer) {
+		case "q":
+			fmt.Fprintln(messages, "Aborted.")
+			exitProcess(exitFatal)
+		case "d", "":
+			excluded := 0
+			for _, item := range items {
+				if item.excluded {
+					excluded++
+				}
+			}
+			noun := "entries"
+			if excluded == 1 {
+				noun = "entry"
+			}
+			if strings.ToLower(prompt(fmt.Sprintf("Exclude %d %s and generate the tree? [y/N] ", excluded, noun))) != "y" {
+				continue
+			}
+			for _, item := range items {
+				if !item.excluded {
+					continue
+				}
+				// Anchor to the input directory so only the chosen entry is hidden
+				addExcludeRule(sourceCLI, "interactive (-i)", "/"+item.rel)
+			}
+			return
+		}
+		picked, err := parseSelection(answer, len(items))
+		if err != nil {
+			fmt.Fprintf(messages, "Invalid selection: %v\n", err)
+			continue
+		}
+		for _, i := range picked {
+			items[i].excluded = !items[i].excluded
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package faultfs wraps an fs.FS so it misbehaves the ways real filesystems do under
+// a walk: directories that cannot be read, entries removed or turned into files
+// between their listing and their reading, nameless entries from broken FUSE
+// drivers, Info errors and short reads. The robustness tests of ftg walk it to check
+// that each of them ends in a warning, never a panic.
+package faultfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"path"
+	"sort"
+	"time"
+)
+
+// Fault is one way a path misbehaves
+type Fault int
+
+const (
+	Permission Fault = iota + 1 // Opening and listing the path fail with fs.ErrPermission
+	Vanished                    // The path is listed but gone: opening it and Info fail with fs.ErrNotExist
+	InfoError                   // Info of the listed entry fails, as lstat can after a listing
+	BecameFile                  // A directory listed as one opens as an empty regular file
+	EmptyName                   // The listing of the directory holds an extra entry with no name
+	ShortReads                  // Listings of the directory and reads of the file return one entry or byte per call
+)
+
+// faultNames are the names String gives the faults
+var faultNames = map[Fault]string{
+	Permission: "permission", Vanished: "vanished", InfoError: "info error",
+	BecameFile: "became a file", EmptyName: "empty name", ShortReads: "short reads",
+}
+
+func (f Fault) String() string {
+	if name, ok := faultNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Fault(%d)", int(f))
+}
+
+// dirFaults and fileFaults are what Random picks from for each kind of path
+var (
+	dirFaults  = []Fault{Permission, Vanished, InfoError, BecameFile, EmptyName, ShortReads}
+	fileFaults = []Fault{Permission, Vanished, InfoError, ShortReads}
+)
+
+// FS is an fs.FS with a fault at some of its paths. It implements only Open, so
+// fs.ReadDir and fs.Stat go through the faults the way they would on a filesystem
+// without shortcuts.
+type FS struct {
+	fsys   fs.FS
+	faults map[string]Fault
+}
+
+// New returns fsys with the faults given by slash-separated path
+func New(fsys fs.FS, faults map[string]Fault) *FS {
+	return &FS{fsys, faults}
+}
+
+// Random returns fsys with a fault at about one in every n of its paths, the fault
+// and the paths chosen by seed, and the faults by path so a test can say which it
+// expected. The root is never faulty.
+func Random(fsys fs.FS, seed uint64, n int) (*FS, map[string]Fault, error) {
+	r := rand.New(rand.NewPCG(seed, seed))
+	faults := map[string]Fault{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." || r.IntN(n) != 0 {
+			return err
+		}
+		choices := fileFaults
+		if d.IsDir() {
+			choices = dirFaults
+		}
+		faults[p] = choices[r.IntN(len(choices))]
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return New(fsys, faults), faults, nil
+}
+
+// Open opens name with its fault, wrapping what fsys returns
+func (f *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	switch f.faults[name] {
+	case Permission:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	case Vanished:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case BecameFile:
+		return &plainFile{name: path.Base(name)}, nil
+	}
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := file.(fs.ReadDirFile); ok {
+		if info, err := dir.Stat(); err == nil && info.IsDir() {
+			return &dirFile{ReadDirFile: dir, fs: f, name: name}, nil
+		}
+	}
+	if f.faults[name] == ShortReads {
+		return shortFile{file}, nil
+	}
+	return file, nil
+}
+
+// dirFile is a directory of FS; its listing carries the faults of its entries
+type dirFile struct {
+	fs.ReadDirFile
+	fs      *FS
+	name    string
+	entries []fs.DirEntry // The rest of the listing, read on the first call of ReadDir
+	read    bool
+}
+
+// ReadDir lists the directory in name order, with the faults of its entries; under
+// ShortReads at most one entry comes back per call, though more were asked for
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := readAll(d.ReadDirFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if fault := d.fs.faults[path.Join(d.name, entry.Name())]; fault == Vanished || fault == InfoError {
+				entry = faultyEntry{entry, fault}
+			}
+			d.entries = append(d.entries, entry)
+		}
+		if d.fs.faults[d.name] == EmptyName {
+			d.entries = append(d.entries, namelessEntry{})
+		}
+		sort.Slice(d.entries, func(i, j int) bool { return d.entries[i].Name() < d.entries[j].Name() })
+		d.read = true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if d.fs.faults[d.name] == ShortReads {
+		n = 1
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// readAll reads the whole listing of a directory
+func readAll(dir fs.ReadDirFile) ([]fs.DirEntry, error) {
+	var all []fs.DirEntry
+	for {
+		entries, err := dir.ReadDir(64)
+		all = append(all, entries...)
+		if err == io.EOF {
+			return all, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// faultyEntry is a listed entry whose Info fails
+type faultyEntry struct {
+	fs.DirEntry
+	fault Fault
+}
+
+func (e faultyEntry) Info() (fs.FileInfo, error) {
+	if e.fault == Vanished {
+		return nil, &fs.PathError{Op: "lstat", Path: e.Name(), Err: fs.ErrNotExist}
+	}
+	return nil, &fs.PathError{Op: "lstat", Path: e.Name(), Err: errors.New("input/output error")}
+}
+
+// namelessEntry is the entry with no name an EmptyName listing holds
+type namelessEntry struct{}
+
+func (namelessEntry) Name() string               { return "" }
+func (namelessEntry) IsDir() bool                { return false }
+func (namelessEntry) Type() fs.FileMode          { return 0 }
+func (namelessEntry) Info() (fs.FileInfo, error) { return plainInfo{}, nil }
+
+// plainFile is the empty regular file a BecameFile directory opens as
+type plainFile struct {
+	name string
+}
+
+func (f *plainFile) Stat() (fs.FileInfo, error) { return plainInfo{f.name}, nil }
+func (f *plainFile) Read([]byte) (int, error)   { return 0, io.EOF }
+func (f *plainFile) Close() error               { return nil }
+
+// plainInfo describes an empty regular file
+type plainInfo struct {
+	name string
+}
+
+func (i plainInfo) Name() string       { return i.name }
+func (i plainInfo) Size() int64        { return 0 }
+func (i plainInfo) Mode() fs.FileMode  { return 0o644 }
+func (i plainInfo) ModTime() time.Time { return time.Time{} }
+func (i plainInfo) IsDir() bool        { return false }
+func (i plainInfo) Sys() any           { return nil }
+
+// shortFile is a file of FS that reads at most one byte per call
+type shortFile struct {
+	fs.File
+}
+
+func (f shortFile) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return f.File.Read(p)
+}
@@ -0,0 +1,101 @@
+package faultfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// base is the filesystem the faults are put on
+var base = fstest.MapFS{
+	"dir/a.txt":     {Data: []byte("abc")},
+	"dir/b.txt":     {Data: []byte("b")},
+	"dir/sub/c.txt": {},
+	"top.txt":       {Data: []byte("top")},
+}
+
+func TestFaults(t *testing.T) {
+	t.Run("permission", func(t *testing.T) {
+		if _, err := fs.ReadDir(New(base, map[string]Fault{"dir": Permission}), "dir"); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("ReadDir err = %v", err)
+		}
+	})
+	t.Run("vanished", func(t *testing.T) {
+		fsys := New(base, map[string]Fault{"dir/sub": Vanished})
+		entries, err := fs.ReadDir(fsys, "dir")
+		if err != nil || len(entries) != 3 {
+			t.Fatalf("the vanished entry is not listed: %v, %v", entries, err)
+		}
+		if _, err := entries[2].Info(); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Info err = %v", err)
+		}
+		if _, err := fs.ReadDir(fsys, "dir/sub"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("ReadDir err = %v", err)
+		}
+	})
+	t.Run("info error", func(t *testing.T) {
+		entries, _ := fs.ReadDir(New(base, map[string]Fault{"dir/a.txt": InfoError}), "dir")
+		if _, err := entries[0].Info(); err == nil {
+			t.Error("Info succeeded")
+		}
+	})
+	t.Run("became a file", func(t *testing.T) {
+		fsys := New(base, map[string]Fault{"dir/sub": BecameFile})
+		entries, _ := fs.ReadDir(fsys, "dir")
+		if !entries[2].IsDir() {
+			t.Error("the directory is not listed as one")
+		}
+		if _, err := fs.ReadDir(fsys, "dir/sub"); err == nil {
+			t.Error("ReadDir succeeded")
+		}
+		if info, err := fs.Stat(fsys, "dir/sub"); err != nil || info.IsDir() {
+			t.Errorf("Stat = %v, %v, want a file", info, err)
+		}
+	})
+	t.Run("empty name", func(t *testing.T) {
+		entries, err := fs.ReadDir(New(base, map[string]Fault{"dir": EmptyName}), "dir")
+		if err != nil || len(entries) != 4 || entries[0].Name() != "" {
+			t.Errorf("entries %v, %v", entries, err)
+		}
+	})
+	t.Run("short reads", func(t *testing.T) {
+		fsys := New(base, map[string]Fault{"dir": ShortReads, "dir/a.txt": ShortReads})
+		f, _ := fsys.Open("dir")
+		if batch, err := f.(fs.ReadDirFile).ReadDir(10); err != nil || len(batch) != 1 {
+			t.Errorf("first batch %v, %v", batch, err)
+		}
+		if entries, err := fs.ReadDir(fsys, "dir"); err != nil || len(entries) != 3 {
+			t.Errorf("whole listing %v, %v", entries, err)
+		}
+		data, err := fs.ReadFile(fsys, "dir/a.txt")
+		if err != nil || string(data) != "abc" {
+			t.Errorf("ReadFile = %q, %v", data, err)
+		}
+		file, _ := fsys.Open("dir/a.txt")
+		if n, _ := file.Read(make([]byte, 8)); n != 1 {
+			t.Errorf("read %d bytes at once", n)
+		}
+		if _, err := io.ReadAll(file); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// The same seed picks the same faults, never at the root
+func TestRandom(t *testing.T) {
+	_, first, err := Random(base, 7, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, second, _ := Random(base, 7, 2)
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("faults %v and %v", first, second)
+	}
+	for p, fault := range first {
+		if second[p] != fault || p == "." {
+			t.Errorf("%s: %v and %v", p, fault, second[p])
+		}
+	}
+}
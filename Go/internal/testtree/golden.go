@@ -0,0 +1,51 @@
+package testtree
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata with the current output")
+
+// Golden compares got with testdata/<name>.golden, relative to the test's package
+// directory. With -update the file is written instead, so a deliberate change of
+// output is one "go test -update" and a reviewed diff of testdata.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	gotLines, wantLines := strings.Split(string(got), "\n"), strings.Split(string(want), "\n")
+	line := 0
+	for line < len(gotLines) && line < len(wantLines) && gotLines[line] == wantLines[line] {
+		line++
+	}
+	t.Errorf("output differs from %s from line %d on\n  got:  %q\n  want: %q\nfull output:\n%s",
+		file, line+1, lineAt(gotLines, line), lineAt(wantLines, line), got)
+}
+
+// lineAt returns line i, or a marker once the text has ended
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return "(end of output)"
+}
@@ -0,0 +1,262 @@
+// Package testtree builds the fixture trees of ftg's tests from a compact description,
+// as an fstest.MapFS or as real files in a temporary directory, and compares output
+// with golden files.
+//
+// A description has one entry per line; blank lines and lines starting with # are
+// skipped and leading indentation is ignored:
+//
+//	src/                          a directory
+//	src/main.go                   an empty file
+//	README.md content="# Demo\n"  a file with this content (a Go string literal)
+//	big.bin size=4096             a file of this many bytes
+//	secret.key mode=0600          permission bits; directories default to 0755, files to 0644
+//	old.log mtime=2020-01-02T03:04:05Z
+//	current -> src                a symbolic link
+//	"tab\there.txt"               a quoted Go string literal for names with spaces or control characters
+//
+// Parent directories are created as needed. Every entry without an mtime gets
+// ModTime, so listings that show times stay reproducible.
+package testtree
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// ModTime is the modification time of entries that do not set one
+var ModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// HostileNames are entry names that renderers must escape or quote; not all of them can
+// be created on every filesystem, so Dir fixtures pick the ones their platform allows
+var HostileNames = []string{
+	"with space.txt",
+	"tab\there.txt",
+	"new\nline.txt",
+	`back\nslash.txt`,
+	"carriage\rreturn.txt",
+	"ansi \x1b[31mred\x1b[0m.txt",
+	`quote"d.txt`,
+	"comma,separated.csv",
+	"-dash-first.txt",
+	"bad\xffutf8.txt",
+	"ünïcödé.txt",
+	"emoji 🐹.txt",
+	"```fence.md",
+	"<script>.js",
+}
+
+// Entry is one line of a description
+type Entry struct {
+	Path    string // Slash-separated path below the root
+	Dir     bool
+	Link    string // Target of a symbolic link
+	Content []byte
+	Mode    fs.FileMode // Permission bits given with mode=
+	ModeSet bool        // mode= was given, so even 0 is applied
+	ModTime time.Time
+}
+
+// Parse reads a description
+func Parse(spec string) ([]Entry, error) {
+	var entries []Entry
+	for number, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseLine reads the path, then an optional "-> target", then key=value attributes
+func parseLine(line string) (Entry, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Path: tokens[0], ModTime: ModTime}
+	if strings.HasSuffix(entry.Path, "/") {
+		entry.Path, entry.Dir = strings.TrimSuffix(entry.Path, "/"), true
+	}
+	if entry.Path == "" || path.Clean(entry.Path) != entry.Path || strings.HasPrefix(entry.Path, "../") {
+		return Entry{}, fmt.Errorf("invalid path %q", tokens[0])
+	}
+	rest := tokens[1:]
+	if len(rest) >= 2 && rest[0] == "->" {
+		if entry.Dir {
+			return Entry{}, fmt.Errorf("%s: a link cannot end in /", entry.Path)
+		}
+		entry.Link, rest = rest[1], rest[2:]
+	}
+	for _, token := range rest {
+		key, value, ok := strings.Cut(token, "=")
+		if !ok {
+			return Entry{}, fmt.Errorf("%s: %q is not key=value", entry.Path, token)
+		}
+		switch key {
+		case "content":
+			entry.Content = []byte(value)
+		case "size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 0 {
+				return Entry{}, fmt.Errorf("%s: invalid size %q", entry.Path, value)
+			}
+			entry.Content = bytes.Repeat([]byte("x"), size)
+		case "mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || mode > 0o777 {
+				return Entry{}, fmt.Errorf("%s: invalid mode %q", entry.Path, value)
+			}
+			entry.Mode, entry.ModeSet = fs.FileMode(mode), true
+		case "mtime":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return Entry{}, fmt.Errorf("%s: invalid mtime %q", entry.Path, value)
+			}
+			entry.ModTime = t
+		default:
+			return Entry{}, fmt.Errorf("%s: unknown attribute %q", entry.Path, key)
+		}
+	}
+	if entry.Dir && entry.Content != nil {
+		return Entry{}, fmt.Errorf("%s: a directory has no content", entry.Path)
+	}
+	return entry, nil
+}
+
+// tokenize splits a line at spaces; a token, or the value of key=, may be a quoted Go string
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	for line = strings.TrimLeft(line, " \t"); line != ""; line = strings.TrimLeft(line, " \t") {
+		prefix := ""
+		if key, value, ok := strings.Cut(line, "="); ok && strings.HasPrefix(value, `"`) && !strings.ContainsAny(key, " \t\"") {
+			prefix, line = key+"=", value
+		}
+		if strings.HasPrefix(line, `"`) {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("bad quoting in %q", line)
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens, line = append(tokens, prefix+text), line[len(quoted):]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		tokens, line = append(tokens, prefix+line[:end]), line[end:]
+	}
+	return tokens, nil
+}
+
+// mustParse parses a description or fails the test
+func mustParse(t testing.TB, spec string) []Entry {
+	t.Helper()
+	entries, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("testtree: %v", err)
+	}
+	return entries
+}
+
+// MapFS builds the description as an in-memory filesystem
+func MapFS(t testing.TB, spec string) fstest.MapFS {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for _, entry := range mustParse(t, spec) {
+		file := &fstest.MapFile{Data: entry.Content, ModTime: entry.ModTime, Mode: entry.Mode}
+		switch {
+		case entry.Link != "":
+			file.Data, file.Mode = []byte(entry.Link), fs.ModeSymlink|0o777
+		case entry.Dir:
+			file.Mode = fs.ModeDir | entry.perm(0o755)
+		default:
+			file.Mode = entry.perm(0o644)
+		}
+		fsys[entry.Path] = file
+	}
+	return fsys
+}
+
+// Dir builds the description in a new temporary directory and returns its path. Modes
+// and directory times are applied once everything exists, deepest first, so a
+// read-only directory can still be filled and keeps the time it was given. The test
+// is skipped where symbolic links cannot be created.
+func Dir(t testing.TB, spec string) string {
+	t.Helper()
+	root := t.TempDir()
+	entries := mustParse(t, spec)
+	for _, entry := range entries {
+		full := filepath.Join(root, filepath.FromSlash(entry.Path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("testtree: %v", err)
+		}
+		var err error
+		switch {
+		case entry.Link != "":
+			if err = os.Symlink(filepath.FromSlash(entry.Link), full); err != nil {
+				t.Skipf("testtree: cannot create symbolic links here: %v", err)
+			}
+		case entry.Dir:
+			err = os.MkdirAll(full, 0o755)
+		default:
+			err = os.WriteFile(full, entry.Content, 0o644)
+		}
+		if err != nil {
+			t.Fatalf("testtree: %v", err)
+		}
+	}
+
+	// Deepest first, so setting a directory's time comes after its children changed it
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.Count(entries[i].Path, "/") > strings.Count(entries[j].Path, "/")
+	})
+	for _, entry := range entries {
+		if entry.Link != "" {
+			continue
+		}
+		full := filepath.Join(root, filepath.FromSlash(entry.Path))
+		if err := os.Chtimes(full, entry.ModTime, entry.ModTime); err != nil {
+			t.Fatalf("testtree: %v", err)
+		}
+		if entry.ModeSet {
+			if err := os.Chmod(full, entry.Mode); err != nil {
+				t.Fatalf("testtree: %v", err)
+			}
+		}
+	}
+	// Let the temporary directory be removed even below modes like 0500
+	t.Cleanup(func() {
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				os.Chmod(p, 0o755)
+			}
+			return nil
+		})
+	})
+	return root
+}
+
+// perm returns the permission bits of the entry, or fallback when it set none
+func (e Entry) perm(fallback fs.FileMode) fs.FileMode {
+	if !e.ModeSet {
+		return fallback
+	}
+	return e.Mode
+}
@@ -0,0 +1,119 @@
+package testtree
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const sample = `
+# a comment
+src/
+src/main.go content="package main\n"
+big.bin size=5
+secret.key mode=0600
+old.log mtime=2020-01-02T03:04:05Z
+current -> src
+"tab\there.txt"
+"with space/inner.txt"
+`
+
+func TestParse(t *testing.T) {
+	entries, err := Parse(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Path: "src", Dir: true, ModTime: ModTime},
+		{Path: "src/main.go", Content: []byte("package main\n"), ModTime: ModTime},
+		{Path: "big.bin", Content: []byte("xxxxx"), ModTime: ModTime},
+		{Path: "secret.key", Mode: 0o600, ModeSet: true, ModTime: ModTime},
+		{Path: "old.log", ModTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Path: "current", Link: "src", ModTime: ModTime},
+		{Path: "tab\there.txt", ModTime: ModTime},
+		{Path: "with space/inner.txt", ModTime: ModTime},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, entry := range entries {
+		w := want[i]
+		if entry.Path != w.Path || entry.Dir != w.Dir || entry.Link != w.Link || string(entry.Content) != string(w.Content) ||
+			entry.Mode != w.Mode || entry.ModeSet != w.ModeSet || !entry.ModTime.Equal(w.ModTime) {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, w)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"../escape",
+		"a//b",
+		"file size=big",
+		"file mode=999",
+		"file mtime=yesterday",
+		"file colour=red",
+		"dir/ content=x",
+		"dir/ -> target",
+		`"unterminated`,
+		"file loose",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestMapFS(t *testing.T) {
+	fsys := MapFS(t, sample)
+	data, err := fs.ReadFile(fsys, "src/main.go")
+	if err != nil || string(data) != "package main\n" {
+		t.Errorf("src/main.go = %q, %v", data, err)
+	}
+	info, err := fs.Stat(fsys, "secret.key")
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("secret.key mode = %v, %v", info.Mode(), err)
+	}
+	if target, err := fsys.ReadLink("current"); err != nil || target != "src" {
+		t.Errorf("current -> %q, %v", target, err)
+	}
+	entries, err := fs.ReadDir(fsys, "with space")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "inner.txt" {
+		t.Errorf("with space holds %v, %v", entries, err)
+	}
+}
+
+func TestDir(t *testing.T) {
+	root := Dir(t, sample+"locked/ mode=0500\nlocked/inside.txt\n")
+	data, err := os.ReadFile(filepath.Join(root, "src", "main.go"))
+	if err != nil || string(data) != "package main\n" {
+		t.Errorf("src/main.go = %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(root, "old.log"))
+	if err != nil || !info.ModTime().Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("old.log mtime = %v, %v", info.ModTime(), err)
+	}
+	info, err = os.Stat(filepath.Join(root, "src"))
+	if err != nil || !info.ModTime().Equal(ModTime) {
+		t.Errorf("src mtime = %v after its child was written, want %v", info.ModTime(), ModTime)
+	}
+	if target, err := os.Readlink(filepath.Join(root, "current")); err != nil || target != "src" {
+		t.Errorf("current -> %q, %v", target, err)
+	}
+	if runtime.GOOS != "windows" {
+		info, err = os.Stat(filepath.Join(root, "locked"))
+		if err != nil || info.Mode().Perm() != 0o500 {
+			t.Errorf("locked mode = %v, %v", info.Mode(), err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "locked", "inside.txt")); err != nil {
+			t.Errorf("locked/inside.txt was not created: %v", err)
+		}
+	}
+}
+
+func TestGoldenMatches(t *testing.T) {
+	Golden(t, "golden", []byte("stored output\n"))
+}
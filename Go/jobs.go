@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+var (
+	jobsAuto = false // --jobs auto: adapt the number of concurrent reads to the filesystem
+	jobsMin  = 1     // --jobs-min: fewest concurrent reads with --jobs auto
+	jobsMax  = 64    // --jobs-max: most concurrent reads with --jobs auto
+)
+
+// jobsValue is the --jobs flag: a number of concurrent reads, or "auto"
+type jobsValue struct{}
+
+// String returns the current setting
+func (jobsValue) String() string {
+	if jobsAuto {
+		return "auto"
+	}
+	return strconv.Itoa(walkJobs)
+}
+
+// Set accepts "auto" or a number
+func (jobsValue) Set(value string) error {
+	if value == "auto" {
+		jobsAuto = true
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("use a number or auto")
+	}
+	walkJobs, jobsAuto = n, false
+	return nil
+}
+
+// Tuning of the --jobs auto controller
+const (
+	jobsStart     = 4    // Reads in flight before anything is measured
+	jobsWindow    = 16   // Reads per decision
+	jobsErrorRate = 0.10 // Share of failed reads in a window that halves the limit
+	jobsSlowdown  = 2.0  // Median latency over the baseline that shrinks the limit by a quarter
+	jobsFlat      = 1.25 // Median latency over the baseline still counted as unloaded, growing the limit
+)
+
+// jobsController adapts the number of concurrent reads to how the filesystem copes,
+// the way TCP congestion control does: it grows while latency stays near the best
+// seen, shrinks by a quarter when latency climbs because reads queue up on the
+// server, and halves when reads start failing. Decisions are taken once per window
+// of reads, so one slow directory does not move it. It only sees the numbers it is
+// fed, so latency traces can drive it without a filesystem.
+type jobsController struct {
+	min, max    int
+	limit, peak int
+	adjustments int
+	baseline    time.Duration   // Best window median; re-learned at the lower bound, so a lasting slowdown becomes the new normal
+	latencies   []time.Duration // Current window
+	failures    int
+}
+
+// newJobsController starts at a modest limit within the bounds
+func newJobsController(lo, hi int) *jobsController {
+	start := min(max(jobsStart, lo), hi)
+	return &jobsController{min: lo, max: hi, limit: start, peak: start}
+}
+
+// observe records one read and returns the new limit with the reason, or "" when the
+// limit did not change
+func (c *jobsController) observe(latency time.Duration, failed bool) (int, string) {
+	c.latencies = append(c.latencies, latency)
+	if failed {
+		c.failures++
+	}
+	if len(c.latencies) < jobsWindow {
+		return c.limit, ""
+	}
+	slices.Sort(c.latencies)
+	median := c.latencies[len(c.latencies)/2]
+	errorRate := float64(c.failures) / float64(len(c.latencies))
+	c.latencies, c.failures = c.latencies[:0], 0
+	if c.baseline == 0 || median < c.baseline || c.limit == c.min {
+		c.baseline = median
+	}
+
+	limit, reason := c.limit, ""
+	switch {
+	case errorRate > jobsErrorRate:
+		limit, reason = c.limit/2, fmt.Sprintf("%.0f%% of reads failed", 100*errorRate)
+	case float64(median) > jobsSlowdown*float64(c.baseline):
+		limit, reason = c.limit-max(1, c.limit/4), fmt.Sprintf("median read %s, best %s", median.Round(time.Microsecond), c.baseline.Round(time.Microsecond))
+	case float64(median) <= jobsFlat*float64(c.baseline):
+		limit, reason = c.limit+max(1, c.limit/4), fmt.Sprintf("median read %s stays near best %s", median.Round(time.Microsecond), c.baseline.Round(time.Microsecond))
+	}
+	limit = min(max(limit, c.min), c.max)
+	if limit == c.limit {
+		return c.limit, ""
+	}
+	c.limit = limit
+	c.peak = max(c.peak, limit)
+	c.adjustments++
+	return limit, reason
+}
+
+// strainError reports whether a failed read says the filesystem is struggling, as I/O
+// errors and timeouts do; missing or forbidden directories, and huge ones left to the
+// walk, say nothing about load
+func strainError(err error) bool {
+	return err != nil && !os.IsPermission(err) && !os.IsNotExist(err) && !errors.Is(err, syscall.ENOTDIR) && err != errStreamed
+}
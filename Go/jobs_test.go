@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// window feeds the controller one window of reads taking latency, the first failed
+// of them failing, and returns the limit and reason of the decision
+func window(c *jobsController, latency time.Duration, failed int) (int, string) {
+	limit, reason := c.limit, ""
+	for i := range jobsWindow {
+		limit, reason = c.observe(latency, i < failed)
+	}
+	return limit, reason
+}
+
+// The controller grows while reads stay near the best latency seen, holds in between,
+// shrinks by a quarter when latency doubles and halves when reads fail, and decides
+// once per window
+func TestJobsControllerTrace(t *testing.T) {
+	ms := time.Millisecond
+	c := newJobsController(1, 64)
+	if c.limit != jobsStart {
+		t.Fatalf("starts at %d, want %d", c.limit, jobsStart)
+	}
+	for i := range jobsWindow - 1 {
+		if limit, reason := c.observe(50*ms, true); limit != jobsStart || reason != "" {
+			t.Fatalf("read %d decided %d (%s) before the window was full", i+1, limit, reason)
+		}
+	}
+	c = newJobsController(1, 64)
+	for _, step := range []struct {
+		latency time.Duration
+		failed  int
+		limit   int
+		reason  string
+	}{
+		{ms, 0, 5, "median read 1ms stays near best 1ms"},
+		{ms, 0, 6, "median read 1ms stays near best 1ms"},
+		{ms, 0, 7, "median read 1ms stays near best 1ms"},
+		{ms, 0, 8, "median read 1ms stays near best 1ms"},
+		{ms, 0, 10, "median read 1ms stays near best 1ms"},
+		{ms * 3 / 2, 0, 10, ""}, // Slower, not yet queueing
+		{3 * ms, 0, 8, "median read 3ms, best 1ms"},
+		{3 * ms, 0, 6, "median read 3ms, best 1ms"},
+		{ms, 3, 3, "19% of reads failed"},
+		{ms, 1, 4, "median read 1ms stays near best 1ms"}, // One failure in a window is noise
+		{ms / 2, 0, 5, "median read 500µs stays near best 500µs"},
+	} {
+		if limit, reason := window(c, step.latency, step.failed); limit != step.limit || reason != step.reason {
+			t.Errorf("%s with %d failed: %d (%s), want %d (%s)", step.latency, step.failed, limit, reason, step.limit, step.reason)
+		}
+	}
+	if c.peak != 10 || c.adjustments != 10 {
+		t.Errorf("peak %d after %d adjustments, want 10 after 10", c.peak, c.adjustments)
+	}
+}
+
+// The limit stays within --jobs-min and --jobs-max, and at the lower bound a lasting
+// slowdown becomes the new baseline, so the controller grows again from there
+func TestJobsControllerBounds(t *testing.T) {
+	ms := time.Millisecond
+	for lo, hi := range map[int]int{8: 64, 1: 2, 4: 4} {
+		if c := newJobsController(lo, hi); c.limit != min(max(jobsStart, lo), hi) {
+			t.Errorf("bounds %d..%d: starts at %d", lo, hi, c.limit)
+		}
+	}
+
+	c := newJobsController(2, 6)
+	var limits []string
+	for _, step := range []struct {
+		latency time.Duration
+		failed  int
+	}{{ms, 0}, {ms, 0}, {ms, 0}, {ms, 8}, {ms, 8}, {ms, 8}, {10 * ms, 0}, {10 * ms, 0}, {10 * ms, 0}} {
+		limit, _ := window(c, step.latency, step.failed)
+		limits = append(limits, fmt.Sprint(limit))
+	}
+	// Up to the bound, halved down to the other, then 10ms is the best there is
+	if got, want := strings.Join(limits, " "), "5 6 6 3 2 2 3 4 5"; got != want {
+		t.Errorf("limits %s, want %s", got, want)
+	}
+	if c.baseline != 10*ms {
+		t.Errorf("baseline %s, want 10ms", c.baseline)
+	}
+}
+
+// Only failures that say the filesystem is struggling feed the error rate
+func TestStrainError(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&fs.PathError{Op: "open", Path: "d", Err: fs.ErrPermission}, false},
+		{&fs.PathError{Op: "open", Path: "d", Err: fs.ErrNotExist}, false},
+		{&fs.PathError{Op: "open", Path: "d", Err: syscall.ENOTDIR}, false},
+		{errStreamed, false},
+		{&fs.PathError{Op: "readdirent", Path: "d", Err: syscall.EIO}, true},
+		{&fs.PathError{Op: "open", Path: "d", Err: os.ErrDeadlineExceeded}, true},
+		{errors.New("stale file handle"), true},
+	} {
+		if got := strainError(test.err); got != test.want {
+			t.Errorf("%v: %v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+// --jobs auto draws the tree --jobs 1 draws and reports the concurrency it reached
+func TestJobsAuto(t *testing.T) {
+	var tree strings.Builder
+	for d := range 40 {
+		fmt.Fprintf(&tree, "d%02d/sub/f.txt\n", d)
+	}
+	dir := testtree.Dir(t, tree.String())
+	serial, _, _ := runFTG(t, dir, "-d", ".", "--jobs", "1", "-o", "-", "--quiet", "--no-summary")
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "--jobs", "auto", "--jobs-max", "8", "--report-resources", "-o", "-", "--quiet", "--no-summary")
+	if code != exitOK || stdout != serial {
+		t.Errorf("exit code %d, got\n%s\nwant\n%s", code, stdout, serial)
+	}
+	if !strings.Contains(stderr, "  Concurrent reads:  ") || !strings.Contains(stderr, " at peak, ") || !strings.Contains(stderr, "(--jobs auto)\n") {
+		t.Errorf("no concurrency in the resource report:\n%s", stderr)
+	}
+
+	for _, args := range [][]string{{"--jobs-min", "0"}, {"--jobs-min", "9", "--jobs-max", "8"}} {
+		if _, stderr, code := runFTG(t, dir, append([]string{"--jobs", "auto", "-o", "-"}, args...)...); code != exitUsage ||
+			!strings.Contains(stderr, "--jobs-min must be at least 1 and no more than --jobs-max") {
+			t.Errorf("%q: exit code %d, %s", args, code, stderr)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// formatJSON selects the nested JSON tree
+const formatJSON = "json"
+
+// jsonIndent is --json-indent: the spaces per level of -f json and -f manifest
+var jsonIndent = 2
+
+// marshalJSON encodes a JSON snapshot canonically, so the same tree gives the same
+// bytes: struct fields in declaration order, map keys sorted, jsonIndent spaces per
+// level and a final newline
+func marshalJSON(v any) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetIndent("", strings.Repeat(" ", jsonIndent))
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// jsonNode is one entry of the -f json tree
+type jsonNode struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`                // "dir", "file" or "link"
+	Icon     string      `json:"icon,omitempty"`      // --icons glyph, kept out of the name
+	Status   string      `json:"gitStatus,omitempty"` // --git-status letter: M, A or D
+	Children []*jsonNode `json:"children,omitempty"`
+	Elided   int         `json:"elided,omitempty"` // Entries left out by --sample
+	Error    string      `json:"error,omitempty"`  // Why a directory could not be listed
+
+	Checksum      string `json:"checksum,omitempty"`      // Whole --checksum digest, e.g. "sha256:…"
+	ChecksumError string `json:"checksumError,omitempty"` // Why a file has no digest
+
+	Size      *int64 `json:"size,omitempty"`      // Bytes with -s, and for directories with --dir-sizes
+	DiskUsage *int64 `json:"diskUsage,omitempty"` // Bytes on disk with --disk-usage or --size-both; a directory has size or this
+	Sparse    bool   `json:"sparse,omitempty"`    // Takes much less room on disk than its size
+	MTime     string `json:"mtime,omitempty"`     // --mtime, RFC 3339 in UTC
+	BirthTime string `json:"birthTime,omitempty"` // --btime, where the platform records it
+	Media     string `json:"media,omitempty"`     // --media-info dimensions or duration, e.g. "640×480"
+	InFlux    bool   `json:"inFlux,omitempty"`    // --skip-active: modified during the scan
+	Orphan    bool   `json:"orphan,omitempty"`    // --find-orphans matched the entry
+
+	IdenticalTo    string `json:"identicalTo,omitempty"`    // --dedupe-subtrees: the earlier directory with the same contents
+	AlreadyShownAt string `json:"alreadyShownAt,omitempty"` // --dedupe-mounts: where the same directory was shown first
+	NotScanned     bool   `json:"notScanned,omitempty"`     // --budget ran out before the directory was listed
+
+	// The rest is set on the root only
+	Meta        *jsonMeta           `json:"meta,omitempty"`        // Always, even with --no-summary
+	NameStats   *jsonNameStats      `json:"nameStats,omitempty"`   // --name-stats
+	Groups      []*jsonGroup        `json:"groups,omitempty"`      // --group-by, in place of children
+	InFluxCount *int                `json:"inFluxCount,omitempty"` // --skip-active
+	Orphans     map[string][]string `json:"orphans,omitempty"`     // --find-orphans paths per rule
+	Usage       []usageCell         `json:"usage,omitempty"`       // --usage-by
+	Provenance  *provenanceRecord   `json:"provenance,omitempty"`  // --provenance
+	Rules       []ruleReportRow     `json:"rules,omitempty"`       // --explain-excludes
+	Redacted    *int                `json:"redacted,omitempty"`    // Entries redaction renamed
+}
+
+// jsonMeta describes how the tree was made
+type jsonMeta struct {
+	Filters      filterRecord      `json:"filters"`
+	Completeness *jsonCompleteness `json:"completeness,omitempty"` // When directories could not be entered
+}
+
+// jsonGroup is one --group-by section: the group's files in their tree
+type jsonGroup struct {
+	Key      string      `json:"key"`
+	Files    int         `json:"files"`
+	Bytes    int64       `json:"bytes"`
+	Children []*jsonNode `json:"children"`
+	Elided   int         `json:"elided,omitempty"`
+}
+
+// jsonCompleteness is the coverage estimate of a scan that could not enter every directory
+type jsonCompleteness struct {
+	Percent      float64 `json:"percent"`
+	Inaccessible int     `json:"inaccessible"`
+}
+
+// jsonTypes maps the tree's type letters to JSON type names
+var jsonTypes = map[string]string{"D": "dir", "F": "file", "L": "link"}
+
+// buildJSONChildren converts a listing into nodes through walkTree, with the filters
+// and exclusions of every other format
+func buildJSONChildren(ctx context.Context, dir string, entries []fs.DirEntry) ([]*jsonNode, int, error) {
+	top := &jsonNode{}
+	parents := []*jsonNode{top} // The directory whose entries the walk is in, last
+	err := walkTree(ctx, dir, entries, func(e treeEntry) error {
+		parent := parents[len(parents)-1]
+		switch e.event {
+		case walkElided:
+			parent.Elided = e.elided
+		case walkOpen:
+			parents = append(parents, parent.Children[len(parent.Children)-1])
+		case walkClose:
+			parents = parents[:len(parents)-1]
+		case walkFailed:
+			parent.Children[len(parent.Children)-1].Error = e.err.Error()
+		case walkEntry:
+			name := e.entry.Name()
+			fullPath := filepath.Join(e.dir, name)
+			safeName, redacted := redactName(name)
+			if redacted {
+				redactedCount++
+			}
+			node := &jsonNode{Name: safeName, Type: jsonTypes[e.entryType]}
+			node.Checksum, node.ChecksumError = checksumField(fullPath, e.entry)
+			annotateJSON(node, e.dir, e.entry)
+			node.Icon = entryIcon(safeName, e.entry.IsDir() || e.descend)
+			node.Status = gitStatuses[e.rel]
+			parent.Children = append(parent.Children, node)
+			if e.visitedAt != "" {
+				node.AlreadyShownAt = redactPath(displayPath(e.visitedAt))
+			}
+			if e.identicalTo != "" {
+				node.IdenticalTo = redactPath(displayPath(e.identicalTo))
+			}
+			node.NotScanned = e.notScanned
+		}
+		return nil
+	})
+	if top.Children == nil {
+		top.Children = []*jsonNode{}
+	}
+	return top.Children, top.Elided, err
+}
+
+// annotateJSON sets the fields the tree shows as notes after an entry's name
+func annotateJSON(node *jsonNode, dir string, entry fs.DirEntry) {
+	fullPath := filepath.Join(dir, entry.Name())
+	if entry.IsDir() {
+		if size, shown, _ := shownSize(dir, entry); shown && diskUsage {
+			node.DiskUsage = &size
+		} else if shown {
+			node.Size = &size
+		}
+	} else if apparent, allocated, ok, err := entrySizes(fullPath, entry); showSizes && ok && err == nil {
+		node.Size = &apparent
+		if diskUsage || sizeBoth {
+			node.DiskUsage = &allocated
+		}
+		node.Sparse = isSparse(apparent, allocated)
+	}
+	if info, err := entryInfo(entry); err == nil {
+		if showMTime {
+			node.MTime = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		if born, ok := birthTime(fullPath, info); ok && showBirthTime {
+			node.BirthTime = born.UTC().Format(time.RFC3339)
+		}
+	}
+	node.Media = strings.TrimSuffix(strings.TrimPrefix(mediaNote(dir, entry), "("), ")")
+	node.InFlux = inFluxNote(fullPath, entry) != ""
+	node.Orphan = orphanNote(dir, entry.Name(), entry.IsDir()) != ""
+}
+
+// renderJSON returns the tree rooted at the input directory as indented JSON
+func renderJSON(ctx context.Context, root string, entries []fs.DirEntry) ([]byte, error) {
+	node := &jsonNode{Name: redactText(rootName(root)), Type: "dir"}
+	var walkErr error
+	if groupBy != "" {
+		node.Groups = []*jsonGroup{}
+		eachGroup(ctx, root, func(group *fileGroup, entries []fs.DirEntry) {
+			section := &jsonGroup{Key: redactText(group.key), Files: group.files, Bytes: group.bytes, Children: []*jsonNode{}}
+			if entries != nil && walkErr == nil {
+				section.Children, section.Elided, walkErr = buildJSONChildren(ctx, root, entries)
+			}
+			node.Groups = append(node.Groups, section)
+		})
+	} else {
+		node.Children, node.Elided, walkErr = buildJSONChildren(ctx, root, entries)
+	}
+	if walkErr != nil {
+		return walkFailure(ctx, walkErr)
+	}
+	node.Meta = &jsonMeta{Filters: collectFilters()}
+	if nameStatsEnabled {
+		node.NameStats = nameStatsJSON()
+	}
+	if inaccessible := counters.unreadable + counters.vanished; inaccessible > 0 {
+		node.Meta.Completeness = &jsonCompleteness{estimateCompleteness(counters.readable, inaccessible), inaccessible}
+	}
+	if skipActive > 0 {
+		node.InFluxCount = &inFluxCount
+	}
+	if findOrphans {
+		node.Orphans = orphanPaths()
+	}
+	if usageEnabled {
+		node.Usage = usageCells()
+	}
+	if provenanceFlag {
+		record := collectProvenance(root, scanStarted, time.Now())
+		node.Provenance = &record
+	}
+	if explainExcludes {
+		node.Rules = ruleReport()
+	}
+	if redactionEnabled() {
+		node.Redacted = &redactedCount
+	}
+	return marshalJSON(node)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// jsonFixture has a merge leftover, a small GIF, a file modified after the scan
+// starts and two directories with the same contents
+const jsonFixture = `
+a/
+a/x.txt content="same"
+b/
+b/x.txt content="same"
+fix.orig size=3
+logo.gif content="GIF89a\x02\x00\x03\x00\x00\x00\x00"
+live.log size=5 mtime=2099-01-01T00:00:00Z
+`
+
+// renderJSONFixture renders jsonFixture as -f json and decodes the result
+func renderJSONFixture(t *testing.T, configure func()) (string, jsonNode) {
+	t.Helper()
+	got := renderFixture(t, testtree.MapFS(t, jsonFixture), true, func() {
+		setOption(t, &outputFormat, formatJSON)
+		if configure != nil {
+			configure()
+		}
+	})
+	var root jsonNode
+	if err := json.Unmarshal([]byte(got), &root); err != nil {
+		t.Fatalf("decode: %v\n%s", err, got)
+	}
+	return got, root
+}
+
+// child returns the node with this name among nodes, failing the test without one
+func child(t *testing.T, nodes []*jsonNode, name string) *jsonNode {
+	t.Helper()
+	for _, node := range nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	t.Fatalf("no entry %q", name)
+	return nil
+}
+
+// Every option that annotates the md tree must change the JSON tree too
+func TestJSONCarriesAnnotations(t *testing.T) {
+	plain, _ := renderJSONFixture(t, nil)
+	tests := []struct {
+		name      string
+		configure func(*testing.T)
+		check     func(*testing.T, jsonNode)
+	}{
+		{"-s", func(t *testing.T) { setOption(t, &showSizes, true) }, func(t *testing.T, root jsonNode) {
+			if size := child(t, root.Children, "fix.orig").Size; size == nil || *size != 3 {
+				t.Errorf("fix.orig size = %v, want 3", size)
+			}
+			if child(t, root.Children, "a").Size != nil {
+				t.Error("a directory has a size without --dir-sizes")
+			}
+		}},
+		{"--dir-sizes", func(t *testing.T) { setOption(t, &dirSizes, true) }, func(t *testing.T, root jsonNode) {
+			if size := child(t, root.Children, "a").Size; size == nil || *size != 4 {
+				t.Errorf("a size = %v, want 4", size)
+			}
+		}},
+		{"--size-both", func(t *testing.T) { setOption(t, &showSizes, true); setOption(t, &sizeBoth, true) }, func(t *testing.T, root jsonNode) {
+			file := child(t, root.Children, "fix.orig")
+			if file.Size == nil || *file.Size != 3 || file.DiskUsage == nil || *file.DiskUsage != 3 || file.Sparse {
+				t.Errorf("fix.orig size %v, disk usage %v, sparse %v; want 3, its length in memory, and false", file.Size, file.DiskUsage, file.Sparse)
+			}
+		}},
+		{"--disk-usage --dir-sizes", func(t *testing.T) {
+			setOption(t, &showSizes, true)
+			setOption(t, &diskUsage, true)
+			setOption(t, &dirSizes, true)
+		}, func(t *testing.T, root jsonNode) {
+			if a := child(t, root.Children, "a"); a.Size != nil || a.DiskUsage == nil || *a.DiskUsage != 4 {
+				t.Errorf("a size %v, disk usage %v; want only a disk usage of 4", a.Size, a.DiskUsage)
+			}
+		}},
+		{"--mtime", func(t *testing.T) { setOption(t, &showMTime, true) }, func(t *testing.T, root jsonNode) {
+			if got := child(t, root.Children, "fix.orig").MTime; got != "2024-01-02T03:04:05Z" {
+				t.Errorf("mtime = %q", got)
+			}
+		}},
+		{"--media-info", func(t *testing.T) { setOption(t, &showMediaInfo, true) }, func(t *testing.T, root jsonNode) {
+			if got := child(t, root.Children, "logo.gif").Media; got != "2×3" {
+				t.Errorf("media = %q, want 2×3", got)
+			}
+		}},
+		{"--find-orphans", func(t *testing.T) { setOption(t, &findOrphans, true) }, func(t *testing.T, root jsonNode) {
+			if !child(t, root.Children, "fix.orig").Orphan {
+				t.Error("fix.orig is not marked as an orphan")
+			}
+			if hits := root.Orphans["merge leftover"]; len(hits) != 1 || hits[0] != "fix.orig" {
+				t.Errorf("orphans = %v", root.Orphans)
+			}
+		}},
+		{"--skip-active", func(t *testing.T) { setOption(t, &skipActive, time.Second) }, func(t *testing.T, root jsonNode) {
+			if !child(t, root.Children, "live.log").InFlux || child(t, root.Children, "fix.orig").InFlux {
+				t.Error("only live.log should be in flux")
+			}
+			if root.InFluxCount == nil || *root.InFluxCount != 1 {
+				t.Errorf("inFluxCount = %v, want 1", root.InFluxCount)
+			}
+		}},
+		{"--dedupe-subtrees", func(t *testing.T) { setOption(t, &dedupeSubtrees, true) }, func(t *testing.T, root jsonNode) {
+			b := child(t, root.Children, "b")
+			if b.IdenticalTo != "a" || len(b.Children) != 0 {
+				t.Errorf("b = %+v, want identicalTo a without children", b)
+			}
+			if len(child(t, root.Children, "a").Children) != 1 {
+				t.Error("a is not shown in full")
+			}
+		}},
+		{"--usage-by", func(t *testing.T) { setOption(t, &usageBy, []string{"ext"}); setOption(t, &usageEnabled, true) }, func(t *testing.T, root jsonNode) {
+			if len(root.Usage) == 0 || root.Usage[0].Row != "gif" || root.Usage[0].Bytes != 13 {
+				t.Errorf("usage = %+v, want gif with 13 bytes first", root.Usage)
+			}
+		}},
+		{"--provenance", func(t *testing.T) { setOption(t, &provenanceFlag, true) }, func(t *testing.T, root jsonNode) {
+			if root.Provenance == nil || root.Provenance.Started == "" || len(root.Provenance.Rules) == 0 {
+				t.Errorf("provenance = %+v", root.Provenance)
+			}
+		}},
+		{"--explain-excludes", func(t *testing.T) { setOption(t, &explainExcludes, true) }, func(t *testing.T, root jsonNode) {
+			if len(root.Rules) == 0 || root.Rules[0].Source != sourceDefaults || root.Rules[0].Pattern != "node_modules" {
+				t.Errorf("rules = %+v, want the default rules", root.Rules)
+			}
+		}},
+		{"--redact", func(t *testing.T) { setOption(t, &redactPatterns, []string{"*.orig"}) }, func(t *testing.T, root jsonNode) {
+			if root.Redacted == nil || *root.Redacted != 1 {
+				t.Errorf("redacted = %v, want 1", root.Redacted)
+			}
+		}},
+		{"--group-by", func(t *testing.T) { setOption(t, &groupBy, "ext") }, func(t *testing.T, root jsonNode) {
+			if len(root.Children) != 0 || len(root.Groups) != 4 {
+				t.Fatalf("got %d children and %d groups, want the four groups only", len(root.Children), len(root.Groups))
+			}
+			txt := root.Groups[3]
+			if txt.Key != "txt" || txt.Files != 2 || txt.Bytes != 8 || len(txt.Children) != 2 {
+				t.Errorf("txt group = %+v", txt)
+			}
+			child(t, root.Groups[0].Children, "logo.gif")
+			if len(root.Groups[0].Children) != 1 {
+				t.Errorf("gif group holds %d entries, want logo.gif only", len(root.Groups[0].Children))
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, root := renderJSONFixture(t, func() { test.configure(t) })
+			if got == plain {
+				t.Fatalf("%s leaves the JSON tree unchanged", test.name)
+			}
+			test.check(t, root)
+		})
+	}
+}
+
+// Provenance times change on every run and must not change the fingerprint
+func TestJSONProvenanceTimesAreVolatile(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, jsonFixture), false, func() {
+		setOption(t, &outputFormat, formatJSON)
+		setOption(t, &provenanceFlag, true)
+	})
+	recorded, ok := recordedFingerprint([]byte(got))
+	if !ok {
+		t.Fatalf("no fingerprint in\n%s", got)
+	}
+	later := regexp.MustCompile(`(?m)^(    "(?:started|finished)": )".*"`).ReplaceAllString(got, `$1"2099-01-01T00:00:00Z"`)
+	if later == got {
+		t.Fatal("no provenance times in the output")
+	}
+	if sum := contentFingerprint([]byte(later)); sum != recorded {
+		t.Errorf("other scan times change the fingerprint: %s, recorded %s", sum, recorded)
+	}
+}
+
+// The same tree gives the same JSON bytes whatever order its listings arrive in, with
+// the map-valued and list-valued root fields every option adds
+func TestJSONCanonical(t *testing.T) {
+	spec := jsonFixture + "c/z.go size=7\nc/a.go size=9\nc/m.txt size=1\nc/b/ÿ.md\nc/b/A.md\nc/b/a.md\n"
+	configure := func() {
+		setOption(t, &outputFormat, formatJSON)
+		setOption(t, &showSizes, true)
+		setOption(t, &nameStatsEnabled, true)
+		setOption(t, &explainExcludes, true)
+		setOption(t, &usageEnabled, true)
+		setOption(t, &usageBy, []string{"ext"})
+	}
+	first := renderFixture(t, testtree.MapFS(t, spec), false, configure)
+	for seed := range int64(5) {
+		setOption(t, &shuffleListings, rand.New(rand.NewSource(seed)))
+		if again := renderFixture(t, testtree.MapFS(t, spec), false, configure); again != first {
+			t.Fatalf("shuffled listings (seed %d) change the JSON:\n%s\nfirst:\n%s", seed, again, first)
+		}
+	}
+}
+
+// --json-indent sets the spaces per level, and the fingerprint is still found under it
+func TestJSONIndent(t *testing.T) {
+	for _, indent := range []int{1, 2, 4} {
+		got := renderFixture(t, testtree.MapFS(t, jsonFixture), false, func() {
+			setOption(t, &outputFormat, formatJSON)
+			setOption(t, &jsonIndent, indent)
+		})
+		level := strings.Repeat(" ", indent)
+		if !strings.Contains(got, "\n"+level+`"name": "fixture",`+"\n") || !strings.Contains(got, "\n"+level+level+level+`"name": "a",`) {
+			t.Errorf("--json-indent %d:\n%s", indent, got)
+		}
+		if recorded, ok := recordedFingerprint([]byte(got)); !ok || recorded != contentFingerprint([]byte(got)) {
+			t.Errorf("--json-indent %d: fingerprint %q (%v), content %s", indent, recorded, ok, contentFingerprint([]byte(got)))
+		}
+	}
+	if _, stderr, code := runFTG(t, t.TempDir(), "-f", "json", "--json-indent", "0", "-o", "-"); code != exitUsage || !strings.Contains(stderr, "between 1 and 8") {
+		t.Errorf("--json-indent 0: exit code %d, %s", code, stderr)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/fs"
+	"path"
+)
+
+// keepSet holds the paths to render, keyed by their slash-separated path relative
+// to the input directory. Files map to an optional annotation, ancestor
+// directories map to "".
+type keepSet map[string]string
+
+// keepFilter restricts the walk to a set of paths and their ancestors; nil disables it
+var keepFilter keepSet
+
+// add records a path and all of its ancestor directories
+func (k keepSet) add(rel, note string) {
+	k[rel] = note
+	for dir := path.Dir(rel); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if _, ok := k[dir]; !ok {
+			k[dir] = ""
+		}
+	}
+}
+
+// isKept reports whether a path survives the keep filter
+func isKept(rel string) bool {
+	if keepFilter == nil {
+		return true
+	}
+	_, ok := keepFilter[rel]
+	return ok
+}
+
+// filterKept drops entries that are not part of the keep filter
+func filterKept(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if keepFilter == nil {
+		return entries
+	}
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		if isKept(relativePath(dir, entry.Name())) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	diffContext = 3         // Unchanged lines shown around each change in a unified diff
+	diffCells   = 4_000_000 // Largest line table compared line by line; beyond it the differing middle is replaced whole
+)
+
+// diffLine is one line of a line diff: ' ' kept, '-' removed or '+' added
+type diffLine struct {
+	op   byte
+	text []byte
+}
+
+// splitLines splits data into lines that keep their line endings
+func splitLines(data []byte) [][]byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineEdits returns the shortest edit turning lines a into lines b. The common head
+// and tail are matched first, so the table of the longest common subsequence only
+// covers the lines that differ, as in a regenerated section of a README.
+func lineEdits(a, b [][]byte) []diffLine {
+	head := 0
+	for head < len(a) && head < len(b) && bytes.Equal(a[head], b[head]) {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && bytes.Equal(a[len(a)-1-tail], b[len(b)-1-tail]) {
+		tail++
+	}
+	edits := make([]diffLine, 0, len(a)+len(b))
+	for _, line := range a[:head] {
+		edits = append(edits, diffLine{' ', line})
+	}
+	oldMid, newMid := a[head:len(a)-tail], b[head:len(b)-tail]
+	if (len(oldMid)+1)*(len(newMid)+1) > diffCells {
+		for _, line := range oldMid {
+			edits = append(edits, diffLine{'-', line})
+		}
+		for _, line := range newMid {
+			edits = append(edits, diffLine{'+', line})
+		}
+	} else {
+		// common[i][j] is the longest common subsequence of oldMid[i:] and newMid[j:]
+		width := len(newMid) + 1
+		common := make([]int, (len(oldMid)+1)*width)
+		for i := len(oldMid) - 1; i >= 0; i-- {
+			for j := len(newMid) - 1; j >= 0; j-- {
+				if bytes.Equal(oldMid[i], newMid[j]) {
+					common[i*width+j] = common[(i+1)*width+j+1] + 1
+				} else {
+					common[i*width+j] = max(common[(i+1)*width+j], common[i*width+j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(oldMid) || j < len(newMid) {
+			switch {
+			case i < len(oldMid) && j < len(newMid) && bytes.Equal(oldMid[i], newMid[j]):
+				edits = append(edits, diffLine{' ', oldMid[i]})
+				i, j = i+1, j+1
+			case j == len(newMid) || i < len(oldMid) && common[(i+1)*width+j] >= common[i*width+j+1]:
+				edits = append(edits, diffLine{'-', oldMid[i]})
+				i++
+			default:
+				edits = append(edits, diffLine{'+', newMid[j]})
+				j++
+			}
+		}
+	}
+	for _, line := range a[len(a)-tail:] {
+		edits = append(edits, diffLine{' ', line})
+	}
+	return edits
+}
+
+// unifiedDiff returns the changes from before to after in the unified format that
+// patch and git apply read, naming both sides name; "" when nothing changed
+func unifiedDiff(name string, before, after []byte) []byte {
+	return unifiedHunks(name, name, lineEdits(splitLines(before), splitLines(after)))
+}
+
+// unifiedHunks formats edits as the hunks of a unified diff from oldName to newName,
+// with diffContext lines around each change; "" when there is none. A '!' line of
+// ftg diff -f unified stands for a line of each side.
+func unifiedHunks(oldName, newName string, edits []diffLine) []byte {
+	// oldAt[k] and newAt[k] count the lines of each side before edit k
+	oldAt, newAt := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for k, edit := range edits {
+		oldAt[k+1], newAt[k+1] = oldAt[k], newAt[k]
+		if edit.op != '+' {
+			oldAt[k+1]++
+		}
+		if edit.op != '-' {
+			newAt[k+1]++
+		}
+	}
+	var out bytes.Buffer
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		// Changes closer than twice the context share one hunk
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*diffContext {
+				break
+			}
+			end = run
+		}
+		from, to := max(0, k-diffContext), min(len(edits), end+diffContext)
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldAt[from], oldAt[to]-oldAt[from]), hunkRange(newAt[from], newAt[to]-newAt[from]))
+		for _, edit := range edits[from:to] {
+			out.WriteByte(edit.op)
+			out.Write(edit.text)
+			if !bytes.HasSuffix(edit.text, []byte("\n")) {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = to
+	}
+	return out.Bytes()
+}
+
+// hunkRange formats the start and length of one side of a hunk the way diff -u does:
+// the length left out when it is 1, and an empty side starting at the line before it
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
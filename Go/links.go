@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	checkLinks  string                                                      // --check-links: file type whose relative links are checked ("md")
+	brokenLinks = map[string][]string{}                                     // Missing link targets per file, by relative path
+	linkTargets = regexp.MustCompile(`!?\[[^\]]*\]\(\s*(<[^>]*>|[^)\s]+)`)  // Inline links and images
+	linkDefs    = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*(<[^>]*>|\S+)`) // Reference definitions
+	urlScheme   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*:`)
+)
+
+// collectLinks walks the tree before it is rendered, the way grepSelection does, and
+// records the broken relative links of every markdown file. Targets are resolved
+// against the paths the walk lists, not by stat calls, so a link to an excluded path
+// counts as broken. Directories below --max-depth are still listed, since links
+// into them are valid even when the tree does not show them.
+func collectLinks(root string, rootEntries []fs.DirEntry) {
+	present := map[string]bool{"": true}
+	var documents []string
+	var walk func(dir string, entries []fs.DirEntry)
+	walk = func(dir string, entries []fs.DirEntry) {
+		for _, entry := range visibleEntries(dir, entries) {
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			name := entry.Name()
+			rel := relativePath(dir, name)
+			present[rel] = true
+			fullPath := filepath.Join(dir, name)
+			if !entry.IsDir() && !shouldDescend(fullPath, entry) {
+				if strings.EqualFold(path.Ext(name), ".md") {
+					documents = append(documents, rel)
+				}
+				continue
+			}
+			subEntries, ok := listingCache[fullPath]
+			if !ok {
+				var err error
+				if subEntries, err = readDir(fullPath); err != nil {
+					// The walk reads it again and reports the error
+					continue
+				}
+				listingCache[fullPath] = subEntries
+			}
+			walk(fullPath, subEntries)
+		}
+	}
+	walk(root, rootEntries)
+
+	for _, doc := range documents {
+		data, err := readFile(filepath.Join(root, filepath.FromSlash(doc)))
+		if err != nil {
+			warnf("Cannot read %s to check its links: %v", doc, err)
+			continue
+		}
+		for _, target := range markdownLinks(string(data)) {
+			if resolved, ok := resolveLink(doc, target); ok && !present[resolved] {
+				brokenLinks[doc] = append(brokenLinks[doc], target)
+			}
+		}
+	}
+}
+
+// markdownLinks returns the link and image targets of a markdown document, skipping
+// fenced code blocks and inline code
+func markdownLinks(text string) []string {
+	var targets []string
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		line = stripCodeSpans(line)
+		if match := linkDefs.FindStringSubmatch(line); match != nil {
+			targets = append(targets, strings.Trim(match[1], "<>"))
+			continue
+		}
+		for _, match := range linkTargets.FindAllStringSubmatch(line, -1) {
+			targets = append(targets, strings.Trim(match[1], "<>"))
+		}
+	}
+	return targets
+}
+
+// stripCodeSpans removes `inline code` so link syntax inside it is not taken for a link
+func stripCodeSpans(line string) string {
+	for {
+		start := strings.Index(line, "`")
+		if start < 0 {
+			return line
+		}
+		end := strings.Index(line[start+1:], "`")
+		if end < 0 {
+			return line
+		}
+		line = line[:start] + line[start+1+end+1:]
+	}
+}
+
+// resolveLink maps a link target of doc to the relative path it points at. URLs,
+// pure anchors and links leaving the input directory are not checked; a leading "/"
+// is taken relative to the input directory, as on code hosting sites.
+func resolveLink(doc, target string) (string, bool) {
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "//") || urlScheme.MatchString(target) {
+		return "", false
+	}
+	if i := strings.IndexAny(target, "#?"); i >= 0 {
+		target = target[:i]
+	}
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	var resolved string
+	if strings.HasPrefix(target, "/") {
+		resolved = path.Clean(strings.TrimPrefix(target, "/"))
+	} else {
+		resolved = path.Clean(path.Join(path.Dir(doc), target))
+	}
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+	if resolved == "." {
+		resolved = ""
+	}
+	return resolved, true
+}
+
+// linkNote returns the annotation of a file with broken links
+func linkNote(rel string) string {
+	if len(brokenLinks[rel]) == 0 {
+		return ""
+	}
+	return msgCount("note.brokenLinks", len(brokenLinks[rel]))
+}
+
+// writeBrokenLinks appends every missing target per file
+func writeBrokenLinks(writer io.Writer) {
+	fmt.Fprintf(writer, "\n%s\n\n", msg("links.heading"))
+	if len(brokenLinks) == 0 {
+		fmt.Fprintln(writer, msg("links.none"))
+		return
+	}
+	docs := make([]string, 0, len(brokenLinks))
+	for doc := range brokenLinks {
+		docs = append(docs, doc)
+	}
+	sort.Strings(docs)
+	for _, doc := range docs {
+		fmt.Fprintf(writer, "- %s\n", redactPath(displayPath(doc)))
+		for _, target := range brokenLinks[doc] {
+			fmt.Fprintf(writer, "  - %s\n", codeSpan(redactPath(target)))
+		}
+	}
+}
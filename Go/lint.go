@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+var lintFilters bool // --lint-filters: after the walk, warn about exclusion rules that can be simplified
+
+// lintRuleState is what the walk saw of one rule: how many entries it matched, how
+// many of those it decided, and which rules decided the rest
+type lintRuleState struct {
+	matched  int
+	decided  int
+	deciders map[string]bool
+}
+
+// lintMatch is a rule matching an entry, with the description findings cite it by
+type lintMatch struct {
+	key         string
+	description string
+}
+
+var (
+	lintStates = map[string]*lintRuleState{} // By lintKey, or by origin for ignore-file rules
+	lintSeen   = map[string]bool{}           // Entries already counted, since some listings are filtered twice
+)
+
+// lintKey identifies a rule of a layer by its source and position
+func lintKey(source string, index int) string {
+	return fmt.Sprintf("%s#%d", source, index)
+}
+
+// lintState returns the record of a rule, creating it on first use
+func lintState(key string) *lintRuleState {
+	state, ok := lintStates[key]
+	if !ok {
+		state = &lintRuleState{deciders: map[string]bool{}}
+		lintStates[key] = state
+	}
+	return state
+}
+
+// ruleMatches reports whether a layer rule matches an entry, as ruleLayer.match tests it
+func ruleMatches(rule excludeRule, rel, name string) bool {
+	if ftree.IsLiteral(rule.pattern) {
+		return rule.pattern == name
+	}
+	return ftree.MatchPattern(rule.pattern, matchPath(rel), name)
+}
+
+// lintEntry records every rule that matches an entry and the one that decides it.
+// Sources are tried in --rules-order and the last match within a source wins, as in
+// decidingRule, so every other match was shadowed by the decider.
+func lintEntry(rel, name string, isDir bool) {
+	if lintSeen[rel] {
+		return
+	}
+	lintSeen[rel] = true
+	var matches []lintMatch
+	decider := -1
+	for _, source := range rulesOrder {
+		before := len(matches)
+		if source == sourceIgnoreFiles {
+			matches = append(matches, ignoreFileMatches(rel, isDir)...)
+		} else if l, ok := ruleLayers[source]; ok {
+			for i, rule := range l.rules {
+				if ruleMatches(rule, rel, name) {
+					matches = append(matches, lintMatch{lintKey(source, i), rule.describe()})
+				}
+			}
+		}
+		if decider < 0 && len(matches) > before {
+			decider = len(matches) - 1
+		}
+	}
+	for i, match := range matches {
+		state := lintState(match.key)
+		state.matched++
+		if i == decider {
+			state.decided++
+		} else {
+			state.deciders[matches[decider].description] = true
+		}
+	}
+}
+
+// ignoreFileMatches returns the ignore-file rules matching an entry in the order
+// ignoreFileRule weighs them: .gitignore lines from the root down, then an
+// export-ignore, then the .dockerignore rule, which wins over all of them
+func ignoreFileMatches(rel string, isDir bool) []lintMatch {
+	var matches []lintMatch
+	if useGitignore {
+		parts := strings.Split(rel, "/")
+		dirs := []string{""}
+		for i := 1; i < len(parts); i++ {
+			dirs = append(dirs, strings.Join(parts[:i], "/"))
+		}
+		for _, dir := range dirs {
+			for _, rule := range ignoreRules[dir] {
+				if rule.matches(rel, isDir) {
+					matches = append(matches, lintMatch{rule.source(), rule.source()})
+				}
+			}
+		}
+	}
+	if exportIgnore {
+		if rule, found := exportIgnoreRule(rel); found && rule.set {
+			origin := fmt.Sprintf("%s (%s export-ignore)", displayPath(rule.attributesFile()), rule.pattern)
+			matches = append(matches, lintMatch{origin, origin})
+		}
+	}
+	if useDockerignore {
+		if rule, found := dockerignoreRule(rel, isDir); found {
+			matches = append(matches, lintMatch{rule.origin, rule.origin})
+		}
+	}
+	return matches
+}
+
+// lintCandidate is a rule the duplicate check compares
+type lintCandidate struct {
+	key         string
+	description string
+	source      string
+	canonical   string // Patterns with the same canonical form match the same entries
+}
+
+// lintCanonical returns the form under which two rules match the same entries: a
+// pattern with a "/" is a path from the root with or without the outer slashes
+func lintCanonical(pattern string, include bool) string {
+	if strings.Contains(pattern, "/") {
+		pattern = "/" + strings.Trim(pattern, "/")
+	}
+	if include {
+		return "!" + pattern
+	}
+	return pattern
+}
+
+// lintCandidates lists the rules in precedence order. Of the ignore files only the
+// root .gitignore is compared: its lines are the ones -e patterns repeat, and a
+// directory-only line matches fewer entries than any -e pattern.
+func lintCandidates() []lintCandidate {
+	var candidates []lintCandidate
+	for _, source := range rulesOrder {
+		if source == sourceIgnoreFiles {
+			for _, rule := range ignoreRules[""] {
+				if rule.dirOnly {
+					continue
+				}
+				pattern := rule.pattern
+				if rule.anchored {
+					pattern = "/" + pattern
+				}
+				candidates = append(candidates, lintCandidate{rule.source(), rule.source(), source, lintCanonical(pattern, rule.negate)})
+			}
+			continue
+		}
+		if l, ok := ruleLayers[source]; ok {
+			for i, rule := range l.rules {
+				candidates = append(candidates, lintCandidate{lintKey(source, i), rule.describe(), source, lintCanonical(rule.pattern, rule.include)})
+			}
+		}
+	}
+	return candidates
+}
+
+// userSource reports whether a source holds rules given to ftg, which the lint may
+// suggest removing; defaults and ignore files are only cited
+func userSource(source string) bool {
+	return source == sourceCLI || source == sourcePresets
+}
+
+// reportFilterLint warns about the rules that can go: duplicates of another rule,
+// rules that matched nothing the walk reached, and rules whose every match was
+// decided by a rule of higher precedence. Entries inside excluded directories are
+// never reached, so a rule only matching there counts as matching nothing.
+func reportFilterLint() {
+	findings := 0
+	reported := map[string]bool{}
+
+	candidates := lintCandidates()
+	groups := map[string][]lintCandidate{}
+	var order []string
+	for _, c := range candidates {
+		if _, ok := groups[c.canonical]; !ok {
+			order = append(order, c.canonical)
+		}
+		groups[c.canonical] = append(groups[c.canonical], c)
+	}
+	for _, canonical := range order {
+		group := groups[canonical]
+		if len(group) < 2 {
+			continue
+		}
+		var user, others []lintCandidate
+		for _, c := range group {
+			if userSource(c.source) {
+				user = append(user, c)
+			} else {
+				others = append(others, c)
+			}
+		}
+		if len(user) == 0 {
+			continue
+		}
+		for _, c := range group {
+			reported[c.key] = true
+		}
+		var cited []string
+		for _, c := range group {
+			cited = append(cited, c.description)
+		}
+		suggestion := "keep one of them and remove the rest"
+		if len(others) > 0 {
+			suggestion = fmt.Sprintf("remove %s; %s already covers it", lintList(user), others[0].description)
+		}
+		findings++
+		warnf("Filter lint (duplicate): %s match the same entries; %s", strings.Join(cited, ", "), suggestion)
+	}
+
+	for _, c := range candidates {
+		if !userSource(c.source) || reported[c.key] {
+			continue
+		}
+		state := lintStates[c.key]
+		switch {
+		case state == nil || state.matched == 0:
+			findings++
+			warnf("Filter lint (unused): %s matched nothing the walk reached; remove it, or check its spelling", c.description)
+		case state.decided == 0:
+			var deciders []string
+			for decider := range state.deciders {
+				deciders = append(deciders, decider)
+			}
+			sort.Strings(deciders)
+			findings++
+			warnf("Filter lint (shadowed): %s matched %s, all decided by %s; remove it",
+				c.description, treeCount(state.matched, "entry", "entries"), strings.Join(deciders, ", "))
+		}
+	}
+	if findings == 0 {
+		fmt.Fprintln(messages, "Filter lint: no redundant, unused or shadowed exclusion rules")
+	}
+}
+
+// lintList joins the descriptions of candidates for a suggestion
+func lintList(candidates []lintCandidate) string {
+	var descriptions []string
+	for _, c := range candidates {
+		descriptions = append(descriptions, c.description)
+	}
+	return strings.Join(descriptions, " and ")
+}
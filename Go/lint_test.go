@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// The lint reads the hits of the walk, which every format makes
+func TestLintFiltersWorksForEveryFormat(t *testing.T) {
+	dir := testtree.Dir(t, "src/main.go\nbuild/out.o\n")
+	for _, format := range []string{"md", "text", "json", "html", "csv", "tsv", "md-list", "mermaid", "svg", "manifest"} {
+		t.Run(format, func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, "-o", "-", "-f", format, "--lint-filters", "-e", "build,nothing-here")
+			if code != exitWarnings {
+				t.Fatalf("exit code %d, want %d for the lint warning (stderr %q)", code, exitWarnings, stderr)
+			}
+			if !strings.Contains(stdout+stderr, "Filter lint (unused): ") || !strings.Contains(stdout+stderr, "nothing-here") {
+				t.Errorf("no unused finding for nothing-here:\n%s", stderr)
+			}
+			if strings.Contains(stdout+stderr, `"build" (user (-e)) matched nothing`) {
+				t.Errorf("build is reported unused although it hid build/")
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockFile does nothing where flock is unavailable; records are still appended
+// with a single write to a file opened for appending
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile releases a lock taken by lockFile
+func unlockFile(f *os.File) error {
+	return nil
+}
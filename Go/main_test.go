@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// TestMain runs ftg itself instead of the tests when runFTG starts the test binary,
+// or a --pipe helper when a test names one
+func TestMain(m *testing.M) {
+	if len(os.Args) == 3 && os.Args[1] == pipeHelperArg {
+		pipeHelper(os.Args[2])
+	}
+	if os.Getenv("FTG_TEST_RUN_MAIN") == "1" {
+		os.Args = append([]string{"ftg"}, os.Args[1:]...)
+		main()
+		exitProcess(exitOK)
+	}
+	os.Exit(m.Run())
+}
+
+// runFTG runs ftg with args in dir, away from the user's config, and returns its
+// stdout, stderr and exit code
+func runFTG(t *testing.T, dir string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := ftgCommand(t, dir, args...)
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		t.Fatalf("run ftg: %v", err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// ftgCommand is the command runFTG runs, for a test that starts or stops it itself
+func ftgCommand(t *testing.T, dir string, args ...string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	home := t.TempDir()
+	cmd.Env = append(os.Environ(), "FTG_TEST_RUN_MAIN=1", "FTG_NO_UPDATE_CHECK=1", "HOME="+home, "XDG_CONFIG_HOME="+home, "APPDATA="+home,
+		"XDG_CACHE_HOME="+home, "LOCALAPPDATA="+home)
+	return cmd
+}
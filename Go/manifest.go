@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// formatManifest selects the flat file manifest for compliance tooling
+const formatManifest = "manifest"
+
+// manifestSchema is the JSON Schema of -f manifest, printed by --manifest-schema.
+// Fields may be added within a schemaVersion; anything else bumps it.
+//
+//go:embed manifest.schema.json
+var manifestSchema []byte
+
+var manifestAllowPartial bool // --manifest-allow-partial: write files that cannot be read with a null sha256 instead of failing
+
+// manifestDoc is the -f manifest document
+type manifestDoc struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Root          string          `json:"root"`
+	Generated     string          `json:"generated"`
+	Files         []manifestFile  `json:"files"`
+	Summary       manifestSummary `json:"summary"`
+}
+
+// manifestFile is one regular file of the tree
+type manifestFile struct {
+	Path       string  `json:"path"`
+	Size       int64   `json:"size"`
+	SHA256     *string `json:"sha256"`
+	MimeType   string  `json:"mimeType"`
+	Executable bool    `json:"executable"`
+}
+
+// manifestSummary totals the files of the manifest
+type manifestSummary struct {
+	Files     int            `json:"files"`
+	Bytes     int64          `json:"bytes"`
+	Unhashed  int            `json:"unhashed"`
+	MimeTypes map[string]int `json:"mimeTypes"`
+}
+
+// manifestFailure is a file whose checksum could not be computed
+type manifestFailure struct {
+	rel string
+	err error
+}
+
+// renderManifest returns every regular file of the tree with its checksum and type, in
+// the order of --traversal.
+// Unlike the trees there is no sampling, so nothing is left out silently; a file
+// that cannot be read fails the run unless --manifest-allow-partial is given.
+func renderManifest(ctx context.Context, root string, entries []fs.DirEntry) ([]byte, error) {
+	doc := manifestDoc{
+		SchemaVersion: 1,
+		Root:          redactPath(virtualPath(root)),
+		Generated:     time.Now().UTC().Format(time.RFC3339),
+		Files:         []manifestFile{},
+		Summary:       manifestSummary{MimeTypes: map[string]int{}},
+	}
+	var failures []manifestFailure
+	err := walkTree(ctx, root, entries, func(e treeEntry) error {
+		switch {
+		case e.event == walkFailed:
+			failures = append(failures, manifestFailure{e.rel, e.err})
+		case e.event == walkEntry && e.entry.Type().IsRegular() && !isDeleted(e.entry):
+			file, err := manifestEntry(filepath.Join(e.dir, e.entry.Name()), e.entry)
+			if err != nil {
+				failures = append(failures, manifestFailure{e.rel, err})
+				doc.Summary.Unhashed++
+			}
+			doc.Files = append(doc.Files, file)
+			doc.Summary.Files++
+			doc.Summary.Bytes += file.Size
+			if file.MimeType != "" {
+				doc.Summary.MimeTypes[file.MimeType]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return walkFailure(ctx, err)
+	}
+
+	if len(failures) > 0 && !manifestAllowPartial {
+		first := failures[0]
+		return nil, fmt.Errorf("%s could not be read for the manifest (%s: %s); use --manifest-allow-partial to write it anyway",
+			plural(len(failures), "path"), redactPath(displayPath(first.rel)), readErrorReason(first.err))
+	}
+	return marshalJSON(doc)
+}
+
+// manifestEntry hashes a file and sniffs its type from the first bytes, in one read
+func manifestEntry(fullPath string, entry fs.DirEntry) (manifestFile, error) {
+	if _, redacted := redactName(entry.Name()); redacted {
+		redactedCount++
+	}
+	file := manifestFile{Path: filepath.ToSlash(redactPath(displayPath(relativePath(filepath.Dir(fullPath), entry.Name()))))}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return file, err
+	}
+	file.Size = info.Size()
+	file.Executable = isExecutable(entry.Name(), info.Mode())
+	f, err := openPath(fullPath)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	head := &cappedBuffer{limit: 512}
+	if _, err := io.Copy(io.MultiWriter(h, head), f); err != nil {
+		return file, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	file.SHA256 = &sum
+	file.MimeType = http.DetectContentType(head.Bytes())
+	return file, nil
+}
+
+// isExecutable reports whether a file can be run: any execute bit, or on Windows,
+// where there are none, an executable extension
+func isExecutable(name string, mode fs.FileMode) bool {
+	if modeBitsMeaningful() {
+		return mode.Perm()&0o111 != 0
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".exe", ".com", ".bat", ".cmd", ".ps1":
+		return true
+	}
+	return false
+}
+
+// showManifestSchema prints the schema of -f manifest and exits
+func showManifestSchema() {
+	os.Stdout.Write(bytes.TrimSpace(manifestSchema))
+	fmt.Println()
+	exitProcess(exitOK)
+}
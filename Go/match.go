@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// Pattern semantics shared by -e and --only, as ftree.MatchPattern implements them:
+//   - a pattern without "/" matches the entry name at any depth; "*", "?" and
+//     "[...]" are wildcards, and a literal name is a plain lookup
+//   - a pattern containing "/" matches the whole path relative to the input
+//     directory, or to --relative-to when set (a leading "/" is optional); "**"
+//     spans zero or more directories, so "src/**/__pycache__" hides
+//     src/__pycache__ and src/a/b/__pycache__
+var (
+	onlyPatterns []string            // --only patterns; when set only matching paths and their ancestors are shown
+	onlyExts     = map[string]bool{} // --only-ext extensions, lower case with the dot; files with them count as --only matches
+	onlyMemo     = map[string]bool{} // Whether a directory contains an --only match, by relative path
+)
+
+// excludingPattern returns the label of the rule that hides an entry, if any.
+// Dotfiles no rule decides on are then left to --hidden; "!pattern" keeps them too.
+func excludingPattern(rel, name string, isDir bool) (string, bool) {
+	rule, _, found := decidingRule(rel, name, isDir)
+	if !found && dotfileHidden(rel, name) {
+		return hiddenLabel, true
+	}
+	if !found || rule.include {
+		return "", false
+	}
+	if rule.fromFile {
+		excludeSources[rule.label()] = ignoreFileOrigin
+	}
+	return rule.label(), true
+}
+
+// shouldExclude reports whether an entry of dir is hidden by the exclusion rules
+func shouldExclude(dir string, entry fs.DirEntry) bool {
+	loadIgnoreFiles(dir)
+	_, excluded := excludingPattern(relativePath(dir, entry.Name()), entry.Name(), entry.IsDir())
+	return excluded
+}
+
+// filterExcluded drops the entries hidden by exclusion patterns and counts them per
+// pattern. Renderers call it before choosing connectors, so the last visible entry
+// gets the last-branch connector even when excluded names sort after it.
+func filterExcluded(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	loadIgnoreFiles(dir)
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		if lintFilters {
+			lintEntry(relativePath(dir, entry.Name()), entry.Name(), entry.IsDir())
+		}
+		if pattern, excluded := excludingPattern(relativePath(dir, entry.Name()), entry.Name(), entry.IsDir()); excluded {
+			counters.excludedBy[pattern]++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// matchesOnly reports whether an entry or one of its ancestors matches an --only pattern
+func matchesOnly(rel string) bool {
+	for current := matchPath(rel); current != "." && current != "/"; current = path.Dir(current) {
+		for _, pattern := range onlyPatterns {
+			if ftree.MatchPattern(pattern, current, path.Base(current)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// onlyActive reports whether --only or --only-ext restricts the tree
+func onlyActive() bool {
+	return len(onlyPatterns) > 0 || len(onlyExts) > 0
+}
+
+// parseOnlyExts reads an --only-ext list; "go" and ".go" are the same, matched case-insensitively
+func parseOnlyExts(list string) {
+	for _, ext := range strings.Split(list, ",") {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+			onlyExts["."+ext] = true
+		}
+	}
+}
+
+// onlyMatch reports whether an entry matches --only, inside a match or by itself, or
+// is a file with an --only-ext extension
+func onlyMatch(rel string, isDir bool) bool {
+	return matchesOnly(rel) || (!isDir && onlyExts[strings.ToLower(path.Ext(rel))])
+}
+
+// containsOnlyMatch reports whether a directory holds a visible --only match anywhere below it
+func containsOnlyMatch(dir string) bool {
+	rel := relativePath(filepath.Dir(dir), filepath.Base(dir))
+	if found, ok := onlyMemo[rel]; ok {
+		return found
+	}
+	found := false
+	// Read directly: look-ahead listings must not count as walk progress
+	if entries, err := readDir(dir); err == nil {
+		for _, entry := range visibleEntries(dir, entries) {
+			childRel := relativePath(dir, entry.Name())
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			if onlyMatch(childRel, entry.IsDir()) || (shouldDescend(filepath.Join(dir, entry.Name()), entry) && containsOnlyMatch(filepath.Join(dir, entry.Name()))) {
+				found = true
+				break
+			}
+		}
+	}
+	onlyMemo[rel] = found
+	return found
+}
+
+// filterOnly keeps entries that match --only, sit inside a match, or lead to one
+func filterOnly(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if !onlyActive() {
+		return entries
+	}
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		rel := relativePath(dir, entry.Name())
+		if onlyMatch(rel, entry.IsDir()) || (shouldDescend(filepath.Join(dir, entry.Name()), entry) && containsOnlyMatch(filepath.Join(dir, entry.Name()))) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
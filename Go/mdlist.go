@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// formatMarkdownList selects markdown with the tree as a nested bulleted list, which
+// document search indexes and which can link each entry
+const formatMarkdownList = "md-list"
+
+var linkBase string // --link-base: with -f md-list, link each entry to this URL followed by its path
+
+// markdownEscapes backslash-escapes the characters that would format a list item's
+// name or end its link text
+var markdownEscapes = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`)
+
+// renderMarkdownList returns the markdown report with the tree as a nested list:
+// directories bold with a trailing "/", two spaces of indentation per level
+func renderMarkdownList(ctx context.Context, root string, entries []fs.DirEntry, bare bool) ([]byte, error) {
+	var out bytes.Buffer
+	if !bare && injectFile == "" {
+		fmt.Fprintf(&out, "%s\n\n%s\n\n", headerTitle(root), msg("header.star", repository))
+	}
+	if err := writeListEntries(ctx, &out, root, entries); err != nil {
+		return walkFailure(ctx, err)
+	}
+	writeCompleteness(&out)
+	return out.Bytes(), nil
+}
+
+// writeListEntries writes an item for every entry below dir through walkTree, with
+// the filters and exclusions of every other format. Two spaces indent each level.
+func writeListEntries(ctx context.Context, writer io.Writer, dir string, entries []fs.DirEntry) error {
+	rels := []string{""} // Displayed path of each open directory, for links
+	var line string      // Item of the directory walkTree reads next
+	return walkTree(ctx, dir, entries, func(e treeEntry) error {
+		indent := strings.Repeat("  ", e.depth-1)
+		switch e.event {
+		case walkElided:
+			fmt.Fprintf(writer, "%s- %s\n", indent, markdownEscapes.Replace(msg("tree.similar", groupThousands(e.elided))))
+		case walkFailed:
+			fmt.Fprintf(writer, "%s (unreadable)\n", line)
+		case walkOpen:
+			fmt.Fprintln(writer, line)
+			name, _ := displayName(e.entry.Name())
+			rels = append(rels, strings.TrimPrefix(rels[len(rels)-1]+"/"+name, "/"))
+		case walkClose:
+			rels = rels[:len(rels)-1]
+		case walkEntry:
+			label := entryLabel(e.dir, e.entry) + stubNote(e) + annotationNote(e.rel)
+			name, _ := displayName(e.entry.Name())
+			shown, _ := entryName(e.dir, e.entry.Name())
+			notes := strings.TrimPrefix(label, shown)
+			item := markdownEscapes.Replace(shown)
+			if e.entry.IsDir() || e.descend {
+				item = "**" + item + "/**"
+			}
+			if linkBase != "" {
+				item = fmt.Sprintf("[%s](%s)", item, listLink(strings.TrimPrefix(rels[len(rels)-1]+"/"+name, "/")))
+			}
+			item = iconPrefix(shown, e.entry.IsDir() || e.descend) + item
+			line = fmt.Sprintf("%s- %s%s", indent, item, markdownEscapes.Replace(notes))
+			if !e.descend {
+				fmt.Fprintln(writer, line)
+			}
+		}
+		return nil
+	})
+}
+
+// listLink returns the --link-base URL of an entry. Each path segment is escaped,
+// so spaces, "#" and "?" in names stay part of the path.
+func listLink(rel string) string {
+	segments := strings.Split(rel, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimRight(linkBase, "/") + "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// listFixture has spaces, # and % in its names, and characters markdown would read;
+// names with spaces are quoted
+const listFixture = `
+100%.txt
+[x]*.md
+"my docs/read me.md"
+"my docs/deep/er/a#b.go"
+"my docs/deep/er/note.txt"
+z/
+`
+
+// -f md-list is a nested list with bold directories; --link-base links each entry to
+// its URL-escaped path below the base, with or without a trailing slash
+func TestMarkdownListGolden(t *testing.T) {
+	for _, test := range []struct {
+		golden, base string
+	}{
+		{"list-spaces", ""},
+		{"list-spaces-links", "https://github.com/o/r/blob/main/"},
+		{"list-spaces-links", "https://github.com/o/r/blob/main"},
+		{"list-spaces-relative-links", "docs/tree"},
+	} {
+		got := renderFixture(t, testtree.MapFS(t, listFixture), true, func() {
+			setOption(t, &outputFormat, formatMarkdownList)
+			setOption(t, &linkBase, test.base)
+		})
+		testtree.Golden(t, test.golden+".md", []byte(got))
+	}
+}
+
+// Each level indents two spaces more than its parent, and only with spaces, however
+// deep the tree goes
+func TestMarkdownListIndentation(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, "a/b/c/d/e/f/g/h/i/j/leaf.txt\na/b/side.txt\ntop.txt\n"), true, func() {
+		setOption(t, &outputFormat, formatMarkdownList)
+		setOption(t, &noSummary, true)
+	})
+	item := regexp.MustCompile(`^( *)- (\S.*)$`)
+	depth := -1
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		match := item.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("%q is not a list item:\n%s", line, got)
+		}
+		level := len(match[1]) / 2
+		if len(match[1])%2 != 0 || level > depth+1 {
+			t.Errorf("%q is indented %d spaces below an item at level %d", line, len(match[1]), depth)
+		}
+		depth = level
+	}
+	if !strings.Contains(got, "\n"+strings.Repeat("  ", 10)+"- leaf.txt\n") || !strings.Contains(got, "\n    - side.txt\n") {
+		t.Errorf("got\n%s", got)
+	}
+
+	_, stderr, code := runFTG(t, t.TempDir(), "--link-base", "https://example.com", "-o", "-")
+	if code != exitUsage || !strings.Contains(stderr, "--link-base only works with -f md-list") {
+		t.Errorf("--link-base with -f md: exit code %d, %s", code, stderr)
+	}
+}
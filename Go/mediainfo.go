@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif" // Registers the decoders image.DecodeConfig uses
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var showMediaInfo bool // --media-info: annotate images with their dimensions and media files with their duration
+
+// mediaHeaderLimit is the most --media-info reads of a file: enough for the headers
+// of the supported formats, with room for the EXIF block before a JPEG's frame header
+const mediaHeaderLimit = 128 << 10
+
+// mediaBoxLimit is the most top-level MP4 boxes --media-info steps over looking for
+// the movie header, which may sit after the media data at the end of the file
+const mediaBoxLimit = 64
+
+// mediaBuffers holds header buffers for reuse, as every image of a large asset
+// directory needs one
+var mediaBuffers = sync.Pool{New: func() any {
+	buffer := make([]byte, mediaHeaderLimit)
+	return &buffer
+}}
+
+// mediaKinds maps the extensions --media-info reads to the parser for them
+var mediaKinds = map[string]string{
+	".png": "image", ".jpg": "image", ".jpeg": "image", ".gif": "image", ".webp": "webp",
+	".wav": "wav", ".mp4": "mp4", ".m4v": "mp4", ".m4a": "mp4", ".mov": "mp4",
+}
+
+// mediaNote returns "(1920×1080)" for an image, "(3:25)" for audio and
+// "(1920×1080, 3:25)" for video with --media-info. Only the headers are read; a
+// file that cannot be read or parsed gets no note.
+func mediaNote(path string, entry fs.DirEntry) string {
+	kind := mediaKinds[strings.ToLower(filepath.Ext(entry.Name()))]
+	if !showMediaInfo || kind == "" || !entry.Type().IsRegular() {
+		return ""
+	}
+	f, err := openPath(filepath.Join(path, entry.Name()))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if kind == "mp4" {
+		return mp4Note(f)
+	}
+	buffer := mediaBuffers.Get().(*[]byte)
+	defer mediaBuffers.Put(buffer)
+	n, err := io.ReadFull(f, *buffer)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return ""
+	}
+	head := (*buffer)[:n]
+	switch kind {
+	case "webp":
+		if width, height, ok := webpSize(head); ok {
+			return fmt.Sprintf("(%d×%d)", width, height)
+		}
+	case "wav":
+		if duration, ok := wavDuration(head); ok {
+			return "(" + formatMediaDuration(duration) + ")"
+		}
+	default:
+		if config, _, err := image.DecodeConfig(bytes.NewReader(head)); err == nil {
+			return fmt.Sprintf("(%d×%d)", config.Width, config.Height)
+		}
+	}
+	return ""
+}
+
+// webpSize reads the canvas size from the first chunk of a WebP file, which is a
+// lossy (VP8), lossless (VP8L) or extended (VP8X) header
+func webpSize(head []byte) (int, int, bool) {
+	if len(head) < 30 || string(head[:4]) != "RIFF" || string(head[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+	data := head[20:]
+	switch string(head[12:16]) {
+	case "VP8 ":
+		if data[3] != 0x9d || data[4] != 0x01 || data[5] != 0x2a {
+			return 0, 0, false
+		}
+		return int(binary.LittleEndian.Uint16(data[6:]) & 0x3fff), int(binary.LittleEndian.Uint16(data[8:]) & 0x3fff), true
+	case "VP8L":
+		if data[0] != 0x2f {
+			return 0, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(data[1:])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, true
+	case "VP8X":
+		return int(uint24(data[4:])) + 1, int(uint24(data[7:])) + 1, true
+	}
+	return 0, 0, false
+}
+
+// uint24 reads a little-endian 24-bit number
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// wavDuration divides the size of a WAV file's data chunk by the byte rate of its
+// format chunk, both of which come before the samples
+func wavDuration(head []byte) (time.Duration, bool) {
+	if len(head) < 12 || string(head[:4]) != "RIFF" || string(head[8:12]) != "WAVE" {
+		return 0, false
+	}
+	var byteRate uint32
+	for offset := 12; offset+8 <= len(head); {
+		id, size := string(head[offset:offset+4]), binary.LittleEndian.Uint32(head[offset+4:])
+		data := head[offset+8:]
+		switch id {
+		case "fmt ":
+			if len(data) < 12 {
+				return 0, false
+			}
+			byteRate = binary.LittleEndian.Uint32(data[8:])
+		case "data":
+			if byteRate == 0 {
+				return 0, false
+			}
+			return time.Duration(float64(size) / float64(byteRate) * float64(time.Second)), true
+		}
+		// Chunks are padded to an even size
+		next := uint64(offset) + 8 + uint64(size) + uint64(size&1)
+		if next > uint64(len(head)) {
+			return 0, false
+		}
+		offset = int(next)
+	}
+	return 0, false
+}
+
+// mp4Note finds the movie box among the top-level boxes of an MP4 or QuickTime file,
+// reading only box headers on the way, and describes the duration from its movie
+// header and the size of its first video track
+func mp4Note(f fs.File) string {
+	reader, ok := f.(io.ReaderAt)
+	if !ok {
+		return ""
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+	var header [16]byte
+	for offset, boxes := int64(0), 0; offset+8 <= info.Size() && boxes < mediaBoxLimit; boxes++ {
+		if _, err := reader.ReadAt(header[:], offset); err != nil && err != io.EOF {
+			return ""
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(header[:4])), int64(8)
+		switch size {
+		case 0:
+			size = info.Size() - offset
+		case 1:
+			size, headerSize = int64(binary.BigEndian.Uint64(header[8:])), 16
+		}
+		if size < headerSize {
+			return ""
+		}
+		if string(header[4:8]) == "moov" {
+			buffer := mediaBuffers.Get().(*[]byte)
+			defer mediaBuffers.Put(buffer)
+			n, err := reader.ReadAt((*buffer)[:min(size-headerSize, mediaHeaderLimit)], offset+headerSize)
+			if err != nil && err != io.EOF {
+				return ""
+			}
+			return describeMovie((*buffer)[:n])
+		}
+		offset += size
+	}
+	return ""
+}
+
+// describeMovie reads the movie header and the track headers in a movie box; a box
+// cut off by the read limit is parsed as far as it was read
+func describeMovie(moov []byte) string {
+	var duration time.Duration
+	var width, height uint32
+	var walk func(boxes []byte)
+	walk = func(boxes []byte) {
+		for len(boxes) >= 8 {
+			size := binary.BigEndian.Uint32(boxes)
+			if size < 8 {
+				return
+			}
+			body := boxes[8:min(int(size), len(boxes))]
+			switch string(boxes[4:8]) {
+			case "mvhd":
+				duration = mvhdDuration(body)
+			case "trak":
+				walk(body)
+			case "tkhd":
+				if w, h := tkhdSize(body); width == 0 && w > 0 && h > 0 {
+					width, height = w, h
+				}
+			}
+			if int(size) >= len(boxes) {
+				return
+			}
+			boxes = boxes[size:]
+		}
+	}
+	walk(moov)
+	var notes []string
+	if width > 0 {
+		notes = append(notes, fmt.Sprintf("%d×%d", width, height))
+	}
+	if duration > 0 {
+		notes = append(notes, formatMediaDuration(duration))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(notes, ", ") + ")"
+}
+
+// mvhdDuration reads the duration of a movie header, in its time scale
+func mvhdDuration(body []byte) time.Duration {
+	var scale, length uint64
+	switch {
+	case len(body) >= 32 && body[0] == 1:
+		scale, length = uint64(binary.BigEndian.Uint32(body[20:])), binary.BigEndian.Uint64(body[24:])
+	case len(body) >= 20 && body[0] == 0:
+		scale, length = uint64(binary.BigEndian.Uint32(body[12:])), uint64(binary.BigEndian.Uint32(body[16:]))
+	}
+	if scale == 0 {
+		return 0
+	}
+	return time.Duration(float64(length) / float64(scale) * float64(time.Second))
+}
+
+// tkhdSize reads the presentation size of a track header; audio tracks have none
+func tkhdSize(body []byte) (uint32, uint32) {
+	offset := 76
+	if len(body) > 0 && body[0] == 1 {
+		offset = 88
+	}
+	if len(body) < offset+8 {
+		return 0, 0
+	}
+	return binary.BigEndian.Uint32(body[offset:]) >> 16, binary.BigEndian.Uint32(body[offset+4:]) >> 16
+}
+
+// formatMediaDuration formats a duration as a player shows it: 0:42, 3:25 or 1:02:03
+func formatMediaDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// --media-info annotates the images in testdata/media with their size, audio with its
+// duration and video with both; files that only look like media get no note
+func TestMediaInfo(t *testing.T) {
+	render := func(on bool) string {
+		return renderFixture(t, os.DirFS("testdata/media"), true, func() {
+			setOption(t, &showMediaInfo, on)
+			setOption(t, &noSummary, true)
+		})
+	}
+	want := "├── [F] broken.png\n" +
+		"├── [F] clip.mp4 (1920×1080, 3:25)\n" +
+		"├── [F] empty.mp4\n" +
+		"├── [F] extended.webp (1920×1080)\n" +
+		"├── [F] logo.png (64×48)\n" +
+		"├── [F] lossless.webp (100×50)\n" +
+		"├── [F] lossy.webp (320×200)\n" +
+		"├── [F] photo.JPG (33×17)\n" +
+		"├── [F] song.m4a (3:25)\n" +
+		"├── [F] spinner.gif (10×20)\n" +
+		"├── [F] text.wav\n" +
+		"└── [F] voice.wav (0:42)\n"
+	if got := render(true); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := render(false); strings.Contains(got, "(") {
+		t.Errorf("notes without --media-info:\n%s", got)
+	}
+}
+
+// countingFS counts the bytes read from each file, through Read and ReadAt
+type countingFS struct {
+	fs.FS
+	mu   *sync.Mutex
+	read map[string]int64
+}
+
+// Open opens a file that counts what is read from it; directories are left as they are
+func (c countingFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if _, isDir := f.(fs.ReadDirFile); err != nil || isDir {
+		return f, err
+	}
+	return countingFile{f, c, name}, nil
+}
+
+// countingFile is a file of countingFS
+type countingFile struct {
+	fs.File
+	fs   countingFS
+	name string
+}
+
+// add counts n bytes read from the file
+func (f countingFile) add(n int) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	f.fs.read[f.name] += int64(n)
+}
+
+// Read reads from the file and counts the bytes
+func (f countingFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.add(n)
+	return n, err
+}
+
+// ReadAt reads from the file at an offset and counts the bytes
+func (f countingFile) ReadAt(b []byte, offset int64) (int, error) {
+	reader, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, fs.ErrInvalid
+	}
+	n, err := reader.ReadAt(b, offset)
+	f.add(n)
+	return n, err
+}
+
+// jpegWithEXIF puts APP1 segments of 65,533 bytes each after the start of a JPEG
+func jpegWithEXIF(jpeg []byte, segments int) []byte {
+	out := append([]byte{}, jpeg[:2]...)
+	for range segments {
+		out = append(out, 0xff, 0xe1)
+		out = binary.BigEndian.AppendUint16(out, 0xffff)
+		out = append(out, make([]byte, 0xffff-2)...)
+	}
+	return append(out, jpeg[2:]...)
+}
+
+// Only the headers are read: a few bytes past the start of a large image, the box
+// headers of an MP4 up to its movie box, however large its media data, and nothing
+// past the limit, so a JPEG whose frame header sits beyond it gets no note
+func TestMediaInfoReadsHeaders(t *testing.T) {
+	fixture := func(name string) []byte {
+		data, err := os.ReadFile(path.Join("testdata/media", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	clip := fixture("clip.mp4")
+	ftyp := binary.BigEndian.Uint32(clip)
+	mdat := binary.BigEndian.Uint32(clip[ftyp:])
+	var movie bytes.Buffer
+	movie.Write(clip[:ftyp])
+	movie.Write(binary.BigEndian.AppendUint32(nil, 8+8<<20))
+	movie.WriteString("mdat")
+	movie.Write(make([]byte, 8<<20))
+	movie.Write(clip[ftyp+mdat:])
+
+	fsys := countingFS{fstest.MapFS{
+		"big.png":        {Data: append(fixture("logo.png"), make([]byte, 4<<20)...)},
+		"exif.jpg":       {Data: jpegWithEXIF(fixture("photo.JPG"), 1)},
+		"exif-large.jpg": {Data: jpegWithEXIF(fixture("photo.JPG"), 3)},
+		"long.mp4":       {Data: movie.Bytes()},
+	}, &sync.Mutex{}, map[string]int64{}}
+	got := renderFixture(t, fsys, true, func() {
+		setOption(t, &showMediaInfo, true)
+		setOption(t, &noSummary, true)
+	})
+	want := "├── [F] big.png (64×48)\n" +
+		"├── [F] exif-large.jpg\n" +
+		"├── [F] exif.jpg (33×17)\n" +
+		"└── [F] long.mp4 (1920×1080, 3:25)\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	for name, n := range fsys.read {
+		if limit := int64(mediaHeaderLimit); n > limit {
+			t.Errorf("%s: %d bytes read, more than the %d of the headers", name, n, limit)
+		}
+	}
+	if n := fsys.read["long.mp4"]; n > int64(len(clip)) {
+		t.Errorf("long.mp4: %d bytes read; the boxes other than the media data hold %d", n, len(clip))
+	}
+}
+
+// Durations read as a player shows them
+func TestFormatMediaDuration(t *testing.T) {
+	for seconds, want := range map[float64]string{0: "0:00", 0.4: "0:00", 0.6: "0:01", 42: "0:42", 205: "3:25", 3600: "1:00:00", 3723: "1:02:03"} {
+		if got := formatMediaDuration(time.Duration(seconds * float64(time.Second))); got != want {
+			t.Errorf("%vs: %s, want %s", seconds, got, want)
+		}
+	}
+}
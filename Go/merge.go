@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const mergeUsage = "usage: ftg merge [-f md|json] [-o file] [--force] snapshot.json..."
+
+// mergeNode is one path of "ftg merge": what each host's snapshot says about it
+type mergeNode struct {
+	name     string
+	types    []string // JSON type on each host, in argument order; "" where the host lacks the path
+	sizes    []int64  // Size on each host, -1 where the snapshot has none
+	children map[string]*mergeNode
+}
+
+// mergeTree is the union of the snapshots read so far. Only the union is kept: each
+// snapshot is streamed through a json.Decoder, so no document is held whole.
+type mergeTree struct {
+	hosts []string
+	root  *mergeNode
+}
+
+// runSnapshotMerge implements "ftg merge": it unions -f json snapshots of the same directory
+// taken on several hosts, and shows each entry with the hosts it exists on and the
+// sizes the hosts disagree on
+func runSnapshotMerge(args []string) {
+	fset := flag.NewFlagSet("merge", flag.ExitOnError)
+	format := fset.String("f", formatMarkdown, "Output format (md, json)")
+	output := fset.String("o", stdoutTarget, "Output location")
+	fset.BoolVar(&forceOverwrite, "force", false, "Replace the output file if it exists")
+	_ = fset.Parse(args)
+	if fset.NArg() < 2 {
+		usageExit(mergeUsage)
+	}
+	if *format != formatMarkdown && *format != formatJSON {
+		usageExit(fmt.Sprintf("merge reports as md or json, not %s", *format))
+	}
+	if *output == stdoutTarget {
+		messages = os.Stderr
+	}
+	tree, err := mergeSnapshots(fset.Args())
+	if err != nil {
+		errorExit(err.Error())
+	}
+	var out bytes.Buffer
+	if *format == formatJSON {
+		data, _ := marshalJSON(tree.report())
+		out.Write(data)
+	} else {
+		tree.write(&out)
+	}
+	finishRun(writeOutputs([]string{*output}, out.Bytes()))
+}
+
+// mergeSnapshots reads the snapshot files in order, each host named after its file
+func mergeSnapshots(paths []string) (*mergeTree, error) {
+	tree := &mergeTree{root: &mergeNode{children: map[string]*mergeNode{}}}
+	seen := map[string]bool{}
+	for _, path := range paths {
+		host := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if seen[host] {
+			return nil, fmt.Errorf("two snapshots are named %s; each host needs a file name of its own", host)
+		}
+		seen[host] = true
+		tree.hosts = append(tree.hosts, host)
+	}
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read snapshot: %w", err)
+		}
+		err = tree.read(f, i)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s is not an ftg -f json snapshot: %w", path, err)
+		}
+	}
+	return tree, nil
+}
+
+// read streams one snapshot into the tree as host
+func (m *mergeTree) read(r io.Reader, host int) error {
+	decoder := json.NewDecoder(r)
+	if err := m.readEntry(decoder, m.root, host, true); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("more than one document")
+	}
+	return nil
+}
+
+// readEntry reads the object of one entry below parent, or of the root itself. ftg
+// writes an entry's name and type before its children, so the entry is in the tree
+// by the time they are read.
+func (m *mergeTree) readEntry(decoder *json.Decoder, parent *mergeNode, host int, root bool) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+	node := parent
+	var name, kind string
+	size := int64(-1)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		switch {
+		case key == "name":
+			err = decoder.Decode(&name)
+		case key == "type":
+			err = decoder.Decode(&kind)
+		case key == "size":
+			err = decoder.Decode(&size)
+		case key == "children":
+			if !root {
+				if node, err = m.entry(parent, name, kind, host); err != nil {
+					return err
+				}
+			}
+			if err := expectDelim(decoder, '['); err != nil {
+				return err
+			}
+			for decoder.More() {
+				if err := m.readEntry(decoder, node, host, false); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	if root {
+		return nil
+	}
+	if node == parent {
+		var err error
+		if node, err = m.entry(parent, name, kind, host); err != nil {
+			return err
+		}
+	}
+	node.sizes[host] = size
+	return nil
+}
+
+// entry records that host has the entry name of parent, of the JSON type kind
+func (m *mergeTree) entry(parent *mergeNode, name, kind string, host int) (*mergeNode, error) {
+	if name == "" || strings.Contains(name, "/") || kind == "" {
+		return nil, fmt.Errorf("an entry below %q has no usable name or type", parent.name)
+	}
+	node, ok := parent.children[name]
+	if !ok {
+		node = &mergeNode{name: name, types: make([]string, len(m.hosts)), sizes: make([]int64, len(m.hosts)),
+			children: map[string]*mergeNode{}}
+		for i := range node.sizes {
+			node.sizes[i] = -1
+		}
+		parent.children[name] = node
+	}
+	if node.types[host] != "" {
+		return nil, fmt.Errorf("%q is listed twice", name)
+	}
+	node.types[host] = kind
+	return node, nil
+}
+
+// expectDelim reads the next token, which must be delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("found %v where %v was expected", token, delim)
+	}
+	return nil
+}
+
+// sorted returns the children of a node in the tree's order, directories being the
+// entries that are one on any host
+func (n *mergeNode) sorted() []*mergeNode {
+	children := make([]*mergeNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return sortsBefore(children[i].name, children[i].isDir(), children[j].name, children[j].isDir())
+	})
+	return children
+}
+
+// isDir reports whether any host has the entry as a directory
+func (n *mergeNode) isDir() bool {
+	for _, kind := range n.types {
+		if kind == "dir" {
+			return true
+		}
+	}
+	return false
+}
+
+// presentOn returns the indexes of the hosts that have the entry
+func (n *mergeNode) presentOn() []int {
+	var hosts []int
+	for i, kind := range n.types {
+		if kind != "" {
+			hosts = append(hosts, i)
+		}
+	}
+	return hosts
+}
+
+// sizeMismatch reports whether two hosts that record the entry's size disagree on it
+func (n *mergeNode) sizeMismatch() bool {
+	first := int64(-1)
+	for _, size := range n.sizes {
+		switch {
+		case size < 0:
+		case first < 0:
+			first = size
+		case size != first:
+			return true
+		}
+	}
+	return false
+}
+
+// typeMismatch reports whether the hosts that have the entry disagree on its type
+func (n *mergeNode) typeMismatch() bool {
+	first := ""
+	for _, kind := range n.types {
+		switch {
+		case kind == "":
+		case first == "":
+			first = kind
+		case kind != first:
+			return true
+		}
+	}
+	return false
+}
+
+// hostsLabel returns "[3/3]" for an entry on every host, "[only: web2]" for one on a
+// single host and "[2/3: web1, web3]" otherwise
+func (m *mergeTree) hostsLabel(n *mergeNode) string {
+	present := n.presentOn()
+	switch {
+	case len(present) == len(m.hosts):
+		return fmt.Sprintf("[%d/%d]", len(present), len(m.hosts))
+	case len(present) == 1:
+		return "[only: " + m.hosts[present[0]] + "]"
+	}
+	names := make([]string, len(present))
+	for i, host := range present {
+		names[i] = m.hosts[host]
+	}
+	return fmt.Sprintf("[%d/%d: %s]", len(present), len(m.hosts), strings.Join(names, ", "))
+}
+
+// notes returns the disagreements shown after an entry's hosts
+func (m *mergeTree) notes(n *mergeNode) string {
+	var notes []string
+	if n.typeMismatch() {
+		var kinds []string
+		for i, kind := range n.types {
+			if kind != "" {
+				kinds = append(kinds, m.hosts[i]+" "+kind)
+			}
+		}
+		notes = append(notes, "type differs: "+strings.Join(kinds, ", "))
+	}
+	if n.sizeMismatch() {
+		var sizes []string
+		for i, size := range n.sizes {
+			if size >= 0 {
+				sizes = append(sizes, m.hosts[i]+" "+formatSize(size))
+			}
+		}
+		notes = append(notes, "size differs: "+strings.Join(sizes, ", "))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, "; ") + ")"
+}
+
+// write renders the merged tree as markdown, followed by the entries only one host has
+func (m *mergeTree) write(writer io.Writer) {
+	fmt.Fprintf(writer, "# Merged File Tree of %s\n\n%s\n\n```sh\n", plural(len(m.hosts), "host"), strings.Join(m.hosts, ", "))
+	m.writeLines(writer, m.root, "")
+	fmt.Fprintln(writer, "```")
+	summary := m.summary()
+	fmt.Fprintf(writer, "\n%s on every host, %s on only some, %s with differing sizes or types\n",
+		treeCount(summary.Common, "entry", "entries"), treeCount(summary.Partial, "entry", "entries"), treeCount(summary.Conflicts, "entry", "entries"))
+	for _, host := range m.hosts {
+		unique := summary.Unique[host]
+		if len(unique) == 0 {
+			continue
+		}
+		fmt.Fprintf(writer, "\nOnly on %s:\n\n```diff\n", host)
+		for _, path := range unique {
+			fmt.Fprintf(writer, "+ %s\n", path)
+		}
+		fmt.Fprintln(writer, "```")
+	}
+}
+
+// writeLines writes one tree line per entry below n
+func (m *mergeTree) writeLines(writer io.Writer, n *mergeNode, prefix string) {
+	children := n.sorted()
+	for i, child := range children {
+		isLast := i == len(children)-1
+		kind := "F"
+		if child.isDir() {
+			kind = "D"
+		}
+		name, _ := displayName(child.name)
+		fmt.Fprintf(writer, "%s%s [%s] %s %s%s\n", prefix, connectors.Connector(isLast), kind, name, m.hostsLabel(child), m.notes(child))
+		m.writeLines(writer, child, connectors.Indent(prefix, isLast))
+	}
+}
+
+// mergeSummary counts the merged entries; Unique lists, per host, the paths no other
+// host has, without those below a directory already listed
+type mergeSummary struct {
+	Common    int                 `json:"common"`
+	Partial   int                 `json:"partial"`
+	Conflicts int                 `json:"conflicts"`
+	Unique    map[string][]string `json:"unique"`
+}
+
+// summary walks the merged tree for its counts
+func (m *mergeTree) summary() mergeSummary {
+	summary := mergeSummary{Unique: map[string][]string{}}
+	var walk func(n *mergeNode, rel string, listed bool)
+	walk = func(n *mergeNode, rel string, listed bool) {
+		for _, child := range n.sorted() {
+			path := strings.TrimPrefix(rel+"/"+child.name, "/")
+			present := child.presentOn()
+			if len(present) == len(m.hosts) {
+				summary.Common++
+			} else {
+				summary.Partial++
+			}
+			if child.sizeMismatch() || child.typeMismatch() {
+				summary.Conflicts++
+			}
+			unique := len(present) == 1 && len(m.hosts) > 1
+			if unique && !listed {
+				host := m.hosts[present[0]]
+				summary.Unique[host] = append(summary.Unique[host], path)
+			}
+			walk(child, path, listed || unique)
+		}
+	}
+	walk(m.root, "", false)
+	return summary
+}
+
+// mergeJSON is an entry of "ftg merge -f json"
+type mergeJSON struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`            // Of the first host that has the entry
+	Hosts    []string          `json:"hosts"`           // Hosts that have it, in argument order
+	Types    map[string]string `json:"types,omitempty"` // Type per host, when they differ
+	Sizes    map[string]int64  `json:"sizes,omitempty"` // Size per host, when they differ
+	Children []*mergeJSON      `json:"children,omitempty"`
+}
+
+// mergeReport is the document of "ftg merge -f json"
+type mergeReport struct {
+	Hosts    []string     `json:"hosts"`
+	Children []*mergeJSON `json:"children"`
+	Summary  mergeSummary `json:"summary"`
+}
+
+// report converts the merged tree for encoding
+func (m *mergeTree) report() mergeReport {
+	var convert func(n *mergeNode) []*mergeJSON
+	convert = func(n *mergeNode) []*mergeJSON {
+		nodes := []*mergeJSON{}
+		for _, child := range n.sorted() {
+			node := &mergeJSON{Name: child.name, Hosts: []string{}, Children: convert(child)}
+			for _, host := range child.presentOn() {
+				if node.Type == "" {
+					node.Type = child.types[host]
+				}
+				node.Hosts = append(node.Hosts, m.hosts[host])
+			}
+			if child.typeMismatch() {
+				node.Types = map[string]string{}
+				for _, host := range child.presentOn() {
+					node.Types[m.hosts[host]] = child.types[host]
+				}
+			}
+			if child.sizeMismatch() {
+				node.Sizes = map[string]int64{}
+				for i, size := range child.sizes {
+					if size >= 0 {
+						node.Sizes[m.hosts[i]] = size
+					}
+				}
+			}
+			if len(node.Children) == 0 {
+				node.Children = nil
+			}
+			nodes = append(nodes, node)
+		}
+		return nodes
+	}
+	return mergeReport{Hosts: m.hosts, Children: convert(m.root), Summary: m.summary()}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// mergeHosts writes an -f json -s snapshot of each host's tree, named after the host,
+// and returns the paths in host order
+func mergeHosts(t *testing.T, hosts ...[2]string) (string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	var snapshots []string
+	for _, host := range hosts {
+		root := testtree.Dir(t, host[1])
+		snapshot := filepath.Join(dir, host[0]+".json")
+		if _, stderr, code := runFTG(t, dir, "-d", root, "-f", "json", "-s", "-o", snapshot); code != exitOK {
+			t.Fatalf("snapshot of %s: exit code %d: %s", host[0], code, stderr)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return dir, snapshots
+}
+
+func TestMergeSnapshots(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts [][2]string
+		want  string
+	}{
+		{"identical", [][2]string{{"web1", "app/a.txt size=3\nconf\n"}, {"web2", "app/a.txt size=3\nconf\n"}},
+			"├── [D] app [2/2]\n│   └── [F] a.txt [2/2]\n└── [F] conf [2/2]\n```\n\n3 entries on every host, 0 entries on only some, 0 entries with differing sizes or types\n"},
+		{"disjoint", [][2]string{{"web1", "a.txt\n"}, {"web2", "b/c.txt\n"}},
+			"├── [F] a.txt [only: web1]\n└── [D] b [only: web2]\n    └── [F] c.txt [only: web2]\n```\n\n0 entries on every host, 3 entries on only some, 0 entries with differing sizes or types\n\nOnly on web1:\n\n```diff\n+ a.txt\n```\n\nOnly on web2:\n\n```diff\n+ b\n```\n"},
+		{"conflicting", [][2]string{{"web1", "a.txt size=3\nb\n"}, {"web2", "a.txt size=5\nb/\n"}, {"web3", "a.txt size=3\n"}},
+			"├── [F] a.txt [3/3] (size differs: web1 3 B, web2 5 B, web3 3 B)\n└── [D] b [2/3: web1, web2] (type differs: web1 file, web2 dir)\n```\n\n1 entry on every host, 1 entry on only some, 2 entries with differing sizes or types\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, snapshots := mergeHosts(t, test.hosts...)
+			stdout, stderr, code := runFTG(t, dir, append([]string{"merge"}, snapshots...)...)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			tree := stdout[strings.Index(stdout, "```sh\n")+len("```sh\n"):]
+			if tree != test.want {
+				t.Errorf("got\n%s\nwant\n%s", tree, test.want)
+			}
+		})
+	}
+}
+
+// The JSON report lists the hosts of each entry and only the sizes that differ
+func TestMergeJSON(t *testing.T) {
+	dir, snapshots := mergeHosts(t, [2]string{"web1", "z.txt size=1\na.txt size=3\n"}, [2]string{"web2", "a.txt size=4\n"})
+	out := filepath.Join(dir, "merged.json")
+	if _, stderr, code := runFTG(t, dir, "merge", "-f", "json", "-o", out, snapshots[0], snapshots[1]); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report mergeReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("%v in\n%s", err, data)
+	}
+	if len(report.Children) != 2 {
+		t.Fatalf("%d entries, want a.txt and z.txt:\n%s", len(report.Children), data)
+	}
+	a, z := report.Children[0], report.Children[1]
+	if a.Name != "a.txt" || !slices.Equal(a.Hosts, []string{"web1", "web2"}) || !maps.Equal(a.Sizes, map[string]int64{"web1": 3, "web2": 4}) {
+		t.Errorf("a.txt = %+v, want it on both hosts with both sizes", a)
+	}
+	if z.Name != "z.txt" || !slices.Equal(z.Hosts, []string{"web1"}) || z.Sizes != nil {
+		t.Errorf("z.txt = %+v, want it on web1 only, without sizes", z)
+	}
+	if want := map[string][]string{"web1": {"z.txt"}}; fmt.Sprint(report.Summary.Unique) != fmt.Sprint(want) {
+		t.Errorf("unique = %v, want %v", report.Summary.Unique, want)
+	}
+}
+
+// Inputs that cannot be merged are refused before anything is written
+func TestMergeRefused(t *testing.T) {
+	dir, snapshots := mergeHosts(t, [2]string{"web1", "a.txt\n"})
+	notJSON := filepath.Join(dir, "web2.json")
+	if err := os.WriteFile(notJSON, []byte("# File Tree for x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{snapshots[0]}, exitUsage, "usage: ftg merge"},
+		{[]string{"-f", "csv", snapshots[0], snapshots[0]}, exitUsage, "not csv"},
+		{[]string{snapshots[0], snapshots[0]}, exitFatal, "two snapshots are named web1"},
+		{[]string{snapshots[0], notJSON}, exitFatal, "web2.json is not an ftg -f json snapshot"},
+	} {
+		_, stderr, code := runFTG(t, dir, append([]string{"merge"}, test.args...)...)
+		if code != test.code || !strings.Contains(stderr, test.want) {
+			t.Errorf("merge %q: exit code %d, %s; want %d and %q", test.args, code, stderr, test.code, test.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// formatMermaid selects a markdown file holding the tree as a Mermaid diagram
+const formatMermaid = "mermaid"
+
+var (
+	mermaidDirection = "TD" // --mermaid-direction: TD (graph TD, top down) or LR (flowchart LR, left to right)
+	mermaidMaxNodes  = 2000 // --mermaid-max-nodes: the diagram stops here; renderers slow down past a few thousand
+	mermaidNodes     int    // Nodes written so far, the root included
+	mermaidCut       bool   // The node cap was reached
+)
+
+// mermaidEscapes replaces the characters that end or alter a quoted Mermaid label
+// with Mermaid's entity codes; "#" starts an entity code itself
+var mermaidEscapes = strings.NewReplacer("#", "#35;", `"`, "#quot;", "<", "#lt;", ">", "#gt;", "`", "#96;")
+
+// renderMermaid returns the markdown report with the tree as a ```mermaid block. Nodes
+// get sequential IDs, so no name can break the syntax; the names are in the labels.
+func renderMermaid(ctx context.Context, root string, entries []fs.DirEntry, bare bool) ([]byte, error) {
+	var out bytes.Buffer
+	if !bare {
+		fmt.Fprintf(&out, "%s\n\n%s\n", headerTitle(root), msg("header.star", repository))
+	}
+	out.WriteString("```mermaid\n")
+	if strings.EqualFold(mermaidDirection, "LR") {
+		out.WriteString("flowchart LR\n")
+	} else {
+		out.WriteString("graph TD\n")
+	}
+	mermaidNodes = 1
+	fmt.Fprintf(&out, "    n0[\"%s/\"]\n", mermaidLabel(shownRoot(root)))
+	if err := writeMermaidEntries(ctx, &out, root, entries); err != nil {
+		return walkFailure(ctx, err)
+	}
+	out.WriteString("```\n")
+	if mermaidCut {
+		fmt.Fprintf(&out, "\n%s\n", msg("summary.mermaidCut", groupThousands(mermaidMaxNodes)))
+	}
+	writeCompleteness(&out)
+	return out.Bytes(), nil
+}
+
+// errMermaidCut stops walkTree once the diagram reached --mermaid-max-nodes
+var errMermaidCut = errors.New("mermaid node cap reached")
+
+// writeMermaidEntries adds a node and an edge from its parent for every entry below
+// dir, walked by walkTree with the same filters and exclusions as every other format
+func writeMermaidEntries(ctx context.Context, writer io.Writer, dir string, entries []fs.DirEntry) error {
+	parents := []string{"n0"} // Node ID of each open directory
+	var id, label string      // Node of the directory walkTree reads next
+	err := walkTree(ctx, dir, entries, func(e treeEntry) error {
+		parent := parents[len(parents)-1]
+		switch e.event {
+		case walkElided:
+			if !mermaidNode(writer, parent) {
+				return errMermaidCut
+			}
+			fmt.Fprintf(writer, "    %s --> n%d[\"%s\"]\n", parent, mermaidNodes-1, mermaidLabel(msg("tree.similar", groupThousands(e.elided))))
+		case walkFailed:
+			fmt.Fprintf(writer, "    %s --> %s[\"%s/ (unreadable)\"]\n", parent, id, label)
+		case walkOpen:
+			fmt.Fprintf(writer, "    %s --> %s[\"%s/\"]\n", parent, id, label)
+			parents = append(parents, id)
+		case walkClose:
+			parents = parents[:len(parents)-1]
+		case walkEntry:
+			if !mermaidNode(writer, parent) {
+				return errMermaidCut
+			}
+			id, label = fmt.Sprintf("n%d", mermaidNodes-1), mermaidLabel(entryLabel(e.dir, e.entry)+stubNote(e))
+			if !e.descend {
+				fmt.Fprintf(writer, "    %s --> %s[\"%s\"]\n", parent, id, label)
+			}
+		}
+		return nil
+	})
+	if err == errMermaidCut {
+		return nil
+	}
+	return err
+}
+
+// mermaidNode claims the next node ID, or ends the diagram with a marker node below
+// parent and a warning once the cap is reached
+func mermaidNode(writer io.Writer, parent string) bool {
+	if mermaidCut {
+		return false
+	}
+	if mermaidNodes >= mermaidMaxNodes {
+		mermaidCut = true
+		noteTruncated()
+		fmt.Fprintf(writer, "    %s --> cut[\"…\"]\n", parent)
+		warnf("The Mermaid diagram stops at %s nodes (--mermaid-max-nodes); use --max-depth or --only to narrow it", groupThousands(mermaidMaxNodes))
+		return false
+	}
+	mermaidNodes++
+	return true
+}
+
+// mermaidLabel escapes text for a quoted Mermaid label
+func mermaidLabel(text string) string {
+	return mermaidEscapes.Replace(text)
+}
@@ -0,0 +1,48 @@
+package main
+
+import "io/fs"
+
+// fileID identifies a directory independently of the path it was reached by
+type fileID struct {
+	dev, ino uint64
+}
+
+var (
+	dedupeMounts bool                  // Show directories reachable at several paths (bind mounts, overlays) only once
+	visitedDirs  = map[fileID]string{} // Relative path of the first rendered directory per identity
+	dirIdentity  = statIdentity        // Looks up a directory's identity; replaceable for fake stat layers
+)
+
+// resetVisitedDirs starts a new tree with only the input directory marked as shown,
+// so a mount of the root further down is caught too
+func resetVisitedDirs(root string) {
+	visitedDirs = map[fileID]string{}
+	if info, err := lstat(root); err == nil {
+		if id, ok := dirIdentity(info); ok {
+			visitedDirs[id] = "."
+		}
+	}
+}
+
+// firstVisit returns the relative path at which a directory was first shown, "." for
+// the input directory, and records rel as the first visit of a new one. Bind mounts
+// and overlay layers can expose the same directory twice; the first path wins, and
+// later ones are annotated and not descended into.
+func firstVisit(entry fs.DirEntry, rel string) (string, bool) {
+	if !dedupeMounts {
+		return "", false
+	}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return "", false
+	}
+	id, ok := dirIdentity(info)
+	if !ok {
+		return "", false
+	}
+	if first, seen := visitedDirs[id]; seen {
+		return first, true
+	}
+	visitedDirs[id] = rel
+	return "", false
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// statIdentity reports that file identities are unavailable, so --dedupe-mounts has no effect
+func statIdentity(info fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
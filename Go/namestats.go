@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	nameStatsEnabled bool                           // --name-stats: append a section on the lengths of entry names
+	nameMax          = 100                          // --name-max: names longer than this many characters are listed
+	nameSuggest      bool                           // --name-suggest: show what a truncate-and-hash rename would make of long names
+	nameTotals       nameDirStats                   // Names counted over the whole tree
+	nameDirs         = map[string]*nameDirStats{}   // Names counted per directory, by relative path
+	nameLongest      []nameLength                   // The longest names, longest first
+	nameOffenders    []nameLength                   // Names longer than --name-max, in walk order
+	nameTaken        = map[string]map[string]bool{} // Suggestions made per directory, so no two collide
+)
+
+// nameStatsTopN is how many of the longest names and directories the section lists
+const nameStatsTopN = 10
+
+// nameOffendersShown is how many names over --name-max the markdown section lists; JSON has all
+const nameOffendersShown = 50
+
+// nameHashLength is how many hex digits of the name's hash a suggestion starts with
+const nameHashLength = 8
+
+// nameDirStats counts the names of a directory, or of the tree
+type nameDirStats struct {
+	names   int
+	chars   int // Characters of all names together
+	longest int
+}
+
+// nameLength is one entry's name and its length in characters
+type nameLength struct {
+	rel        string
+	length     int
+	suggestion string // With --name-suggest, for names over --name-max
+}
+
+// recordNameStats counts the names of a listing: the walk calls it with each
+// directory's shown entries, and a streamed directory batch by batch
+func recordNameStats(dir string, entries []fs.DirEntry) {
+	if !nameStatsEnabled || len(entries) == 0 {
+		return
+	}
+	relDir := relativePath(dir, "")
+	stats := nameDirs[relDir]
+	if stats == nil {
+		stats = &nameDirStats{}
+		nameDirs[relDir] = stats
+	}
+	var siblings map[string]bool
+	for _, entry := range entries {
+		name := entry.Name()
+		length := utf8.RuneCountInString(name)
+		for _, s := range []*nameDirStats{stats, &nameTotals} {
+			s.names++
+			s.chars += length
+			s.longest = max(s.longest, length)
+		}
+		found := nameLength{rel: relativePath(dir, name), length: length}
+		if length > nameMax {
+			if nameSuggest {
+				if siblings == nil {
+					siblings = siblingNames(relDir, entries)
+				}
+				found.suggestion = suggestName(name, nameMax, entry.IsDir(), siblings)
+				siblings[found.suggestion] = true
+			}
+			nameOffenders = append(nameOffenders, found)
+		}
+		keepLongest(found)
+	}
+}
+
+// siblingNames returns the names a suggestion in a directory must not take: those of
+// the listing and the suggestions already made there
+func siblingNames(relDir string, entries []fs.DirEntry) map[string]bool {
+	taken := nameTaken[relDir]
+	if taken == nil {
+		taken = map[string]bool{}
+		nameTaken[relDir] = taken
+	}
+	for _, entry := range entries {
+		taken[entry.Name()] = true
+	}
+	return taken
+}
+
+// keepLongest adds a name to the longest ones if it is among the top nameStatsTopN
+func keepLongest(found nameLength) {
+	if len(nameLongest) == nameStatsTopN && found.length <= nameLongest[len(nameLongest)-1].length {
+		return
+	}
+	i := sort.Search(len(nameLongest), func(i int) bool { return nameLongest[i].length < found.length })
+	nameLongest = append(nameLongest, nameLength{})
+	copy(nameLongest[i+1:], nameLongest[i:])
+	nameLongest[i] = found
+	if len(nameLongest) > nameStatsTopN {
+		nameLongest = nameLongest[:nameStatsTopN]
+	}
+}
+
+// suggestName returns a rename of name within limit characters: as much of the name
+// as fits, a hyphen, a short hash of the whole name and the extension, as in
+// "nightly-build-2024-03-12-linux-3f2a9c1d.tar.gz". Directories keep no extension.
+// The hash grows a digit pair at a time until the result is not among taken, the
+// other names of the directory. It only reads its arguments.
+func suggestName(name string, limit int, isDir bool, taken map[string]bool) string {
+	stem, ext := name, ""
+	if !isDir {
+		stem, ext = splitNameExt(name)
+	}
+	sum := sha256.Sum256([]byte(name))
+	digest := hex.EncodeToString(sum[:])
+	var suggestion string
+	for hashLen := nameHashLength; hashLen <= len(digest); hashLen += 2 {
+		room := max(limit-utf8.RuneCountInString(ext)-hashLen-1, 1)
+		prefix := strings.TrimRight(truncateRunes(stem, room), "-_. ")
+		if prefix == "" {
+			prefix = truncateRunes(stem, 1)
+		}
+		suggestion = prefix + "-" + digest[:hashLen] + ext
+		if !taken[suggestion] {
+			break
+		}
+	}
+	return suggestion
+}
+
+// splitNameExt splits a file name before its extension. A compressed tarball keeps
+// both parts, an extension longer than a few characters is taken as part of the
+// name, and a dotfile's name is all stem.
+func splitNameExt(name string) (string, string) {
+	ext := path.Ext(name)
+	if ext == name || len(ext) > 8 {
+		return name, ""
+	}
+	stem := strings.TrimSuffix(name, ext)
+	if inner := path.Ext(stem); inner == ".tar" && inner != stem {
+		return strings.TrimSuffix(stem, inner), inner + ext
+	}
+	return stem, ext
+}
+
+// truncateRunes returns at most the first n characters of s, stopping short rather
+// than splitting an emoji sequence or a character from its marks
+func truncateRunes(s string, n int) string {
+	for i := 0; i < len(s); {
+		size, _ := nextCluster(s[i:])
+		if n -= utf8.RuneCountInString(s[i : i+size]); n < 0 {
+			return s[:i]
+		}
+		i += size
+	}
+	return s
+}
+
+// average returns the mean name length a directory's counts give
+func (s nameDirStats) average() float64 {
+	if s.names == 0 {
+		return 0
+	}
+	return float64(s.chars) / float64(s.names)
+}
+
+// rankedNameDirs returns the directories with the longest names on average, the
+// longer total breaking ties, then the path
+func rankedNameDirs() []string {
+	dirs := make([]string, 0, len(nameDirs))
+	for dir := range nameDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		a, b := nameDirs[dirs[i]], nameDirs[dirs[j]]
+		if a.average() != b.average() {
+			return a.average() > b.average()
+		}
+		if a.chars != b.chars {
+			return a.chars > b.chars
+		}
+		return dirs[i] < dirs[j]
+	})
+	return dirs[:min(len(dirs), nameStatsTopN)]
+}
+
+// writeNameStats appends the --name-stats section: totals, the longest names, the
+// directories with the longest names on average and the names over --name-max
+func writeNameStats(writer io.Writer) {
+	fmt.Fprintf(writer, "\n%s\n\n", msg("names.heading"))
+	if nameTotals.names == 0 {
+		fmt.Fprintln(writer, msg("names.none"))
+		return
+	}
+	fmt.Fprintln(writer, msg("names.summary", groupThousands(nameTotals.names), localizeDecimal(fmt.Sprintf("%.1f", nameTotals.average())), groupThousands(len(nameOffenders)), nameMax))
+
+	fmt.Fprintf(writer, "\n%s\n| --- | ---: |\n", msg("names.longestTable"))
+	for _, found := range nameLongest {
+		fmt.Fprintf(writer, "| %s | %d |\n", tableSpan(redactPath(displayPath(found.rel))), found.length)
+	}
+
+	fmt.Fprintf(writer, "\n%s\n| --- | ---: | ---: | ---: |\n", msg("names.dirTable"))
+	for _, dir := range rankedNameDirs() {
+		stats := nameDirs[dir]
+		fmt.Fprintf(writer, "| %s | %s | %s | %d |\n", tableSpan(redactPath(displayPath(dir))), groupThousands(stats.names), localizeDecimal(fmt.Sprintf("%.1f", stats.average())), stats.longest)
+	}
+
+	if len(nameOffenders) == 0 {
+		return
+	}
+	if nameSuggest {
+		fmt.Fprintf(writer, "\n%s\n| --- | ---: | --- |\n", msg("names.suggestTable", nameMax))
+	} else {
+		fmt.Fprintf(writer, "\n%s\n| --- | ---: |\n", msg("names.overTable", nameMax))
+	}
+	for i, found := range nameOffenders {
+		if i == nameOffendersShown {
+			more := msg("anomalies.more", groupThousands(len(nameOffenders)-i))
+			if nameSuggest {
+				fmt.Fprintf(writer, "| %s | | |\n", more)
+			} else {
+				fmt.Fprintf(writer, "| %s | |\n", more)
+			}
+			break
+		}
+		row := fmt.Sprintf("| %s | %d |", tableSpan(redactPath(displayPath(found.rel))), found.length)
+		if nameSuggest {
+			row += " " + tableSpan(found.safeSuggestion()) + " |"
+		}
+		fmt.Fprintln(writer, row)
+	}
+}
+
+// safeSuggestion returns the suggested rename to publish: redacted with the name
+func (n nameLength) safeSuggestion() string {
+	if shouldRedact(path.Base(n.rel)) {
+		return redactedLabel
+	}
+	return n.suggestion
+}
+
+// jsonNameStats is the "nameStats" object of -f json
+type jsonNameStats struct {
+	Names         int               `json:"names"`
+	AverageLength float64           `json:"averageLength"`
+	Limit         int               `json:"limit"`
+	Longest       []jsonNameLength  `json:"longest"`
+	Directories   []jsonNameDirStat `json:"directories"`
+	OverLimit     []jsonNameLength  `json:"overLimit"`
+}
+
+// jsonNameLength is a name of "nameStats", with its suggested rename when there is one
+type jsonNameLength struct {
+	Path       string `json:"path"`
+	Length     int    `json:"length"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// jsonNameDirStat is a directory of "nameStats"
+type jsonNameDirStat struct {
+	Path          string  `json:"path"`
+	Names         int     `json:"names"`
+	AverageLength float64 `json:"averageLength"`
+	Longest       int     `json:"longest"`
+}
+
+// nameStatsJSON returns the --name-stats section for -f json
+func nameStatsJSON() *jsonNameStats {
+	report := &jsonNameStats{
+		Names:         nameTotals.names,
+		AverageLength: roundTenth(nameTotals.average()),
+		Limit:         nameMax,
+		Longest:       []jsonNameLength{},
+		Directories:   []jsonNameDirStat{},
+		OverLimit:     []jsonNameLength{},
+	}
+	for _, found := range nameLongest {
+		report.Longest = append(report.Longest, jsonNameLength{Path: redactPath(displayPath(found.rel)), Length: found.length})
+	}
+	for _, dir := range rankedNameDirs() {
+		stats := nameDirs[dir]
+		report.Directories = append(report.Directories, jsonNameDirStat{Path: redactPath(displayPath(dir)), Names: stats.names, AverageLength: roundTenth(stats.average()), Longest: stats.longest})
+	}
+	for _, found := range nameOffenders {
+		report.OverLimit = append(report.OverLimit, jsonNameLength{Path: redactPath(displayPath(found.rel)), Length: found.length, Suggestion: found.safeSuggestion()})
+	}
+	return report
+}
+
+// roundTenth rounds to one decimal, as the markdown section shows averages
+func roundTenth(x float64) float64 {
+	return float64(int64(x*10+0.5)) / 10
+}
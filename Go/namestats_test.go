@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// A suggestion keeps as much of the name as fits the limit with the hash and the
+// extension, a tarball's two extensions included, never splits a character and moves
+// to a longer hash when the shorter one is taken
+func TestSuggestName(t *testing.T) {
+	long := "nightly-build-2024-03-12-linux-x86_64-release-candidate.tar.gz"
+	for _, test := range []struct {
+		name   string
+		limit  int
+		isDir  bool
+		prefix string // What of the name the suggestion starts with, before "-" and the hash
+		ext    string
+	}{
+		{long, 40, false, "nightly-build-2024-03-12", ".tar.gz"},
+		{long, 20, false, "nigh", ".tar.gz"},
+		{long, 40, true, "nightly-build-2024-03-12-linux", ""},
+		{"generated_" + strings.Repeat("x", 90) + ".json", 30, false, "generated_xxxxxx", ".json"},
+		{"report.final-version-2", 16, false, "report", ""}, // An extension that long is part of the name
+		{".very-long-hidden-configuration", 20, false, ".very-long", ""},
+		{"ünïcödé-" + strings.Repeat("é", 40) + ".txt", 24, false, "ünïcödé-ééé", ".txt"},
+		{strings.Repeat("👨‍👩‍👧", 10) + ".png", 20, false, "👨‍👩‍👧", ".png"}, // Seven runes of room, five to a family
+		{"x" + strings.Repeat("-", 40), 12, true, "x", ""},                 // Trailing separators are trimmed from the prefix
+		{"tiny", 2, false, "t", ""},                                        // A limit too small for the hash keeps one character
+	} {
+		got := suggestName(test.name, test.limit, test.isDir, nil)
+		stem, hash, _ := strings.Cut(strings.TrimSuffix(got, test.ext), test.prefix+"-")
+		if stem != "" || !strings.HasPrefix(got, test.prefix+"-") || len(hash) != nameHashLength || !strings.HasSuffix(got, test.ext) {
+			t.Errorf("suggestName(%q, %d) = %q, want %q-<%d hex digits>%s", test.name, test.limit, got, test.prefix, nameHashLength, test.ext)
+		}
+		if test.limit >= nameHashLength+len(test.ext)+2 && utf8.RuneCountInString(got) > test.limit {
+			t.Errorf("suggestName(%q, %d) = %q, longer than the limit", test.name, test.limit, got)
+		}
+		if again := suggestName(test.name, test.limit, test.isDir, nil); again != got {
+			t.Errorf("suggestName(%q) is %q, then %q", test.name, got, again)
+		}
+	}
+
+	first := suggestName(long, 30, false, nil)
+	second := suggestName(long, 30, false, map[string]bool{first: true})
+	third := suggestName(long, 30, false, map[string]bool{first: true, second: true})
+	if second == first || third == second || !strings.HasSuffix(second, ".tar.gz") || utf8.RuneCountInString(second) > 30 ||
+		len(strings.TrimSuffix(second, ".tar.gz"))-strings.LastIndex(second, "-")-1 != nameHashLength+2 {
+		t.Errorf("taken %q gives %q, then %q; want a hash two digits longer each time", first, second, third)
+	}
+}
+
+// A compressed tarball keeps both extensions, a dotfile and a name with a long
+// extension keep none
+func TestSplitNameExt(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"a.txt": {"a", ".txt"}, "a.tar.gz": {"a", ".tar.gz"}, "a.tar": {"a", ".tar"}, ".tar.gz": {".tar", ".gz"},
+		".bashrc": {".bashrc", ""}, "Makefile": {"Makefile", ""}, "x.verylongext": {"x.verylongext", ""}, "a.b.c": {"a.b", ".c"},
+	} {
+		if stem, ext := splitNameExt(name); stem != want[0] || ext != want[1] {
+			t.Errorf("splitNameExt(%q) = %q, %q; want %q, %q", name, stem, ext, want[0], want[1])
+		}
+	}
+}
+
+// nameFixture has two long names that share their first 40 characters in one directory
+const nameFixture = `
+README.md
+artifacts/a.txt
+artifacts/nightly-build-2024-03-12-linux-x86_64-release-candidate.tar.gz
+artifacts/nightly-build-2024-03-12-linux-x86_64-release-candidatf.tar.gz
+src/main.go
+`
+
+// --name-stats adds a section with the totals, the longest names, the directories by
+// average and the names over --name-max, with distinct suggestions in md, text and json
+func TestNameStats(t *testing.T) {
+	configure := func(format string) func() {
+		return func() {
+			setOption(t, &outputFormat, format)
+			setOption(t, &nameStatsEnabled, true)
+			setOption(t, &nameMax, 40)
+			setOption(t, &nameSuggest, true)
+			setOption(t, &noSummary, true)
+		}
+	}
+	long := "nightly-build-2024-03-12-linux-x86_64-release-candidate.tar.gz"
+	other := strings.Replace(long, "date", "datf", 1)
+	first := suggestName(long, 40, false, map[string]bool{"a.txt": true, long: true, other: true})
+	second := suggestName(other, 40, false, map[string]bool{"a.txt": true, long: true, other: true, first: true})
+	if first == second {
+		t.Fatalf("the fixture's names give one suggestion %q", first)
+	}
+	section := "\n## Name lengths\n\n" +
+		"7 names, 22.4 characters long on average; 2 longer than 40 characters.\n\n" +
+		"| longest names | characters |\n| --- | ---: |\n" +
+		"| `artifacts/" + long + "` | 62 |\n" +
+		"| `artifacts/" + other + "` | 62 |\n" +
+		"| `README.md` | 9 |\n| `artifacts` | 9 |\n| `src/main.go` | 7 |\n| `artifacts/a.txt` | 5 |\n| `src` | 3 |\n\n" +
+		"| directory | names | average | longest |\n| --- | ---: | ---: | ---: |\n" +
+		"| `artifacts` | 3 | 43.0 | 62 |\n| `.` | 3 | 7.0 | 9 |\n| `src` | 1 | 7.0 | 7 |\n\n" +
+		"| longer than 40 characters | characters | suggested name |\n| --- | ---: | --- |\n" +
+		"| `artifacts/" + long + "` | 62 | `" + first + "` |\n" +
+		"| `artifacts/" + other + "` | 62 | `" + second + "` |\n"
+	for _, format := range []string{formatMarkdown, formatText} {
+		if got := renderFixture(t, testtree.MapFS(t, nameFixture), true, configure(format)); !strings.HasSuffix(got, section) {
+			t.Errorf("-f %s: got\n%s\nwant it to end in\n%s", format, got, section)
+		}
+	}
+
+	var root struct {
+		NameStats struct {
+			Names         int
+			AverageLength float64
+			Limit         int
+			Longest       []struct{ Path string }
+			Directories   []struct{ Path string }
+			OverLimit     []struct{ Path, Suggestion string }
+		}
+	}
+	if err := json.Unmarshal([]byte(renderFixture(t, testtree.MapFS(t, nameFixture), true, configure(formatJSON))), &root); err != nil {
+		t.Fatal(err)
+	}
+	stats := root.NameStats
+	if stats.Names != 7 || stats.AverageLength != 22.4 || stats.Limit != 40 || len(stats.Longest) != 7 || len(stats.Directories) != 3 ||
+		len(stats.OverLimit) != 2 || stats.OverLimit[0].Suggestion != first || stats.OverLimit[1].Suggestion != second {
+		t.Errorf("nameStats = %+v", stats)
+	}
+
+	got := renderFixture(t, testtree.MapFS(t, "a.txt\n"), true, func() {
+		setOption(t, &outputFormat, formatMarkdown)
+		setOption(t, &nameStatsEnabled, true)
+		setOption(t, &nameMax, 40)
+	})
+	if !strings.HasSuffix(got, "1 names, 5.0 characters long on average; 0 longer than 40 characters.\n\n"+
+		"| longest names | characters |\n| --- | ---: |\n| `a.txt` | 5 |\n\n"+
+		"| directory | names | average | longest |\n| --- | ---: | ---: | ---: |\n| `.` | 1 | 5.0 | 5 |\n") {
+		t.Errorf("nothing over the limit:\n%s", got)
+	}
+
+	var many strings.Builder
+	for i := range nameOffendersShown + 2 {
+		fmt.Fprintf(&many, "%02d-%s.txt\n", i, strings.Repeat("n", 40))
+	}
+	got = renderFixture(t, testtree.MapFS(t, many.String()), true, func() {
+		setOption(t, &nameStatsEnabled, true)
+		setOption(t, &nameMax, 40)
+		setOption(t, &nameSuggest, false)
+	})
+	_, over, _ := strings.Cut(got, "| longer than 40 characters | characters |\n")
+	if rows := strings.Count(over, ".txt` | 47 |\n"); rows != nameOffendersShown || !strings.HasSuffix(over, "| … and 2 more | |\n") {
+		t.Errorf("%d of %d names over the limit listed:\n%s", rows, nameOffendersShown+2, over)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// onlyFixture has a match only reachable through a deep chain, a directory with no
+// match anywhere below it and extensions in both cases
+const onlyFixture = `
+a/b/c/d/deep.GO
+none/x/n.txt
+keep/r.MD
+keep/s.proto
+keep/t.txt
+top.go
+skip.txt
+`
+
+// --only-ext keeps the files with the extensions, case-insensitively and with or
+// without the dot, and the directories that lead to them; exclusions win
+func TestOnlyExt(t *testing.T) {
+	dir := testtree.Dir(t, onlyFixture)
+	deep := "├── [D] a\n" +
+		"│   └── [D] b\n" +
+		"│       └── [D] c\n" +
+		"│           └── [D] d\n" +
+		"│               └── [F] deep.GO\n"
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--only-ext", "go,.md"}, deep +
+			"├── [D] keep\n" +
+			"│   └── [F] r.MD\n" +
+			"└── [F] top.go\n"},
+		{[]string{"--only-ext", " .GO , md,"}, deep +
+			"├── [D] keep\n" +
+			"│   └── [F] r.MD\n" +
+			"└── [F] top.go\n"},
+		{[]string{"--only-ext", "proto"}, "└── [D] keep\n" +
+			"    └── [F] s.proto\n"},
+		{[]string{"--only-ext", "go,md", "-e", "deep.GO"}, "├── [D] keep\n" +
+			"│   └── [F] r.MD\n" +
+			"└── [F] top.go\n"},
+		{[]string{"--only-ext", "go", "-e", "b"}, "└── [F] top.go\n"},
+		{[]string{"--only-ext", "rs"}, ""},
+	} {
+		stdout, stderr, code := runFTG(t, dir, append([]string{"-d", ".", "-o", "-", "--no-summary", "--quiet"}, test.args...)...)
+		if code != exitOK || stdout != test.want {
+			t.Errorf("%q: exit code %d, got\n%s\nwant\n%s%s", test.args, code, stdout, test.want, stderr)
+		}
+	}
+
+	stdout, stderr, code := runFTG(t, dir, "explain", "--only-ext", "go", "none")
+	if want := "  verdict: hidden (does not match --only or --only-ext and contains no match)\n"; code != 0 || !strings.HasSuffix(stdout, want) {
+		t.Errorf("explain none: exit code %d, %s\n%s", code, stderr, stdout)
+	}
+}
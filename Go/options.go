@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Options documents are JSON objects keyed by long flag name, for example
+//
+//	{"e": "dist,coverage", "o": ["tree.md", "clipboard"], "max-depth": 3, "provenance": true}
+//
+// or the same keys as flat TOML, read by parseConfig as config files are. Strings,
+// numbers and booleans set a flag once; arrays set a repeatable flag once per
+// element. --print-config writes the same schema as JSON.
+var (
+	optionsFrom string // File, or "-" for stdin, holding an options document
+	printConfig bool   // Print the effective options document and exit
+)
+
+// applyOptions reads an options document, JSON or TOML, and applies every option not
+// given on the command line
+func applyOptions(source string) error {
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read options from %s: %v", source, err)
+	}
+	doc, err := parseConfig(source, data)
+	if err != nil {
+		return fmt.Errorf("invalid options document %s: %v", source, err)
+	}
+	return applyDocument(doc, source)
+}
+
+// applyDocument sets every option of a decoded document whose flag was not already set
+func applyDocument(doc map[string]any, source string) error {
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[canonicalFlag(f.Name)] = true })
+	names := make([]string, 0, len(doc))
+	for name := range doc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch {
+		case name == "options-from" || name == "print-config" || name == "config" || name == "no-config":
+			return fmt.Errorf("option %q cannot be set from an options document", name)
+		case flag.Lookup(name) == nil:
+			return fmt.Errorf("unknown option %q in %s", name, source)
+		case explicit[canonicalFlag(name)]:
+			continue
+		}
+		values, ok := doc[name].([]any)
+		if !ok {
+			values = []any{doc[name]}
+		}
+		for _, value := range values {
+			var text string
+			switch v := value.(type) {
+			case string:
+				text = v
+			case bool:
+				text = strconv.FormatBool(v)
+			case json.Number:
+				text = v.String()
+			default:
+				return fmt.Errorf("option %q in %s must be a string, number, boolean or array", name, source)
+			}
+			if err := flag.Set(name, text); err != nil {
+				return fmt.Errorf("option %q in %s: %v", name, source, err)
+			}
+		}
+	}
+	return nil
+}
+
+// showConfig prints every option set on the command line or by --options-from and exits
+func showConfig() {
+	doc := map[string]any{}
+	flag.Visit(func(f *flag.Flag) {
+		name := canonicalFlag(f.Name)
+		if list, ok := f.Value.(*stringList); ok {
+			doc[name] = []string(*list)
+			return
+		}
+		// Booleans and integers keep their JSON type; everything else, durations included, is text
+		if getter, ok := f.Value.(flag.Getter); ok {
+			switch value := getter.Get().(type) {
+			case bool, int:
+				doc[name] = value
+				return
+			}
+		}
+		doc[name] = f.Value.String()
+	})
+	delete(doc, "print-config")
+	delete(doc, "options-from")
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		errorExit(err.Error())
+	}
+	fmt.Println(string(out))
+	exitProcess(exitOK)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// printConfigArgs sets options of each JSON type: text, repeated, integer and boolean
+var printConfigArgs = []string{"-e", "dist,coverage", "-o", "tree.md", "-o", "tree.txt", "-L", "3", "--provenance", "--sort", "size"}
+
+// What --print-config writes, read back with --options-from, prints the same again;
+// so does the same document written as TOML, from a file or from stdin
+func TestOptionsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	want, stderr, code := runFTG(t, dir, append(printConfigArgs, "--print-config")...)
+	if code != exitOK || !strings.Contains(want, `"max-depth": 3`) {
+		t.Fatalf("exit code %d:\n%s%s", code, want, stderr)
+	}
+	toml := "e = \"dist,coverage\"\no = [\"tree.md\", \"tree.txt\"]\nmax-depth = 3\nprovenance = true\nsort = 'size'\n"
+	for name, data := range map[string]string{"options.json": want, "options.toml": toml, "options": toml} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got, stderr, _ := runFTG(t, dir, "--options-from", name, "--print-config"); got != want {
+			t.Errorf("--options-from %s printed\n%s%s\nwant\n%s", name, got, stderr, want)
+		}
+	}
+	for _, data := range []string{want, toml} {
+		cmd := ftgCommand(t, dir, "--options-from", "-", "--print-config")
+		cmd.Stdin = strings.NewReader(data)
+		if got, err := cmd.Output(); err != nil || string(got) != want {
+			t.Errorf("--options-from - printed\n%s(%v)\nwant\n%s", got, err, want)
+		}
+	}
+}
+
+// A flag on the command line wins over the document, which wins over the config file
+func TestOptionsPrecedence(t *testing.T) {
+	dir := testtree.Dir(t, "options.toml content=max-depth=3\n")
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte("max-depth = 1\nsort = \"size\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, stderr, code := runFTG(t, dir, "--config", "config.toml", "--options-from", "options.toml", "--print-config")
+	if code != exitOK || !strings.Contains(got, `"max-depth": 3`) || !strings.Contains(got, `"sort": "size"`) {
+		t.Errorf("exit code %d:\n%s%s", code, got, stderr)
+	}
+	if got, _, _ := runFTG(t, dir, "-L", "2", "--options-from", "options.toml", "--print-config"); !strings.Contains(got, `"max-depth": 2`) {
+		t.Errorf("-L 2 lost to the document:\n%s", got)
+	}
+}
+
+func TestOptionsRefused(t *testing.T) {
+	for _, test := range []struct {
+		doc, want string
+	}{
+		{`{"no-such-option": 1}`, `unknown option "no-such-option"`},
+		{`{"options-from": "other.json"}`, `option "options-from" cannot be set from an options document`},
+		{"[run]\nmax-depth = 3\n", "tables are not supported"},
+		{`{"max-depth": "deep"}`, `option "max-depth" in doc: parse error`},
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "doc"), []byte(test.doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, stderr, code := runFTG(t, dir, "--options-from", "doc", "--print-config"); code == exitOK || !strings.Contains(stderr, test.want) {
+			t.Errorf("%q: exit code %d, %s; want %q", test.doc, code, stderr, test.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// orphanRule flags a derived artifact whose companion file is missing.
+// A nil companion means every match is a leftover.
+type orphanRule struct {
+	name      string                      // Label used in the summary
+	patterns  []string                    // Globs matched against the entry name
+	dirOnly   bool                        // Match directories instead of files
+	companion func(dir, name string) bool // Reports whether the source/companion exists
+}
+
+// orphanRules are the built-in --find-orphans checks; add new rules here
+var orphanRules = []orphanRule{
+	{name: "python bytecode without source", patterns: []string{"*.pyc"}, companion: pythonSourceExists},
+	{name: "object file without source", patterns: []string{"*.o", "*.obj"}, companion: sourceWithExtension(".c", ".cc", ".cpp", ".cxx", ".m", ".s", ".S", ".asm")},
+	{name: "merge leftover", patterns: []string{"*.orig", "*.rej"}},
+	{name: "editor swap file", patterns: []string{".*.swp", ".*.swo", "*~", "#*#", ".#*"}},
+	{name: "node_modules without package.json", patterns: []string{"node_modules"}, dirOnly: true, companion: siblingExists("package.json")},
+}
+
+var (
+	findOrphans bool                    // Annotate suspected orphaned artifacts
+	orphanHits  = map[string][]string{} // Relative paths flagged, per rule name
+)
+
+// fileExists reports whether a path exists
+func fileExists(p string) bool {
+	_, err := lstat(p)
+	return err == nil
+}
+
+// siblingExists returns a companion check for a fixed file next to the entry
+func siblingExists(sibling string) func(dir, name string) bool {
+	return func(dir, _ string) bool {
+		return fileExists(filepath.Join(dir, sibling))
+	}
+}
+
+// sourceWithExtension returns a companion check for a same-stem file with any of exts
+func sourceWithExtension(exts ...string) func(dir, name string) bool {
+	return func(dir, name string) bool {
+		stem := strings.TrimSuffix(name, path.Ext(name))
+		for _, ext := range exts {
+			if fileExists(filepath.Join(dir, stem+ext)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// pythonSourceExists finds the .py for a .pyc, including __pycache__/mod.cpython-312.pyc layouts
+func pythonSourceExists(dir, name string) bool {
+	stem := strings.TrimSuffix(name, ".pyc")
+	if filepath.Base(dir) == "__pycache__" {
+		if i := strings.Index(stem, "."); i > 0 {
+			stem = stem[:i]
+		}
+		return fileExists(filepath.Join(filepath.Dir(dir), stem+".py"))
+	}
+	return fileExists(filepath.Join(dir, stem+".py"))
+}
+
+// matchOrphanRule returns the first rule that flags the entry, or nil
+func matchOrphanRule(dir, name string, isDir bool) *orphanRule {
+	for i := range orphanRules {
+		rule := &orphanRules[i]
+		if rule.dirOnly != isDir {
+			continue
+		}
+		for _, pattern := range rule.patterns {
+			if ok, _ := path.Match(pattern, name); !ok {
+				continue
+			}
+			if rule.companion == nil || !rule.companion(dir, name) {
+				return rule
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// orphanNote returns the annotation for a suspected orphan and records the hit
+func orphanNote(dir, name string, isDir bool) string {
+	if !findOrphans {
+		return ""
+	}
+	rule := matchOrphanRule(dir, name, isDir)
+	if rule == nil {
+		return ""
+	}
+	orphanHits[rule.name] = append(orphanHits[rule.name], relativePath(dir, name))
+	return "(orphan?)"
+}
+
+// writeOrphanSummary appends the per-rule counts and paths of suspected orphans
+func writeOrphanSummary(writer io.Writer) {
+	fmt.Fprintf(writer, "\n%s\n\n", msg("orphans.heading"))
+	total := 0
+	for _, rule := range orphanRules {
+		hits := orphanHits[rule.name]
+		total += len(hits)
+		fmt.Fprintf(writer, "- %s: %d\n", rule.name, len(hits))
+		sort.Strings(hits)
+		for _, hit := range hits {
+			fmt.Fprintf(writer, "  - %s\n", redactPath(displayPath(hit)))
+		}
+	}
+	if total == 0 {
+		fmt.Fprintf(writer, "\n%s\n", msg("orphans.none"))
+	}
+}
+
+// orphanPaths returns the suspected orphans per rule, sorted, with every rule present
+func orphanPaths() map[string][]string {
+	paths := map[string][]string{}
+	for _, rule := range orphanRules {
+		hits := []string{}
+		for _, hit := range orphanHits[rule.name] {
+			hits = append(hits, redactPath(displayPath(hit)))
+		}
+		sort.Strings(hits)
+		paths[rule.name] = hits
+	}
+	return paths
+}
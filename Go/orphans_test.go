@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Each rule flags its artifact when the companion is missing and leaves it alone
+// when the companion is there; a rule without a companion flags every match
+func TestOrphanRules(t *testing.T) {
+	src := testtree.Dir(t, "a.pyc\nb.py\nb.pyc\npkg/mod.py\npkg/__pycache__/mod.cpython-312.pyc\npkg/__pycache__/gone.cpython-312.pyc\n"+
+		"x.o\ny.c\ny.o\nz.obj\nz.cpp\nf.orig\ng.rej\n.f.swp\n.f.swo\nnotes~\n#a#\n.#lock\n"+
+		"web/package.json\nweb/node_modules/\napp/node_modules/\n")
+	for _, test := range []struct {
+		dir, name string
+		isDir     bool
+		rule      string // Empty when the entry is no orphan
+	}{
+		{"", "a.pyc", false, "python bytecode without source"},
+		{"", "b.pyc", false, ""},
+		{"pkg/__pycache__", "gone.cpython-312.pyc", false, "python bytecode without source"},
+		{"pkg/__pycache__", "mod.cpython-312.pyc", false, ""},
+		{"", "x.o", false, "object file without source"},
+		{"", "y.o", false, ""},
+		{"", "z.obj", false, ""},
+		{"", "f.orig", false, "merge leftover"},
+		{"", "g.rej", false, "merge leftover"},
+		{"", ".f.swp", false, "editor swap file"},
+		{"", ".f.swo", false, "editor swap file"},
+		{"", "notes~", false, "editor swap file"},
+		{"", "#a#", false, "editor swap file"},
+		{"", ".#lock", false, "editor swap file"},
+		{"app", "node_modules", true, "node_modules without package.json"},
+		{"web", "node_modules", true, ""},
+		{"", "y.c", false, ""},
+		{"", "pkg", true, ""},
+	} {
+		got := ""
+		if rule := matchOrphanRule(filepath.Join(src, filepath.FromSlash(test.dir)), test.name, test.isDir); rule != nil {
+			got = rule.name
+		}
+		if got != test.rule {
+			t.Errorf("%s in %q: rule %q, want %q", test.name, test.dir, got, test.rule)
+		}
+	}
+}
+
+// --find-orphans annotates the matches in the tree and lists them per rule after it,
+// every rule present in the json even without a match
+func TestFindOrphans(t *testing.T) {
+	src := testtree.Dir(t, "a.pyc\nb.py\nb.pyc\nf.orig\napp/node_modules/\nweb/package.json\nweb/node_modules/\n")
+	stdout, stderr, code := runFTG(t, t.TempDir(), "-d", src, "--find-orphans", "-c", "-o", "-", "--quiet")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	for _, want := range []string{
+		"├── [F] a.pyc (orphan?)\n", "├── [F] b.pyc\n", "├── [F] f.orig (orphan?)\n", "│   └── [D] node_modules (orphan?)\n",
+		"\n## Orphan candidates\n\n- python bytecode without source: 1\n  - a.pyc\n- object file without source: 0\n" +
+			"- merge leftover: 1\n  - f.orig\n- editor swap file: 0\n- node_modules without package.json: 1\n  - app/node_modules\n",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("missing %q:\n%s", want, stdout)
+		}
+	}
+
+	stdout, _, _ = runFTG(t, t.TempDir(), "-d", src, "--find-orphans", "-c", "-f", "json", "-o", "-", "--quiet")
+	var root struct{ Orphans map[string][]string }
+	if err := json.Unmarshal([]byte(stdout), &root); err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Orphans) != len(orphanRules) || !slices.Equal(root.Orphans["merge leftover"], []string{"f.orig"}) ||
+		root.Orphans["editor swap file"] == nil || len(root.Orphans["editor swap file"]) != 0 {
+		t.Errorf("orphans = %v", root.Orphans)
+	}
+
+	stdout, _, _ = runFTG(t, t.TempDir(), "-d", testtree.Dir(t, "b.py\nb.pyc\n"), "--find-orphans", "-o", "-", "--quiet")
+	if !strings.Contains(stdout, "\nNo orphaned artifacts found.\n") {
+		t.Errorf("no orphans:\n%s", stdout)
+	}
+}
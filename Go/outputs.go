@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Upload settings for --post-url
+var (
+	postURL         string                           // Endpoint that receives the rendered tree via PUT
+	postContentType = "text/markdown; charset=utf-8" // Content-Type header sent with the upload
+	postAuthEnv     string                           // Environment variable holding the Authorization header value
+	postRetries     = 3                              // Attempts made before an upload is reported as failed
+)
+
+// stdoutTarget is the -o value that writes the tree to standard output
+const stdoutTarget = "-"
+
+var (
+	stdout         io.Writer = os.Stdout // Destination of -o -
+	messages       io.Writer = os.Stdout // Status messages; moved to stderr when the tree goes to stdout
+	forceOverwrite bool                  // --force: replace output files that already exist
+)
+
+// toStdout reports whether one of the destinations is standard output
+func toStdout(locations []string) bool {
+	return stdoutCount(locations) > 0
+}
+
+// stdoutCount returns how many of the output locations are stdout
+func stdoutCount(locations []string) int {
+	count := 0
+	for _, location := range locations {
+		if location == stdoutTarget {
+			count++
+		}
+	}
+	return count
+}
+
+// stringList is a flag.Value that collects every occurrence of a repeated flag
+type stringList []string
+
+// String returns the collected values as a comma-separated list
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set appends a value each time the flag appears
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// checkOverwrite refuses to replace an existing output file without --force, so two
+// runs in the same second never silently overwrite each other. The file
+// --preserve-annotations reads its notes from is rewritten by design.
+func checkOverwrite(location string) error {
+	if forceOverwrite || location == stdoutTarget || location == clipboardTarget {
+		return nil
+	}
+	if preserveAnnotations && location == annotationSource(outputLocations) {
+		return nil
+	}
+	// --skip-unchanged replaces ftg's own output when its fingerprint no longer matches
+	if skipUnchanged && hasFingerprint(location) {
+		return nil
+	}
+	if _, err := os.Lstat(location); err == nil {
+		return fmt.Errorf("output location %s already exists; pass --force to replace it", location)
+	}
+	return nil
+}
+
+// writeFile writes the rendered tree to a local destination
+func writeFile(location string, data []byte) error {
+	if err := writeAtomic(location, data); err != nil {
+		return fmt.Errorf("cannot write to output location %s: %v", location, err)
+	}
+	return nil
+}
+
+// writeAtomic replaces location in one step so readers never see a partial file
+func writeAtomic(location string, data []byte) error {
+	f, err := createAtomic(location)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.abort()
+		return err
+	}
+	return f.commit()
+}
+
+// atomicFile is a temporary file that replaces its target when committed
+type atomicFile struct {
+	*os.File
+	location string
+	perm     os.FileMode // Permissions the target gets
+	direct   bool        // Written in place, since no temporary file could be created next to it
+}
+
+// createAtomic starts a replacement of location. The temporary file is created next
+// to the target, on the same volume, so the rename cannot cross filesystems. A
+// target that already exists keeps its permissions; a new one gets 0644.
+func createAtomic(location string) (*atomicFile, error) {
+	perm := os.FileMode(0o644)
+	if info, err := os.Stat(location); err == nil && info.Mode().IsRegular() {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(location), "."+filepath.Base(location)+".*.tmp")
+	if err != nil {
+		// Directories we may write files in but not create them fall back to a plain write
+		f, err := os.OpenFile(location, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &atomicFile{File: f, location: location, perm: perm, direct: true}, nil
+	}
+	pendingTemps.Lock()
+	pendingTemps.names[tmp.Name()] = true
+	pendingTemps.Unlock()
+	return &atomicFile{File: tmp, location: location, perm: perm}, nil
+}
+
+// commit moves the temporary file over the target; if the rename still fails with
+// EXDEV the data is copied instead
+func (f *atomicFile) commit() error {
+	if err := f.Close(); err != nil {
+		f.abort()
+		return err
+	}
+	if f.direct {
+		return nil
+	}
+	pendingTemps.Lock()
+	defer pendingTemps.Unlock()
+	delete(pendingTemps.names, f.Name())
+	defer os.Remove(f.Name())
+	if err := os.Chmod(f.Name(), f.perm); err != nil {
+		return err
+	}
+	err := os.Rename(f.Name(), f.location)
+	if errors.Is(err, syscall.EXDEV) {
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(f.location, data, f.perm)
+	}
+	return err
+}
+
+// abort drops the temporary file and leaves the target as it was
+func (f *atomicFile) abort() {
+	f.Close()
+	if !f.direct {
+		pendingTemps.Lock()
+		delete(pendingTemps.names, f.Name())
+		os.Remove(f.Name())
+		pendingTemps.Unlock()
+	}
+}
+
+// putURL uploads the rendered tree, retrying network errors and 429/5xx responses
+func putURL(url string, data []byte) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var lastErr error
+	for attempt := 1; attempt <= postRetries; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * time.Second)
+		}
+
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("invalid upload URL %s: %v", url, err)
+		}
+		req.Header.Set("Content-Type", postContentType)
+		if postAuthEnv != "" {
+			if auth := os.Getenv(postAuthEnv); auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode < 300:
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			lastErr = fmt.Errorf("server responded %s", resp.Status)
+		default:
+			return fmt.Errorf("upload to %s rejected: %s", url, resp.Status)
+		}
+	}
+	return fmt.Errorf("upload to %s failed after %d attempts: %v", url, postRetries, lastErr)
+}
+
+// writeOutputs delivers the rendered tree to every destination and reports
+// whether all of them succeeded; each failure is logged individually
+func writeOutputs(locations []string, data []byte) bool {
+	ok := writeLocations(locations, data)
+	if postURL != "" {
+		if err := putURL(postURL, data); err != nil {
+			warnf("Error: %v", err)
+			ok = false
+		} else {
+			writtenOutputs = append(writtenOutputs, postURL)
+			fmt.Fprintln(messages, msg("status.uploaded", postURL))
+		}
+	}
+	return ok
+}
+
+// writeLocations is writeOutputs without the --post-url upload
+func writeLocations(locations []string, data []byte) bool {
+	ok := true
+	for _, location := range locations {
+		if location == stdoutTarget {
+			if _, err := stdout.Write(data); err != nil {
+				warnf("Error: cannot write to standard output: %v", err)
+				ok = false
+				continue
+			}
+			writtenOutputs = append(writtenOutputs, location)
+			continue
+		}
+		if location == clipboardTarget {
+			if err := copyToClipboard(data); err != nil {
+				warnf("Error: cannot copy to clipboard: %v", err)
+				ok = false
+				continue
+			}
+			writtenOutputs = append(writtenOutputs, location)
+			fmt.Fprintln(messages, msg("status.copied"))
+			continue
+		}
+		if skipUnchanged && unchangedOnDisk(location, data) {
+			writtenOutputs = append(writtenOutputs, location)
+			fmt.Fprintln(messages, msg("status.unchanged", location))
+			continue
+		}
+		if err := checkOverwrite(location); err != nil {
+			warnf("Error: %v", err)
+			ok = false
+			continue
+		}
+		if err := writeFile(location, data); err != nil {
+			warnf("Error: %v", err)
+			ok = false
+			continue
+		}
+		writtenOutputs = append(writtenOutputs, location)
+		fmt.Fprintln(messages, msg("status.written", location))
+	}
+	return ok
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestAtomicReplaceKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no permission bits to keep")
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		existing os.FileMode // 0 for no file yet
+		want     os.FileMode
+	}{
+		{"new file", 0, 0o644},
+		{"private file", 0o600, 0o600},
+		{"group-writable file", 0o664, 0o664},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			location := filepath.Join(dir, test.name+".md")
+			if test.existing != 0 {
+				if err := os.WriteFile(location, []byte("old\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(location, test.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			f, err := createAtomic(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString("new\n"); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.commit(); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != test.want {
+				t.Errorf("mode = %o, want %o", got, test.want)
+			}
+			if data, _ := os.ReadFile(location); string(data) != "new\n" {
+				t.Errorf("content = %q, want the replacement", data)
+			}
+		})
+	}
+}
+
+// Without -o each format is written to a file named with its extension
+func TestDefaultOutputExtension(t *testing.T) {
+	want := map[string]string{
+		formatMarkdown: "tree.md", formatMarkdownList: "tree.md", formatMermaid: "tree.md", formatText: "tree.txt",
+		formatHTML: "tree.html", formatJSON: "tree.json", formatManifest: "tree.json", formatSVG: "tree.svg",
+		formatCSV: "tree.csv", formatTSV: "tree.tsv", formatPaths: "tree.txt",
+	}
+	for format := range formats {
+		t.Run(format, func(t *testing.T) {
+			dir := testtree.Dir(t, "src/a.txt\n")
+			if _, stderr, code := runFTG(t, dir, "-d", "src", "-f", format, "--output-template", "tree.{ext}"); code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			if _, err := os.Stat(filepath.Join(dir, want[format])); err != nil {
+				t.Errorf("-f %s did not write %s: %v", format, want[format], err)
+			}
+		})
+	}
+}
+
+// An -o file named for another format gets a warning unless --no-ext-check is given
+func TestOutputExtensionMismatch(t *testing.T) {
+	tests := []struct {
+		args []string
+		warn bool
+	}{
+		{[]string{"-f", "json", "-o", "tree.md"}, true},
+		{[]string{"-f", "json", "-o", "tree.md", "--no-ext-check"}, false},
+		{[]string{"-f", "json", "-o", "tree.JSON"}, false},
+		{[]string{"-f", "text", "-o", "tree"}, false},
+		{[]string{"-f", "md", "-o", "tree.markdown"}, false},
+		{[]string{"-o", "tree.html"}, true},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			dir := testtree.Dir(t, "src/a.txt\n")
+			stdout, stderr, code := runFTG(t, dir, append([]string{"-d", "src"}, test.args...)...)
+			warned := strings.Contains(stdout+stderr, "--no-ext-check keeps quiet about it")
+			if wantCode := map[bool]int{true: exitWarnings, false: exitOK}[test.warn]; warned != test.warn || code != wantCode {
+				t.Errorf("warned %v with exit code %d, want %v and %d: %s", warned, code, test.warn, wantCode, stdout+stderr)
+			}
+		})
+	}
+}
+
+// uploadServer answers each PUT with the next status, recording the requests
+func uploadServer(t *testing.T, statuses ...int) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requests = append(requests, r)
+		w.WriteHeader(statuses[min(len(requests), len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// --post-url PUTs the tree with its content type and the Authorization header of
+// --post-auth-env, retries 429 and 5xx responses and gives up on other errors
+func TestPutURL(t *testing.T) {
+	t.Setenv("FTG_TEST_TOKEN", "Bearer secret")
+	setOption(t, &postAuthEnv, "FTG_TEST_TOKEN")
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		want     string // In the error, "" for success
+	}{
+		{"created", []int{http.StatusCreated}, 1, ""},
+		{"retried", []int{http.StatusServiceUnavailable, http.StatusOK}, 2, ""},
+		{"rejected", []int{http.StatusForbidden}, 1, "rejected: 403 Forbidden"},
+		{"exhausted", []int{http.StatusTooManyRequests}, 2, "failed after 2 attempts: server responded 429 Too Many Requests"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setOption(t, &postRetries, 2)
+			server, requests := uploadServer(t, test.statuses...)
+			err := putURL(server.URL+"/tree.md", []byte("tree\n"))
+			if test.want == "" && err != nil || test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)) {
+				t.Errorf("putURL = %v, want %q", err, test.want)
+			}
+			if len(*requests) != test.attempts {
+				t.Fatalf("%d requests, want %d", len(*requests), test.attempts)
+			}
+			r := (*requests)[0]
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPut || r.URL.Path != "/tree.md" || string(body) != "tree\n" ||
+				r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Content-Type") != postContentType {
+				t.Errorf("request %s %s %q, headers %v", r.Method, r.URL.Path, body, r.Header)
+			}
+		})
+	}
+}
+
+// A failed upload is reported on its own and fails the run; the -o file is still written
+func TestPostURLPartialFailure(t *testing.T) {
+	server, _ := uploadServer(t, http.StatusBadRequest)
+	dir := testtree.Dir(t, "src/a.txt\n")
+	_, stderr, code := runFTG(t, dir, "-d", "src", "-o", "tree.md", "--post-url", server.URL, "--post-auth-env", "UNSET_FTG_TOKEN")
+	if code == exitOK || !strings.Contains(stderr, "rejected: 400 Bad Request") {
+		t.Errorf("exit code %d: %s", code, stderr)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "tree.md")); err != nil || !strings.Contains(string(data), "a.txt") {
+		t.Errorf("tree.md = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	overlayPlan string   // --overlay: render the tree as it would be after the moves, deletions and creations of this plan
+	overlayOps  []planOp // The parsed plan, applied to every render
+)
+
+// planOp is one operation of an --overlay plan, on slash-separated paths relative to the root
+type planOp struct {
+	line   int    // Line of the plan, for errors
+	action string // move, delete or create
+	from   string // Path moved, deleted or created
+	to     string // Where a move puts it
+	dir    bool   // A created entry is a directory
+}
+
+// loadPlan reads an --overlay plan: a YAML sequence of single-key items, one per line,
+//
+//   - move: services/old -> services/new
+//   - delete: legacy/notes.txt
+//   - create: docs/adr (dir)
+//
+// A created entry is a file unless it is marked (dir) or its path ends in "/".
+// Paths may be quoted; # starts a comment.
+func loadPlan(file string) ([]planOp, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var ops []planOp
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(yamlStripComment(scanner.Text()))
+		if text == "" || (n == 1 && text == "---") {
+			continue
+		}
+		op, err := parsePlanLine(strings.TrimSpace(strings.TrimPrefix(text, "- ")))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		op.line = n
+		ops = append(ops, op)
+	}
+	return ops, scanner.Err()
+}
+
+// parsePlanLine parses "move: a -> b", "delete: a" or "create: a (dir)"
+func parsePlanLine(text string) (planOp, error) {
+	action, rest, ok := yamlKey(text)
+	if !ok || rest == "" {
+		return planOp{}, fmt.Errorf("expected move:, delete: or create: and a path, not %q", text)
+	}
+	op := planOp{action: action}
+	var err error
+	switch action {
+	case "move":
+		from, to, found := strings.Cut(rest, " -> ")
+		if !found {
+			return planOp{}, fmt.Errorf("a move needs old -> new, not %q", rest)
+		}
+		if op.from, err = planPath(from); err != nil {
+			return planOp{}, err
+		}
+		op.to, err = planPath(to)
+	case "delete":
+		op.from, err = planPath(rest)
+	case "create":
+		if kind, found := strings.CutSuffix(rest, ")"); found {
+			if i := strings.LastIndex(kind, " ("); i >= 0 {
+				switch kind[i+2:] {
+				case "dir":
+					op.dir = true
+				case "file":
+				default:
+					return planOp{}, fmt.Errorf("a created entry is a (dir) or a (file), not (%s)", kind[i+2:])
+				}
+				rest = kind[:i]
+			}
+		}
+		op.dir = op.dir || strings.HasSuffix(strings.Trim(rest, `"'`), "/")
+		op.from, err = planPath(rest)
+	default:
+		return planOp{}, fmt.Errorf("unknown operation %q (use move, delete or create)", action)
+	}
+	return op, err
+}
+
+// planPath returns a plan path as a clean path relative to the root. The root itself
+// and paths leaving it are refused.
+func planPath(text string) (string, error) {
+	value, err := yamlScalar(strings.TrimSpace(text))
+	if err != nil {
+		return "", err
+	}
+	slashed := strings.TrimSuffix(filepath.ToSlash(value), "/")
+	clean := path.Clean(strings.TrimPrefix(slashed, "/"))
+	switch {
+	case slashed == "" || clean == ".":
+		return "", fmt.Errorf("the root cannot be moved, deleted or created")
+	case clean == ".." || strings.HasPrefix(clean, "../"):
+		return "", fmt.Errorf("%s is outside the root", value)
+	}
+	return clean, nil
+}
+
+// overlayNode is an entry of the planned tree. Entries that exist come from the
+// walked tree and are listed from it when first needed; planned ones only exist here.
+type overlayNode struct {
+	name     string
+	source   string      // Path in the walked tree; "" for a planned entry
+	entry    fs.DirEntry // The walked entry; nil for the root and planned entries
+	dir      bool
+	children map[string]*overlayNode // Nil until listed
+	listErr  error                   // Why the directory could not be listed
+	note     string                  // (moved from old) or (planned)
+}
+
+// overlayFS is the tree as an --overlay plan would leave it, as an fs.FS over the
+// walked one. Moved entries keep their source, so contents and link targets are
+// read from where they are now. Workers of --jobs list directories concurrently,
+// and listing fills in the tree, so mu guards it.
+type overlayFS struct {
+	base fs.FS
+	root string // The input directory, for link targets base cannot read
+	mu   sync.Mutex
+	top  *overlayNode
+}
+
+// overlayTree applies the --overlay plan to the tree below root. Operations that do
+// not apply are collected with their lines and fail the run, since the rendered tree
+// would not be the planned one.
+func overlayTree(root string, base fs.FS) (*overlayFS, error) {
+	o := &overlayFS{base: base, root: root, top: &overlayNode{name: ".", source: ".", dir: true}}
+	var problems []string
+	for _, op := range overlayOps {
+		if err := o.apply(op); err != nil {
+			problems = append(problems, fmt.Sprintf("%s:%d: %v", overlayPlan, op.line, err))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("the --overlay plan does not apply to %s:\n  %s", root, strings.Join(problems, "\n  "))
+	}
+	return o, nil
+}
+
+// apply performs one operation on the planned tree
+func (o *overlayFS) apply(op planOp) error {
+	parent, name := o.find(path.Dir(op.from)), path.Base(op.from)
+	var node *overlayNode
+	if parent != nil {
+		node = o.children(parent)[name]
+	}
+	switch op.action {
+	case "move":
+		if node == nil {
+			return fmt.Errorf("cannot move %s: it does not exist", op.from)
+		}
+		if op.to == op.from || strings.HasPrefix(op.to, op.from+"/") {
+			return fmt.Errorf("cannot move %s into itself", op.from)
+		}
+		target, err := o.parentFor(op.to)
+		if err != nil {
+			return fmt.Errorf("cannot move %s to %s: %v", op.from, op.to, err)
+		}
+		delete(parent.children, name)
+		node.name = path.Base(op.to)
+		if node.note == "" {
+			node.note = "(moved from " + op.from + ")"
+		}
+		target.children[node.name] = node
+	case "delete":
+		if node == nil {
+			return fmt.Errorf("cannot delete %s: it does not exist", op.from)
+		}
+		delete(parent.children, name)
+	case "create":
+		target, err := o.parentFor(op.from)
+		if err != nil {
+			return fmt.Errorf("cannot create %s: %v", op.from, err)
+		}
+		node := &overlayNode{name: name, dir: op.dir, note: "(planned)"}
+		if op.dir {
+			node.children = map[string]*overlayNode{}
+		}
+		target.children[name] = node
+	}
+	return nil
+}
+
+// parentFor returns the directory a new entry at p goes in, which must exist already,
+// while p itself must not
+func (o *overlayFS) parentFor(p string) (*overlayNode, error) {
+	parent := o.find(path.Dir(p))
+	switch {
+	case parent == nil:
+		return nil, fmt.Errorf("%s does not exist", path.Dir(p))
+	case !parent.dir:
+		return nil, fmt.Errorf("%s is not a directory", path.Dir(p))
+	case o.children(parent)[path.Base(p)] != nil:
+		return nil, fmt.Errorf("%s already exists", p)
+	}
+	return parent, nil
+}
+
+// find returns the node at a slash-separated path, listing directories on the way
+func (o *overlayFS) find(name string) *overlayNode {
+	node := o.top
+	if name == "." {
+		return node
+	}
+	for _, part := range strings.Split(name, "/") {
+		if !node.dir {
+			return nil
+		}
+		if node = o.children(node)[part]; node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// children returns the entries of a directory node, listing its source the first time
+func (o *overlayFS) children(node *overlayNode) map[string]*overlayNode {
+	if node.children != nil || !node.dir {
+		return node.children
+	}
+	node.children = map[string]*overlayNode{}
+	entries, err := fs.ReadDir(o.base, node.source)
+	node.listErr = err
+	for _, entry := range entries {
+		source := path.Join(node.source, entry.Name())
+		node.children[entry.Name()] = &overlayNode{name: entry.Name(), source: source, entry: entry, dir: entry.IsDir()}
+	}
+	return node.children
+}
+
+// lookup returns the node of a valid fs.FS name
+func (o *overlayFS) lookup(op, name string) (*overlayNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	node := o.find(name)
+	if node == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return node, nil
+}
+
+// Open lists directories from the planned tree and reads files from their source;
+// planned files are empty
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	node, err := o.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.dir && node.source != "" {
+		return o.base.Open(node.source)
+	}
+	return &overlayFile{fs: o, path: name, node: node}, nil
+}
+
+// ReadDir returns the sorted entries of a directory of the planned tree
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.readDir(name)
+}
+
+// readDir is ReadDir with mu held
+func (o *overlayFS) readDir(name string) ([]fs.DirEntry, error) {
+	node, err := o.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	children := o.children(node)
+	if node.listErr != nil {
+		return nil, node.listErr
+	}
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, overlayEntry{child})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Stat returns an entry's metadata, following a link at its source
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	node, err := o.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.source == "" {
+		return plannedInfo{node}, nil
+	}
+	info, err := fs.Stat(o.base, node.source)
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{info, node.name}, nil
+}
+
+// Lstat returns an entry's metadata without following links
+func (o *overlayFS) Lstat(name string) (fs.FileInfo, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	node, err := o.lookup("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	if node == o.top {
+		return fs.Stat(o.base, ".")
+	}
+	return overlayEntry{node}.Info()
+}
+
+// ReadLink returns the target of a link at its source
+func (o *overlayFS) ReadLink(name string) (string, error) {
+	o.mu.Lock()
+	node, err := o.lookup("readlink", name)
+	o.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	if node.source == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	if linkFS, ok := o.base.(interface {
+		ReadLink(name string) (string, error)
+	}); ok {
+		return linkFS.ReadLink(node.source)
+	}
+	return os.Readlink(filepath.Join(o.root, filepath.FromSlash(node.source)))
+}
+
+// overlayEntry is the fs.DirEntry of a node of the planned tree
+type overlayEntry struct {
+	node *overlayNode
+}
+
+func (e overlayEntry) Name() string { return e.node.name }
+func (e overlayEntry) IsDir() bool  { return e.node.dir }
+
+// Type returns the walked entry's type; planned entries are directories or files
+func (e overlayEntry) Type() fs.FileMode {
+	if e.node.entry != nil {
+		return e.node.entry.Type()
+	}
+	if e.node.dir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+// Info returns the walked entry's metadata under its planned name
+func (e overlayEntry) Info() (fs.FileInfo, error) {
+	if e.node.entry == nil {
+		return plannedInfo{e.node}, nil
+	}
+	info, err := e.node.entry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{info, e.node.name}, nil
+}
+
+// renamedInfo is the metadata of a moved entry under its new name
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string { return i.name }
+
+// plannedInfo is the metadata of a planned entry: empty, and as new as the scan
+type plannedInfo struct {
+	node *overlayNode
+}
+
+func (i plannedInfo) Name() string       { return i.node.name }
+func (i plannedInfo) Size() int64        { return 0 }
+func (i plannedInfo) ModTime() time.Time { return scanStarted }
+func (i plannedInfo) IsDir() bool        { return i.node.dir }
+func (i plannedInfo) Sys() any           { return nil }
+
+// Mode returns the permissions a new directory or file usually gets
+func (i plannedInfo) Mode() fs.FileMode {
+	if i.node.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// overlayFile is an opened directory or planned file of the planned tree
+type overlayFile struct {
+	fs     *overlayFS
+	path   string
+	node   *overlayNode
+	offset int // Entries already returned by ReadDir(n)
+}
+
+// Stat returns the entry's metadata
+func (f *overlayFile) Stat() (fs.FileInfo, error) { return f.fs.Stat(f.path) }
+
+// Read reads nothing: a planned file is empty, and a directory has no contents
+func (f *overlayFile) Read([]byte) (int, error) {
+	if f.node.dir {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: errors.New("is a directory")}
+	}
+	return 0, io.EOF
+}
+
+// Close does nothing
+func (f *overlayFile) Close() error { return nil }
+
+// ReadDir lists a directory in batches of n, or whole for n <= 0
+func (f *overlayFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.fs.mu.Lock()
+	entries, err := f.fs.readDir(f.path)
+	f.fs.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	entries = entries[f.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		entries = entries[:min(n, len(entries))]
+	}
+	f.offset += len(entries)
+	return entries, nil
+}
+
+// overlayNote returns (moved from old) or (planned) for an entry the --overlay plan
+// changed. Only nodes the walk already listed are looked at.
+func overlayNote(fullPath string) string {
+	o, ok := treeFS.(*overlayFS)
+	if !ok {
+		return ""
+	}
+	name, ok := fsPath(fullPath)
+	if !ok {
+		return ""
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	node := o.top
+	for _, part := range strings.Split(name, "/") {
+		if node = node.children[part]; node == nil {
+			return ""
+		}
+	}
+	return node.note
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// overviewNode is one entry of the --overview-depth summary
+type overviewNode struct {
+	depth  int
+	label  string
+	isDir  bool
+	anchor string // Anchor of the directory's place in the full tree, "" for files and closed directories
+	dirs   int    // Directories shown below it in the full tree
+	files  int    // Other entries shown below it in the full tree
+}
+
+var (
+	overviewDepth int                          // --overview-depth: levels of the overview above the full tree, 0 for none
+	overviewNodes []*overviewNode              // Overview entries in tree order
+	overviewByRel = map[string]*overviewNode{} // Overview directories by relative path, for the rollups
+	fenceOpen     bool                         // The full tree's code block is open
+)
+
+// renderOverview writes the overview and the full tree below it from one walk: the
+// full tree is rendered first and the overview collected along the way
+func renderOverview(ctx context.Context, writer io.Writer, root string, entries []fs.DirEntry) {
+	var full strings.Builder
+	generateTree(ctx, &full, root, entries)
+	if fenceOpen {
+		full.WriteString("```\n")
+	}
+
+	fmt.Fprintf(writer, "\n%s\n\n", msg("overview.heading"))
+	for _, node := range overviewNodes {
+		indent := strings.Repeat("  ", node.depth-1)
+		label := codeSpan(node.label)
+		if node.isDir {
+			label = codeSpan(node.label + "/")
+		}
+		if node.anchor != "" {
+			label = "[" + label + "](#" + node.anchor + ")"
+		}
+		if node.isDir {
+			fmt.Fprintf(writer, "%s- %s — %s, %s\n", indent, label, msgCount("count.dir", node.dirs), msgCount("count.file", node.files))
+		} else {
+			fmt.Fprintf(writer, "%s- %s\n", indent, label)
+		}
+	}
+	fmt.Fprintf(writer, "\n%s\n\n%s", msg("overview.full"), full.String())
+}
+
+// recordOverview is called for every entry of the full tree before it is printed. It
+// adds the entry to the rollups of its overview ancestors, collects entries within the
+// overview depth, and starts a new code block behind an anchor at each such directory
+// so the overview can link there; the first entry opens the code block.
+func recordOverview(writer io.Writer, path string, entry fs.DirEntry, label string, descend bool) {
+	if overviewDepth == 0 {
+		return
+	}
+	rel := relativePath(path, entry.Name())
+	parts := strings.Split(rel, "/")
+	isDir := descend || entry.IsDir()
+	for i := 1; i < len(parts) && i <= overviewDepth; i++ {
+		if node := overviewByRel[strings.Join(parts[:i], "/")]; node != nil {
+			if isDir {
+				node.dirs++
+			} else {
+				node.files++
+			}
+		}
+	}
+
+	if len(parts) <= overviewDepth {
+		node := &overviewNode{depth: len(parts), label: label, isDir: isDir}
+		if descend {
+			node.anchor = fmt.Sprintf("ftg-dir-%d", len(overviewByRel)+1)
+			overviewByRel[rel] = node
+			if fenceOpen {
+				fmt.Fprintln(writer, "```")
+			}
+			fmt.Fprintf(writer, "<a id=\"%s\"></a>\n\n", node.anchor)
+			fenceOpen = false
+		}
+		overviewNodes = append(overviewNodes, node)
+	}
+	if !fenceOpen {
+		fmt.Fprintln(writer, "```sh")
+		fenceOpen = true
+	}
+}
+
+// codeSpan wraps text, made printable, in a markdown code span whose delimiters are
+// longer than any run of backticks inside it
+func codeSpan(text string) string {
+	text = printableName(text)
+	run := longestBacktickRun([]byte(text))
+	if run == 0 {
+		return "`" + text + "`"
+	}
+	delimiter := strings.Repeat("`", run+1)
+	return delimiter + " " + text + " " + delimiter
+}
+
+// tableSpan is codeSpan for a table cell, where a "|" would end the cell even inside
+// a code span
+func tableSpan(text string) string {
+	return strings.ReplaceAll(codeSpan(text), "|", `\|`)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+const overviewTree = `
+	README.md
+	docs/a.md
+	empty/
+	src/util.go
+	src/app/main.go
+	src/app/deep/x.go
+	src/app/deep/y.go
+	vendor/lib.go
+`
+
+// --overview-depth puts the linked overview with its rollups above the full tree, and
+// both follow the filters
+func TestOverviewGolden(t *testing.T) {
+	dir := testtree.Dir(t, overviewTree)
+	for _, test := range []struct {
+		golden string
+		args   []string
+	}{
+		{"overview-1", []string{"--overview-depth", "1"}},
+		{"overview-2", []string{"--overview-depth", "2"}},
+		{"overview-2-excluded", []string{"--overview-depth", "2", "-e", "vendor,deep"}},
+		{"overview-1-de", []string{"--overview-depth", "1", "--lang", "de"}},
+	} {
+		t.Run(test.golden, func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, append([]string{"-d", ".", "-o", "-", "--quiet"}, test.args...)...)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			testtree.Golden(t, test.golden+".md", []byte(stdout))
+		})
+	}
+}
+
+// The full tree below the overview is the plain tree line for line, split at the
+// anchors, and comes from the same single walk
+func TestOverviewFullTree(t *testing.T) {
+	render := func(depth int) (string, int32) {
+		var reads atomic.Int32
+		got := renderFixture(t, cancellingFS{testtree.MapFS(t, overviewTree), 0, &reads, func() {}}, false, func() {
+			setOption(t, &overviewDepth, depth)
+			setOption(t, &noSummary, true)
+		})
+		return got, reads.Load()
+	}
+	plain, plainReads := render(0)
+	overview, overviewReads := render(2)
+	if overviewReads != plainReads {
+		t.Errorf("--overview-depth read %d directories, the plain tree %d", overviewReads, plainReads)
+	}
+
+	treeLines := func(text string) []string {
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "├") || strings.HasPrefix(line, "└") || strings.HasPrefix(line, "│") || strings.HasPrefix(line, " ") {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+	_, full, ok := strings.Cut(overview, "\n## Full tree\n")
+	if !ok {
+		t.Fatalf("no full tree:\n%s", overview)
+	}
+	if got, want := strings.Join(treeLines(full), "\n"), strings.Join(treeLines(plain), "\n"); got != want {
+		t.Errorf("full tree:\n%s\nwant\n%s", got, want)
+	}
+	if fences := strings.Count(full, "```sh\n"); fences != strings.Count(full, "<a id=")+1 || fences != strings.Count(full, "```\n") {
+		t.Errorf("%d code blocks for the anchors, or one left open:\n%s", fences, full)
+	}
+}
+
+// The overview is a markdown layout and is refused with the other formats and with
+// --group-by
+func TestOverviewRefused(t *testing.T) {
+	dir := testtree.Dir(t, "a.txt\n")
+	for _, args := range [][]string{
+		{"-f", "json"}, {"-f", "text"}, {"--group-by", "ext"},
+	} {
+		_, stderr, code := runFTG(t, dir, append([]string{"-d", ".", "-o", "-", "--overview-depth", "1"}, args...)...)
+		if code != exitUsage || !strings.Contains(stderr, "--overview-depth needs -f md and cannot be combined with --group-by") {
+			t.Errorf("%q: exit code %d, %s", args, code, stderr)
+		}
+	}
+}
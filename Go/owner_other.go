@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileOwner reports that ownership is not available on this platform
+func fileOwner(fs.FileInfo) string {
+	return "unknown"
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// ownerNames caches uid lookups so each owner is resolved once per run
+var ownerNames = map[uint32]string{}
+
+// fileOwner returns the user name owning the file, or its numeric uid when unknown
+func fileOwner(info fs.FileInfo) string {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "unknown"
+	}
+	if name, ok := ownerNames[st.Uid]; ok {
+		return name
+	}
+	name := strconv.FormatUint(uint64(st.Uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	ownerNames[st.Uid] = name
+	return name
+}
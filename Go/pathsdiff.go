@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// formatUnified is the format of ftg diff between two -f paths snapshots
+const formatUnified = "unified"
+
+// snapshotEntry is one line of a -f paths snapshot
+type snapshotEntry struct {
+	path  string // Without the / of a directory
+	isDir bool
+}
+
+// line returns the entry as the snapshot writes it, without the line ending
+func (e snapshotEntry) line() string {
+	if e.isDir {
+		return e.path + "/"
+	}
+	return e.path
+}
+
+// snapshotEdit is one line of the diff of two snapshots
+type snapshotEdit struct {
+	op    byte          // ' ' kept, '-' removed, '+' added, '!' changed type or moved
+	entry snapshotEntry // The entry in the new snapshot, or in the old one when removed
+	from  string        // A move: the line of the entry in the old snapshot
+	below int           // A moved directory: the entries that moved with it
+}
+
+// readPathsSnapshot reads a -f paths snapshot: one path per line, directories ending
+// in /. Blank lines and the CR of CRLF line endings are skipped.
+func readPathsSnapshot(file string) ([]snapshotEntry, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var entries []snapshotEntry
+	seen := map[string]bool{}
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		entry := snapshotEntry{strings.TrimSuffix(line, "/"), strings.HasSuffix(line, "/")}
+		if entry.path == "" || seen[entry.path] {
+			return nil, fmt.Errorf("line %d: %q is not a path of its own", number+1, line)
+		}
+		seen[entry.path] = true
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// runPathsDiff prints the unified diff of two -f paths snapshots and exits with the
+// code for checks that found differences when they differ. Lines are matched by
+// path, so an entry whose type changed is one ! line rather than a removal and an
+// addition, and so is an entry that moved, see detectMoves.
+func runPathsDiff(oldFile, newFile string) {
+	before, err := readPathsSnapshot(oldFile)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read the snapshot %s: %v", oldFile, err))
+	}
+	after, err := readPathsSnapshot(newFile)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read the snapshot %s: %v", newFile, err))
+	}
+	edits := detectMoves(snapshotEdits(before, after))
+	lines := make([]diffLine, len(edits))
+	for i, edit := range edits {
+		lines[i] = diffLine{edit.op, []byte(edit.text() + "\n")}
+	}
+	out := unifiedHunks(oldFile, newFile, lines)
+	os.Stdout.Write(out)
+	if len(out) > 0 {
+		exitProcess(exitDifferences)
+	}
+	exitProcess(runExitCode(true))
+}
+
+// snapshotEdits matches the lines of two snapshots by path in the order given
+func snapshotEdits(before, after []snapshotEntry) []snapshotEdit {
+	keys := func(entries []snapshotEntry) [][]byte {
+		lines := make([][]byte, len(entries))
+		for i, entry := range entries {
+			lines[i] = []byte(entry.path + "\n")
+		}
+		return lines
+	}
+	var edits []snapshotEdit
+	i, j := 0, 0
+	for _, edit := range lineEdits(keys(before), keys(after)) {
+		switch edit.op {
+		case ' ':
+			op := byte(' ')
+			if before[i].isDir != after[j].isDir {
+				op = '!'
+			}
+			edits = append(edits, snapshotEdit{op: op, entry: after[j]})
+			i, j = i+1, j+1
+		case '-':
+			edits = append(edits, snapshotEdit{op: '-', entry: before[i]})
+			i++
+		default:
+			edits = append(edits, snapshotEdit{op: '+', entry: after[j]})
+			j++
+		}
+	}
+	return edits
+}
+
+// detectMoves collapses a removal and an addition of the same name and type into
+// one ! line at the new place, when no other entry removed or added has that name
+// and type. A directory moves with everything below it, which must be the same on
+// both sides, and its entries are then left out; directories are matched first,
+// outermost first, so a file is only matched outside moved directories.
+func detectMoves(edits []snapshotEdit) []snapshotEdit {
+	below := map[int][]int{} // Removed or added directories: the edits below them on the same side
+	dirs := map[string]int{} // The removed and added directories, by op and path
+	for k, edit := range edits {
+		if edit.op == '-' || edit.op == '+' {
+			if edit.entry.isDir {
+				dirs[string(edit.op)+edit.entry.path] = k
+			}
+		}
+	}
+	for k, edit := range edits {
+		if edit.op != '-' && edit.op != '+' {
+			continue
+		}
+		for parent := path.Dir(edit.entry.path); parent != "."; parent = path.Dir(parent) {
+			if d, ok := dirs[string(edit.op)+parent]; ok {
+				below[d] = append(below[d], k)
+			}
+		}
+	}
+	// subtree lists what is below a directory, from it and with types
+	subtree := func(d int) []string {
+		var names []string
+		for _, k := range below[d] {
+			names = append(names, strings.TrimPrefix(edits[k].entry.line(), edits[d].entry.path+"/"))
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	dropped := map[int]bool{}
+	for _, dirPass := range []bool{true, false} {
+		byName := map[string]*moveCandidates{}
+		var order []string
+		for k, edit := range edits {
+			if dropped[k] || edit.entry.isDir != dirPass || edit.op != '-' && edit.op != '+' {
+				continue
+			}
+			name := path.Base(edit.entry.path)
+			if byName[name] == nil {
+				byName[name] = &moveCandidates{depth: strings.Count(edit.entry.path, "/")}
+				order = append(order, name)
+			}
+			byName[name].depth = min(byName[name].depth, strings.Count(edit.entry.path, "/"))
+			if edit.op == '-' {
+				byName[name].removed = append(byName[name].removed, k)
+			} else {
+				byName[name].added = append(byName[name].added, k)
+			}
+		}
+		// Outermost first, so the entries of a moved directory are not matched on their own
+		slices.SortStableFunc(order, func(a, b string) int { return byName[a].depth - byName[b].depth })
+		for _, name := range order {
+			match := byName[name]
+			removed, added := unconsumed(match.removed, dropped), unconsumed(match.added, dropped)
+			if len(removed) != 1 || len(added) != 1 {
+				continue
+			}
+			from, to := removed[0], added[0]
+			if dirPass && !slices.Equal(subtree(from), subtree(to)) {
+				continue
+			}
+			dropped[from] = true
+			for _, k := range append(below[from], below[to]...) {
+				dropped[k] = true
+			}
+			edits[to].op, edits[to].from, edits[to].below = '!', edits[from].entry.line(), len(below[to])
+		}
+	}
+	kept := edits[:0]
+	for k, edit := range edits {
+		if !dropped[k] {
+			kept = append(kept, edit)
+		}
+	}
+	return kept
+}
+
+// moveCandidates are the removals and additions of one name and type
+type moveCandidates struct {
+	removed, added []int // Indexes of the edits
+	depth          int   // Of the shallowest, counted in slashes
+}
+
+// unconsumed returns the edits not yet taken by a move
+func unconsumed(edits []int, dropped map[int]bool) []int {
+	var left []int
+	for _, k := range edits {
+		if !dropped[k] {
+			left = append(left, k)
+		}
+	}
+	return left
+}
+
+// text returns the diff line of an edit, without its op: a ! line names what the
+// entry was or where it moved from
+func (e snapshotEdit) text() string {
+	switch {
+	case e.op != '!':
+		return e.entry.line()
+	case e.from == "" && e.entry.isDir:
+		return e.entry.line() + " (was a file)"
+	case e.from == "":
+		return e.entry.line() + " (was a directory)"
+	case e.below > 0:
+		return fmt.Sprintf("%s (moved from %s with %s)", e.entry.line(), e.from, treeCount(e.below, "entry", "entries"))
+	}
+	return fmt.Sprintf("%s (moved from %s)", e.entry.line(), e.from)
+}
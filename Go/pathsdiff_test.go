@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// writeSnapshots writes two -f paths snapshots to a temporary directory
+func writeSnapshots(t *testing.T, before, after string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range map[string]string{"old.txt": before, "new.txt": after} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// A type change, a moved file and a moved directory are one ! line each; a name
+// removed or added more than once stays a removal and an addition
+func TestPathsDiffUnified(t *testing.T) {
+	dir := writeSnapshots(t,
+		"README.md\nbuild/\nbuild/x\ndocs/\ndocs/guide.md\nnotes\nsrc/\nsrc/main.go\nsrc/util/\nsrc/util/a.go\nsrc/util/b.go\nx/\nx/a.txt\ny/\ny/a.txt\n",
+		"CHANGES\nREADME.md\nbuild\ndocs/\nguide.md\nlib/\nlib/util/\nlib/util/a.go\nlib/util/b.go\nnotes/\nsrc/\nsrc/main.go\nz/\nz/a.txt\n")
+	stdout, stderr, code := runFTG(t, dir, "diff", "-f", "unified", "old.txt", "new.txt")
+	if code != exitDifferences {
+		t.Fatalf("exit code %d, want %d: %s", code, exitDifferences, stderr)
+	}
+	testtree.Golden(t, "paths-diff.unified", []byte(stdout))
+}
+
+// A moved directory only collapses into one line when everything below it moved too
+func TestPathsDiffDirectoryMoveNeedsSameEntries(t *testing.T) {
+	dir := writeSnapshots(t, "util/\nutil/a.go\nutil/b.go\n", "lib/\nlib/util/\nlib/util/a.go\n")
+	stdout, _, _ := runFTG(t, dir, "diff", "-f", "unified", "old.txt", "new.txt")
+	if strings.Contains(stdout, "(moved from util/ ") || !strings.Contains(stdout, "\n-util/\n") ||
+		!strings.Contains(stdout, "\n!lib/util/a.go (moved from util/a.go)\n") {
+		t.Errorf("diff:\n%s", stdout)
+	}
+}
+
+// Identical snapshots print nothing and exit 0, whatever their line endings
+func TestPathsDiffIdentical(t *testing.T) {
+	dir := writeSnapshots(t, "a/\na/b.txt\n", "a/\r\na/b.txt\r\n\r\n")
+	if stdout, stderr, code := runFTG(t, dir, "diff", "-f", "unified", "old.txt", "new.txt"); code != exitOK || stdout != "" {
+		t.Errorf("exit code %d: %q %s", code, stdout, stderr)
+	}
+}
+
+// Two -f paths runs of trees that differ diff to what changed between them
+func TestPathsDiffRoundTrip(t *testing.T) {
+	dir := testtree.Dir(t, "old/src/a.go\nold/b.txt\nnew/lib/src/a.go\nnew/b.txt\n")
+	for _, side := range []string{"old", "new"} {
+		if _, stderr, code := runFTG(t, dir, "-d", side, "-f", "paths", "-o", side+".txt", "--quiet"); code != exitOK {
+			t.Fatalf("exit code %d: %s", code, stderr)
+		}
+	}
+	stdout, _, code := runFTG(t, dir, "diff", "-f", "unified", "old.txt", "new.txt")
+	want := "--- old.txt\n+++ new.txt\n@@ -1,2 +1,3 @@\n b.txt\n+lib/\n!lib/src/ (moved from src/ with 1 entry)\n"
+	if code != exitDifferences || stdout != want {
+		t.Errorf("exit code %d:\n%s\nwant\n%s", code, stdout, want)
+	}
+}
+
+func TestPathsDiffRefused(t *testing.T) {
+	dir := writeSnapshots(t, "a\n", "a\na\n")
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-d", ".", "-f", "unified"}, "-f unified is only available with ftg diff"},
+		{[]string{"diff", "-f", "unified", "old.txt", "new.txt"}, `Cannot read the snapshot new.txt: line 2: "a" is not a path of its own`},
+		{[]string{"diff", "-f", "unified", "old.txt", "missing.txt"}, "Cannot read the snapshot missing.txt"},
+		{[]string{"diff", "-f", "unified", "--emit-sync-script", "s.sh", "old.txt", "old.txt"}, "--emit-sync-script copies from the new directory"},
+	} {
+		if _, stderr, code := runFTG(t, dir, test.args...); code == exitOK || !strings.Contains(stderr, test.want) {
+			t.Errorf("%q: exit code %d, %s; want %q", test.args, code, stderr, test.want)
+		}
+	}
+}
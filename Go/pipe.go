@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var (
+	pipeCommand string             // --pipe: shell command the rendered output is run through before it is written
+	pipeTimeout = 60 * time.Second // --pipe-timeout: the command is killed after this long, 0 for no limit
+	pipeStderr  = 64 << 10         // Bytes of the command's stderr kept for the error message
+)
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write keeps what fits and reports everything as written, so the command never blocks on stderr
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// pipeShell returns the shell invocation that runs command on this platform
+func pipeShell(command string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", command}
+	}
+	return []string{"sh", "-c", command}
+}
+
+// writePiped runs the rendered output through --pipe and streams the command's stdout
+// to every destination as it arrives. Files are written to temporary files that only
+// replace their targets once the command has succeeded, so a failing or timed-out
+// command leaves them untouched; standard output receives the stream directly.
+// The clipboard and --post-url need the whole result and get it when the command ends.
+func writePiped(locations []string, data []byte) bool {
+	var sinks []io.Writer
+	var files []*atomicFile
+	needWhole := postURL != ""
+	for _, location := range locations {
+		switch location {
+		case stdoutTarget:
+			sinks = append(sinks, stdout)
+		case clipboardTarget:
+			needWhole = true
+		default:
+			if err := checkOverwrite(location); err != nil {
+				for _, f := range files {
+					f.abort()
+				}
+				warnf("Error: %v", err)
+				return false
+			}
+			f, err := createAtomic(location)
+			if err != nil {
+				for _, f := range files {
+					f.abort()
+				}
+				warnf("Error: cannot write to output location %s: %v", location, err)
+				return false
+			}
+			files = append(files, f)
+			sinks = append(sinks, f)
+		}
+	}
+	whole := &bytes.Buffer{}
+	if needWhole {
+		sinks = append(sinks, whole)
+	}
+
+	ctx := context.Background()
+	if pipeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pipeTimeout)
+		defer cancel()
+	}
+	args := pipeShell(pipeCommand)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = io.MultiWriter(sinks...)
+	stderr := &cappedBuffer{limit: pipeStderr}
+	cmd.Stderr = stderr
+	// Children of the shell may keep the pipes open after it is killed
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		for _, f := range files {
+			f.abort()
+		}
+		reason := err.Error()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = fmt.Sprintf("timed out after %s (--pipe-timeout)", pipeTimeout)
+		}
+		message := fmt.Sprintf("--pipe command %q failed: %s", pipeCommand, reason)
+		if text := strings.TrimSpace(stderr.String()); text != "" {
+			message += "\n" + text
+		}
+		exitWith(exitPipe, message)
+	}
+
+	ok := true
+	for _, location := range locations {
+		if location == stdoutTarget {
+			writtenOutputs = append(writtenOutputs, location)
+		}
+	}
+	for _, f := range files {
+		if err := f.commit(); err != nil {
+			warnf("Error: cannot write to output location %s: %v", f.location, err)
+			ok = false
+			continue
+		}
+		writtenOutputs = append(writtenOutputs, f.location)
+		fmt.Fprintln(messages, msg("status.written", f.location))
+	}
+	for _, location := range locations {
+		if location != clipboardTarget {
+			continue
+		}
+		if err := copyToClipboard(whole.Bytes()); err != nil {
+			warnf("Error: cannot copy to clipboard: %v", err)
+			ok = false
+			continue
+		}
+		writtenOutputs = append(writtenOutputs, location)
+		fmt.Fprintln(messages, msg("status.copied"))
+	}
+	if postURL != "" {
+		if err := putURL(postURL, whole.Bytes()); err != nil {
+			warnf("Error: %v", err)
+			ok = false
+		} else {
+			writtenOutputs = append(writtenOutputs, postURL)
+			fmt.Fprintln(messages, msg("status.uploaded", postURL))
+		}
+	}
+	return ok
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// pipeHelperArg starts the test binary as pipeHelper
+const pipeHelperArg = "ftg-test-pipe"
+
+// pipeHelper is the --pipe command of the tests: upper copies its input in upper case,
+// fail reports on stderr and exits 3, and hang never ends
+func pipeHelper(name string) {
+	switch name {
+	case "upper":
+		data, _ := io.ReadAll(os.Stdin)
+		os.Stdout.Write(bytes.ToUpper(data))
+	case "fail":
+		io.Copy(io.Discard, os.Stdin)
+		fmt.Fprintln(os.Stderr, "helper: bad input")
+		os.Exit(3)
+	case "hang":
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+// helperCommand is the --pipe command that runs the test binary as pipeHelper
+func helperCommand(name string) string {
+	return fmt.Sprintf(`"%s" %s %s`, os.Args[0], pipeHelperArg, name)
+}
+
+// The --pipe command's output is what lands in the file
+func TestPipeTransforms(t *testing.T) {
+	dir := t.TempDir()
+	src := testtree.Dir(t, "a/b.txt\nc.txt\n")
+	for _, args := range [][]string{{"-o", "plain.md"}, {"-o", "tree.md", "--pipe", helperCommand("upper")}} {
+		if _, stderr, code := runFTG(t, dir, append([]string{"-d", src}, args...)...); code != exitOK {
+			t.Fatalf("%q: exit code %d: %s", args, code, stderr)
+		}
+	}
+	plain, err := os.ReadFile(filepath.Join(dir, "plain.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "tree.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := bytes.ToUpper(plain); !bytes.Equal(got, want) {
+		t.Errorf("tree.md holds\n%s\nwant\n%s", got, want)
+	}
+}
+
+// A command that fails or times out exits with the pipe's code, shows its stderr and
+// leaves the output file as it was
+func TestPipeFails(t *testing.T) {
+	for _, test := range []struct {
+		helper string
+		args   []string
+		want   string
+	}{
+		{"fail", nil, "failed: exit status 3\nhelper: bad input"},
+		{"hang", []string{"--pipe-timeout", "200ms"}, "failed: timed out after 200ms (--pipe-timeout)"},
+	} {
+		dir := t.TempDir()
+		out := filepath.Join(dir, "tree.md")
+		if err := os.WriteFile(out, []byte("old\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		args := append([]string{"-d", testtree.Dir(t, "c.txt\n"), "-o", "tree.md", "--force", "--pipe", helperCommand(test.helper)}, test.args...)
+		_, stderr, code := runFTG(t, dir, args...)
+		if code != exitPipe || !strings.Contains(stderr, test.want) {
+			t.Errorf("%s: exit code %d, %s; want %d and %q", test.helper, code, stderr, exitPipe, test.want)
+		}
+		if got, _ := os.ReadFile(out); string(got) != "old\n" {
+			t.Errorf("%s: tree.md holds %q after the command failed", test.helper, got)
+		}
+	}
+}
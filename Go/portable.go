@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Values of --crlf
+const (
+	crlfAuto   = "auto"
+	crlfAlways = "always"
+	crlfNever  = "never"
+)
+
+var crlfMode = crlfAuto // --crlf: end lines with CRLF: auto (text and markdown on Windows), always or never
+
+// crlfValue is the --crlf flag; a bare --crlf means always
+type crlfValue struct{}
+
+// String returns the line ending mode chosen
+func (crlfValue) String() string {
+	return crlfMode
+}
+
+// Set chooses the line ending mode
+func (crlfValue) Set(value string) error {
+	switch strings.ToLower(value) {
+	case crlfAuto:
+		crlfMode = crlfAuto
+	case crlfAlways, "true":
+		crlfMode = crlfAlways
+	case crlfNever, "false":
+		crlfMode = crlfNever
+	default:
+		return fmt.Errorf("unknown --crlf value %q (use auto, always or never)", value)
+	}
+	return nil
+}
+
+// IsBoolFlag lets --crlf be given without a value
+func (crlfValue) IsBoolFlag() bool {
+	return true
+}
+
+// useCRLF decides whether the output of a format gets CRLF line endings: auto gives
+// them to the text and markdown formats on Windows, where Notepad and other editors
+// expect them, and leaves JSON, HTML, SVG and the tables as they are
+func useCRLF(format string) bool {
+	switch crlfMode {
+	case crlfAlways:
+		return true
+	case crlfNever:
+		return false
+	}
+	if runtime.GOOS != "windows" {
+		return false
+	}
+	switch format {
+	case formatMarkdown, formatMarkdownList, formatMermaid, formatText:
+		return true
+	}
+	return false
+}
+
+// toCRLF ends every line of data with CRLF; lines that already do are left as they are
+func toCRLF(data []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(data) + bytes.Count(data, []byte("\n")))
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if body, ok := bytes.CutSuffix(line, []byte("\n")); ok && !bytes.HasSuffix(body, []byte("\r")) {
+			out.Write(body)
+			out.WriteString("\r\n")
+			continue
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+// reservedNames are the device names Windows reserves in every directory, with or
+// without an extension, compared case-insensitively
+var reservedNames = map[string]bool{"CON": true, "PRN": true, "AUX": true, "NUL": true}
+
+func init() {
+	for _, digit := range "123456789¹²³" {
+		reservedNames["COM"+string(digit)] = true
+		reservedNames["LPT"+string(digit)] = true
+	}
+}
+
+// sanitizeFileName makes a name safe for a file on every platform, so an output
+// named after its directory can be copied anywhere: the characters Windows does not
+// allow become _, the trailing dots and spaces it would strip silently are dropped,
+// and a reserved device name such as CON or nul.txt gets a leading _
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if reservedName(name) {
+		name = "_" + name
+	}
+	return name
+}
+
+// reservedName reports whether Windows reserves a file name for a device
+func reservedName(name string) bool {
+	stem, _, _ := strings.Cut(name, ".")
+	return reservedNames[strings.ToUpper(strings.TrimRight(stem, " "))]
+}
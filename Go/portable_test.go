@@ -0,0 +1,115 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestToCRLF(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"a\nb\n", "a\r\nb\r\n"},
+		{"a\nb", "a\r\nb"},
+		{"a\r\nb\n", "a\r\nb\r\n"},
+		{"\n\n", "\r\n\r\n"},
+		{"lone\rcr\n", "lone\rcr\r\n"},
+		{"├── [F] ünïcödé\n", "├── [F] ünïcödé\r\n"},
+	}
+	for _, test := range tests {
+		if got := string(toCRLF([]byte(test.in))); got != test.want {
+			t.Errorf("toCRLF(%q) = %q, want %q", test.in, got, test.want)
+		}
+		if again := string(toCRLF([]byte(test.want))); again != test.want {
+			t.Errorf("toCRLF is not idempotent on %q: %q", test.want, again)
+		}
+	}
+}
+
+func TestUseCRLF(t *testing.T) {
+	onWindows := runtime.GOOS == "windows"
+	tests := []struct {
+		mode, format string
+		want         bool
+	}{
+		{crlfAlways, formatJSON, true},
+		{crlfNever, formatMarkdown, false},
+		{crlfAuto, formatMarkdown, onWindows},
+		{crlfAuto, formatText, onWindows},
+		{crlfAuto, formatMermaid, onWindows},
+		{crlfAuto, formatJSON, false},
+		{crlfAuto, formatHTML, false},
+		{crlfAuto, formatCSV, false},
+	}
+	for _, test := range tests {
+		setOption(t, &crlfMode, test.mode)
+		if got := useCRLF(test.format); got != test.want {
+			t.Errorf("--crlf %s -f %s: useCRLF = %v, want %v", test.mode, test.format, got, test.want)
+		}
+	}
+}
+
+func TestCRLFFlag(t *testing.T) {
+	for value, want := range map[string]string{"auto": crlfAuto, "ALWAYS": crlfAlways, "true": crlfAlways, "never": crlfNever, "false": crlfNever} {
+		setOption(t, &crlfMode, "")
+		if err := (crlfValue{}).Set(value); err != nil || crlfMode != want {
+			t.Errorf("--crlf=%s: mode %q, err %v, want %q", value, crlfMode, err, want)
+		}
+	}
+	if err := (crlfValue{}).Set("sometimes"); err == nil {
+		t.Error("--crlf=sometimes was accepted")
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"project", "project"},
+		{"a:b*c?d", "a_b_c_d"},
+		{`back\slash/and|pipe`, "back_slash_and_pipe"},
+		{`<quoted">`, "_quoted__"},
+		{"tab\there", "tab_here"},
+		{"trailing. . ", "trailing"},
+		{"CON", "_CON"},
+		{"nul.txt", "_nul.txt"},
+		{"Com1.tree.md", "_Com1.tree.md"},
+		{"lpt²", "_lpt²"},
+		{"aux .md", "_aux .md"},
+		{"console", "console"},
+		{"COM0", "COM0"},
+		{"ünïcödé 🐹", "ünïcödé 🐹"},
+	}
+	for _, test := range tests {
+		if got := sanitizeFileName(test.in); got != test.want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+// The default output name is safe wherever the input directory's name is not
+func TestDefaultOutputPathIsPortable(t *testing.T) {
+	setOption(t, &outputTemplate, "file_tree_{dir}.{ext}")
+	for dir, want := range map[string]string{
+		"con":          "file_tree__con.md",
+		"my:project":   "file_tree_my_project.md",
+		"dots...":      "file_tree_dots.md",
+		"_":            "file_tree_root.md",
+		"plain-dir_01": "file_tree_plain-dir_01.md",
+	} {
+		setOption(t, &scan.Root, dir)
+		if got := defaultOutputPath("md"); got != want {
+			t.Errorf("input %q: %q, want %q", dir, got, want)
+		}
+	}
+}
+
+// --crlf ends every line of the tree with CRLF, the fingerprint line included
+func TestCRLFOutput(t *testing.T) {
+	dir := t.TempDir()
+	stdout, stderr, code := runFTG(t, dir, "-o", "-", "--crlf", "-f", "text")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if lone := strings.Count(stdout, "\n") - strings.Count(stdout, "\r\n"); lone != 0 || stdout == "" {
+		t.Errorf("%d lines end in a bare LF:\n%q", lone, stdout)
+	}
+}
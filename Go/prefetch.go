@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+var walkJobs = runtime.GOMAXPROCS(0) // --jobs: directories read concurrently before the tree is rendered; 1 reads them one at a time during the walk
+
+// listingResult is a directory read by a prefetch worker
+type listingResult struct {
+	dir     string
+	entries []fs.DirEntry
+	err     error
+	took    time.Duration
+}
+
+// prefetchListings reads every directory the walk will descend into with walkJobs
+// concurrent readers and leaves the listings in listingCache, the way the sampling
+// pass does, so the walk that follows renders from memory, serially and in order.
+// Workers only read; exclusions, depth and ordering are decided here on one
+// goroutine, since they use package state. Directories that cannot be read are not
+// cached, so the walk reads them again and reports the error; followed links are
+// also left to the walk, which checks them for cycles, and so are directories past
+// --stream-threshold, which it streams. With --jobs auto there are
+// --jobs-max workers, and a jobsController fed with every read's latency decides
+// how many of them may read at once. Once ctx is cancelled no more reads are handed
+// out, and it returns when those in flight are back.
+func prefetchListings(ctx context.Context, root string, rootEntries []fs.DirEntry) {
+	if !jobsAuto && walkJobs <= 1 {
+		return
+	}
+	workers, limit := walkJobs, walkJobs
+	var controller *jobsController
+	if jobsAuto {
+		controller = newJobsController(jobsMin, jobsMax)
+		workers, limit = jobsMax, controller.limit
+	}
+	jobs := make(chan string)
+	results := make(chan listingResult)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for dir := range jobs {
+				started := time.Now()
+				entries, err := readListingCapped(dir)
+				results <- listingResult{dir, entries, err, time.Since(started)}
+			}
+		}()
+	}
+
+	var pending []string // Directories waiting for a worker, read depth first to bound the queue
+	var expand func(dir string, entries []fs.DirEntry)
+	expand = func(dir string, entries []fs.DirEntry) {
+		for _, entry := range visibleEntries(dir, entries) {
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			fullPath := filepath.Join(dir, entry.Name())
+			if !entry.IsDir() || !shouldDescend(fullPath, entry) {
+				continue
+			}
+			if resumed != nil && resumed.takes(relativePath(dir, entry.Name())) {
+				// Taken from the spill unread
+				continue
+			}
+			if cached, ok := listingCache[fullPath]; ok {
+				// Already read by --auto-depth
+				expand(fullPath, cached)
+				continue
+			}
+			pending = append(pending, fullPath)
+		}
+	}
+	expand(root, rootEntries)
+
+	inFlight := 0
+	for {
+		if ctx.Err() != nil {
+			pending = nil
+		}
+		if len(pending) == 0 && inFlight == 0 {
+			break
+		}
+		var send chan string
+		var next string
+		if len(pending) > 0 && inFlight < limit {
+			send, next = jobs, pending[len(pending)-1]
+		}
+		select {
+		case send <- next:
+			pending = pending[:len(pending)-1]
+			inFlight++
+		case result := <-results:
+			inFlight--
+			resources.readDirs++
+			if controller != nil {
+				previous := limit
+				var reason string
+				if limit, reason = controller.observe(result.took, strainError(result.err)); reason != "" && reportResources {
+					fmt.Fprintf(messages, "--jobs auto: %d -> %d concurrent reads (%s)\n", previous, limit, reason)
+				}
+			}
+			if result.err != nil {
+				continue
+			}
+			entries := byteOrder(result.entries)
+			listingCache[result.dir] = entries
+			expand(result.dir, entries)
+		}
+	}
+	close(jobs)
+	if controller != nil {
+		resources.jobs = &jobsReport{controller.limit, controller.peak, controller.adjustments}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// exclusionProfile is a named set of exclusions for the generated and vendored
+// content of one kind of project, selected with --profile. A profile with a marker
+// is suggested by "ftg init" when that file is in the directory.
+type exclusionProfile struct {
+	name     string
+	marker   string
+	patterns []string
+	custom   bool // Defined with --define-profile, on the command line or in a config file
+}
+
+// builtinProfiles are the profiles every run knows; --list-profiles prints them from here
+var builtinProfiles = []exclusionProfile{
+	{name: "node", marker: "package.json", patterns: []string{"node_modules", "dist", "build", "coverage", ".next"}},
+	{name: "python", marker: "pyproject.toml", patterns: []string{"__pycache__", ".venv", "venv", ".pytest_cache", ".mypy_cache", "*.egg-info", "*.pyc"}},
+	{name: "go", marker: "go.mod", patterns: []string{"vendor", "bin"}},
+	{name: "rust", marker: "Cargo.toml", patterns: []string{"target"}},
+	{name: "java", marker: "pom.xml", patterns: []string{"target", "build", ".gradle"}},
+	{name: "jetbrains", marker: ".idea", patterns: []string{".idea", "*.iml", "out"}},
+	{name: "vscode", marker: ".vscode", patterns: []string{".vscode", "*.code-workspace"}},
+	{name: "macos", patterns: []string{".DS_Store", "._*", ".AppleDouble"}},
+}
+
+var (
+	profileNames stringList // --profile: profiles to apply, comma-separated and repeatable
+	profileDefs  stringList // --define-profile: name=pattern,pattern definitions, repeatable
+	listProfiles bool       // --list-profiles: print the profiles and exit
+)
+
+// profileName is the form of a profile name, so one can never be taken for a pattern
+var profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// knownProfiles returns the built-in profiles and those of --define-profile, both
+// sorted by name. A definition with the name of a built-in profile replaces it, and
+// a later definition replaces an earlier one.
+func knownProfiles() ([]exclusionProfile, error) {
+	byName := map[string]exclusionProfile{}
+	for _, profile := range builtinProfiles {
+		byName[profile.name] = profile
+	}
+	for _, def := range profileDefs {
+		name, list, ok := strings.Cut(def, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !profileName.MatchString(name) {
+			return nil, fmt.Errorf("--define-profile %q is not name=pattern,pattern with a lowercase name", def)
+		}
+		profile := exclusionProfile{name: name, custom: true}
+		for _, pattern := range strings.Split(list, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				profile.patterns = append(profile.patterns, pattern)
+			}
+		}
+		if len(profile.patterns) == 0 {
+			return nil, fmt.Errorf("--define-profile %s has no patterns", name)
+		}
+		byName[name] = profile
+	}
+	profiles := make([]exclusionProfile, 0, len(byName))
+	for _, profile := range byName {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].name < profiles[j].name })
+	return profiles, nil
+}
+
+// applyProfiles adds the patterns of every --profile to the presets source, in the
+// order given, so -e and "!pattern" still override them under the default
+// --rules-order. An --include pattern a profile excludes by that very name is
+// re-included after them, as it is for --hidden=hide.
+func applyProfiles() error {
+	if len(profileNames) == 0 {
+		return nil
+	}
+	profiles, err := knownProfiles()
+	if err != nil {
+		return err
+	}
+	byName := map[string]exclusionProfile{}
+	names := make([]string, len(profiles))
+	for i, profile := range profiles {
+		byName[profile.name] = profile
+		names[i] = profile.name
+	}
+	var applied []string
+	for _, list := range profileNames {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			profile, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("unknown --profile %q (use %s, or see --list-profiles)", name, strings.Join(names, ", "))
+			}
+			appliedProfiles = append(appliedProfiles, name)
+			for _, pattern := range profile.patterns {
+				addExcludeRule(sourcePresets, "profile "+name, pattern)
+				applied = append(applied, pattern)
+			}
+		}
+	}
+	for _, keep := range includePatterns {
+		for _, pattern := range applied {
+			if ok, _ := path.Match(pattern, keep); ok || pattern == keep {
+				addExcludeRule(sourcePresets, "user (--include)", "!"+keep)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// showProfiles prints every profile and its patterns, marking those defined with
+// --define-profile, and exits
+func showProfiles() {
+	profiles, err := knownProfiles()
+	if err != nil {
+		usageExit(err.Error())
+	}
+	fmt.Println("Exclusion profiles (--profile name,name):")
+	for _, profile := range profiles {
+		note := ""
+		if profile.custom {
+			note = "  (--define-profile)"
+		}
+		fmt.Printf("  %-10s %s%s\n", profile.name, strings.Join(profile.patterns, ", "), note)
+	}
+	exitProcess(exitOK)
+}
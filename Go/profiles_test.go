@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// profileTree has the generated content of several kinds of project besides sources
+const profileTree = `
+__pycache__/p.pyc
+bin/b
+coverage/c
+dist/d
+out/o
+src/a.go
+src/x.tmp
+vendor/v
+`
+
+// Profiles merge whether given in one --profile or several; -e "!pattern" and --include
+// keep what a profile leaves out; a defined profile adds to the built-in ones or
+// replaces one, from the command line or a config file; an unknown name is refused
+func TestProfileMerge(t *testing.T) {
+	src := testtree.Dir(t, profileTree)
+	merged := "├── [D] __pycache__\n" +
+		"│   └── [F] p.pyc\n" +
+		"├── [D] out\n" +
+		"│   └── [F] o\n" +
+		"└── [D] src\n" +
+		"    ├── [F] a.go\n" +
+		"    └── [F] x.tmp\n"
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--profile", "node,go"}, merged},
+		{[]string{"--profile", "node", "--profile", "GO"}, merged},
+		{[]string{"--profile", "node,go", "-e", "!dist", "--include", "vendor"}, "├── [D] __pycache__\n" +
+			"│   └── [F] p.pyc\n" +
+			"├── [D] dist\n" +
+			"│   └── [F] d\n" +
+			"├── [D] out\n" +
+			"│   └── [F] o\n" +
+			"├── [D] src\n" +
+			"│   ├── [F] a.go\n" +
+			"│   └── [F] x.tmp\n" +
+			"└── [D] vendor\n" +
+			"    └── [F] v\n"},
+		{[]string{"--profile", "custom,go,python", "--define-profile", "custom=*.tmp,out", "--define-profile", "go=bin"}, "├── [D] coverage\n" +
+			"│   └── [F] c\n" +
+			"├── [D] dist\n" +
+			"│   └── [F] d\n" +
+			"├── [D] src\n" +
+			"│   └── [F] a.go\n" +
+			"└── [D] vendor\n" +
+			"    └── [F] v\n"},
+	} {
+		stdout, stderr, code := runFTG(t, t.TempDir(), append(test.args, "-d", src, "-o", "-", "--no-summary")...)
+		if code != exitOK || stdout != test.want {
+			t.Errorf("%q: exit code %d, %s\ngot\n%s\nwant\n%s", test.args, code, stderr, stdout, test.want)
+		}
+	}
+
+	dir := testtree.Dir(t, `out/o
+a.tmp
+b.go
+ftg.toml content="profile = [\"custom\"]\ndefine-profile = [\"custom=*.tmp,out\"]\n"
+`)
+	if stdout, stderr, code := runFTG(t, dir, "-d", ".", "--config", "ftg.toml", "-o", "-", "--no-summary"); code != exitOK || stdout != "├── [F] b.go\n└── [F] ftg.toml\n" {
+		t.Errorf("config file: exit code %d, %s\n%s", code, stderr, stdout)
+	}
+
+	if _, stderr, code := runFTG(t, t.TempDir(), "-d", src, "--profile", "node,nope", "-o", "-"); code != exitUsage || !strings.Contains(stderr, `unknown --profile "nope"`) {
+		t.Errorf("unknown profile: exit code %d, %s", code, stderr)
+	}
+}
+
+// --list-profiles prints every profile with its patterns from the data the runs use,
+// and the defined ones marked, one line each
+func TestListProfilesInSync(t *testing.T) {
+	stdout, stderr, code := runFTG(t, t.TempDir(), "--list-profiles", "--define-profile", "custom=*.tmp,out")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	setOption(t, &profileDefs, stringList{"custom=*.tmp,out"})
+	profiles, err := knownProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")[1:]
+	if len(lines) != len(builtinProfiles)+1 || len(lines) != len(profiles) {
+		t.Fatalf("%d profiles listed, want %d:\n%s", len(lines), len(profiles), stdout)
+	}
+	for i, profile := range profiles {
+		fields := strings.Fields(strings.ReplaceAll(lines[i], ",", ""))
+		want := append([]string{profile.name}, profile.patterns...)
+		if profile.custom {
+			want = append(want, "(--define-profile)")
+		}
+		if strings.Join(fields, " ") != strings.Join(want, " ") {
+			t.Errorf("line %q, want the profile %q", lines[i], want)
+		}
+	}
+}
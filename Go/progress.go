@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Progress events written to stderr with --progress-json, one JSON object per line:
+//
+//	{"event":"dir","path":"src","entries":42,"done":1380,"elapsedMs":912}
+//	{"event":"summary","dirs":120,"done":1380,"warnings":0,"remaining":"~3m20s remaining (62%)","remainingMs":200000,"percent":62,"elapsedMs":1000}
+//	{"event":"warning","message":"Cannot read directory ...","elapsedMs":1012}
+//	{"event":"error","message":"Cannot read the input directory","elapsedMs":3}
+//	{"event":"complete","dirs":412,"done":5120,"warnings":1,"elapsedMs":2210}
+//
+// "path" is relative to the input directory with forward slashes, "entries" is
+// the size of that directory's listing, "dirs" and "done" are the running
+// totals of directories read and entries listed. "dir" events are limited to
+// ten per second, "summary" events are sent once per second, and exactly one
+// "complete" or "error" event ends the stream. Field names are stable.
+//
+// "remaining", "remainingMs" and "percent" estimate the rest of the walk from the
+// directories found but not yet read and the recent time per directory; they are
+// left out until two directories were read. While the walk keeps finding more
+// directories than it reads the time is a lower bound, "percent" an upper one, and
+// "remaining" says so.
+type progressEvent struct {
+	Event       string `json:"event"`
+	Path        string `json:"path,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Entries     *int   `json:"entries,omitempty"`
+	Dirs        *int   `json:"dirs,omitempty"`
+	Done        int    `json:"done"`
+	Warnings    *int   `json:"warnings,omitempty"`
+	Remaining   string `json:"remaining,omitempty"`
+	RemainingMs *int64 `json:"remainingMs,omitempty"`
+	Percent     *int   `json:"percent,omitempty"`
+	ElapsedMs   int64  `json:"elapsedMs"`
+}
+
+const (
+	progressDirInterval     = 100 * time.Millisecond
+	progressSummaryInterval = time.Second
+)
+
+// progress tracks the walk for --progress-json
+var progress struct {
+	enabled     bool
+	started     time.Time
+	lastDir     time.Time
+	lastSummary time.Time
+	dirs        int
+	done        int
+	warnings    int
+	muted       bool // Warnings are dropped while --verify-renderers renders the other formats
+	line        bool // The --progress status line is drawn on stderr
+	lastLine    time.Time
+	lineWidth   int // Columns of the status line on screen, 0 when there is none
+	written     int // Entries the renderers emitted
+}
+
+// Values of --progress
+const (
+	progressNever  = "never"
+	progressAuto   = "auto"
+	progressAlways = "always"
+)
+
+var (
+	progressMode = progressAuto             // --progress: the status line on stderr: never, auto (when stderr is a terminal) or always
+	quiet        bool                       // --quiet: no status line, banner or status messages
+	progressSink io.Writer      = os.Stderr // Where the status line is drawn
+)
+
+// startProgress enables progress events and starts the elapsed-time clock
+func startProgress() {
+	progress.enabled = true
+	progress.started = time.Now()
+	progress.lastSummary = progress.started
+}
+
+// emitProgress writes one event line to stderr
+func emitProgress(event progressEvent) {
+	event.Done = progress.done
+	event.ElapsedMs = time.Since(progress.started).Milliseconds()
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", line)
+}
+
+// progressCounts returns the running totals attached to summary-style events
+func progressCounts(name string) progressEvent {
+	dirs, warnings := progress.dirs, progress.warnings
+	return progressEvent{Event: name, Dirs: &dirs, Warnings: &warnings}
+}
+
+// progressDir records a directory listing and emits rate-limited dir and summary events
+func progressDir(dir string, entries int) {
+	progress.dirs++
+	progress.done += entries
+	if progress.line {
+		drawProgressLine(dir)
+	}
+	if !progress.enabled {
+		return
+	}
+	now := time.Now()
+	if now.Sub(progress.lastDir) >= progressDirInterval {
+		progress.lastDir = now
+		rel, err := filepath.Rel(scan.Root, dir)
+		if err != nil {
+			rel = dir
+		}
+		emitProgress(progressEvent{Event: "dir", Path: filepath.ToSlash(rel), Entries: &entries})
+	}
+	if now.Sub(progress.lastSummary) >= progressSummaryInterval {
+		progress.lastSummary = now
+		event := progressCounts("summary")
+		if est, ok := walkETA.estimate(); ok {
+			remainingMs, percent := est.remaining.Milliseconds(), int(math.Round(est.percent))
+			event.Remaining, event.RemainingMs, event.Percent = est.describe(), &remainingMs, &percent
+		}
+		emitProgress(event)
+	}
+}
+
+// finishProgress emits the final complete event
+func finishProgress() {
+	if progress.enabled {
+		emitProgress(progressCounts("complete"))
+	}
+}
+
+// warnf reports a non-fatal problem, as a warning event when progress events are enabled
+func warnf(format string, args ...any) {
+	if progress.muted {
+		return
+	}
+	progress.warnings++
+	if progress.enabled {
+		emitProgress(progressEvent{Event: "warning", Message: fmt.Sprintf(format, args...)})
+		return
+	}
+	clearProgressLine()
+	log.Printf(format, args...)
+}
+
+// wantProgressLine decides whether --progress draws its status line. auto needs
+// stderr to be a terminal, so a redirected stderr never collects the redraws;
+// --quiet and --progress-json turn the line off.
+func wantProgressLine(progressJSON bool) bool {
+	switch {
+	case quiet || progressJSON || progressMode == progressNever:
+		return false
+	case progressMode == progressAlways:
+		return true
+	}
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&fs.ModeCharDevice != 0
+}
+
+// progressEntry counts an entry a renderer emitted for the status line
+func progressEntry(dir string) {
+	progress.written++
+	if progress.line {
+		drawProgressLine(dir)
+	}
+}
+
+// drawProgressLine redraws the status line on stderr at most ten times a second:
+// directories read, entries written and the directory being read, cut to the
+// terminal width. The line is rewritten in place with a carriage return and padded
+// over the previous one, so no escape codes are needed.
+func drawProgressLine(dir string) {
+	now := time.Now()
+	if now.Sub(progress.lastLine) < progressDirInterval {
+		return
+	}
+	progress.lastLine = now
+	rel, err := filepath.Rel(scan.Root, dir)
+	if err != nil {
+		rel = dir
+	}
+	line := fmt.Sprintf("%s read, %s written: %s", treeCount(progress.dirs, "directory", "directories"), treeCount(progress.written, "entry", "entries"), redactPath(displayPath(filepath.ToSlash(rel))))
+	width := terminalWidth(os.Stderr)
+	if width <= 0 {
+		width = 80
+	}
+	line = truncateWidth(line, width-1)
+	shown := displayWidth(line)
+	fmt.Fprintf(progressSink, "\r%s%s", line, strings.Repeat(" ", max(0, progress.lineWidth-shown)))
+	progress.lineWidth = shown
+}
+
+// clearProgressLine blanks the status line, so a message or the tree starts on a clean line
+func clearProgressLine() {
+	if progress.lineWidth > 0 {
+		fmt.Fprintf(progressSink, "\r%s\r", strings.Repeat(" ", progress.lineWidth))
+		progress.lineWidth = 0
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// progressEvents parses every line --progress-json wrote to stderr as one event with
+// only the documented fields, failing the test on any other line
+func progressEvents(t *testing.T, stderr string) []progressEvent {
+	t.Helper()
+	var events []progressEvent
+	for _, line := range strings.Split(strings.TrimSuffix(stderr, "\n"), "\n") {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(line)))
+		decoder.DisallowUnknownFields()
+		var event progressEvent
+		if err := decoder.Decode(&event); err != nil || decoder.More() {
+			t.Fatalf("stderr line %q is not a progress event: %v", line, err)
+		}
+		switch event.Event {
+		case "dir", "summary", "warning", "complete", "error":
+		default:
+			t.Errorf("unknown event %q in %q", event.Event, line)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// With --progress-json stderr holds nothing but events: warnings turn into warning
+// events, the count of entries done only grows and complete comes last
+func TestProgressJSONEvents(t *testing.T) {
+	dir := testtree.Dir(t, "src/a/one.txt\nsrc/a/two.txt\nsrc/b.txt\n")
+	_, stderr, code := runFTG(t, dir, "-d", "src", "--progress-json", "-f", "json", "-o", "tree.md")
+	if code != exitWarnings {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	events := progressEvents(t, stderr)
+	seen := map[string]int{}
+	done := 0
+	for _, event := range events {
+		seen[event.Event]++
+		if event.Done < done {
+			t.Errorf("done went back from %d to %d", done, event.Done)
+		}
+		done = event.Done
+	}
+	if seen["warning"] != 1 || seen["dir"] == 0 || seen["complete"] != 1 {
+		t.Errorf("events %v, want a warning, dir events and one complete", seen)
+	}
+	last := events[len(events)-1]
+	if last.Event != "complete" || last.Dirs == nil || *last.Dirs != 2 || last.Done != 4 || last.Warnings == nil || *last.Warnings != 1 {
+		t.Errorf("last event %+v, want complete with 2 dirs, 4 entries and 1 warning", last)
+	}
+}
+
+// A fatal error is an error event too, and with the tree on stdout the status
+// messages are left out rather than mixed in with the events
+func TestProgressJSONError(t *testing.T) {
+	_, stderr, code := runFTG(t, t.TempDir(), "-d", "missing", "--progress-json", "-o", "-")
+	events := progressEvents(t, stderr)
+	if code != exitFatal || len(events) != 1 || events[0].Event != "error" || events[0].Message == "" {
+		t.Errorf("exit code %d, events %+v", code, events)
+	}
+}
+
+// useProgressSink draws the status line into sink, with the counters from zero, for
+// the rest of the test
+func useProgressSink(t *testing.T, sink io.Writer) {
+	setOption(t, &progressSink, sink)
+	setOption(t, &progress, progress)
+	progress.dirs, progress.done, progress.written, progress.lineWidth, progress.lastLine = 0, 0, 0, 0, time.Time{}
+}
+
+// The status line counts directories read and entries written, names the directory
+// being read, redraws at most ten times a second and pads over a longer line before it
+func TestProgressLine(t *testing.T) {
+	var sink bytes.Buffer
+	useProgressSink(t, &sink)
+	setOption(t, &scan.Root, "root")
+	progress.line = true
+
+	progressDir(filepath.Join("root", "src", "components"), 3)
+	first := "1 directory read, 0 entries written: src/components"
+	if want := "\r" + first; sink.String() != want {
+		t.Errorf("first line %q, want %q", sink.String(), want)
+	}
+	sink.Reset()
+	progressEntry(filepath.Join("root", "src"))
+	progressDir(filepath.Join("root", "src", "b"), 2)
+	if sink.Len() != 0 || progress.dirs != 2 || progress.done != 5 || progress.written != 1 {
+		t.Errorf("drew %q within a tenth of a second; %d dirs, %d done, %d written", sink.String(), progress.dirs, progress.done, progress.written)
+	}
+	progress.lastLine = time.Time{}
+	progressEntry(filepath.Join("root", "src"))
+	second := "2 directories read, 2 entries written: src"
+	if want := "\r" + second + strings.Repeat(" ", len(first)-len(second)); sink.String() != want {
+		t.Errorf("redrawn line %q, want %q", sink.String(), want)
+	}
+	sink.Reset()
+	clearProgressLine()
+	clearProgressLine()
+	if want := "\r" + strings.Repeat(" ", len(second)) + "\r"; sink.String() != want || progress.lineWidth != 0 {
+		t.Errorf("cleared with %q, want %q once", sink.String(), want)
+	}
+
+	setOption(t, &progressMode, progressAlways)
+	for _, test := range []struct {
+		quiet, json bool
+		want        bool
+	}{{false, false, true}, {true, false, false}, {false, true, false}} {
+		setOption(t, &quiet, test.quiet)
+		if got := wantProgressLine(test.json); got != test.want {
+			t.Errorf("--progress=always, quiet %v, --progress-json %v: %v", test.quiet, test.json, got)
+		}
+	}
+	setOption(t, &quiet, false)
+	setOption(t, &progressMode, progressNever)
+	if wantProgressLine(false) {
+		t.Error("--progress=never draws the line")
+	}
+}
+
+// A render counts every directory listed and every entry written, whatever it draws
+func TestProgressLineCounts(t *testing.T) {
+	var sink bytes.Buffer
+	useProgressSink(t, &sink)
+	got := renderFixture(t, testtree.MapFS(t, "src/a/one.txt\nsrc/a/two.txt\nsrc/b.txt\ntop.txt\n"), true, func() {
+		progress.line = true
+		setOption(t, &noSummary, true)
+	})
+	if progress.dirs != 3 || progress.written != strings.Count(got, "\n") || !strings.HasPrefix(sink.String(), "\r") {
+		t.Errorf("%d dirs, %d written, drew %q for\n%s", progress.dirs, progress.written, sink.String(), got)
+	}
+}
+
+// The status line goes to stderr only, so a tree on stdout is the same with it or
+// without; --quiet drops it along with the banner and the status messages
+func TestProgressFlags(t *testing.T) {
+	dir := testtree.Dir(t, "src/a/one.txt\nsrc/b.txt\n")
+	plain, _, _ := runFTG(t, dir, "-d", "src", "-o", "-", "--progress=never")
+	stdout, stderr, code := runFTG(t, dir, "-d", "src", "-o", "-", "--progress=always")
+	if code != exitOK || stdout != plain || !strings.Contains(stderr, "\r1 directory read, 0 entries written: .") {
+		t.Errorf("exit code %d, stdout\n%s\nstderr %q", code, stdout, stderr)
+	}
+	stdout, stderr, code = runFTG(t, dir, "-d", "src", "-o", "tree.md", "--progress=always", "-q")
+	if code != exitOK || stdout != "" || stderr != "" {
+		t.Errorf("-q: exit code %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+	for _, test := range []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"--progress=sometimes"}, `unknown --progress value "sometimes" (use never, auto or always)`},
+		{[]string{"--progress=always", "--progress-json"}, "--progress=always cannot be combined with --progress-json"},
+	} {
+		if _, stderr, code := runFTG(t, dir, append([]string{"-o", "-"}, test.args...)...); code != exitUsage || !strings.Contains(stderr, test.message) {
+			t.Errorf("%q: exit code %d, %s", test.args, code, stderr)
+		}
+	}
+}
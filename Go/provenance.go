@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// scanCounters records what the walk skipped or could not read
+type scanCounters struct {
+	excludedBy map[string]int // Entries hidden per exclusion pattern
+	readable   int            // Directories listed successfully
+	unreadable int            // Directories that could not be listed
+	vanished   int            // Directories that disappeared between listing and reading
+	special    int            // Devices, sockets, pipes and other irregular entries
+	dirs       int            // Directories shown in the tree
+	files      int            // Other entries shown in the tree
+	bytes      int64          // Size of the regular files shown, for the summary
+}
+
+var (
+	counters       = scanCounters{excludedBy: map[string]int{}}
+	excludeSources = map[string]string{} // Where each exclusion pattern came from
+	provenanceFlag bool                  // --provenance: append a record of what the scan covered
+)
+
+// countEntry updates the counters for a rendered entry
+func countEntry(dir string, entry fs.DirEntry) {
+	recordRendered(dir, entry.Name(), entry.Type().IsRegular() && !isDeleted(entry))
+	progressEntry(dir)
+	if groupShown != nil {
+		rel := relativePath(dir, entry.Name())
+		if groupShown[rel] {
+			return
+		}
+		groupShown[rel] = true
+	}
+	if entry.Type()&(fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe|fs.ModeSocket|fs.ModeIrregular) != 0 {
+		counters.special++
+	}
+	if entry.IsDir() {
+		counters.dirs++
+		return
+	}
+	counters.files++
+	if isDeleted(entry) {
+		// A tracked file --git-status shows as deleted has nothing on disk to read
+		return
+	}
+	// Read even without the summary: a file that vanished since the listing, or
+	// whose lstat fails, is warned about once here in every format
+	info, err := entryInfo(entry)
+	if err != nil {
+		warnf("%s", entryInfoError(filepath.Join(dir, entry.Name()), err))
+		return
+	}
+	if !noSummary && entry.Type().IsRegular() {
+		counters.bytes += totalSize(filepath.Join(dir, entry.Name()), info)
+	}
+}
+
+// excludedTotal returns the number of entries hidden by exclusion rules
+func excludedTotal() int {
+	total := 0
+	for _, hidden := range counters.excludedBy {
+		total += hidden
+	}
+	return total
+}
+
+// countReadError classifies a failed directory read; a directory replaced by a file counts as vanished
+func countReadError(err error) {
+	if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) || errors.Is(err, ftree.ErrChangedType) {
+		counters.vanished++
+	} else {
+		counters.unreadable++
+	}
+}
+
+// effectiveUser returns the user the scan runs as
+func effectiveUser() string {
+	if uid := os.Geteuid(); uid >= 0 {
+		id := strconv.Itoa(uid)
+		if u, err := user.LookupId(id); err == nil {
+			return fmt.Sprintf("%s (uid %s)", redactText(u.Username), id)
+		}
+		return "uid " + id
+	}
+	if u, err := user.Current(); err == nil {
+		return redactText(u.Username)
+	}
+	return "unknown"
+}
+
+// provenanceRecord is what --provenance records about a scan
+type provenanceRecord struct {
+	Root       string           `json:"root"`
+	Filesystem string           `json:"filesystem"`
+	User       string           `json:"user"`
+	Started    string           `json:"started"`
+	Finished   string           `json:"finished"`
+	Unreadable int              `json:"unreadable"`
+	Vanished   int              `json:"vanished"`
+	Special    int              `json:"special"`
+	Virtual    []string         `json:"virtual,omitempty"` // Only a scan of / skips virtual filesystems
+	Rules      []provenanceRule `json:"rules"`
+}
+
+// provenanceRule is one exclusion pattern, where it came from and how many entries it hid
+type provenanceRule struct {
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"`
+	Hidden  int    `json:"hidden"`
+}
+
+// collectProvenance gathers the record of a scan of root between started and finished
+func collectProvenance(root string, started, finished time.Time) provenanceRecord {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		absRoot = root
+	}
+	record := provenanceRecord{
+		Root:       redactPath(virtualPath(absRoot)),
+		Filesystem: filesystemType(absRoot),
+		User:       effectiveUser(),
+		Started:    started.Format(time.RFC3339),
+		Finished:   finished.Format(time.RFC3339),
+		Unreadable: counters.unreadable,
+		Vanished:   counters.vanished,
+		Special:    counters.special,
+		Virtual:    skippedVirtualList(),
+		Rules:      []provenanceRule{},
+	}
+	patterns := make([]string, 0, len(excludeSources))
+	for pattern := range excludeSources {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		record.Rules = append(record.Rules, provenanceRule{pattern, excludeSources[pattern], counters.excludedBy[pattern]})
+	}
+	return record
+}
+
+// writeProvenance appends the auditable record of what the scan covered
+func writeProvenance(writer io.Writer, root string, started, finished time.Time) {
+	record := collectProvenance(root, started, finished)
+	fmt.Fprintf(writer, "\n%s\n\n", msg("provenance.heading"))
+	for _, line := range [][2]string{
+		{"provenance.root", record.Root},
+		{"provenance.filesystem", record.Filesystem},
+		{"provenance.user", record.User},
+		{"provenance.started", record.Started},
+		{"provenance.finished", record.Finished},
+		{"provenance.unreadable", groupThousands(record.Unreadable)},
+		{"provenance.vanished", groupThousands(record.Vanished)},
+		{"provenance.special", groupThousands(record.Special)},
+	} {
+		fmt.Fprintf(writer, "- %s: %s\n", msg(line[0]), line[1])
+	}
+	// Only a scan of / skips virtual filesystems; elsewhere the line would be empty
+	if len(record.Virtual) > 0 {
+		fmt.Fprintf(writer, "- %s: %s\n", msg("provenance.virtual"), strings.Join(record.Virtual, ", "))
+	}
+
+	fmt.Fprintf(writer, "\n%s\n| --- | --- | --- |\n", msg("provenance.table"))
+	for _, rule := range record.Rules {
+		fmt.Fprintf(writer, "| %s | %s | %d |\n", rule.Pattern, rule.Source, rule.Hidden)
+	}
+}
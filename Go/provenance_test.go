@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestProvenanceVirtualLine(t *testing.T) {
+	saved := skippedVirtual
+	t.Cleanup(func() { skippedVirtual = saved })
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	skippedVirtual = map[string]bool{}
+	var out bytes.Buffer
+	writeProvenance(&out, t.TempDir(), started, started)
+	if strings.Contains(out.String(), msg("provenance.virtual")) {
+		t.Errorf("provenance lists virtual filesystems when none were skipped:\n%s", out.String())
+	}
+
+	skippedVirtual = map[string]bool{"/sys": true, "/proc": true}
+	out.Reset()
+	writeProvenance(&out, t.TempDir(), started, started)
+	if want := "- " + msg("provenance.virtual") + ": /proc, /sys\n"; !strings.Contains(out.String(), want) {
+		t.Errorf("provenance does not contain %q:\n%s", want, out.String())
+	}
+}
+
+// The markdown table and the JSON rules name the entries a pattern hid the same way,
+// with the same counts
+func TestProvenanceHidden(t *testing.T) {
+	fixture := "build/a.o\nbuild/b.o\nsrc/main.go\nsrc/main.o\n"
+	configure := func(format string) func() {
+		return func() {
+			setOption(t, &outputFormat, format)
+			setOption(t, &provenanceFlag, true)
+			addExcludeRule(sourceCLI, "cli", "*.o")
+		}
+	}
+	md := renderFixture(t, testtree.MapFS(t, fixture), true, configure(formatMarkdown))
+	if !strings.Contains(md, msg("provenance.table")+"\n| --- | --- | --- |\n") || !strings.Contains(md, "| *.o | cli | 3 |\n") {
+		t.Errorf("no hidden count for *.o:\n%s", md)
+	}
+
+	var root struct {
+		Provenance struct {
+			Rules []map[string]any
+		}
+	}
+	if err := json.Unmarshal([]byte(renderFixture(t, testtree.MapFS(t, fixture), true, configure(formatJSON))), &root); err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range root.Provenance.Rules {
+		if rule["pattern"] == "*.o" && rule["hidden"] == 3.0 && len(rule) == 3 {
+			return
+		}
+	}
+	t.Errorf("json rules %v, want *.o with hidden 3", root.Provenance.Rules)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+var (
+	pruneEmpty bool                // --prune: leave out directories with nothing visible inside
+	prunedDirs = map[string]bool{} // Directories --prune leaves out, by full path
+)
+
+// pruneTree walks the tree before it is rendered and marks, bottom up, every directory
+// that shows nothing: empty, everything inside excluded or filtered, or holding only
+// directories pruned themselves. Directories the walk does not enter, such as those
+// cut off by --max-depth, are not empty and stay; files count even with --dirs-only.
+// Listings are cached for the walk.
+func pruneTree(root string, rootEntries []fs.DirEntry) {
+	var walk func(dir string, entries []fs.DirEntry) bool
+	walk = func(dir string, entries []fs.DirEntry) bool {
+		shown := 0
+		for _, entry := range contentEntries(dir, entries) {
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			fullPath := filepath.Join(dir, entry.Name())
+			if shouldDescend(fullPath, entry) {
+				subEntries, ok := listingCache[fullPath]
+				if !ok {
+					var err error
+					if subEntries, err = readDir(fullPath); err != nil {
+						// The walk reads it again and reports the error
+						shown++
+						continue
+					}
+					listingCache[fullPath] = subEntries
+				}
+				if !walk(fullPath, subEntries) {
+					prunedDirs[fullPath] = true
+					continue
+				}
+			}
+			shown++
+		}
+		return shown > 0
+	}
+	walk(root, rootEntries)
+}
+
+// filterPruned drops the directories --prune left out
+func filterPruned(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if len(prunedDirs) == 0 {
+		return entries
+	}
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		if !prunedDirs[filepath.Join(dir, entry.Name())] {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// pruneFixture is the fixture of the --prune tests: a directory that is empty, one that
+// only holds the excluded node_modules, a chain emptied by pruning its children and a
+// chain that leads to a file
+const pruneFixture = `
+empty/
+only/node_modules/x/i.js
+chain/a/b/
+keep/deep/deeper/f.txt
+top.txt
+`
+
+// --prune drops the empty directory, the one emptied by exclusion and the chain emptied
+// by pruning, and keeps every directory --max-depth cuts off
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		depth int
+		want  string
+	}{
+		{0, "├── [D] keep\n" +
+			"│   └── [D] deep\n" +
+			"│       └── [D] deeper\n" +
+			"│           └── [F] f.txt\n" +
+			"└── [F] top.txt\n"},
+		{2, "├── [D] chain\n" +
+			"│   └── [D] a\n" +
+			"│       └── … (1 entry omitted)\n" +
+			"├── [D] keep\n" +
+			"│   └── [D] deep\n" +
+			"│       └── … (2 entries omitted)\n" +
+			"└── [F] top.txt\n"},
+	}
+	for _, test := range tests {
+		got := renderFixture(t, testtree.MapFS(t, pruneFixture), true, func() {
+			setOption(t, &noSummary, true)
+			setOption(t, &pruneEmpty, true)
+			setOption(t, &scan.MaxDepth, test.depth)
+		})
+		if got != test.want {
+			t.Errorf("-L %d: got\n%s\nwant\n%s", test.depth, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// redactedLabel replaces any name that must not be published
+const redactedLabel = "[redacted]"
+
+// Redaction settings for publishing trees externally
+var (
+	redactPatterns []string            // Glob patterns whose matching names are redacted
+	redactKeepExt  bool                // Keep the extension of redacted file names
+	redactEnv      bool                // Also redact the current user name and host name
+	redactSecrets  = map[string]bool{} // Literal names redacted by --redact-env
+	redactedCount  int                 // Entries redacted in the tree
+)
+
+// loadRedactEnv records the local user and host names as redaction targets
+func loadRedactEnv() {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		// Without a user database (scratch containers) only $USER is available
+		name = u.Username
+	}
+	// Windows reports DOMAIN\user
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if name != "" {
+		redactSecrets[name] = true
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		redactSecrets[host] = true
+		if short, _, found := strings.Cut(host, "."); found {
+			redactSecrets[short] = true
+		}
+	}
+}
+
+// redactionEnabled reports whether any redaction option is active
+func redactionEnabled() bool {
+	return len(redactPatterns) > 0 || redactEnv
+}
+
+// shouldRedact reports whether a single name or path segment must be hidden
+func shouldRedact(name string) bool {
+	if redactSecrets[name] {
+		return true
+	}
+	for _, pattern := range redactPatterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redactName returns the name to publish for an entry and whether it was redacted
+func redactName(name string) (string, bool) {
+	if !shouldRedact(name) {
+		return name, false
+	}
+	if ext := path.Ext(name); redactKeepExt && ext != "" && ext != name {
+		return redactedLabel + ext, true
+	}
+	return redactedLabel, true
+}
+
+// redactText returns a name safe to publish outside the tree itself (group keys, user names)
+func redactText(name string) string {
+	safe, _ := redactName(name)
+	return safe
+}
+
+// redactPath redacts every sensitive segment of an OS path, keeping its separators
+func redactPath(p string) string {
+	if !redactionEnabled() {
+		return p
+	}
+	segments := strings.Split(filepath.ToSlash(p), "/")
+	for i, segment := range segments {
+		if segment != "" {
+			segments[i] = redactText(segment)
+		}
+	}
+	return filepath.FromSlash(strings.Join(segments, "/"))
+}
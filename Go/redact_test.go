@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Names matching --redact-patterns leak through no format: not in the tree, a link
+// target, a group heading, the usage table or the provenance block
+func TestRedactionAcrossFormats(t *testing.T) {
+	src := testtree.Dir(t, "topsecret-plans/inner.txt\nkeys/id_rsa_backup\nkeys/deploy.pem\nlink -> keys/deploy.pem\n")
+	redact := []string{"-d", src, "--redact-patterns", "*secret*,*.pem,id_rsa*", "-o", "-", "--quiet"}
+	for _, args := range [][]string{
+		{"--provenance", "--usage-by", "owner,ext"},
+		{"--group-by", "ext"},
+		{"-f", "json", "--provenance", "--usage-by", "owner,ext"},
+		{"-f", "md-list"}, {"-f", "text"}, {"-f", "html"}, {"-f", "svg"}, {"-f", "mermaid"},
+		{"-f", "manifest"}, {"-f", "csv"}, {"-f", "tsv"}, {"-f", "paths"},
+	} {
+		stdout, stderr, code := runFTG(t, t.TempDir(), append(redact, args...)...)
+		if code != exitOK || !strings.Contains(stdout, "redacted") {
+			t.Errorf("%q: exit code %d, nothing redacted:\n%s%s", args, code, stdout, stderr)
+			continue
+		}
+		for _, planted := range []string{"topsecret", "id_rsa", "deploy"} {
+			if strings.Contains(stdout+stderr, planted) {
+				t.Errorf("%q: %s leaks:\n%s%s", args, planted, stdout, stderr)
+			}
+		}
+	}
+
+	stdout, _, _ := runFTG(t, t.TempDir(), append(redact, "--redact-keep-ext")...)
+	for _, want := range []string{"├── [F] [redacted].pem\n", "[L] link -> keys/[redacted].pem\n", "└── [D] [redacted]\n", "Redacted entries: 3\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("with --redact-keep-ext, missing %q:\n%s", want, stdout)
+		}
+	}
+}
+
+// --redact-env hides the path segments equal to the user or host name, and the
+// user of the provenance block
+func TestRedactEnv(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		t.Skipf("no host name: %v", err)
+	}
+	name := current.Username[strings.LastIndex(current.Username, `\`)+1:]
+	src := testtree.Dir(t, "home/"+name+"/notes.txt\nmachines/"+host+"/\n")
+	for _, format := range []string{"md", "json"} {
+		stdout, stderr, code := runFTG(t, t.TempDir(), "-d", src, "--redact-env", "--provenance", "-f", format, "-o", "-", "--quiet")
+		if code != exitOK {
+			t.Fatalf("-f %s: exit code %d: %s", format, code, stderr)
+		}
+		for _, leak := range []string{"] " + name + "\n", "] " + host + "\n", `"name": "` + name + `"`, `"name": "` + host + `"`, "user: " + name, `"user": "` + name} {
+			if strings.Contains(stdout, leak) {
+				t.Errorf("-f %s: %q leaks:\n%s", format, leak, stdout)
+			}
+		}
+		if want := map[string]string{"md": "Redacted entries: 2\n", "json": `"redacted": 2`}[format]; !strings.Contains(stdout, want) {
+			t.Errorf("-f %s: missing %q:\n%s", format, want, stdout)
+		}
+	}
+}
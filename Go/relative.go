@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	relativeTo    string // Base directory for displayed paths and "/" patterns; relative values start at the input directory
+	displayPrefix string // Input directory as seen from relativeTo, "" when both are the same
+	matchPrefix   string // Prefix applied before matching "/" patterns; empty when the base does not contain the input
+)
+
+// setRelativeTo resolves --relative-to against the input directory. When the input
+// directory is not inside the base, paths are shown absolute and patterns keep
+// matching relative to the input directory.
+func setRelativeTo(base string) {
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(scan.Root, base)
+	} else {
+		base = realPath(base)
+	}
+	absBase, errBase := filepath.Abs(base)
+	absRoot, errRoot := filepath.Abs(scan.Root)
+	if errBase != nil || errRoot != nil {
+		errorExit("Cannot resolve --relative-to " + base)
+	}
+	rel, err := filepath.Rel(absBase, absRoot)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		warnf("%s is not inside --relative-to %s; showing absolute paths", absRoot, absBase)
+		displayPrefix = filepath.ToSlash(absRoot)
+		return
+	}
+	if rel != "." {
+		displayPrefix = filepath.ToSlash(rel)
+		matchPrefix = displayPrefix
+	}
+}
+
+// displayPath returns a path relative to the input directory as it should be shown
+func displayPath(rel string) string {
+	if displayPrefix == "" {
+		return rel
+	}
+	// path.Join would collapse the "//" of a UNC prefix like //server/share
+	if share, ok := strings.CutPrefix(displayPrefix, "//"); ok {
+		return "//" + path.Join(share, rel)
+	}
+	return path.Join(displayPrefix, rel)
+}
+
+// rootName returns the name shown for a root directory: its base name, or the volume
+// of a drive or share root such as D:\ or \\server\share, whose base name is a separator
+func rootName(dir string) string {
+	base := filepath.Base(dir)
+	if base == string(filepath.Separator) {
+		if vol := filepath.VolumeName(dir); vol != "" {
+			return vol
+		}
+	}
+	return base
+}
+
+// matchPath returns a path relative to the input directory as "/" patterns see it
+func matchPath(rel string) string {
+	if matchPrefix == "" {
+		return rel
+	}
+	return path.Join(matchPrefix, rel)
+}
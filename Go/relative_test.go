@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// A base above the input directory prefixes both shown paths and the paths "/"
+// patterns match; a base elsewhere shows absolute paths, with a warning, and
+// leaves matching relative to the input directory
+func TestSetRelativeTo(t *testing.T) {
+	src := testtree.Dir(t, "services/api/cmd/main.go\nother/\n")
+	api := filepath.Join(src, "services", "api")
+	for _, test := range []struct {
+		base                  string
+		display, match, warns string
+	}{
+		{"../..", "services/api/cmd", "services/api/cmd", ""},
+		{src, "services/api/cmd", "services/api/cmd", ""},
+		{".", "cmd", "cmd", ""},
+		{"../../other", filepath.ToSlash(api) + "/cmd", "cmd", "is not inside --relative-to"},
+	} {
+		t.Run(test.base, func(t *testing.T) {
+			logs := captureWarnings(t)
+			setOption(t, &progress.warnings, 0)
+			setOption(t, &scan.Root, api)
+			setOption(t, &displayPrefix, "")
+			setOption(t, &matchPrefix, "")
+			setRelativeTo(test.base)
+			if got := displayPath("cmd"); got != test.display {
+				t.Errorf("displayPath = %q, want %q", got, test.display)
+			}
+			if got := matchPath("cmd"); got != test.match {
+				t.Errorf("matchPath = %q, want %q", got, test.match)
+			}
+			if got := logs.String(); test.warns == "" && got != "" || !strings.Contains(got, test.warns) {
+				t.Errorf("warnings %q, want %q", got, test.warns)
+			}
+		})
+	}
+}
+
+// Traversal still starts at -d while the paths of the flat formats and "/"
+// patterns are taken from the base
+func TestRelativeTo(t *testing.T) {
+	src := testtree.Dir(t, "services/api/cmd/main.go\nservices/api/go.mod\nservices/api/internal/cmd/x.go\nother/\n")
+	api := filepath.Join(src, "services", "api")
+	for _, test := range []struct {
+		args []string
+		want string
+		code int
+	}{
+		{[]string{"--relative-to", "../..", "-f", "paths"}, "services/api/cmd/\nservices/api/cmd/main.go\nservices/api/go.mod\n" +
+			"services/api/internal/\nservices/api/internal/cmd/\nservices/api/internal/cmd/x.go\n", exitOK},
+		{[]string{"--relative-to", "../..", "-e", "/services/api/cmd", "-f", "paths"}, "services/api/go.mod\n" +
+			"services/api/internal/\nservices/api/internal/cmd/\nservices/api/internal/cmd/x.go\n", exitOK},
+		// Anchored to the base, /cmd names nothing
+		{[]string{"--relative-to", "../..", "-e", "/cmd", "-f", "paths"}, "services/api/cmd/\nservices/api/cmd/main.go\nservices/api/go.mod\n" +
+			"services/api/internal/\nservices/api/internal/cmd/\nservices/api/internal/cmd/x.go\n", exitOK},
+		{[]string{"-e", "/cmd", "-f", "paths"}, "go.mod\ninternal/\ninternal/cmd/\ninternal/cmd/x.go\n", exitOK},
+		{[]string{"--relative-to", "../../other", "-e", "/cmd", "-f", "paths"}, filepath.ToSlash(api) + "/go.mod\n" +
+			filepath.ToSlash(api) + "/internal/\n" + filepath.ToSlash(api) + "/internal/cmd/\n" + filepath.ToSlash(api) + "/internal/cmd/x.go\n", exitWarnings},
+	} {
+		stdout, stderr, code := runFTG(t, api, append([]string{"-d", ".", "-o", "-", "--quiet"}, test.args...)...)
+		if code != test.code || stdout != test.want {
+			t.Errorf("%q: exit code %d, got\n%s%s\nwant %d and\n%s", test.args, code, stdout, stderr, test.code, test.want)
+		}
+	}
+	stdout, stderr, _ := runFTG(t, api, "-d", ".", "--relative-to", "../..", "-f", "manifest", "-o", "-", "--quiet")
+	if want := `"path": "services/api/cmd/main.go"`; !strings.Contains(stdout, want) {
+		t.Errorf("the manifest misses %s:\n%s%s", want, stdout, stderr)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/faultfs"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// setOption sets a package option for the rest of the test
+func setOption[T any](t *testing.T, option *T, value T) {
+	t.Helper()
+	saved := *option
+	*option = value
+	t.Cleanup(func() { *option = saved })
+}
+
+// renderFixture renders fsys in-process as the input directory "fixture", the way a
+// run with the default exclusions does, after configure has set its options with
+// setOption. bare leaves out the header, code fence and fingerprint, as -o - does.
+func renderFixture(t *testing.T, fsys fs.FS, bare bool, configure func()) string {
+	t.Helper()
+	return string(renderFixtureContext(context.Background(), t, fsys, bare, configure))
+}
+
+// renderFixtureContext is renderFixture with the context of the run
+func renderFixtureContext(ctx context.Context, t *testing.T, fsys fs.FS, bare bool, configure func()) []byte {
+	t.Helper()
+	useFixture(t, fsys, configure)
+	data, err := renderRun(ctx, bare)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fingerprinted(data, bare)
+}
+
+// useFixture makes fsys the input directory "fixture" for the rest of the test, with
+// the options configure sets and the default exclusions
+func useFixture(t *testing.T, fsys fs.FS, configure func()) {
+	t.Helper()
+	setOption(t, &fixtureFS, fsys)
+	setOption(t, &scan.Root, "fixture")
+	setOption(t, &ruleLayers, map[string]*ruleLayer{})
+	setOption(t, &excludeSources, map[string]string{})
+	setOption(t, &ignoreFileHashes, map[string]string{})
+	resetWalkState()
+	t.Cleanup(resetWalkState)
+	if configure != nil {
+		configure()
+	}
+	if !scan.NoDefaultExcludes {
+		for _, pattern := range ftree.DefaultExcludes {
+			addExcludeRule(sourceDefaults, "default", pattern)
+		}
+	}
+}
+
+// fixture is the tree most renderer tests share: nested directories, a default
+// exclusion, a dotfile, an empty directory and names that sort in byte order
+const fixture = `
+README.md content="# Demo\n"
+.env size=12
+Makefile size=100
+docs/
+docs/guide.md size=2048
+empty/
+node_modules/left-pad/index.js
+src/
+src/main.go size=300
+src/util/
+src/util/strings.go size=1200
+src/util/strings_test.go size=900
+`
+
+func TestGoldenMarkdown(t *testing.T) {
+	testtree.Golden(t, "tree.md", []byte(renderFixture(t, testtree.MapFS(t, fixture), false, nil)))
+}
+
+func TestGoldenMarkdownSizes(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), false, func() {
+		setOption(t, &showSizes, true)
+	})
+	testtree.Golden(t, "tree-sizes.md", []byte(got))
+}
+
+func TestGoldenText(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), false, func() {
+		setOption(t, &outputFormat, formatText)
+	})
+	testtree.Golden(t, "tree.txt", []byte(got))
+}
+
+func TestGoldenJSON(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), false, func() {
+		setOption(t, &outputFormat, formatJSON)
+	})
+	testtree.Golden(t, "tree.json", []byte(got))
+}
+
+func TestGoldenMarkdownList(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), false, func() {
+		setOption(t, &outputFormat, formatMarkdownList)
+	})
+	testtree.Golden(t, "tree-list.md", []byte(got))
+}
+
+func TestGoldenMermaid(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), false, func() {
+		setOption(t, &outputFormat, formatMermaid)
+	})
+	testtree.Golden(t, "tree-mermaid.md", []byte(got))
+}
+
+// A diagram cut at --mermaid-max-nodes ends in a marker node and exits with
+// exitTruncated; one under the cap exits 0
+func TestMermaidMaxNodesExitCode(t *testing.T) {
+	dir := testtree.Dir(t, "a/one.txt\na/two.txt\nb.txt\n")
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "-f", "mermaid", "--mermaid-max-nodes", "3", "-o", "-")
+	if code != exitTruncated || !strings.Contains(stdout, `cut["…"]`) || !strings.Contains(stderr, "stops at 3 nodes") {
+		t.Errorf("exit code %d, want %d:\n%s%s", code, exitTruncated, stdout, stderr)
+	}
+	if _, stderr, code := runFTG(t, dir, "-d", ".", "-f", "mermaid", "-o", "-"); code != exitOK {
+		t.Errorf("without the cut: exit code %d: %s", code, stderr)
+	}
+	// The cut of a render --verify-renderers only compares is not the output's
+	if _, stderr, code := runFTG(t, dir, "-d", ".", "--verify-renderers", "--mermaid-max-nodes", "3", "-o", "-"); code != exitOK {
+		t.Errorf("--verify-renderers: exit code %d: %s", code, stderr)
+	}
+}
+
+// Bare and with the time in the page header masked, as both change on every run
+func TestGoldenHTML(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), true, func() {
+		setOption(t, &outputFormat, formatHTML)
+	})
+	got = regexp.MustCompile(`<p class="meta">[^·]*·`).ReplaceAllString(got, `<p class="meta">TIME ·`)
+	testtree.Golden(t, "tree.html", []byte(got))
+}
+
+func TestGoldenCompleteTree(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), true, func() {
+		setOption(t, &scan.NoDefaultExcludes, true)
+	})
+	testtree.Golden(t, "tree-complete.md", []byte(got))
+}
+
+// A fixture built on disk renders like the same fixture in memory
+func TestGoldenDiskFixture(t *testing.T) {
+	dir := testtree.Dir(t, fixture)
+	stdout, stderr, code := runFTG(t, dir, "-c", "-o", "-")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	testtree.Golden(t, "tree-complete.md", []byte(stdout))
+}
+
+// Renders of small MapFS fixtures match exactly, with paths of the FS joined the way
+// exclusions see them
+func TestRenderMapFS(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		configure func(t *testing.T)
+		want      string
+	}{
+		{"flat", "b.txt\na.txt\n", nil, "├── [F] a.txt\n└── [F] b.txt\n"},
+		{"nested", "a/b/c.txt\na/d.txt\n", nil, "└── [D] a\n    ├── [D] b\n    │   └── [F] c.txt\n    └── [F] d.txt\n"},
+		{"empty directory", "a/\n", nil, "└── [D] a\n"},
+		{"relative path exclusion", "a/b/c.txt\na/d.txt\nb/c.txt\n", func(t *testing.T) {
+			addExcludeRule(sourceCLI, "user (-e)", "a/b")
+		}, "├── [D] a\n│   └── [F] d.txt\n└── [D] b\n    └── [F] c.txt\n"},
+		{"text", "a/b/c.txt\na/d.txt\n", func(t *testing.T) {
+			setOption(t, &outputFormat, formatText)
+		}, ".\n└── a\n    ├── b\n    │   └── c.txt\n    └── d.txt\n\n2 directories, 2 files\n"},
+		{"sizes", "a.txt size=3\nb/\n", func(t *testing.T) {
+			setOption(t, &showSizes, true)
+		}, "├── [F] a.txt (3 B)\n└── [D] b\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, test.spec), true, func() {
+				setOption(t, &noSummary, true)
+				if test.configure != nil {
+					test.configure(t)
+				}
+			})
+			if got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+// The header names the input directory as given, not the root of the FS
+func TestRenderMapFSHeader(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, "a.txt\n"), false, nil)
+	if want := "# File Tree for fixture\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("got\n%s\nwant it to start with %q", got, want)
+	}
+}
+
+// A render that cannot be written comes back as an error for main to report, with
+// nothing rendered and the process left running
+func TestRenderRunError(t *testing.T) {
+	useFixture(t, faultfs.New(testtree.MapFS(t, "a.txt\nb.txt\nc.txt\n"), map[string]faultfs.Fault{"b.txt": faultfs.Permission}), func() {
+		setOption(t, &outputFormat, formatManifest)
+		setOption(t, &messages, io.Discard)
+	})
+	data, err := renderRun(context.Background(), true)
+	if err == nil || !strings.Contains(err.Error(), "could not be read for the manifest") || data != nil {
+		t.Errorf("renderRun = %q, %v; want the unreadable file as an error", data, err)
+	}
+}
+
+// A tree longer than an SVG can hold is cut with a line counting the rest
+func TestSVGMaxLines(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, "a.txt\nb.txt\nc.txt\n"), true, func() {
+		setOption(t, &outputFormat, formatSVG)
+		setOption(t, &svgMaxLines, 3)
+		setOption(t, &messages, io.Discard)
+	})
+	if !strings.Contains(got, ">a.txt<") || strings.Contains(got, ">b.txt<") || !strings.Contains(got, ">… 2 more lines<") || !svgCut {
+		t.Errorf("got\n%s\nwant a.txt and a line for the two left out", got)
+	}
+}
+
+// ftree.Render draws the entries the command line walks line for line as ftg does,
+// in md and in text with the style -f text picks
+func TestRenderMatchesLibrary(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		style  string
+	}{
+		{formatMarkdown, "default"},
+		{formatText, "tree"},
+	} {
+		t.Run(test.format, func(t *testing.T) {
+			configure := func() {
+				setOption(t, &outputFormat, test.format)
+				setOption(t, &noSummary, true)
+				setOption(t, &connectors, ftree.Styles[test.style])
+			}
+			root := &ftree.Node{Name: ".", IsDir: true}
+			parents := []*ftree.Node{root}
+			_, err := walkFixture(context.Background(), t, testtree.MapFS(t, fixture), configure, func(e treeEntry) error {
+				parent := parents[len(parents)-1]
+				switch e.event {
+				case walkEntry:
+					parent.Children = append(parent.Children, &ftree.Node{Name: e.entry.Name(), IsDir: e.entryType == "D"})
+				case walkOpen:
+					parents = append(parents, parent.Children[len(parent.Children)-1])
+				case walkClose:
+					parents = parents[:len(parents)-1]
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var library strings.Builder
+			if err := ftree.Render(&library, root, test.format, ftree.RenderOptions{Style: ftree.Styles[test.style]}); err != nil {
+				t.Fatal(err)
+			}
+			want := library.String()
+			if test.format == formatMarkdown {
+				want = strings.TrimSuffix(want[strings.Index(want, "```sh\n")+len("```sh\n"):], "```\n")
+			}
+			if got := renderFixture(t, testtree.MapFS(t, fixture), true, configure); got != want {
+				t.Errorf("ftg:\n%s\nftree.Render:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Windows file attributes and reparse tags used to classify entries
+const (
+	fileAttributeReparsePoint       = 0x400
+	fileAttributeOffline            = 0x1000
+	fileAttributeRecallOnOpen       = 0x40000
+	fileAttributeRecallOnDataAccess = 0x400000
+	reparseTagMountPoint            = 0xA0000003
+	reparseTagSymlink               = 0xA000000C
+)
+
+// reparseKind is how the walk treats a Windows reparse point
+type reparseKind int
+
+const (
+	reparseNone        reparseKind = iota // Ordinary entry, or a reparse point with local data (dedup, synced cloud files)
+	reparseJunction                       // Directory junction or volume mount point
+	reparseSymlink                        // NTFS symbolic link
+	reparsePlaceholder                    // Cloud file whose contents are not stored locally
+)
+
+var followSymlinks bool // Descend into junctions instead of listing them as links
+
+// classifyReparse maps raw file attributes and a reparse tag to a reparseKind.
+// Placeholders are recognized by their recall attributes, which OneDrive and
+// other cloud providers set whether or not the entry is also a reparse point.
+func classifyReparse(attrs, tag uint32) reparseKind {
+	if attrs&(fileAttributeRecallOnDataAccess|fileAttributeRecallOnOpen|fileAttributeOffline) != 0 {
+		return reparsePlaceholder
+	}
+	if attrs&fileAttributeReparsePoint == 0 {
+		return reparseNone
+	}
+	switch tag {
+	case reparseTagMountPoint:
+		return reparseJunction
+	case reparseTagSymlink:
+		return reparseSymlink
+	}
+	return reparseNone
+}
+
+// shouldDescend reports whether the walk lists the contents of a directory entry.
+// Nothing below --max-depth is listed.
+// Placeholders are never opened so listing does not trigger hydration, and links
+// are only followed with --follow-symlinks when they lead to a directory that is
+// not already being listed further up the same path.
+func shouldDescend(fullPath string, entry fs.DirEntry) bool {
+	if !withinDepth(fullPath) || isDeleted(entry) {
+		return false
+	}
+	if reparseKindOf(fullPath, entry) == reparsePlaceholder {
+		return false
+	}
+	if isLink(fullPath, entry) {
+		if !followSymlinks || !linksToDir(fullPath) {
+			return false
+		}
+		if linksToAncestor(fullPath) {
+			warnf("Not following %s: it points to one of its parent directories", fullPath)
+			return false
+		}
+		return true
+	}
+	return entry.IsDir()
+}
+
+// isLink reports whether an entry is a symbolic link or a Windows junction
+func isLink(fullPath string, entry fs.DirEntry) bool {
+	switch reparseKindOf(fullPath, entry) {
+	case reparseJunction, reparseSymlink:
+		return true
+	}
+	return entry.Type()&fs.ModeSymlink != 0
+}
+
+// linksToDir reports whether a link resolves to a directory
+func linksToDir(fullPath string) bool {
+	info, err := targetInfo(fullPath)
+	return err == nil && info.IsDir()
+}
+
+// linksToAncestor reports whether a link resolves to a directory the walk is already
+// inside. Every directory on the way up is resolved, so a cycle through other links
+// (a/x -> ../b, b/y -> ../a) is caught on its second lap instead of recursing forever.
+func linksToAncestor(fullPath string) bool {
+	target, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return true
+	}
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return true
+	}
+	dir, err := filepath.Abs(filepath.Dir(fullPath))
+	if err != nil {
+		return true
+	}
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			resolved = dir
+		}
+		if resolved == target || strings.HasPrefix(resolved, target+string(filepath.Separator)) {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// reparseType returns the type letter for an entry, "L" for links and junctions
+func reparseType(fullPath string, entry fs.DirEntry) string {
+	if isLink(fullPath, entry) {
+		return "L"
+	}
+	return getEntryType(entry)
+}
+
+// reparseLabel returns the " -> target" suffix for links, marked [cycle] when
+// --follow-symlinks would loop, and the note for placeholders
+func reparseLabel(fullPath string, entry fs.DirEntry) string {
+	if isLink(fullPath, entry) {
+		target, err := readLinkPath(fullPath)
+		if err != nil {
+			return " -> ?"
+		}
+		label := " -> " + redactPath(target)
+		if followSymlinks && linksToDir(fullPath) && linksToAncestor(fullPath) {
+			label += " [cycle]"
+		}
+		return label
+	}
+	if reparseKindOf(fullPath, entry) == reparsePlaceholder {
+		return " (online-only)"
+	}
+	return ""
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "io/fs"
+
+// reparseKindOf reports that reparse points only exist on Windows
+func reparseKindOf(string, fs.DirEntry) reparseKind {
+	return reparseNone
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Links are shown with their target and left alone by default; --follow-symlinks
+// descends into a link to a directory and marks one to a parent [cycle] instead of
+// looping, with a warning and the exit code for warnings
+func TestSymlinkCycles(t *testing.T) {
+	src := testtree.Dir(t, "self -> .\nlinked -> sub\nsub/f.txt\nsub/up -> ..\n")
+	for _, test := range []struct {
+		args []string
+		want string
+		code int
+	}{
+		{nil, "├── [L] linked -> sub\n" +
+			"├── [L] self -> .\n" +
+			"└── [D] sub\n" +
+			"    ├── [F] f.txt\n" +
+			"    └── [L] up -> ..\n", exitOK},
+		{[]string{"--follow-symlinks"}, "├── [L] linked -> sub\n" +
+			"│   ├── [F] f.txt\n" +
+			"│   └── [L] up -> .. [cycle]\n" +
+			"├── [L] self -> . [cycle]\n" +
+			"└── [D] sub\n" +
+			"    ├── [F] f.txt\n" +
+			"    └── [L] up -> .. [cycle]\n", exitWarnings},
+	} {
+		stdout, stderr, code := runFTG(t, t.TempDir(), append(test.args, "-d", src, "-o", "-", "--no-summary")...)
+		if stdout != test.want || code != test.code {
+			t.Errorf("%q: exit code %d, got\n%s\nwant %d and\n%s", test.args, code, stdout, test.code, test.want)
+		}
+		if cycles := strings.Count(stderr, "it points to one of its parent directories"); cycles != strings.Count(test.want, "[cycle]") {
+			t.Errorf("%q: %d cycle warnings for the [cycle] markers:\n%s", test.args, cycles, stderr)
+		}
+	}
+}
+
+// Junctions and mount points are links, NTFS symlinks are links, and recall or
+// offline attributes make a cloud placeholder whatever the tag; other reparse
+// points, such as deduplicated files, are ordinary entries
+func TestClassifyReparse(t *testing.T) {
+	for _, test := range []struct {
+		attrs, tag uint32
+		want       reparseKind
+	}{
+		{0, 0, reparseNone},
+		{0x10, 0, reparseNone}, // A plain directory
+		{fileAttributeReparsePoint, reparseTagMountPoint, reparseJunction},
+		{fileAttributeReparsePoint | 0x10, reparseTagMountPoint, reparseJunction},
+		{fileAttributeReparsePoint, reparseTagSymlink, reparseSymlink},
+		{fileAttributeReparsePoint, 0x80000013, reparseNone}, // IO_REPARSE_TAG_DEDUP
+		{reparseTagMountPoint, 0, reparseNone},               // A tag without the attribute is not read
+		{fileAttributeReparsePoint | fileAttributeRecallOnDataAccess, 0x9000001A, reparsePlaceholder},
+		{fileAttributeRecallOnDataAccess, 0, reparsePlaceholder},
+		{fileAttributeRecallOnOpen, 0, reparsePlaceholder},
+		{fileAttributeOffline, 0, reparsePlaceholder},
+		{fileAttributeReparsePoint | fileAttributeOffline, reparseTagSymlink, reparsePlaceholder},
+	} {
+		if got := classifyReparse(test.attrs, test.tag); got != test.want {
+			t.Errorf("attributes %#x, tag %#x: %v, want %v", test.attrs, test.tag, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// reparseKindOf classifies an entry from its attributes and, for reparse points, its tag
+func reparseKindOf(fullPath string, entry fs.DirEntry) reparseKind {
+	info, err := entryInfo(entry)
+	if err != nil {
+		return reparseNone
+	}
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return reparseNone
+	}
+	var tag uint32
+	if data.FileAttributes&fileAttributeReparsePoint != 0 {
+		// The tag is only reported by the find APIs, which do not open the file
+		name, err := syscall.UTF16PtrFromString(fullPath)
+		if err != nil {
+			return reparseNone
+		}
+		var find syscall.Win32finddata
+		handle, err := syscall.FindFirstFile(name, &find)
+		if err != nil {
+			return reparseNone
+		}
+		syscall.FindClose(handle)
+		tag = find.Reserved0
+	}
+	return classifyReparse(data.FileAttributes, tag)
+}
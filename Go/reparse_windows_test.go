@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// A directory junction is shown as a link to its target and only descended into
+// with --follow-symlinks
+func TestJunctionIsALink(t *testing.T) {
+	src := testtree.Dir(t, "real/f.txt\n")
+	junction := filepath.Join(src, "j")
+	if out, err := exec.Command("cmd", "/c", "mklink", "/J", junction, filepath.Join(src, "real")).CombinedOutput(); err != nil {
+		t.Skipf("cannot create a junction: %v: %s", err, out)
+	}
+	stdout, stderr, code := runFTG(t, t.TempDir(), "-d", src, "-o", "-", "--no-summary")
+	if code != exitOK || !strings.Contains(stdout, "├── [L] j -> ") || strings.Count(stdout, "f.txt") != 1 {
+		t.Errorf("exit code %d, got\n%s%s", code, stdout, stderr)
+	}
+	stdout, stderr, code = runFTG(t, t.TempDir(), "-d", src, "--follow-symlinks", "-o", "-", "--no-summary")
+	if code != exitOK || !strings.Contains(stdout, "├── [L] j -> ") || strings.Count(stdout, "f.txt") != 2 {
+		t.Errorf("--follow-symlinks: exit code %d, got\n%s%s", code, stdout, stderr)
+	}
+}
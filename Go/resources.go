@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"runtime"
+	"time"
+)
+
+// phaseTiming is the wall time spent in one phase of a run
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+var reportResources bool // Print resource usage to stderr after the run
+
+// resources counts filesystem calls for --report-resources. The counters are
+// plain increments; anything that costs more than that (goroutine sampling,
+// clocks, memory statistics) only runs when the report was requested.
+var resources struct {
+	readDirs   int           // Directory listings, including --only look-ahead
+	stats      int           // Lstat calls, including DirEntry.Info
+	bytesRead  int64         // File contents read by content features
+	goroutines int           // Highest goroutine count seen
+	phases     []phaseTiming // Completed phases in order
+	phase      string        // Phase currently running
+	phaseStart time.Time
+	jobs       *jobsReport // Concurrency reached by --jobs auto, nil otherwise
+}
+
+// jobsReport is how --jobs auto ended up
+type jobsReport struct {
+	final, peak, adjustments int
+}
+
+// readDir lists a directory and counts the call
+func readDir(dir string) ([]fs.DirEntry, error) {
+	resources.readDirs++
+	if reportResources {
+		resources.goroutines = max(resources.goroutines, runtime.NumGoroutine())
+	}
+	return listDir(dir)
+}
+
+// lstat stats a path without following links and counts the call
+func lstat(path string) (fs.FileInfo, error) {
+	resources.stats++
+	return statPath(path)
+}
+
+// readFile reads a file's contents and counts the bytes
+func readFile(path string) ([]byte, error) {
+	data, err := readPath(path)
+	resources.bytesRead += int64(len(data))
+	return data, err
+}
+
+// entryInfo returns a directory entry's file info and counts the call
+func entryInfo(entry fs.DirEntry) (fs.FileInfo, error) {
+	resources.stats++
+	return entry.Info()
+}
+
+// startPhase ends the running phase, if any, and starts timing the next one
+func startPhase(name string) {
+	if !reportResources {
+		return
+	}
+	now := time.Now()
+	if resources.phase != "" {
+		resources.phases = append(resources.phases, phaseTiming{resources.phase, now.Sub(resources.phaseStart)})
+	}
+	resources.phase, resources.phaseStart = name, now
+}
+
+// writeResourceReport ends the last phase and prints the collected usage
+func writeResourceReport(writer io.Writer) {
+	startPhase("")
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fmt.Fprintln(writer, "Resource usage:")
+	if rss, ok := peakRSS(); ok {
+		fmt.Fprintf(writer, "  Peak RSS:          %s\n", formatSize(int64(rss)))
+	} else {
+		fmt.Fprintf(writer, "  Peak RSS:          n/a (Go runtime reserved %s)\n", formatSize(int64(mem.Sys)))
+	}
+	fmt.Fprintf(writer, "  Heap in use:       %s\n", formatSize(int64(mem.HeapInuse)))
+	fmt.Fprintf(writer, "  ReadDir calls:     %d\n", resources.readDirs)
+	fmt.Fprintf(writer, "  Stat calls:        %d\n", resources.stats)
+	fmt.Fprintf(writer, "  Content bytes:     %s\n", formatSize(resources.bytesRead))
+	fmt.Fprintf(writer, "  Peak goroutines:   %d\n", max(resources.goroutines, runtime.NumGoroutine()))
+	if hashCache != nil {
+		fmt.Fprintf(writer, "  Digest cache:      %d hits, %d misses (%d stale)\n", hashCacheStats.hits, hashCacheStats.misses, hashCacheStats.stale)
+	}
+	if jobs := resources.jobs; jobs != nil {
+		fmt.Fprintf(writer, "  Concurrent reads:  %d at the end, %d at peak, %s (--jobs auto)\n", jobs.final, jobs.peak, plural(jobs.adjustments, "adjustment"))
+	}
+	for _, phase := range resources.phases {
+		fmt.Fprintf(writer, "  %-18s %s\n", phase.name+" time:", phase.duration.Round(time.Microsecond))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runResult is the single JSON object written with --result-json-fd when the run ends:
+//
+//	{"status":"warnings","exitCode":3,"outputs":["tree.md"],"dirs":120,"entries":1380,
+//	 "excluded":42,"unreadable":1,"vanished":0,"warnings":1,"durationMs":1000}
+//
+// "status" is "ok", "warnings" or "error"; "message" is only set for errors.
+type runResult struct {
+	Status     string   `json:"status"`
+	ExitCode   int      `json:"exitCode"`
+	Message    string   `json:"message,omitempty"`
+	Outputs    []string `json:"outputs"`
+	Dirs       int      `json:"dirs"`
+	Entries    int      `json:"entries"`
+	Excluded   int      `json:"excluded"`
+	Unreadable int      `json:"unreadable"`
+	Vanished   int      `json:"vanished"`
+	Warnings   int      `json:"warnings"`
+	DurationMs int64    `json:"durationMs"`
+}
+
+var (
+	resultFD       = -1      // Descriptor given with --result-json-fd; -1 disables the result object
+	resultFile     *os.File  // Open result descriptor, nil until validated
+	runStarted     time.Time // Start of the run, for the result duration
+	writtenOutputs []string  // Destinations that received the tree
+)
+
+// writeResult writes the result object once; later calls do nothing
+func writeResult(code int, message string) {
+	if resultFile == nil {
+		return
+	}
+	result := runResult{
+		Status:     "ok",
+		ExitCode:   code,
+		Message:    message,
+		Outputs:    writtenOutputs,
+		Dirs:       progress.dirs,
+		Entries:    progress.done,
+		Unreadable: counters.unreadable,
+		Vanished:   counters.vanished,
+		Warnings:   progress.warnings,
+		DurationMs: time.Since(runStarted).Milliseconds(),
+	}
+	switch {
+	case message != "" || code == exitFatal || code == exitUsage:
+		result.Status = "error"
+	case code != exitOK:
+		result.Status = "warnings"
+	}
+	if result.Outputs == nil {
+		result.Outputs = []string{}
+	}
+	for _, n := range counters.excludedBy {
+		result.Excluded += n
+	}
+	line, err := json.Marshal(result)
+	if err == nil {
+		fmt.Fprintf(resultFile, "%s\n", line)
+	}
+	resultFile.Close()
+	resultFile = nil
+}
+
+// finishRun prints the resource report, ends the progress stream, writes the result and exits
+func finishRun(ok bool) {
+	if reportResources {
+		writeResourceReport(os.Stderr)
+	}
+	finishProgress()
+	code := runExitCode(ok)
+	writeResult(code, "")
+	appendUsageLog(code)
+	exitProcess(code)
+}
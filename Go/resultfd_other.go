@@ -0,0 +1,7 @@
+//go:build !unix
+
+package main
+
+// openResultFD does nothing: Windows has no inheritable numbered descriptors beyond
+// the standard three, so --result-json-fd is accepted and ignored there
+func openResultFD(fd int) {}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// openResultFD checks that --result-json-fd names an open, writable descriptor
+func openResultFD(fd int) {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFL, 0)
+	if errno != 0 {
+		usageExit(fmt.Sprintf("--result-json-fd %d is not an open file descriptor: %v", fd, errno))
+	}
+	if int(flags)&syscall.O_ACCMODE == syscall.O_RDONLY {
+		usageExit(fmt.Sprintf("--result-json-fd %d is not open for writing", fd))
+	}
+	resultFile = os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The --resume state file is a journal of JSON lines: a header naming the input
+// directory and the options the lines depend on, then one line per directory whose
+// section of the tree was rendered completely, with the byte range of its lines in
+// the spill file next to it and what its entries added to the counters. The spill
+// holds every line the run rendered, in order. Both files only grow while a run
+// lasts, and a line is journaled only once the spill holds its section, so a run
+// killed at any point leaves every journaled section intact. A run given the same
+// state file and options takes those directories from the spill instead of walking
+// them again, and journals on into the same files; one that renders the whole tree
+// removes them.
+var resumeState string // --resume: checkpoint the walk to this file and pick an interrupted run up from it
+
+const resumeVersion = 1 // Version of the journal, in its header
+
+// resumeNeutral are the options that change where the tree goes or what a run reports
+// while it goes, not its lines, so a resumed run may set them differently
+var resumeNeutral = map[string]bool{
+	"resume": true, "d": true, "o": true, "stdout": true, "copy": true, "quiet": true, "progress": true,
+	"progress-json": true, "timeout": true, "jobs": true, "jobs-min": true, "jobs-max": true, "report-resources": true,
+}
+
+// resumeHeader is the first line of the journal
+type resumeHeader struct {
+	Version int               `json:"version"`
+	Root    string            `json:"root"`    // Absolute input directory
+	Options map[string]string `json:"options"` // Every option set but resumeNeutral, by long name
+	Width   int               `json:"width"`   // Column lines were wrapped at, 0 for none
+	Color   bool              `json:"color"`   // Lines carry ANSI colors
+}
+
+// resumeSection is a directory a run rendered completely
+type resumeSection struct {
+	Path   string       `json:"path"`   // From the input directory, with slashes
+	Prefix string       `json:"prefix"` // The prefix of its line and whether it was the last of its
+	Last   bool         `json:"last"`   // directory: one whose neighbours changed draws its lines differently
+	Start  int64        `json:"start"`  // Byte range of its lines in the spill
+	End    int64        `json:"end"`
+	Counts resumeCounts `json:"counts"` // What its entries added, which a run taking it adds in turn
+}
+
+// resumeCounts are the counters and records of the summary that a section's entries
+// add to. Directories that could not be read are reported by name, so a section with
+// any is not journaled and is walked again.
+type resumeCounts struct {
+	ExcludedBy  map[string]int    `json:"excludedBy,omitempty"`
+	IgnoreFiles map[string]string `json:"ignoreFiles,omitempty"` // Ignore files read, by shown path: their digests
+	Readable    int               `json:"readable"`
+	Special     int               `json:"special"`
+	Dirs        int               `json:"dirs"`
+	Files       int               `json:"files"`
+	Bytes       int64             `json:"bytes"`
+	TextDirs    int               `json:"textDirs"`
+	TextFiles   int               `json:"textFiles"`
+	Redacted    int               `json:"redacted"`
+	InFlux      int               `json:"inFlux"`
+	failed      int               // Directories that could not be read or vanished
+}
+
+// resumeRun is the --resume state of a run
+type resumeRun struct {
+	location string
+	done     map[string]resumeSection // Sections journaled by earlier runs, by path
+	journal  *os.File
+	spill    *os.File
+	buffered *bufio.Writer // Lines on their way into the spill
+	spillEnd int64         // Size of the spill with the buffered lines: where the next line goes
+	open     []openSection // Directories whose lines are being rendered, innermost last
+	err      error         // Why checkpointing stopped
+}
+
+// openSection is a directory being rendered, with the counts from before its entries
+type openSection struct {
+	resumeSection
+	before resumeCounts
+}
+
+var resumed *resumeRun // The --resume state of the run, nil without the flag
+
+// spillPath names the spill file of a state file
+func spillPath(location string) string {
+	return location + ".spill"
+}
+
+// checkResume refuses --resume where a run walks or reports on more than the
+// directories it renders, which a resumed run takes from the spill unwalked
+func checkResume(subcommand bool) {
+	if subcommand {
+		usageExit("--resume only applies to the tree, not to ftg estimate, daemon, conform, diff or explain")
+	}
+	if outputFormat != formatMarkdown && outputFormat != formatText {
+		usageExit("--resume is only available with -f md and -f text")
+	}
+	for _, refused := range []archiveRefusal{
+		{groupBy != "", "--group-by"}, {overviewDepth > 0, "--overview-depth"}, {scanBudget > 0, "--budget"},
+		{sidecarJSON, "--sidecar"}, {checkOutputs, "--check"}, {selfCheck, "--self-check"}, {verifyRenderers, "--verify-renderers"},
+		{dedupeSubtrees, "--dedupe-subtrees"}, {flagSet("dedupe-mounts") && dedupeMounts, "--dedupe-mounts"},
+		{usageEnabled, "--usage-by"}, {nameStatsEnabled, "--name-stats"}, {anomaliesEnabled, "--anomalies"},
+		{securityReport, "--security-report"}, {ownerBoundaries, "--owner-boundaries"}, {len(retentionSpecs) > 0, "--simulate-retention"},
+		{findOrphans, "--find-orphans"}, {preserveAnnotations, "--preserve-annotations"}, {checkLinks != "", "--check-links"},
+		{provenanceFlag, "--provenance"}, {explainExcludes, "--explain-excludes"}, {lintFilters, "--lint-filters"},
+	} {
+		if refused.set {
+			usageExit(fmt.Sprintf("%s cannot be combined with --resume, which takes the directories an earlier run completed "+
+				"from its spill without walking them again", refused.name))
+		}
+	}
+}
+
+// openResume opens the state file of --resume, or starts it. A state file of another
+// input directory, or written with other options, is refused. The journal is cut
+// back to its last complete line, which a killed run may have left half written.
+func openResume(location string) *resumeRun {
+	root, err := filepath.Abs(scan.Root)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot resolve the input directory: %v", err))
+	}
+	header := resumeHeader{Version: resumeVersion, Root: root, Options: resumeOptions(), Width: wrapColumns}
+	_, header.Color = painter.(ansiPainter)
+	journal, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot open the --resume state: %v", err))
+	}
+	spill, err := os.OpenFile(spillPath(location), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot open the --resume spill: %v", err))
+	}
+	r := &resumeRun{location: location, done: map[string]resumeSection{}, journal: journal, spill: spill, buffered: bufio.NewWriter(spill)}
+	data, err := io.ReadAll(journal)
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read the --resume state: %v", err))
+	}
+	if len(data) == 0 {
+		line, _ := json.Marshal(header)
+		if err := spill.Truncate(0); err != nil {
+			errorExit(fmt.Sprintf("Cannot start the --resume spill: %v", err))
+		}
+		if _, err := journal.Write(append(line, '\n')); err != nil {
+			errorExit(fmt.Sprintf("Cannot start the --resume state: %v", err))
+		}
+		return r
+	}
+	info, err := spill.Stat()
+	if err != nil {
+		errorExit(fmt.Sprintf("Cannot read the --resume spill: %v", err))
+	}
+	r.spillEnd = info.Size()
+	first, rest, _ := bytes.Cut(data, []byte("\n"))
+	var written resumeHeader
+	if err := json.Unmarshal(first, &written); err != nil || written.Version != resumeVersion {
+		errorExit(fmt.Sprintf("%s is not a --resume state file of this version of ftg; delete it to start over", location))
+	}
+	if written.Root != header.Root {
+		usageExit(fmt.Sprintf("--resume %s holds a run of %s, not %s; pass that directory or another state file", location, written.Root, header.Root))
+	}
+	if changed := header.differences(written); len(changed) > 0 {
+		verb := "differ"
+		if len(changed) == 1 {
+			verb = "differs"
+		}
+		usageExit(fmt.Sprintf("--resume %s was written with other options (%s %s); resume with the options of that run, "+
+			"or delete %s and %s to start over", location, strings.Join(changed, ", "), verb, location, spillPath(location)))
+	}
+	complete := int64(len(first) + 1)
+	for len(rest) > 0 {
+		line, more, found := bytes.Cut(rest, []byte("\n"))
+		var section resumeSection
+		if !found || json.Unmarshal(line, &section) != nil || section.Start > section.End || section.End > r.spillEnd {
+			break
+		}
+		r.done[section.Path] = section
+		complete += int64(len(line) + 1)
+		rest = more
+	}
+	if err := journal.Truncate(complete); err != nil {
+		errorExit(fmt.Sprintf("Cannot repair the --resume state: %v", err))
+	}
+	if _, err := journal.Seek(complete, io.SeekStart); err != nil {
+		errorExit(fmt.Sprintf("Cannot repair the --resume state: %v", err))
+	}
+	taken := 0
+	for rel := range r.done {
+		if _, parentDone := r.done[path.Dir(rel)]; !parentDone {
+			taken++
+		}
+	}
+	fmt.Fprintf(messages, "Resuming from %s: %s rendered already\n", location, treeCount(taken, "directory", "directories"))
+	return r
+}
+
+// resumeOptions returns the options of the run that its lines depend on
+func resumeOptions() map[string]string {
+	options := map[string]string{}
+	flag.Visit(func(f *flag.Flag) {
+		if name := canonicalFlag(f.Name); !resumeNeutral[name] {
+			options[name] = f.Value.String()
+		}
+	})
+	return options
+}
+
+// differences names what a run's header sets differently from the journal's
+func (h resumeHeader) differences(written resumeHeader) []string {
+	var changed []string
+	for name, value := range h.Options {
+		if was, ok := written.Options[name]; !ok || was != value {
+			changed = append(changed, flagName(name))
+		}
+	}
+	for name := range written.Options {
+		if _, ok := h.Options[name]; !ok {
+			changed = append(changed, flagName(name))
+		}
+	}
+	sort.Strings(changed)
+	if h.Width != written.Width {
+		changed = append(changed, "the wrap width")
+	}
+	if h.Color != written.Color {
+		changed = append(changed, "color")
+	}
+	return changed
+}
+
+// Write appends rendered lines to the spill
+func (r *resumeRun) Write(p []byte) (int, error) {
+	if r.err == nil {
+		n, err := r.buffered.Write(p)
+		r.spillEnd += int64(n)
+		r.err = err
+	}
+	return len(p), nil
+}
+
+// takes reports whether an earlier run journaled the directory at rel, so the walk
+// need not read it ahead
+func (r *resumeRun) takes(rel string) bool {
+	_, ok := r.done[rel]
+	return ok
+}
+
+// enter is called for a directory the tree descends into, before its line. A section
+// an earlier run journaled with the same prefix and position is written as it was
+// and true returned, for the walk to skip the directory; otherwise the directory's
+// section opens.
+func (r *resumeRun) enter(writer io.Writer, e treeEntry, prefix string) bool {
+	if section, ok := r.done[e.rel]; ok && section.Prefix == prefix && section.Last == e.isLast {
+		lines := make([]byte, section.End-section.Start)
+		if _, err := r.spill.ReadAt(lines, section.Start); err == nil {
+			writer.Write(lines)
+			section.Counts.add()
+			return true
+		}
+	}
+	r.open = append(r.open, openSection{resumeSection{Path: e.rel, Prefix: prefix, Last: e.isLast, Start: r.spillEnd}, currentCounts()})
+	return false
+}
+
+// drop closes the section of a directory that could not be read, unjournaled
+func (r *resumeRun) drop() {
+	r.open = r.open[:len(r.open)-1]
+}
+
+// close journals the section of a directory once its last entry is rendered
+func (r *resumeRun) close() {
+	section := r.open[len(r.open)-1]
+	r.open = r.open[:len(r.open)-1]
+	counts := currentCounts().since(section.before)
+	if counts.failed > 0 || r.err != nil {
+		return
+	}
+	section.End, section.Counts = r.spillEnd, counts
+	line, _ := json.Marshal(section.resumeSection)
+	if r.err = r.buffered.Flush(); r.err == nil {
+		_, r.err = r.journal.Write(append(line, '\n'))
+	}
+	if r.err != nil {
+		warnf("Warning: cannot checkpoint to --resume %s any more: %v", r.location, r.err)
+	}
+}
+
+// closeFiles closes the state file and the spill
+func (r *resumeRun) closeFiles() {
+	r.journal.Close()
+	r.spill.Close()
+}
+
+// finish removes the state file and the spill once the whole tree is rendered
+func (r *resumeRun) finish() {
+	r.closeFiles()
+	os.Remove(spillPath(r.location))
+	os.Remove(r.location)
+}
+
+// currentCounts takes the counts a section adds to
+func currentCounts() resumeCounts {
+	return resumeCounts{
+		ExcludedBy: maps.Clone(counters.excludedBy), IgnoreFiles: maps.Clone(ignoreFileHashes),
+		Readable: counters.readable, Special: counters.special, Dirs: counters.dirs, Files: counters.files, Bytes: counters.bytes,
+		TextDirs: textDirs, TextFiles: textFiles, Redacted: redactedCount, InFlux: inFluxCount,
+		failed: counters.unreadable + counters.vanished,
+	}
+}
+
+// since returns what was added to the counts after before was taken
+func (c resumeCounts) since(before resumeCounts) resumeCounts {
+	added := resumeCounts{
+		Readable: c.Readable - before.Readable, Special: c.Special - before.Special, Dirs: c.Dirs - before.Dirs,
+		Files: c.Files - before.Files, Bytes: c.Bytes - before.Bytes, TextDirs: c.TextDirs - before.TextDirs,
+		TextFiles: c.TextFiles - before.TextFiles, Redacted: c.Redacted - before.Redacted, InFlux: c.InFlux - before.InFlux,
+		failed: c.failed - before.failed,
+	}
+	for pattern, hits := range c.ExcludedBy {
+		if n := hits - before.ExcludedBy[pattern]; n > 0 {
+			if added.ExcludedBy == nil {
+				added.ExcludedBy = map[string]int{}
+			}
+			added.ExcludedBy[pattern] = n
+		}
+	}
+	for name, digest := range c.IgnoreFiles {
+		if _, ok := before.IgnoreFiles[name]; !ok {
+			if added.IgnoreFiles == nil {
+				added.IgnoreFiles = map[string]string{}
+			}
+			added.IgnoreFiles[name] = digest
+		}
+	}
+	return added
+}
+
+// add puts the counts of a section taken from the spill into the run's
+func (c resumeCounts) add() {
+	for pattern, hits := range c.ExcludedBy {
+		counters.excludedBy[pattern] += hits
+	}
+	maps.Copy(ignoreFileHashes, c.IgnoreFiles)
+	counters.readable += c.Readable
+	counters.special += c.Special
+	counters.dirs += c.Dirs
+	counters.files += c.Files
+	counters.bytes += c.Bytes
+	textDirs += c.TextDirs
+	textFiles += c.TextFiles
+	redactedCount += c.Redacted
+	inFluxCount += c.InFlux
+}
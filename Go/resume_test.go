@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// A run cancelled part way journals the directories it completed; the next takes
+// them from the spill without reading them and renders what an uninterrupted run
+// does, summary included, even past a journal line the first left half written
+func TestResumeTakesCompletedDirectories(t *testing.T) {
+	const dirs = 6
+	want := renderFixture(t, syntheticTree(dirs, 2), true, nil)
+	state := filepath.Join(t.TempDir(), "state.ftg")
+	render := func(after int32) (string, int32) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var reads atomic.Int32
+		got := renderFixtureContext(ctx, t, cancellingFS{syntheticTree(dirs, 2), after, &reads, cancel}, true, func() {
+			setOption(t, &walkJobs, 1)
+			setOption(t, &messages, io.Discard)
+			setOption(t, &resumed, openResume(state))
+			t.Cleanup(resumed.closeFiles)
+		})
+		return string(got), reads.Load()
+	}
+	if got, _ := render(8); got != "" {
+		t.Fatalf("the cancelled run rendered\n%s", got)
+	}
+	journal, err := os.ReadFile(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(journal), "\n"); lines < 3 {
+		t.Fatalf("journal of %d lines, want the header and the completed directories:\n%s", lines, journal)
+	}
+	if err := os.WriteFile(state, append(journal, `{"path":"d005","prefix":"","la`...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, reads := render(0)
+	if got != want {
+		t.Errorf("resumed run:\n%s\nuninterrupted run:\n%s", got, want)
+	}
+	// The input directory and two per synthetic directory, less those taken
+	if all := int32(1 + 2*dirs); reads > all-4 {
+		t.Errorf("the resumed run read %d of %d directories, want at least two taken from the spill", reads, all)
+	}
+}
+
+// A state file is only resumed with the options and input directory it was written
+// with, and --resume is refused where the run would report on directories it takes
+// from the spill unwalked
+func TestResumeRefused(t *testing.T) {
+	dir := t.TempDir()
+	src := testtree.Dir(t, "a/b.txt\nc.txt\n")
+	state := filepath.Join(dir, "state.ftg")
+	if _, stderr, code := runFTG(t, dir, "-d", src, "--resume", state, "--timeout", "1ns", "-o", "-"); code != exitCancelled ||
+		!strings.Contains(stderr, "the same command with --resume "+state+" carries on") {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-d", src, "-L", "2"}, "written with other options (--max-depth differs)"},
+		{[]string{"-d", src, "-L", "2", "-e", "x"}, "(--max-depth, -e differ)"},
+		{[]string{"-d", testtree.Dir(t, "a.txt\n")}, "holds a run of " + src},
+		{[]string{"-d", src, "-f", "json"}, "--resume is only available with -f md and -f text"},
+		{[]string{"-d", src, "--usage-by", "ext"}, "--usage-by cannot be combined with --resume"},
+		{[]string{"-d", src, "-d", src}, "--resume cannot be used with several input directories"},
+	} {
+		_, stderr, code := runFTG(t, dir, append(test.args, "--resume", state, "-o", "-")...)
+		if code != exitUsage || !strings.Contains(stderr, test.want) {
+			t.Errorf("%q: exit code %d, %s; want %d and %q", test.args, code, stderr, exitUsage, test.want)
+		}
+	}
+	// A run with the options of the state file's renders the tree and removes it
+	stdout, stderr, code := runFTG(t, dir, "-d", src, "--resume", state, "-o", "-", "--quiet")
+	if code != exitOK || !strings.Contains(stdout, "[F] b.txt") {
+		t.Fatalf("exit code %d: %s%s", code, stdout, stderr)
+	}
+	for _, file := range []string{state, spillPath(state)} {
+		if _, err := os.Stat(file); err == nil {
+			t.Errorf("%s is left after the tree was rendered", file)
+		}
+	}
+}
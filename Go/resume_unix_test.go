@@ -0,0 +1,64 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// A run killed with SIGKILL part way is picked up by the same command with
+// --resume: the directories it completed come from the spill, and the output is
+// byte for byte that of a run that was never interrupted. The walk is held at c,
+// whose .gitignore is a FIFO that blocks -g until the run is killed.
+func TestResumeAfterKill(t *testing.T) {
+	src := testtree.Dir(t, "a/x/one.txt size=3\nb/two.txt size=5\nc/three.txt\nd/four.txt\ntop.txt\n")
+	ignore := filepath.Join(src, "c", ".gitignore")
+	if err := syscall.Mkfifo(ignore, 0o644); err != nil {
+		t.Skipf("no FIFO: %v", err)
+	}
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state.ftg")
+	args := []string{"-d", src, "-g", "--jobs", "1", "--resume", state, "-o", "-"}
+
+	cmd := ftgCommand(t, dir, args...)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		// The header, a/x, a and b
+		if journal, _ := os.ReadFile(state); bytes.Count(journal, []byte("\n")) == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			t.Fatal("a and b were not journaled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	if err := os.Remove(ignore); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ignore, []byte("*.tmp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, stderr, code := runFTG(t, dir, args...)
+	if code != exitOK || !strings.Contains(stderr, "Resuming from "+state+": 2 directories rendered already") {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	want, _, _ := runFTG(t, dir, "-d", src, "-g", "-o", "-")
+	if got != want {
+		t.Errorf("resumed run:\n%s\nuninterrupted run:\n%s", got, want)
+	}
+}
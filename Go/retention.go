@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// retentionCond is one condition of a --simulate-retention policy
+type retentionCond struct {
+	field string        // "age", "size" or "name"
+	op    string        // ">", ">=", "<", "<=" for age and size; "matches" for name
+	age   time.Duration // Compared with the time since the last modification
+	size  int64         // Compared with the file size
+	glob  string        // Pattern with -e syntax, for name
+}
+
+// retentionPolicy is one simulated cleanup rule and what it would remove
+type retentionPolicy struct {
+	spec    string
+	conds   []retentionCond // All must hold for a file to be removed
+	files   int
+	bytes   int64
+	byDir   map[string]int64 // Reclaimed bytes per directory holding the removed files
+	removed map[string]bool  // Relative paths of removed files
+}
+
+// retentionNode is one entry of the tree the simulation walks once and renders per policy
+type retentionNode struct {
+	name     string
+	rel      string
+	isDir    bool
+	size     int64
+	modified time.Time
+	children []*retentionNode
+}
+
+var retentionSpecs stringList // --simulate-retention policies in the order given
+
+// parseRetention parses a policy such as "delete if older than 180d and size > 100MB".
+// Conditions are joined with "and"; each is "older than D", "newer than D",
+// "size OP N" with OP one of > >= < <= and a B/KB/MB/GB/TB size, or "name matches P".
+func parseRetention(spec string) (*retentionPolicy, error) {
+	text := strings.TrimSpace(spec)
+	if lower := strings.ToLower(text); strings.HasPrefix(lower, "delete if ") {
+		text = text[len("delete if "):]
+	}
+	policy := &retentionPolicy{spec: spec, byDir: map[string]int64{}, removed: map[string]bool{}}
+	for _, part := range splitWord(text, "and") {
+		fields := strings.Fields(part)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("cannot parse %q in retention policy %q", part, spec)
+		}
+		var cond retentionCond
+		var err error
+		switch strings.ToLower(fields[0]) {
+		case "older", "newer":
+			if len(fields) != 3 || strings.ToLower(fields[1]) != "than" {
+				return nil, fmt.Errorf("expected %q in retention policy %q", fields[0]+" than DURATION", spec)
+			}
+			cond = retentionCond{field: "age", op: ">"}
+			if strings.ToLower(fields[0]) == "newer" {
+				cond.op = "<"
+			}
+			cond.age, err = parseAge(fields[2])
+		case "size":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("expected \"size OP SIZE\" in retention policy %q", spec)
+			}
+			cond = retentionCond{field: "size", op: fields[1]}
+			switch cond.op {
+			case ">", ">=", "<", "<=":
+			default:
+				return nil, fmt.Errorf("unknown comparison %q in retention policy %q", cond.op, spec)
+			}
+			cond.size, err = parseByteSize(fields[2])
+		case "name":
+			if len(fields) != 3 || strings.ToLower(fields[1]) != "matches" {
+				return nil, fmt.Errorf("expected \"name matches PATTERN\" in retention policy %q", spec)
+			}
+			cond = retentionCond{field: "name", op: "matches", glob: fields[2]}
+		default:
+			return nil, fmt.Errorf("unknown condition %q in retention policy %q (use older than, newer than, size, name matches)", fields[0], spec)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v in retention policy %q", err, spec)
+		}
+		policy.conds = append(policy.conds, cond)
+	}
+	if len(policy.conds) == 0 {
+		return nil, fmt.Errorf("retention policy %q has no conditions", spec)
+	}
+	return policy, nil
+}
+
+// splitWord splits text on a whole word, ignoring case
+func splitWord(text, word string) []string {
+	var parts, current []string
+	for _, field := range strings.Fields(text) {
+		if strings.EqualFold(field, word) {
+			parts = append(parts, strings.Join(current, " "))
+			current = nil
+			continue
+		}
+		current = append(current, field)
+	}
+	return append(parts, strings.Join(current, " "))
+}
+
+// parseAge parses a duration with day (d), week (w) and year (y) units, or any Go duration
+func parseAge(text string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'y': 365 * 24 * time.Hour}
+	if unit, ok := units[text[len(text)-1]]; ok {
+		n, err := strconv.ParseFloat(text[:len(text)-1], 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", text)
+	}
+	return d, nil
+}
+
+// parseByteSize parses a size such as 100MB, 10K, 5MiB or 4096; units are powers of
+// 1024 like formatSize, and a bare number is bytes
+func parseByteSize(text string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(text))
+	multiplier := int64(1)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		for _, suffix := range []string{unit + "IB", unit + "B", unit} {
+			if strings.HasSuffix(number, suffix) {
+				multiplier = int64(1) << (10 * (i + 1))
+				number = strings.TrimSuffix(number, suffix)
+				break
+			}
+		}
+		if multiplier > 1 {
+			break
+		}
+	}
+	if multiplier == 1 {
+		number = strings.TrimSuffix(number, "B")
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// matches reports whether a file meets every condition of the policy
+func (p *retentionPolicy) matches(node *retentionNode, now time.Time) bool {
+	for _, cond := range p.conds {
+		var ok bool
+		switch cond.field {
+		case "age":
+			ok = compare(int64(now.Sub(node.modified)), cond.op, int64(cond.age))
+		case "size":
+			ok = compare(node.size, cond.op, cond.size)
+		case "name":
+			ok = ftree.MatchPattern(cond.glob, matchPath(node.rel), node.name)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compare applies a comparison operator
+func compare(a int64, op string, b int64) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// collectRetentionTree reads the tree below dir with the walk's filters, once for all policies.
+// Listings are read directly: the simulation must not count as walk progress.
+func collectRetentionTree(dir string) []*retentionNode {
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil
+	}
+	var nodes []*retentionNode
+	for _, entry := range visibleEntries(dir, entries) {
+		if shouldExclude(dir, entry) {
+			continue
+		}
+		fullPath := filepath.Join(dir, entry.Name())
+		node := &retentionNode{name: entry.Name(), rel: relativePath(dir, entry.Name()), isDir: entry.IsDir()}
+		if shouldDescend(fullPath, entry) {
+			node.isDir = true
+			node.children = collectRetentionTree(fullPath)
+		} else if info, err := entryInfo(entry); err == nil && info.Mode().IsRegular() {
+			node.size, node.modified = info.Size(), info.ModTime()
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// simulate evaluates the policy against every regular file of the tree
+func (p *retentionPolicy) simulate(nodes []*retentionNode, now time.Time) {
+	for _, node := range nodes {
+		if node.isDir {
+			p.simulate(node.children, now)
+			continue
+		}
+		if node.modified.IsZero() || !p.matches(node, now) {
+			continue
+		}
+		p.files++
+		p.bytes += node.size
+		p.byDir[path.Dir(node.rel)] += node.size
+		p.removed[node.rel] = true
+	}
+}
+
+// writeRetention appends the retention simulation: a comparison of the policies, then
+// per policy the reclaimed space by directory and the tree with removed files struck through
+func writeRetention(writer io.Writer, root string) {
+	nodes := collectRetentionTree(root)
+	now := scanStarted
+	var policies []*retentionPolicy
+	for _, spec := range retentionSpecs {
+		policy, err := parseRetention(spec)
+		if err != nil {
+			// Validated before the walk
+			continue
+		}
+		policy.simulate(nodes, now)
+		policies = append(policies, policy)
+	}
+
+	fmt.Fprintf(writer, "\n%s\n\n%s\n\n", msg("retention.heading"), msg("retention.intro"))
+	fmt.Fprintf(writer, "%s\n| --- | ---: | ---: |\n", msg("retention.table"))
+	for _, policy := range policies {
+		fmt.Fprintf(writer, "| %s | %s | %s |\n", policy.spec, groupThousands(policy.files), formatSize(policy.bytes))
+	}
+	for _, policy := range policies {
+		fmt.Fprintf(writer, "\n### %s\n", policy.spec)
+		if policy.files == 0 {
+			fmt.Fprintf(writer, "\n%s\n", msg("retention.none"))
+			continue
+		}
+		dirs := make([]string, 0, len(policy.byDir))
+		for dir := range policy.byDir {
+			dirs = append(dirs, dir)
+		}
+		sort.Slice(dirs, func(i, j int) bool {
+			if policy.byDir[dirs[i]] != policy.byDir[dirs[j]] {
+				return policy.byDir[dirs[i]] > policy.byDir[dirs[j]]
+			}
+			return dirs[i] < dirs[j]
+		})
+		fmt.Fprintf(writer, "\n%s\n| --- | ---: |\n", msg("retention.dirTable"))
+		for _, dir := range dirs {
+			fmt.Fprintf(writer, "| %s | %s |\n", redactPath(displayPath(dir)), formatSize(policy.byDir[dir]))
+		}
+		fmt.Fprintln(writer, "\n```sh")
+		policy.writeTree(writer, nodes, "")
+		fmt.Fprintln(writer, "```")
+	}
+}
+
+// writeTree renders the simulated tree, striking through the files the policy removes
+func (p *retentionPolicy) writeTree(writer io.Writer, nodes []*retentionNode, prefix string) {
+	for i, node := range nodes {
+		isLast := i == len(nodes)-1
+		label, _ := displayName(node.name)
+		entryType, class := "F", classFile
+		if node.isDir {
+			entryType, class = "D", classDir
+		}
+		if p.removed[node.rel] {
+			label = strikeThrough(label) + " (" + formatSize(node.size) + ")"
+		}
+		printEntry(writer, painter, label, entryType, class, prefix, isLast, nil)
+		if node.isDir {
+			p.writeTree(writer, node.children, connectors.Indent(prefix, isLast))
+		}
+	}
+}
+
+// strikeThrough overlays a combining long stroke on every character, which stays
+// visible inside code blocks where markdown ~~ has no effect
+func strikeThrough(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(r)
+		b.WriteRune('̶')
+	}
+	return b.String()
+}
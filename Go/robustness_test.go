@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/faultfs"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// robustFormats are the formats the robustness tests render; each walks the tree its
+// own way or through walkTree
+var robustFormats = []string{formatMarkdown, formatText, formatJSON, formatCSV, formatHTML, formatMarkdownList, formatMermaid}
+
+// captureWarnings collects what warnf logs for the rest of the test
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	writer, flags := log.Writer(), log.Flags()
+	log.SetOutput(&logs)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+	return &logs
+}
+
+// renderFaulty renders fsys like renderFixture, failing the test with the faults
+// in play when the render panics
+func renderFaulty(t *testing.T, fsys fs.FS, faults map[string]faultfs.Fault, configure func()) (out string) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panic with faults %v: %v", faults, r)
+		}
+	}()
+	return renderFixture(t, fsys, true, configure)
+}
+
+// Each fault ends in a warning naming the path, or, for short reads and faults the
+// walk never meets, in the output it would have without the fault
+func TestFaultWarnings(t *testing.T) {
+	tests := []struct {
+		path  string
+		fault faultfs.Fault
+		want  string // Warning, "" for none and the output unchanged
+	}{
+		{"src/util", faultfs.Permission, "Cannot read directory fixture/src/util: open src/util: permission denied"},
+		{"src/util", faultfs.Vanished, "fixture/src/util was removed during the scan"},
+		{"src/util", faultfs.BecameFile, "fixture/src/util changed from a directory to a file during the scan"},
+		{"src/util", faultfs.EmptyName, `Skipping entry with invalid name "" in fixture/src/util`},
+		{"src/util", faultfs.ShortReads, ""},
+		{"src/main.go", faultfs.Vanished, "fixture/src/main.go was removed during the scan"},
+		{"src/main.go", faultfs.InfoError, "Cannot read fixture/src/main.go: lstat main.go: input/output error"},
+		{"src/main.go", faultfs.ShortReads, ""},
+		{"src/main.go", faultfs.Permission, ""},
+	}
+	for _, format := range robustFormats {
+		clean := renderFixture(t, testtree.MapFS(t, fixture), true, func() { setOption(t, &outputFormat, format) })
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s %v %s", format, test.fault, test.path), func(t *testing.T) {
+				logs := captureWarnings(t)
+				faults := map[string]faultfs.Fault{test.path: test.fault}
+				got := renderFaulty(t, faultfs.New(testtree.MapFS(t, fixture), faults), faults, func() {
+					setOption(t, &outputFormat, format)
+				})
+				warnings := strings.TrimSpace(logs.String())
+				switch {
+				case test.want == "" && warnings != "":
+					t.Errorf("warned %q", warnings)
+				case test.want == "" && got != clean:
+					t.Errorf("output changed without a warning:\n%s\nwant\n%s", got, clean)
+				case test.want != "" && warnings != test.want:
+					t.Errorf("warned %q, want %q", warnings, test.want)
+				}
+			})
+		}
+	}
+}
+
+// robustTree is a tree of nested directories with files of every depth, none of them
+// hidden by the default exclusions
+func robustTree() fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for a := range 4 {
+		for b := range 3 {
+			fsys[fmt.Sprintf("a%d/f.txt", a)] = &fstest.MapFile{Data: []byte("top")}
+			for c := range 3 {
+				fsys[fmt.Sprintf("a%d/b%d/c%d.txt", a, b, c)] = &fstest.MapFile{Data: []byte("leaf")}
+			}
+			fsys[fmt.Sprintf("a%d/b%d/deep/x.go", a, b)] = &fstest.MapFile{Data: []byte("package x\n")}
+		}
+	}
+	return fsys
+}
+
+// reached reports whether the walk meets the fault at p: no directory above it is
+// unreadable or gone
+func reached(faults map[string]faultfs.Fault, p string) bool {
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		switch faults[dir] {
+		case faultfs.Permission, faultfs.Vanished, faultfs.BecameFile:
+			return false
+		}
+	}
+	return true
+}
+
+// warnedAbout reports whether a line of warnings names p itself, not only something
+// below it
+func warnedAbout(warnings, p string) bool {
+	for line := range strings.Lines(warnings) {
+		for rest := line; ; {
+			i := strings.Index(rest, p)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(p):]
+			if rest == "" || strings.ContainsAny(rest[:1], " :\n") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Random faults across the tree, in every format and with and without the --jobs
+// prefetch, never panic, and each one the walk meets that changes what it can show
+// is warned about with its path
+func TestRandomFaults(t *testing.T) {
+	for seed := range uint64(40) {
+		format := robustFormats[seed%uint64(len(robustFormats))]
+		jobs := 1 + int(seed%2)
+		t.Run(fmt.Sprintf("seed %d %s jobs %d", seed, format, jobs), func(t *testing.T) {
+			fsys, faults, err := faultfs.Random(robustTree(), seed, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			logs := captureWarnings(t)
+			renderFaulty(t, fsys, faults, func() {
+				setOption(t, &outputFormat, format)
+				setOption(t, &walkJobs, jobs)
+			})
+			warnings := logs.String()
+			for p, fault := range faults {
+				if !reached(faults, p) {
+					continue
+				}
+				isDir := !strings.Contains(path.Base(p), ".")
+				switch {
+				case isDir && (fault == faultfs.InfoError || fault == faultfs.ShortReads):
+				case !isDir && (fault == faultfs.Permission || fault == faultfs.ShortReads):
+				case !warnedAbout(warnings, "fixture/"+p):
+					t.Errorf("no warning for %v at %s; warnings:\n%s", fault, p, warnings)
+				}
+			}
+		})
+	}
+}
+
+// A directory that cannot be listed gets a marker in its place and a row in the list
+// of unreadable directories, the rest of the tree is rendered, and the run ends with
+// the exit code for warnings
+func TestUnreadableDirectoryMarker(t *testing.T) {
+	captureWarnings(t)
+	faults := map[string]faultfs.Fault{"src/util": faultfs.Permission}
+	got := renderFaulty(t, faultfs.New(testtree.MapFS(t, fixture), faults), faults, func() {
+		setOption(t, &progress.warnings, 0)
+		setOption(t, &outputTruncated, false)
+	})
+	for _, want := range []string{
+		"    ├── [F] main.go\n    └── [D] util\n        └── [error: permission denied]\n",
+		"## Unreadable directories\n\n- `src/util`: permission denied\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+		}
+	}
+	if code := runExitCode(true); code != exitWarnings {
+		t.Errorf("exit code %d, want %d", code, exitWarnings)
+	}
+}
+
+// The same through the command, on a directory whose permissions are stripped
+func TestUnreadableDirectoryExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("permission bits do not stop this user reading a directory")
+	}
+	src := testtree.Dir(t, "locked/a.txt\nopen.txt\n")
+	locked := filepath.Join(src, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0o755) })
+	stdout, stderr, code := runFTG(t, t.TempDir(), "-d", src, "-o", "-")
+	if code != exitWarnings || !strings.Contains(stdout, "├── [D] locked\n│   └── [error: permission denied]\n└── [F] open.txt\n") {
+		t.Errorf("exit code %d, %s\n%s", code, stderr, stdout)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	rootLabel = "auto" // --root-label: how headers name the input directory: auto, abs, rel or none
+	fullPaths bool     // --full-paths: show each entry as its path from the input directory
+	osPaths   bool     // --os-paths: keep the separators of the OS in shown paths instead of /
+)
+
+// shownRoot returns the input directory as the headers and root lines name it: the
+// path as given, with . resolved to the absolute path for auto, absolute for abs,
+// relative to the working directory for rel, and . for none
+func shownRoot(root string) string {
+	label := root
+	abs, err := filepath.Abs(root)
+	switch {
+	case rootLabel == "none":
+		return "."
+	case err != nil:
+	case rootLabel == "abs", rootLabel == "auto" && filepath.Clean(root) == ".":
+		label = abs
+	case rootLabel == "rel":
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil {
+				label = rel
+			}
+		}
+	}
+	return outputPath(redactPath(virtualPath(label)))
+}
+
+// headerTitle returns the title line of the markdown reports
+func headerTitle(root string) string {
+	if rootLabel == "none" {
+		return msg("header.titleBare")
+	}
+	return msg("header.title", shownRoot(root))
+}
+
+// outputPath returns a shown path with forward slashes, or with --os-paths the
+// separators of the OS
+func outputPath(p string) string {
+	if osPaths {
+		return filepath.FromSlash(p)
+	}
+	return filepath.ToSlash(p)
+}
+
+// entryName returns the name an entry is shown by and whether the name itself was
+// redacted: the name, or with --full-paths its path from the input directory (or
+// --relative-to) with every segment escaped and redacted like a name
+func entryName(dir, name string) (string, bool) {
+	if !fullPaths {
+		return displayName(name)
+	}
+	segments := strings.Split(displayPath(relativePath(dir, name)), "/")
+	redacted := false
+	for i, segment := range segments {
+		if segment != "" {
+			segments[i], redacted = displayName(segment)
+		}
+	}
+	return outputPath(strings.Join(segments, "/")), redacted
+}
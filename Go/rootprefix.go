@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// rootPrefix makes a mounted volume look like the filesystem root, for scanning from
+// a container: "/scan/etc" is shown as "/etc", absolute --relative-to values are read
+// inside it, and the virtual filesystem rules for "/" apply to the prefix itself.
+// -d still takes a real path. Build with CGO_ENABLED=0 for a static binary that runs in
+// scratch images: owners then come from /etc/passwd when present and degrade to numeric IDs.
+var rootPrefix string
+
+// setRootPrefix validates and normalizes --root-prefix
+func setRootPrefix(prefix string) {
+	abs, err := filepath.Abs(prefix)
+	if err != nil {
+		errorExit("Cannot resolve --root-prefix " + prefix)
+	}
+	rootPrefix = filepath.Clean(abs)
+}
+
+// virtualPath returns a real path as seen from inside --root-prefix
+func virtualPath(p string) string {
+	if rootPrefix == "" {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	rel, err := filepath.Rel(rootPrefix, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+	if rel == "." {
+		return "/"
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// realPath maps an absolute path given inside --root-prefix to the host path
+func realPath(p string) string {
+	if rootPrefix == "" || !filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(rootPrefix, p)
+}
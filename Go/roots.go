@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+var inputRoots []string // -d values, in the order given; more than one renders a section per root
+
+// rootsValue is the -d flag: repeatable, and a value naming no existing path is split at commas
+type rootsValue struct{}
+
+// String returns the roots given so far
+func (rootsValue) String() string {
+	return strings.Join(inputRoots, ",")
+}
+
+// Set adds one root, or several for "api,web,infra". A path that exists is taken
+// whole, so a directory with a comma in its name still works.
+func (rootsValue) Set(value string) error {
+	roots := []string{value}
+	if _, err := os.Stat(value); err != nil && strings.Contains(value, ",") {
+		roots = nil
+		for _, root := range strings.Split(value, ",") {
+			if root = strings.TrimSpace(root); root != "" {
+				roots = append(roots, root)
+			}
+		}
+	}
+	inputRoots = append(inputRoots, roots...)
+	if len(inputRoots) > 0 {
+		scan.Root = inputRoots[0]
+	}
+	return nil
+}
+
+// multiRoot reports whether several input directories are rendered in one run
+func multiRoot() bool {
+	return len(inputRoots) > 1
+}
+
+// checkRoots validates the input directories of a multi-root run before anything is
+// read or written: each must be a directory, and options tied to a single root are
+// refused. The subcommands name what they refuse themselves.
+func checkRoots(refusals []archiveRefusal) {
+	for _, refused := range refusals {
+		if refused.set {
+			usageExit(fmt.Sprintf("%s cannot be used with several input directories", refused.name))
+		}
+	}
+	if outputFormat != formatMarkdown && outputFormat != formatText {
+		usageExit(fmt.Sprintf("several input directories are rendered as -f md or text, not %s", outputFormat))
+	}
+	for i, root := range inputRoots {
+		root = normalizeInput(root)
+		info, err := os.Stat(root)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			errorExit(fmt.Sprintf("Input directory %s does not exist", root))
+		case err != nil:
+			errorExit(fmt.Sprintf("Cannot read input directory %s: %v", root, err))
+		case !info.IsDir():
+			errorExit(fmt.Sprintf("Input directory %s is not a directory", root))
+		}
+		inputRoots[i] = root
+	}
+	scan.Root = inputRoots[0]
+}
+
+// renderRoots renders every input directory as one output. A single root renders as
+// it always has; several are rendered one after another, in the order given, each
+// as its own section with its own summary, under one header and followed by the
+// totals over all of them.
+func renderRoots(ctx context.Context, bare bool) ([]byte, error) {
+	if !multiRoot() {
+		return renderRun(ctx, bare)
+	}
+	markdown := outputFormat == formatMarkdown
+	var output bytes.Buffer
+	if markdown && !bare && injectFile == "" {
+		names := make([]string, len(inputRoots))
+		for i, root := range inputRoots {
+			names[i] = redactPath(virtualPath(root))
+		}
+		fmt.Fprintf(&output, "%s\n\n%s\n", msg("header.title", strings.Join(names, ", ")), msg("header.star", repository))
+	}
+	var total scanCounters
+	excluded, dirs, files := 0, 0, 0
+	for i, root := range inputRoots {
+		if i > 0 {
+			resetWalkState()
+		}
+		scan.Root = root
+		if markdown {
+			fmt.Fprintf(&output, "\n%s\n", msg("roots.section", redactPath(virtualPath(root))))
+		} else if i > 0 {
+			output.WriteString("\n")
+		}
+		data, err := renderRun(ctx, bare)
+		if err != nil || ctx.Err() != nil {
+			return nil, err
+		}
+		output.Write(data)
+		total.dirs += counters.dirs
+		total.files += counters.files
+		total.bytes += counters.bytes
+		excluded += excludedTotal()
+		dirs, files = dirs+textDirs, files+textFiles
+	}
+	scan.Root = inputRoots[0]
+
+	switch {
+	case !markdown && scan.DirsOnly:
+		fmt.Fprintf(&output, "\ntotal: %s\n", treeCount(dirs, "directory", "directories"))
+	case !markdown:
+		fmt.Fprintf(&output, "\ntotal: %s, %s\n", treeCount(dirs, "directory", "directories"), treeCount(files, "file", "files"))
+	case !noSummary:
+		fmt.Fprintf(&output, "\n%s\n", msg("roots.total", len(inputRoots), msgCount("count.dir", total.dirs), msgCount("count.file", total.files),
+			formatTotal(total.bytes), groupThousands(excluded)))
+	}
+	return output.Bytes(), nil
+}
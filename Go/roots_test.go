@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// rootsFixture is a workspace of three roots, one of them with a comma in its name
+const rootsFixture = `
+api/main.go
+web/index.html
+web/src/app.js
+"a,b/c.txt"
+`
+
+// Several -d roots, repeated or comma-separated, render as one output: a header
+// naming them all, a section per root in the order given with its own summary, and
+// a combined total
+func TestMultipleRoots(t *testing.T) {
+	dir := testtree.Dir(t, rootsFixture)
+	for _, args := range [][]string{{"-d", "web", "-d", "api"}, {"-d", "web,api"}} {
+		if _, stderr, code := runFTG(t, dir, append(args, "-o", "tree.md", "--force", "--quiet")...); code != exitOK {
+			t.Fatalf("%q: exit code %d, %s", args, code, stderr)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "tree.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := string(data)
+		want := "# File Tree for web, api\n\n" +
+			"## Give the project a star at https://github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/tree/main/Go\n\n" +
+			"## web\n```sh\n├── [F] index.html\n└── [D] src\n    └── [F] app.js\n```\n\n" +
+			"Summary: 1 directory, 2 files, 0 B in total, 0 excluded\n"
+		if !strings.HasPrefix(got, want) {
+			t.Errorf("%q: got\n%s\nwant it to start with\n%s", args, got, want)
+		}
+		web, api := strings.Index(got, "\n## web\n"), strings.Index(got, "\n## api\n")
+		if web < 0 || api < web || !strings.Contains(got[api:], "```sh\n└── [F] main.go\n```\n\nSummary: 0 directories, 1 file, 0 B in total, 0 excluded\n") {
+			t.Errorf("%q: no api section after web:\n%s", args, got)
+		}
+		if !strings.Contains(got, "\nTotal over 2 directories: 1 directory, 3 files, 0 B in total, 0 excluded\n") {
+			t.Errorf("%q: no combined total:\n%s", args, got)
+		}
+	}
+
+	stdout, _, _ := runFTG(t, dir, "-d", "api", "-d", "a,b", "-d", "web", "-f", "text", "-o", "-", "--quiet")
+	want := "api\n└── main.go\n\n0 directories, 1 file\n\n" +
+		"a,b\n└── c.txt\n\n0 directories, 1 file\n\n" +
+		"web\n├── index.html\n└── src\n    └── app.js\n\n1 directory, 2 files\n\n" +
+		"total: 1 directory, 4 files\n"
+	if stdout != want {
+		t.Errorf("-f text, a root with a comma taken whole: got\n%s\nwant\n%s", stdout, want)
+	}
+}
+
+// A missing root, or one that is no directory, ends the run with its path before the
+// output file is created, and formats other than md and text are refused
+func TestMultipleRootsRefused(t *testing.T) {
+	dir := testtree.Dir(t, rootsFixture)
+	for _, test := range []struct {
+		root, message string
+	}{
+		{"nope", "Input directory nope does not exist"},
+		{"api/main.go", "Input directory api/main.go is not a directory"},
+	} {
+		_, stderr, code := runFTG(t, dir, "-d", "web", "-d", test.root, "-o", "tree.md")
+		if code != exitFatal || !strings.Contains(stderr, test.message) {
+			t.Errorf("%s: exit code %d, %s", test.root, code, stderr)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "tree.md")); err == nil {
+			t.Errorf("%s: the output file was created", test.root)
+		}
+	}
+	_, stderr, code := runFTG(t, dir, "-d", "web", "-d", "api", "-f", "json", "-o", "-")
+	if code != exitUsage || !strings.Contains(stderr, "several input directories are rendered as -f md or text, not json") {
+		t.Errorf("-f json: exit code %d, %s", code, stderr)
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import "syscall"
+
+// peakRSS returns the process's maximum resident set size in bytes
+func peakRSS() (uint64, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+	// Linux reports kilobytes
+	return uint64(usage.Maxrss) * 1024, true
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// peakRSS reports that the peak resident set size is unavailable on this platform
+func peakRSS() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// Rule sources, listed in the default precedence order of --rules-order
+const (
+	sourceCLI         = "cli"         // -e, --exclude-from, -i and --options-from
+	sourceIgnoreFiles = "ignorefiles" // .gitignore with -g, .gitattributes with --export-ignore, .dockerignore
+	sourcePresets     = "presets"     // Named rule sets of --profile
+	sourceDefaults    = "defaults"    // The common exclusions added to every run
+)
+
+// excludeRule is one exclusion, or with a leading "!" an inclusion
+type excludeRule struct {
+	pattern  string // Pattern as matched, without the "!"
+	include  bool   // "!pattern" shows what lower-priority sources exclude
+	origin   string // Where the rule came from, e.g. "user (-e)" or ".gitignore:3 (build/)"
+	fromFile bool   // Read from an ignore file, so counted per file and line
+}
+
+// ignoreFileOrigin is the source recorded for hits of ignore-file rules
+const ignoreFileOrigin = "ignore file"
+
+// label is the key the rule's hits are counted under
+func (r excludeRule) label() string {
+	if r.fromFile {
+		return r.origin
+	}
+	if r.include {
+		return "!" + r.pattern
+	}
+	return r.pattern
+}
+
+// describe names the rule and where it came from, for ftg explain
+func (r excludeRule) describe() string {
+	if r.fromFile {
+		return r.origin
+	}
+	return fmt.Sprintf("pattern %q (%s)", r.label(), r.origin)
+}
+
+// ruleLayer holds the rules of one source in the order they were given; the last
+// matching rule of a layer wins, so later -e patterns override earlier ones
+type ruleLayer struct {
+	set   ftree.RuleSet // The patterns, matched in the order given
+	rules []excludeRule // Rule i of set, with where it came from
+}
+
+var (
+	explainExcludes bool                                                                    // Append the rule table with --explain-excludes
+	rulesOrder      = []string{sourceCLI, sourceIgnoreFiles, sourcePresets, sourceDefaults} // Highest priority first
+	ruleLayers      = map[string]*ruleLayer{}                                               // Pattern rules per source
+)
+
+// layer returns the rules of a source, creating the layer on first use
+func layer(source string) *ruleLayer {
+	if l, ok := ruleLayers[source]; ok {
+		return l
+	}
+	l := &ruleLayer{}
+	ruleLayers[source] = l
+	return l
+}
+
+// addExcludeRule appends a pattern to a source; "!pattern" becomes an inclusion.
+// Separators are normalized with filepath.ToSlash, so packages\legacy\src given on
+// Windows matches the same relative path as packages/legacy/src; on other systems a
+// backslash stays an escape or part of a name, and matching is case-sensitive.
+func addExcludeRule(source, origin, pattern string) {
+	parsed, ok := ftree.ParseRule(filepath.ToSlash(pattern))
+	if !ok {
+		return
+	}
+	rule := excludeRule{pattern: parsed.Pattern, include: parsed.Include, origin: origin}
+	l := layer(source)
+	l.set.Add(parsed)
+	l.rules = append(l.rules, rule)
+	excludeSources[rule.label()] = origin
+}
+
+// loadExcludeFile adds the patterns of an --exclude-from file, one per line, after
+// those of -e. Blank lines and lines starting with "#" are skipped; a CRLF line end
+// and a UTF-8 byte order mark are dropped, so files saved on Windows read the same.
+func loadExcludeFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	digest := sha256.New()
+	digest.Write(data)
+	recordIgnoreFile(file, digest)
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addExcludeRule(sourceCLI, fmt.Sprintf("user (--exclude-from %s:%d)", file, i+1), line)
+	}
+	return nil
+}
+
+// match returns the last rule of the layer that matches the entry
+func (l *ruleLayer) match(rel, name string) (excludeRule, bool) {
+	if i, ok := l.set.Match(matchPath(rel), name); ok {
+		return l.rules[i], true
+	}
+	return excludeRule{}, false
+}
+
+// ignoreFileRule returns the ignore-file rule deciding an entry; the ignore files of
+// its directory must already be loaded with loadIgnoreFiles. Excluded directories are
+// never entered, so, as in git, a file inside one cannot be re-included by a negation.
+func ignoreFileRule(rel string, isDir bool) (excludeRule, bool) {
+	if useDockerignore {
+		if rule, found := dockerignoreRule(rel, isDir); found {
+			return rule, true
+		}
+	}
+	if exportIgnore {
+		if rule, found := exportIgnoreRule(rel); found && rule.set {
+			return excludeRule{pattern: rule.pattern, origin: fmt.Sprintf("%s (%s export-ignore)", displayPath(rule.attributesFile()), rule.pattern), fromFile: true}, true
+		}
+	}
+	if useGitignore {
+		if rule, found := gitignoreRule(rel, isDir); found {
+			return excludeRule{pattern: rule.pattern, include: rule.negate, origin: rule.source(), fromFile: true}, true
+		}
+	}
+	return excludeRule{}, false
+}
+
+// loadIgnoreFiles parses the ignore files of a directory before its entries are decided
+func loadIgnoreFiles(dir string) {
+	if useGitignore {
+		loadGitignore(dir)
+	}
+	if exportIgnore {
+		loadAttributes(dir)
+	}
+}
+
+// decidingRule asks the sources in --rules-order, each an ftree.ExcludeFunc, and
+// returns the first rule that matches the entry, with its priority (1 for the first
+// source). An inclusion that wins keeps the entry even if lower-priority sources
+// would exclude it.
+func decidingRule(rel, name string, isDir bool) (excludeRule, int, bool) {
+	var decided excludeRule
+	sources := make([]ftree.ExcludeFunc, len(rulesOrder))
+	for i, source := range rulesOrder {
+		sources[i] = func(rel string, d fs.DirEntry) ftree.Decision {
+			rule, found := sourceRule(source, rel, d.Name(), d.IsDir())
+			switch {
+			case !found:
+				return ftree.Undecided
+			case rule.include:
+				decided = rule
+				return ftree.Keep
+			}
+			decided = rule
+			return ftree.Hide
+		}
+	}
+	if _, i := ftree.Decide(sources, rel, ruleEntry{name, isDir}); i >= 0 {
+		return decided, i + 1, true
+	}
+	return excludeRule{}, 0, false
+}
+
+// sourceRule returns the rule of one --rules-order source that decides an entry
+func sourceRule(source, rel, name string, isDir bool) (excludeRule, bool) {
+	if source == sourceIgnoreFiles {
+		return ignoreFileRule(rel, isDir)
+	}
+	if l, ok := ruleLayers[source]; ok {
+		return l.match(rel, name)
+	}
+	return excludeRule{}, false
+}
+
+// ruleEntry is the fs.DirEntry the rule sources are asked about: matching only
+// needs the name and whether it is a directory
+type ruleEntry struct {
+	name  string
+	isDir bool
+}
+
+func (e ruleEntry) Name() string { return e.name }
+func (e ruleEntry) IsDir() bool  { return e.isDir }
+
+func (e ruleEntry) Type() fs.FileMode {
+	if e.isDir {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e ruleEntry) Info() (fs.FileInfo, error) {
+	return nil, fs.ErrInvalid
+}
+
+// parseRulesOrder validates --rules-order; sources left out keep their default relative order after the listed ones
+func parseRulesOrder(spec string) error {
+	var order []string
+	seen := map[string]bool{}
+	for _, source := range strings.Split(spec, ",") {
+		source = strings.TrimSpace(source)
+		switch source {
+		case sourceCLI, sourceIgnoreFiles, sourcePresets, sourceDefaults:
+		default:
+			return fmt.Errorf("unknown --rules-order source %q (use cli, ignorefiles, presets, defaults)", source)
+		}
+		if seen[source] {
+			return fmt.Errorf("--rules-order lists %q twice", source)
+		}
+		seen[source] = true
+		order = append(order, source)
+	}
+	for _, source := range rulesOrder {
+		if !seen[source] {
+			order = append(order, source)
+		}
+	}
+	rulesOrder = order
+	return nil
+}
+
+// ruleReportRow is one exclusion rule of the --explain-excludes table
+type ruleReportRow struct {
+	Precedence int    `json:"precedence"` // 1 decides first
+	Source     string `json:"source"`
+	Pattern    string `json:"pattern"`
+	Origin     string `json:"origin"`
+	Hits       int    `json:"hits"`
+}
+
+// ruleReport returns the exclusion rules in evaluation order with their hits
+func ruleReport() []ruleReportRow {
+	rows := []ruleReportRow{}
+	for i, source := range rulesOrder {
+		if source == sourceIgnoreFiles {
+			// Ignore files hold many rules; only the ones that hid something are listed
+			var labels []string
+			for label := range counters.excludedBy {
+				if excludeSources[label] == ignoreFileOrigin {
+					labels = append(labels, label)
+				}
+			}
+			sort.Strings(labels)
+			for _, label := range labels {
+				rows = append(rows, ruleReportRow{i + 1, source, label, ignoreFileOrigin, counters.excludedBy[label]})
+			}
+			continue
+		}
+		l, ok := ruleLayers[source]
+		if !ok {
+			continue
+		}
+		for _, rule := range l.rules {
+			rows = append(rows, ruleReportRow{i + 1, source, rule.label(), rule.origin, counters.excludedBy[rule.label()]})
+		}
+	}
+	// --hidden=hide only applies when no source decided
+	if hiddenMode == hiddenHide {
+		rows = append(rows, ruleReportRow{len(rulesOrder) + 1, "hidden", ".*", "--hidden=hide", counters.excludedBy[hiddenLabel]})
+	}
+	return rows
+}
+
+// writeRuleReport appends the --explain-excludes section: every source in precedence
+// order with its rules and how many entries each one hid
+func writeRuleReport(writer io.Writer) {
+	fmt.Fprintf(writer, "\n%s\n\n%s\n| --- | --- | --- | --- | --- |\n", msg("rules.heading"), msg("rules.table"))
+	for _, row := range ruleReport() {
+		fmt.Fprintf(writer, "| %d | %s | %s | %s | %d |\n", row.Precedence, row.Source, row.Pattern, row.Origin, row.Hits)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Each --rules-order source is an ftree.ExcludeFunc, and the first one that decides
+// an entry wins: an inclusion in a higher source keeps what a lower one excludes
+func TestRulesOrder(t *testing.T) {
+	dir := testtree.Dir(t, `
+.gitignore content="*.log\n"
+a.log
+keep.log
+main.go
+node_modules/x.js
+`)
+	tests := []struct {
+		args []string
+		want string // Entries shown below the root, space-separated
+	}{
+		{[]string{"-g"}, ".gitignore main.go"},
+		{[]string{"-g", "-e", "!a.log"}, ".gitignore a.log main.go"},
+		{[]string{"-g", "-e", "!a.log", "--rules-order", "ignorefiles,cli"}, ".gitignore main.go"},
+		{[]string{"-e", "!node_modules"}, ".gitignore a.log keep.log main.go node_modules x.js"},
+		{[]string{"-e", "!node_modules", "--rules-order", "defaults"}, ".gitignore a.log keep.log main.go"},
+		{[]string{"-e", "*.log,!keep.log"}, ".gitignore keep.log main.go"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, append([]string{"-o", "-", "--no-summary"}, test.args...)...)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			var shown []string
+			for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+				shown = append(shown, line[strings.LastIndex(line, " ")+1:])
+			}
+			if got := strings.Join(shown, " "); got != test.want {
+				t.Errorf("shown %q, want %q", got, test.want)
+			}
+		})
+	}
+}
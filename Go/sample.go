@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// sampleEdge is how many entries --sample keeps from each end of a large directory
+const sampleEdge = 3
+
+var (
+	sampleSize int // Directories with more visible entries than this are sampled; 0 disables
+	maxEntries int // --max-entries: entries shown per directory before the rest collapse into one line; 0 shows all
+)
+
+// sampleEntries keeps only both ends of an already filtered listing when it has more
+// than sampleSize entries. It returns the entries
+// to render, the index before which the elision line goes (-1 for none), and how
+// many entries it stands for. The tail always follows the elision line, so the
+// last-child connector still belongs to a real entry.
+func sampleEntries(entries []fs.DirEntry) ([]fs.DirEntry, int, int) {
+	if sampleSize <= 0 || len(entries) <= sampleSize {
+		return entries, -1, 0
+	}
+	edge := min(sampleEdge, max(1, (sampleSize-1)/2))
+	hidden := len(entries) - 2*edge
+	return append(entries[:edge:edge], entries[len(entries)-edge:]...), edge, hidden
+}
+
+// printElision writes the line standing in for the entries --sample left out
+func printElision(writer io.Writer, prefix string, hidden int) {
+	if _, err := fmt.Fprintf(writer, "%s %s\n", painter.connector(prefix+connectors.Branch), msg("tree.similar", groupThousands(hidden))); err != nil {
+		warnf("Error writing entry: %v", err)
+	}
+}
+
+// truncatesListings reports whether a format stops each directory at --max-entries:
+// the trees drawn line by line do
+func truncatesListings(format string) bool {
+	return maxEntries > 0 && (format == formatMarkdown || format == formatText || format == formatSVG)
+}
+
+// truncateEntries keeps the first maxEntries entries of an already filtered and
+// sorted listing and returns how many it left out
+func truncateEntries(entries []fs.DirEntry) ([]fs.DirEntry, int) {
+	if maxEntries <= 0 || len(entries) <= maxEntries {
+		return entries, 0
+	}
+	return entries[:maxEntries], len(entries) - maxEntries
+}
+
+// printMore writes the line counting the entries --max-entries left out, the last
+// line of the directory unless a read error follows it
+func printMore(writer io.Writer, prefix string, hidden int, isLast bool) {
+	connector := connectors.Connector(isLast)
+	if _, err := fmt.Fprintf(writer, "%s %s\n", painter.connector(prefix+connector), msgCount("tree.more", hidden)); err != nil {
+		warnf("Error writing entry: %v", err)
+	}
+}
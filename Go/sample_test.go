@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// entriesFixture has four entries in d that are not excluded, the last a directory,
+// besides the excluded node_modules
+const entriesFixture = `
+d/a.txt
+d/b.txt
+d/c.txt
+d/node_modules/x.js
+d/sub/inner.txt
+top.txt
+`
+
+// --max-entries below, at and above the size of d: a cut directory ends in one line
+// with the last connector counting the entries left that are not excluded, printed
+// directories are still walked, and only a cut run ends with exitTruncated
+func TestMaxEntries(t *testing.T) {
+	const whole = "├── [D] d\n" +
+		"│   ├── [F] a.txt\n" +
+		"│   ├── [F] b.txt\n" +
+		"│   ├── [F] c.txt\n" +
+		"│   └── [D] sub\n" +
+		"│       └── [F] inner.txt\n" +
+		"└── [F] top.txt\n"
+	tests := []struct {
+		n    int
+		want string
+		code int
+	}{
+		{1, "├── [D] d\n" +
+			"│   ├── [F] a.txt\n" +
+			"│   └── … 3 more entries not shown\n" +
+			"└── … 1 more entry not shown\n", exitTruncated},
+		{3, "├── [D] d\n" +
+			"│   ├── [F] a.txt\n" +
+			"│   ├── [F] b.txt\n" +
+			"│   ├── [F] c.txt\n" +
+			"│   └── … 1 more entry not shown\n" +
+			"└── [F] top.txt\n", exitTruncated},
+		{4, whole, exitOK},
+		{5, whole, exitOK},
+	}
+	for _, test := range tests {
+		got := renderFixture(t, testtree.MapFS(t, entriesFixture), true, func() {
+			setOption(t, &noSummary, true)
+			setOption(t, &maxEntries, test.n)
+			setOption(t, &progress.warnings, 0)
+			setOption(t, &outputTruncated, false)
+		})
+		if got != test.want {
+			t.Errorf("--max-entries %d: got\n%s\nwant\n%s", test.n, got, test.want)
+		}
+		if code := runExitCode(true); code != test.code {
+			t.Errorf("--max-entries %d: exit code %d, want %d", test.n, code, test.code)
+		}
+	}
+}
+
+// The count of entries not shown groups its thousands
+func TestMaxEntriesThousands(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := range 4384 {
+		fsys[fmt.Sprintf("big/%04d.txt", i)] = &fstest.MapFile{}
+	}
+	got := renderFixture(t, fsys, true, func() {
+		setOption(t, &noSummary, true)
+		setOption(t, &maxEntries, 2)
+	})
+	if want := "    ├── [F] 0001.txt\n    └── … 4,382 more entries not shown\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("got\n%s\nwant it to end in\n%s", got, want)
+	}
+}
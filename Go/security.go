@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Severities of --security-report findings, most severe first
+const (
+	severityHigh   = "high"
+	severityMedium = "medium"
+	severityLow    = "low"
+)
+
+// securityFinding is one risky permission found in the tree
+type securityFinding struct {
+	severity string
+	kind     string // Message key of the rule, e.g. "security.finding.setuid"
+	rel      string
+	details  string // Mode, ACL entries or link target
+}
+
+var (
+	securityReport   bool              // --security-report: append the risky permissions found in the tree
+	strictSecurity   bool              // --strict-security: exit with exitFindings on high or medium findings
+	securityFindings []securityFinding // Findings in walk order
+)
+
+// securityRule checks one entry; it returns the severity and details of a finding, or ok false
+type securityRule struct {
+	kind  string // Message key of the finding name
+	check func(fullPath string, info fs.FileInfo) (severity, details string, ok bool)
+}
+
+// securityRules are checked against every entry of the tree in this order. Mode bits
+// mean nothing on Windows, where Go derives them from the read-only attribute, so the
+// mode rules only run elsewhere.
+var securityRules = []securityRule{
+	{"security.finding.worldWritableFile", func(_ string, info fs.FileInfo) (string, string, bool) {
+		mode := info.Mode()
+		if !modeBitsMeaningful() || !mode.IsRegular() || mode.Perm()&0o002 == 0 {
+			return "", "", false
+		}
+		return severityHigh, lsMode(mode), true
+	}},
+	{"security.finding.worldWritableDir", func(_ string, info fs.FileInfo) (string, string, bool) {
+		mode := info.Mode()
+		if !modeBitsMeaningful() || !mode.IsDir() || mode.Perm()&0o002 == 0 {
+			return "", "", false
+		}
+		// With the sticky bit (like /tmp) only owners can remove or rename entries
+		if mode&fs.ModeSticky != 0 {
+			return severityLow, lsMode(mode), true
+		}
+		return severityHigh, lsMode(mode), true
+	}},
+	{"security.finding.setuid", func(_ string, info fs.FileInfo) (string, string, bool) {
+		mode := info.Mode()
+		if !modeBitsMeaningful() || !mode.IsRegular() || mode&fs.ModeSetuid == 0 {
+			return "", "", false
+		}
+		return severityHigh, lsMode(mode) + " owner " + fileOwner(info), true
+	}},
+	{"security.finding.setgid", func(_ string, info fs.FileInfo) (string, string, bool) {
+		mode := info.Mode()
+		if !modeBitsMeaningful() || !mode.IsRegular() || mode&fs.ModeSetgid == 0 {
+			return "", "", false
+		}
+		return severityMedium, lsMode(mode), true
+	}},
+	{"security.finding.extendedACL", func(fullPath string, info fs.FileInfo) (string, string, bool) {
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", "", false
+		}
+		grants := extendedACL(fullPath)
+		if len(grants) == 0 {
+			return "", "", false
+		}
+		return severityMedium, strings.Join(grants, ", "), true
+	}},
+	{"security.finding.symlinkOutside", func(fullPath string, info fs.FileInfo) (string, string, bool) {
+		if info.Mode()&fs.ModeSymlink == 0 {
+			return "", "", false
+		}
+		target, outside := linkLeavesRoot(fullPath)
+		if !outside {
+			return "", "", false
+		}
+		return severityMedium, "-> " + redactPath(target), true
+	}},
+}
+
+// modeBitsMeaningful reports whether permission bits reflect real access rules on this platform
+func modeBitsMeaningful() bool {
+	return runtime.GOOS != "windows"
+}
+
+// lsMode formats a mode the way ls -l does, with s/S and t/T for setuid, setgid and sticky
+func lsMode(mode fs.FileMode) string {
+	out := []byte("-" + mode.Perm().String()[1:])
+	switch {
+	case mode.IsDir():
+		out[0] = 'd'
+	case mode&fs.ModeSymlink != 0:
+		out[0] = 'l'
+	}
+	special := func(i int, set bool, exec, noExec byte) {
+		if !set {
+			return
+		}
+		if out[i] == 'x' {
+			out[i] = exec
+		} else {
+			out[i] = noExec
+		}
+	}
+	special(3, mode&fs.ModeSetuid != 0, 's', 'S')
+	special(6, mode&fs.ModeSetgid != 0, 's', 'S')
+	special(9, mode&fs.ModeSticky != 0, 't', 'T')
+	return string(out)
+}
+
+// recordSecurity runs the security rules against an entry of the tree
+func recordSecurity(dir string, entry fs.DirEntry) {
+	if !securityReport {
+		return
+	}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return
+	}
+	fullPath := filepath.Join(dir, entry.Name())
+	for _, rule := range securityRules {
+		if severity, details, ok := rule.check(fullPath, info); ok {
+			securityFindings = append(securityFindings, securityFinding{severity, rule.kind, relativePath(dir, entry.Name()), details})
+		}
+	}
+}
+
+// linkLeavesRoot resolves a link and reports whether it points outside the input
+// directory; broken links are resolved lexically as far as they go
+func linkLeavesRoot(fullPath string) (string, bool) {
+	target, err := os.Readlink(fullPath)
+	if err != nil {
+		return "", false
+	}
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		resolved = target
+		if !filepath.IsAbs(resolved) {
+			resolved = filepath.Join(filepath.Dir(fullPath), resolved)
+		}
+	}
+	root, err := filepath.EvalSymlinks(scan.Root)
+	if err != nil {
+		root = scan.Root
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return target, true
+	}
+	return target, false
+}
+
+// severityRank orders severities for the report
+func severityRank(severity string) int {
+	switch severity {
+	case severityHigh:
+		return 0
+	case severityMedium:
+		return 1
+	}
+	return 2
+}
+
+// failingSecurityFindings counts the findings --strict-security fails on
+func failingSecurityFindings() int {
+	failing := 0
+	for _, finding := range securityFindings {
+		if finding.severity != severityLow {
+			failing++
+		}
+	}
+	return failing
+}
+
+// writeSecurityReport appends the findings, most severe first, with counts by severity
+func writeSecurityReport(writer io.Writer) {
+	fmt.Fprintf(writer, "\n%s\n\n", msg("security.heading"))
+	if !aclSupported {
+		fmt.Fprintf(writer, "%s\n\n", msg("security.noACL"))
+	}
+	if len(securityFindings) == 0 {
+		fmt.Fprintln(writer, msg("security.none"))
+		return
+	}
+	counts := map[string]int{}
+	for _, finding := range securityFindings {
+		counts[finding.severity]++
+	}
+	fmt.Fprintf(writer, "%s\n\n", msg("security.counts", groupThousands(counts[severityHigh]), groupThousands(counts[severityMedium]), groupThousands(counts[severityLow])))
+
+	findings := append([]securityFinding(nil), securityFindings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		if a, b := severityRank(findings[i].severity), severityRank(findings[j].severity); a != b {
+			return a < b
+		}
+		return findings[i].rel < findings[j].rel
+	})
+	fmt.Fprintf(writer, "%s\n| --- | --- | --- | --- |\n", msg("security.table"))
+	for _, finding := range findings {
+		fmt.Fprintf(writer, "| %s | %s | %s | %s |\n", msg("security.severity."+finding.severity), msg(finding.kind),
+			tableSpan(redactPath(displayPath(finding.rel))), strings.ReplaceAll(finding.details, "|", `\|`))
+	}
+}
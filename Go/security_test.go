@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSecurityRulesHaveMessages(t *testing.T) {
+	for _, rule := range securityRules {
+		if _, ok := catalogEN[rule.kind]; !ok {
+			t.Errorf("rule %q has no English message", rule.kind)
+		}
+	}
+	for _, severity := range []string{severityHigh, severityMedium, severityLow} {
+		if _, ok := catalogEN["security.severity."+severity]; !ok {
+			t.Errorf("severity %q has no English message", severity)
+		}
+	}
+}
+
+func TestSecurityReportIsTranslated(t *testing.T) {
+	savedFindings, savedLang, savedCatalog := securityFindings, lang, catalog
+	t.Cleanup(func() { securityFindings, lang, catalog = savedFindings, savedLang, savedCatalog })
+	securityFindings = []securityFinding{
+		{severityLow, "security.finding.worldWritableDir", "tmp", "drwxrwxrwt"},
+		{severityHigh, "security.finding.setuid", "bin/tool", "-rwsr-xr-x owner root"},
+	}
+	tests := []struct {
+		lang string
+		rows []string
+	}{
+		{"en", []string{
+			"| high | setuid | `bin/tool` | -rwsr-xr-x owner root |",
+			"| low | world-writable directory | `tmp` | drwxrwxrwt |",
+		}},
+		{"de", []string{
+			"| hoch | setuid | `bin/tool` | -rwsr-xr-x owner root |",
+			"| niedrig | für alle schreibbares Verzeichnis | `tmp` | drwxrwxrwt |",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.lang, func(t *testing.T) {
+			if err := setLang(test.lang); err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			writeSecurityReport(&out)
+			last := -1
+			for _, row := range test.rows {
+				at := strings.Index(out.String(), row)
+				if at < 0 {
+					t.Fatalf("report has no row %q:\n%s", row, out.String())
+				}
+				if at < last {
+					t.Errorf("row %q is out of severity order:\n%s", row, out.String())
+				}
+				last = at
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"runtime"
+)
+
+var (
+	selfCheck       bool       // --self-check: render twice and require identical output
+	shuffleListings *rand.Rand // Shuffles every listing before it is put in byte order, for --self-check
+)
+
+// checkReproducible renders the tree a second time with every listing shuffled and a
+// different --jobs and GOMAXPROCS, and exits with exitDifferences and the first differing line
+// when the output is not byte-identical to first. Map iteration order is random per
+// range statement, so a report that ranges over a map unsorted is likely to show too.
+// Timestamp lines (--provenance) are compared without the time.
+func checkReproducible(ctx context.Context, first []byte, bare bool) {
+	resetWalkState()
+	procs := runtime.GOMAXPROCS(0)
+	if procs > 1 {
+		runtime.GOMAXPROCS(1)
+	} else {
+		runtime.GOMAXPROCS(4)
+	}
+	jobs, auto := walkJobs, jobsAuto
+	if jobs > 1 || auto {
+		walkJobs, jobsAuto = 1, false
+	} else {
+		walkJobs = 4
+	}
+	shuffleListings = rand.New(rand.NewSource(rand.Int63()))
+	second, err := renderRoots(ctx, bare)
+	if ctx.Err() != nil {
+		cancelExit(ctx)
+	}
+	if err != nil {
+		errorExit(err.Error())
+	}
+	shuffleListings = nil
+	runtime.GOMAXPROCS(procs)
+	walkJobs, jobsAuto = jobs, auto
+
+	line, a, b, same := firstDifference(first, second)
+	if !same {
+		exitWith(exitDifferences, fmt.Sprintf("Self-check failed: the second run differs from line %d on\n  first run:  %q\n  second run: %q", line, a, b))
+	}
+	clearProgressLine()
+	fmt.Fprintf(messages, "Self-check passed: both runs rendered the same %s\n", formatSize(int64(len(first))))
+}
+
+// firstDifference compares two outputs line by line, ignoring the fingerprint comment
+// and the text after the prefix of volatile lines, and returns the first line that differs
+func firstDifference(a, b []byte) (int, string, string, bool) {
+	linesA := bytes.SplitAfter(fingerprintLine.ReplaceAll(a, nil), []byte("\n"))
+	linesB := bytes.SplitAfter(fingerprintLine.ReplaceAll(b, nil), []byte("\n"))
+	for i := 0; i < max(len(linesA), len(linesB)); i++ {
+		var x, y []byte
+		if i < len(linesA) {
+			x = stableLine(linesA[i])
+		}
+		if i < len(linesB) {
+			y = stableLine(linesB[i])
+		}
+		if !bytes.Equal(x, y) {
+			return i + 1, string(x), string(y), false
+		}
+	}
+	return 0, "", "", true
+}
+
+// stableLine cuts a volatile line down to its prefix
+func stableLine(line []byte) []byte {
+	for _, prefix := range volatilePrefixes() {
+		if bytes.HasPrefix(line, prefix) {
+			return prefix
+		}
+	}
+	return line
+}
+
+// resetWalkState clears what one walk leaves behind for the next render
+func resetWalkState() {
+	counters = scanCounters{excludedBy: map[string]int{}}
+	listingCache = map[string][]fs.DirEntry{}
+	onlyMemo = map[string]bool{}
+	dirTotals = map[string]int64{}
+	fileSizes = map[string]int64{}
+	visitedDirs = map[fileID]string{}
+	subtreeSums = map[string]subtreeSum{}
+	firstSubtree = map[string]string{}
+	brokenLinks = map[string][]string{}
+	orphanHits = map[string][]string{}
+	usageBytes = map[[2]string]int64{}
+	nameTotals, nameDirs, nameLongest, nameOffenders, nameTaken = nameDirStats{}, map[string]*nameDirStats{}, nil, nil, map[string]map[string]bool{}
+	securityFindings, readFailures = nil, nil
+	anomalyFindings, anomalyNotes = nil, map[string][]string{}
+	dirOwners, boundaryFindings, boundaryNotes = map[string]string{}, nil, map[string]string{}
+	overviewNodes, overviewByRel, fenceOpen = nil, map[string]*overviewNode{}, false
+	keepFilter, prunedDirs = nil, map[string]bool{}
+	redactedCount, inFluxCount, inFluxFiles = 0, 0, map[string]bool{}
+	textDirs, textFiles = 0, 0
+	mermaidCut, svgCut = false, false
+	autoDepthNote = ""
+	resetAnnotations()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+var (
+	sidecarJSON      bool                     // --sidecar: write each -o file's -f json snapshot next to it
+	checkOutputs     bool                     // --check: compare the -o files with this run's render and write nothing
+	recordedListings map[string][]fs.DirEntry // Listings the sidecar render read, replayed for the output's; nil otherwise
+)
+
+// sidecarPath names the JSON snapshot written next to an output file
+func sidecarPath(location string) string {
+	return location + ".json"
+}
+
+// recordListing keeps a listing getEntries read while the sidecar is rendered
+func recordListing(dir string, entries []fs.DirEntry) {
+	if recordedListings != nil {
+		recordedListings[dir] = slices.Clone(entries)
+	}
+}
+
+// checkPairing refuses --sidecar and --check where there is no output file to pair
+// or compare: standard output, the clipboard, an upload, a pipe or an injected section
+func checkPairing(locations []string) {
+	if sidecarJSON {
+		switch outputFormat {
+		case formatJSON, formatManifest:
+			usageExit(fmt.Sprintf("--sidecar pairs an output with its -f json snapshot; -f %s is one already", outputFormat))
+		case formatHTMLSite, formatMDSite:
+			usageExit("--sidecar cannot be combined with -f " + outputFormat)
+		}
+		if scanBudget > 0 {
+			usageExit("--sidecar cannot be combined with --budget, which could stop the two renders at different places")
+		}
+	}
+	name := "--sidecar"
+	if checkOutputs {
+		name = "--check"
+	}
+	if injectFile != "" || pipeCommand != "" || postURL != "" {
+		usageExit(name + " cannot be combined with --inject, --pipe or --post-url")
+	}
+	if len(locations) == 0 || toStdout(locations) || slices.Contains(locations, clipboardTarget) {
+		usageExit(name + " needs -o files; standard output and the clipboard leave nothing to pair or compare")
+	}
+}
+
+// renderSidecar renders the -f json snapshot of the run's tree and leaves its listings
+// in the walk's cache, so the output rendered next shows the same tree even when the
+// directory changes in between. Only the output's render reports warnings.
+func renderSidecar(ctx context.Context) ([]byte, error) {
+	format := outputFormat
+	outputFormat, progress.muted = formatJSON, true
+	recordedListings = map[string][]fs.DirEntry{}
+	data, err := renderRoots(ctx, false)
+	listings := recordedListings
+	outputFormat, progress.muted, recordedListings = format, false, nil
+	resetWalkState()
+	for dir, entries := range listings {
+		listingCache[dir] = entries
+	}
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return embedFingerprint(formatJSON, data), nil
+}
+
+// writeSidecars writes the snapshot next to every output file, as writeOutputs writes
+// the output itself
+func writeSidecars(locations []string, data []byte) bool {
+	sidecars := make([]string, len(locations))
+	for i, location := range locations {
+		sidecars[i] = sidecarPath(location)
+	}
+	return writeLocations(sidecars, data)
+}
+
+// checkFiles compares every output file, and with --sidecar its snapshot, with what
+// this run rendered, ignoring the lines that change on every run. It names each file
+// of a pair that is missing or out of date and exits with exitDifferences when any is.
+func checkFiles(locations []string, data, sidecar []byte) {
+	var reports []string
+	failed := false
+	for _, location := range locations {
+		files, renders := []string{location}, [][]byte{data}
+		if sidecar != nil {
+			files, renders = append(files, sidecarPath(location)), append(renders, sidecar)
+		}
+		var states []string
+		for i, file := range files {
+			state := checkFile(file, renders[i])
+			failed = failed || state != "up to date"
+			states = append(states, fmt.Sprintf("%s is %s", file, state))
+		}
+		reports = append(reports, strings.Join(states, ", "))
+	}
+	if failed {
+		exitWith(exitDifferences, "Check failed: "+strings.Join(reports, "; "))
+	}
+	fmt.Fprintf(messages, "Check passed: %s\n", strings.Join(reports, "; "))
+	finishRun(true)
+}
+
+// checkFile says how a file on disk compares with the render it should hold
+func checkFile(location string, want []byte) string {
+	existing, err := os.ReadFile(location)
+	switch {
+	case os.IsNotExist(err):
+		return "missing"
+	case err != nil:
+		return fmt.Sprintf("unreadable (%v)", err)
+	case contentFingerprint(existing) != contentFingerprint(want):
+		return "out of date"
+	}
+	return "up to date"
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// The output and its sidecar come from one walk: a file added between the two renders
+// is in neither
+func TestSidecarSameWalk(t *testing.T) {
+	fsys := testtree.MapFS(t, "a/b.txt\nc.txt\n")
+	useFixture(t, fsys, func() {
+		setOption(t, &noSummary, true)
+	})
+	sidecar, err := renderSidecar(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys["a/late.txt"] = &fstest.MapFile{}
+	data, err := renderRun(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sidecar), "late.txt") || strings.Contains(string(data), "late.txt") {
+		t.Errorf("a file added after the sidecar's walk is shown:\n%s\n%s", data, sidecar)
+	}
+	if !strings.Contains(string(sidecar), `"name": "b.txt"`) || !strings.Contains(string(data), "b.txt") {
+		t.Errorf("missing entries:\n%s\n%s", data, sidecar)
+	}
+}
+
+// --check names each file of the pair that is missing or out of date, and passes only
+// when both hold what the run renders
+func TestSidecarCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		drift func(t *testing.T, dir, src string)
+		code  int
+		want  string
+	}{
+		{"in step", func(*testing.T, string, string) {}, exitOK, "Check passed: tree.md is up to date, tree.md.json is up to date"},
+		{"output missing", func(t *testing.T, dir, src string) { removeFile(t, dir, "tree.md") }, exitDifferences,
+			"tree.md is missing, tree.md.json is up to date"},
+		{"sidecar missing", func(t *testing.T, dir, src string) { removeFile(t, dir, "tree.md.json") }, exitDifferences,
+			"tree.md is up to date, tree.md.json is missing"},
+		{"both missing", func(t *testing.T, dir, src string) { removeFile(t, dir, "tree.md"); removeFile(t, dir, "tree.md.json") }, exitDifferences,
+			"tree.md is missing, tree.md.json is missing"},
+		{"output edited", func(t *testing.T, dir, src string) { editFile(t, dir, "tree.md", "[F] c.txt", "[F] d.txt") }, exitDifferences,
+			"tree.md is out of date, tree.md.json is up to date"},
+		{"sidecar edited", func(t *testing.T, dir, src string) { editFile(t, dir, "tree.md.json", `"c.txt"`, `"d.txt"`) }, exitDifferences,
+			"tree.md is up to date, tree.md.json is out of date"},
+		{"tree changed", func(t *testing.T, dir, src string) {
+			if err := os.WriteFile(filepath.Join(src, "new.txt"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}, exitDifferences, "tree.md is out of date, tree.md.json is out of date"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, src := t.TempDir(), testtree.Dir(t, "a/b.txt\nc.txt\n")
+			args := []string{"-d", src, "-o", "tree.md", "--sidecar"}
+			if _, stderr, code := runFTG(t, dir, args...); code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			test.drift(t, dir, src)
+			stdout, stderr, code := runFTG(t, dir, append(args, "--check")...)
+			if code != test.code || !strings.Contains(stdout+stderr, test.want) {
+				t.Errorf("exit code %d, %s%s; want %d and %q", code, stdout, stderr, test.code, test.want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "tree.md")); test.name == "output missing" && err == nil {
+				t.Error("--check wrote tree.md")
+			}
+		})
+	}
+}
+
+// --sidecar and --check need an output file to pair or compare
+func TestSidecarRefused(t *testing.T) {
+	dir := testtree.Dir(t, "a.txt\n")
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--sidecar", "-o", "-"}, "--sidecar needs -o files"},
+		{[]string{"--check", "--copy", "-o", "tree.md"}, "--check needs -o files"},
+		{[]string{"--sidecar", "-f", "json", "-o", "tree.json"}, "-f json is one already"},
+		{[]string{"--sidecar", "--pipe", "cat", "-o", "tree.md"}, "cannot be combined with --inject, --pipe or --post-url"},
+	} {
+		_, stderr, code := runFTG(t, dir, append([]string{"-d", dir}, test.args...)...)
+		if code != exitUsage || !strings.Contains(stderr, test.want) {
+			t.Errorf("%q: exit code %d, %s; want %d and %q", test.args, code, stderr, exitUsage, test.want)
+		}
+	}
+}
+
+// removeFile deletes a file of dir
+func removeFile(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.Remove(filepath.Join(dir, name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// editFile replaces old with new in a file of dir
+func editFile(t *testing.T, dir, name, old, new string) {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), old) {
+		t.Fatalf("%s has no %q:\n%s", name, old, data)
+	}
+	if err := os.WriteFile(file, []byte(strings.Replace(string(data), old, new, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formatSize renders a byte count with one decimal in B, KB, MB, GB or TB, using
+// the decimal separator and unit names of --lang
+func formatSize(size int64) string {
+	const unit = 1024
+	units := strings.Fields(msg("size.units"))
+	if size < unit {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	value := float64(size) / unit
+	for _, suffix := range units[1 : len(units)-1] {
+		if value < unit {
+			return localizeDecimal(fmt.Sprintf("%.1f", value)) + " " + suffix
+		}
+		value /= unit
+	}
+	return localizeDecimal(fmt.Sprintf("%.1f", value)) + " " + units[len(units)-1]
+}
+
+// localizeDecimal swaps the decimal point of a formatted number for the one of --lang
+func localizeDecimal(number string) string {
+	return strings.Replace(number, ".", msg("number.decimal"), 1)
+}
+
+// plural formats a count with a singular or plural noun
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// groupThousands formats a count with the thousands separator of --lang, e.g. 9,987
+func groupThousands(count int) string {
+	digits := fmt.Sprint(count)
+	if count < 0 {
+		return "-" + groupThousands(-count)
+	}
+	separator := msg("number.thousands")
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + separator + digits[i:]
+	}
+	return digits
+}
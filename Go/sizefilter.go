@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+var (
+	minSize   int64 = -1                 // --min-size: files smaller than this are left out; -1 when not set
+	maxSize   int64 = -1                 // --max-size: files larger than this are left out; -1 when not set
+	fileSizes       = map[string]int64{} // Sizes the size filter read, by full path; -1 when unreadable
+)
+
+// sizeFilterActive reports whether --min-size or --max-size restricts the files shown
+func sizeFilterActive() bool {
+	return minSize >= 0 || maxSize >= 0
+}
+
+// filterSize drops the files outside the --min-size and --max-size range.
+// Directories, and links the walk follows into one, stay so the walk still enters
+// them; --prune leaves out those where nothing qualifies. A file whose size cannot
+// be read is left out, as it cannot be compared.
+func filterSize(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if !sizeFilterActive() {
+		return entries
+	}
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		fullPath := filepath.Join(dir, entry.Name())
+		if entry.IsDir() || (!entry.Type().IsRegular() && shouldDescend(fullPath, entry)) || sizeInRange(entrySize(fullPath, entry)) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// entrySize returns a file's size, reading it once per walk
+func entrySize(fullPath string, entry fs.DirEntry) int64 {
+	if size, ok := fileSizes[fullPath]; ok {
+		return size
+	}
+	size := int64(-1)
+	if info, err := entryInfo(entry); err == nil {
+		size = info.Size()
+	}
+	fileSizes[fullPath] = size
+	return size
+}
+
+// sizeInRange reports whether a file size passes --min-size and --max-size
+func sizeInRange(size int64) bool {
+	return size >= 0 && (minSize < 0 || size >= minSize) && (maxSize < 0 || size <= maxSize)
+}
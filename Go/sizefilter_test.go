@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Sizes take K, M, G and T with or without B or iB, in any case, as powers of 1024;
+// a bare number is bytes
+func TestParseByteSize(t *testing.T) {
+	for text, want := range map[string]int64{
+		"0":       0,
+		"4096":    4096,
+		"512B":    512,
+		"10K":     10 << 10,
+		"10k":     10 << 10,
+		"10KB":    10 << 10,
+		"10KiB":   10 << 10,
+		"5M":      5 << 20,
+		"5MiB":    5 << 20,
+		"1.5M":    3 << 19,
+		"1G":      1 << 30,
+		"1gib":    1 << 30,
+		"2T":      2 << 40,
+		" 100MB ": 100 << 20,
+	} {
+		got, err := parseByteSize(text)
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"", "K", "ten", "-1K", "5X", "1.2.3M", "Inf", "NaN"} {
+		if got, err := parseByteSize(text); err == nil {
+			t.Errorf("parseByteSize(%q) = %d, want an error", text, got)
+		}
+	}
+}
+
+// sizeFixture has files of 100 B, 10 KB and 5 MB, and a directory of small files only
+const sizeFixture = `
+big.bin size=5242880
+logs/a.log size=100
+logs/b.log size=100
+mid.bin size=10240
+small.txt size=100
+`
+
+// Files outside the range are left out with the connectors of what is left, the
+// directories stay unless --prune finds nothing in them
+func TestSizeFilter(t *testing.T) {
+	tests := []struct {
+		min, max int64
+		prune    bool
+		want     string
+	}{
+		{10 << 10, -1, false, "├── [F] big.bin\n" +
+			"├── [D] logs\n" +
+			"└── [F] mid.bin\n"},
+		{10 << 10, -1, true, "├── [F] big.bin\n" +
+			"└── [F] mid.bin\n"},
+		{-1, 1 << 10, false, "├── [D] logs\n" +
+			"│   ├── [F] a.log\n" +
+			"│   └── [F] b.log\n" +
+			"└── [F] small.txt\n"},
+		{10 << 10, 10 << 10, true, "└── [F] mid.bin\n"},
+	}
+	for _, test := range tests {
+		got := renderFixture(t, testtree.MapFS(t, sizeFixture), true, func() {
+			setOption(t, &noSummary, true)
+			setOption(t, &minSize, test.min)
+			setOption(t, &maxSize, test.max)
+			setOption(t, &pruneEmpty, test.prune)
+		})
+		if got != test.want {
+			t.Errorf("--min-size %d --max-size %d --prune=%v: got\n%s\nwant\n%s", test.min, test.max, test.prune, got, test.want)
+		}
+	}
+}
+
+// Invalid sizes and an empty range are refused before the walk
+func TestSizeFlagsRefused(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--min-size", "10X"}, `--min-size: invalid size "10X"`},
+		{[]string{"--max-size", "-5M"}, `--max-size: invalid size "-5M"`},
+		{[]string{"--min-size", "2M", "--max-size", "1M"}, "--min-size must not be larger than --max-size"},
+	} {
+		_, stderr, code := runFTG(t, t.TempDir(), append(test.args, "-d", ".", "-o", "-")...)
+		if code != exitUsage || !strings.Contains(stderr, test.want) {
+			t.Errorf("%q: exit code %d, %s; want %d and %q", test.args, code, stderr, exitUsage, test.want)
+		}
+	}
+}
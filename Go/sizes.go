@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	showSizes bool                 // -s: annotate files with their size
+	dirSizes  bool                 // --dir-sizes: annotate directories with the total size of the files below them
+	diskUsage bool                 // --disk-usage: show and sum the space files take on disk instead of their length
+	sizeBoth  bool                 // --size-both: show a file's length and the space it takes on disk
+	dirTotals = map[string]int64{} // Cumulative directory sizes, computed once per directory
+)
+
+// sparseMinGap is how much less than its length a file must take on disk to be marked
+// sparse, besides less than half: files small enough to be stored in their inode take
+// no blocks at all without having holes
+const sparseMinGap = 4096
+
+// sizeNote returns the size annotation of an entry, "(?)" when it cannot be read. A
+// size on disk says so, and a file taking much less room than its length is marked
+// "(sparse)".
+func sizeNote(path string, entry fs.DirEntry) string {
+	if entry.IsDir() {
+		size, shown, _ := shownSize(path, entry)
+		if !shown {
+			return ""
+		}
+		return "(" + formatTotal(size) + ")"
+	}
+	if !showSizes {
+		return ""
+	}
+	apparent, allocated, ok, err := entrySizes(filepath.Join(path, entry.Name()), entry)
+	switch {
+	case err != nil:
+		return "(?)"
+	case !ok:
+		return ""
+	}
+	note := formatSize(apparent)
+	if sizeBoth {
+		note += ", " + msg("size.onDisk", formatSize(allocated))
+	} else if diskUsage {
+		note = msg("size.onDisk", formatSize(allocated))
+	}
+	note = "(" + note + ")"
+	if isSparse(apparent, allocated) {
+		note += " " + msg("size.sparse")
+	}
+	return note
+}
+
+// shownSize returns the size the tree shows for an entry: the cumulative size of a
+// directory with --dir-sizes, or with -s the size of a file or of the file a link
+// points to, on disk with --disk-usage. shown is false when the options show none.
+func shownSize(path string, entry fs.DirEntry) (size int64, shown bool, err error) {
+	fullPath := filepath.Join(path, entry.Name())
+	if entry.IsDir() {
+		if !dirSizes {
+			return 0, false, nil
+		}
+		return directorySize(fullPath), true, nil
+	}
+	if !showSizes {
+		return 0, false, nil
+	}
+	apparent, allocated, shown, err := entrySizes(fullPath, entry)
+	if diskUsage {
+		return allocated, shown, err
+	}
+	return apparent, shown, err
+}
+
+// entrySizes returns the length of a file, or of the file a link points to, and the
+// space it takes on disk. ok is false for a link to a directory; err is set when the
+// file, or a broken link's target, cannot be read.
+func entrySizes(fullPath string, entry fs.DirEntry) (apparent, allocated int64, ok bool, err error) {
+	info, err := entryInfo(entry)
+	if err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		// Links show the size of their target; a broken link has none
+		info, err = targetInfo(fullPath)
+	}
+	if err != nil {
+		return 0, 0, true, err
+	}
+	if info.IsDir() {
+		return 0, 0, false, nil
+	}
+	return info.Size(), diskSize(fullPath, info), true, nil
+}
+
+// diskSize returns the space a file takes on disk where the platform can tell, and
+// its length where it cannot, as for files of an in-memory FS
+func diskSize(fullPath string, info fs.FileInfo) int64 {
+	if size, ok := allocatedSize(fullPath, info); ok {
+		return size
+	}
+	return info.Size()
+}
+
+// totalSize is what a regular file adds to the totals: its size on disk with
+// --disk-usage, its length otherwise
+func totalSize(fullPath string, info fs.FileInfo) int64 {
+	if diskUsage {
+		return diskSize(fullPath, info)
+	}
+	return info.Size()
+}
+
+// formatTotal formats a sum of totalSize
+func formatTotal(size int64) string {
+	if diskUsage {
+		return msg("size.onDisk", formatSize(size))
+	}
+	return formatSize(size)
+}
+
+// isSparse tells whether a file takes so much less room on disk than its length that
+// it must have holes, or be compressed
+func isSparse(apparent, allocated int64) bool {
+	return allocated < apparent/2 && apparent-allocated >= sparseMinGap
+}
+
+// targetInfo stats a path following links, through treeFS when it lies below the input directory
+func targetInfo(p string) (fs.FileInfo, error) {
+	if name, ok := fsPath(p); ok {
+		return fs.Stat(treeFS, name)
+	}
+	return os.Stat(p)
+}
+
+// directorySize sums the regular files below dir that the exclusions keep, including
+// those deeper than --max-depth, by their size on disk with --disk-usage; links are not
+// followed and unreadable parts count as 0
+func directorySize(dir string) int64 {
+	if total, ok := dirTotals[dir]; ok {
+		return total
+	}
+	var total int64
+	if entries, err := readDir(dir); err == nil {
+		for _, entry := range visibleEntries(dir, entries) {
+			if shouldExclude(dir, entry) {
+				continue
+			}
+			fullPath := filepath.Join(dir, entry.Name())
+			if entry.IsDir() {
+				total += directorySize(fullPath)
+			} else if info, err := entryInfo(entry); err == nil && info.Mode().IsRegular() && !isInFlux(fullPath, entry) {
+				total += totalSize(fullPath, info)
+			}
+		}
+	}
+	dirTotals[dir] = total
+	return total
+}
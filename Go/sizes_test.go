@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// sparseFile makes a 10 MB file with no data in dir, skipping the test where the
+// filesystem allocates it all the same
+func sparseFile(t *testing.T, dir string) fs.FileInfo {
+	t.Helper()
+	file := filepath.Join(dir, "vm.img")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(file, 10<<20); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, ok := allocatedSize(file, info); !ok || size >= info.Size()/2 {
+		t.Skipf("the filesystem allocates %d bytes for a 10 MB hole (known: %v)", size, ok)
+	}
+	return info
+}
+
+// The platform reports less than the length of a file with holes, and files of an
+// in-memory FS fall back to their length
+func TestAllocatedSize(t *testing.T) {
+	dir := t.TempDir()
+	info := sparseFile(t, dir)
+	if got := diskSize(filepath.Join(dir, "vm.img"), info); got >= info.Size() {
+		t.Errorf("diskSize = %d, want less than %d", got, info.Size())
+	}
+	fsys := testtree.MapFS(t, "a.txt size=3000\n")
+	mapped, err := fs.Stat(fsys, "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := allocatedSize("a.txt", mapped); ok {
+		t.Error("allocatedSize knows the blocks of an in-memory file")
+	}
+	if got := diskSize("a.txt", mapped); got != 3000 {
+		t.Errorf("diskSize = %d, want the length 3000", got)
+	}
+}
+
+func TestIsSparse(t *testing.T) {
+	for _, test := range []struct {
+		apparent, allocated int64
+		want                bool
+	}{
+		{10 << 20, 0, true},
+		{10 << 20, 5 << 20, false}, // Exactly half
+		{10 << 20, 5<<20 - 1, true},
+		{3, 4096, false},    // Small files take a whole block
+		{100, 0, false},     // Stored in the inode
+		{8192, 0, true},     // Two blocks of holes
+		{8191, 4096, false}, // Less than a block missing
+	} {
+		if got := isSparse(test.apparent, test.allocated); got != test.want {
+			t.Errorf("isSparse(%d, %d) = %v, want %v", test.apparent, test.allocated, got, test.want)
+		}
+	}
+}
+
+// --disk-usage shows and totals sizes on disk, --size-both shows both, and a file with
+// holes is marked whichever is shown
+func TestDiskUsage(t *testing.T) {
+	dir := testtree.Dir(t, "vm/\n")
+	info := sparseFile(t, filepath.Join(dir, "vm"))
+	onDisk := formatSize(diskSize(filepath.Join(dir, "vm", "vm.img"), info))
+	for _, test := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-s"}, []string{"vm.img (10.0 MB) (sparse)", "10.0 MB in total"}},
+		{[]string{"--size-both"}, []string{"vm.img (10.0 MB, " + onDisk + " on disk) (sparse)", "10.0 MB in total"}},
+		{[]string{"--disk-usage", "--dir-sizes"}, []string{"vm (" + onDisk + " on disk)\n", "vm.img (" + onDisk + " on disk) (sparse)", onDisk + " on disk in total"}},
+	} {
+		stdout, stderr, code := runFTG(t, dir, append([]string{"-d", dir, "-o", "-"}, test.args...)...)
+		if code != exitOK {
+			t.Fatalf("%q: exit code %d: %s", test.args, code, stderr)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("%q: got\n%s\nwant %q", test.args, stdout, want)
+			}
+		}
+	}
+}
+
+// Directory totals sum the metric shown; files of an in-memory FS take their length on disk
+func TestDiskUsageDirectoryTotals(t *testing.T) {
+	fsys := testtree.MapFS(t, "a/b.txt size=2048\na/c.txt size=1024\n")
+	for _, test := range []struct {
+		disk bool
+		want string
+	}{
+		{false, "└── [D] a (3.0 KB)\n"},
+		{true, "└── [D] a (3.0 KB on disk)\n"},
+	} {
+		got := renderFixture(t, fsys, true, func() {
+			setOption(t, &noSummary, true)
+			setOption(t, &dirSizes, true)
+			setOption(t, &diskUsage, test.disk)
+			setOption(t, &showSizes, true)
+		})
+		if !strings.HasPrefix(got, test.want) {
+			t.Errorf("--disk-usage=%v: got\n%s\nwant it to start with %q", test.disk, got, test.want)
+		}
+	}
+}
+
+// Sizes switch unit at 1024 and keep one decimal above bytes
+func TestFormatSize(t *testing.T) {
+	for size, want := range map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1024:          "1.0 KB",
+		1536:          "1.5 KB",
+		1024*1024 - 1: "1024.0 KB",
+		1024 * 1024:   "1.0 MB",
+		1572864:       "1.5 MB",
+		1 << 30:       "1.0 GB",
+		5 << 40:       "5.0 TB",
+		2048 << 40:    "2048.0 TB",
+	} {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+// -s annotates files at the unit boundaries and a link it cannot size with (?), and
+// --dir-sizes sums the files below a directory
+func TestSizeNotes(t *testing.T) {
+	fsys := testtree.MapFS(t, "a.bin size=1023\nb.bin size=1024\nd/c.bin size=1572864\nbroken -> nowhere\n")
+	got := renderFixture(t, fsys, true, func() {
+		setOption(t, &noSummary, true)
+		setOption(t, &showSizes, true)
+		setOption(t, &dirSizes, true)
+	})
+	want := "├── [F] a.bin (1023 B)\n" +
+		"├── [F] b.bin (1.0 KB)\n" +
+		"├── [L] broken -> nowhere (?)\n" +
+		"└── [D] d (1.5 MB)\n" +
+		"    └── [F] c.bin (1.5 MB)\n"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("got\n%s\nwant it to start with\n%s", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// Orders accepted by --sort; the default keeps the listing's byte order
+const (
+	sortName       = "name"        // Case-insensitive by name
+	sortDirsFirst  = "dirs-first"  // Directories, then files, each case-insensitive
+	sortFilesFirst = "files-first" // Files, then directories, each case-insensitive
+)
+
+var sortOrder string // --sort value, "" for the listing order
+
+// checkSortOrder validates --sort
+func checkSortOrder() error {
+	switch sortOrder {
+	case "", sortName, sortDirsFirst, sortFilesFirst:
+		return nil
+	}
+	return fmt.Errorf("unknown --sort value %q (use name, dirs-first or files-first)", sortOrder)
+}
+
+// sortEntries orders a listing for --sort. Names are folded with strings.ToLower so
+// the order does not depend on the locale, and names equal after folding fall back to
+// the byte order so every run prints the same sequence.
+func sortEntries(entries []fs.DirEntry) []fs.DirEntry {
+	if sortOrder == "" {
+		return entries
+	}
+	sorted := append(entries[:0:0], entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sortsBefore(sorted[i].Name(), sorted[i].IsDir(), sorted[j].Name(), sorted[j].IsDir())
+	})
+	return sorted
+}
+
+// sortsBefore reports whether entry a comes before entry b in the --sort order, or
+// in byte order when there is no --sort
+func sortsBefore(a string, aDir bool, b string, bDir bool) bool {
+	if sortOrder == "" {
+		return a < b
+	}
+	if sortOrder != sortName && aDir != bDir {
+		return aDir == (sortOrder == sortDirsFirst)
+	}
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	if la != lb {
+		return la < lb
+	}
+	return a < b
+}
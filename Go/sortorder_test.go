@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// mixedTree has names that differ only in case and directories among the files
+const mixedTree = `
+Apple.txt
+apple.txt
+b.txt
+c.md
+a/x
+B/x
+Zeta/x
+`
+
+// Each --sort order lists the top level exactly so, with the last connector on the
+// last entry of that order
+func TestSortOrders(t *testing.T) {
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"", []string{"Apple.txt", "B", "Zeta", "a", "apple.txt", "b.txt", "c.md"}},
+		{sortName, []string{"a", "Apple.txt", "apple.txt", "B", "b.txt", "c.md", "Zeta"}},
+		{sortDirsFirst, []string{"a", "B", "Zeta", "Apple.txt", "apple.txt", "b.txt", "c.md"}},
+		{sortFilesFirst, []string{"Apple.txt", "apple.txt", "b.txt", "c.md", "a", "B", "Zeta"}},
+	}
+	for _, test := range tests {
+		t.Run("sort "+test.order, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, mixedTree), true, func() {
+				setOption(t, &sortOrder, test.order)
+			})
+			var names []string
+			last := ""
+			for _, line := range strings.Split(got, "\n") {
+				if strings.HasPrefix(line, "├── ") || strings.HasPrefix(line, "└── ") {
+					names = append(names, line[strings.Index(line, "] ")+2:])
+					last = line
+				}
+			}
+			if !slices.Equal(names, test.want) {
+				t.Errorf("order %q, want %q", names, test.want)
+			}
+			if want := test.want[len(test.want)-1]; !strings.HasPrefix(last, "└── ") || !strings.HasSuffix(last, "] "+want) {
+				t.Errorf("last line %q, want └── on %s", last, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+var (
+	streamThreshold = 100000 // --stream-threshold: directories with more entries are streamed in batches; 0 reads every directory whole
+	streamSorted    bool     // --stream-sort: put streamed directories in order with an external merge sort
+)
+
+// Sizes of streamed reading
+const streamBatch = 4096 // Entries read per ReadDir call
+
+var streamRunSize = 65536 // Entries sorted in memory before a run is spilled to a temporary file
+
+// errStreamed tells the --jobs prefetch that a directory is left to the walk, which streams it
+var errStreamed = errors.New("directory is streamed by the walk")
+
+// streamingActive reports whether huge directories are streamed: the markdown and
+// text trees print entries as they come, the other formats need whole listings.
+// The daemon's snapshot, an archive and an --overlay plan hold listings in memory.
+func streamingActive() bool {
+	switch treeFS.(type) {
+	case *snapshotFS, *archiveFS, *overlayFS:
+		return false
+	}
+	return streamThreshold > 0 && (outputFormat == formatMarkdown || outputFormat == formatText)
+}
+
+// streamNote returns the label annotation of a streamed directory whose order could
+// not be kept
+func streamNote(te treeEntry) string {
+	if te.event != walkOpen || te.listed >= 0 || streamSorted {
+		return ""
+	}
+	return " (streamed, unsorted)"
+}
+
+// entryStream is the ftree.Stream of a huge directory: it reads it in batches,
+// starting with the entries read while deciding to stream it. Entries keep the order
+// the filesystem returns them in, unless --stream-sort merges them into the usual
+// order through temporary files. Sampling and --anomalies, which compare a whole
+// listing, do not apply; filters, labels and --max-entries do.
+type entryStream struct {
+	dir    string
+	file   fs.ReadDirFile
+	head   []fs.DirEntry
+	shown  int           // Entries shown so far, counted against --max-entries
+	sorter *externalSort // --stream-sort: each entry goes through it before the first is shown
+	err    error         // Why reading stopped before the sorted entries were all shown
+}
+
+// openDir opens a directory for batched reading, through treeFS when it lies below
+// the input directory
+func openDir(dir string) (fs.ReadDirFile, error) {
+	if name, ok := fsPath(dir); ok {
+		f, err := treeFS.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		if dirFile, ok := f.(fs.ReadDirFile); ok {
+			return dirFile, nil
+		}
+		f.Close()
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: ftree.ErrChangedType}
+	}
+	return os.Open(dir)
+}
+
+// readHead reads up to limit+1 entries of a directory in batches. It returns the
+// open directory when there are more than limit, and closes it otherwise.
+func readHead(dir string, limit int) ([]fs.DirEntry, fs.ReadDirFile, error) {
+	f, err := openDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var head []fs.DirEntry
+	for len(head) <= limit {
+		batch, err := f.ReadDir(streamBatch)
+		head = append(head, batch...)
+		if err == io.EOF {
+			f.Close()
+			return head, nil, nil
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return head, f, nil
+}
+
+// readListingCapped is readListing for the --jobs prefetch while streaming is active:
+// a directory larger than --stream-threshold is not read whole but left to the walk
+func readListingCapped(dir string) ([]fs.DirEntry, error) {
+	if !streamingActive() {
+		return readListing(dir)
+	}
+	head, f, err := readHead(dir, streamThreshold)
+	if f != nil {
+		f.Close()
+		return nil, errStreamed
+	}
+	return head, err
+}
+
+// openStream is treeWalker's Stream hook. Up to --stream-threshold entries a
+// directory is left to getEntries, which gets the entries read already; past that
+// they start a stream, so memory stays bounded by the threshold rather than the size
+// of the directory.
+func openStream(ctx context.Context, dir string) (ftree.Stream, error) {
+	if _, cached := listingCache[dir]; cached || !streamingActive() {
+		return nil, nil
+	}
+	resources.readDirs++
+	head, f, err := readHead(dir, streamThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		listingCache[dir] = byteOrder(head)
+		return nil, nil
+	}
+	counters.readable++
+	progressDiscover(dir, head, true)
+	progressDir(dir, len(head))
+	stream := &entryStream{dir: dir, file: f, head: head}
+	if streamSorted {
+		stream.sorter = &externalSort{}
+	}
+	return stream, nil
+}
+
+// Next returns the next batch the directory shows, and io.EOF after the last
+func (s *entryStream) Next() (ftree.Listing, error) {
+	if s.sorter != nil {
+		return s.nextSorted()
+	}
+	batch, err := s.read()
+	if err != nil {
+		return ftree.Listing{}, err
+	}
+	return s.show(batch), nil
+}
+
+// nextSorted is Next for --stream-sort. The first call reads the whole directory into
+// the sort; a listing that fails part way shows what was read, then the error.
+func (s *entryStream) nextSorted() (ftree.Listing, error) {
+	if !s.sorter.started {
+		for {
+			batch, err := s.read()
+			if err != nil {
+				if err != io.EOF {
+					s.err = err
+				}
+				break
+			}
+			for _, entry := range batch {
+				if err := s.sorter.add(entry); err != nil {
+					return ftree.Listing{}, fmt.Errorf("cannot sort the streamed entries: %w", err)
+				}
+			}
+		}
+		if err := s.sorter.start(); err != nil {
+			return ftree.Listing{}, fmt.Errorf("cannot sort the streamed entries: %w", err)
+		}
+	}
+	var batch []fs.DirEntry
+	for len(batch) < streamBatch {
+		entry, ok, err := s.sorter.next(s.dir)
+		if err != nil {
+			return ftree.Listing{}, fmt.Errorf("cannot sort the streamed entries: %w", err)
+		}
+		if !ok {
+			break
+		}
+		batch = append(batch, entry)
+	}
+	if len(batch) == 0 {
+		if s.err != nil {
+			return ftree.Listing{}, s.err
+		}
+		return ftree.Listing{}, io.EOF
+	}
+	return s.show(batch), nil
+}
+
+// read returns the next batch of valid entries with the filters applied, and io.EOF
+// at the end
+func (s *entryStream) read() ([]fs.DirEntry, error) {
+	batch := s.head
+	s.head = nil
+	if batch == nil {
+		var err error
+		if batch, err = s.file.ReadDir(streamBatch); err != nil {
+			return nil, err
+		}
+		progress.done += len(batch)
+		progressDiscover(s.dir, batch, false)
+	}
+	batch = filterExcluded(s.dir, filteredEntries(s.dir, validEntries(s.dir, batch)))
+	recordNameStats(s.dir, batch)
+	matchAnnotations(s.dir, batch)
+	return batch, nil
+}
+
+// show stops a batch at --max-entries, counted over the whole directory
+func (s *entryStream) show(batch []fs.DirEntry) ftree.Listing {
+	keep := len(batch)
+	if maxEntries > 0 {
+		keep = max(0, min(keep, maxEntries-s.shown))
+	}
+	s.shown += keep
+	return ftree.Listing{Entries: batch[:keep], Truncated: len(batch) - keep}
+}
+
+// Close closes the directory and removes the sort's spilled runs
+func (s *entryStream) Close() error {
+	if s.sorter != nil {
+		s.sorter.close()
+	}
+	return s.file.Close()
+}
+
+// streamRecord is an entry as the external sort keeps it: its name and type
+type streamRecord struct {
+	name string
+	mode fs.FileMode
+}
+
+// streamedEntry is an fs.DirEntry rebuilt from a sorted run; Info stats it again
+type streamedEntry struct {
+	dir string
+	streamRecord
+}
+
+// Name returns the entry's name
+func (e streamedEntry) Name() string { return e.name }
+
+// IsDir reports whether the entry is a directory
+func (e streamedEntry) IsDir() bool { return e.mode.IsDir() }
+
+// Type returns the entry's type bits
+func (e streamedEntry) Type() fs.FileMode { return e.mode.Type() }
+
+// Info stats the entry without following links
+func (e streamedEntry) Info() (fs.FileInfo, error) { return lstat(filepath.Join(e.dir, e.name)) }
+
+// recordBefore orders records like the walk orders listings
+func recordBefore(a, b streamRecord) bool {
+	return sortsBefore(a.name, a.mode.IsDir(), b.name, b.mode.IsDir())
+}
+
+// externalSort sorts more entries than fit in memory: runs of streamRunSize records
+// are sorted and spilled to temporary files, which are then merged
+type externalSort struct {
+	buffer  []streamRecord
+	runs    []*os.File
+	merge   *runMerge // The spilled runs once started
+	started bool
+}
+
+// add puts an entry in the sort, spilling the buffer when it is full
+func (s *externalSort) add(entry fs.DirEntry) error {
+	s.buffer = append(s.buffer, streamRecord{entry.Name(), entry.Type()})
+	if len(s.buffer) < streamRunSize {
+		return nil
+	}
+	return s.spill()
+}
+
+// spill writes the buffer as a sorted run of length-prefixed records
+func (s *externalSort) spill() error {
+	sort.Slice(s.buffer, func(i, j int) bool { return recordBefore(s.buffer[i], s.buffer[j]) })
+	f, err := os.CreateTemp("", "ftg-stream-*.run")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f)
+	w := bufio.NewWriter(f)
+	var record []byte
+	for _, r := range s.buffer {
+		record = binary.AppendUvarint(record[:0], uint64(len(r.name)))
+		record = append(record, r.name...)
+		record = binary.AppendUvarint(record, uint64(r.mode))
+		if _, err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	s.buffer = s.buffer[:0]
+	return w.Flush()
+}
+
+// start ends adding and readies next. Without a spilled run the buffer is sorted in
+// place; otherwise the rest is spilled too and the runs are merged.
+func (s *externalSort) start() error {
+	s.started = true
+	if len(s.runs) == 0 {
+		sort.Slice(s.buffer, func(i, j int) bool { return recordBefore(s.buffer[i], s.buffer[j]) })
+		return nil
+	}
+	if len(s.buffer) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	s.merge = &runMerge{}
+	for _, f := range s.runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		run := &runReader{r: bufio.NewReader(f)}
+		if ok, err := run.advance(); err != nil {
+			return err
+		} else if ok {
+			s.merge.runs = append(s.merge.runs, run)
+		}
+	}
+	heap.Init(s.merge)
+	return nil
+}
+
+// next returns the next entry in order, and false after the last
+func (s *externalSort) next(dir string) (fs.DirEntry, bool, error) {
+	if s.merge == nil {
+		if len(s.buffer) == 0 {
+			return nil, false, nil
+		}
+		r := s.buffer[0]
+		s.buffer = s.buffer[1:]
+		return streamedEntry{dir, r}, true, nil
+	}
+	if s.merge.Len() == 0 {
+		return nil, false, nil
+	}
+	run := s.merge.runs[0]
+	entry := streamedEntry{dir, run.current}
+	ok, err := run.advance()
+	if err != nil {
+		return nil, false, err
+	}
+	if ok {
+		heap.Fix(s.merge, 0)
+	} else {
+		heap.Pop(s.merge)
+	}
+	return entry, true, nil
+}
+
+// close removes the spilled runs
+func (s *externalSort) close() {
+	for _, f := range s.runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// runReader reads the records of one spilled run
+type runReader struct {
+	r       *bufio.Reader
+	current streamRecord
+}
+
+// advance reads the next record into current and reports whether there was one
+func (run *runReader) advance() (bool, error) {
+	size, err := binary.ReadUvarint(run.r)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	name := make([]byte, size)
+	if _, err := io.ReadFull(run.r, name); err != nil {
+		return false, err
+	}
+	mode, err := binary.ReadUvarint(run.r)
+	if err != nil {
+		return false, err
+	}
+	run.current = streamRecord{string(name), fs.FileMode(mode)}
+	return true, nil
+}
+
+// runMerge is a heap of runs by their current record
+type runMerge struct {
+	runs []*runReader
+}
+
+func (m *runMerge) Len() int           { return len(m.runs) }
+func (m *runMerge) Less(i, j int) bool { return recordBefore(m.runs[i].current, m.runs[j].current) }
+func (m *runMerge) Swap(i, j int)      { m.runs[i], m.runs[j] = m.runs[j], m.runs[i] }
+func (m *runMerge) Push(x any)         { m.runs = append(m.runs, x.(*runReader)) }
+func (m *runMerge) Pop() any {
+	last := m.runs[len(m.runs)-1]
+	m.runs = m.runs[:len(m.runs)-1]
+	return last
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// bigFixture has a directory past a --stream-threshold of 3, whose entries go below
+// others and hold a subdirectory of their own
+const bigFixture = `
+a.txt
+big/e.txt
+big/b.txt
+big/nested/inner.txt
+big/d.txt
+big/c.txt
+big/a.txt
+z.txt
+`
+
+// brokenStreamFS is fsys with the listing of one directory failing after it returned
+// a number of entries, one per call
+type brokenStreamFS struct {
+	fs.FS
+	dir   string
+	after int
+}
+
+// Open opens a file, wrapping the broken directory
+func (f brokenStreamFS) Open(name string) (fs.File, error) {
+	file, err := f.FS.Open(name)
+	if err != nil || name != f.dir {
+		return file, err
+	}
+	return &brokenDir{file.(fs.ReadDirFile), f.after}, nil
+}
+
+// brokenDir is the directory brokenStreamFS breaks
+type brokenDir struct {
+	fs.ReadDirFile
+	left int
+}
+
+// ReadDir returns the next entry until none are left to return, then fails
+func (d *brokenDir) ReadDir(int) ([]fs.DirEntry, error) {
+	if d.left == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: "big", Err: syscall.EIO}
+	}
+	d.left--
+	return d.ReadDirFile.ReadDir(1)
+}
+
+// A streamed directory renders like one read whole, in the order the filesystem
+// lists it, and says it is streamed unless --stream-sort put it in order
+func TestStreamedDirectory(t *testing.T) {
+	for _, format := range []string{formatMarkdown, formatText} {
+		t.Run(format, func(t *testing.T) {
+			render := func(configure func()) string {
+				return renderFixture(t, testtree.MapFS(t, bigFixture), true, func() {
+					setOption(t, &outputFormat, format)
+					if configure != nil {
+						configure()
+					}
+				})
+			}
+			whole := render(func() { setOption(t, &streamThreshold, 0) })
+			streamed := render(func() { setOption(t, &streamThreshold, 3) })
+			if want := strings.Replace(whole, "big\n", "big (streamed, unsorted)\n", 1); streamed != want {
+				t.Errorf("streamed:\n%s\nwant:\n%s", streamed, want)
+			}
+			sorted := render(func() {
+				setOption(t, &streamThreshold, 3)
+				setOption(t, &streamSorted, true)
+			})
+			if sorted != whole {
+				t.Errorf("--stream-sort:\n%s\nwant:\n%s", sorted, whole)
+			}
+		})
+	}
+}
+
+// --max-entries counts the entries of a streamed directory over all its batches
+func TestStreamedMaxEntries(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, bigFixture), true, func() {
+		setOption(t, &streamThreshold, 3)
+		setOption(t, &streamSorted, true)
+		setOption(t, &maxEntries, 2)
+	})
+	want := "├── [D] big\n│   ├── [F] a.txt\n│   ├── [F] b.txt\n│   └── … 4 more entries not shown\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("tree:\n%s\nwant it to hold:\n%s", got, want)
+	}
+}
+
+// A streamed directory whose listing fails part way shows the entries read, then the
+// error, and the file stays complete
+func TestStreamedReadError(t *testing.T) {
+	warnings := captureWarnings(t)
+	got := renderFixture(t, brokenStreamFS{testtree.MapFS(t, bigFixture), "big", 5}, true, func() {
+		setOption(t, &streamThreshold, 3)
+	})
+	want := "├── [D] big (streamed, unsorted)\n│   ├── [F] a.txt\n│   ├── [F] b.txt\n│   ├── [F] c.txt\n│   ├── [F] d.txt\n│   ├── [F] e.txt\n│   └── [error: input/output error]\n└── [F] z.txt\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("tree:\n%s\nwant it to hold:\n%s", got, want)
+	}
+	if counters.unreadable != 1 || !strings.Contains(warnings.String(), "big") {
+		t.Errorf("unreadable = %d, warnings %q; want the failure counted and warned about", counters.unreadable, warnings.String())
+	}
+}
+
+// hugeFS is fsys with a directory "huge" of n files made up as they are listed, in a
+// scrambled order, so no listing of it is ever held whole outside the walk
+type hugeFS struct {
+	fs.FS
+	n       int
+	largest *int // Largest batch asked for; -1 once the whole directory was asked for
+}
+
+// hugeName names the i-th file of the listing; 7919 is prime, so the names are a
+// permutation of f000000 and up
+func (f hugeFS) hugeName(i int) string {
+	return fmt.Sprintf("f%06d", i*7919%f.n)
+}
+
+// Open opens the made-up directory or one of its files, and fsys otherwise
+func (f hugeFS) Open(name string) (fs.File, error) {
+	if name == "huge" {
+		return &hugeDir{hugeFS: f}, nil
+	}
+	if file, ok := strings.CutPrefix(name, "huge/"); ok {
+		if i, err := strconv.Atoi(strings.TrimPrefix(file, "f")); err == nil && i < f.n {
+			return hugeFile{file}, nil
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.FS.Open(name)
+}
+
+// hugeDir lists the made-up files in batches
+type hugeDir struct {
+	hugeFS
+	next int
+}
+
+func (d *hugeDir) Stat() (fs.FileInfo, error) { return hugeInfo{"huge", true}, nil }
+func (d *hugeDir) Read([]byte) (int, error)   { return 0, io.EOF }
+func (d *hugeDir) Close() error               { return nil }
+
+// ReadDir returns the next n files, noting the largest batch
+func (d *hugeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		*d.largest = -1
+		n = d.n
+	} else if *d.largest >= 0 {
+		*d.largest = max(*d.largest, n)
+	}
+	var batch []fs.DirEntry
+	for ; d.next < d.n && len(batch) < n; d.next++ {
+		batch = append(batch, fs.FileInfoToDirEntry(hugeInfo{d.hugeName(d.next), false}))
+	}
+	if len(batch) == 0 {
+		return nil, io.EOF
+	}
+	return batch, nil
+}
+
+// hugeFile is one of the made-up files, empty
+type hugeFile struct{ name string }
+
+func (f hugeFile) Stat() (fs.FileInfo, error) { return hugeInfo{f.name, false}, nil }
+func (f hugeFile) Read([]byte) (int, error)   { return 0, io.EOF }
+func (f hugeFile) Close() error               { return nil }
+
+// hugeInfo describes the made-up directory and its files
+type hugeInfo struct {
+	name  string
+	isDir bool
+}
+
+func (i hugeInfo) Name() string       { return i.name }
+func (i hugeInfo) Size() int64        { return 0 }
+func (i hugeInfo) ModTime() time.Time { return time.Time{} }
+func (i hugeInfo) IsDir() bool        { return i.isDir }
+func (i hugeInfo) Sys() any           { return nil }
+func (i hugeInfo) Mode() fs.FileMode {
+	if i.isDir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// A directory of 20,000 entries past a threshold of 1,000 is read in batches, never
+// whole and never into the listing cache; unsorted it keeps the filesystem's order,
+// with --stream-sort it is merged from spilled runs that are removed afterwards
+func TestStreamHugeDirectory(t *testing.T) {
+	const n = 20000
+	spill := t.TempDir()
+	t.Setenv("TMPDIR", spill)
+	t.Setenv("TMP", spill)
+	for _, sorted := range []bool{false, true} {
+		largest := 0
+		fsys := hugeFS{testtree.MapFS(t, "huge/\nzz.txt\n"), n, &largest}
+		got := renderFixture(t, fsys, true, func() {
+			setOption(t, &streamThreshold, 1000)
+			setOption(t, &streamSorted, sorted)
+			setOption(t, &streamRunSize, 3000)
+			setOption(t, &noSummary, true)
+		})
+		if largest <= 0 || largest > streamBatch {
+			t.Errorf("sorted %v: largest batch read %d, want at most %d", sorted, largest, streamBatch)
+		}
+		for dir, entries := range listingCache {
+			if len(entries) > 1000 {
+				t.Errorf("sorted %v: %s cached with %d entries", sorted, dir, len(entries))
+			}
+		}
+
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		note := " (streamed, unsorted)"
+		if sorted {
+			note = ""
+		}
+		if len(lines) != n+2 || lines[0] != "├── [D] huge"+note || lines[n+1] != "└── [F] zz.txt" {
+			t.Fatalf("sorted %v: %d lines, from %q to %q", sorted, len(lines), lines[0], lines[len(lines)-1])
+		}
+		for i, line := range lines[1 : n+1] {
+			want := fsys.hugeName(i)
+			if sorted {
+				want = fmt.Sprintf("f%06d", i)
+			}
+			if connector := map[bool]string{true: "│   └── ", false: "│   ├── "}[i == n-1]; line != connector+"[F] "+want {
+				t.Fatalf("sorted %v: line %d is %q, want %s", sorted, i+2, line, want)
+			}
+		}
+	}
+	if left, _ := os.ReadDir(spill); len(left) != 0 {
+		t.Errorf("spilled runs left behind: %v", left)
+	}
+}
+
+// The external sort spills a run per streamRunSize records and merges them into the
+// walk's order, however the runs split the names
+func TestExternalSort(t *testing.T) {
+	spill := t.TempDir()
+	t.Setenv("TMPDIR", spill)
+	t.Setenv("TMP", spill)
+	setOption(t, &streamRunSize, 4)
+	var sorter externalSort
+	names := []string{"k", "b/", "x", "a", "m/", "c", "z", "e", "d/", "y", "f"}
+	for _, name := range names {
+		info := hugeInfo{strings.TrimSuffix(name, "/"), strings.HasSuffix(name, "/")}
+		if err := sorter.add(fs.FileInfoToDirEntry(info)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sorter.runs) != 2 {
+		t.Errorf("%d runs spilled, want 2", len(sorter.runs))
+	}
+	if err := sorter.start(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		entry, ok, err := sorter.next("dir")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		got = append(got, name)
+	}
+	if want := "a b/ c d/ e f k m/ x y z"; strings.Join(got, " ") != want {
+		t.Errorf("merged %q, want %s", got, want)
+	}
+	sorter.close()
+	if left, _ := os.ReadDir(spill); len(left) != 0 {
+		t.Errorf("spilled runs left behind: %v", left)
+	}
+}
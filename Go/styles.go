@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// connectors is the style used when printing entries
+var connectors = ftree.Styles["default"]
+
+// styleNames returns the preset names in sorted order
+func styleNames() []string {
+	names := make([]string, 0, len(ftree.Styles))
+	for name := range ftree.Styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupStyle returns the preset with the given name
+func lookupStyle(name string) (ftree.Style, error) {
+	style, ok := ftree.Styles[name]
+	if !ok {
+		return ftree.Style{}, fmt.Errorf("unknown style %q (available: %s)", name, strings.Join(styleNames(), ", "))
+	}
+	return style, nil
+}
+
+// parseConnectors builds a style from "branch,last-branch,pipe-prefix,space-prefix"
+func parseConnectors(spec string) (ftree.Style, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) != 4 {
+		return ftree.Style{}, fmt.Errorf("--connectors needs exactly 4 comma-separated strings, got %d", len(parts))
+	}
+	style := ftree.Style{Branch: parts[0], LastBranch: parts[1], Pipe: parts[2], Space: parts[3]}
+	if ambiguousWide {
+		// Box drawing and spaces that line up elsewhere get the same width here
+		style = alignStyle(style)
+	}
+	return style, validateStyle(style)
+}
+
+// alignStyle pads the narrower of each pair of interchangeable pieces with spaces, for
+// --ambiguous-wide, where the box-drawing characters take two columns and the
+// spaces of the space prefix one
+func alignStyle(s ftree.Style) ftree.Style {
+	pad := func(a, b *string) {
+		wa, wb := displayWidth(*a), displayWidth(*b)
+		*a += strings.Repeat(" ", max(0, wb-wa))
+		*b += strings.Repeat(" ", max(0, wa-wb))
+	}
+	pad(&s.Branch, &s.LastBranch)
+	pad(&s.Pipe, &s.Space)
+	return s
+}
+
+// validateStyle checks that interchangeable pieces share a display width so columns line up
+func validateStyle(s ftree.Style) error {
+	if s.Branch == "" || s.LastBranch == "" {
+		return fmt.Errorf("connectors must not be empty")
+	}
+	if b, l := displayWidth(s.Branch), displayWidth(s.LastBranch); b != l {
+		return fmt.Errorf("branch %q is %d columns wide but last-branch %q is %d", s.Branch, b, s.LastBranch, l)
+	}
+	if p, sp := displayWidth(s.Pipe), displayWidth(s.Space); p != sp {
+		return fmt.Errorf("pipe prefix %q is %d columns wide but space prefix %q is %d", s.Pipe, p, s.Space, sp)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// Every --style preset draws the fixture as its golden shows
+func TestGoldenStyles(t *testing.T) {
+	for _, name := range styleNames() {
+		t.Run(name, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, fixture), true, func() {
+				setOption(t, &connectors, ftree.Styles[name])
+			})
+			testtree.Golden(t, "tree-style-"+name+".md", []byte(got))
+		})
+	}
+}
+
+// --connectors takes pieces of any characters whose interchangeable pairs are as
+// wide, counting a wide character as two columns
+func TestParseConnectors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string // In the error, "" for a valid style
+	}{
+		{"├──,└──,│  ,   ", ""},
+		{"中─,└──,│  ,   ", ""},
+		{"├─,└──,│  ,   ", `branch "├─" is 2 columns wide but last-branch "└──" is 3`},
+		{"├──,└──,中 ,  ", `pipe prefix "中 " is 3 columns wide but space prefix "  " is 2`},
+		{"├──,└──,│  ", "needs exactly 4 comma-separated strings, got 3"},
+		{",,│,  ", "connectors must not be empty"},
+	}
+	for _, test := range tests {
+		_, err := parseConnectors(test.spec)
+		if test.want == "" && err != nil || test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("parseConnectors(%q) = %v, want %q", test.spec, err, test.want)
+		}
+	}
+}
+
+// A custom style is drawn with its pieces, wide ones included
+func TestCustomConnectors(t *testing.T) {
+	dir := testtree.Dir(t, "a/b.txt\nc.txt\n")
+	stdout, stderr, code := runFTG(t, dir, "-d", ".", "--connectors", "中─,└──,中 ,   ", "-o", "-")
+	if want := "中─ [D] a\n中 └── [F] b.txt\n└── [F] c.txt\n"; code != exitOK || !strings.Contains(stdout, want) {
+		t.Errorf("exit code %d:\n%s%s\nwant\n%s", code, stdout, stderr, want)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// formatSVG selects the vector image rendering
+const formatSVG = "svg"
+
+// svgTheme holds the colors of one --svg-theme
+type svgTheme struct {
+	background, text, dir, file string
+}
+
+// svgThemes are the themes accepted by --svg-theme
+var svgThemes = map[string]svgTheme{
+	"light": {background: "#ffffff", text: "#24292f", dir: "#0969da", file: "#6e7781"},
+	"dark":  {background: "#0d1117", text: "#c9d1d9", dir: "#58a6ff", file: "#8b949e"},
+}
+
+var (
+	svgFontSize  = 14      // Font size in pixels
+	svgThemeName = "light" // Color theme
+	svgGlyphs    bool      // Draw folder and file shapes instead of the [D]/[F] tags
+	svgMaxLines  = 2000    // Trees with more lines are cut there; --max-depth shortens them
+	svgCut       bool      // The tree was cut at svgMaxLines
+)
+
+// Geometry of the monospaced text, relative to the font size
+const (
+	svgLineHeight = 1.4 // Line pitch
+	svgCharWidth  = 0.6 // Advance of one column; close to most monospaced fonts
+)
+
+// renderSVG renders the tree as text lines in a monospaced font, with a viewBox
+// computed from the number of lines and the widest line
+func renderSVG(ctx context.Context, root string, entries []fs.DirEntry) ([]byte, error) {
+	var tree bytes.Buffer
+	generateTree(ctx, &tree, root, entries)
+	if ctx.Err() != nil {
+		// A cancelled walk is not measured against svgMaxLines
+		return nil, nil
+	}
+	lines := append([]string{shownRoot(root)}, strings.Split(strings.TrimSuffix(tree.String(), "\n"), "\n")...)
+	if tree.Len() == 0 {
+		lines = lines[:1]
+	}
+	if len(lines) > svgMaxLines {
+		// The last line that fits counts the lines left out
+		left := len(lines) - svgMaxLines + 1
+		lines = append(lines[:svgMaxLines-1], fmt.Sprintf("… %s more lines", groupThousands(left)))
+		svgCut = true
+		noteTruncated()
+		warnf("The SVG stops at %s lines, more than it can hold legibly; use --max-depth to shorten the tree", groupThousands(svgMaxLines))
+	}
+
+	theme := svgThemes[svgThemeName]
+	size := float64(svgFontSize)
+	pitch, advance, pad := size*svgLineHeight, size*svgCharWidth, size
+	columns := 0
+	for _, line := range lines {
+		columns = max(columns, displayWidth(line))
+	}
+	width := 2*pad + float64(columns)*advance
+	height := 2*pad + float64(len(lines))*pitch
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\" font-family=\"ui-monospace, Menlo, Consolas, monospace\" font-size=\"%d\">\n",
+		svgNumber(width), svgNumber(height), svgNumber(width), svgNumber(height), svgFontSize)
+	fmt.Fprintf(&out, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", theme.background)
+	for i, line := range lines {
+		// The baseline sits one font size below the top of the line box
+		y := pad + float64(i)*pitch + size
+		prefix, tag, name, ok := splitTreeLine(line)
+		if i == 0 || !ok {
+			fmt.Fprintf(&out, "<text x=\"%s\" y=\"%s\" fill=\"%s\" xml:space=\"preserve\">%s</text>\n", svgNumber(pad), svgNumber(y), theme.text, svgEscape(line))
+			continue
+		}
+		color := theme.file
+		if tag == "D" {
+			color = theme.dir
+		}
+		x := pad + float64(displayWidth(prefix))*advance
+		fmt.Fprintf(&out, "<text x=\"%s\" y=\"%s\" fill=\"%s\" xml:space=\"preserve\">%s</text>\n", svgNumber(pad), svgNumber(y), theme.text, svgEscape(prefix))
+		if svgGlyphs {
+			out.WriteString(svgGlyph(tag, x, y-size*0.8, size, color))
+		} else {
+			fmt.Fprintf(&out, "<text x=\"%s\" y=\"%s\" fill=\"%s\">[%s]</text>\n", svgNumber(x), svgNumber(y), color, tag)
+		}
+		// The tag and its space take four columns in both modes, so names stay aligned
+		fmt.Fprintf(&out, "<text x=\"%s\" y=\"%s\" fill=\"%s\" xml:space=\"preserve\">%s</text>\n", svgNumber(x+4*advance), svgNumber(y), theme.text, svgEscape(name))
+	}
+	out.WriteString("</svg>\n")
+	return out.Bytes(), nil
+}
+
+// splitTreeLine splits a rendered entry line into its connector prefix, type letter and label
+func splitTreeLine(line string) (string, string, string, bool) {
+	for _, tag := range []string{"D", "F", "L"} {
+		if i := strings.Index(line, " ["+tag+"] "); i >= 0 {
+			return line[:i+1], tag, line[i+len(tag)+4:], true
+		}
+	}
+	return "", "", "", false
+}
+
+// svgGlyph draws a folder or a page in the three columns before a name; top is the top of the line box
+func svgGlyph(tag string, x, top, size float64, color string) string {
+	w, h := size*1.3, size*0.9
+	top += size * 0.1
+	if tag == "D" {
+		// A folder: a tab on the top left of a wider body
+		return fmt.Sprintf("<path d=\"M%s %sh%sl%s %sh%sv%sh%sz\" fill=\"%s\"/>\n",
+			svgNumber(x), svgNumber(top), svgNumber(w*0.4), svgNumber(w*0.1), svgNumber(h*0.15),
+			svgNumber(w*0.5), svgNumber(h*0.85), svgNumber(-w), color)
+	}
+	// A page, outlined; links get a dashed outline
+	dash := ""
+	if tag == "L" {
+		dash = " stroke-dasharray=\"2 1\""
+	}
+	return fmt.Sprintf("<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"none\" stroke=\"%s\"%s/>\n",
+		svgNumber(x+w*0.2), svgNumber(top), svgNumber(w*0.6), svgNumber(h), color, dash)
+}
+
+// svgEscape escapes text for XML, replacing characters XML cannot carry
+func svgEscape(s string) string {
+	var b strings.Builder
+	if err := xml.EscapeText(&b, []byte(s)); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// svgNumber formats a coordinate with at most two decimals
+func svgNumber(v float64) string {
+	s := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
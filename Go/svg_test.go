@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// svgDocument is the part of -f svg output the tests read back
+type svgDocument struct {
+	Width   string `xml:"width,attr"`
+	Height  string `xml:"height,attr"`
+	ViewBox string `xml:"viewBox,attr"`
+	Rects   []struct {
+		Fill   string `xml:"fill,attr"`
+		Stroke string `xml:"stroke,attr"`
+		Dash   string `xml:"stroke-dasharray,attr"`
+	} `xml:"rect"`
+	Paths []struct {
+		Fill string `xml:"fill,attr"`
+	} `xml:"path"`
+	Texts []struct {
+		X    string `xml:"x,attr"`
+		Y    string `xml:"y,attr"`
+		Fill string `xml:"fill,attr"`
+		Body string `xml:",chardata"`
+	} `xml:"text"`
+}
+
+// decodeSVG parses the output with an XML decoder, so a stray & or < fails the test
+func decodeSVG(t *testing.T, data string) svgDocument {
+	t.Helper()
+	var doc svgDocument
+	if err := xml.NewDecoder(strings.NewReader(data)).Decode(&doc); err != nil {
+		t.Fatalf("the SVG is not well-formed XML: %v\n%s", err, data)
+	}
+	return doc
+}
+
+const svgTree = "a<b/x&y.txt\nc.go\nlink -> c.go\n"
+
+// The viewBox holds the lines at 1.4 font sizes apart and the widest line at 0.6 of a
+// font size a column, inside a padding of one font size; names come back unescaped
+func TestSVGGeometry(t *testing.T) {
+	for _, test := range []struct {
+		size                  int
+		glyphs                bool
+		width, height, pitch  string
+		baselines, nameStarts []string
+	}{
+		// Five lines; the widest, "└── [L] link -> c.go", is 20 columns
+		{10, false, "140", "90", "14", []string{"20", "34", "48", "62", "76"}, []string{"58", "82", "58", "58"}},
+		{20, true, "280", "180", "28", []string{"40", "68", "96", "124", "152"}, []string{"116", "164", "116", "116"}},
+	} {
+		got := renderFixture(t, testtree.MapFS(t, svgTree), true, func() {
+			setOption(t, &outputFormat, formatSVG)
+			setOption(t, &svgFontSize, test.size)
+			setOption(t, &svgGlyphs, test.glyphs)
+		})
+		doc := decodeSVG(t, got)
+		if doc.Width != test.width || doc.Height != test.height || doc.ViewBox != "0 0 "+test.width+" "+test.height {
+			t.Errorf("size %d: width %s, height %s, viewBox %q; want %s by %s", test.size, doc.Width, doc.Height, doc.ViewBox, test.width, test.height)
+		}
+
+		var baselines, names, nameStarts, tags []string
+		for i, text := range doc.Texts {
+			if i == 0 || text.X != doc.Texts[0].X {
+				// Tags and names start right of the connectors; the root line and the connectors at the padding
+				if strings.HasPrefix(text.Body, "[") {
+					tags = append(tags, text.Body)
+				} else {
+					names, nameStarts = append(names, text.Body), append(nameStarts, text.X)
+				}
+				if i == 0 {
+					baselines = append(baselines, text.Y)
+				}
+				continue
+			}
+			baselines = append(baselines, text.Y)
+		}
+		if strings.Join(baselines, " ") != strings.Join(test.baselines, " ") {
+			t.Errorf("size %d: baselines %q, want %q, %s apart", test.size, baselines, test.baselines, test.pitch)
+		}
+		if want := "fixture|a<b|x&y.txt|c.go|link -> c.go"; strings.Join(names, "|") != want {
+			t.Errorf("size %d: names %q, want %s", test.size, names, want)
+		}
+		if strings.Join(nameStarts[1:], " ") != strings.Join(test.nameStarts, " ") {
+			t.Errorf("size %d: names start at %q, want %q", test.size, nameStarts[1:], test.nameStarts)
+		}
+
+		if test.glyphs {
+			// One folder, two pages and a dashed page for the link, after the background
+			if len(tags) != 0 || len(doc.Paths) != 1 || len(doc.Rects) != 4 || doc.Rects[3].Dash == "" || doc.Rects[1].Dash != "" {
+				t.Errorf("glyphs: tags %q, %d paths, rects %+v", tags, len(doc.Paths), doc.Rects)
+			}
+		} else if strings.Join(tags, " ") != "[D] [F] [F] [L]" || len(doc.Paths) != 0 || len(doc.Rects) != 1 {
+			t.Errorf("tags %q, %d paths, %d rects; want the [D]/[F]/[L] tags and only the background", tags, len(doc.Paths), len(doc.Rects))
+		}
+	}
+}
+
+// --svg-theme colors the background, the text, and directories apart from files, in
+// the tags and in the glyphs alike
+func TestSVGThemes(t *testing.T) {
+	for name, theme := range svgThemes {
+		for _, glyphs := range []bool{false, true} {
+			doc := decodeSVG(t, renderFixture(t, testtree.MapFS(t, "dir/\nfile.txt\n"), true, func() {
+				setOption(t, &outputFormat, formatSVG)
+				setOption(t, &svgThemeName, name)
+				setOption(t, &svgGlyphs, glyphs)
+			}))
+			if doc.Rects[0].Fill != theme.background || doc.Texts[0].Fill != theme.text {
+				t.Errorf("%s: background %s, text %s", name, doc.Rects[0].Fill, doc.Texts[0].Fill)
+			}
+			dir, file := "", ""
+			if glyphs {
+				dir, file = doc.Paths[0].Fill, doc.Rects[1].Stroke
+			} else {
+				for _, text := range doc.Texts {
+					switch text.Body {
+					case "[D]":
+						dir = text.Fill
+					case "[F]":
+						file = text.Fill
+					}
+				}
+			}
+			if dir != theme.dir || file != theme.file {
+				t.Errorf("%s, glyphs %v: directory %s, file %s; want %s and %s", name, glyphs, dir, file, theme.dir, theme.file)
+			}
+		}
+	}
+}
+
+// A tree over the line limit still renders as valid XML, sized to the lines kept, and
+// warns with the way to shorten it
+func TestSVGLineLimit(t *testing.T) {
+	logs := captureWarnings(t)
+	doc := decodeSVG(t, renderFixture(t, syntheticTree(3, 2), true, func() {
+		setOption(t, &outputFormat, formatSVG)
+		setOption(t, &svgMaxLines, 4)
+		setOption(t, &svgFontSize, 10)
+		setOption(t, &svgCut, false)
+		setOption(t, &outputTruncated, false)
+		setOption(t, &progress.warnings, 0)
+		setOption(t, &messages, io.Discard)
+	}))
+	if last := doc.Texts[len(doc.Texts)-1].Body; last != "… 10 more lines" || doc.Height != "76" {
+		t.Errorf("last line %q, height %s; want the 10 lines left out counted on the fourth line, 76 high", last, doc.Height)
+	}
+	if !strings.Contains(logs.String(), "The SVG stops at 4 lines") || !strings.Contains(logs.String(), "use --max-depth") {
+		t.Errorf("warnings:\n%s", logs)
+	}
+
+	_, stderr, code := runFTG(t, t.TempDir(), "-d", testtree.Dir(t, "a.txt\n"), "-f", "svg", "--svg-theme", "sepia", "-o", "-")
+	if code != exitUsage || !strings.Contains(stderr, `unknown --svg-theme "sepia"`) {
+		t.Errorf("--svg-theme sepia: exit code %d, %s", code, stderr)
+	}
+	_, stderr, code = runFTG(t, t.TempDir(), "-d", testtree.Dir(t, "a.txt\n"), "-f", "svg", "--svg-font-size", "0", "-o", "-")
+	if code != exitUsage || !strings.Contains(stderr, "--svg-font-size must be at least 1") {
+		t.Errorf("--svg-font-size 0: exit code %d, %s", code, stderr)
+	}
+}
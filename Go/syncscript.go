@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Script languages of "ftg diff --emit-sync-script"
+const (
+	syncScriptSh         = "sh"
+	syncScriptPowerShell = "powershell"
+)
+
+var (
+	syncScript     string // --emit-sync-script: print a script that changes a copy of the old tree into the new one
+	includeDeletes bool   // --include-deletes: let the sync script delete what the new tree no longer has
+)
+
+// syncOp is one step of a sync script, on a path relative to both roots
+type syncOp struct {
+	action string // mkdir, copy, link, delete, rmdir, or skip with the reason in note
+	path   string
+	note   string
+}
+
+// planSync lists the steps turning the old tree into the new one. Deletions come
+// first, each directory's contents before the directory; then creations, each
+// directory before its contents. Without --include-deletes removed entries stay, and
+// entries whose type changed are skipped, since the old one would have to go first.
+func planSync(before, after *diffNode) (deletes, creates []syncOp) {
+	var walk func(before, after *diffNode, parent string)
+	walk = func(before, after *diffNode, parent string) {
+		for _, name := range diffNames(before, after) {
+			oldChild, newChild := diffChild(before, name), diffChild(after, name)
+			rel := strings.TrimPrefix(parent+"/"+name, "/")
+			switch diffChangeOf(oldChild, newChild) {
+			case diffAdded:
+				creates = appendCreates(creates, newChild, rel)
+			case diffRemoved:
+				if includeDeletes {
+					deletes = appendDeletes(deletes, oldChild, rel)
+				}
+			case diffTypeChanged:
+				if !includeDeletes {
+					creates = append(creates, syncOp{action: "skip", path: rel, note: fmt.Sprintf("was [%s], now [%s]; replacing it needs --include-deletes", oldChild.kind, newChild.kind)})
+					continue
+				}
+				deletes = appendDeletes(deletes, oldChild, rel)
+				creates = appendCreates(creates, newChild, rel)
+			default:
+				walk(oldChild, newChild, rel)
+			}
+		}
+	}
+	walk(before, after, "")
+	return deletes, creates
+}
+
+// appendCreates adds the steps creating an entry and everything below it, parents first
+func appendCreates(ops []syncOp, node *diffNode, rel string) []syncOp {
+	switch node.kind {
+	case "D":
+		ops = append(ops, syncOp{action: "mkdir", path: rel})
+	case "L":
+		return append(ops, syncOp{action: "link", path: rel})
+	default:
+		return append(ops, syncOp{action: "copy", path: rel})
+	}
+	for _, name := range diffNames(node, nil) {
+		ops = appendCreates(ops, node.children[name], rel+"/"+name)
+	}
+	return ops
+}
+
+// appendDeletes adds the steps deleting an entry and everything below it, children first
+func appendDeletes(ops []syncOp, node *diffNode, rel string) []syncOp {
+	if node.kind != "D" {
+		return append(ops, syncOp{action: "delete", path: rel})
+	}
+	for _, name := range diffNames(node, nil) {
+		ops = appendDeletes(ops, node.children[name], rel+"/"+name)
+	}
+	return append(ops, syncOp{action: "rmdir", path: rel})
+}
+
+// writeSyncScript prints the sync script for a diff. The source root, from which
+// files are copied, defaults to the new directory and the destination to the current
+// directory; both can be passed when the script runs. Files present in both trees
+// are not compared, so only the layout is synced.
+func writeSyncScript(writer io.Writer, report diffReport, newDir string, before, after *diffNode) {
+	source, err := filepath.Abs(newDir)
+	if err != nil {
+		source = newDir
+	}
+	deletes, creates := planSync(before, after)
+	removed := report.Summary.Removed + report.Summary.TypeChanged
+	if syncScript == syncScriptPowerShell {
+		writePowerShellSync(writer, report, source, deletes, creates, removed)
+		return
+	}
+	fmt.Fprintf(writer, "#!/bin/sh\n# Generated by ftg diff: changes a copy of %s into the layout of %s.\n", scriptComment(report.Old), scriptComment(report.New))
+	fmt.Fprintln(writer, "# Usage: sh sync.sh [source] [destination]; files are copied from source, by default\n# the new directory, into destination, by default the current directory.")
+	fmt.Fprintln(writer, "set -eu")
+	fmt.Fprintf(writer, "src=${1:-%s}\ndst=${2:-.}\n", shellQuote(source))
+	if !includeDeletes && removed > 0 {
+		fmt.Fprintf(writer, "# %s removed or replaced left in place; rerun ftg diff with --include-deletes to delete them\n", treeCount(removed, "entry", "entries"))
+	}
+	for _, op := range append(deletes, creates...) {
+		dst, src := `"$dst"/`+shellQuote(op.path), `"$src"/`+shellQuote(op.path)
+		switch op.action {
+		case "mkdir":
+			fmt.Fprintf(writer, "mkdir -p -- %s\n", dst)
+		case "copy":
+			fmt.Fprintf(writer, "cp -p -- %s %s\n", src, dst)
+		case "link":
+			fmt.Fprintf(writer, "cp -P -p -- %s %s\n", src, dst)
+		case "delete":
+			fmt.Fprintf(writer, "rm -f -- %s\n", dst)
+		case "rmdir":
+			fmt.Fprintf(writer, "rmdir -- %s\n", dst)
+		case "skip":
+			fmt.Fprintf(writer, "# skipped %s: %s\n", scriptComment(op.path), op.note)
+		}
+	}
+}
+
+// writePowerShellSync prints the sync script in PowerShell. Paths are passed with
+// -LiteralPath, or to parameters that take them literally, so [ and ] stay names.
+func writePowerShellSync(writer io.Writer, report diffReport, source string, deletes, creates []syncOp, removed int) {
+	fmt.Fprintf(writer, "# Generated by ftg diff: changes a copy of %s into the layout of %s.\n", scriptComment(report.Old), scriptComment(report.New))
+	fmt.Fprintln(writer, "# Usage: ./sync.ps1 [-Source dir] [-Destination dir]; files are copied from Source, by default\n# the new directory, into Destination, by default the current directory.")
+	fmt.Fprintf(writer, "param([string]$Source = %s, [string]$Destination = '.')\n", powerShellQuote(source))
+	fmt.Fprintln(writer, "$ErrorActionPreference = 'Stop'")
+	if !includeDeletes && removed > 0 {
+		fmt.Fprintf(writer, "# %s removed or replaced left in place; rerun ftg diff with --include-deletes to delete them\n", treeCount(removed, "entry", "entries"))
+	}
+	for _, op := range append(deletes, creates...) {
+		rel := powerShellQuote(op.path)
+		dst, src := "(Join-Path $Destination "+rel+")", "(Join-Path $Source "+rel+")"
+		switch op.action {
+		case "mkdir":
+			fmt.Fprintf(writer, "New-Item -ItemType Directory -Force -Path %s | Out-Null\n", dst)
+		case "copy":
+			fmt.Fprintf(writer, "Copy-Item -LiteralPath %s -Destination %s\n", src, dst)
+		case "link":
+			fmt.Fprintf(writer, "New-Item -ItemType SymbolicLink -Path %s -Target (Get-Item -LiteralPath %s).Target | Out-Null\n", dst, src)
+		case "delete":
+			fmt.Fprintf(writer, "Remove-Item -LiteralPath %s -Force\n", dst)
+		case "rmdir":
+			fmt.Fprintf(writer, "Remove-Item -LiteralPath %s\n", dst)
+		case "skip":
+			fmt.Fprintf(writer, "# skipped %s: %s\n", scriptComment(op.path), op.note)
+		}
+	}
+}
+
+// powerShellQuoteChars are the characters PowerShell takes as a single quote,
+// typographic ones included; each is doubled inside a single-quoted string
+var powerShellQuoteChars = strings.NewReplacer("'", "''", "‘", "‘‘", "’", "’’", "‚", "‚‚", "‛", "‛‛")
+
+// powerShellQuote quotes an argument as a PowerShell verbatim string
+func powerShellQuote(arg string) string {
+	return "'" + powerShellQuoteChars.Replace(arg) + "'"
+}
+
+// scriptComment keeps a path on one comment line: line breaks in names would end
+// the comment and run the rest as a command
+func scriptComment(path string) string {
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(path)
+}
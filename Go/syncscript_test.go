@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// syncTrees writes the old and new trees of the sync script tests into dir: a
+// directory removed with what it holds, a file that became a directory, additions
+// nested three deep, a link, and names that need quoting in both shells
+func syncTrees(t *testing.T, dir string) {
+	t.Helper()
+	for _, file := range []string{
+		"old/gone/sub/f.txt", "old/t", "old/keep.txt",
+		"new/keep.txt", "new/new/deep/x.go", "new/t/in.txt", "new/it's here/$x y.txt",
+	} {
+		full := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(file+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("deep", filepath.Join(dir, "new", "new", "current")); err != nil {
+		t.Skipf("no symbolic links: %v", err)
+	}
+}
+
+// The scripts for both shells, with and without deletions, quote every name and order
+// deletions children first and creations parents first
+func TestGoldenSyncScripts(t *testing.T) {
+	dir := t.TempDir()
+	syncTrees(t, dir)
+	for _, test := range []struct {
+		golden string
+		args   []string
+	}{
+		{"sync.sh", []string{"--emit-sync-script", "sh"}},
+		{"sync-deletes.sh", []string{"--emit-sync-script", "sh", "--include-deletes"}},
+		{"sync-deletes.ps1", []string{"--emit-sync-script", "powershell", "--include-deletes"}},
+	} {
+		t.Run(test.golden, func(t *testing.T) {
+			stdout, stderr, code := runFTG(t, dir, append([]string{"diff"}, append(test.args, "old", "new")...)...)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			testtree.Golden(t, test.golden, []byte(strings.ReplaceAll(stdout, dir, "/work")))
+		})
+	}
+}
+
+// The sh script with --include-deletes turns a copy of the old tree into one ftg diff
+// finds identical to the new tree
+func TestSyncScriptRuns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+	dir := t.TempDir()
+	syncTrees(t, dir)
+	copyDir := testtree.Dir(t, "gone/sub/f.txt\nt\nkeep.txt\n")
+	script, stderr, code := runFTG(t, dir, "diff", "--emit-sync-script", "sh", "--include-deletes", "old", "new")
+	if code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	cmd := exec.Command(sh, "-s", filepath.Join(dir, "new"), copyDir)
+	cmd.Stdin = strings.NewReader(script)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("the script failed: %v\n%s", err, out)
+	}
+	if stdout, stderr, code := runFTG(t, dir, "diff", copyDir, "new"); code != exitOK {
+		t.Errorf("exit code %d after the script, %s\n%s", code, stderr, stdout)
+	}
+}
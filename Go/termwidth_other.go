@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "os"
+
+// terminalWidth cannot ask the terminal here; COLUMNS is used instead
+func terminalWidth(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// testPatterns implements "ftg test-pattern [--exclude-from file]... pattern... -- path...":
+// it decides each literal path with the same matcher the walk uses, without touching
+// the filesystem. Like the walk, a path is hidden when it or any of its ancestors
+// matches; a "!pattern" that keeps it is reported with where it came from.
+func testPatterns(args []string) {
+	split := -1
+	for i, arg := range args {
+		if arg == "--" {
+			split = i
+			break
+		}
+	}
+	if split <= 0 || split == len(args)-1 {
+		usageExit("usage: ftg test-pattern [--exclude-from file]... pattern... -- path...")
+	}
+
+	// Files are read in order with the patterns, as --exclude-from is during a run
+	for i := 0; i < split; i++ {
+		arg := args[i]
+		if arg == "--exclude-from" {
+			if i+1 == split {
+				usageExit("--exclude-from needs a file")
+			}
+			i++
+			if err := loadExcludeFile(args[i]); err != nil {
+				usageExit(fmt.Sprintf("Cannot read the --exclude-from file: %v", err))
+			}
+			continue
+		}
+		for _, pattern := range strings.Split(arg, ",") {
+			addExcludeRule(sourceCLI, fmt.Sprintf("test-pattern argument %d", i+1), pattern)
+		}
+	}
+
+	for _, target := range args[split+1:] {
+		rel := strings.Trim(path.Clean(strings.ReplaceAll(target, "\\", "/")), "/")
+		if rel == "." || rel == "" {
+			fmt.Printf("%s: the input directory itself, never excluded\n", target)
+			continue
+		}
+		fmt.Printf("%s: %s\n", target, patternVerdict(rel))
+	}
+}
+
+// patternVerdict decides rel from its top directory down: the first excluded level
+// hides it, and otherwise the deepest negation that matched is what kept it
+func patternVerdict(rel string) string {
+	verdict := "not excluded"
+	parts := strings.Split(rel, "/")
+	for i, name := range parts {
+		current := strings.Join(parts[:i+1], "/")
+		rule, _, found := decidingRule(current, name, current != rel)
+		if !found {
+			continue
+		}
+		if rule.include {
+			verdict = "kept by " + rule.describe()
+		} else {
+			verdict = "excluded by " + rule.describe()
+		}
+		if current != rel {
+			verdict += " (matched ancestor " + current + ")"
+		}
+		if !rule.include {
+			break
+		}
+	}
+	return verdict
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestTestPattern(t *testing.T) {
+	dir := testtree.Dir(t, `rules.ignore content="# build output\r\n*.log\r\n!keep.log\r\n\r\nsrc/**/gen\r\n"`)
+	tests := []struct {
+		patterns []string
+		path     string
+		want     string
+	}{
+		{[]string{"*.log"}, "a/debug.log", `excluded by pattern "*.log" (test-pattern argument 1)`},
+		{[]string{"*.log"}, "a/debug.txt", "not excluded"},
+		{[]string{"build"}, "build/out/app", `excluded by pattern "build" (test-pattern argument 1) (matched ancestor build)`},
+		{[]string{"src/**/gen"}, "src/gen", `excluded by pattern "src/**/gen" (test-pattern argument 1)`},
+		{[]string{"src/**/gen"}, "src/a/b/gen/x.go", `excluded by pattern "src/**/gen" (test-pattern argument 1) (matched ancestor src/a/b/gen)`},
+		{[]string{"src/**/gen"}, "lib/src/gen", "not excluded"},
+		{[]string{"/src"}, "src", `excluded by pattern "/src" (test-pattern argument 1)`},
+		// The last matching rule of a source wins, so a later negation keeps the path
+		{[]string{"*.log", "!keep.log"}, "keep.log", `kept by pattern "!keep.log" (test-pattern argument 2)`},
+		{[]string{"!keep.log", "*.log"}, "keep.log", `excluded by pattern "*.log" (test-pattern argument 2)`},
+		{[]string{"*.log,!keep.log"}, "x/keep.log", `kept by pattern "!keep.log" (test-pattern argument 1)`},
+		// A kept ancestor does not keep what a rule excludes below it
+		{[]string{"logs", "!logs", "*.tmp"}, "logs/a.tmp", `excluded by pattern "*.tmp" (test-pattern argument 3)`},
+		{[]string{"--exclude-from", "rules.ignore"}, "a/keep.log", `kept by pattern "!keep.log" (user (--exclude-from rules.ignore:3))`},
+		{[]string{"--exclude-from", "rules.ignore"}, "a/x.log", `excluded by pattern "*.log" (user (--exclude-from rules.ignore:2))`},
+		{[]string{"--exclude-from", "rules.ignore"}, "src/x/gen", `excluded by pattern "src/**/gen" (user (--exclude-from rules.ignore:5))`},
+		{[]string{"--exclude-from", "rules.ignore"}, ".", "the input directory itself, never excluded"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.patterns, " ")+" "+test.path, func(t *testing.T) {
+			args := append(append([]string{"test-pattern"}, test.patterns...), "--", test.path)
+			stdout, stderr, code := runFTG(t, dir, args...)
+			if code != exitOK {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			if want := test.path + ": " + test.want + "\n"; stdout != want {
+				t.Errorf("got  %q\nwant %q", stdout, want)
+			}
+		})
+	}
+}
+
+func TestTestPatternUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"test-pattern", "*.log"},
+		{"test-pattern", "--", "a"},
+		{"test-pattern", "*.log", "--"},
+		{"test-pattern", "--exclude-from", "--", "a"},
+		{"test-pattern", "--exclude-from", "missing.ignore", "--", "a"},
+	} {
+		if _, _, code := runFTG(t, t.TempDir(), args...); code != exitUsage {
+			t.Errorf("%q: exit code %d, want %d", args, code, exitUsage)
+		}
+	}
+}
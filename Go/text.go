@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+const formatText = "text" // Plain text laid out like the output of tree(1)
+
+var (
+	trailingSlash bool // --trailing-slash: end directory names with "/" in -f text
+	textDirs      int  // Directories printed by -f text, for the summary line
+	textFiles     int  // Other entries printed by -f text
+)
+
+// renderText renders the tree the way tree(1) prints it: the root as given, entries
+// without type tags and a count of directories and files at the bottom
+func renderText(ctx context.Context, root string, entries []fs.DirEntry) ([]byte, error) {
+	var out bytes.Buffer
+	name := "."
+	if flagSet("d") || rootLabel != "auto" {
+		name = shownRoot(root)
+	}
+	fmt.Fprintln(&out, painter.name(name, classDir))
+	generateTree(ctx, &out, root, entries)
+	if scan.DirsOnly {
+		fmt.Fprintf(&out, "\n%s\n", treeCount(textDirs, "directory", "directories"))
+	} else {
+		fmt.Fprintf(&out, "\n%s, %s\n", treeCount(textDirs, "directory", "directories"), treeCount(textFiles, "file", "files"))
+	}
+	if budgetNote != "" {
+		fmt.Fprintf(&out, "\n%s\n", budgetNote)
+	}
+	if nameStatsEnabled {
+		writeNameStats(&out)
+	}
+	return out.Bytes(), nil
+}
+
+// printTextEntry writes one line of -f text and counts it
+func printTextEntry(writer io.Writer, style treePainter, name, entryType, class, lead string) {
+	if entryType == "D" {
+		textDirs++
+		if trailingSlash && entryTemplate == nil {
+			name += "/"
+		}
+	} else {
+		textFiles++
+	}
+	if _, err := fmt.Fprintf(writer, "%s %s\n", style.connector(lead), style.name(name, class)); err != nil {
+		warnf("Error writing entry: %v", err)
+	}
+}
+
+// treeCount formats a count of the summary line in tree(1)'s wording
+func treeCount(count int, one, other string) string {
+	if count == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", count, other)
+}
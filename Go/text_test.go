@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestGoldenTextTrailingSlash(t *testing.T) {
+	got := renderFixture(t, testtree.MapFS(t, fixture), false, func() {
+		setOption(t, &outputFormat, formatText)
+		setOption(t, &trailingSlash, true)
+	})
+	testtree.Golden(t, "tree-slash.txt", []byte(got))
+}
+
+// Without -o, -f text writes a .txt file named like the other defaults
+func TestTextDefaultsToTxt(t *testing.T) {
+	dir := testtree.Dir(t, "a.go\n")
+	if _, stderr, code := runFTG(t, dir, "-f", "text", "-q"); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	written, _ := filepath.Glob(filepath.Join(dir, "file_tree_*.txt"))
+	if len(written) != 1 {
+		entries, _ := os.ReadDir(dir)
+		t.Fatalf("want one file_tree_*.txt, got %v", entries)
+	}
+	data, err := os.ReadFile(written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), ".\n└── a.go\n") {
+		t.Errorf("%s holds\n%s", filepath.Base(written[0]), data)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"time"
+)
+
+// Shortcuts accepted by --time-format
+const (
+	timeFormatISO      = "iso"
+	timeFormatUnix     = "unix"
+	timeFormatRelative = "relative"
+)
+
+var (
+	showMTime  bool       // --mtime: annotate each entry with its modification time
+	timeFormat string     // --time-format: layout or shortcut of timestamp annotations
+	clockNow   = time.Now // Current time for relative timestamps; a variable so a fixed time can replace it
+)
+
+// parseTimeFormat validates --time-format: a shortcut, or a Go reference layout
+// such as "Jan 2 15:04", which must contain at least one element of the layout
+func parseTimeFormat(spec string) error {
+	switch spec {
+	case "", timeFormatISO, timeFormatUnix, timeFormatRelative:
+		return nil
+	}
+	if time.Unix(0, 0).UTC().Format(spec) == spec {
+		return fmt.Errorf("--time-format %q has no element of the reference time 2006-01-02 15:04:05; use a layout like \"Jan 2 15:04\", or iso, unix or relative", spec)
+	}
+	return nil
+}
+
+// formatTimestamp formats a time for the labels of --mtime, --btime and --git-age
+func formatTimestamp(t time.Time) string {
+	switch timeFormat {
+	case timeFormatISO:
+		return t.Format(time.RFC3339)
+	case timeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case timeFormatRelative:
+		return relativeTime(t, clockNow())
+	case "":
+		return t.Format(timeLayout)
+	}
+	return t.Format(timeFormat)
+}
+
+// relativeTime describes how long before now a time was, e.g. "3 days ago", in the
+// largest whole unit; times after now read "in 3 days"
+func relativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	if d < time.Minute {
+		return "just now"
+	}
+	units := []struct {
+		size       time.Duration
+		one, other string
+	}{
+		{365 * 24 * time.Hour, "year", "years"},
+		{30 * 24 * time.Hour, "month", "months"},
+		{7 * 24 * time.Hour, "week", "weeks"},
+		{24 * time.Hour, "day", "days"},
+		{time.Hour, "hour", "hours"},
+		{time.Minute, "minute", "minutes"},
+	}
+	for _, unit := range units {
+		if d >= unit.size {
+			amount := treeCount(int(d/unit.size), unit.one, unit.other)
+			if future {
+				return "in " + amount
+			}
+			return amount + " ago"
+		}
+	}
+	return "just now"
+}
+
+// mtimeNote returns "(<modification time>)" with --mtime, "(?)" when the entry
+// cannot be stat'ed
+func mtimeNote(entry fs.DirEntry) string {
+	if !showMTime {
+		return ""
+	}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return "(?)"
+	}
+	return "(" + formatTimestamp(info.ModTime()) + ")"
+}
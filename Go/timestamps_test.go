@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/faultfs"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// mtimeFixture has entries modified at known times, the directory included
+const mtimeFixture = `
+go.mod mtime=2024-03-12T09:41:00Z
+src/ mtime=2024-03-10T18:05:00Z
+src/main.go mtime=2023-01-01T00:00:00Z
+`
+
+// --mtime annotates every entry with its modification time in each --time-format,
+// relative times counted from a fixed now
+func TestMTime(t *testing.T) {
+	setOption(t, &time.Local, time.UTC)
+	setOption(t, &clockNow, func() time.Time { return time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC) })
+	for _, test := range []struct {
+		format          string
+		mod, dir, inner string
+	}{
+		{"", "2024-03-12 09:41", "2024-03-10 18:05", "2023-01-01 00:00"},
+		{timeFormatISO, "2024-03-12T09:41:00Z", "2024-03-10T18:05:00Z", "2023-01-01T00:00:00Z"},
+		{timeFormatUnix, "1710236460", "1710093900", "1672531200"},
+		{timeFormatRelative, "3 days ago", "4 days ago", "1 year ago"},
+		{"Jan 2 15:04", "Mar 12 09:41", "Mar 10 18:05", "Jan 1 00:00"},
+	} {
+		got := renderFixture(t, testtree.MapFS(t, mtimeFixture), true, func() {
+			setOption(t, &showMTime, true)
+			setOption(t, &timeFormat, test.format)
+			setOption(t, &noSummary, true)
+		})
+		want := "├── [F] go.mod (" + test.mod + ")\n" +
+			"└── [D] src (" + test.dir + ")\n" +
+			"    └── [F] main.go (" + test.inner + ")\n"
+		if got != want {
+			t.Errorf("--time-format %q: got\n%s\nwant\n%s", test.format, got, want)
+		}
+	}
+
+	var root struct {
+		Children []struct{ Name, MTime string }
+	}
+	got := renderFixture(t, testtree.MapFS(t, mtimeFixture), true, func() {
+		setOption(t, &outputFormat, formatJSON)
+		setOption(t, &showMTime, true)
+		setOption(t, &timeFormat, timeFormatRelative)
+	})
+	if err := json.Unmarshal([]byte(got), &root); err != nil || root.Children[0].MTime != "2024-03-12T09:41:00Z" {
+		t.Errorf("json, whatever --time-format: %v, %+v", err, root.Children)
+	}
+}
+
+// An entry whose Info fails shows (?) in place of its time, and the tree goes on
+func TestMTimeUnavailable(t *testing.T) {
+	setOption(t, &time.Local, time.UTC)
+	captureWarnings(t)
+	fsys := faultfs.New(testtree.MapFS(t, mtimeFixture), map[string]faultfs.Fault{"go.mod": faultfs.InfoError})
+	got := renderFaulty(t, fsys, nil, func() {
+		setOption(t, &showMTime, true)
+		setOption(t, &noSummary, true)
+		setOption(t, &messages, io.Discard)
+	})
+	if !strings.Contains(got, "├── [F] go.mod (?)\n") || !strings.Contains(got, "    └── [F] main.go (2023-01-01 00:00)\n") {
+		t.Errorf("got\n%s\nwant go.mod with (?) and the rest with times", got)
+	}
+}
+
+// Relative times use the largest whole unit, either side of now
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		ago  time.Duration
+		want string
+	}{
+		{0, "just now"},
+		{59 * time.Second, "just now"},
+		{-30 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{90 * time.Minute, "1 hour ago"},
+		{47 * time.Hour, "1 day ago"},
+		{13 * 24 * time.Hour, "1 week ago"},
+		{29 * 24 * time.Hour, "4 weeks ago"},
+		{45 * 24 * time.Hour, "1 month ago"},
+		{800 * 24 * time.Hour, "2 years ago"},
+		{-3 * 24 * time.Hour, "in 3 days"},
+		{-2 * time.Hour, "in 2 hours"},
+	} {
+		if got := relativeTime(now.Add(-test.ago), now); got != test.want {
+			t.Errorf("%s ago: %q, want %q", test.ago, got, test.want)
+		}
+	}
+}
+
+// --time-format takes the shortcuts and any layout with an element of the reference time
+func TestParseTimeFormat(t *testing.T) {
+	for spec, ok := range map[string]bool{
+		"": true, "iso": true, "unix": true, "relative": true, "Jan 2 15:04": true, "2006": true, "15h04": true,
+		"iso8601": false, "yyyy-mm-dd": false, "%Y-%m-%d": false,
+	} {
+		if err := parseTimeFormat(spec); (err == nil) != ok {
+			t.Errorf("%q: %v", spec, err)
+		}
+	}
+	_, stderr, code := runFTG(t, t.TempDir(), "--mtime", "--time-format", "yyyy", "-o", "-")
+	if code != exitUsage || !strings.Contains(stderr, `--time-format "yyyy" has no element of the reference time`) {
+		t.Errorf("exit code %d, %s", code, stderr)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Update check settings
+var (
+	releasesAPI       = "https://api.github.com/repos/EastTexasElectronics/File-Tree-Generator-Multiverse/releases/latest"
+	updateTimeout     = 5 * time.Second
+	updateOptOutEnv   = "FTG_NO_UPDATE_CHECK" // Any non-empty value disables all network access for check-update
+	releasesChangelog = "https://github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/releases"
+)
+
+// semver is a parsed semantic version
+type semver struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseSemver reads versions like "1.2.3", "v1.2.3-rc.1" or "go-v1.2.3"; build metadata is ignored
+func parseSemver(s string) (semver, error) {
+	start := strings.IndexAny(s, "0123456789")
+	if start < 0 {
+		return semver{}, fmt.Errorf("invalid version %q", s)
+	}
+	core, _, _ := strings.Cut(s[start:], "+")
+	core, pre, hasPre := strings.Cut(core, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, fmt.Errorf("invalid version %q", s)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, fmt.Errorf("invalid version %q", s)
+		}
+		nums[i] = n
+	}
+	v := semver{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, nil
+}
+
+// compareSemver returns -1, 0 or 1 following semver precedence rules
+func compareSemver(a, b semver) int {
+	for _, d := range []int{a.major - b.major, a.minor - b.minor, a.patch - b.patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	// A release outranks any of its pre-releases
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		x, errX := strconv.Atoi(a.pre[i])
+		y, errY := strconv.Atoi(b.pre[i])
+		switch {
+		case errX == nil && errY == nil:
+			if x != y {
+				return sign(x - y)
+			}
+		case errX == nil:
+			return -1 // Numeric identifiers sort before alphanumeric ones
+		case errY == nil:
+			return 1
+		default:
+			if c := strings.Compare(a.pre[i], b.pre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return sign(len(a.pre) - len(b.pre))
+}
+
+// sign reduces an integer to -1, 0 or 1
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// latestRelease asks the releases API for the newest tag and its page
+func latestRelease(url string) (tag, page string, err error) {
+	client := &http.Client{Timeout: updateTimeout}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "ftg/"+version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("releases API responded %s", resp.Status)
+	}
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", "", fmt.Errorf("unreadable releases API response: %v", err)
+	}
+	if release.TagName == "" {
+		return "", "", errors.New("releases API returned no tag")
+	}
+	if release.HTMLURL == "" {
+		release.HTMLURL = releasesChangelog
+	}
+	return release.TagName, release.HTMLURL, nil
+}
+
+// updateResult is the --json output of check-update
+type updateResult struct {
+	Current         string `json:"current"`
+	Latest          string `json:"latest,omitempty"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+	URL             string `json:"url,omitempty"`
+	Warning         string `json:"warning,omitempty"`
+}
+
+// checkUpdate implements "ftg check-update [--json]"; failures are soft and exit 0
+func checkUpdate(args []string) {
+	fset := flag.NewFlagSet("check-update", flag.ExitOnError)
+	jsonOutput := fset.Bool("json", false, "Print the result as JSON")
+	_ = fset.Parse(args)
+
+	result := updateResult{Current: version}
+	switch {
+	case os.Getenv(updateOptOutEnv) != "":
+		result.Warning = "update check disabled by " + updateOptOutEnv
+	default:
+		tag, page, err := latestRelease(releasesAPI)
+		if err != nil {
+			result.Warning = "could not check for updates: " + err.Error()
+			break
+		}
+		result.Latest, result.URL = tag, page
+		current, errCurrent := parseSemver(version)
+		latest, errLatest := parseSemver(tag)
+		if errCurrent != nil || errLatest != nil {
+			result.Warning = fmt.Sprintf("cannot compare versions %q and %q", version, tag)
+			break
+		}
+		result.UpdateAvailable = compareSemver(latest, current) > 0
+	}
+
+	if *jsonOutput {
+		out, _ := json.Marshal(result)
+		fmt.Println(string(out))
+		return
+	}
+	switch {
+	case result.Warning != "":
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", result.Warning)
+	case result.UpdateAvailable:
+		fmt.Printf("A newer version is available: %s (you have %s)\nChangelog: %s\n", result.Latest, version, result.URL)
+	default:
+		fmt.Printf("File Tree Generator %s is up to date (latest release %s)\n", version, result.Latest)
+	}
+}
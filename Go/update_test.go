@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareSemver(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.1", "1.0.1", 0},
+		{"v1.2.0", "1.10.0", -1},
+		{"go-v2.0.0", "1.99.99", 1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0+build.5", "1.0.0", 0},
+	}
+	for _, test := range tests {
+		a, errA := parseSemver(test.a)
+		b, errB := parseSemver(test.b)
+		if errA != nil || errB != nil {
+			t.Fatalf("parseSemver: %v, %v", errA, errB)
+		}
+		if got := compareSemver(a, b); got != test.want {
+			t.Errorf("compareSemver(%s, %s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+	for _, bad := range []string{"", "latest", "1.2", "1.2.x"} {
+		if _, err := parseSemver(bad); err == nil {
+			t.Errorf("parseSemver(%q) accepted it", bad)
+		}
+	}
+}
+
+// releasesServer answers the releases API with status and body
+func releasesServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "ftg/"+version || !strings.Contains(r.Header.Get("Accept"), "github") {
+			http.Error(w, "bad headers", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLatestRelease(t *testing.T) {
+	tests := []struct {
+		name, body string
+		status     int
+		tag, page  string
+		err        string
+	}{
+		{"release", `{"tag_name":"go-v1.2.0","html_url":"https://example.com/r/1.2.0"}`, http.StatusOK, "go-v1.2.0", "https://example.com/r/1.2.0", ""},
+		{"no page", `{"tag_name":"v1.2.0"}`, http.StatusOK, "v1.2.0", releasesChangelog, ""},
+		{"no tag", `{}`, http.StatusOK, "", "", "returned no tag"},
+		{"not found", `{"message":"Not Found"}`, http.StatusNotFound, "", "", "responded 404 Not Found"},
+		{"garbage", `<html>`, http.StatusOK, "", "", "unreadable releases API response"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag, page, err := latestRelease(releasesServer(t, test.status, test.body).URL)
+			if tag != test.tag || page != test.page || test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("latestRelease = %q, %q, %v; want %q, %q, %q", tag, page, err, test.tag, test.page, test.err)
+			}
+		})
+	}
+}
+
+// A server slower than updateTimeout is a failure, not a hang
+func TestLatestReleaseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { <-release }))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	setOption(t, &updateTimeout, 50*time.Millisecond)
+	if _, _, err := latestRelease(server.URL); err == nil || !strings.Contains(err.Error(), "Timeout") {
+		t.Errorf("latestRelease = %v, want a timeout", err)
+	}
+}
+
+// captureStdout returns what fn prints on standard output
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = saved }()
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// check-update --json reports a newer release, its own up-to-date version, a soft
+// failure and the opt-out, which makes no request at all
+func TestCheckUpdateJSON(t *testing.T) {
+	t.Setenv(updateOptOutEnv, "")
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		optOut bool
+		want   updateResult
+	}{
+		{"newer", http.StatusOK, `{"tag_name":"v99.0.0","html_url":"https://example.com/99"}`, false,
+			updateResult{Current: version, Latest: "v99.0.0", UpdateAvailable: true, URL: "https://example.com/99"}},
+		{"current", http.StatusOK, `{"tag_name":"v` + version + `","html_url":"https://example.com/c"}`, false,
+			updateResult{Current: version, Latest: "v" + version, URL: "https://example.com/c"}},
+		{"down", http.StatusBadGateway, ``, false,
+			updateResult{Current: version, Warning: "could not check for updates: releases API responded 502 Bad Gateway"}},
+		{"opt-out", http.StatusOK, `{"tag_name":"v99.0.0"}`, true,
+			updateResult{Current: version, Warning: "update check disabled by " + updateOptOutEnv}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(test.status)
+				io.WriteString(w, test.body)
+			}))
+			t.Cleanup(server.Close)
+			setOption(t, &releasesAPI, server.URL)
+			if test.optOut {
+				t.Setenv(updateOptOutEnv, "1")
+			}
+			var got updateResult
+			if err := json.Unmarshal([]byte(captureStdout(t, func() { checkUpdate([]string{"--json"}) })), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("check-update --json = %+v, want %+v", got, test.want)
+			}
+			if test.optOut && requests > 0 {
+				t.Errorf("the opt-out made %d requests", requests)
+			}
+		})
+	}
+}
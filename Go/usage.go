@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+var (
+	usageBy      []string                // --usage-by pivots: rows, then optional columns ("owner", "ext")
+	usageTop     = 8                     // Columns shown before the rest collapse into "other"
+	usageBytes   = map[[2]string]int64{} // Bytes per (row, column)
+	usageEnabled bool                    // Whether --usage-by was given
+)
+
+// parseUsageBy validates a --usage-by value such as "owner,ext"
+func parseUsageBy(value string) error {
+	parts := strings.Split(value, ",")
+	if len(parts) > 2 || (len(parts) == 2 && parts[0] == parts[1]) {
+		return fmt.Errorf("--usage-by takes one or two different pivots, got %q", value)
+	}
+	for _, part := range parts {
+		if part != "owner" && part != "ext" {
+			return fmt.Errorf("unknown --usage-by pivot %q (use owner or ext)", part)
+		}
+	}
+	usageBy, usageEnabled = parts, true
+	return nil
+}
+
+// recordUsage attributes a rendered file's size to its row and column
+func recordUsage(dir string, entry fs.DirEntry) {
+	if !usageEnabled || !entry.Type().IsRegular() {
+		return
+	}
+	info, err := entryInfo(entry)
+	if err != nil {
+		return
+	}
+	rel := relativePath(dir, entry.Name())
+	var cell [2]string
+	for i, pivot := range usageBy {
+		cell[i] = pivotKey(pivot, rel, info)
+	}
+	usageBytes[cell] += info.Size()
+}
+
+// rankByBytes orders keys by descending total, then by name, so tables are deterministic
+func rankByBytes(totals map[string]int64) []string {
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if totals[keys[i]] != totals[keys[j]] {
+			return totals[keys[i]] > totals[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// writeUsage appends the --usage-by table: one row per first pivot value and, with a
+// second pivot, one column per top value plus "other", with totals on both axes
+func writeUsage(writer io.Writer) {
+	rowTotals, colTotals := map[string]int64{}, map[string]int64{}
+	var total int64
+	for cell, bytes := range usageBytes {
+		rowTotals[cell[0]] += bytes
+		colTotals[cell[1]] += bytes
+		total += bytes
+	}
+	rows := rankByBytes(rowTotals)
+
+	fmt.Fprintf(writer, "\n%s\n\n", msg("usage.heading", strings.Join(usageBy, msg("usage.and"))))
+	if len(usageBy) == 1 {
+		fmt.Fprintf(writer, "| %s | %s |\n| --- | ---: |\n", usageBy[0], msg("usage.bytes"))
+		for _, row := range rows {
+			fmt.Fprintf(writer, "| %s | %s |\n", redactText(row), formatSize(rowTotals[row]))
+		}
+		fmt.Fprintf(writer, "| **%s** | %s |\n", msg("usage.total"), formatSize(total))
+		return
+	}
+
+	cols := rankByBytes(colTotals)
+	other := false
+	if len(cols) > usageTop {
+		cols, other = cols[:usageTop], true
+	}
+	shown := map[string]bool{}
+	header, align := "| "+usageBy[0]+" \\ "+usageBy[1], "| ---"
+	for _, col := range cols {
+		shown[col] = true
+		header += " | " + redactText(col)
+		align += " | ---:"
+	}
+	if other {
+		header += " | " + msg("usage.other")
+		align += " | ---:"
+	}
+	fmt.Fprintf(writer, "%s | %s |\n%s | ---: |\n", header, msg("usage.total"), align)
+
+	line := func(label string, bytesFor func(col string) int64, rest, sum int64) {
+		fmt.Fprintf(writer, "| %s", label)
+		for _, col := range cols {
+			fmt.Fprintf(writer, " | %s", formatSize(bytesFor(col)))
+		}
+		if other {
+			fmt.Fprintf(writer, " | %s", formatSize(rest))
+		}
+		fmt.Fprintf(writer, " | %s |\n", formatSize(sum))
+	}
+	for _, row := range rows {
+		var rest int64
+		for cell, bytes := range usageBytes {
+			if cell[0] == row && !shown[cell[1]] {
+				rest += bytes
+			}
+		}
+		line(redactText(row), func(col string) int64 { return usageBytes[[2]string{row, col}] }, rest, rowTotals[row])
+	}
+	var rest int64
+	for col, bytes := range colTotals {
+		if !shown[col] {
+			rest += bytes
+		}
+	}
+	line("**"+msg("usage.total")+"**", func(col string) int64 { return colTotals[col] }, rest, total)
+}
+
+// usageCell is the bytes of one (row, column) of the --usage-by table
+type usageCell struct {
+	Row    string `json:"row"`
+	Column string `json:"column,omitempty"` // Only with a second pivot
+	Bytes  int64  `json:"bytes"`
+}
+
+// usageCells returns every cell of the --usage-by table, largest first
+func usageCells() []usageCell {
+	cells := make([]usageCell, 0, len(usageBytes))
+	for cell, bytes := range usageBytes {
+		cells = append(cells, usageCell{redactText(cell[0]), redactText(cell[1]), bytes})
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Bytes != cells[j].Bytes {
+			return cells[i].Bytes > cells[j].Bytes
+		}
+		if cells[i].Row != cells[j].Row {
+			return cells[i].Row < cells[j].Row
+		}
+		return cells[i].Column < cells[j].Column
+	})
+	return cells
+}
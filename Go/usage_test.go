@@ -0,0 +1,17 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// The totals row and column of the --usage-by table are in the report's language
+func TestUsageTotalsLocalized(t *testing.T) {
+	src := testtree.Dir(t, "a.txt size=3\n")
+	stdout, stderr, code := runFTG(t, src, "-d", ".", "--lang", "de", "--usage-by", "ext,owner", "-o", "-", "--quiet")
+	if code != exitOK || !strings.Contains(stdout, " | gesamt |\n") || !strings.Contains(stdout, "| **gesamt** | 3 B | 3 B |\n") {
+		t.Errorf("exit code %d, got\n%s%s", code, stdout, stderr)
+	}
+}
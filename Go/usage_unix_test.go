@@ -0,0 +1,81 @@
+//go:build unix
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// usageFS is a tree whose files belong to the fabricated uids 1001 (alice), 1002 (bob)
+// and 4000000003, which no system names
+func usageFS(t *testing.T) fstest.MapFS {
+	fsys := testtree.MapFS(t, `
+data/run1.csv size=4000
+data/run2.csv size=2000
+data/plot.png size=1500
+data/notes.txt size=100
+bob/scan.png size=3000
+bob/model.bin size=700
+bob/README size=50
+shared/x.csv size=10
+`)
+	for name, uid := range map[string]uint32{
+		"data/run1.csv": 1001, "data/run2.csv": 1001, "data/plot.png": 1001, "data/notes.txt": 1001,
+		"bob/scan.png": 1002, "bob/model.bin": 1002, "bob/README": 1002, "shared/x.csv": 4000000003,
+	} {
+		fsys[name].Sys = &syscall.Stat_t{Uid: uid}
+	}
+	return fsys
+}
+
+// Owners are rows and the top extensions columns, both largest first and then by
+// name, with the rest of the extensions collapsed into "other" and totals on both axes
+func TestUsageByOwnerAndExt(t *testing.T) {
+	configure := func(pivots string) func() {
+		return func() {
+			setOption(t, &ownerNames, map[uint32]string{1001: "alice", 1002: "bob"})
+			setOption(t, &usageTop, 2)
+			setOption(t, &usageBy, nil)
+			setOption(t, &usageEnabled, false)
+			if err := parseUsageBy(pivots); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	got := renderFixture(t, usageFS(t), true, configure("owner,ext"))
+	want := "## Disk usage by owner and ext\n\n" +
+		"| owner \\ ext | csv | png | other | total |\n" +
+		"| --- | ---: | ---: | ---: | ---: |\n" +
+		"| alice | 5.9 KB | 1.5 KB | 100 B | 7.4 KB |\n" +
+		"| bob | 0 B | 2.9 KB | 750 B | 3.7 KB |\n" +
+		"| 4000000003 | 10 B | 0 B | 0 B | 10 B |\n" +
+		"| **total** | 5.9 KB | 4.4 KB | 850 B | 11.1 KB |\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+	}
+
+	got = renderFixture(t, usageFS(t), true, configure("ext"))
+	want = "## Disk usage by ext\n\n| ext | bytes |\n| --- | ---: |\n" +
+		"| csv | 5.9 KB |\n| png | 4.4 KB |\n| bin | 700 B |\n| txt | 100 B |\n| (no extension) | 50 B |\n| **total** | 11.1 KB |\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("got\n%s\nwant it to hold\n%s", got, want)
+	}
+
+	got = renderFixture(t, usageFS(t), true, func() {
+		configure("owner,ext")()
+		setOption(t, &outputFormat, formatJSON)
+	})
+	var root struct{ Usage []usageCell }
+	if err := json.Unmarshal([]byte(got), &root); err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Usage) != 7 || root.Usage[0] != (usageCell{"alice", "csv", 6000}) || root.Usage[6] != (usageCell{"4000000003", "csv", 10}) {
+		t.Errorf("usage = %+v", root.Usage)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// usageLogVersion is the "v" field of every usage record; readers skip newer versions
+const usageLogVersion = 1
+
+// usageLogCap is the size past which the usage log is moved to usage.log.1 and
+// started afresh, so at most about twice this is kept
+const usageLogCap = 1 << 20
+
+// usageRecord is one line of the --usage-log file:
+//
+//	{"v":1,"time":"2026-10-14T08:30:00Z","flags":["gitignore","max-depth","size"],
+//	 "durationMs":420,"dirs":12,"entries":240,"exitCode":0}
+//
+// Only the canonical names of the flags given are kept, never their values, and no
+// path of any kind, so the log can be shared without revealing what was scanned.
+type usageRecord struct {
+	Version    int       `json:"v"`
+	Time       time.Time `json:"time"`
+	Flags      []string  `json:"flags"`
+	DurationMs int64     `json:"durationMs"`
+	Dirs       int       `json:"dirs"`
+	Entries    int       `json:"entries"`
+	ExitCode   int       `json:"exitCode"`
+}
+
+var (
+	usageLog    bool // --usage-log: append a record of the flags used to the local usage log
+	usageLogged bool // The record of this run has been written
+)
+
+// usageLogPath returns where the usage log lives: ftg/usage.log in $XDG_STATE_HOME,
+// ~/.local/state by default, or in the local application data directory on Windows
+func usageLogPath() (string, error) {
+	if state := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(state) {
+		return filepath.Join(state, "ftg", "usage.log"), nil
+	}
+	if runtime.GOOS == "windows" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(base, "ftg", "usage.log"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "ftg", "usage.log"), nil
+}
+
+// usedFlags returns the canonical names of the flags set for this run, from the
+// command line, an options document or a config file, sorted and without repeats.
+// --usage-log itself is in every record and left out.
+func usedFlags() []string {
+	seen := map[string]bool{"usage-log": true}
+	flags := []string{}
+	flag.Visit(func(f *flag.Flag) {
+		if name := canonicalFlag(f.Name); !seen[name] {
+			seen[name] = true
+			flags = append(flags, name)
+		}
+	})
+	sort.Strings(flags)
+	return flags
+}
+
+// newUsageRecord describes this run for the usage log
+func newUsageRecord(code int) usageRecord {
+	record := usageRecord{
+		Version:  usageLogVersion,
+		Time:     time.Now().UTC().Truncate(time.Second),
+		Flags:    usedFlags(),
+		Dirs:     progress.dirs,
+		Entries:  progress.done,
+		ExitCode: code,
+	}
+	if !runStarted.IsZero() {
+		// Zero when the run failed while its options were read
+		record.DurationMs = time.Since(runStarted).Milliseconds()
+	}
+	return record
+}
+
+// appendUsageLog appends this run's record to the usage log once, rotating the log
+// when it has grown past usageLogCap. Nothing leaves the machine; a log that cannot
+// be written only warns.
+func appendUsageLog(code int) {
+	if !usageLog || usageLogged {
+		return
+	}
+	usageLogged = true
+	logPath, err := usageLogPath()
+	if err != nil {
+		warnf("Cannot find the usage log directory: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o700); err != nil {
+		warnf("Cannot create the usage log directory: %v", err)
+		return
+	}
+	if info, err := os.Stat(logPath); err == nil && info.Size() >= usageLogCap {
+		if err := os.Rename(logPath, logPath+".1"); err != nil {
+			warnf("Cannot rotate the usage log %s: %v", logPath, err)
+		}
+	}
+	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		warnf("Cannot open the usage log %s: %v", logPath, err)
+		return
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		warnf("Cannot lock the usage log %s: %v", logPath, err)
+		return
+	}
+	defer unlockFile(f)
+	line, err := json.Marshal(newUsageRecord(code))
+	if err != nil {
+		warnf("Cannot encode the usage record: %v", err)
+		return
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		warnf("Cannot append to the usage log %s: %v", logPath, err)
+	}
+}
+
+// parseUsageLog reads the records of a usage log, skipping damaged lines and newer versions
+func parseUsageLog(log []byte) []usageRecord {
+	var records []usageRecord
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	for scanner.Scan() {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var record usageRecord
+		if json.Unmarshal(text, &record) != nil || record.Version > usageLogVersion {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// usageSummary is what "ftg usage-report" makes of a usage log
+type usageSummary struct {
+	Runs         int         `json:"runs"`
+	First        time.Time   `json:"first"`
+	Last         time.Time   `json:"last"`
+	Failed       int         `json:"failed"`
+	AverageMs    int64       `json:"averageMs"`
+	Entries      int64       `json:"entries"`
+	Flags        []usageFlag `json:"flags"`
+	WithoutFlags int         `json:"withoutFlags"`
+}
+
+// usageFlag is how often one flag was used
+type usageFlag struct {
+	Name string `json:"name"`
+	Runs int    `json:"runs"`
+}
+
+// summarizeUsage aggregates usage records: runs, their time span, failures, the mean
+// duration, the entries listed, and the flags by how many runs used them, most first
+func summarizeUsage(records []usageRecord) usageSummary {
+	summary := usageSummary{Flags: []usageFlag{}}
+	counts := map[string]int{}
+	var totalMs int64
+	for _, record := range records {
+		if summary.Runs == 0 || record.Time.Before(summary.First) {
+			summary.First = record.Time
+		}
+		if record.Time.After(summary.Last) {
+			summary.Last = record.Time
+		}
+		summary.Runs++
+		if record.ExitCode == exitFatal || record.ExitCode == exitUsage {
+			summary.Failed++
+		}
+		totalMs += record.DurationMs
+		summary.Entries += int64(record.Entries)
+		if len(record.Flags) == 0 {
+			summary.WithoutFlags++
+		}
+		for _, name := range record.Flags {
+			counts[name]++
+		}
+	}
+	if summary.Runs > 0 {
+		summary.AverageMs = totalMs / int64(summary.Runs)
+	}
+	for name, runs := range counts {
+		summary.Flags = append(summary.Flags, usageFlag{name, runs})
+	}
+	sort.Slice(summary.Flags, func(i, j int) bool {
+		if summary.Flags[i].Runs != summary.Flags[j].Runs {
+			return summary.Flags[i].Runs > summary.Flags[j].Runs
+		}
+		return summary.Flags[i].Name < summary.Flags[j].Name
+	})
+	return summary
+}
+
+// usageReport implements "ftg usage-report [--json] [usage.log]": it reads the
+// rotated log and the current one, or the file given
+func usageReport(args []string) {
+	fset := flag.NewFlagSet("usage-report", flag.ExitOnError)
+	jsonOutput := fset.Bool("json", false, "Print the report as JSON")
+	_ = fset.Parse(args)
+	if fset.NArg() > 1 {
+		usageExit("usage: ftg usage-report [--json] [usage.log]")
+	}
+	var files []string
+	if fset.NArg() == 1 {
+		files = []string{fset.Arg(0)}
+	} else {
+		logPath, err := usageLogPath()
+		if err != nil {
+			errorExit(fmt.Sprintf("Cannot find the usage log directory: %v", err))
+		}
+		files = []string{logPath + ".1", logPath}
+	}
+	var log []byte
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		switch {
+		case err == nil:
+			log = append(log, data...)
+		case errors.Is(err, fs.ErrNotExist) && (i < len(files)-1 || len(log) > 0):
+			// Not rotated yet, or rotated and nothing recorded since
+		default:
+			errorExit(fmt.Sprintf("Cannot read the usage log: %v (runs are only recorded with --usage-log)", err))
+		}
+	}
+	summary := summarizeUsage(parseUsageLog(log))
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(summary, "", "  ")
+		fmt.Println(string(out))
+		exitProcess(exitOK)
+	}
+	writeUsageReport(os.Stdout, summary)
+	exitProcess(exitOK)
+}
+
+// writeUsageReport renders a usage summary as markdown
+func writeUsageReport(writer io.Writer, summary usageSummary) {
+	if summary.Runs == 0 {
+		fmt.Fprintln(writer, "No runs recorded.")
+		return
+	}
+	fmt.Fprintf(writer, "%s from %s to %s; %d failed, %s on average, %s entries listed.\n",
+		treeCount(summary.Runs, "run", "runs"),
+		summary.First.Local().Format(timeLayout), summary.Last.Local().Format(timeLayout), summary.Failed,
+		(time.Duration(summary.AverageMs) * time.Millisecond).String(), groupThousands(int(summary.Entries)))
+	if summary.WithoutFlags > 0 {
+		fmt.Fprintf(writer, "%s used no flags at all.\n", treeCount(summary.WithoutFlags, "run", "runs"))
+	}
+	if len(summary.Flags) == 0 {
+		return
+	}
+	fmt.Fprintln(writer, "\n| Flag | Runs | Share |\n| --- | ---: | ---: |")
+	for _, used := range summary.Flags {
+		fmt.Fprintf(writer, "| %s | %d | %.0f%% |\n", flagName(used.Name), used.Runs, 100*float64(used.Runs)/float64(summary.Runs))
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// useUsageLog points the usage log of the runs the test starts at a temporary
+// directory and returns the log's path
+func useUsageLog(t *testing.T) string {
+	t.Helper()
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+	return filepath.Join(state, "ftg", "usage.log")
+}
+
+// A record names the flags a run was given, long or short, and nothing of their
+// values, the directories or the files: none of them appears anywhere in the log,
+// which only its owner can read
+func TestUsageLogRedaction(t *testing.T) {
+	logPath := useUsageLog(t)
+	dir := testtree.Dir(t, "secret-src/classified.txt\n")
+	args := []string{"-d", "secret-src", "-e", "hidden-pattern", "--max-depth", "7", "-o", "secret-out.md", "--pipe-timeout", "93s", "--usage-log"}
+	if _, stderr, code := runFTG(t, dir, args...); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if _, stderr, code := runFTG(t, dir, "-d", "secret-src", "-o", "-", "--usage-log"); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret", "classified", "hidden-pattern", "93", dir, filepath.Base(dir), "out.md"} {
+		if strings.Contains(string(log), secret) {
+			t.Errorf("the usage log holds %q:\n%s", secret, log)
+		}
+	}
+	records := parseUsageLog(log)
+	if len(records) != 2 {
+		t.Fatalf("%d records, want 2:\n%s", len(records), log)
+	}
+	for i, want := range [][]string{{"d", "e", "max-depth", "o", "pipe-timeout"}, {"d", "o"}} {
+		if got := records[i].Flags; !slices.Equal(got, want) {
+			t.Errorf("record %d has flags %q, want %q", i, got, want)
+		}
+	}
+	if info, err := os.Stat(logPath); err != nil {
+		t.Error(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("the usage log has mode %v, want it private to its owner", info.Mode())
+	}
+}
+
+// A log past usageLogCap moves to usage.log.1 and the run starts a new one
+func TestUsageLogRotation(t *testing.T) {
+	logPath := useUsageLog(t)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	full := strings.Repeat(`{"v":1,"flags":[]}`+"\n", usageLogCap/19+1)
+	if err := os.WriteFile(logPath, []byte(full), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runFTG(t, testtree.Dir(t, "a.txt\n"), "-d", ".", "-o", "-", "--usage-log"); code != exitOK {
+		t.Fatalf("exit code %d: %s", code, stderr)
+	}
+	if rotated, err := os.ReadFile(logPath + ".1"); err != nil || string(rotated) != full {
+		t.Errorf("usage.log.1 is not the full log: %v", err)
+	}
+	if log, err := os.ReadFile(logPath); err != nil || strings.Count(string(log), "\n") != 1 {
+		t.Errorf("usage.log after the rotation: %q, %v", log, err)
+	}
+}
+
+// The report counts runs, failures and entries, spans the first to the last run,
+// and ranks flags by the runs that used them, ties by name; damaged lines and
+// records of a newer version are skipped
+func TestUsageReport(t *testing.T) {
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var log []byte
+	for _, record := range []usageRecord{
+		{Version: 1, Time: day.Add(2 * time.Hour), Flags: []string{"gitignore", "size"}, DurationMs: 300, Entries: 10},
+		{Version: 1, Time: day, Flags: []string{"size"}, DurationMs: 100, Entries: 5, ExitCode: exitUsage},
+		{Version: 1, Time: day.Add(time.Hour), Flags: []string{}, DurationMs: 200, Entries: 1000, ExitCode: exitWarnings},
+		{Version: 2, Time: day, Flags: []string{"future"}},
+	} {
+		line, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log = append(append(log, line...), '\n')
+	}
+	log = append(log, "{not json\n\n"...)
+
+	want := usageSummary{
+		Runs: 3, First: day, Last: day.Add(2 * time.Hour), Failed: 1, AverageMs: 200, Entries: 1015,
+		Flags:        []usageFlag{{"size", 2}, {"gitignore", 1}},
+		WithoutFlags: 1,
+	}
+	got := summarizeUsage(parseUsageLog(log))
+	if gotJSON, wantJSON := mustJSON(t, got), mustJSON(t, want); gotJSON != wantJSON {
+		t.Errorf("summary %s, want %s", gotJSON, wantJSON)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "usage.log")
+	if err := os.WriteFile(file, log, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := runFTG(t, dir, "usage-report", "--json", file)
+	var reported usageSummary
+	if code != exitOK || json.Unmarshal([]byte(stdout), &reported) != nil || mustJSON(t, reported) != mustJSON(t, want) {
+		t.Errorf("usage-report --json: exit code %d, %s%s", code, stdout, stderr)
+	}
+	stdout, _, _ = runFTG(t, dir, "usage-report", file)
+	for _, row := range []string{"| --size | 2 | 67% |\n", "| --gitignore | 1 | 33% |\n", "1 run used no flags at all.\n"} {
+		if !strings.Contains(stdout, row) {
+			t.Errorf("usage-report:\n%s\nwant it to hold %q", stdout, row)
+		}
+	}
+}
+
+// mustJSON encodes v for comparison
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
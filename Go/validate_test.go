@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInvalidValuesAreUsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--sample", "-1"}, "--sample must not be negative"},
+		{[]string{"--max-entries", "-1"}, "--max-entries must not be negative"},
+		{[]string{"-f", "csv", "--sample", "3"}, "-f csv lists every entry and cannot be combined with --sample"},
+		{[]string{"-f", "tsv", "--sample", "3"}, "-f tsv lists every entry and cannot be combined with --sample"},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			dir := t.TempDir()
+			_, stderr, code := runFTG(t, dir, append([]string{"-o", "-"}, test.args...)...)
+			if code != exitUsage {
+				t.Errorf("exit code = %d, want %d (stderr %q)", code, exitUsage, stderr)
+			}
+			if !strings.Contains(stderr, test.want) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr, test.want)
+			}
+		})
+	}
+}
+
+// Summaries only md and json have a place for are refused elsewhere, not dropped
+func TestSummaryOptionsNeedMarkdownOrJSON(t *testing.T) {
+	for _, option := range [][]string{
+		{"--provenance"},
+		{"--explain-excludes"},
+		{"--usage-by", "ext"},
+		{"--find-orphans"},
+		{"--skip-active", "2s"},
+	} {
+		t.Run(option[0], func(t *testing.T) {
+			dir := t.TempDir()
+			_, stderr, code := runFTG(t, dir, append([]string{"-o", "-", "-f", "text"}, option...)...)
+			if code != exitUsage || !strings.Contains(stderr, option[0]+" is only available with -f md and -f json") {
+				t.Errorf("-f text %s: exit code %d, stderr %q", option[0], code, stderr)
+			}
+			for _, format := range []string{"md", "json"} {
+				if _, stderr, code := runFTG(t, dir, append([]string{"-o", "-", "-f", format}, option...)...); code != 0 {
+					t.Errorf("-f %s %s: exit code %d, stderr %q", format, option[0], code, stderr)
+				}
+			}
+		})
+	}
+}
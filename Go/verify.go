@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	verifyRenderers   bool            // --verify-renderers: render every format and require the same entries in each
+	recordingRendered bool            // Entries are being recorded in renderedStream
+	renderedStream    []renderedEntry // Entries the current render emitted, in order
+)
+
+// renderedEntry is one entry a renderer emitted
+type renderedEntry struct {
+	rel     string // Path relative to the input directory, with slashes
+	regular bool   // A regular file, the only entries a manifest lists
+}
+
+// verifyFormats are the formats --verify-renderers compares; html-site and md-site
+// write files of their own and are left out
+var verifyFormats = []string{formatJSON, formatMarkdown, formatMarkdownList, formatText, formatHTML, formatSVG, formatMermaid, formatManifest, formatCSV, formatTSV, formatPaths}
+
+// recordRendered notes an entry a renderer is emitting, for --verify-renderers
+func recordRendered(dir, name string, regular bool) {
+	if recordingRendered {
+		renderedStream = append(renderedStream, renderedEntry{relativePath(dir, name), regular})
+	}
+}
+
+// checkRenderers renders the tree once in every format, re-parses the outputs whose
+// structure can be read back, and exits with exitDifferences when two formats
+// emitted different entries or an output does not hold what its renderer emitted.
+// -f json is the reference; formats that leave out entries by design under the
+// options given are only read back, and named. The run's own format is rendered last, so what the walk leaves behind
+// for the summary and the result file is that of the output written.
+func checkRenderers(ctx context.Context) {
+	format, partial, paint := outputFormat, manifestAllowPartial, painter
+	// A manifest would stop the check at the first unreadable file, and colour
+	// codes would get in the way of the re-parsing
+	manifestAllowPartial, painter = true, plainPainter{}
+	order := []string{}
+	for _, candidate := range verifyFormats {
+		if candidate != format {
+			order = append(order, candidate)
+		}
+	}
+	if !siteFormat(format) {
+		order = append(order, format)
+	}
+	streams := map[string][]renderedEntry{}
+	skipped := map[string]string{}
+	failure := ""
+	for _, candidate := range order {
+		resetWalkState()
+		outputFormat = candidate
+		// Only the render of the run's own format reports its warnings
+		progress.muted = candidate != format
+		renderedStream, recordingRendered = nil, true
+		out, err := renderRun(ctx, false)
+		if ctx.Err() != nil {
+			cancelExit(ctx)
+		}
+		if err != nil {
+			errorExit(fmt.Sprintf("-f %s: %v", candidate, err))
+		}
+		recordingRendered, progress.muted = false, false
+		if err := reparseRendered(candidate, out, renderedStream); err != nil && failure == "" {
+			failure = err.Error()
+		}
+		if reason := unlikeEntries(candidate); reason != "" {
+			skipped[candidate] = reason
+			continue
+		}
+		streams[candidate] = renderedStream
+	}
+	outputFormat, manifestAllowPartial, painter = format, partial, paint
+	reference := streams[formatJSON]
+	notes := []string{}
+	for _, candidate := range verifyFormats[1:] {
+		if reason, ok := skipped[candidate]; ok {
+			notes = append(notes, fmt.Sprintf("-f %s only read back: %s", candidate, reason))
+			continue
+		}
+		if failure != "" {
+			continue
+		}
+		if diff := streamDifference(reference, streams[candidate]); diff != "" {
+			failure = fmt.Sprintf("-f %s and -f json list different entries: %s", candidate, diff)
+		}
+	}
+	if failure != "" {
+		exitWith(exitDifferences, "Renderer check failed: "+failure)
+	}
+	clearProgressLine()
+	fmt.Fprintf(messages, "Renderer check passed: %d formats listed the same %s\n", len(streams), treeCount(len(reference), "entry", "entries"))
+	for _, note := range notes {
+		fmt.Fprintf(messages, "  (%s)\n", note)
+	}
+}
+
+// unlikeEntries returns why a format is meant to list other entries than -f json
+// after the render just made, "" when it must list the same
+func unlikeEntries(format string) string {
+	switch {
+	case format == formatManifest && sampleSize > 0:
+		return "it never samples"
+	case truncatesListings(format):
+		return "it stops each directory at --max-entries"
+	case format == formatMermaid && mermaidCut:
+		return "it was cut at --mermaid-max-nodes"
+	case format == formatSVG && svgCut:
+		return "it was cut at the lines an SVG can hold"
+	}
+	return ""
+}
+
+// streamDifference describes the first difference between two entry streams, "" for none
+func streamDifference(want, got []renderedEntry) string {
+	for i := 0; i < max(len(want), len(got)); i++ {
+		switch {
+		case i == len(got):
+			return fmt.Sprintf("%s is missing (%d entries instead of %d)", want[i].rel, len(got), len(want))
+		case i == len(want):
+			return fmt.Sprintf("%s is extra (%d entries instead of %d)", got[i].rel, len(got), len(want))
+		case want[i].rel != got[i].rel:
+			return fmt.Sprintf("entry %d is %s instead of %s", i+1, got[i].rel, want[i].rel)
+		}
+	}
+	return ""
+}
+
+// reparseRendered reads an output back and checks it against the entries its
+// renderer emitted: every path for json, manifest, csv, tsv and paths, the nesting of each line for
+// md and text. The other formats are compared by their entry streams only.
+func reparseRendered(format string, out []byte, stream []renderedEntry) error {
+	switch format {
+	case formatJSON:
+		var root jsonNode
+		if err := json.Unmarshal(out, &root); err != nil {
+			return fmt.Errorf("-f json does not parse: %v", err)
+		}
+		var paths []string
+		var walk func(node *jsonNode, parent string)
+		walk = func(node *jsonNode, parent string) {
+			for _, child := range node.Children {
+				paths = append(paths, parent+child.Name)
+				walk(child, parent+child.Name+"/")
+			}
+		}
+		walk(&root, "")
+		want := make([]string, len(stream))
+		for i, entry := range stream {
+			want[i] = filepath.ToSlash(redactPath(entry.rel))
+		}
+		return comparePaths(format, want, paths)
+	case formatManifest:
+		var doc manifestDoc
+		if err := json.Unmarshal(out, &doc); err != nil {
+			return fmt.Errorf("-f manifest does not parse: %v", err)
+		}
+		paths := make([]string, len(doc.Files))
+		for i, file := range doc.Files {
+			paths[i] = file.Path
+		}
+		want := []string{}
+		for _, entry := range stream {
+			if entry.regular {
+				want = append(want, filepath.ToSlash(redactPath(displayPath(entry.rel))))
+			}
+		}
+		return comparePaths(format, want, paths)
+	case formatCSV, formatTSV:
+		reader := csv.NewReader(bytes.NewReader(out))
+		if format == formatTSV {
+			reader.Comma = '\t'
+		}
+		rows, err := reader.ReadAll()
+		if err != nil || len(rows) == 0 {
+			return fmt.Errorf("-f %s does not parse: %v", format, err)
+		}
+		paths := make([]string, 0, len(rows)-1)
+		for _, row := range rows[1:] {
+			paths = append(paths, row[0])
+		}
+		want := make([]string, len(stream))
+		for i, entry := range stream {
+			// csvPath reads outputFormat, which is the format being checked
+			want[i] = csvPath(entry.rel)
+		}
+		return comparePaths(format, want, paths)
+	case formatPaths:
+		paths := []string{}
+		for _, line := range strings.SplitAfter(string(out), "\n") {
+			if line != "" {
+				paths = append(paths, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "/"))
+			}
+		}
+		want := make([]string, len(stream))
+		for i, entry := range stream {
+			want[i] = strings.TrimSuffix(pathsLine(entry.rel, false), "\n")
+		}
+		return comparePaths(format, want, paths)
+	case formatMarkdown, formatText:
+		depths := treeLineDepths(out, format == formatText)
+		if len(depths) != len(stream) {
+			return fmt.Errorf("-f %s shows %s, but its renderer emitted %d", format, treeCount(len(depths), "entry line", "entry lines"), len(stream))
+		}
+		for i, entry := range stream {
+			if want := strings.Count(entry.rel, "/"); depths[i] != want {
+				return fmt.Errorf("-f %s shows %s at depth %d instead of %d", format, entry.rel, depths[i], want)
+			}
+		}
+	}
+	return nil
+}
+
+// comparePaths reports the first path an output holds in place of the one its
+// renderer emitted
+func comparePaths(format string, want, got []string) error {
+	for i := 0; i < max(len(want), len(got)); i++ {
+		switch {
+		case i == len(got):
+			return fmt.Errorf("-f %s is missing %s, which its renderer emitted", format, want[i])
+		case i == len(want):
+			return fmt.Errorf("-f %s holds %s, which its renderer did not emit", format, got[i])
+		case want[i] != got[i]:
+			return fmt.Errorf("-f %s holds %s where its renderer emitted %s", format, got[i], want[i])
+		}
+	}
+	return nil
+}
+
+// treeLineDepths returns the nesting depth of every entry line of a rendered tree:
+// the tagged lines inside the code fences of md, or in -f text the connector lines
+// up to the blank line before the count. Lines for elisions, omissions and read
+// errors, and the continuations of wrapped names, are not entries.
+func treeLineDepths(out []byte, text bool) []int {
+	var depths []int
+	inFence := text
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for first := true; scanner.Scan(); first = false {
+		line := scanner.Text()
+		switch {
+		case text && first:
+			// The root
+			continue
+		case text && line == "":
+			return depths
+		case !text && isFenceLine([]byte(line)):
+			inFence = !inFence && strings.TrimLeft(line, "`") == "sh"
+			continue
+		case !inFence:
+			continue
+		}
+		if text || entryTemplate != nil {
+			// --entry-format lines have no type tag to split at
+			if depth, ok := textLineDepth(line); ok {
+				depths = append(depths, depth)
+			}
+			continue
+		}
+		if prefix, _, _, ok := splitTreeLine(line); ok {
+			if depth, ok := treeLineDepth(prefix); ok {
+				depths = append(depths, depth)
+			}
+		}
+	}
+	return depths
+}
+
+// textLineDepth returns the depth of an -f text entry line from its connectors.
+// Every note line the tree writes starts with "… " or "[error: ".
+func textLineDepth(line string) (int, bool) {
+	for depth := 0; ; depth++ {
+		for _, branch := range []string{connectors.Branch, connectors.LastBranch} {
+			if rest, ok := strings.CutPrefix(line, branch+" "); ok {
+				return depth, !strings.HasPrefix(rest, "… ") && !strings.HasPrefix(rest, "[error: ")
+			}
+		}
+		switch {
+		case strings.HasPrefix(line, connectors.Pipe):
+			line = line[len(connectors.Pipe):]
+		case strings.HasPrefix(line, connectors.Space):
+			line = line[len(connectors.Space):]
+		default:
+			return 0, false
+		}
+	}
+}
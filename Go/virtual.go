@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// virtualFilesystems are the pseudo filesystems skipped when scanning "/"
+var virtualFilesystems = map[string]bool{
+	"proc": true,
+	"sys":  true,
+	"dev":  true,
+	"run":  true,
+}
+
+var (
+	includeVirtual bool                // Descend into virtual filesystems when the root is "/"
+	skippedVirtual = map[string]bool{} // Virtual filesystems left out of the tree
+)
+
+// isFilesystemRoot reports whether dir is the root of a Unix filesystem, or the --root-prefix standing in for it
+func isFilesystemRoot(dir string) bool {
+	if rootPrefix != "" {
+		if abs, err := filepath.Abs(dir); err == nil && abs == rootPrefix {
+			return true
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(dir)) == "/"
+}
+
+// filterVirtual drops /proc, /sys, /dev and /run from a listing of "/" unless --include-virtual is set
+func filterVirtual(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	if includeVirtual || !isFilesystemRoot(dir) {
+		return entries
+	}
+	kept := entries[:0:0]
+	for _, entry := range entries {
+		if entry.IsDir() && virtualFilesystems[entry.Name()] {
+			skippedVirtual["/"+entry.Name()] = true
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// skippedVirtualList returns the skipped virtual filesystems in sorted order
+func skippedVirtualList() []string {
+	list := make([]string, 0, len(skippedVirtual))
+	for name := range skippedVirtual {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
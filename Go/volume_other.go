@@ -0,0 +1,16 @@
+//go:build !windows
+
+package main
+
+// platformExcludes are default exclusions only needed on some platforms
+var platformExcludes []string
+
+// normalizeInput returns dir unchanged; only Windows has bare volume names
+func normalizeInput(dir string) string {
+	return dir
+}
+
+// longPath returns p unchanged; only Windows limits the length of paths
+func longPath(p string) string {
+	return p
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// platformExcludes are default exclusions for drive roots: the recycle bin, in both
+// of the spellings Windows versions use, and restore point storage
+var platformExcludes = []string{"$RECYCLE.BIN", "$Recycle.Bin", "System Volume Information"}
+
+// normalizeInput gives a bare volume its root: "D:" would otherwise mean the current
+// directory of drive D to filepath.Join but the root to os.DirFS, and "\\server\share"
+// needs a trailing separator the same way
+func normalizeInput(dir string) string {
+	if vol := filepath.VolumeName(dir); vol != "" && vol == dir {
+		return dir + `\`
+	}
+	return dir
+}
+
+// longPath returns the extended form of a path, \\?\C:\dir or \\?\UNC\server\share\dir,
+// which lifts the MAX_PATH limit of 260 characters for it and everything joined to
+// it, so deeply nested trees can be read. Older Go releases only add the prefix to
+// long absolute paths they are given, not to a relative root joined by os.DirFS.
+func longPath(p string) string {
+	abs, err := filepath.Abs(p)
+	switch {
+	case err != nil || strings.HasPrefix(abs, `\\?\`):
+		return p
+	case strings.HasPrefix(abs, `\\`):
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ in, want string }{
+		{`C:\src\project`, `\\?\C:\src\project`},
+		{`C:\src\..\project\`, `\\?\C:\project`},
+		{`\\server\share\dir`, `\\?\UNC\server\share\dir`},
+		{`\\?\C:\already`, `\\?\C:\already`},
+		{`\\?\UNC\server\share`, `\\?\UNC\server\share`},
+		{`relative\dir`, `\\?\` + filepath.Join(cwd, `relative\dir`)},
+	}
+	for _, test := range tests {
+		if got := longPath(test.in); got != test.want {
+			t.Errorf("longPath(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+// A directory nested past MAX_PATH is listed and walked like any other
+func TestLongPathReadsDeepTrees(t *testing.T) {
+	root := t.TempDir()
+	deep := root
+	for len(deep) < 300 {
+		deep = filepath.Join(deep, strings.Repeat("d", 40))
+	}
+	if err := os.MkdirAll(longPath(deep), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(longPath(filepath.Join(deep, "leaf.txt")), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setOption(t, &scan.Root, root)
+	setOption(t, &treeFS, nil)
+	entries, err := getEntries(context.Background(), deep)
+	if err != nil {
+		t.Fatalf("listing %d characters deep: %v", len(deep), err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "leaf.txt" {
+		t.Errorf("entries = %v, want leaf.txt", entries)
+	}
+
+	stdout, stderr, code := runFTG(t, root, "-o", "-", "-f", "text")
+	if code != exitOK || !strings.Contains(stdout, "leaf.txt") {
+		t.Errorf("exit code %d, stderr %q, stdout\n%s", code, stderr, stdout)
+	}
+}
+
+// Bare volumes get their root, so D: means the drive and not its current directory
+func TestNormalizeInput(t *testing.T) {
+	for in, want := range map[string]string{`D:`: `D:\`, `D:\`: `D:\`, `\\server\share`: `\\server\share\`, `D:\src`: `D:\src`, `src`: `src`} {
+		if got := normalizeInput(in); got != want {
+			t.Errorf("normalizeInput(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// walkEvent says what a call of walkTree's callback is about
+type walkEvent = ftree.Event
+
+const (
+	walkEntry  = ftree.EventEntry  // An entry; a directory comes before it is read
+	walkOpen   = ftree.EventOpen   // A directory was read; its entries follow, then walkClose
+	walkClose  = ftree.EventClose  // Every entry of a directory was visited
+	walkFailed = ftree.EventFailed // A directory could not be read; err says why
+	walkElided = ftree.EventElided // --sample left out elided entries of dir here
+	// --max-entries left out elided entries of dir after the last shown; isLast unless
+	// walkFailed follows for a streamed directory
+	walkTruncated = ftree.EventTruncated
+)
+
+// treeEntry is one call of walkTree's callback. Every event of an entry carries the
+// entry; walkElided has none and only sets dir, depth and elided, walkTruncated the
+// same and isLast.
+type treeEntry struct {
+	event     walkEvent
+	dir       string      // Directory holding the entry
+	entry     fs.DirEntry // The entry itself
+	rel       string      // Path from the input directory, with slashes
+	depth     int         // 1 for the entries of the input directory
+	entryType string      // D, F or L, as the trees tag it
+	size      int64       // Size in bytes of anything but a directory, -1 when it cannot be read
+	isLast    bool        // Last of the entries listed for its directory
+	descend   bool        // walkTree reads the directory after walkEntry unless fn returns fs.SkipDir
+	listed    int         // Entries the directory shows, for walkOpen; -1 when it is streamed
+	elided    int         // Entries --sample left out, for walkElided, or --max-entries, for walkTruncated
+	err       error       // Why the directory could not be read, for walkFailed
+
+	// Why a directory the walk would enter is shown without its entries, see stopDescent
+	visitedAt      string // --dedupe-mounts: where the same directory was shown first, "." for the input directory
+	identicalTo    string // --dedupe-subtrees: the earlier directory with the same contents
+	identicalFiles int    // and how many files the two hold
+	notScanned     bool   // --budget ran out before the directory was listed
+}
+
+// Orders of --traversal
+const (
+	traversalDFS = "dfs" // Each directory's entries right below it, as the trees show them
+	traversalBFS = "bfs" // A level at a time, for the flat formats
+)
+
+var traversal = traversalDFS // --traversal: the order the flat formats list entries in
+
+// flatFormats are the formats that list entries without nesting them, which alone
+// can take --traversal bfs
+var flatFormats = []string{formatCSV, formatTSV, formatPaths, formatManifest}
+
+// scan is the ftree.Generator the command line fronts: Root is the input directory,
+// MaxDepth -L/--max-depth, DirsOnly --dirs-only with tree -d's meaning, and
+// NoDefaultExcludes the long spelling of -c. Its Walker is treeWalker, whose hooks
+// apply those and every other flag.
+var scan ftree.Generator
+
+// init hands scan the walker, which reads scan in turn
+func init() {
+	scan.Walker = treeWalker
+}
+
+// treeWalker is the ftree.Walker of the command line: the listings of getEntries,
+// with exclusions, the filters, --sample, --max-entries and --max-depth applied, and
+// directories past --stream-threshold streamed
+var treeWalker = &ftree.Walker{
+	ReadDir: getEntries,
+	Join:    func(dir, name string) string { return filepath.Join(dir, name) },
+	List:    listedEntries,
+	Descend: func(e ftree.Entry) bool { return shouldDescend(filepath.Join(e.Dir, e.Name), e.DirEntry) },
+	Info:    entryInfo,
+	Stream:  openStream,
+}
+
+// listedEntries is what a directory shows in every tree, in order. It also does the
+// bookkeeping of a listing, --anomalies, --name-stats and --preserve-annotations.
+func listedEntries(dir string, entries []fs.DirEntry) ftree.Listing {
+	visible := filterExcluded(dir, visibleEntries(dir, entries))
+	recordAnomalies(dir, visible)
+	recordNameStats(dir, visible)
+	visible, elideAt, elided := sampleEntries(visible)
+	more := 0
+	if truncatesListings(outputFormat) {
+		visible, more = truncateEntries(visible)
+		if more > 0 {
+			noteTruncated()
+		}
+	}
+	matchAnnotations(dir, visible)
+	return ftree.Listing{Entries: visible, ElideAt: elideAt, Elided: elided, Truncated: more}
+}
+
+// walkTree visits every entry below root that the trees list, in their order, and
+// descends where they do, on scan's walker. It also does the bookkeeping of each
+// rendered entry: the counters, --usage-by, --history and --security-report, and
+// of each directory that could not be read, see recordReadFailure. Directories shown
+// as stubs, see stopDescent, get walkEntry without descend and are not read.
+//
+// A directory gets walkEntry, then walkOpen, its entries and walkClose, or walkFailed
+// once it could not be read. Like filepath.WalkDir, fs.SkipDir returned from
+// walkEntry keeps a directory from being read, and any other error ends the walk and
+// is returned. The walk stops with the context's error once ctx is done.
+//
+// With --traversal bfs a flat format gets the entries a level at a time, see
+// ftree.Walker.WalkBreadthFirst: a directory's walkOpen comes once its level is done.
+func walkTree(ctx context.Context, root string, entries []fs.DirEntry, fn func(treeEntry) error) error {
+	walk := scan.Walker.Walk
+	if traversal == traversalBFS && slices.Contains(flatFormats, outputFormat) {
+		walk = scan.Walker.WalkBreadthFirst
+	}
+	opened := map[string]treeEntry{} // Directories to be read, by path; their later events reuse the entry
+	return walk(ctx, root, entries, func(e ftree.Entry) error {
+		switch e.Event {
+		case ftree.EventElided, ftree.EventTruncated:
+			return fn(treeEntry{event: e.Event, dir: e.Dir, depth: e.Depth, elided: e.Elided, isLast: e.IsLast})
+		case ftree.EventSkipped:
+			// getEntries drops these already; a listing from elsewhere gets the same warning
+			warnf("Skipping entry with invalid name %q in %s", e.Name, e.Dir)
+			return nil
+		}
+		if e.Event != ftree.EventEntry {
+			te := opened[e.Path]
+			if e.Event == ftree.EventClose || e.Event == ftree.EventFailed && e.Listed != -1 {
+				// Done with, but for a streamed directory, whose walkClose follows walkFailed
+				delete(opened, e.Path)
+			}
+			te.event, te.err, te.listed = e.Event, e.Err, e.Listed
+			if e.Event == ftree.EventFailed {
+				recordReadFailure(filepath.Join(e.Dir, e.Name), te.rel, e.Err)
+			}
+			return fn(te)
+		}
+		countEntry(e.Dir, e.DirEntry)
+		recordUsage(e.Dir, e.DirEntry)
+		recordHistory(e.Dir, e.DirEntry)
+		recordSecurity(e.Dir, e.DirEntry)
+		te := treeEntry{event: walkEntry, dir: e.Dir, entry: e.DirEntry, rel: relativePath(e.Dir, e.Name), depth: e.Depth,
+			entryType: reparseType(filepath.Join(e.Dir, e.Name), e.DirEntry), size: e.Size, isLast: e.IsLast, descend: e.Descend}
+		stub := te.descend && stopDescent(&te)
+		if te.descend {
+			opened[e.Path] = te
+		}
+		err := fn(te)
+		if err == fs.SkipDir {
+			delete(opened, e.Path)
+		}
+		if err != nil || !stub {
+			return err
+		}
+		return fs.SkipDir
+	})
+}
+
+// stopDescent decides whether a directory the walk would enter is shown as a stub
+// instead: one shown before under another path, a repeat of a subtree shown before,
+// or one --budget left unread. It records why in te and clears te.descend.
+func stopDescent(te *treeEntry) bool {
+	fullPath := filepath.Join(te.dir, te.entry.Name())
+	if first, seen := firstVisit(te.entry, te.rel); seen {
+		te.visitedAt = first
+	} else if first, files, duplicate := identicalSubtree(fullPath, te.rel); duplicate {
+		te.identicalTo, te.identicalFiles = first, files
+	} else if budgetStub(fullPath) {
+		te.notScanned = true
+	} else {
+		return false
+	}
+	te.descend = false
+	return true
+}
+
+// stubNote returns the label annotation of a directory stopDescent kept the walk out of
+func stubNote(te treeEntry) string {
+	switch {
+	case te.visitedAt == ".":
+		return " " + msg("note.alreadyRoot")
+	case te.visitedAt != "":
+		return " " + msg("note.alreadyAt", displayPath(te.visitedAt))
+	case te.identicalTo != "":
+		return " " + msg("note.identical", redactPath(displayPath(te.identicalTo)), msgCount("count.file", te.identicalFiles))
+	case te.notScanned:
+		return " " + msg("note.notScanned")
+	}
+	return ""
+}
+
+// walkFailure is what a render returns once walkTree stopped: no output and no error
+// for a cancelled run, which the caller reports, the walk's error for anything else
+func walkFailure(ctx context.Context, err error) ([]byte, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+// failingDirFS is fsys with the listing of one directory failing, whether it is read
+// whole or opened to stream it
+type failingDirFS struct {
+	fs.FS
+	dir string
+}
+
+// Open fails for the chosen directory and opens fsys otherwise
+func (f failingDirFS) Open(name string) (fs.File, error) {
+	if name == f.dir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.FS.Open(name)
+}
+
+// ReadDir fails for the chosen directory and reads fsys otherwise
+func (f failingDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == f.dir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	return fs.ReadDir(f.FS, name)
+}
+
+// walkFixture runs walkTree over fsys as the input directory, with the options
+// configure sets, and returns one line per event until fn's result ends the walk
+func walkFixture(ctx context.Context, t *testing.T, fsys fs.FS, configure func(), fn func(treeEntry) error) ([]string, error) {
+	t.Helper()
+	useFixture(t, fsys, configure)
+	setOption(t, &treeFS, rootFS(scan.Root))
+	entries, err := getEntries(ctx, scan.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	err = walkTree(ctx, scan.Root, entries, func(e treeEntry) error {
+		switch e.event {
+		case walkEntry:
+			events = append(events, fmt.Sprintf("entry %s %s depth %d", e.rel, e.entryType, e.depth))
+		case walkOpen:
+			events = append(events, "open "+e.rel)
+		case walkClose:
+			events = append(events, "close "+e.rel)
+		case walkFailed:
+			events = append(events, "failed "+e.rel)
+		case walkElided:
+			events = append(events, fmt.Sprintf("elided %d at depth %d", e.elided, e.depth))
+		}
+		if fn == nil {
+			return nil
+		}
+		return fn(e)
+	})
+	return events, err
+}
+
+// checkEvents compares the events of a walk line by line
+func checkEvents(t *testing.T, got []string, want string) {
+	t.Helper()
+	if joined := strings.Join(got, "\n"); joined != strings.TrimSpace(want) {
+		t.Errorf("events:\n%s\nwant:\n%s", joined, strings.TrimSpace(want))
+	}
+}
+
+// A directory gets its entry, then its listing between open and close, in tree order
+func TestWalkTreeEventOrder(t *testing.T) {
+	events, err := walkFixture(context.Background(), t, testtree.MapFS(t, fixture), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, `
+entry .env F depth 1
+entry Makefile F depth 1
+entry README.md F depth 1
+entry docs D depth 1
+open docs
+entry docs/guide.md F depth 2
+close docs
+entry empty D depth 1
+open empty
+close empty
+entry src D depth 1
+open src
+entry src/main.go F depth 2
+entry src/util D depth 2
+open src/util
+entry src/util/strings.go F depth 3
+entry src/util/strings_test.go F depth 3
+close src/util
+close src
+`)
+}
+
+// fs.SkipDir from a directory's entry keeps it from being read, and the walk goes on
+func TestWalkTreeSkipDir(t *testing.T) {
+	events, err := walkFixture(context.Background(), t, testtree.MapFS(t, fixture), nil, func(e treeEntry) error {
+		if e.event == walkEntry && e.rel == "docs" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(events, "\n")
+	if strings.Contains(joined, "docs/guide.md") || strings.Contains(joined, "open docs") {
+		t.Errorf("docs was read after fs.SkipDir:\n%s", joined)
+	}
+	if !strings.HasSuffix(joined, "close src") {
+		t.Errorf("the walk stopped after fs.SkipDir:\n%s", joined)
+	}
+}
+
+// Any other error ends the walk at once and is returned
+func TestWalkTreeStopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	events, err := walkFixture(context.Background(), t, testtree.MapFS(t, fixture), nil, func(e treeEntry) error {
+		if e.rel == "docs/guide.md" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("err = %v, want %v", err, stop)
+	}
+	if last := events[len(events)-1]; last != "entry docs/guide.md F depth 2" {
+		t.Errorf("the walk went on to %q", last)
+	}
+}
+
+// A cancelled context ends the walk with its error
+func TestWalkTreeCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := walkFixture(ctx, t, testtree.MapFS(t, fixture), nil, func(e treeEntry) error {
+		if e.rel == "Makefile" {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("events after the cancel: %q", events[2:])
+	}
+}
+
+// An unreadable directory gets walkFailed in place of its listing, and is counted
+func TestWalkTreeUnreadableDirectory(t *testing.T) {
+	var failure error
+	events, err := walkFixture(context.Background(), t, failingDirFS{testtree.MapFS(t, fixture), "src/util"}, nil, func(e treeEntry) error {
+		if e.event == walkFailed {
+			failure = e.err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(failure, fs.ErrPermission) {
+		t.Errorf("walkFailed err = %v, want a permission error", failure)
+	}
+	joined := strings.Join(events, "\n")
+	if !strings.Contains(joined, "entry src/util D depth 2\nfailed src/util\nclose src") {
+		t.Errorf("events:\n%s", joined)
+	}
+	if counters.unreadable != 1 {
+		t.Errorf("unreadable = %d, want 1", counters.unreadable)
+	}
+	if want := []readFailure{{"src/util", "permission denied"}}; !slices.Equal(readFailures, want) {
+		t.Errorf("readFailures = %v, want %v", readFailures, want)
+	}
+}
+
+// --sample elides the middle of a listing with one walkElided where it was
+func TestWalkTreeSample(t *testing.T) {
+	spec := ""
+	for i := 1; i <= 9; i++ {
+		spec += fmt.Sprintf("f%d.txt\n", i)
+	}
+	events, err := walkFixture(context.Background(), t, testtree.MapFS(t, spec), func() {
+		setOption(t, &sampleSize, 7)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEvents(t, events, `
+entry f1.txt F depth 1
+entry f2.txt F depth 1
+entry f3.txt F depth 1
+elided 3 at depth 1
+entry f7.txt F depth 1
+entry f8.txt F depth 1
+entry f9.txt F depth 1
+`)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+)
+
+// treeFS is the filesystem the walk lists. The CLI uses os.DirFS of the input
+// directory; an fstest.MapFS or embed.FS can stand in for it while inputDirectory
+// still names the root in the header. The walk keeps joining OS paths, and they
+// are mapped to slash-separated paths relative to the FS root only where a listing
+// or stat is made, so exclusions and labels see the same relative paths either way.
+var treeFS fs.FS
+
+// fixtureFS, when set, is listed in place of every input directory, so tests can run
+// the whole render over an fstest.MapFS
+var fixtureFS fs.FS
+
+// fsPath maps a path below the input directory to its name in treeFS
+func fsPath(p string) (string, bool) {
+	if treeFS == nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(scan.Root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// listDir reads a directory through treeFS when it lies below the input directory
+func listDir(dir string) ([]fs.DirEntry, error) {
+	entries, err := readListing(dir)
+	return byteOrder(entries), err
+}
+
+// readListing reads a directory in the order the filesystem returns it. It touches no
+// package state, so the --jobs workers call it concurrently.
+func readListing(dir string) ([]fs.DirEntry, error) {
+	if name, ok := fsPath(dir); ok {
+		return ftree.ReadDir(treeFS, name)
+	}
+	return os.ReadDir(longPath(dir))
+}
+
+// byteOrder puts a listing in name byte order, the order the walk starts from. os.ReadDir
+// and fs.ReadDir already sort, but an fs.FS with its own ReadDir need not; --self-check
+// shuffles listings here to prove nothing later depends on the order they arrived in.
+func byteOrder(entries []fs.DirEntry) []fs.DirEntry {
+	if shuffleListings != nil {
+		shuffleListings.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+	}
+	byName := func(i, j int) bool { return entries[i].Name() < entries[j].Name() }
+	if !sort.SliceIsSorted(entries, byName) {
+		sort.Slice(entries, byName)
+	}
+	return entries
+}
+
+// statPath stats a path without following links, through treeFS when it lies below
+// the input directory. Filesystems without Lstat (fstest.MapFS has no links) fall
+// back to Stat.
+func statPath(p string) (fs.FileInfo, error) {
+	name, ok := fsPath(p)
+	if !ok {
+		return os.Lstat(longPath(p))
+	}
+	if linkFS, ok := treeFS.(interface {
+		Lstat(name string) (fs.FileInfo, error)
+	}); ok {
+		return linkFS.Lstat(name)
+	}
+	return fs.Stat(treeFS, name)
+}
+
+// readPath reads a file's contents through treeFS when it lies below the input directory
+func readPath(p string) ([]byte, error) {
+	if name, ok := fsPath(p); ok {
+		return fs.ReadFile(treeFS, name)
+	}
+	return os.ReadFile(p)
+}
+
+// readLinkPath reads a link's target through treeFS when it lies below the input directory
+func readLinkPath(p string) (string, error) {
+	name, ok := fsPath(p)
+	if !ok {
+		return os.Readlink(p)
+	}
+	if linkFS, ok := treeFS.(interface {
+		ReadLink(name string) (string, error)
+	}); ok {
+		return linkFS.ReadLink(name)
+	}
+	return os.Readlink(p)
+}
+
+// openPath opens a file through treeFS when it lies below the input directory
+func openPath(p string) (fs.File, error) {
+	if name, ok := fsPath(p); ok {
+		return treeFS.Open(name)
+	}
+	return os.Open(p)
+}
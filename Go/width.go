@@ -0,0 +1,199 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// wideRanges lists code point ranges that terminals render two columns wide:
+// East Asian Wide/Fullwidth blocks and the common emoji blocks
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo initials
+	{0x231A, 0x231B},   // Watch, hourglass
+	{0x2329, 0x232A},   // Angle brackets
+	{0x23E9, 0x23EC},   // Media controls
+	{0x23F0, 0x23F0},   // Alarm clock
+	{0x23F3, 0x23F3},   // Hourglass with flowing sand
+	{0x25FD, 0x25FE},   // Medium small squares
+	{0x2614, 0x2615},   // Umbrella, hot beverage
+	{0x2648, 0x2653},   // Zodiac
+	{0x267F, 0x267F},   // Wheelchair
+	{0x2693, 0x2693},   // Anchor
+	{0x26A1, 0x26A1},   // High voltage
+	{0x26AA, 0x26AB},   // Circles
+	{0x26BD, 0x26BE},   // Balls
+	{0x26C4, 0x26C5},   // Snowman, sun behind cloud
+	{0x26CE, 0x26CE},   // Ophiuchus
+	{0x26D4, 0x26D4},   // No entry
+	{0x26EA, 0x26EA},   // Church
+	{0x26F2, 0x26F5},   // Fountain .. sailboat
+	{0x26FA, 0x26FA},   // Tent
+	{0x26FD, 0x26FD},   // Fuel pump
+	{0x2705, 0x2705},   // Check mark
+	{0x270A, 0x270B},   // Raised fists
+	{0x2728, 0x2728},   // Sparkles
+	{0x274C, 0x274C},   // Cross mark
+	{0x274E, 0x274E},   // Negative squared cross mark
+	{0x2753, 0x2755},   // Question marks
+	{0x2757, 0x2757},   // Exclamation mark
+	{0x2795, 0x2797},   // Math operators
+	{0x27B0, 0x27B0},   // Curly loop
+	{0x27BF, 0x27BF},   // Double curly loop
+	{0x2B1B, 0x2B1C},   // Large squares
+	{0x2B50, 0x2B50},   // Star
+	{0x2B55, 0x2B55},   // Circle
+	{0x2E80, 0x303E},   // CJK radicals .. CJK symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana .. CJK compatibility
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // Vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms, small form variants
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F004, 0x1F004}, // Mahjong tile
+	{0x1F0CF, 0x1F0CF}, // Playing card
+	{0x1F18E, 0x1F18E}, // Squared AB
+	{0x1F191, 0x1F19A}, // Squared words
+	{0x1F200, 0x1F251}, // Enclosed ideographic supplement
+	{0x1F300, 0x1F64F}, // Misc symbols and pictographs, emoticons
+	{0x1F680, 0x1F6FF}, // Transport and map symbols
+	{0x1F7E0, 0x1F7EB}, // Colored circles and squares
+	{0x1F90C, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x1FA70, 0x1FAFF}, // Symbols and pictographs extended A
+	{0x20000, 0x2FFFD}, // CJK extensions B..F
+	{0x30000, 0x3FFFD}, // CJK extension G
+}
+
+// ambiguousRanges lists the common East Asian Ambiguous code points: one column wide
+// in most terminals, two in those set up for CJK text. They include the box-drawing
+// characters of the connector presets.
+var ambiguousRanges = [][2]rune{
+	{0x00A1, 0x00A1}, {0x00A4, 0x00A4}, {0x00A7, 0x00A8}, {0x00AA, 0x00AA}, // ¡ ¤ § ¨ ª
+	{0x00AE, 0x00AE}, {0x00B0, 0x00B4}, {0x00B6, 0x00BA}, {0x00BC, 0x00BF}, // ® ° ± ² ³ ´ ¶ · ¸ ¹ º ¼ … ¿
+	{0x00C6, 0x00C6}, {0x00D0, 0x00D0}, {0x00D7, 0x00D8}, {0x00DE, 0x00E1}, // Latin-1 letters, ×
+	{0x00E6, 0x00E6}, {0x00E8, 0x00EA}, {0x00EC, 0x00ED}, {0x00F0, 0x00F0},
+	{0x00F2, 0x00F3}, {0x00F7, 0x00FA}, {0x00FC, 0x00FC}, {0x00FE, 0x00FE}, // ÷
+	{0x0391, 0x03A1}, {0x03A3, 0x03A9}, {0x03B1, 0x03C1}, {0x03C3, 0x03C9}, // Greek
+	{0x0401, 0x0401}, {0x0410, 0x044F}, {0x0451, 0x0451}, // Cyrillic
+	{0x2010, 0x2010}, {0x2013, 0x2016}, {0x2018, 0x2019}, {0x201C, 0x201D}, // Dashes and quotes
+	{0x2020, 0x2022}, {0x2024, 0x2027}, {0x2030, 0x2030}, {0x2032, 0x2033}, // Daggers, bullet, ellipsis, per mille, primes
+	{0x2035, 0x2035}, {0x203B, 0x203B}, {0x203E, 0x203E},
+	{0x20AC, 0x20AC}, {0x2103, 0x2103}, {0x2116, 0x2116}, {0x2121, 0x2122}, // €, ℃, №, ℡, ™
+	{0x2160, 0x216B}, {0x2170, 0x2179}, // Roman numerals
+	{0x2190, 0x2199}, {0x21D2, 0x21D2}, {0x21D4, 0x21D4}, // Arrows
+	{0x2200, 0x2200}, {0x2202, 0x2203}, {0x2207, 0x2208}, {0x220B, 0x220B}, // Math operators
+	{0x220F, 0x220F}, {0x2211, 0x2211}, {0x221A, 0x221A}, {0x221D, 0x2220},
+	{0x2225, 0x2225}, {0x2227, 0x222C}, {0x2234, 0x2237}, {0x2248, 0x2248},
+	{0x2260, 0x2261}, {0x2264, 0x2267}, {0x2282, 0x2283}, {0x2286, 0x2287},
+	{0x2460, 0x24E9},                                                       // Enclosed alphanumerics
+	{0x24EB, 0x254B}, {0x2550, 0x2573}, {0x2580, 0x258F}, {0x2592, 0x2595}, // Box drawing, block elements
+	{0x25A0, 0x25A1}, {0x25A3, 0x25A9}, {0x25B2, 0x25B3}, {0x25B6, 0x25B7}, // Geometric shapes
+	{0x25BC, 0x25BD}, {0x25C0, 0x25C1}, {0x25C6, 0x25C8}, {0x25CB, 0x25CB},
+	{0x25CE, 0x25D1}, {0x25E2, 0x25E5}, {0x25EF, 0x25EF},
+	{0x2605, 0x2606}, {0x2609, 0x2609}, {0x260E, 0x260F}, {0x2640, 0x2640}, // Stars, phone, gender signs
+	{0x2642, 0x2642}, {0x2660, 0x2661}, {0x2663, 0x2665}, {0x2667, 0x266A}, // Card suits, notes
+	{0x266C, 0x266D}, {0x266F, 0x266F}, {0x2776, 0x277F}, // Dingbat digits
+	{0xE000, 0xF8FF}, // Private use, where terminal fonts put icon glyphs
+	{0xFFFD, 0xFFFD}, // Replacement character
+}
+
+// ambiguousWide is --ambiguous-wide: count the East Asian Ambiguous characters two
+// columns wide, as CJK terminals draw them
+var ambiguousWide bool
+
+// runeWidth returns the number of terminal columns a rune occupies
+func runeWidth(r rune) int {
+	switch {
+	case r == 0x200D || r == 0x200B || (r >= 0xFE00 && r <= 0xFE0F):
+		// Zero-width joiner, zero-width space and variation selectors
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case ambiguousWide && inRanges(r, ambiguousRanges):
+		return 2
+	case r < 0x1100:
+		return 1
+	case inRanges(r, wideRanges):
+		return 2
+	}
+	return 1
+}
+
+// inRanges reports whether r lies in one of the sorted ranges
+func inRanges(r rune, ranges [][2]rune) bool {
+	for _, span := range ranges {
+		if r < span[0] {
+			return false
+		}
+		if r <= span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Code points that combine with the character before them into one glyph
+const (
+	zeroWidthJoiner   = 0x200D
+	emojiPresentation = 0xFE0F // Variation selector asking for the emoji form of a symbol
+)
+
+// isEmojiModifier reports whether r is a skin tone modifier, drawn as part of the emoji before it
+func isEmojiModifier(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// isRegionalIndicator reports whether r is one of the letters a flag is made of, two at a time
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// nextCluster returns the size in bytes and the width in columns of the character at
+// the start of s, taken with what terminals draw as part of it: combining marks,
+// variation selectors, skin tone modifiers, the second letter of a flag and every
+// emoji a zero-width joiner adds. U+FE0F makes a narrow symbol an emoji, two columns
+// wide. Invalid UTF-8 counts as one narrow character per byte.
+func nextCluster(s string) (int, int) {
+	r, size := utf8.DecodeRuneInString(s)
+	width := runeWidth(r)
+	if isRegionalIndicator(r) {
+		if next, n := utf8.DecodeRuneInString(s[size:]); isRegionalIndicator(next) {
+			return size + n, 2
+		}
+	}
+	for size < len(s) {
+		next, n := utf8.DecodeRuneInString(s[size:])
+		switch {
+		case next == zeroWidthJoiner:
+			size += n
+			if size < len(s) {
+				joined, m := utf8.DecodeRuneInString(s[size:])
+				size += m
+				width = max(width, runeWidth(joined))
+			}
+			continue
+		case next == emojiPresentation && width == 1:
+			width = 2
+		case isEmojiModifier(next) && width > 0:
+		case runeWidth(next) != 0:
+			return size, width
+		}
+		size += n
+	}
+	return size, width
+}
+
+// displayWidth returns the number of terminal columns a string occupies
+func displayWidth(s string) int {
+	width := 0
+	for len(s) > 0 {
+		size, w := nextCluster(s)
+		width += w
+		s = s[size:]
+	}
+	return width
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/ftree"
+	"github.com/EastTexasElectronics/File-Tree-Generator-Multiverse/Go/internal/testtree"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s           string
+		narrow, cjk int // Without and with --ambiguous-wide
+	}{
+		{"main.go", 7, 7},
+		{"日本語", 6, 6},
+		{"📁", 2, 2},
+		{"👨‍👩‍👧", 2, 2},  // Joined into one family emoji
+		{"👍🏽", 2, 2},     // With a skin tone
+		{"🇩🇪", 2, 2},     // Two regional indicators make a flag
+		{"école", 5, 5}, // A combining accent takes no column
+		{"☺️", 2, 2},     // Emoji presentation of a narrow symbol
+		{"├── ", 4, 7},
+		{"│   ", 4, 5},
+		{"±5 §3 α", 7, 10},
+		{"…", 1, 2},
+	}
+	for _, test := range tests {
+		if got := displayWidth(test.s); got != test.narrow {
+			t.Errorf("displayWidth(%q) = %d, want %d", test.s, got, test.narrow)
+		}
+	}
+	setOption(t, &ambiguousWide, true)
+	for _, test := range tests {
+		if got := displayWidth(test.s); got != test.cjk {
+			t.Errorf("--ambiguous-wide: displayWidth(%q) = %d, want %d", test.s, got, test.cjk)
+		}
+	}
+}
+
+// Truncation never cuts through an emoji sequence, a flag or a character and its marks
+func TestTruncateWidth(t *testing.T) {
+	for _, test := range []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 2, "ab"},
+		{"日本語", 5, "日本"},
+		{"a👨‍👩‍👧b", 2, "a"},
+		{"a👨‍👩‍👧b", 3, "a👨‍👩‍👧"},
+		{"🇩🇪🇫🇷", 3, "🇩🇪"},
+		{"éx", 1, "é"},
+	} {
+		if got := truncateWidth(test.s, test.width); got != test.want {
+			t.Errorf("truncateWidth(%q, %d) = %q, want %q", test.s, test.width, got, test.want)
+		}
+	}
+}
+
+// With --ambiguous-wide every preset's pipe and space prefixes take the same columns,
+// so the guides of deeper levels stay under those of the levels above
+func TestAlignStyleAmbiguousWide(t *testing.T) {
+	setOption(t, &ambiguousWide, true)
+	for name, style := range ftree.Styles {
+		aligned := alignStyle(style)
+		if err := validateStyle(aligned); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if !strings.HasPrefix(aligned.Space, style.Space) || !strings.HasPrefix(aligned.Pipe, style.Pipe) {
+			t.Errorf("%s: aligned %q and %q, want %q and %q padded", name, aligned.Pipe, aligned.Space, style.Pipe, style.Space)
+		}
+	}
+	if _, err := parseConnectors("├── ,└── ,│   ,    "); err != nil {
+		t.Errorf("custom box-drawing connectors: %v", err)
+	}
+	dir := testtree.Dir(t, "a.txt\n")
+	if _, stderr, code := runFTG(t, dir, "-d", dir, "-f", "svg", "-o", "-", "--ambiguous-wide"); code != exitUsage || !strings.Contains(stderr, "cannot be combined with -f svg") {
+		t.Errorf("-f svg --ambiguous-wide: exit code %d, %s", code, stderr)
+	}
+}
+
+// wideNames has names of emoji, CJK, joined sequences and combining and ambiguous
+// characters, with annotations long enough to wrap
+const wideNames = `
+"📁 docs/"
+src/
+"📁 docs/👨‍👩‍👧 family notes.md" size=2048
+"📁 docs/日本語のファイル.txt" size=1200000
+"📁 docs/🇩🇪 flag.txt" size=300
+"src/école ±.go" size=90000
+"ok 👍🏽.txt" size=5
+`
+
+// Wrapped and cut annotations of wide names line up under their guides at a narrow
+// terminal width, with and without --ambiguous-wide
+func TestGoldenWideNames(t *testing.T) {
+	for _, test := range []struct {
+		golden      string
+		columns     int
+		noWrap, cjk bool
+	}{
+		{"tree-wide-40.txt", 40, false, false},
+		{"tree-wide-30.txt", 30, false, false},
+		{"tree-wide-nowrap.txt", 30, true, false},
+		{"tree-wide-cjk.txt", 40, false, true},
+	} {
+		t.Run(test.golden, func(t *testing.T) {
+			got := renderFixture(t, testtree.MapFS(t, wideNames), true, func() {
+				setOption(t, &noSummary, true)
+				setOption(t, &showSizes, true)
+				setOption(t, &showMTime, true)
+				setOption(t, &wrapColumns, test.columns)
+				setOption(t, &noWrap, test.noWrap)
+				setOption(t, &ambiguousWide, test.cjk)
+				setOption(t, &connectors, connectors)
+				if test.cjk {
+					connectors = alignStyle(connectors)
+				}
+			})
+			for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+				// Only a name longer than the room left stays on a line too wide
+				if width := displayWidth(line); width > test.columns && strings.ContainsAny(line, "(…") {
+					t.Errorf("%q is %d columns wide, more than %d", line, width, test.columns)
+				}
+			}
+			testtree.Golden(t, test.golden, []byte(got))
+		})
+	}
+}
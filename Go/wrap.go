@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	wrapColumns int  // Terminal width tree lines are fitted to; 0 when the tree does not go to a terminal
+	noWrap      bool // --no-wrap: cut annotations that do not fit with "…" instead of wrapping them
+)
+
+// terminalWrapWidth returns the width to fit the tree to: that of the terminal when
+// standard output is one and the only destination, else 0. Files, the clipboard and
+// pipes are never wrapped. COLUMNS stands in where the width cannot be asked.
+func terminalWrapWidth(locations []string) int {
+	if len(locations) == 0 || postURL != "" || pipeCommand != "" {
+		return 0
+	}
+	for _, location := range locations {
+		if location != stdoutTarget {
+			return 0
+		}
+	}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&fs.ModeCharDevice == 0 {
+		return 0
+	}
+	if width := terminalWidth(os.Stdout); width > 0 {
+		return width
+	}
+	width, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	return max(width, 0)
+}
+
+// entryLeadWidth returns the columns a tree line takes before the entry's name: the
+// prefix, the connector and, outside -f text, the type tag
+func entryLeadWidth(prefix, entryType string, isLast bool) int {
+	connector := connectors.Connector(isLast)
+	width := displayWidth(prefix+connector) + 1
+	if outputFormat != formatText {
+		width += displayWidth("[" + entryType + "] ")
+	} else if entryType == "D" && trailingSlash {
+		width++ // The "/" printTextEntry adds after the name
+	}
+	return width
+}
+
+// fitLabel fits a label to wrapColumns after a lead of leadWidth columns. The name
+// always stays whole on the first line; the annotations after it that do not fit
+// move to continuation lines of available columns, breaking between words, or with
+// --no-wrap are cut with "…". It returns the first line's label and the rest.
+func fitLabel(leadWidth, available int, name, label string) (string, []string) {
+	if wrapColumns <= 0 || leadWidth+displayWidth(label) <= wrapColumns || !strings.HasPrefix(label, name) {
+		return label, nil
+	}
+	notes := strings.TrimPrefix(label, name)
+	room := wrapColumns - leadWidth - displayWidth(name)
+	if noWrap {
+		ellipsis := displayWidth("…") // Two columns with --ambiguous-wide
+		if room <= ellipsis {
+			return name, nil
+		}
+		return name + truncateWidth(notes, room-ellipsis) + "…", nil
+	}
+	first, lines := name, []string{}
+	line, width, onFirst := "", 0, true
+	for _, word := range strings.Fields(notes) {
+		wordWidth := displayWidth(word)
+		if onFirst {
+			if wordWidth+1 <= room {
+				first += " " + word
+				room -= wordWidth + 1
+				continue
+			}
+			onFirst = false
+		}
+		if line != "" && width+1+wordWidth > available {
+			lines = append(lines, line)
+			line, width = "", 0
+		}
+		if line != "" {
+			line += " "
+			width++
+		}
+		// A word wider than a whole line stands alone rather than being split
+		line += word
+		width += wordWidth
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return first, lines
+}
+
+// truncateWidth returns the longest start of s that fits in width columns, without
+// cutting through an emoji sequence or a character and its marks
+func truncateWidth(s string, width int) string {
+	used := 0
+	for i := 0; i < len(s); {
+		size, w := nextCluster(s[i:])
+		if used+w > width {
+			return s[:i]
+		}
+		used += w
+		i += size
+	}
+	return s
+}
+
+// continuationPrefix returns the guides and indentation of an entry's continuation
+// lines: the guides of the lines below, then spaces up to two past the name's column
+func continuationPrefix(newPrefix string, children bool, leadWidth int) string {
+	guide := connectors.Indent(newPrefix, !children)
+	return guide + strings.Repeat(" ", max(leadWidth+2-displayWidth(guide), 1))
+}
+
+// printContinuations writes the wrapped annotations of an entry under its name
+func printContinuations(writer io.Writer, guide string, lines []string) {
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(writer, "%s%s\n", painter.connector(guide), line); err != nil {
+			warnf("Error writing entry: %v", err)
+		}
+	}
+}
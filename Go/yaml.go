@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document without its comment
+type yamlLine struct {
+	n      int // Line number, for errors
+	indent int
+	text   string
+}
+
+// parseYAML reads the block subset of YAML layout templates need: nested mappings and
+// sequences by indentation, "- key: value" items, one-line [a, b] sequences, plain
+// and quoted scalars, and # comments. Scalars stay strings; their users convert them.
+// Anchors, tags, multi-line scalars and multiple documents are rejected.
+func parseYAML(data []byte) (map[string]any, error) {
+	var lines []yamlLine
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		raw := strings.TrimRight(yamlStripComment(scanner.Text()), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || (n == 1 && text == "---") {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", n)
+		}
+		lines = append(lines, yamlLine{n, len(raw) - len(text), text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	p := &yamlParser{lines: lines}
+	value, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].n)
+	}
+	doc, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("the document must be a mapping")
+	}
+	return doc, nil
+}
+
+// yamlStripComment removes a # comment that starts the line or follows a space,
+// outside quotes
+func yamlStripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlParser walks the comment-free lines of a document
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// block parses the mapping or sequence whose lines start at indent
+func (p *yamlParser) block(indent int) (any, error) {
+	line := p.lines[p.pos]
+	if line.indent != indent {
+		return nil, fmt.Errorf("line %d: unexpected indentation", line.n)
+	}
+	if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// sequence parses "- item" lines at indent. An item that starts a mapping continues
+// on the lines indented to its first key.
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if line.text != "-" && !strings.HasPrefix(line.text, "- ") {
+			// A key at the indent of a sequence it holds ends the sequence
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			// The item is the block on the following, deeper lines
+			p.pos++
+			if p.pos == len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, "")
+				continue
+			}
+			item, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		if _, _, isKey := yamlKey(rest); isKey {
+			// Re-read the line as the first key of a mapping indented past the dash
+			p.lines[p.pos] = yamlLine{line.n, indent + len(line.text) - len(rest), rest}
+			item, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		item, err := yamlScalarOrFlow(rest, line.n)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.pos++
+	}
+	return items, nil
+}
+
+// mapping parses "key: value" lines at indent; a key without a value holds the
+// deeper block that follows, or a sequence at the same indent
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	fields := map[string]any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, rest, ok := yamlKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", line.n)
+		}
+		if _, dup := fields[key]; dup {
+			return nil, fmt.Errorf("line %d: %q is set twice", line.n, key)
+		}
+		p.pos++
+		if rest != "" {
+			value, err := yamlScalarOrFlow(rest, line.n)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = value
+			continue
+		}
+		next := -1
+		if p.pos < len(p.lines) {
+			next = p.lines[p.pos].indent
+		}
+		switch {
+		case next > indent:
+			value, err := p.block(next)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = value
+		case next == indent && strings.HasPrefix(p.lines[p.pos].text+" ", "- "):
+			value, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = value
+		default:
+			fields[key] = nil
+		}
+	}
+	return fields, nil
+}
+
+// yamlKey splits "key: value" at the first ": " outside quotes, or a line ending in ":"
+func yamlKey(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		value, after, err := yamlQuoted(text)
+		if err != nil || !(after == ":" || strings.HasPrefix(after, ": ")) {
+			return "", "", false
+		}
+		return value, strings.TrimSpace(after[1:]), true
+	}
+	if strings.HasPrefix(text, "[") {
+		return "", "", false
+	}
+	if i := strings.Index(text, ": "); i > 0 {
+		return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), true
+	}
+	if strings.HasSuffix(text, ":") && len(text) > 1 {
+		return strings.TrimSpace(text[:len(text)-1]), "", true
+	}
+	return "", "", false
+}
+
+// yamlScalarOrFlow parses a value on one line: a [a, b] sequence or a scalar
+func yamlScalarOrFlow(text string, n int) (any, error) {
+	if !strings.HasPrefix(text, "[") {
+		value, err := yamlScalar(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		return value, nil
+	}
+	items := []any{}
+	rest := strings.TrimSpace(text[1:])
+	for !strings.HasPrefix(rest, "]") {
+		var item string
+		if strings.HasPrefix(rest, "\"") || strings.HasPrefix(rest, "'") {
+			value, after, err := yamlQuoted(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			item, rest = value, strings.TrimSpace(after)
+		} else {
+			end := strings.IndexAny(rest, ",]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated [ sequence", n)
+			}
+			item, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		items = append(items, item)
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, fmt.Errorf("line %d: expected , or ] in sequence", n)
+		}
+	}
+	if strings.TrimSpace(rest[1:]) != "" {
+		return nil, fmt.Errorf("line %d: unexpected %q after the sequence", n, rest[1:])
+	}
+	return items, nil
+}
+
+// yamlScalar returns a plain or quoted scalar as a string
+func yamlScalar(text string) (string, error) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		value, after, err := yamlQuoted(text)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(after) != "" {
+			return "", fmt.Errorf("unexpected %q after the string", after)
+		}
+		return value, nil
+	}
+	switch text[0] {
+	case '&', '*', '!', '|', '>', '{', '@', '`':
+		return "", fmt.Errorf("unsupported value %q (quote it)", text)
+	}
+	return text, nil
+}
+
+// yamlQuoted parses the quoted string at the start of text and returns the text after it
+func yamlQuoted(text string) (string, string, error) {
+	if text[0] == '\'' {
+		// Single quotes escape themselves by doubling
+		var b strings.Builder
+		for i := 1; i < len(text); i++ {
+			if text[i] != '\'' {
+				b.WriteByte(text[i])
+				continue
+			}
+			if i+1 < len(text) && text[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), text[i+1:], nil
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	}
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", text[:i+1])
+			}
+			return value, text[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}